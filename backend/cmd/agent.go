@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"reconya-ai/internal/agent"
+	"reconya-ai/internal/config"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/exclusion"
+	"reconya-ai/internal/oui"
+	"reconya-ai/internal/pingsweep"
+	"reconya-ai/internal/portscan"
+)
+
+// runAgent runs the sensor side of distributed scanning. Nothing is stored locally,
+// the device service is only used to parse scanner output.
+func runAgent() {
+	cfg, err := config.LoadAgentConfig()
+	if err != nil {
+		logger.Fatalf("Failed to load agent configuration: %v", err)
+	}
+
+	client, err := agent.NewClient(cfg)
+	if err != nil {
+		logger.Fatalf("Failed to configure agent client: %v", err)
+	}
+
+	ouiService := oui.NewOUIService(filepath.Join(filepath.Dir(cfg.StatePath), "oui"))
+	if err := ouiService.Initialize(); err != nil {
+		logger.Warnf("Failed to initialize OUI service: %v", err)
+		ouiService = nil
+	}
+
+	deviceService := device.NewDeviceService(nil, nil, nil, nil, ouiService)
+	portScanService := portscan.NewPortScanService(deviceService, nil, nil)
+	pingSweepService := pingsweep.NewPingSweepService(nil, deviceService, nil, nil, portScanService)
+	// The server sends the exclusion rules with every check-in
+	exclusionService := exclusion.NewExclusionService(nil)
+	pingSweepService.Exclusions = exclusionService
+	portScanService.Exclusions = exclusionService
+	portScanService.WebService.Exclusions = exclusionService
+	sensor := agent.NewSensor(cfg, client, pingSweepService, portScanService)
+
+	done := make(chan bool)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-stop
+		logger.Infof("Agent received %v, stopping", sig)
+		close(done)
+	}()
+
+	logger.Infof("Starting reconYa agent %s, reporting to %s", cfg.Name, cfg.ServerURL)
+	sensor.Run(done)
+	logger.Infof("Agent stopped")
+}
+
+// agentTLSConfig accepts client certificates signed by the agent CA. Certificates
+// are optional so browsers and token enrolled agents can still connect.
+func agentTLSConfig(caFile string) (*tls.Config, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read agent CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientCAs:  pool,
+		ClientAuth: tls.VerifyClientCertIfGiven,
+	}, nil
+}
@@ -3,7 +3,7 @@ package main
 import (
 	"context"
 	"database/sql"
-	"log"
+	"io"
 	"net"
 	"net/http"
 	"os"
@@ -15,198 +15,282 @@ import (
 	"time"
 
 	"reconya-ai/db"
+	"reconya-ai/internal/adhoc"
+	"reconya-ai/internal/agent"
+	"reconya-ai/internal/arpwatch"
 	"reconya-ai/internal/config"
 	"reconya-ai/internal/device"
+	"reconya-ai/internal/devicemerge"
+	"reconya-ai/internal/dhcp"
 	"reconya-ai/internal/eventlog"
+	"reconya-ai/internal/exclusion"
+	"reconya-ai/internal/inventory"
 	"reconya-ai/internal/ipv6monitor"
+	"reconya-ai/internal/jobqueue"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/neighbor"
 	"reconya-ai/internal/network"
 	"reconya-ai/internal/nicidentifier"
+	"reconya-ai/internal/oneshot"
 	"reconya-ai/internal/oui"
 	"reconya-ai/internal/pingsweep"
 	"reconya-ai/internal/portscan"
+	"reconya-ai/internal/rescan"
 	"reconya-ai/internal/scan"
+	"reconya-ai/internal/scanprofile"
 	"reconya-ai/internal/settings"
+	"reconya-ai/internal/snmp"
+	"reconya-ai/internal/supervisor"
 	"reconya-ai/internal/systemstatus"
+	"reconya-ai/internal/topology"
+	"reconya-ai/internal/trust"
+	"reconya-ai/internal/upnp"
 	"reconya-ai/internal/web"
+	"reconya-ai/internal/wol"
 	"reconya-ai/middleware"
 )
 
-func runDeviceUpdater(service *device.DeviceService, done <-chan bool) {
-	defer func() {
-		if r := recover(); r != nil {
-			errorLogger.Printf("Device updater panic recovered: %v", r)
-			errorLogger.Printf("Device updater stack trace: %s", debug.Stack())
-		}
-		infoLogger.Println("Device updater service stopped")
-	}()
-
-	ticker := time.NewTicker(5 * time.Second)
+// runEvery calls fn on every tick until ctx is cancelled. A panicking iteration is
+// logged and the loop carries on with the next tick.
+func runEvery(ctx context.Context, name string, interval time.Duration, fn func()) error {
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	infoLogger.Println("Device updater started")
 	for {
 		select {
-		case <-done:
-			infoLogger.Println("Device updater received shutdown signal")
-			return
+		case <-ctx.Done():
+			return nil
 		case <-ticker.C:
 			func() {
 				defer func() {
 					if r := recover(); r != nil {
-						errorLogger.Printf("UpdateDeviceStatuses panic: %v", r)
-						errorLogger.Printf("UpdateDeviceStatuses stack: %s", debug.Stack())
+						logger.With("stack", string(debug.Stack())).Errorf("%s iteration panic: %v", name, r)
 					}
 				}()
-				err := service.UpdateDeviceStatuses()
-				if err != nil {
-					infoLogger.Printf("Failed to update device statuses: %v", err)
-					// Add a delay after an error to allow other operations to complete
-					time.Sleep(1 * time.Second)
-				}
+				fn()
 			}()
 		}
 	}
 }
 
-func runGeolocationCacheCleanup(repo *db.GeolocationRepository, done <-chan bool) {
-	defer func() {
-		if r := recover(); r != nil {
-			errorLogger.Printf("Geolocation cache cleanup panic recovered: %v", r)
-			errorLogger.Printf("Cache cleanup stack trace: %s", debug.Stack())
+func runDeviceUpdater(ctx context.Context, service *device.DeviceService) error {
+	return runEvery(ctx, "Device updater", 5*time.Second, func() {
+		err := service.UpdateDeviceStatuses()
+		if err != nil {
+			logger.Errorf("Failed to update device statuses: %v", err)
+			// Add a delay after an error to allow other operations to complete
+			time.Sleep(1 * time.Second)
 		}
-		infoLogger.Println("Geolocation cache cleanup service stopped")
-	}()
+	})
+}
 
-	// Run cleanup every 6 hours
-	ticker := time.NewTicker(6 * time.Hour)
-	defer ticker.Stop()
+func runWakeScheduler(ctx context.Context, service *wol.WakeOnLANService) error {
+	return runEvery(ctx, "Wake scheduler", 30*time.Second, service.RunDueSchedules)
+}
 
-	infoLogger.Println("Geolocation cache cleanup service started")
-	
+func runARPWatch(ctx context.Context, service *arpwatch.ARPWatchService) error {
+	service.Listen(doneChannel(ctx))
+	// Without a raw socket the service keeps checking sweeps, there is nothing to restart
+	<-ctx.Done()
+	return nil
+}
+
+func runNeighborMonitor(ctx context.Context, service *neighbor.NeighborService) error {
+	service.Run(doneChannel(ctx))
+	return nil
+}
+
+func runDHCPProbe(ctx context.Context, service *dhcp.DHCPService) error {
+	service.ProbeAll()
+	return runEvery(ctx, "DHCP probe", 15*time.Minute, service.ProbeAll)
+}
+
+func runDHCPListener(ctx context.Context, service *dhcp.DHCPService) error {
+	service.Listen(doneChannel(ctx))
+	// Without a raw socket there is nothing to capture and nothing to restart
+	<-ctx.Done()
+	return nil
+}
+
+func runIPv6AddressExpiry(ctx context.Context, service *device.DeviceService) error {
+	return runEvery(ctx, "IPv6 address expiry", 1*time.Hour, func() {
+		expired, err := service.ExpireIPv6Addresses()
+		if err != nil {
+			logger.Errorf("IPv6 address expiry failed: %v", err)
+		} else if expired > 0 {
+			logger.Infof("Expired %d IPv6 addresses", expired)
+		}
+	})
+}
+
+func runGeolocationCacheCleanup(ctx context.Context, repo *db.GeolocationRepository) error {
 	// Run initial cleanup
-	ctx := context.Background()
 	if err := repo.CleanupExpired(ctx); err != nil {
-		errorLogger.Printf("Initial geolocation cache cleanup failed: %v", err)
+		logger.Errorf("Initial geolocation cache cleanup failed: %v", err)
 	}
 
+	// Run cleanup every 6 hours
+	return runEvery(ctx, "Geolocation cache cleanup", 6*time.Hour, func() {
+		if err := repo.CleanupExpired(ctx); err != nil {
+			logger.Errorf("Geolocation cache cleanup failed: %v", err)
+		}
+	})
+}
+
+func runNetworkDetection(ctx context.Context, nicService *nicidentifier.NicIdentifierService) error {
+	// Check for new networks every 30 seconds without creating devices/system status
+	return runEvery(ctx, "Network detection", 30*time.Second, nicService.CheckForNewNetworks)
+}
+
+// runConfigReloader reloads the configuration on SIGHUP. Changes that need a
+// restart are logged and otherwise ignored.
+func runConfigReloader(ctx context.Context, cfg *config.Config) error {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
 	for {
 		select {
-		case <-done:
-			infoLogger.Println("Geolocation cache cleanup received shutdown signal")
-			return
-		case <-ticker.C:
-			func() {
-				defer func() {
-					if r := recover(); r != nil {
-						errorLogger.Printf("Cache cleanup iteration panic: %v", r)
-					}
-				}()
-				
-				if err := repo.CleanupExpired(ctx); err != nil {
-					errorLogger.Printf("Geolocation cache cleanup failed: %v", err)
-				}
-			}()
+		case <-ctx.Done():
+			return nil
+		case <-hangup:
+			result, err := cfg.Reload()
+			if err != nil {
+				logger.Errorf("Configuration reload failed, keeping the running configuration: %v", err)
+				continue
+			}
+			logger.Infof("Configuration reloaded, applied: %v", result.Applied)
+			if len(result.RestartRequired) > 0 {
+				logger.Warnf("Configuration changes that need a restart: %v", result.RestartRequired)
+			}
 		}
 	}
 }
 
-func runNetworkDetection(nicService *nicidentifier.NicIdentifierService, done <-chan bool) {
-	defer func() {
-		if r := recover(); r != nil {
-			errorLogger.Printf("Network detection panic recovered: %v", r)
-			errorLogger.Printf("Network detection stack trace: %s", debug.Stack())
-		}
-		infoLogger.Println("Network detection service stopped")
-	}()
+// runScanManager keeps the scan manager under supervision, a running scan is
+// stopped at shutdown
+func runScanManager(ctx context.Context, scanManager *scan.ScanManager) error {
+	<-ctx.Done()
+	return scanManager.Shutdown(context.Background())
+}
 
-	// Run network detection every 30 seconds to catch new network connections
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
+// runIPv6Monitor stops passive IPv6 monitoring at shutdown, scans start it
+func runIPv6Monitor(ctx context.Context, service *ipv6monitor.IPv6MonitorService) error {
+	<-ctx.Done()
+	return service.Stop()
+}
 
-	infoLogger.Println("Network detection service started")
+// runDBManager stops the serialized database queue after every other component
+func runDBManager(ctx context.Context, dbManager *db.DBManager) error {
+	<-ctx.Done()
+	dbManager.Stop()
+	return nil
+}
 
-	for {
-		select {
-		case <-done:
-			infoLogger.Println("Network detection received shutdown signal")
-			return
-		case <-ticker.C:
-			func() {
-				defer func() {
-					if r := recover(); r != nil {
-						errorLogger.Printf("Network detection iteration panic: %v", r)
-					}
-				}()
-				
-				// Check for new networks without creating devices/system status
-				nicService.CheckForNewNetworks()
-			}()
+// serveHTTP serves until ctx is cancelled, then lets open requests finish
+func serveHTTP(ctx context.Context, server *http.Server, cfg *config.Config) error {
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if cfg.TLSCertFile != "" {
+			serveErr <- server.ServeTLS(ln, cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			serveErr <- server.Serve(ln)
 		}
+	}()
+	logger.Infof("Server is starting on port %s...", cfg.Port)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
 	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), httpDrainTimeout)
+	defer cancel()
+	return server.Shutdown(drainCtx)
 }
 
-// Global loggers for different output streams
-var (
-	infoLogger  = log.New(os.Stdout, "", log.LstdFlags)
-	errorLogger = log.New(os.Stderr, "", log.LstdFlags)
-)
+// doneChannel adapts ctx for services that stop on a closed channel
+func doneChannel(ctx context.Context) <-chan bool {
+	done := make(chan bool)
+	go func() {
+		<-ctx.Done()
+		close(done)
+	}()
+	return done
+}
+
+var logger = logging.For("main")
+
+// setupLogging applies LOG_FORMAT, LOG_LEVEL and LOG_LEVELS before anything else logs
+func setupLogging(output io.Writer) {
+	logConfig, err := config.LoadLogConfig()
+	if err == nil {
+		err = logging.Setup(logging.Options{
+			Format:     logConfig.Format,
+			Level:      logConfig.Level,
+			Levels:     logConfig.Levels,
+			BufferSize: logConfig.BufferSize,
+			Output:     output,
+		})
+	}
+	if err != nil {
+		logger.Fatalf("Failed to configure logging: %v", err)
+	}
+}
 
 func main() {
-	// Ignore common termination signals to prevent external kills
-	signal.Ignore(syscall.SIGTERM, syscall.SIGQUIT)
-
-	// Set up global panic recovery with restart
-	defer func() {
-		if r := recover(); r != nil {
-			errorLogger.Printf("FATAL PANIC in main(): %v", r)
-			errorLogger.Printf("Stack trace: %s", debug.Stack())
-			errorLogger.Printf("RESTARTING BACKEND IN 1 SECOND...")
-			time.Sleep(1 * time.Second)
-			// Restart the main function
-			main()
-		}
-	}()
+	// `reconya scan` prints its results on stdout, so it logs to stderr
+	if len(os.Args) > 1 && os.Args[1] == "scan" {
+		setupLogging(os.Stderr)
+		os.Exit(runScan(os.Args[2:]))
+	}
+
+	setupLogging(os.Stdout)
+
+	// `reconya agent` runs a remote sensor reporting to a central server
+	if len(os.Args) > 1 && os.Args[1] == "agent" {
+		runAgent()
+		return
+	}
 
-	infoLogger.Printf("Starting reconYa backend - Process ID: %d", os.Getpid())
-	infoLogger.Printf("Runtime: %s/%s, Go version: %s", runtime.GOOS, runtime.GOARCH, runtime.Version())
-	infoLogger.Printf("🛡️ Backend is protected against external termination")
+	// SIGINT, SIGTERM and SIGQUIT shut down gracefully, a second signal exits at once
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
+	defer stopSignals()
 
+	logger.Infof("Starting reconYa backend - Process ID: %d", os.Getpid())
+	logger.Infof("Runtime: %s/%s, Go version: %s", runtime.GOOS, runtime.GOARCH, runtime.Version())
+
+	// Startup failures exit non-zero so systemd or Kubernetes restart the process
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		infoLogger.Printf("Failed to load configuration: %v", err)
-		infoLogger.Printf("CRITICAL ERROR - RESTARTING IN 2 SECONDS...")
-		time.Sleep(2 * time.Second)
-		main() // Restart instead of fatal exit
-		return
+		logger.Fatalf("Failed to load configuration: %v", err)
 	}
 
 	// Create repositories factory
 	var repoFactory *db.RepositoryFactory
 	var sqliteDB *sql.DB
 
-	infoLogger.Println("Using SQLite database")
+	logger.Infof("Using SQLite database")
 	sqliteDB, err = db.ConnectToSQLite(cfg.SQLitePath)
 	if err != nil {
-		infoLogger.Printf("Failed to connect to SQLite: %v", err)
-		infoLogger.Printf("DATABASE ERROR - RESTARTING IN 3 SECONDS...")
-		time.Sleep(3 * time.Second)
-		main() // Restart instead of fatal exit
-		return
+		logger.Fatalf("Failed to connect to SQLite: %v", err)
 	}
 
 	// Initialize database schema
 	if err := db.InitializeSchema(sqliteDB); err != nil {
-		infoLogger.Printf("Failed to initialize database schema: %v", err)
-		infoLogger.Printf("SCHEMA ERROR - RESTARTING IN 3 SECONDS...")
-		time.Sleep(3 * time.Second)
-		main() // Restart instead of fatal exit
-		return
+		logger.Fatalf("Failed to initialize database schema: %v", err)
 	}
 
 	// Reset port scan cooldowns for development
-	infoLogger.Println("Resetting port scan cooldowns for development...")
+	logger.Infof("Resetting port scan cooldowns for development...")
 	if err := db.ResetPortScanCooldowns(sqliteDB); err != nil {
-		infoLogger.Printf("Warning: Failed to reset port scan cooldowns: %v", err)
+		logger.Warnf("Failed to reset port scan cooldowns: %v", err)
 	}
 
 	repoFactory = db.NewRepositoryFactory(sqliteDB, cfg.DatabaseName)
@@ -225,53 +309,102 @@ func main() {
 	// Initialize OUI service for MAC address vendor lookup
 	ouiDataPath := filepath.Join(filepath.Dir(cfg.SQLitePath), "oui")
 	ouiService := oui.NewOUIService(ouiDataPath)
-	infoLogger.Println("Initializing OUI service...")
+	logger.Infof("Initializing OUI service...")
 	if err := ouiService.Initialize(); err != nil {
-		infoLogger.Printf("Warning: Failed to initialize OUI service: %v", err)
-		infoLogger.Println("Continuing without OUI service - vendor lookup will rely on Nmap only")
+		logger.Warnf("Failed to initialize OUI service: %v", err)
+		logger.Infof("Continuing without OUI service - vendor lookup will rely on Nmap only")
 		ouiService = nil
 	} else {
 		stats := ouiService.GetStatistics()
-		infoLogger.Printf("OUI service initialized successfully - %v entries loaded, last updated: %v",
+		logger.Infof("OUI service initialized successfully - %v entries loaded, last updated: %v",
 			stats["total_entries"], stats["last_updated"])
 	}
 
 	// Initialize services with repositories
 	networkService := network.NewNetworkService(networkRepo, cfg, dbManager)
 	deviceService := device.NewDeviceService(deviceRepo, networkService, cfg, dbManager, ouiService)
+	deviceService.RegisterMetrics()
 	eventLogService := eventlog.NewEventLogService(eventLogRepo, deviceService, dbManager)
 	systemStatusService := systemstatus.NewSystemStatusService(systemStatusRepo, geolocationRepo)
 	settingsService := settings.NewSettingsService(settingsRepo)
-	portScanService := portscan.NewPortScanService(deviceService, eventLogService)
+	portScanService := portscan.NewPortScanService(deviceService, eventLogService, cfg)
 	pingSweepService := pingsweep.NewPingSweepService(cfg, deviceService, eventLogService, networkService, portScanService)
+
+	// Scan profiles set how each network is discovered, port scanned and enriched
+	scanProfileService := scanprofile.NewScanProfileService(repoFactory.NewScanProfileRepository(), networkService)
+	if err := scanProfileService.SeedBuiltins(); err != nil {
+		logger.Fatalf("Failed to seed scan profiles: %v", err)
+	}
+	portScanService.ScanProfiles = scanProfileService
+
+	// Exclusion rules keep scan stages away from protected hosts, every stage checks them
+	exclusionService := exclusion.NewExclusionService(repoFactory.NewExclusionRepository())
+	pingSweepService.Exclusions = exclusionService
+	portScanService.Exclusions = exclusionService
+	portScanService.WebService.Exclusions = exclusionService
+	fingerprintService := deviceService.FingerprintService()
+	fingerprintService.Exclusions = exclusionService
+
+	// Port, fingerprint and web scans run from a persistent queue with per-type workers
+	jobQueueService := jobqueue.NewJobQueueService(repoFactory.NewScanJobRepository())
+	portScanService.RegisterJobs(jobQueueService)
+	rescanService := rescan.NewRescanService(deviceService, portScanService, fingerprintService, jobQueueService, scanProfileService)
+
+	// Ad-hoc scans run the same pipeline as `reconya scan` and keep their results in a scratch area
+	adhocRunner := oneshot.NewRunner(pingSweepService, portScanService, portScanService.WebService, fingerprintService)
+	adhocScanService := adhoc.NewAdhocScanService(repoFactory.NewAdhocScanRepository(), adhocRunner, networkService, deviceService, scanProfileService, cfg.Tuning().PortScanWorkers)
 	
 	// Initialize IPv6 monitoring service
-	ipv6MonitorService := ipv6monitor.NewIPv6MonitorService(deviceService, networkService, infoLogger)
+	ipv6MonitorService := ipv6monitor.NewIPv6MonitorService(deviceService, networkService, logging.For("ipv6monitor"))
+	ipv6MonitorService.Exclusions = exclusionService
+	
+	// Initialize SSDP/UPnP discovery service
+	upnpService := upnp.NewUPnPService(deviceService)
+	upnpService.Exclusions = exclusionService
+	
+	// Initialize SNMP polling with per-network credentials
+	snmpService := snmp.NewSNMPService(repoFactory.NewSNMPCredentialRepository(), deviceService, cfg.SecretKey)
+	snmpService.Exclusions = exclusionService
+	pingSweepService.SNMPService = snmpService
+	
+	// Initialize topology inference from neighbor tables, switch ports and routes
+	topologyService := topology.NewTopologyService(deviceService, networkService)
+	
+	// Initialize Wake-on-LAN with scheduled wakes
+	wolService := wol.NewWakeOnLANService(repoFactory.NewWakeScheduleRepository(), deviceService, networkService, eventLogService, cfg)
+	inventoryService := inventory.NewInventoryService(repoFactory.NewInventoryRepository(), deviceService)
+	trustService := trust.NewTrustService(repoFactory.NewTrustRepository(), deviceService, networkService, eventLogService)
+	deviceMergeService := devicemerge.NewDeviceMergeService(repoFactory.NewDeviceOperationRepository(), deviceService, eventLogService)
+	
+	// Initialize ARP spoofing detection
+	arpWatchService := arpwatch.NewARPWatchService(deviceService, eventLogService)
+	
+	// Initialize rogue DHCP server detection
+	dhcpService := dhcp.NewDHCPService(repoFactory.NewDHCPRepository(), networkService, deviceService, eventLogService)
 	
 	// Initialize scan manager to control scanning
-	scanManager := scan.NewScanManager(pingSweepService, networkService, ipv6MonitorService)
+	scanManager := scan.NewScanManager(pingSweepService, networkService, ipv6MonitorService, upnpService, snmpService, topologyService, wolService, trustService, arpWatchService, jobQueueService, scanProfileService)
 
 	// NIC identification for network detection and suggestions
 	nicService := nicidentifier.NewNicIdentifierService(networkService, systemStatusService, eventLogService, deviceService, cfg)
 
-	// Create a done channel to coordinate graceful shutdown
-	done := make(chan bool)
-
 	// Trigger initial network identification and detection
 	nicService.Identify()
-	
-	// Remove automatic ping sweep - now controlled by scan manager
-	go runDeviceUpdater(deviceService, done)
-	
-	// Start periodic network detection
-	go runNetworkDetection(nicService, done)
-	
-	// Start geolocation cache cleanup routine
-	go runGeolocationCacheCleanup(geolocationRepo, done)
+
+	// Follow the kernel neighbor tables for real-time device presence
+	neighborService := neighbor.NewNeighborService(deviceService, networkService, ipv6MonitorService)
 
 	// Initialize web handlers for HTMX frontend
 	sessionSecret := "your-secret-key-here-replace-in-production"
-	webHandler := web.NewWebHandler(deviceService, eventLogService, networkService, systemStatusService, scanManager, geolocationRepo, settingsService, nicService, cfg, sessionSecret)
+	// Remote sensor agents report their scans to this server
+	agentService := agent.NewAgentService(repoFactory.NewAgentRepository(), deviceService, networkService, eventLogService, cfg)
+	agentService.Exclusions = exclusionService
+
+	// The supervisor starts the components in this order and stops them in reverse,
+	// so the HTTP server stops accepting requests first and the database goes last
+	sup := supervisor.NewSupervisor()
+
+	webHandler := web.NewWebHandler(deviceService, eventLogService, networkService, systemStatusService, scanManager, geolocationRepo, settingsService, nicService, snmpService, topologyService, wolService, inventoryService, trustService, deviceMergeService, dhcpService, agentService, jobQueueService, rescanService, adhocScanService, scanProfileService, exclusionService, sup, cfg, sessionSecret)
 	router := webHandler.SetupRoutes()
 	loggedRouter := middleware.LoggingMiddleware(router)
 
@@ -280,129 +413,94 @@ func main() {
 		Handler: loggedRouter,
 	}
 
-	infoLogger.Println("Backend initialization completed successfully")
-
-	// Channel to signal server startup completion (buffered to prevent blocking)
-	serverReady := make(chan bool, 1)
-
-	go func() {
-		infoLogger.Printf("Server is starting on port %s...", cfg.Port)
-
-		// Test if port is available before starting
-		ln, err := net.Listen("tcp", ":"+cfg.Port)
+	// Agents may authenticate with a client certificate signed by the agent CA
+	if cfg.AgentClientCAFile != "" {
+		tlsConfig, err := agentTLSConfig(cfg.AgentClientCAFile)
 		if err != nil {
-			infoLogger.Printf("Port %s is not available: %v", cfg.Port, err)
-			select {
-			case serverReady <- false:
-			default:
-			}
-			return
-		}
-		ln.Close()
-
-		// Start the actual server
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			infoLogger.Printf("Server ListenAndServe error: %v", err)
-			// Signal background services to stop
-			close(done)
-			select {
-			case serverReady <- false:
-			default:
-			}
-			infoLogger.Printf("SERVER ERROR - RESTARTING IN 2 SECONDS...")
-			time.Sleep(2 * time.Second)
-			main() // Restart instead of fatal exit
-			return
-		}
-		infoLogger.Println("Server ListenAndServe has exited normally")
-	}()
-
-	// Wait for server to be ready or timeout after 5 seconds
-	go func() {
-		time.Sleep(500 * time.Millisecond) // Give server time to start
-		// Test if server is actually responding
-		resp, err := http.Get("http://localhost:" + cfg.Port + "/")
-		if err == nil {
-			resp.Body.Close()
-			select {
-			case serverReady <- true:
-			default:
-				// Channel full or closed, ignore
-			}
+			logger.Errorf("Agent client certificates disabled: %v", err)
 		} else {
-			infoLogger.Printf("Server health check failed: %v", err)
+			server.TLSConfig = tlsConfig
 		}
-	}()
-
-	// Wait for startup completion with timeout
-	select {
-	case ready := <-serverReady:
-		if ready {
-			infoLogger.Printf("✅ reconYa backend is ready and accepting connections on port %s", cfg.Port)
-			infoLogger.Println("🚀 Backend startup completed successfully")
-			infoLogger.Printf("[INFO] Server started successfully on port %s", cfg.Port)
-			infoLogger.Println("[READY] reconYa backend is ready to serve requests")
-		} else {
-			infoLogger.Println("❌ Backend startup failed")
-		}
-	case <-time.After(10 * time.Second):
-		infoLogger.Println("⚠️ Backend startup timeout - server may still be initializing")
 	}
 
-	waitForShutdown(server, done)
-}
-
+	sup.Add(supervisor.Component{
+		Name:  "database manager",
+		Run:   func(ctx context.Context) error { return runDBManager(ctx, dbManager) },
+		Check: sqliteDB.PingContext,
+	})
+	sup.Add(supervisor.Component{Name: "config reloader", Run: func(ctx context.Context) error { return runConfigReloader(ctx, cfg) }})
+	sup.Add(supervisor.Component{Name: "device updater", Run: func(ctx context.Context) error { return runDeviceUpdater(ctx, deviceService) }})
+	sup.Add(supervisor.Component{Name: "network detection", Run: func(ctx context.Context) error { return runNetworkDetection(ctx, nicService) }})
+	sup.Add(supervisor.Component{Name: "geolocation cleanup", Run: func(ctx context.Context) error { return runGeolocationCacheCleanup(ctx, geolocationRepo) }})
+	sup.Add(supervisor.Component{Name: "wake scheduler", Run: func(ctx context.Context) error { return runWakeScheduler(ctx, wolService) }})
+	sup.Add(supervisor.Component{Name: "ARP watch", Run: func(ctx context.Context) error { return runARPWatch(ctx, arpWatchService) }})
+	sup.Add(supervisor.Component{Name: "DHCP probe", Run: func(ctx context.Context) error { return runDHCPProbe(ctx, dhcpService) }})
+	sup.Add(supervisor.Component{Name: "DHCP listener", Run: func(ctx context.Context) error { return runDHCPListener(ctx, dhcpService) }})
+	sup.Add(supervisor.Component{Name: "IPv6 address expiry", Run: func(ctx context.Context) error { return runIPv6AddressExpiry(ctx, deviceService) }})
+	sup.Add(supervisor.Component{Name: "neighbor monitor", Run: func(ctx context.Context) error { return runNeighborMonitor(ctx, neighborService) }})
+	sup.Add(supervisor.Component{Name: "IPv6 monitor", Run: func(ctx context.Context) error { return runIPv6Monitor(ctx, ipv6MonitorService) }})
+	sup.Add(supervisor.Component{Name: "scan job queue", Run: jobQueueService.Run})
+	sup.Add(supervisor.Component{Name: "ad-hoc scans", Run: adhocScanService.Run})
+	sup.Add(supervisor.Component{Name: "scan manager", Run: func(ctx context.Context) error { return runScanManager(ctx, scanManager) }})
+	sup.Add(supervisor.Component{Name: "HTTP server", Run: func(ctx context.Context) error { return serveHTTP(ctx, server, cfg) }})
+
+	logger.Infof("Backend initialization completed successfully")
+
+	rootCtx, cancelRoot := context.WithCancel(context.Background())
+	defer cancelRoot()
+	sup.Start(rootCtx)
+
+	if waitUntilReady(signalCtx, sup, startupTimeout) {
+		logger.Infof("✅ reconYa backend is ready and accepting connections on port %s", cfg.Port)
+		logger.Infof("[READY] reconYa backend is ready to serve requests")
+	} else if signalCtx.Err() == nil {
+		logger.Infof("⚠️ Backend is not ready yet, see /readyz for the components still starting")
+	}
 
-func waitForShutdown(server *http.Server, done chan bool) {
-	stop := make(chan os.Signal, 1)
-	// Only listen for manual interrupt (Ctrl+C), ignore automated termination
-	signal.Notify(stop, os.Interrupt)
+	<-signalCtx.Done()
+	// Restore default signal handling so a second signal terminates immediately
+	stopSignals()
+	logger.Infof("Shutdown signal received, stopping services (deadline %v)...", shutdownTimeout)
 
-	// Log runtime and system information for debugging
-	infoLogger.Printf("Runtime info - OS: %s, Arch: %s, Go version: %s", runtime.GOOS, runtime.GOARCH, runtime.Version())
-	infoLogger.Printf("Process ID: %d", os.Getpid())
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	if err := sup.Stop(shutdownCtx); err != nil {
+		logger.Errorf("Shutdown did not complete: %v", err)
+		os.Exit(1)
+	}
+	if err := sqliteDB.Close(); err != nil {
+		logger.Errorf("Failed to close database: %v", err)
+	}
+	logger.Infof("[SUCCESS] Services stopped")
+}
 
-	infoLogger.Println("Waiting for interrupt signal (Ctrl+C) to shutdown...")
-	infoLogger.Println("Server is running and ready to accept connections...")
+const (
+	// startupTimeout is how long startup waits for every component to become ready
+	// before logging that the backend is ready
+	startupTimeout = 10 * time.Second
+	// shutdownTimeout fits within the default grace periods of systemd and Kubernetes
+	shutdownTimeout = 25 * time.Second
+	// httpDrainTimeout bounds how long open requests may take at shutdown
+	httpDrainTimeout = 10 * time.Second
+)
 
-	// Add a ticker to show the server is alive
-	ticker := time.NewTicker(30 * time.Second)
+// waitUntilReady polls the supervisor until all components are ready, ctx is
+// cancelled or the timeout passes
+func waitUntilReady(ctx context.Context, sup *supervisor.Supervisor, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(200 * time.Millisecond)
 	defer ticker.Stop()
 
-	// Add a context with cancel to handle potential deadlocks
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
 	for {
+		if ready, _ := sup.Ready(ctx); ready {
+			return true
+		}
 		select {
-		case sig := <-stop:
-			infoLogger.Printf("Received shutdown signal: %v", sig)
-
-			// Signal background services to stop
-			close(done)
-
-			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
-			defer shutdownCancel()
-
-			infoLogger.Println("Shutting down the server...")
-			if err := server.Shutdown(shutdownCtx); err != nil {
-				errorLogger.Printf("Server Shutdown error: %v", err)
-				// Force exit after timeout
-				errorLogger.Println("Forcing shutdown...")
-				os.Exit(1)
-			}
-			infoLogger.Println("[SUCCESS] Services stopped")
-			return
+		case <-ctx.Done():
+			return false
+		case <-deadline:
+			return false
 		case <-ticker.C:
-			infoLogger.Println("Server heartbeat: Still running...")
-			// Check if context was cancelled (indicates shutdown in progress)
-			select {
-			case <-ctx.Done():
-				infoLogger.Println("Context cancelled, shutting down...")
-				return
-			default:
-				// Continue normal operation
-			}
 		}
 	}
 }
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/config"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/exclusion"
+	"reconya-ai/internal/fingerprint"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/network"
+	"reconya-ai/internal/oneshot"
+	"reconya-ai/internal/oui"
+	"reconya-ai/internal/pingsweep"
+	"reconya-ai/internal/portscan"
+	"reconya-ai/internal/scanprofile"
+	"reconya-ai/models"
+)
+
+const scanUsage = `Usage: reconya scan [flags] <cidr or address>
+
+Sweeps the target once, port scans, fetches web pages and fingerprints every host
+found, and prints the results. Nothing is stored unless -db is given.
+
+Flags:
+`
+
+// runScan implements `reconya scan`, it returns the process exit code
+func runScan(args []string) int {
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), scanUsage)
+		flags.PrintDefaults()
+	}
+	format := flags.String("format", "table", "output format: "+strings.Join(oneshot.Formats, ", "))
+	profileName := flags.String("profile", "standard", "scan profile: "+strings.Join(scanprofile.BuiltinNames(), ", "))
+	concurrency := flags.Int("concurrency", 0, "hosts scanned at once (default: scanning.port_scan_workers)")
+	outputPath := flags.String("o", "", "write the results to this file instead of stdout")
+	dbPath := flags.String("db", "", "also save the devices into this existing reconya SQLite database")
+	verbose := flags.Bool("v", false, "log progress to stderr")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	profile, err := scanprofile.Builtin(*profileName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unknown profile %q, expected one of %s\n", *profileName, strings.Join(scanprofile.BuiltinNames(), ", "))
+		return 2
+	}
+	if !isFormat(*format) {
+		fmt.Fprintf(os.Stderr, "unknown format %q, expected one of %s\n", *format, strings.Join(oneshot.Formats, ", "))
+		return 2
+	}
+	target, err := oneshot.NormalizeTarget(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if !*verbose {
+		logging.SetLevels(slog.LevelWarn, nil)
+	}
+
+	cfg, err := config.LoadTuning()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load configuration: %v\n", err)
+		return 1
+	}
+	if *concurrency < 1 {
+		*concurrency = cfg.Tuning().PortScanWorkers
+	}
+
+	var store *scanStore
+	if *dbPath != "" {
+		store, err = openScanStore(*dbPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open database: %v\n", err)
+			return 1
+		}
+		defer store.Close()
+	}
+
+	ouiService := oui.NewOUIService(filepath.Join("data", "oui"))
+	if err := ouiService.Initialize(); err != nil {
+		logger.Warnf("Failed to initialize OUI service: %v", err)
+		ouiService = nil
+	}
+
+	// As for agents, the device service only parses scanner output here
+	deviceService := device.NewDeviceService(nil, nil, cfg, nil, ouiService)
+	portScanService := portscan.NewPortScanService(deviceService, nil, cfg)
+	pingSweepService := pingsweep.NewPingSweepService(cfg, deviceService, nil, nil, portScanService)
+	fingerprintService := fingerprint.NewFingerprintService()
+	if store != nil {
+		// The exclusion rules of the database apply to scans saved into it
+		pingSweepService.Exclusions = store.exclusions
+		portScanService.Exclusions = store.exclusions
+		portScanService.WebService.Exclusions = store.exclusions
+		fingerprintService.Exclusions = store.exclusions
+	}
+	runner := oneshot.NewRunner(pingSweepService, portScanService, portScanService.WebService, fingerprintService)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, runErr := runner.Run(ctx, oneshot.Options{Target: target, Profile: profile, Concurrency: *concurrency})
+	if result == nil {
+		fmt.Fprintf(os.Stderr, "scan failed: %v\n", runErr)
+		return 1
+	}
+	if runErr != nil {
+		fmt.Fprintf(os.Stderr, "scan interrupted, printing the hosts found so far\n")
+	}
+
+	var out io.Writer = os.Stdout
+	if *outputPath != "" {
+		file, err := os.Create(*outputPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create output file: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := oneshot.Write(out, *format, result); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write results: %v\n", err)
+		return 1
+	}
+
+	if store != nil {
+		saved, err := store.Save(target, result.Devices)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to save results: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "saved %d devices to %s\n", saved, *dbPath)
+	}
+	if len(result.Errors) > 0 || runErr != nil {
+		return 1
+	}
+	return 0
+}
+
+func isFormat(format string) bool {
+	for _, name := range oneshot.Formats {
+		if name == format {
+			return true
+		}
+	}
+	return false
+}
+
+// scanStore saves `reconya scan` results into a database the server created
+type scanStore struct {
+	close          func() error
+	networkService *network.NetworkService
+	deviceService  *device.DeviceService
+	exclusions     *exclusion.ExclusionService
+}
+
+func openScanStore(path string) (*scanStore, error) {
+	// Only an existing database, a typo should not create a new empty one
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	sqliteDB, err := db.ConnectToSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.InitializeSchema(sqliteDB); err != nil {
+		sqliteDB.Close()
+		return nil, err
+	}
+
+	repoFactory := db.NewRepositoryFactory(sqliteDB, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	dbManager := db.NewDBManager()
+	networkService := network.NewNetworkService(repoFactory.NewNetworkRepository(), nil, dbManager)
+	return &scanStore{
+		close: func() error {
+			dbManager.Stop()
+			return sqliteDB.Close()
+		},
+		networkService: networkService,
+		deviceService:  device.NewDeviceService(repoFactory.NewDeviceRepository(), networkService, nil, dbManager, nil),
+		exclusions:     exclusion.NewExclusionService(repoFactory.NewExclusionRepository()),
+	}, nil
+}
+
+// Save records the devices under the network with the scanned CIDR, creating it
+// when the server does not know it yet
+func (s *scanStore) Save(cidr string, devices []models.Device) (int, error) {
+	n, err := s.networkService.FindOrCreate(cidr)
+	if err != nil {
+		return 0, err
+	}
+	saved := 0
+	for i := range devices {
+		d := devices[i]
+		d.NetworkID = n.ID
+		if _, err := s.deviceService.CreateOrUpdate(&d); err != nil {
+			return saved, fmt.Errorf("device %s: %w", d.IPv4, err)
+		}
+		saved++
+	}
+	return saved, nil
+}
+
+func (s *scanStore) Close() error {
+	return s.close()
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reconya-ai/models"
+	"time"
+)
+
+// AdhocScanRepository stores ad-hoc scans and the devices they found. Devices
+// are kept as JSON on the scan so they never show up in the inventory.
+type AdhocScanRepository struct {
+	db *sql.DB
+}
+
+func NewAdhocScanRepository(db *sql.DB) *AdhocScanRepository {
+	return &AdhocScanRepository{db: db}
+}
+
+const adhocScanColumns = `id, targets, profile, status, progress, devices, errors, error, network_id,
+	created_at, updated_at, started_at, finished_at`
+
+// Create stores a new scan, assigning its ID
+func (r *AdhocScanRepository) Create(ctx context.Context, scan *models.AdhocScan) (*models.AdhocScan, error) {
+	now := time.Now().UTC()
+	scan.ID = GenerateID()
+	scan.CreatedAt = now
+	scan.UpdatedAt = now
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO adhoc_scans (`+adhocScanColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		scan.ID, nullableJSON(scan.Targets), scan.Profile, string(scan.Status), scan.Progress,
+		nullableJSON(scan.Devices), nullableJSON(scan.Errors), nullableString(&scan.Error), nullableString(&scan.NetworkID),
+		now, now, nullableTime(scan.StartedAt), nullableTime(scan.FinishedAt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create adhoc scan: %w", err)
+	}
+	return scan, nil
+}
+
+// Update saves the status, progress and results of a scan
+func (r *AdhocScanRepository) Update(ctx context.Context, scan *models.AdhocScan) error {
+	scan.UpdatedAt = time.Now().UTC()
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE adhoc_scans SET status = ?, progress = ?, devices = ?, errors = ?, error = ?, network_id = ?,
+			updated_at = ?, started_at = ?, finished_at = ? WHERE id = ?`,
+		string(scan.Status), scan.Progress, nullableJSON(scan.Devices), nullableJSON(scan.Errors),
+		nullableString(&scan.Error), nullableString(&scan.NetworkID), scan.UpdatedAt,
+		nullableTime(scan.StartedAt), nullableTime(scan.FinishedAt), scan.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update adhoc scan: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// FailActive marks scans left waiting or running by a previous process as failed
+func (r *AdhocScanRepository) FailActive(ctx context.Context, reason string) (int64, error) {
+	now := time.Now().UTC()
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE adhoc_scans SET status = ?, error = ?, finished_at = ?, updated_at = ? WHERE status IN (?, ?)`,
+		string(models.AdhocScanFailed), reason, now, now, string(models.AdhocScanQueued), string(models.AdhocScanRunning))
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail active adhoc scans: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// FindByID retrieves a single scan with its devices
+func (r *AdhocScanRepository) FindByID(ctx context.Context, id string) (*models.AdhocScan, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+adhocScanColumns+` FROM adhoc_scans WHERE id = ?`, id)
+	scan, err := scanAdhocScan(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find adhoc scan: %w", err)
+	}
+	return scan, nil
+}
+
+// FindAll lists scans, newest first
+func (r *AdhocScanRepository) FindAll(ctx context.Context) ([]*models.AdhocScan, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+adhocScanColumns+` FROM adhoc_scans ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying adhoc scans: %w", err)
+	}
+	defer rows.Close()
+
+	var scans []*models.AdhocScan
+	for rows.Next() {
+		scan, err := scanAdhocScan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning adhoc scan: %w", err)
+		}
+		scans = append(scans, scan)
+	}
+	return scans, rows.Err()
+}
+
+// Delete removes a scan and the devices it holds
+func (r *AdhocScanRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM adhoc_scans WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete adhoc scan: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanAdhocScan(row rowScanner) (*models.AdhocScan, error) {
+	var scan models.AdhocScan
+	var status, targets string
+	var devices, hostErrors, scanError, networkID sql.NullString
+	var startedAt, finishedAt sql.NullTime
+
+	err := row.Scan(&scan.ID, &targets, &scan.Profile, &status, &scan.Progress, &devices, &hostErrors, &scanError,
+		&networkID, &scan.CreatedAt, &scan.UpdatedAt, &startedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	scan.Status = models.AdhocScanStatus(status)
+	scan.Error = scanError.String
+	scan.NetworkID = networkID.String
+	if err := json.Unmarshal([]byte(targets), &scan.Targets); err != nil {
+		return nil, fmt.Errorf("invalid targets: %w", err)
+	}
+	if devices.Valid && devices.String != "" {
+		if err := json.Unmarshal([]byte(devices.String), &scan.Devices); err != nil {
+			return nil, fmt.Errorf("invalid devices: %w", err)
+		}
+	}
+	if hostErrors.Valid && hostErrors.String != "" {
+		if err := json.Unmarshal([]byte(hostErrors.String), &scan.Errors); err != nil {
+			return nil, fmt.Errorf("invalid errors: %w", err)
+		}
+	}
+	if startedAt.Valid {
+		scan.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		scan.FinishedAt = &finishedAt.Time
+	}
+	return &scan, nil
+}
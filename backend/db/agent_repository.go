@@ -0,0 +1,208 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"reconya-ai/models"
+)
+
+// AgentRepository stores remote sensor agents and the networks assigned to them.
+// API keys are only stored as hashes, hashing is left to the caller.
+type AgentRepository struct {
+	db *sql.DB
+}
+
+func NewAgentRepository(db *sql.DB) *AgentRepository {
+	return &AgentRepository{db: db}
+}
+
+const agentColumns = `id, name, hostname, platform, enrolled_via, remote_addr, last_error,
+	last_check_in, last_report_at, created_at, updated_at`
+
+// FindByID retrieves an agent with its networks
+func (r *AgentRepository) FindByID(ctx context.Context, id string) (*models.Agent, error) {
+	return r.findOne(ctx, `SELECT `+agentColumns+` FROM agents WHERE id = ?`, id)
+}
+
+// FindByName retrieves an agent by its unique name
+func (r *AgentRepository) FindByName(ctx context.Context, name string) (*models.Agent, error) {
+	return r.findOne(ctx, `SELECT `+agentColumns+` FROM agents WHERE name = ?`, name)
+}
+
+// FindByKeyHash retrieves the agent an API key was issued to
+func (r *AgentRepository) FindByKeyHash(ctx context.Context, keyHash string) (*models.Agent, error) {
+	return r.findOne(ctx, `SELECT `+agentColumns+` FROM agents WHERE key_hash = ?`, keyHash)
+}
+
+func (r *AgentRepository) findOne(ctx context.Context, query string, args ...interface{}) (*models.Agent, error) {
+	agent, err := scanAgent(r.db.QueryRowContext(ctx, query, args...))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find agent: %w", err)
+	}
+	if agent.NetworkIDs, err = r.findNetworkIDs(ctx, agent.ID); err != nil {
+		return nil, err
+	}
+	return agent, nil
+}
+
+// FindAll returns every agent by name
+func (r *AgentRepository) FindAll(ctx context.Context) ([]*models.Agent, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+agentColumns+` FROM agents ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying agents: %w", err)
+	}
+
+	var agents []*models.Agent
+	for rows.Next() {
+		agent, err := scanAgent(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning agent: %w", err)
+		}
+		agents = append(agents, agent)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, agent := range agents {
+		if agent.NetworkIDs, err = r.findNetworkIDs(ctx, agent.ID); err != nil {
+			return nil, err
+		}
+	}
+	return agents, nil
+}
+
+func (r *AgentRepository) findNetworkIDs(ctx context.Context, agentID string) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT network_id FROM agent_networks WHERE agent_id = ? ORDER BY network_id`, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying agent networks: %w", err)
+	}
+	defer rows.Close()
+
+	networkIDs := make([]string, 0)
+	for rows.Next() {
+		var networkID string
+		if err := rows.Scan(&networkID); err != nil {
+			return nil, fmt.Errorf("error scanning agent network: %w", err)
+		}
+		networkIDs = append(networkIDs, networkID)
+	}
+	return networkIDs, rows.Err()
+}
+
+// Create stores a new agent. keyHash may be empty for agents that authenticate
+// with a client certificate.
+func (r *AgentRepository) Create(ctx context.Context, agent *models.Agent, keyHash string) error {
+	if agent.ID == "" {
+		agent.ID = GenerateID()
+	}
+	now := time.Now()
+	agent.CreatedAt = now
+	agent.UpdatedAt = now
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO agents (id, name, hostname, platform, key_hash, enrolled_via, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		agent.ID, agent.Name, nullableString(&agent.Hostname), nullableString(&agent.Platform), nullableString(&keyHash),
+		agent.EnrolledVia, agent.CreatedAt, agent.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create agent: %w", err)
+	}
+	return nil
+}
+
+// SetKeyHash replaces the API key of an agent, the previous key stops working
+func (r *AgentRepository) SetKeyHash(ctx context.Context, id, keyHash string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE agents SET key_hash = ?, enrolled_via = ?, updated_at = ? WHERE id = ?`,
+		keyHash, "token", time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update agent key: %w", err)
+	}
+	return nil
+}
+
+// RecordCheckIn stores what an agent reported about itself when checking in
+func (r *AgentRepository) RecordCheckIn(ctx context.Context, agent *models.Agent, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE agents SET hostname = ?, platform = ?, remote_addr = ?, last_error = ?, last_check_in = ?, updated_at = ?
+		WHERE id = ?`,
+		nullableString(&agent.Hostname), nullableString(&agent.Platform), nullableString(&agent.RemoteAddr),
+		nullableString(&agent.LastError), at, at, agent.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record agent check-in: %w", err)
+	}
+	return nil
+}
+
+// RecordReport stores when an agent last delivered scan results
+func (r *AgentRepository) RecordReport(ctx context.Context, id string, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE agents SET last_report_at = ?, updated_at = ? WHERE id = ?`, at, at, id)
+	if err != nil {
+		return fmt.Errorf("failed to record agent report: %w", err)
+	}
+	return nil
+}
+
+// SetNetworks replaces the networks assigned to an agent
+func (r *AgentRepository) SetNetworks(ctx context.Context, id string, networkIDs []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM agent_networks WHERE agent_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to clear agent networks: %w", err)
+	}
+	for _, networkID := range networkIDs {
+		_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO agent_networks (agent_id, network_id) VALUES (?, ?)`, id, networkID)
+		if err != nil {
+			return fmt.Errorf("failed to assign network to agent: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE agents SET updated_at = ? WHERE id = ?`, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to update agent: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Delete removes an agent, its key stops working. Devices it reported are kept.
+func (r *AgentRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM agents WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete agent: %w", err)
+	}
+	return nil
+}
+
+func scanAgent(row rowScanner) (*models.Agent, error) {
+	var agent models.Agent
+	var hostname, platform, remoteAddr, lastError sql.NullString
+	var lastCheckIn, lastReportAt sql.NullTime
+
+	err := row.Scan(&agent.ID, &agent.Name, &hostname, &platform, &agent.EnrolledVia, &remoteAddr, &lastError,
+		&lastCheckIn, &lastReportAt, &agent.CreatedAt, &agent.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	agent.Hostname = hostname.String
+	agent.Platform = platform.String
+	agent.RemoteAddr = remoteAddr.String
+	agent.LastError = lastError.String
+	if lastCheckIn.Valid {
+		agent.LastCheckIn = &lastCheckIn.Time
+	}
+	if lastReportAt.Valid {
+		agent.LastReportAt = &lastReportAt.Time
+	}
+	return &agent, nil
+}
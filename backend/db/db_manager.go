@@ -2,21 +2,30 @@ package db
 
 import (
 	"context"
-	"log"
+	"reconya-ai/internal/metrics"
 	"reconya-ai/models"
 	"time"
 )
 
+var (
+	dbQueueWait = metrics.NewHistogramVec("reconya_db_queue_wait_seconds",
+		"Time database operations wait in the DBManager queue", metrics.DefaultBuckets)
+	dbOperationDuration = metrics.NewHistogramVec("reconya_db_operation_duration_seconds",
+		"Time spent executing serialized database operations", metrics.DefaultBuckets)
+)
+
 // Operation represents a database operation that needs to be executed
 type Operation struct {
-	Execute func() error
-	Result  chan error
+	Execute  func() error
+	Result   chan error
+	queuedAt time.Time
 }
 
 // OperationWithResult represents a database operation that returns a result
 type OperationWithResult struct {
-	Execute func() (interface{}, error)
-	Result  chan OperationResult
+	Execute  func() (interface{}, error)
+	Result   chan OperationResult
+	queuedAt time.Time
 }
 
 // OperationResult contains the result of an operation
@@ -40,9 +49,13 @@ func NewDBManager() *DBManager {
 		stopping:     make(chan struct{}),
 	}
 
+	metrics.NewGaugeFunc("reconya_db_queue_depth", "Database operations waiting in the DBManager queue", func() float64 {
+		return float64(len(m.opQueue) + len(m.resultOpQueue))
+	})
+
 	// Start the worker goroutine
 	go m.worker()
-	log.Println("Database access manager started")
+	logger.Infof("Database access manager started")
 
 	return m
 }
@@ -52,10 +65,16 @@ func (m *DBManager) worker() {
 	for {
 		select {
 		case op := <-m.opQueue:
+			startedAt := time.Now()
+			dbQueueWait.Observe(startedAt.Sub(op.queuedAt).Seconds())
 			err := op.Execute()
+			dbOperationDuration.Observe(time.Since(startedAt).Seconds())
 			op.Result <- err
 		case op := <-m.resultOpQueue:
+			startedAt := time.Now()
+			dbQueueWait.Observe(startedAt.Sub(op.queuedAt).Seconds())
 			data, err := op.Execute()
+			dbOperationDuration.Observe(time.Since(startedAt).Seconds())
 			op.Result <- OperationResult{Data: data, Error: err}
 		case <-m.stopping:
 			return
@@ -67,8 +86,9 @@ func (m *DBManager) worker() {
 func (m *DBManager) ExecuteOperation(execute func() error) error {
 	resultChan := make(chan error, 1)
 	m.opQueue <- Operation{
-		Execute: execute,
-		Result:  resultChan,
+		Execute:  execute,
+		Result:   resultChan,
+		queuedAt: time.Now(),
 	}
 	return <-resultChan
 }
@@ -77,8 +97,9 @@ func (m *DBManager) ExecuteOperation(execute func() error) error {
 func (m *DBManager) ExecuteOperationWithResult(execute func() (interface{}, error)) (interface{}, error) {
 	resultChan := make(chan OperationResult, 1)
 	m.resultOpQueue <- OperationWithResult{
-		Execute: execute,
-		Result:  resultChan,
+		Execute:  execute,
+		Result:   resultChan,
+		queuedAt: time.Now(),
 	}
 	result := <-resultChan
 	return result.Data, result.Error
@@ -103,9 +124,9 @@ func (m *DBManager) CreateOrUpdateDevice(repo DeviceRepository, ctx context.Cont
 }
 
 // UpdateDeviceStatuses serializes access to device status updates
-func (m *DBManager) UpdateDeviceStatuses(repo DeviceRepository, ctx context.Context, timeout time.Duration) error {
+func (m *DBManager) UpdateDeviceStatuses(repo DeviceRepository, ctx context.Context, idleAfter, offlineAfter time.Duration) error {
 	return m.ExecuteOperation(func() error {
-		return repo.UpdateDeviceStatuses(ctx, timeout)
+		return repo.UpdateDeviceStatuses(ctx, idleAfter, offlineAfter)
 	})
 }
 
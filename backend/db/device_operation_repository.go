@@ -0,0 +1,428 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reconya-ai/models"
+	"time"
+)
+
+// DeviceOperationRepository applies manual device merges and splits in a single
+// transaction each and keeps the record needed to undo them
+type DeviceOperationRepository struct {
+	db *sql.DB
+}
+
+func NewDeviceOperationRepository(db *sql.DB) *DeviceOperationRepository {
+	return &DeviceOperationRepository{db: db}
+}
+
+// FindByID returns an operation with its snapshot
+func (r *DeviceOperationRepository) FindByID(ctx context.Context, id string) (*models.DeviceOperation, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT id, type, device_id, other_device_id, mac, description, snapshot, created_at, undone_at
+		FROM device_operations WHERE id = ?`, id)
+	return scanDeviceOperation(row)
+}
+
+// List returns operations newest first. A device ID limits the result to the
+// operations that kept, removed or created that device.
+func (r *DeviceOperationRepository) List(ctx context.Context, deviceID string) ([]*models.DeviceOperation, error) {
+	query := `
+		SELECT id, type, device_id, other_device_id, mac, description, snapshot, created_at, undone_at
+		FROM device_operations`
+	var args []interface{}
+	if deviceID != "" {
+		query += ` WHERE device_id = ? OR other_device_id = ?`
+		args = append(args, deviceID, deviceID)
+	}
+	query += ` ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying device operations: %w", err)
+	}
+	defer rows.Close()
+
+	var operations []*models.DeviceOperation
+	for rows.Next() {
+		op, err := scanDeviceOperation(rows)
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, op)
+	}
+	return operations, rows.Err()
+}
+
+// Merge stores the merged device, moves the event logs, IPv6 addresses and inventory
+// assignments of the removed device over to it and deletes the removed device. The
+// snapshot of the operation must hold both devices as they were before the merge.
+func (r *DeviceOperationRepository) Merge(ctx context.Context, op *models.DeviceOperation, merged *models.Device) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	snapshot := op.Snapshot
+	keptID, removedID := op.DeviceID, op.OtherDeviceID
+
+	snapshot.EventLogIDs, err = queryInt64s(ctx, tx, `SELECT id FROM event_logs WHERE device_id = ?`, removedID)
+	if err != nil {
+		return fmt.Errorf("error querying event logs: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE event_logs SET device_id = ? WHERE device_id = ?`, keptID, removedID); err != nil {
+		return fmt.Errorf("error moving event logs: %w", err)
+	}
+
+	// Recorded addresses would be deleted with the removed device
+	snapshot.IPv6Addresses, err = queryStrings(ctx, tx, `SELECT address FROM device_ipv6_addresses WHERE device_id = ?`, removedID)
+	if err != nil {
+		return fmt.Errorf("error querying IPv6 addresses: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE device_ipv6_addresses SET device_id = ? WHERE device_id = ?`, keptID, removedID); err != nil {
+		return fmt.Errorf("error moving IPv6 addresses: %w", err)
+	}
+
+	snapshot.TagIDs, snapshot.AddedTagIDs, err = moveAssignments(ctx, tx, "device_tags", "tag_id", removedID, keptID)
+	if err != nil {
+		return err
+	}
+	snapshot.GroupIDs, snapshot.AddedGroupIDs, err = moveAssignments(ctx, tx, "device_group_members", "group_id", removedID, keptID)
+	if err != nil {
+		return err
+	}
+
+	// Custom field values of the kept device win over those of the removed one
+	snapshot.FieldValues = make(map[string]string)
+	rows, err := tx.QueryContext(ctx, `SELECT field_id, value FROM device_custom_values WHERE device_id = ?`, removedID)
+	if err != nil {
+		return fmt.Errorf("error querying custom field values: %w", err)
+	}
+	for rows.Next() {
+		var fieldID, value string
+		if err := rows.Scan(&fieldID, &value); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning custom field value: %w", err)
+		}
+		snapshot.FieldValues[fieldID] = value
+	}
+	rows.Close()
+	for fieldID, value := range snapshot.FieldValues {
+		result, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO device_custom_values (device_id, field_id, value) VALUES (?, ?, ?)`, keptID, fieldID, value)
+		if err != nil {
+			return fmt.Errorf("error moving custom field value: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			snapshot.AddedFieldIDs = append(snapshot.AddedFieldIDs, fieldID)
+		}
+	}
+
+	if err := deleteDevice(ctx, tx, removedID); err != nil {
+		return err
+	}
+	if err := replaceDevice(ctx, tx, merged); err != nil {
+		return err
+	}
+
+	if err := insertDeviceOperation(ctx, tx, op); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// Split stores the device that keeps the remaining MACs and inserts the device
+// created for the split off MAC
+func (r *DeviceOperationRepository) Split(ctx context.Context, op *models.DeviceOperation, kept, created *models.Device) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceDevice(ctx, tx, kept); err != nil {
+		return err
+	}
+	if err := insertDevice(ctx, tx, created); err != nil {
+		return err
+	}
+
+	if err := insertDeviceOperation(ctx, tx, op); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// UndoMerge recreates the removed device with its ports, web services, inventory
+// assignments, event logs and IPv6 addresses and restores the kept device to its state before the merge
+func (r *DeviceOperationRepository) UndoMerge(ctx context.Context, op *models.DeviceOperation) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	snapshot := op.Snapshot
+	keptID, removedID := op.DeviceID, op.OtherDeviceID
+
+	if err := replaceDevice(ctx, tx, &snapshot.Device); err != nil {
+		return err
+	}
+	removed := *snapshot.Removed
+	if err := insertDevice(ctx, tx, &removed); err != nil {
+		return err
+	}
+	if err := insertDeviceChildren(ctx, tx, &removed); err != nil {
+		return err
+	}
+
+	for _, id := range snapshot.EventLogIDs {
+		if _, err := tx.ExecContext(ctx, `UPDATE event_logs SET device_id = ? WHERE id = ? AND device_id = ?`, removedID, id, keptID); err != nil {
+			return fmt.Errorf("error moving event log back: %w", err)
+		}
+	}
+	for _, address := range snapshot.IPv6Addresses {
+		if _, err := tx.ExecContext(ctx, `UPDATE device_ipv6_addresses SET device_id = ? WHERE address = ? AND device_id = ?`, removedID, address, keptID); err != nil {
+			return fmt.Errorf("error moving IPv6 address back: %w", err)
+		}
+	}
+
+	// Assignments are restored only for tags, groups and fields that still exist
+	restores := []struct {
+		table, column, source string
+		ids, added            []string
+	}{
+		{"device_tags", "tag_id", "tags", snapshot.TagIDs, snapshot.AddedTagIDs},
+		{"device_group_members", "group_id", "device_groups", snapshot.GroupIDs, snapshot.AddedGroupIDs},
+	}
+	for _, restore := range restores {
+		for _, id := range restore.added {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+restore.table+` WHERE device_id = ? AND `+restore.column+` = ?`, keptID, id); err != nil {
+				return fmt.Errorf("error removing merged %s: %w", restore.table, err)
+			}
+		}
+		for _, id := range restore.ids {
+			if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO `+restore.table+` (device_id, `+restore.column+`) SELECT ?, id FROM `+restore.source+` WHERE id = ?`, removedID, id); err != nil {
+				return fmt.Errorf("error restoring %s: %w", restore.table, err)
+			}
+		}
+	}
+	for _, fieldID := range snapshot.AddedFieldIDs {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM device_custom_values WHERE device_id = ? AND field_id = ?`, keptID, fieldID); err != nil {
+			return fmt.Errorf("error removing merged custom field value: %w", err)
+		}
+	}
+	for fieldID, value := range snapshot.FieldValues {
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO device_custom_values (device_id, field_id, value) SELECT ?, id, ? FROM custom_fields WHERE id = ?`, removedID, value, fieldID); err != nil {
+			return fmt.Errorf("error restoring custom field value: %w", err)
+		}
+	}
+
+	if err := markUndone(ctx, tx, op); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// UndoSplit deletes the device created by a split, hands its event logs back to the
+// original device and restores the MAC addresses of the original device
+func (r *DeviceOperationRepository) UndoSplit(ctx context.Context, op *models.DeviceOperation) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	original := op.Snapshot.Device
+	if _, err := tx.ExecContext(ctx, `UPDATE event_logs SET device_id = ? WHERE device_id = ?`, op.DeviceID, op.OtherDeviceID); err != nil {
+		return fmt.Errorf("error moving event logs: %w", err)
+	}
+	if err := deleteDevice(ctx, tx, op.OtherDeviceID); err != nil {
+		return err
+	}
+
+	// Everything else about the device may have changed since and is left alone
+	_, err = tx.ExecContext(ctx, `UPDATE devices SET mac = ?, vendor = ?, identity = ?, status = ?, last_seen_online_at = ?, updated_at = ? WHERE id = ?`,
+		nullableString(original.MAC), nullableString(original.Vendor), nullableJSON(original.Identity), original.Status,
+		nullableTime(original.LastSeenOnlineAt), time.Now(), op.DeviceID)
+	if err != nil {
+		return fmt.Errorf("error restoring device: %w", err)
+	}
+
+	if err := markUndone(ctx, tx, op); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// replaceDevice overwrites a stored device. Unlike a regular update it also replaces
+// empty ports and web services and the fields an update keeps when they are not set.
+func replaceDevice(ctx context.Context, tx *sql.Tx, device *models.Device) error {
+	for _, table := range []string{"ports", "web_services"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE device_id = ?", device.ID); err != nil {
+			return fmt.Errorf("error deleting device %s: %w", table, err)
+		}
+	}
+	// saveDevice fills unset fields from the stored device, so save a copy
+	saved := *device
+	if err := saveDevice(ctx, tx, &saved); err != nil {
+		return err
+	}
+
+	var osName, osVersion, osFamily sql.NullString
+	var osConfidence sql.NullInt64
+	if device.OS != nil {
+		osName = sql.NullString{String: device.OS.Name, Valid: device.OS.Name != ""}
+		osVersion = sql.NullString{String: device.OS.Version, Valid: device.OS.Version != ""}
+		osFamily = sql.NullString{String: device.OS.Family, Valid: device.OS.Family != ""}
+		osConfidence = sql.NullInt64{Int64: int64(device.OS.Confidence), Valid: device.OS.Confidence > 0}
+	}
+	_, err := tx.ExecContext(ctx, `
+		UPDATE devices SET device_type = ?, os_name = ?, os_version = ?, os_family = ?, os_confidence = ?,
+			upnp_info = ?, snmp_info = ?, switch_port = ?, trust_state = ?, identity = ?
+		WHERE id = ?`,
+		nullableString((*string)(&device.DeviceType)), osName, osVersion, osFamily, osConfidence,
+		nullableJSON(device.UPnP), nullableJSON(device.SNMP), nullableJSON(device.SwitchPort), string(device.TrustState), nullableJSON(device.Identity),
+		device.ID)
+	if err != nil {
+		return fmt.Errorf("error replacing device: %w", err)
+	}
+	return nil
+}
+
+// moveAssignments copies the assignments of one device to another and returns the
+// IDs the source had and the ones that were new to the target
+func moveAssignments(ctx context.Context, tx *sql.Tx, table, column, fromID, toID string) ([]string, []string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT `+column+` FROM `+table+` WHERE device_id = ?`, fromID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error querying %s: %w", table, err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("error scanning %s: %w", table, err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	var added []string
+	for _, id := range ids {
+		result, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO `+table+` (device_id, `+column+`) VALUES (?, ?)`, toID, id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error moving %s: %w", table, err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			added = append(added, id)
+		}
+	}
+	return ids, added, nil
+}
+
+func queryInt64s(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []int64
+	for rows.Next() {
+		var value int64
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+func queryStrings(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+func insertDeviceOperation(ctx context.Context, tx *sql.Tx, op *models.DeviceOperation) error {
+	if op.ID == "" {
+		op.ID = GenerateID()
+	}
+	if op.CreatedAt.IsZero() {
+		op.CreatedAt = time.Now()
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO device_operations (id, type, device_id, other_device_id, mac, description, snapshot, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		op.ID, string(op.Type), op.DeviceID, op.OtherDeviceID, nullableString(&op.MAC), op.Description, nullableJSON(op.Snapshot), op.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error inserting device operation: %w", err)
+	}
+	return nil
+}
+
+func markUndone(ctx context.Context, tx *sql.Tx, op *models.DeviceOperation) error {
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx, `UPDATE device_operations SET undone_at = ? WHERE id = ?`, now, op.ID); err != nil {
+		return fmt.Errorf("error marking device operation undone: %w", err)
+	}
+	op.UndoneAt = &now
+	return nil
+}
+
+func scanDeviceOperation(row rowScanner) (*models.DeviceOperation, error) {
+	var op models.DeviceOperation
+	var opType string
+	var mac, snapshot sql.NullString
+	var undoneAt sql.NullTime
+	err := row.Scan(&op.ID, &opType, &op.DeviceID, &op.OtherDeviceID, &mac, &op.Description, &snapshot, &op.CreatedAt, &undoneAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error scanning device operation: %w", err)
+	}
+
+	op.Type = models.DeviceOperationType(opType)
+	op.MAC = mac.String
+	if snapshot.Valid && snapshot.String != "" {
+		var s models.DeviceOperationSnapshot
+		if err := json.Unmarshal([]byte(snapshot.String), &s); err != nil {
+			return nil, fmt.Errorf("error decoding device operation snapshot: %w", err)
+		}
+		op.Snapshot = &s
+	}
+	if undoneAt.Valid {
+		op.UndoneAt = &undoneAt.Time
+	}
+	return &op, nil
+}
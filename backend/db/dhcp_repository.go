@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reconya-ai/models"
+)
+
+// DHCPRepository stores the expected DHCP servers of each network and the legitimate
+// server last seen by the DHCP probe
+type DHCPRepository struct {
+	db *sql.DB
+}
+
+func NewDHCPRepository(db *sql.DB) *DHCPRepository {
+	return &DHCPRepository{db: db}
+}
+
+// SetAllowlist replaces the expected DHCP servers of a network
+func (r *DHCPRepository) SetAllowlist(ctx context.Context, networkID string, servers []models.DHCPExpectedServer) error {
+	value := nullableJSON(servers)
+	if len(servers) == 0 {
+		value = sql.NullString{}
+	}
+	result, err := r.db.ExecContext(ctx, `UPDATE networks SET dhcp_allowlist = ? WHERE id = ?`, value, networkID)
+	if err != nil {
+		return fmt.Errorf("failed to update DHCP allowlist: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordServer stores the legitimate DHCP server seen answering on a network
+func (r *DHCPRepository) RecordServer(ctx context.Context, networkID string, offer *models.DHCPOffer) error {
+	result, err := r.db.ExecContext(ctx, `UPDATE networks SET dhcp_server = ? WHERE id = ?`, nullableJSON(offer), networkID)
+	if err != nil {
+		return fmt.Errorf("failed to record DHCP server: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
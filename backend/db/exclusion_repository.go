@@ -0,0 +1,278 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"reconya-ai/models"
+	"time"
+)
+
+// ExclusionRepository stores the exclusion rules and their audit trail
+type ExclusionRepository struct {
+	db *sql.DB
+}
+
+func NewExclusionRepository(db *sql.DB) *ExclusionRepository {
+	return &ExclusionRepository{db: db}
+}
+
+// Rules are read with what matching needs from their network or device
+const exclusionRuleSelect = `SELECT r.id, r.scope, r.network_id, r.device_id, r.address, r.stages, r.reason, r.created_by,
+	r.created_at, r.updated_at, n.cidr, d.ipv4, d.mac
+	FROM exclusion_rules r
+	LEFT JOIN networks n ON n.id = r.network_id
+	LEFT JOIN devices d ON d.id = r.device_id`
+
+// Create stores a new rule, assigning its ID, and audits who added it
+func (r *ExclusionRepository) Create(ctx context.Context, rule *models.ExclusionRule, actor string) (*models.ExclusionRule, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := exclusionScopeExists(ctx, tx, rule); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	rule.ID = GenerateID()
+	rule.CreatedBy = actor
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO exclusion_rules (id, scope, network_id, device_id, address, stages, reason, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rule.ID, rule.Scope, nullableString(&rule.NetworkID), nullableString(&rule.DeviceID), nullableString(&rule.Address),
+		nullableJSON(rule.Stages), nullableString(&rule.Reason), nullableString(&rule.CreatedBy), now, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exclusion rule: %w", err)
+	}
+	if err := auditExclusionChange(ctx, tx, rule, models.ExclusionCreated, actor, now); err != nil {
+		return nil, err
+	}
+	return rule, tx.Commit()
+}
+
+// Update saves a rule and audits who changed it
+func (r *ExclusionRepository) Update(ctx context.Context, rule *models.ExclusionRule, actor string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := exclusionScopeExists(ctx, tx, rule); err != nil {
+		return err
+	}
+
+	rule.UpdatedAt = time.Now().UTC()
+	result, err := tx.ExecContext(ctx,
+		`UPDATE exclusion_rules SET scope = ?, network_id = ?, device_id = ?, address = ?, stages = ?, reason = ?, updated_at = ?
+		WHERE id = ?`,
+		rule.Scope, nullableString(&rule.NetworkID), nullableString(&rule.DeviceID), nullableString(&rule.Address),
+		nullableJSON(rule.Stages), nullableString(&rule.Reason), rule.UpdatedAt, rule.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update exclusion rule: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrNotFound
+	}
+	if err := auditExclusionChange(ctx, tx, rule, models.ExclusionUpdated, actor, rule.UpdatedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete removes a rule, its audit trail is kept
+func (r *ExclusionRepository) Delete(ctx context.Context, id, actor string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rule, err := scanExclusionRule(tx.QueryRowContext(ctx, exclusionRuleSelect+` WHERE r.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find exclusion rule: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM exclusion_rules WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete exclusion rule: %w", err)
+	}
+	if err := auditExclusionChange(ctx, tx, rule, models.ExclusionDeleted, actor, time.Now().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindByID retrieves a single rule
+func (r *ExclusionRepository) FindByID(ctx context.Context, id string) (*models.ExclusionRule, error) {
+	rule, err := scanExclusionRule(r.db.QueryRowContext(ctx, exclusionRuleSelect+` WHERE r.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find exclusion rule: %w", err)
+	}
+	return rule, nil
+}
+
+// FindAll lists the rules, oldest first, with the known addresses of the devices
+// that MAC rules match
+func (r *ExclusionRepository) FindAll(ctx context.Context) ([]*models.ExclusionRule, error) {
+	rows, err := r.db.QueryContext(ctx, exclusionRuleSelect+` ORDER BY r.created_at, r.id`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying exclusion rules: %w", err)
+	}
+	var rules []*models.ExclusionRule
+	for rows.Next() {
+		rule, err := scanExclusionRule(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning exclusion rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		mac, err := net.ParseMAC(rule.Address)
+		if err != nil {
+			continue
+		}
+		if rule.KnownIPs, err = r.findIPsByMAC(ctx, mac.String()); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+func (r *ExclusionRepository) findIPsByMAC(ctx context.Context, mac string) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT ipv4 FROM devices WHERE lower(mac) = ? ORDER BY ipv4`, mac)
+	if err != nil {
+		return nil, fmt.Errorf("error querying devices by MAC: %w", err)
+	}
+	defer rows.Close()
+
+	var ips []string
+	for rows.Next() {
+		var ip string
+		if err := rows.Scan(&ip); err != nil {
+			return nil, err
+		}
+		ips = append(ips, ip)
+	}
+	return ips, rows.Err()
+}
+
+// RecordBlock counts a stage a rule kept away from an address
+func (r *ExclusionRepository) RecordBlock(ctx context.Context, ruleID string, stage models.ExclusionStage, target string) error {
+	now := time.Now().UTC()
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO exclusion_audit (id, rule_id, action, stage, target, count, first_at, last_at) VALUES (?, ?, ?, ?, ?, 1, ?, ?)
+		ON CONFLICT(rule_id, stage, target) WHERE action = 'blocked' DO UPDATE SET count = count + 1, last_at = excluded.last_at`,
+		GenerateID(), ruleID, models.ExclusionBlocked, stage, target, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to record exclusion block: %w", err)
+	}
+	return nil
+}
+
+// FindAudit lists the audit trail, most recent first, of one rule or of all of
+// them when ruleID is empty
+func (r *ExclusionRepository) FindAudit(ctx context.Context, ruleID string, limit int) ([]*models.ExclusionAuditEntry, error) {
+	query := `SELECT id, rule_id, action, stage, target, actor, detail, count, first_at, last_at FROM exclusion_audit`
+	var args []interface{}
+	if ruleID != "" {
+		query += ` WHERE rule_id = ?`
+		args = append(args, ruleID)
+	}
+	query += ` ORDER BY last_at DESC, id LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying exclusion audit: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*models.ExclusionAuditEntry
+	for rows.Next() {
+		var entry models.ExclusionAuditEntry
+		var actor, detail sql.NullString
+		if err := rows.Scan(&entry.ID, &entry.RuleID, &entry.Action, &entry.Stage, &entry.Target, &actor, &detail,
+			&entry.Count, &entry.FirstAt, &entry.LastAt); err != nil {
+			return nil, fmt.Errorf("error scanning exclusion audit entry: %w", err)
+		}
+		entry.Actor = actor.String
+		entry.Detail = detail.String
+		entries = append(entries, &entry)
+	}
+	return entries, rows.Err()
+}
+
+// exclusionScopeExists checks the network or device of a rule is there
+func exclusionScopeExists(ctx context.Context, tx *sql.Tx, rule *models.ExclusionRule) error {
+	table, id := "", ""
+	switch rule.Scope {
+	case models.ExclusionNetwork:
+		table, id = "networks", rule.NetworkID
+	case models.ExclusionDevice:
+		table, id = "devices", rule.DeviceID
+	default:
+		return nil
+	}
+	var count int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+table+` WHERE id = ?`, id).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%s %s not found", rule.Scope, id)
+	}
+	return nil
+}
+
+func auditExclusionChange(ctx context.Context, tx *sql.Tx, rule *models.ExclusionRule, action models.ExclusionAuditAction, actor string, at time.Time) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO exclusion_audit (id, rule_id, action, actor, detail, count, first_at, last_at) VALUES (?, ?, ?, ?, ?, 1, ?, ?)`,
+		GenerateID(), rule.ID, action, nullableString(&actor), rule.Summary(), at, at)
+	if err != nil {
+		return fmt.Errorf("failed to audit exclusion rule: %w", err)
+	}
+	return nil
+}
+
+func scanExclusionRule(row rowScanner) (*models.ExclusionRule, error) {
+	var rule models.ExclusionRule
+	var networkID, deviceID, address, stages, reason, createdBy, cidr, ipv4, mac sql.NullString
+
+	err := row.Scan(&rule.ID, &rule.Scope, &networkID, &deviceID, &address, &stages, &reason, &createdBy,
+		&rule.CreatedAt, &rule.UpdatedAt, &cidr, &ipv4, &mac)
+	if err != nil {
+		return nil, err
+	}
+
+	rule.NetworkID = networkID.String
+	rule.DeviceID = deviceID.String
+	rule.Address = address.String
+	rule.Reason = reason.String
+	rule.CreatedBy = createdBy.String
+	rule.NetworkCIDR = cidr.String
+	rule.DeviceIPv4 = ipv4.String
+	rule.DeviceMAC = mac.String
+	if stages.Valid && stages.String != "" {
+		if err := json.Unmarshal([]byte(stages.String), &rule.Stages); err != nil {
+			return nil, fmt.Errorf("invalid exclusion stages: %w", err)
+		}
+	}
+	return &rule, nil
+}
@@ -151,7 +151,7 @@ func (r *GeolocationRepository) CleanupExpired(ctx context.Context) error {
 
 	rowsAffected, err := result.RowsAffected()
 	if err == nil && rowsAffected > 0 {
-		fmt.Printf("Cleaned up %d expired geolocation cache entries\n", rowsAffected)
+		logger.Debugf("Cleaned up %d expired geolocation cache entries", rowsAffected)
 	}
 
 	return nil
@@ -0,0 +1,305 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reconya-ai/models"
+	"time"
+)
+
+// InventoryRepository stores tags, the device group hierarchy, custom field
+// definitions and their assignments to devices
+type InventoryRepository struct {
+	db *sql.DB
+}
+
+func NewInventoryRepository(db *sql.DB) *InventoryRepository {
+	return &InventoryRepository{db: db}
+}
+
+// ListTags returns all tags ordered by name
+func (r *InventoryRepository) ListTags(ctx context.Context) ([]*models.Tag, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, name, color, created_at FROM tags ORDER BY name COLLATE NOCASE`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []*models.Tag
+	for rows.Next() {
+		var tag models.Tag
+		var color sql.NullString
+		if err := rows.Scan(&tag.ID, &tag.Name, &color, &tag.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning tag: %w", err)
+		}
+		tag.Color = color.String
+		tags = append(tags, &tag)
+	}
+	return tags, rows.Err()
+}
+
+// FindTagByName looks a tag up case-insensitively
+func (r *InventoryRepository) FindTagByName(ctx context.Context, name string) (*models.Tag, error) {
+	var tag models.Tag
+	var color sql.NullString
+	err := r.db.QueryRowContext(ctx, `SELECT id, name, color, created_at FROM tags WHERE name = ?`, name).
+		Scan(&tag.ID, &tag.Name, &color, &tag.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find tag: %w", err)
+	}
+	tag.Color = color.String
+	return &tag, nil
+}
+
+// UpsertTag creates a tag or updates its name and color
+func (r *InventoryRepository) UpsertTag(ctx context.Context, tag *models.Tag) error {
+	if tag.ID == "" {
+		tag.ID = GenerateID()
+	}
+	if tag.CreatedAt.IsZero() {
+		tag.CreatedAt = time.Now()
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO tags (id, name, color, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET name = excluded.name, color = excluded.color`,
+		tag.ID, tag.Name, nullableString(&tag.Color), tag.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to upsert tag: %w", err)
+	}
+	return nil
+}
+
+// DeleteTag removes a tag and its assignments
+func (r *InventoryRepository) DeleteTag(ctx context.Context, id string) error {
+	return r.deleteWithAssignments(ctx, "tags", "device_tags", "tag_id", []string{id})
+}
+
+// ListGroups returns all groups. Paths are left for the caller to compute.
+func (r *InventoryRepository) ListGroups(ctx context.Context) ([]*models.DeviceGroup, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, name, parent_id, created_at FROM device_groups ORDER BY name COLLATE NOCASE`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying device groups: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []*models.DeviceGroup
+	for rows.Next() {
+		var group models.DeviceGroup
+		var parentID sql.NullString
+		if err := rows.Scan(&group.ID, &group.Name, &parentID, &group.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning device group: %w", err)
+		}
+		if parentID.Valid && parentID.String != "" {
+			group.ParentID = &parentID.String
+		}
+		groups = append(groups, &group)
+	}
+	return groups, rows.Err()
+}
+
+// CreateGroup stores a new group
+func (r *InventoryRepository) CreateGroup(ctx context.Context, group *models.DeviceGroup) error {
+	if group.ID == "" {
+		group.ID = GenerateID()
+	}
+	if group.CreatedAt.IsZero() {
+		group.CreatedAt = time.Now()
+	}
+	_, err := r.db.ExecContext(ctx, `INSERT INTO device_groups (id, name, parent_id, created_at) VALUES (?, ?, ?, ?)`,
+		group.ID, group.Name, nullableString(group.ParentID), group.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create device group: %w", err)
+	}
+	return nil
+}
+
+// DeleteGroups removes groups and their memberships. Callers pass a whole subtree.
+func (r *InventoryRepository) DeleteGroups(ctx context.Context, ids []string) error {
+	return r.deleteWithAssignments(ctx, "device_groups", "device_group_members", "group_id", ids)
+}
+
+// ListCustomFields returns all custom field definitions
+func (r *InventoryRepository) ListCustomFields(ctx context.Context) ([]*models.CustomField, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, name, label, type, options, created_at FROM custom_fields ORDER BY created_at ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying custom fields: %w", err)
+	}
+	defer rows.Close()
+
+	var fields []*models.CustomField
+	for rows.Next() {
+		var field models.CustomField
+		var label, options sql.NullString
+		var fieldType string
+		if err := rows.Scan(&field.ID, &field.Name, &label, &fieldType, &options, &field.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning custom field: %w", err)
+		}
+		field.Label = label.String
+		field.Type = models.CustomFieldType(fieldType)
+		if options.Valid && options.String != "" {
+			if err := json.Unmarshal([]byte(options.String), &field.Options); err != nil {
+				return nil, fmt.Errorf("invalid options for custom field %s: %w", field.Name, err)
+			}
+		}
+		fields = append(fields, &field)
+	}
+	return fields, rows.Err()
+}
+
+// UpsertCustomField creates or updates a custom field definition
+func (r *InventoryRepository) UpsertCustomField(ctx context.Context, field *models.CustomField) error {
+	if field.ID == "" {
+		field.ID = GenerateID()
+	}
+	if field.CreatedAt.IsZero() {
+		field.CreatedAt = time.Now()
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO custom_fields (id, name, label, type, options, created_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			label = excluded.label,
+			type = excluded.type,
+			options = excluded.options`,
+		field.ID, field.Name, nullableString(&field.Label), string(field.Type), nullableJSON(field.Options), field.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to upsert custom field: %w", err)
+	}
+	return nil
+}
+
+// DeleteCustomField removes a field definition and all of its values
+func (r *InventoryRepository) DeleteCustomField(ctx context.Context, id string) error {
+	return r.deleteWithAssignments(ctx, "custom_fields", "device_custom_values", "field_id", []string{id})
+}
+
+// DeviceTags maps device IDs to tag names
+func (r *InventoryRepository) DeviceTags(ctx context.Context) (map[string][]string, error) {
+	return r.pairs(ctx, `SELECT dt.device_id, t.name FROM device_tags dt JOIN tags t ON t.id = dt.tag_id ORDER BY t.name COLLATE NOCASE`)
+}
+
+// DeviceGroupIDs maps device IDs to the groups they are a direct member of
+func (r *InventoryRepository) DeviceGroupIDs(ctx context.Context) (map[string][]string, error) {
+	return r.pairs(ctx, `SELECT device_id, group_id FROM device_group_members`)
+}
+
+// DeviceCustomValues maps device IDs to custom field values keyed by field ID
+func (r *InventoryRepository) DeviceCustomValues(ctx context.Context) (map[string]map[string]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT device_id, field_id, value FROM device_custom_values`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying custom field values: %w", err)
+	}
+	defer rows.Close()
+
+	values := make(map[string]map[string]string)
+	for rows.Next() {
+		var deviceID, fieldID, value string
+		if err := rows.Scan(&deviceID, &fieldID, &value); err != nil {
+			return nil, fmt.Errorf("error scanning custom field value: %w", err)
+		}
+		if values[deviceID] == nil {
+			values[deviceID] = make(map[string]string)
+		}
+		values[deviceID][fieldID] = value
+	}
+	return values, rows.Err()
+}
+
+// AssignTag attaches or detaches a tag on a set of devices
+func (r *InventoryRepository) AssignTag(ctx context.Context, deviceIDs []string, tagID string, assign bool) error {
+	return r.assign(ctx, "device_tags", "tag_id", deviceIDs, tagID, assign)
+}
+
+// AssignGroup adds a set of devices to a group or removes them from it
+func (r *InventoryRepository) AssignGroup(ctx context.Context, deviceIDs []string, groupID string, assign bool) error {
+	return r.assign(ctx, "device_group_members", "group_id", deviceIDs, groupID, assign)
+}
+
+// SetCustomValue sets a custom field on a set of devices. An empty value clears it.
+func (r *InventoryRepository) SetCustomValue(ctx context.Context, deviceIDs []string, fieldID, value string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, deviceID := range deviceIDs {
+		if value == "" {
+			_, err = tx.ExecContext(ctx, `DELETE FROM device_custom_values WHERE device_id = ? AND field_id = ?`, deviceID, fieldID)
+		} else {
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO device_custom_values (device_id, field_id, value) VALUES (?, ?, ?)
+				ON CONFLICT(device_id, field_id) DO UPDATE SET value = excluded.value`, deviceID, fieldID, value)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to set custom field value: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *InventoryRepository) assign(ctx context.Context, table, column string, deviceIDs []string, id string, assign bool) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, deviceID := range deviceIDs {
+		if assign {
+			_, err = tx.ExecContext(ctx, `INSERT OR IGNORE INTO `+table+` (device_id, `+column+`) VALUES (?, ?)`, deviceID, id)
+		} else {
+			_, err = tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE device_id = ? AND `+column+` = ?`, deviceID, id)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %w", table, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *InventoryRepository) pairs(ctx context.Context, query string) (map[string][]string, error) {
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying device assignments: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string][]string)
+	for rows.Next() {
+		var deviceID, value string
+		if err := rows.Scan(&deviceID, &value); err != nil {
+			return nil, fmt.Errorf("error scanning device assignment: %w", err)
+		}
+		result[deviceID] = append(result[deviceID], value)
+	}
+	return result, rows.Err()
+}
+
+// deleteWithAssignments deletes rows and their device assignments explicitly, since
+// foreign keys are not enforced on every pooled connection
+func (r *InventoryRepository) deleteWithAssignments(ctx context.Context, table, assignmentTable, column string, ids []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+assignmentTable+` WHERE `+column+` = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", assignmentTable, err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete from %s: %w", table, err)
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,310 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"reconya-ai/models"
+)
+
+// RecordIPv6Address marks an IPv6 address as seen on a device now. An address seen
+// on another device before moves to this one.
+func (r *SQLiteDeviceRepository) RecordIPv6Address(ctx context.Context, deviceID, address, source string, seenAt time.Time) error {
+	normalized := models.NormalizeIPv6(address)
+	if normalized == "" {
+		return fmt.Errorf("invalid IPv6 address %q", address)
+	}
+
+	query := `
+	INSERT INTO device_ipv6_addresses (address, address_hex, device_id, type, source, state, first_seen, last_seen)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(address) DO UPDATE SET
+		device_id = excluded.device_id, source = excluded.source, state = excluded.state,
+		first_seen = CASE WHEN device_ipv6_addresses.device_id = excluded.device_id THEN device_ipv6_addresses.first_seen ELSE excluded.first_seen END,
+		last_seen = excluded.last_seen`
+	_, err := r.db.ExecContext(ctx, query, normalized, ipv6Hex(net.ParseIP(normalized)), deviceID, models.IPv6AddressType(normalized),
+		source, string(models.IPv6AddressActive), seenAt, seenAt)
+	if err != nil {
+		return fmt.Errorf("error recording IPv6 address: %w", err)
+	}
+	return nil
+}
+
+// FindByIPv6 finds the device currently holding an IPv6 address
+func (r *SQLiteDeviceRepository) FindByIPv6(ctx context.Context, address string) (*models.Device, error) {
+	normalized := models.NormalizeIPv6(address)
+	if normalized == "" {
+		return nil, ErrNotFound
+	}
+
+	var deviceID string
+	err := r.db.QueryRowContext(ctx,
+		`SELECT device_id FROM device_ipv6_addresses WHERE address = ? AND state != ?`,
+		normalized, string(models.IPv6AddressExpired)).Scan(&deviceID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error finding device by IPv6 address: %w", err)
+	}
+	return r.FindByID(ctx, deviceID)
+}
+
+// FindByIPv6Prefix finds the devices holding an unexpired address inside the prefix
+func (r *SQLiteDeviceRepository) FindByIPv6Prefix(ctx context.Context, prefix *net.IPNet) ([]*models.Device, error) {
+	low, high := ipv6Range(prefix)
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT DISTINCT device_id FROM device_ipv6_addresses WHERE address_hex BETWEEN ? AND ? AND state != ?`,
+		low, high, string(models.IPv6AddressExpired))
+	if err != nil {
+		return nil, fmt.Errorf("error querying IPv6 prefix: %w", err)
+	}
+	var deviceIDs []string
+	for rows.Next() {
+		var deviceID string
+		if err := rows.Scan(&deviceID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning IPv6 address: %w", err)
+		}
+		deviceIDs = append(deviceIDs, deviceID)
+	}
+	rows.Close()
+
+	devices := make([]*models.Device, 0, len(deviceIDs))
+	for _, deviceID := range deviceIDs {
+		device, err := r.FindByID(ctx, deviceID)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// FindIPv6Addresses returns the addresses recorded for a device, most recently seen
+// first
+func (r *SQLiteDeviceRepository) FindIPv6Addresses(ctx context.Context, deviceID string) ([]models.DeviceIPv6Address, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT address, device_id, type, source, state, first_seen, last_seen
+		FROM device_ipv6_addresses WHERE device_id = ? ORDER BY last_seen DESC, address`, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying IPv6 addresses: %w", err)
+	}
+	defer rows.Close()
+
+	addresses := []models.DeviceIPv6Address{}
+	for rows.Next() {
+		var address models.DeviceIPv6Address
+		var state string
+		if err := rows.Scan(&address.Address, &address.DeviceID, &address.Type, &address.Source, &state, &address.FirstSeen, &address.LastSeen); err != nil {
+			return nil, fmt.Errorf("error scanning IPv6 address: %w", err)
+		}
+		address.State = models.IPv6AddressState(state)
+		addresses = append(addresses, address)
+	}
+	return addresses, rows.Err()
+}
+
+// ExpireIPv6Addresses marks addresses not seen since staleBefore as stale, and those
+// not seen since expireBefore as expired. Expired addresses are removed from their
+// device, expired rows older than purgeBefore are deleted. It returns the number
+// of addresses expired.
+func (r *SQLiteDeviceRepository) ExpireIPv6Addresses(ctx context.Context, staleBefore, expireBefore, purgeBefore time.Time) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `UPDATE device_ipv6_addresses SET state = ? WHERE state = ? AND last_seen < ?`,
+		string(models.IPv6AddressStale), string(models.IPv6AddressActive), staleBefore)
+	if err != nil {
+		return 0, fmt.Errorf("error marking IPv6 addresses stale: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT address, device_id FROM device_ipv6_addresses WHERE state != ? AND last_seen < ?`,
+		string(models.IPv6AddressExpired), expireBefore)
+	if err != nil {
+		return 0, fmt.Errorf("error querying expired IPv6 addresses: %w", err)
+	}
+	expired := make(map[string][]string)
+	count := 0
+	for rows.Next() {
+		var address, deviceID string
+		if err := rows.Scan(&address, &deviceID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning IPv6 address: %w", err)
+		}
+		expired[deviceID] = append(expired[deviceID], address)
+		count++
+	}
+	rows.Close()
+
+	for deviceID, addresses := range expired {
+		if err := removeDeviceIPv6Addresses(ctx, tx, deviceID, addresses); err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE device_ipv6_addresses SET state = ? WHERE state != ? AND last_seen < ?`,
+		string(models.IPv6AddressExpired), string(models.IPv6AddressExpired), expireBefore)
+	if err != nil {
+		return 0, fmt.Errorf("error marking IPv6 addresses expired: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM device_ipv6_addresses WHERE state = ? AND last_seen < ?`,
+		string(models.IPv6AddressExpired), purgeBefore)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting expired IPv6 addresses: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return count, nil
+}
+
+// removeDeviceIPv6Addresses drops addresses from the IPv6 columns of a device
+func removeDeviceIPv6Addresses(ctx context.Context, tx *sql.Tx, deviceID string, addresses []string) error {
+	var linkLocal, uniqueLocal, global, additional sql.NullString
+	err := tx.QueryRowContext(ctx, `SELECT ipv6_link_local, ipv6_unique_local, ipv6_global, ipv6_addresses FROM devices WHERE id = ?`, deviceID).
+		Scan(&linkLocal, &uniqueLocal, &global, &additional)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error loading device IPv6 addresses: %w", err)
+	}
+
+	remove := make(map[string]bool)
+	for _, address := range addresses {
+		remove[address] = true
+	}
+	for _, column := range []*sql.NullString{&linkLocal, &uniqueLocal, &global} {
+		if column.Valid && remove[models.NormalizeIPv6(column.String)] {
+			*column = sql.NullString{}
+		}
+	}
+
+	var kept []string
+	if additional.Valid && additional.String != "" {
+		var list []string
+		if err := json.Unmarshal([]byte(additional.String), &list); err == nil {
+			for _, address := range list {
+				if !remove[models.NormalizeIPv6(address)] {
+					kept = append(kept, address)
+				}
+			}
+		}
+	}
+	additional = sql.NullString{}
+	if len(kept) > 0 {
+		if data, err := json.Marshal(kept); err == nil {
+			additional = sql.NullString{String: string(data), Valid: true}
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE devices SET ipv6_link_local = ?, ipv6_unique_local = ?, ipv6_global = ?, ipv6_addresses = ? WHERE id = ?`,
+		linkLocal, uniqueLocal, global, additional, deviceID)
+	if err != nil {
+		return fmt.Errorf("error updating device IPv6 addresses: %w", err)
+	}
+	return nil
+}
+
+// registerIPv6Addresses adds the IPv6 addresses stored on a device to the address
+// table. Saving a device does not mean its addresses were seen, so addresses already
+// in the table keep their timestamps and only follow the device they belong to.
+func registerIPv6Addresses(ctx context.Context, tx *sql.Tx, device *models.Device, now time.Time) error {
+	for _, address := range device.GetAllIPv6Addresses() {
+		normalized := models.NormalizeIPv6(address)
+		if normalized == "" {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, `
+		INSERT INTO device_ipv6_addresses (address, address_hex, device_id, type, source, state, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(address) DO UPDATE SET device_id = excluded.device_id`,
+			normalized, ipv6Hex(net.ParseIP(normalized)), device.ID, models.IPv6AddressType(normalized),
+			models.IPv6SourceScan, string(models.IPv6AddressActive), now, now)
+		if err != nil {
+			return fmt.Errorf("error registering IPv6 address: %w", err)
+		}
+	}
+	return nil
+}
+
+// backfillIPv6Addresses registers the addresses stored on devices before the address
+// table existed
+func backfillIPv6Addresses(db *sql.DB) error {
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM device_ipv6_addresses`).Scan(&count); err != nil || count > 0 {
+		return err
+	}
+
+	rows, err := db.Query(`SELECT id, ipv6_link_local, ipv6_unique_local, ipv6_global, ipv6_addresses FROM devices
+		WHERE ipv6_link_local IS NOT NULL OR ipv6_unique_local IS NOT NULL OR ipv6_global IS NOT NULL OR ipv6_addresses IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	var devices []*models.Device
+	for rows.Next() {
+		var device models.Device
+		var linkLocal, uniqueLocal, global, additional sql.NullString
+		if err := rows.Scan(&device.ID, &linkLocal, &uniqueLocal, &global, &additional); err != nil {
+			rows.Close()
+			return err
+		}
+		device.IPv6LinkLocal = nullStringPtr(linkLocal)
+		device.IPv6UniqueLocal = nullStringPtr(uniqueLocal)
+		device.IPv6Global = nullStringPtr(global)
+		if additional.Valid && additional.String != "" {
+			json.Unmarshal([]byte(additional.String), &device.IPv6Addresses)
+		}
+		devices = append(devices, &device)
+	}
+	rows.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	now := time.Now()
+	for _, device := range devices {
+		if err := registerIPv6Addresses(context.Background(), tx, device, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func nullStringPtr(value sql.NullString) *string {
+	if !value.Valid || value.String == "" {
+		return nil
+	}
+	return &value.String
+}
+
+// ipv6Hex returns the 32 hex digit form of an IPv6 address, which sorts like the
+// address itself
+func ipv6Hex(ip net.IP) string {
+	return hex.EncodeToString(ip.To16())
+}
+
+// ipv6Range returns the lowest and highest address of an IPv6 prefix in hex form
+func ipv6Range(prefix *net.IPNet) (string, string) {
+	low := prefix.IP.Mask(prefix.Mask).To16()
+	high := make(net.IP, net.IPv6len)
+	for i := range high {
+		high[i] = low[i] | ^prefix.Mask[i]
+	}
+	return ipv6Hex(low), ipv6Hex(high)
+}
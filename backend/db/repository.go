@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"net"
 	"reconya-ai/models"
 	"time"
 
@@ -37,8 +38,14 @@ type DeviceRepository interface {
 	FindByIP(ctx context.Context, ip string) (*models.Device, error)
 	FindAll(ctx context.Context) ([]*models.Device, error)
 	CreateOrUpdate(ctx context.Context, device *models.Device) (*models.Device, error)
-	UpdateDeviceStatuses(ctx context.Context, timeout time.Duration) error
+	UpdateDeviceStatuses(ctx context.Context, idleAfter, offlineAfter time.Duration) error
 	DeleteByID(ctx context.Context, id string) error
+	ReplacePorts(ctx context.Context, deviceID string, ports []models.Port) error
+	RecordIPv6Address(ctx context.Context, deviceID, address, source string, seenAt time.Time) error
+	FindByIPv6(ctx context.Context, address string) (*models.Device, error)
+	FindByIPv6Prefix(ctx context.Context, prefix *net.IPNet) ([]*models.Device, error)
+	FindIPv6Addresses(ctx context.Context, deviceID string) ([]models.DeviceIPv6Address, error)
+	ExpireIPv6Addresses(ctx context.Context, staleBefore, expireBefore, purgeBefore time.Time) (int, error)
 }
 
 // EventLogRepository defines the interface for event log operations
@@ -119,6 +126,61 @@ func (f *RepositoryFactory) NewSettingsRepository() SettingsRepository {
 	return NewSQLiteSettingsRepository(f.SQLiteDB)
 }
 
+// NewSNMPCredentialRepository creates a new SNMP credential repository
+func (f *RepositoryFactory) NewSNMPCredentialRepository() *SNMPCredentialRepository {
+	return NewSNMPCredentialRepository(f.SQLiteDB)
+}
+
+// NewWakeScheduleRepository creates a new Wake-on-LAN schedule repository
+func (f *RepositoryFactory) NewWakeScheduleRepository() *WakeScheduleRepository {
+	return NewWakeScheduleRepository(f.SQLiteDB)
+}
+
+// NewInventoryRepository creates a new tag, group and custom field repository
+func (f *RepositoryFactory) NewInventoryRepository() *InventoryRepository {
+	return NewInventoryRepository(f.SQLiteDB)
+}
+
+// NewTrustRepository creates a new MAC baseline and lockdown repository
+func (f *RepositoryFactory) NewTrustRepository() *TrustRepository {
+	return NewTrustRepository(f.SQLiteDB)
+}
+
+// NewDeviceOperationRepository creates a new device operation repository
+func (f *RepositoryFactory) NewDeviceOperationRepository() *DeviceOperationRepository {
+	return NewDeviceOperationRepository(f.SQLiteDB)
+}
+
+// NewDHCPRepository creates a new DHCP allowlist repository
+func (f *RepositoryFactory) NewDHCPRepository() *DHCPRepository {
+	return NewDHCPRepository(f.SQLiteDB)
+}
+
+// NewAgentRepository creates a new remote sensor agent repository
+func (f *RepositoryFactory) NewAgentRepository() *AgentRepository {
+	return NewAgentRepository(f.SQLiteDB)
+}
+
+// NewScanJobRepository creates a new scan job queue repository
+func (f *RepositoryFactory) NewScanJobRepository() *ScanJobRepository {
+	return NewScanJobRepository(f.SQLiteDB)
+}
+
+// NewAdhocScanRepository creates a new ad-hoc scan repository
+func (f *RepositoryFactory) NewAdhocScanRepository() *AdhocScanRepository {
+	return NewAdhocScanRepository(f.SQLiteDB)
+}
+
+// NewScanProfileRepository creates a new scan profile repository
+func (f *RepositoryFactory) NewScanProfileRepository() *ScanProfileRepository {
+	return NewScanProfileRepository(f.SQLiteDB)
+}
+
+// NewExclusionRepository creates a new exclusion rule and audit repository
+func (f *RepositoryFactory) NewExclusionRepository() *ExclusionRepository {
+	return NewExclusionRepository(f.SQLiteDB)
+}
+
 // GenerateID generates a unique ID for a record
 func GenerateID() string {
 	return uuid.New().String()
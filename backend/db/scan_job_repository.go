@@ -0,0 +1,303 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reconya-ai/models"
+	"strings"
+	"time"
+)
+
+// ScanJobRepository stores the scan queue. Every state change is a single
+// statement so workers claiming jobs concurrently never run the same job twice.
+type ScanJobRepository struct {
+	db *sql.DB
+}
+
+func NewScanJobRepository(db *sql.DB) *ScanJobRepository {
+	return &ScanJobRepository{db: db}
+}
+
+const scanJobColumns = `id, type, device_id, priority, status, attempts, max_attempts, run_after, error,
+	payload, progress, stage, result, created_at, updated_at, started_at, finished_at`
+
+// Enqueue adds a job unless the device already has one of the same type waiting
+// or running. In that case the existing job is returned, raised to the higher of
+// both priorities, and created is false. A waiting job takes the new payload.
+func (r *ScanJobRepository) Enqueue(ctx context.Context, job *models.ScanJob) (*models.ScanJob, bool, error) {
+	id := GenerateID()
+	now := time.Now().UTC()
+	runAfter := job.RunAfter.UTC()
+	if job.RunAfter.IsZero() {
+		runAfter = now
+	}
+	var payload interface{}
+	if len(job.Payload) > 0 {
+		payload = string(job.Payload)
+	}
+
+	query := `
+		INSERT INTO scan_jobs (id, type, device_id, priority, status, attempts, max_attempts, run_after, payload, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, 0, ?, ?, ?, ?, ?)
+		ON CONFLICT(type, device_id) WHERE status IN ('queued', 'running') DO UPDATE SET
+			priority = max(priority, excluded.priority),
+			run_after = CASE WHEN status = 'queued' THEN min(run_after, excluded.run_after) ELSE run_after END,
+			payload = CASE WHEN status = 'queued' THEN COALESCE(excluded.payload, payload) ELSE payload END,
+			updated_at = excluded.updated_at
+		RETURNING ` + scanJobColumns
+
+	row := r.db.QueryRowContext(ctx, query,
+		id, string(job.Type), job.DeviceID, job.Priority, string(models.ScanJobQueued), job.MaxAttempts,
+		runAfter, payload, now, now,
+	)
+	stored, err := scanScanJob(row)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to enqueue scan job: %w", err)
+	}
+	return stored, stored.ID == id, nil
+}
+
+// ClaimNext marks the most urgent due job of a type as running and returns it,
+// or nil when nothing is due
+func (r *ScanJobRepository) ClaimNext(ctx context.Context, jobType models.ScanJobType, now time.Time) (*models.ScanJob, error) {
+	now = now.UTC()
+	query := `
+		UPDATE scan_jobs SET status = ?, attempts = attempts + 1, started_at = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM scan_jobs
+			WHERE type = ? AND status = ? AND run_after <= ?
+			ORDER BY priority DESC, run_after ASC, created_at ASC
+			LIMIT 1
+		)
+		RETURNING ` + scanJobColumns
+
+	row := r.db.QueryRowContext(ctx, query,
+		string(models.ScanJobRunning), now, now, string(jobType), string(models.ScanJobQueued), now)
+	job, err := scanScanJob(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim scan job: %w", err)
+	}
+	return job, nil
+}
+
+// Finish records the outcome of a running job. Jobs cancelled while running keep
+// their cancelled status.
+func (r *ScanJobRepository) Finish(ctx context.Context, id string, status models.ScanJobStatus, jobError string) error {
+	now := time.Now().UTC()
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE scan_jobs SET status = ?, error = ?, progress = CASE WHEN ? = 'completed' THEN 100 ELSE progress END,
+			finished_at = ?, updated_at = ? WHERE id = ? AND status = ?`,
+		string(status), nullableString(&jobError), string(status), now, now, id, string(models.ScanJobRunning))
+	if err != nil {
+		return fmt.Errorf("failed to finish scan job: %w", err)
+	}
+	return nil
+}
+
+// SetProgress records how far a running job is
+func (r *ScanJobRepository) SetProgress(ctx context.Context, id string, progress int, stage string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE scan_jobs SET progress = ?, stage = ?, updated_at = ? WHERE id = ? AND status = ?`,
+		progress, nullableString(&stage), time.Now().UTC(), id, string(models.ScanJobRunning))
+	if err != nil {
+		return fmt.Errorf("failed to set scan job progress: %w", err)
+	}
+	return nil
+}
+
+// SetResult stores what a running job reports back
+func (r *ScanJobRepository) SetResult(ctx context.Context, id string, result []byte) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE scan_jobs SET result = ?, updated_at = ? WHERE id = ? AND status = ?`,
+		string(result), time.Now().UTC(), id, string(models.ScanJobRunning))
+	if err != nil {
+		return fmt.Errorf("failed to set scan job result: %w", err)
+	}
+	return nil
+}
+
+// Retry puts a failed running job back in the queue until runAfter
+func (r *ScanJobRepository) Retry(ctx context.Context, id string, jobError string, runAfter time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE scan_jobs SET status = ?, error = ?, run_after = ?, progress = 0, stage = NULL, started_at = NULL, updated_at = ? WHERE id = ? AND status = ?`,
+		string(models.ScanJobQueued), nullableString(&jobError), runAfter.UTC(), time.Now().UTC(), id, string(models.ScanJobRunning))
+	if err != nil {
+		return fmt.Errorf("failed to retry scan job: %w", err)
+	}
+	return nil
+}
+
+// Cancel stops a waiting or running job, finished jobs return ErrNotFound
+func (r *ScanJobRepository) Cancel(ctx context.Context, id string) (*models.ScanJob, error) {
+	now := time.Now().UTC()
+	query := `UPDATE scan_jobs SET status = ?, finished_at = ?, updated_at = ? WHERE id = ? AND status IN (?, ?) RETURNING ` + scanJobColumns
+	row := r.db.QueryRowContext(ctx, query,
+		string(models.ScanJobCancelled), now, now, id, string(models.ScanJobQueued), string(models.ScanJobRunning))
+	job, err := scanScanJob(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel scan job: %w", err)
+	}
+	return job, nil
+}
+
+// SetPriority changes the priority of a waiting or running job
+func (r *ScanJobRepository) SetPriority(ctx context.Context, id string, priority int) (*models.ScanJob, error) {
+	query := `UPDATE scan_jobs SET priority = ?, updated_at = ? WHERE id = ? AND status IN (?, ?) RETURNING ` + scanJobColumns
+	row := r.db.QueryRowContext(ctx, query,
+		priority, time.Now().UTC(), id, string(models.ScanJobQueued), string(models.ScanJobRunning))
+	job, err := scanScanJob(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to set scan job priority: %w", err)
+	}
+	return job, nil
+}
+
+// RequeueRunning puts jobs left running by a previous process back in the queue.
+// The interrupted attempt does not count against the job.
+func (r *ScanJobRepository) RequeueRunning(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE scan_jobs SET status = ?, attempts = max(attempts - 1, 0), progress = 0, stage = NULL, started_at = NULL, updated_at = ? WHERE status = ?`,
+		string(models.ScanJobQueued), time.Now().UTC(), string(models.ScanJobRunning))
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue running scan jobs: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// DeleteFinishedBefore removes completed, failed and cancelled jobs older than cutoff
+func (r *ScanJobRepository) DeleteFinishedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx,
+		`DELETE FROM scan_jobs WHERE status NOT IN (?, ?) AND updated_at < ?`,
+		string(models.ScanJobQueued), string(models.ScanJobRunning), cutoff.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete finished scan jobs: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// FindByID retrieves a single job
+func (r *ScanJobRepository) FindByID(ctx context.Context, id string) (*models.ScanJob, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+scanJobColumns+` FROM scan_jobs WHERE id = ?`, id)
+	job, err := scanScanJob(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find scan job: %w", err)
+	}
+	return job, nil
+}
+
+// FindAll lists jobs matching the filter, active jobs first in the order they
+// will run, then the most recently finished
+func (r *ScanJobRepository) FindAll(ctx context.Context, filter models.ScanJobFilter) ([]*models.ScanJob, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.Type != "" {
+		conditions = append(conditions, "type = ?")
+		args = append(args, string(filter.Type))
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, string(filter.Status))
+	}
+	if filter.DeviceID != "" {
+		conditions = append(conditions, "device_id = ?")
+		args = append(args, filter.DeviceID)
+	}
+
+	query := `SELECT ` + scanJobColumns + ` FROM scan_jobs`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY CASE status WHEN 'running' THEN 0 WHEN 'queued' THEN 1 ELSE 2 END,
+		CASE WHEN status IN ('queued', 'running') THEN -priority ELSE 0 END,
+		CASE WHEN status IN ('queued', 'running') THEN run_after END ASC,
+		updated_at DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying scan jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*models.ScanJob
+	for rows.Next() {
+		job, err := scanScanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning scan job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// CountActive returns the number of waiting and running jobs by type and status
+func (r *ScanJobRepository) CountActive(ctx context.Context) (map[models.ScanJobType]map[models.ScanJobStatus]int, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT type, status, COUNT(*) FROM scan_jobs WHERE status IN (?, ?) GROUP BY type, status`,
+		string(models.ScanJobQueued), string(models.ScanJobRunning))
+	if err != nil {
+		return nil, fmt.Errorf("error counting scan jobs: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[models.ScanJobType]map[models.ScanJobStatus]int)
+	for rows.Next() {
+		var jobType, status string
+		var count int
+		if err := rows.Scan(&jobType, &status, &count); err != nil {
+			return nil, fmt.Errorf("error scanning scan job count: %w", err)
+		}
+		if counts[models.ScanJobType(jobType)] == nil {
+			counts[models.ScanJobType(jobType)] = make(map[models.ScanJobStatus]int)
+		}
+		counts[models.ScanJobType(jobType)][models.ScanJobStatus(status)] = count
+	}
+	return counts, rows.Err()
+}
+
+func scanScanJob(row rowScanner) (*models.ScanJob, error) {
+	var job models.ScanJob
+	var jobType, status string
+	var jobError, payload, stage, result sql.NullString
+	var startedAt, finishedAt sql.NullTime
+
+	err := row.Scan(&job.ID, &jobType, &job.DeviceID, &job.Priority, &status, &job.Attempts, &job.MaxAttempts,
+		&job.RunAfter, &jobError, &payload, &job.Progress, &stage, &result, &job.CreatedAt, &job.UpdatedAt, &startedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Type = models.ScanJobType(jobType)
+	job.Status = models.ScanJobStatus(status)
+	job.Error = jobError.String
+	if payload.Valid && payload.String != "" {
+		job.Payload = []byte(payload.String)
+	}
+	job.Stage = stage.String
+	if result.Valid && result.String != "" {
+		job.Result = []byte(result.String)
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return &job, nil
+}
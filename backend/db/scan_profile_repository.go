@@ -0,0 +1,187 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reconya-ai/models"
+	"strings"
+	"time"
+)
+
+// ScanProfileRepository stores the scan profiles and which network uses which
+type ScanProfileRepository struct {
+	db *sql.DB
+}
+
+func NewScanProfileRepository(db *sql.DB) *ScanProfileRepository {
+	return &ScanProfileRepository{db: db}
+}
+
+const scanProfileColumns = `id, name, description, builtin, discovery, discovery_ports, discovery_timeout, port_scan, ports,
+	port_scan_timeout, timing, max_rate, scan_delay, service_versions, os_detection, web, screenshots, snmp, created_at, updated_at`
+
+// Create stores a new profile, assigning its ID
+func (r *ScanProfileRepository) Create(ctx context.Context, profile *models.ScanProfile) (*models.ScanProfile, error) {
+	now := time.Now().UTC()
+	profile.ID = GenerateID()
+	profile.CreatedAt = now
+	profile.UpdatedAt = now
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO scan_profiles (`+scanProfileColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		append([]interface{}{profile.ID}, append(scanProfileValues(profile), now, now)...)...)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, fmt.Errorf("a scan profile named %q already exists", profile.Name)
+		}
+		return nil, fmt.Errorf("failed to create scan profile: %w", err)
+	}
+	return profile, nil
+}
+
+// Update saves a profile, built-in profiles are only changed by SeedBuiltin
+func (r *ScanProfileRepository) Update(ctx context.Context, profile *models.ScanProfile) error {
+	profile.UpdatedAt = time.Now().UTC()
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE scan_profiles SET name = ?, description = ?, builtin = ?, discovery = ?, discovery_ports = ?, discovery_timeout = ?,
+			port_scan = ?, ports = ?, port_scan_timeout = ?, timing = ?, max_rate = ?, scan_delay = ?, service_versions = ?,
+			os_detection = ?, web = ?, screenshots = ?, snmp = ?, updated_at = ? WHERE id = ? AND builtin = 0`,
+		append(scanProfileValues(profile), profile.UpdatedAt, profile.ID)...)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return fmt.Errorf("a scan profile named %q already exists", profile.Name)
+		}
+		return fmt.Errorf("failed to update scan profile: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SeedBuiltin adds a built-in profile or brings it up to date by name
+func (r *ScanProfileRepository) SeedBuiltin(ctx context.Context, profile *models.ScanProfile) error {
+	now := time.Now().UTC()
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO scan_profiles (`+scanProfileColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET description = excluded.description, builtin = 1, discovery = excluded.discovery,
+			discovery_ports = excluded.discovery_ports, discovery_timeout = excluded.discovery_timeout, port_scan = excluded.port_scan,
+			ports = excluded.ports, port_scan_timeout = excluded.port_scan_timeout, timing = excluded.timing,
+			max_rate = excluded.max_rate, scan_delay = excluded.scan_delay, service_versions = excluded.service_versions,
+			os_detection = excluded.os_detection, web = excluded.web, screenshots = excluded.screenshots, snmp = excluded.snmp,
+			updated_at = excluded.updated_at`,
+		append([]interface{}{GenerateID()}, append(scanProfileValues(profile), now, now)...)...)
+	if err != nil {
+		return fmt.Errorf("failed to seed scan profile %s: %w", profile.Name, err)
+	}
+	return nil
+}
+
+// Delete removes a custom profile, the networks using it go back to the default
+func (r *ScanProfileRepository) Delete(ctx context.Context, id string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM scan_profiles WHERE id = ? AND builtin = 0`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete scan profile: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE networks SET scan_profile_id = NULL WHERE scan_profile_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to unassign scan profile: %w", err)
+	}
+	return tx.Commit()
+}
+
+// SetNetworkProfile assigns a profile to a network, empty goes back to the default
+func (r *ScanProfileRepository) SetNetworkProfile(ctx context.Context, networkID, profileID string) error {
+	result, err := r.db.ExecContext(ctx, `UPDATE networks SET scan_profile_id = ?, updated_at = ? WHERE id = ?`,
+		nullableString(&profileID), time.Now(), networkID)
+	if err != nil {
+		return fmt.Errorf("failed to update network scan profile: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// FindByID retrieves a single profile
+func (r *ScanProfileRepository) FindByID(ctx context.Context, id string) (*models.ScanProfile, error) {
+	return r.findOne(ctx, `SELECT `+scanProfileColumns+` FROM scan_profiles WHERE id = ?`, id)
+}
+
+// FindByName retrieves a single profile by its name
+func (r *ScanProfileRepository) FindByName(ctx context.Context, name string) (*models.ScanProfile, error) {
+	return r.findOne(ctx, `SELECT `+scanProfileColumns+` FROM scan_profiles WHERE name = ?`, name)
+}
+
+// FindAll lists the built-in profiles first, then the custom ones by name
+func (r *ScanProfileRepository) FindAll(ctx context.Context) ([]*models.ScanProfile, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+scanProfileColumns+` FROM scan_profiles ORDER BY builtin DESC, created_at, name`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying scan profiles: %w", err)
+	}
+	defer rows.Close()
+
+	var profiles []*models.ScanProfile
+	for rows.Next() {
+		profile, err := scanScanProfile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning scan profile: %w", err)
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, rows.Err()
+}
+
+func (r *ScanProfileRepository) findOne(ctx context.Context, query string, arg string) (*models.ScanProfile, error) {
+	profile, err := scanScanProfile(r.db.QueryRowContext(ctx, query, arg))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find scan profile: %w", err)
+	}
+	return profile, nil
+}
+
+// scanProfileValues are the stored fields from name to snmp, in column order
+func scanProfileValues(profile *models.ScanProfile) []interface{} {
+	return []interface{}{
+		profile.Name, nullableString(&profile.Description), profile.Builtin, nullableJSON(profile.Discovery),
+		nullableString(&profile.DiscoveryPorts), profile.DiscoveryTimeout, profile.PortScan, nullableString(&profile.Ports),
+		profile.PortScanTimeout, profile.Timing, profile.MaxRate, profile.ScanDelay, profile.ServiceVersions,
+		profile.OSDetection, profile.Web, profile.Screenshots, profile.SNMP,
+	}
+}
+
+func scanScanProfile(row rowScanner) (*models.ScanProfile, error) {
+	var profile models.ScanProfile
+	var description, discovery, discoveryPorts, ports sql.NullString
+
+	err := row.Scan(&profile.ID, &profile.Name, &description, &profile.Builtin, &discovery, &discoveryPorts,
+		&profile.DiscoveryTimeout, &profile.PortScan, &ports, &profile.PortScanTimeout, &profile.Timing, &profile.MaxRate,
+		&profile.ScanDelay, &profile.ServiceVersions, &profile.OSDetection, &profile.Web, &profile.Screenshots, &profile.SNMP,
+		&profile.CreatedAt, &profile.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	profile.Description = description.String
+	profile.DiscoveryPorts = discoveryPorts.String
+	profile.Ports = ports.String
+	if discovery.Valid && discovery.String != "" {
+		if err := json.Unmarshal([]byte(discovery.String), &profile.Discovery); err != nil {
+			return nil, fmt.Errorf("invalid discovery methods: %w", err)
+		}
+	}
+	return &profile, nil
+}
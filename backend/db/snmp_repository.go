@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reconya-ai/models"
+	"time"
+)
+
+// SNMPCredentialRepository stores per-network SNMP credentials. Secret fields are
+// expected to be encrypted by the caller before they reach the repository.
+type SNMPCredentialRepository struct {
+	db *sql.DB
+}
+
+func NewSNMPCredentialRepository(db *sql.DB) *SNMPCredentialRepository {
+	return &SNMPCredentialRepository{db: db}
+}
+
+const snmpCredentialColumns = `id, network_id, version, port, community, username, auth_protocol,
+	auth_passphrase, priv_protocol, priv_passphrase, created_at, updated_at`
+
+// FindByID retrieves a single credential
+func (r *SNMPCredentialRepository) FindByID(ctx context.Context, id string) (*models.SNMPCredential, error) {
+	query := `SELECT ` + snmpCredentialColumns + ` FROM snmp_credentials WHERE id = ?`
+	row := r.db.QueryRowContext(ctx, query, id)
+
+	cred, err := scanSNMPCredential(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find SNMP credential: %w", err)
+	}
+	return cred, nil
+}
+
+// FindByNetworkID returns the credentials of a network in the order they should be tried
+func (r *SNMPCredentialRepository) FindByNetworkID(ctx context.Context, networkID string) ([]*models.SNMPCredential, error) {
+	query := `SELECT ` + snmpCredentialColumns + ` FROM snmp_credentials WHERE network_id = ? ORDER BY created_at ASC`
+	rows, err := r.db.QueryContext(ctx, query, networkID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying SNMP credentials: %w", err)
+	}
+	defer rows.Close()
+
+	var creds []*models.SNMPCredential
+	for rows.Next() {
+		cred, err := scanSNMPCredential(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning SNMP credential: %w", err)
+		}
+		creds = append(creds, cred)
+	}
+
+	return creds, rows.Err()
+}
+
+// Upsert creates or updates a credential
+func (r *SNMPCredentialRepository) Upsert(ctx context.Context, cred *models.SNMPCredential) error {
+	if cred.ID == "" {
+		cred.ID = GenerateID()
+	}
+
+	now := time.Now()
+	if cred.CreatedAt.IsZero() {
+		cred.CreatedAt = now
+	}
+	cred.UpdatedAt = now
+
+	query := `
+		INSERT INTO snmp_credentials (` + snmpCredentialColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			network_id = excluded.network_id,
+			version = excluded.version,
+			port = excluded.port,
+			community = excluded.community,
+			username = excluded.username,
+			auth_protocol = excluded.auth_protocol,
+			auth_passphrase = excluded.auth_passphrase,
+			priv_protocol = excluded.priv_protocol,
+			priv_passphrase = excluded.priv_passphrase,
+			updated_at = excluded.updated_at
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		cred.ID, cred.NetworkID, string(cred.Version), cred.Port,
+		nullableString(&cred.Community), nullableString(&cred.Username), nullableString(&cred.AuthProtocol),
+		nullableString(&cred.AuthPassphrase), nullableString(&cred.PrivProtocol), nullableString(&cred.PrivPassphrase),
+		cred.CreatedAt, cred.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to upsert SNMP credential: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a credential
+func (r *SNMPCredentialRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM snmp_credentials WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete SNMP credential: %w", err)
+	}
+	return nil
+}
+
+// DeleteByNetworkID removes all credentials of a network
+func (r *SNMPCredentialRepository) DeleteByNetworkID(ctx context.Context, networkID string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM snmp_credentials WHERE network_id = ?`, networkID)
+	if err != nil {
+		return fmt.Errorf("failed to delete SNMP credentials for network: %w", err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSNMPCredential(row rowScanner) (*models.SNMPCredential, error) {
+	var cred models.SNMPCredential
+	var version string
+	var community, username, authProtocol, authPassphrase, privProtocol, privPassphrase sql.NullString
+
+	err := row.Scan(&cred.ID, &cred.NetworkID, &version, &cred.Port, &community, &username, &authProtocol,
+		&authPassphrase, &privProtocol, &privPassphrase, &cred.CreatedAt, &cred.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	cred.Version = models.SNMPVersion(version)
+	cred.Community = community.String
+	cred.Username = username.String
+	cred.AuthProtocol = authProtocol.String
+	cred.AuthPassphrase = authPassphrase.String
+	cred.PrivProtocol = privProtocol.String
+	cred.PrivPassphrase = privPassphrase.String
+
+	return &cred, nil
+}
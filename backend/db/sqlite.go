@@ -3,14 +3,17 @@ package db
 import (
 	"database/sql"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+
+	"reconya-ai/internal/logging"
 )
 
+var logger = logging.For("database")
+
 // ConnectToSQLite initializes and returns a SQLite connection
 func ConnectToSQLite(dbPath string) (*sql.DB, error) {
 	// Ensure the directory exists
@@ -51,7 +54,7 @@ func ConnectToSQLite(dbPath string) (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to ping SQLite database: %w", err)
 	}
 
-	log.Println("Connected to SQLite database with optimized settings for concurrency")
+	logger.Infof("Connected to SQLite database with optimized settings for concurrency")
 	return db, nil
 }
 
@@ -89,10 +92,16 @@ func InitializeSchema(db *sql.DB) error {
 		return fmt.Errorf("failed to create devices table: %w", err)
 	}
 
-	// Create unique index on ipv4 to prevent duplicate IP addresses
-	_, err = db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_devices_ipv4 ON devices(ipv4)`)
+	// Devices split apart by MAC may share an IP address, so replace the unique index
+	// on ipv4 that older databases have with a plain one
+	_, err = db.Exec(`DROP INDEX IF EXISTS idx_devices_ipv4`)
 	if err != nil {
-		return fmt.Errorf("failed to create unique index on devices.ipv4: %w", err)
+		return fmt.Errorf("failed to drop unique index on devices.ipv4: %w", err)
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_devices_ipv4_lookup ON devices(ipv4)`)
+	if err != nil {
+		return fmt.Errorf("failed to create index on devices.ipv4: %w", err)
 	}
 
 	// Create index on MAC address for faster lookups
@@ -110,17 +119,17 @@ func InitializeSchema(db *sql.DB) error {
 	// Create IPv6 indexes for faster IPv6 lookups
 	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_devices_ipv6_link_local ON devices(ipv6_link_local)`)
 	if err != nil {
-		log.Printf("Note: IPv6 link local index might already exist: %v", err)
+		logger.Debugf("IPv6 link local index might already exist: %v", err)
 	}
 
 	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_devices_ipv6_unique_local ON devices(ipv6_unique_local)`)
 	if err != nil {
-		log.Printf("Note: IPv6 unique local index might already exist: %v", err)
+		logger.Debugf("IPv6 unique local index might already exist: %v", err)
 	}
 
 	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_devices_ipv6_global ON devices(ipv6_global)`)
 	if err != nil {
-		log.Printf("Note: IPv6 global index might already exist: %v", err)
+		logger.Debugf("IPv6 global index might already exist: %v", err)
 	}
 
 	// Create ports table
@@ -187,107 +196,166 @@ func InitializeSchema(db *sql.DB) error {
 	_, err = db.Exec(`ALTER TABLE devices ADD COLUMN web_scan_ended_at TIMESTAMP`)
 	if err != nil {
 		// Column might already exist, so we ignore the error
-		log.Printf("Note: web_scan_ended_at column might already exist: %v", err)
+		logger.Debugf("Web_scan_ended_at column might already exist: %v", err)
 	}
 
 	// Add device fingerprinting columns if they don't exist (for backward compatibility)
 	_, err = db.Exec(`ALTER TABLE devices ADD COLUMN device_type TEXT`)
 	if err != nil {
-		log.Printf("Note: device_type column might already exist: %v", err)
+		logger.Debugf("Device_type column might already exist: %v", err)
 	}
 
 	_, err = db.Exec(`ALTER TABLE devices ADD COLUMN os_name TEXT`)
 	if err != nil {
-		log.Printf("Note: os_name column might already exist: %v", err)
+		logger.Debugf("Os_name column might already exist: %v", err)
 	}
 
 	_, err = db.Exec(`ALTER TABLE devices ADD COLUMN os_version TEXT`)
 	if err != nil {
-		log.Printf("Note: os_version column might already exist: %v", err)
+		logger.Debugf("Os_version column might already exist: %v", err)
 	}
 
 	_, err = db.Exec(`ALTER TABLE devices ADD COLUMN os_family TEXT`)
 	if err != nil {
-		log.Printf("Note: os_family column might already exist: %v", err)
+		logger.Debugf("Os_family column might already exist: %v", err)
 	}
 
 	_, err = db.Exec(`ALTER TABLE devices ADD COLUMN os_confidence INTEGER`)
 	if err != nil {
-		log.Printf("Note: os_confidence column might already exist: %v", err)
+		logger.Debugf("Os_confidence column might already exist: %v", err)
 	}
 
 	// Add comment column if it doesn't exist (for device editing)
 	_, err = db.Exec(`ALTER TABLE devices ADD COLUMN comment TEXT`)
 	if err != nil {
-		log.Printf("Note: comment column might already exist: %v", err)
+		logger.Debugf("Comment column might already exist: %v", err)
 	}
 
 	// Add IPv6 columns if they don't exist (for IPv6 support)
 	_, err = db.Exec(`ALTER TABLE devices ADD COLUMN ipv6_link_local TEXT`)
 	if err != nil {
-		log.Printf("Note: ipv6_link_local column might already exist: %v", err)
+		logger.Debugf("Ipv6_link_local column might already exist: %v", err)
 	}
 
 	_, err = db.Exec(`ALTER TABLE devices ADD COLUMN ipv6_unique_local TEXT`)
 	if err != nil {
-		log.Printf("Note: ipv6_unique_local column might already exist: %v", err)
+		logger.Debugf("Ipv6_unique_local column might already exist: %v", err)
 	}
 
 	_, err = db.Exec(`ALTER TABLE devices ADD COLUMN ipv6_global TEXT`)
 	if err != nil {
-		log.Printf("Note: ipv6_global column might already exist: %v", err)
+		logger.Debugf("Ipv6_global column might already exist: %v", err)
 	}
 
 	_, err = db.Exec(`ALTER TABLE devices ADD COLUMN ipv6_addresses TEXT`)
 	if err != nil {
-		log.Printf("Note: ipv6_addresses column might already exist: %v", err)
+		logger.Debugf("Ipv6_addresses column might already exist: %v", err)
+	}
+
+	// Add UPnP description column (JSON) populated by SSDP discovery
+	_, err = db.Exec(`ALTER TABLE devices ADD COLUMN upnp_info TEXT`)
+	if err != nil {
+		logger.Debugf("Upnp_info column might already exist: %v", err)
+	}
+
+	// Add SNMP system/interface column (JSON) populated by SNMP polling
+	_, err = db.Exec(`ALTER TABLE devices ADD COLUMN snmp_info TEXT`)
+	if err != nil {
+		logger.Debugf("Snmp_info column might already exist: %v", err)
+	}
+
+	// Add switch port column (JSON) learned from bridge forwarding and LLDP/CDP tables
+	_, err = db.Exec(`ALTER TABLE devices ADD COLUMN switch_port TEXT`)
+	if err != nil {
+		logger.Debugf("Switch_port column might already exist: %v", err)
+	}
+
+	// Add trust state column (new, approved, blocked) for the authorized device baseline
+	_, err = db.Exec(`ALTER TABLE devices ADD COLUMN trust_state TEXT`)
+	if err != nil {
+		logger.Debugf("Trust_state column might already exist: %v", err)
+	}
+
+	// Add identity column (JSON) with randomized MAC and correlation signals
+	_, err = db.Exec(`ALTER TABLE devices ADD COLUMN identity TEXT`)
+	if err != nil {
+		logger.Debugf("Identity column might already exist: %v", err)
+	}
+
+	// Add sensor_id column, the agent that last reported the device
+	_, err = db.Exec(`ALTER TABLE devices ADD COLUMN sensor_id TEXT`)
+	if err != nil {
+		logger.Debugf("Sensor_id column might already exist: %v", err)
 	}
 
 	// Add network table columns for extended network management
+	// Service versions found by nmap -sV during device rescans
+	_, err = db.Exec(`ALTER TABLE ports ADD COLUMN version TEXT`)
+	if err != nil {
+		logger.Debugf("Ports.version column might already exist: %v", err)
+	}
+
 	_, err = db.Exec(`ALTER TABLE networks ADD COLUMN name TEXT`)
 	if err != nil {
-		log.Printf("Note: networks.name column might already exist: %v", err)
+		logger.Debugf("Networks.name column might already exist: %v", err)
 	}
 
 	_, err = db.Exec(`ALTER TABLE networks ADD COLUMN description TEXT`)
 	if err != nil {
-		log.Printf("Note: networks.description column might already exist: %v", err)
+		logger.Debugf("Networks.description column might already exist: %v", err)
 	}
 
 	_, err = db.Exec(`ALTER TABLE networks ADD COLUMN status TEXT DEFAULT 'active'`)
 	if err != nil {
-		log.Printf("Note: networks.status column might already exist: %v", err)
+		logger.Debugf("Networks.status column might already exist: %v", err)
 	}
 
 	_, err = db.Exec(`ALTER TABLE networks ADD COLUMN last_scanned_at TIMESTAMP`)
 	if err != nil {
-		log.Printf("Note: networks.last_scanned_at column might already exist: %v", err)
+		logger.Debugf("Networks.last_scanned_at column might already exist: %v", err)
 	}
 
 	_, err = db.Exec(`ALTER TABLE networks ADD COLUMN device_count INTEGER DEFAULT 0`)
 	if err != nil {
-		log.Printf("Note: networks.device_count column might already exist: %v", err)
+		logger.Debugf("Networks.device_count column might already exist: %v", err)
 	}
 
 	_, err = db.Exec(`ALTER TABLE networks ADD COLUMN created_at TIMESTAMP`)
 	if err != nil {
-		log.Printf("Note: networks.created_at column might already exist: %v", err)
+		logger.Debugf("Networks.created_at column might already exist: %v", err)
 	}
 
 	_, err = db.Exec(`ALTER TABLE networks ADD COLUMN updated_at TIMESTAMP`)
 	if err != nil {
-		log.Printf("Note: networks.updated_at column might already exist: %v", err)
+		logger.Debugf("Networks.updated_at column might already exist: %v", err)
 	}
 
 	// Add IPv6 support to networks table
 	_, err = db.Exec(`ALTER TABLE networks ADD COLUMN ipv6_prefix TEXT`)
 	if err != nil {
-		log.Printf("Note: networks.ipv6_prefix column might already exist: %v", err)
+		logger.Debugf("Networks.ipv6_prefix column might already exist: %v", err)
 	}
 
 	_, err = db.Exec(`ALTER TABLE networks ADD COLUMN address_family TEXT DEFAULT 'ipv4'`)
 	if err != nil {
-		log.Printf("Note: networks.address_family column might already exist: %v", err)
+		logger.Debugf("Networks.address_family column might already exist: %v", err)
+	}
+
+	// Lockdown raises an alert for every device whose MAC is not approved
+	_, err = db.Exec(`ALTER TABLE networks ADD COLUMN lockdown INTEGER DEFAULT 0`)
+	if err != nil {
+		logger.Debugf("Networks.lockdown column might already exist: %v", err)
+	}
+
+	// Expected DHCP servers and the legitimate server last seen by the DHCP probe
+	_, err = db.Exec(`ALTER TABLE networks ADD COLUMN dhcp_allowlist TEXT`)
+	if err != nil {
+		logger.Debugf("Networks.dhcp_allowlist column might already exist: %v", err)
+	}
+
+	_, err = db.Exec(`ALTER TABLE networks ADD COLUMN dhcp_server TEXT`)
+	if err != nil {
+		logger.Debugf("Networks.dhcp_server column might already exist: %v", err)
 	}
 
 	// Create web_services table
@@ -371,7 +439,367 @@ func InitializeSchema(db *sql.DB) error {
 		return fmt.Errorf("failed to create index on settings.user_id: %w", err)
 	}
 
-	log.Println("Database schema initialized successfully")
+	// Create snmp_credentials table (secrets are stored encrypted)
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS snmp_credentials (
+		id TEXT PRIMARY KEY,
+		network_id TEXT NOT NULL,
+		version TEXT NOT NULL,
+		port INTEGER NOT NULL DEFAULT 161,
+		community TEXT,
+		username TEXT,
+		auth_protocol TEXT,
+		auth_passphrase TEXT,
+		priv_protocol TEXT,
+		priv_passphrase TEXT,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL,
+		FOREIGN KEY (network_id) REFERENCES networks(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create snmp_credentials table: %w", err)
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_snmp_credentials_network_id ON snmp_credentials(network_id)`)
+	if err != nil {
+		return fmt.Errorf("failed to create index on snmp_credentials.network_id: %w", err)
+	}
+
+	// Create wake_schedules table for scheduled Wake-on-LAN (SecureOn passwords are stored encrypted)
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS wake_schedules (
+		id TEXT PRIMARY KEY,
+		device_ids TEXT,
+		network_id TEXT,
+		run_at TIMESTAMP NOT NULL,
+		port INTEGER NOT NULL DEFAULT 9,
+		interface TEXT,
+		broadcast_address TEXT,
+		secure_on_password TEXT,
+		status TEXT NOT NULL,
+		error TEXT,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create wake_schedules table: %w", err)
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_wake_schedules_status_run_at ON wake_schedules(status, run_at)`)
+	if err != nil {
+		return fmt.Errorf("failed to create index on wake_schedules: %w", err)
+	}
+
+	// Create tag, group and custom field tables for device inventory
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS tags (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		color TEXT,
+		created_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create tags table: %w", err)
+	}
+
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS device_tags (
+		device_id TEXT NOT NULL,
+		tag_id TEXT NOT NULL,
+		PRIMARY KEY (device_id, tag_id),
+		FOREIGN KEY (device_id) REFERENCES devices(id),
+		FOREIGN KEY (tag_id) REFERENCES tags(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create device_tags table: %w", err)
+	}
+
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS device_groups (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		parent_id TEXT,
+		created_at TIMESTAMP NOT NULL,
+		FOREIGN KEY (parent_id) REFERENCES device_groups(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create device_groups table: %w", err)
+	}
+
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS device_group_members (
+		device_id TEXT NOT NULL,
+		group_id TEXT NOT NULL,
+		PRIMARY KEY (device_id, group_id),
+		FOREIGN KEY (device_id) REFERENCES devices(id),
+		FOREIGN KEY (group_id) REFERENCES device_groups(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create device_group_members table: %w", err)
+	}
+
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS custom_fields (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		label TEXT,
+		type TEXT NOT NULL,
+		options TEXT,
+		created_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create custom_fields table: %w", err)
+	}
+
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS device_custom_values (
+		device_id TEXT NOT NULL,
+		field_id TEXT NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (device_id, field_id),
+		FOREIGN KEY (device_id) REFERENCES devices(id),
+		FOREIGN KEY (field_id) REFERENCES custom_fields(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create device_custom_values table: %w", err)
+	}
+
+	// Create MAC baseline table, an empty network_id applies the entry to every network
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS mac_baseline (
+		id TEXT PRIMARY KEY,
+		mac TEXT NOT NULL,
+		network_id TEXT NOT NULL DEFAULT '',
+		label TEXT,
+		created_at TIMESTAMP NOT NULL,
+		UNIQUE (mac, network_id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create mac_baseline table: %w", err)
+	}
+
+	// Create device operations table, the snapshot holds what is needed to undo a merge or split
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS device_operations (
+		id TEXT PRIMARY KEY,
+		type TEXT NOT NULL,
+		device_id TEXT NOT NULL,
+		other_device_id TEXT NOT NULL,
+		mac TEXT,
+		description TEXT NOT NULL,
+		snapshot TEXT,
+		created_at TIMESTAMP NOT NULL,
+		undone_at TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create device_operations table: %w", err)
+	}
+
+	// Create IPv6 address table, one row per address with its lifecycle. address_hex
+	// holds the 32 hex digit form of the address so prefixes are indexed range queries.
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS device_ipv6_addresses (
+		address TEXT PRIMARY KEY,
+		address_hex TEXT NOT NULL,
+		device_id TEXT NOT NULL,
+		type TEXT NOT NULL,
+		source TEXT NOT NULL,
+		state TEXT NOT NULL DEFAULT 'active',
+		first_seen TIMESTAMP NOT NULL,
+		last_seen TIMESTAMP NOT NULL,
+		FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create device_ipv6_addresses table: %w", err)
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_device_ipv6_addresses_device_id ON device_ipv6_addresses(device_id)`)
+	if err != nil {
+		return fmt.Errorf("failed to create device_ipv6_addresses device_id index: %w", err)
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_device_ipv6_addresses_hex ON device_ipv6_addresses(address_hex)`)
+	if err != nil {
+		return fmt.Errorf("failed to create device_ipv6_addresses address_hex index: %w", err)
+	}
+
+	// Create agents table for remote sensors, key_hash is the SHA-256 of the API key
+	// handed out at enrollment (certificate enrolled agents have none)
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS agents (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		hostname TEXT,
+		platform TEXT,
+		key_hash TEXT UNIQUE,
+		enrolled_via TEXT NOT NULL,
+		remote_addr TEXT,
+		last_error TEXT,
+		last_check_in TIMESTAMP,
+		last_report_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create agents table: %w", err)
+	}
+
+	// Create agent networks table, the networks each agent scans
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS agent_networks (
+		agent_id TEXT NOT NULL,
+		network_id TEXT NOT NULL,
+		PRIMARY KEY (agent_id, network_id),
+		FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE,
+		FOREIGN KEY (network_id) REFERENCES networks(id) ON DELETE CASCADE
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create agent_networks table: %w", err)
+	}
+
+	// Create scan_jobs table for the persistent port, web and fingerprint scan queue
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS scan_jobs (
+		id TEXT PRIMARY KEY,
+		type TEXT NOT NULL,
+		device_id TEXT NOT NULL,
+		priority INTEGER NOT NULL DEFAULT 0,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		max_attempts INTEGER NOT NULL DEFAULT 3,
+		run_after TIMESTAMP NOT NULL,
+		error TEXT,
+		payload TEXT,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL,
+		started_at TIMESTAMP,
+		finished_at TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create scan_jobs table: %w", err)
+	}
+
+	// Progress and results of long jobs such as device rescans
+	for _, column := range []string{"progress INTEGER NOT NULL DEFAULT 0", "stage TEXT", "result TEXT"} {
+		if _, err := db.Exec(`ALTER TABLE scan_jobs ADD COLUMN ` + column); err != nil {
+			logger.Debugf("Scan_jobs column might already exist: %v", err)
+		}
+	}
+
+	// At most one waiting or running job of each type per device
+	_, err = db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_scan_jobs_active ON scan_jobs(type, device_id) WHERE status IN ('queued', 'running')`)
+	if err != nil {
+		return fmt.Errorf("failed to create index on scan_jobs: %w", err)
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_scan_jobs_claim ON scan_jobs(type, status, priority DESC, run_after)`)
+	if err != nil {
+		return fmt.Errorf("failed to create index on scan_jobs: %w", err)
+	}
+
+	// Create adhoc_scans table, the scratch area for scans outside the saved networks
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS adhoc_scans (
+		id TEXT PRIMARY KEY,
+		targets TEXT NOT NULL,
+		profile TEXT NOT NULL,
+		status TEXT NOT NULL,
+		progress INTEGER NOT NULL DEFAULT 0,
+		devices TEXT,
+		errors TEXT,
+		error TEXT,
+		network_id TEXT,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL,
+		started_at TIMESTAMP,
+		finished_at TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create adhoc_scans table: %w", err)
+	}
+
+	// Create scan_profiles table, the built-in profiles are seeded by the scan profile service
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS scan_profiles (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		description TEXT,
+		builtin INTEGER NOT NULL DEFAULT 0,
+		discovery TEXT NOT NULL,
+		discovery_ports TEXT,
+		discovery_timeout INTEGER NOT NULL DEFAULT 0,
+		port_scan INTEGER NOT NULL DEFAULT 0,
+		ports TEXT,
+		port_scan_timeout INTEGER NOT NULL DEFAULT 0,
+		timing INTEGER NOT NULL DEFAULT 4,
+		max_rate INTEGER NOT NULL DEFAULT 0,
+		scan_delay INTEGER NOT NULL DEFAULT 0,
+		service_versions INTEGER NOT NULL DEFAULT 0,
+		os_detection INTEGER NOT NULL DEFAULT 0,
+		web INTEGER NOT NULL DEFAULT 0,
+		screenshots INTEGER NOT NULL DEFAULT 0,
+		snmp INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create scan_profiles table: %w", err)
+	}
+
+	// Networks without a scan profile use the standard one
+	_, err = db.Exec(`ALTER TABLE networks ADD COLUMN scan_profile_id TEXT`)
+	if err != nil {
+		logger.Debugf("Networks.scan_profile_id column might already exist: %v", err)
+	}
+
+	// Create exclusion_rules table, hosts that some scan stages must never touch
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS exclusion_rules (
+		id TEXT PRIMARY KEY,
+		scope TEXT NOT NULL,
+		network_id TEXT,
+		device_id TEXT,
+		address TEXT,
+		stages TEXT NOT NULL,
+		reason TEXT,
+		created_by TEXT,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create exclusion_rules table: %w", err)
+	}
+
+	// Create exclusion_audit table, who changed the rules and what they kept the scanners away from.
+	// Blocks are counted per rule, stage and address so repeated sweeps add no rows.
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS exclusion_audit (
+		id TEXT PRIMARY KEY,
+		rule_id TEXT NOT NULL,
+		action TEXT NOT NULL,
+		stage TEXT NOT NULL DEFAULT '',
+		target TEXT NOT NULL DEFAULT '',
+		actor TEXT,
+		detail TEXT,
+		count INTEGER NOT NULL DEFAULT 1,
+		first_at TIMESTAMP NOT NULL,
+		last_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create exclusion_audit table: %w", err)
+	}
+
+	_, err = db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_exclusion_audit_blocked ON exclusion_audit(rule_id, stage, target) WHERE action = 'blocked'`)
+	if err != nil {
+		return fmt.Errorf("failed to create index on exclusion_audit: %w", err)
+	}
+
+	// Addresses stored on devices before the table existed are registered once
+	if err := backfillIPv6Addresses(db); err != nil {
+		logger.Warnf("Failed to backfill IPv6 addresses: %v", err)
+	}
+
+	logger.Infof("Database schema initialized successfully")
 	return nil
 }
 
@@ -387,9 +815,9 @@ func ResetPortScanCooldowns(db *sql.DB) error {
 	_, err = db.Exec(`UPDATE devices SET web_scan_ended_at = NULL`)
 	if err != nil {
 		// Column might not exist yet, so we ignore this error
-		log.Printf("Note: web_scan_ended_at column might not exist yet: %v", err)
+		logger.Debugf("Web_scan_ended_at column might not exist yet: %v", err)
 	}
 
-	log.Println("Port scan cooldowns reset - all devices are now eligible for scanning")
+	logger.Infof("Port scan cooldowns reset - all devices are now eligible for scanning")
 	return nil
 }
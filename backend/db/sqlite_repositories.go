@@ -6,8 +6,8 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
 	"reconya-ai/models"
+	"reflect"
 	"time"
 )
 
@@ -28,15 +28,16 @@ func (r *SQLiteNetworkRepository) Close() error {
 
 // FindByID finds a network by ID
 func (r *SQLiteNetworkRepository) FindByID(ctx context.Context, id string) (*models.Network, error) {
-	query := `SELECT id, name, cidr, description, status, last_scanned_at, device_count, created_at, updated_at FROM networks WHERE id = ?`
+	query := `SELECT id, name, cidr, description, status, last_scanned_at, device_count, COALESCE(lockdown, 0), dhcp_allowlist, dhcp_server, ipv6_prefix, COALESCE(address_family, 'ipv4'), COALESCE(scan_profile_id, ''), created_at, updated_at FROM networks WHERE id = ?`
 	row := r.db.QueryRowContext(ctx, query, id)
 
 	var network models.Network
 	var name, description, status sql.NullString
 	var lastScannedAt, createdAt, updatedAt sql.NullTime
 	var deviceCount sql.NullInt64
+	var dhcpAllowlist, dhcpServer, ipv6Prefix sql.NullString
 	
-	err := row.Scan(&network.ID, &name, &network.CIDR, &description, &status, &lastScannedAt, &deviceCount, &createdAt, &updatedAt)
+	err := row.Scan(&network.ID, &name, &network.CIDR, &description, &status, &lastScannedAt, &deviceCount, &network.Lockdown, &dhcpAllowlist, &dhcpServer, &ipv6Prefix, &network.AddressFamily, &network.ScanProfileID, &createdAt, &updatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
@@ -65,21 +66,26 @@ func (r *SQLiteNetworkRepository) FindByID(ctx context.Context, id string) (*mod
 	if updatedAt.Valid {
 		network.UpdatedAt = updatedAt.Time
 	}
+	scanNetworkDHCP(&network, dhcpAllowlist, dhcpServer)
+	if ipv6Prefix.Valid && ipv6Prefix.String != "" {
+		network.IPv6Prefix = &ipv6Prefix.String
+	}
 
 	return &network, nil
 }
 
 // FindByCIDR finds a network by CIDR
 func (r *SQLiteNetworkRepository) FindByCIDR(ctx context.Context, cidr string) (*models.Network, error) {
-	query := `SELECT id, name, cidr, description, status, last_scanned_at, device_count, created_at, updated_at FROM networks WHERE cidr = ?`
+	query := `SELECT id, name, cidr, description, status, last_scanned_at, device_count, COALESCE(lockdown, 0), dhcp_allowlist, dhcp_server, ipv6_prefix, COALESCE(address_family, 'ipv4'), COALESCE(scan_profile_id, ''), created_at, updated_at FROM networks WHERE cidr = ?`
 	row := r.db.QueryRowContext(ctx, query, cidr)
 
 	var network models.Network
 	var name, description, status sql.NullString
 	var lastScannedAt, createdAt, updatedAt sql.NullTime
 	var deviceCount sql.NullInt64
+	var dhcpAllowlist, dhcpServer, ipv6Prefix sql.NullString
 	
-	err := row.Scan(&network.ID, &name, &network.CIDR, &description, &status, &lastScannedAt, &deviceCount, &createdAt, &updatedAt)
+	err := row.Scan(&network.ID, &name, &network.CIDR, &description, &status, &lastScannedAt, &deviceCount, &network.Lockdown, &dhcpAllowlist, &dhcpServer, &ipv6Prefix, &network.AddressFamily, &network.ScanProfileID, &createdAt, &updatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
@@ -108,6 +114,10 @@ func (r *SQLiteNetworkRepository) FindByCIDR(ctx context.Context, cidr string) (
 	if updatedAt.Valid {
 		network.UpdatedAt = updatedAt.Time
 	}
+	scanNetworkDHCP(&network, dhcpAllowlist, dhcpServer)
+	if ipv6Prefix.Valid && ipv6Prefix.String != "" {
+		network.IPv6Prefix = &ipv6Prefix.String
+	}
 
 	return &network, nil
 }
@@ -121,6 +131,12 @@ func (r *SQLiteNetworkRepository) FindAll(ctx context.Context) ([]*models.Networ
 		COALESCE(status, 'active') as status, 
 		last_scanned_at, 
 		COALESCE(device_count, 0) as device_count, 
+		COALESCE(lockdown, 0) as lockdown,
+		dhcp_allowlist,
+		dhcp_server,
+		ipv6_prefix,
+		COALESCE(address_family, 'ipv4') as address_family,
+		COALESCE(scan_profile_id, '') as scan_profile_id,
 		COALESCE(created_at, datetime('now')) as created_at, 
 		COALESCE(updated_at, datetime('now')) as updated_at 
 	FROM networks ORDER BY created_at DESC`
@@ -135,11 +151,16 @@ func (r *SQLiteNetworkRepository) FindAll(ctx context.Context) ([]*models.Networ
 		var network models.Network
 		var lastScannedAt sql.NullTime
 		var createdAtStr, updatedAtStr string
+		var dhcpAllowlist, dhcpServer, ipv6Prefix sql.NullString
 		
-		err := rows.Scan(&network.ID, &network.Name, &network.CIDR, &network.Description, &network.Status, &lastScannedAt, &network.DeviceCount, &createdAtStr, &updatedAtStr)
+		err := rows.Scan(&network.ID, &network.Name, &network.CIDR, &network.Description, &network.Status, &lastScannedAt, &network.DeviceCount, &network.Lockdown, &dhcpAllowlist, &dhcpServer, &ipv6Prefix, &network.AddressFamily, &network.ScanProfileID, &createdAtStr, &updatedAtStr)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning network: %w", err)
 		}
+		scanNetworkDHCP(&network, dhcpAllowlist, dhcpServer)
+		if ipv6Prefix.Valid && ipv6Prefix.String != "" {
+			network.IPv6Prefix = &ipv6Prefix.String
+		}
 
 		if lastScannedAt.Valid {
 			network.LastScannedAt = &lastScannedAt.Time
@@ -185,15 +206,19 @@ func (r *SQLiteNetworkRepository) CreateOrUpdate(ctx context.Context, network *m
 		return nil, err
 	}
 
+	if network.AddressFamily == "" {
+		network.AddressFamily = models.AddressFamilyIPv4
+	}
+
 	if err == ErrNotFound {
-		query := `INSERT INTO networks (id, name, cidr, description, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
-		_, err := r.db.ExecContext(ctx, query, network.ID, network.Name, network.CIDR, network.Description, network.Status, network.CreatedAt, network.UpdatedAt)
+		query := `INSERT INTO networks (id, name, cidr, description, status, ipv6_prefix, address_family, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		_, err := r.db.ExecContext(ctx, query, network.ID, network.Name, network.CIDR, network.Description, network.Status, nullableString(network.IPv6Prefix), string(network.AddressFamily), network.CreatedAt, network.UpdatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("error inserting network: %w", err)
 		}
 	} else {
-		query := `UPDATE networks SET name = ?, cidr = ?, description = ?, status = ?, updated_at = ? WHERE id = ?`
-		_, err := r.db.ExecContext(ctx, query, network.Name, network.CIDR, network.Description, network.Status, network.UpdatedAt, network.ID)
+		query := `UPDATE networks SET name = ?, cidr = ?, description = ?, status = ?, ipv6_prefix = ?, address_family = ?, updated_at = ? WHERE id = ?`
+		_, err := r.db.ExecContext(ctx, query, network.Name, network.CIDR, network.Description, network.Status, nullableString(network.IPv6Prefix), string(network.AddressFamily), network.UpdatedAt, network.ID)
 		if err != nil {
 			return nil, fmt.Errorf("error updating network: %w", err)
 		}
@@ -202,6 +227,23 @@ func (r *SQLiteNetworkRepository) CreateOrUpdate(ctx context.Context, network *m
 	return network, nil
 }
 
+// scanNetworkDHCP decodes the DHCP allowlist and recorded server of a network
+func scanNetworkDHCP(network *models.Network, allowlist, server sql.NullString) {
+	if allowlist.Valid && allowlist.String != "" {
+		if err := json.Unmarshal([]byte(allowlist.String), &network.DHCPAllowlist); err != nil {
+			logger.Errorf("Error unmarshaling DHCP allowlist of network %s: %v", network.ID, err)
+		}
+	}
+	if server.Valid && server.String != "" {
+		var offer models.DHCPOffer
+		if err := json.Unmarshal([]byte(server.String), &offer); err == nil {
+			network.DHCPServer = &offer
+		} else {
+			logger.Errorf("Error unmarshaling DHCP server of network %s: %v", network.ID, err)
+		}
+	}
+}
+
 // Delete deletes a network by ID
 func (r *SQLiteNetworkRepository) Delete(ctx context.Context, id string) error {
 	query := `DELETE FROM networks WHERE id = ?`
@@ -253,7 +295,7 @@ func (r *SQLiteDeviceRepository) FindByID(ctx context.Context, id string) (*mode
 	SELECT id, name, comment, ipv4, ipv6_link_local, ipv6_unique_local, ipv6_global, ipv6_addresses,
 	       mac, vendor, device_type, os_name, os_version, os_family, os_confidence,
 	       status, network_id, hostname, created_at, updated_at, last_seen_online_at, 
-	       port_scan_started_at, port_scan_ended_at, web_scan_ended_at
+	       port_scan_started_at, port_scan_ended_at, web_scan_ended_at, upnp_info, snmp_info, switch_port, trust_state, identity, sensor_id
 	FROM devices WHERE id = ?`
 
 	row := tx.QueryRowContext(ctx, query, id)
@@ -265,7 +307,7 @@ func (r *SQLiteDeviceRepository) FindByID(ctx context.Context, id string) (*mode
 	device.IPv6Addresses = make([]string, 0)
 	var mac, vendor, hostname, comment sql.NullString
 	var ipv6LinkLocal, ipv6UniqueLocal, ipv6Global, ipv6Addresses sql.NullString
-	var deviceType sql.NullString
+	var deviceType, upnpInfo, snmpInfo, switchPort, trustState, identity, sensorID sql.NullString
 	var osName, osVersion, osFamily sql.NullString
 	var osConfidence sql.NullInt64
 	var networkID sql.NullString
@@ -277,7 +319,7 @@ func (r *SQLiteDeviceRepository) FindByID(ctx context.Context, id string) (*mode
 		&mac, &vendor, &deviceType,
 		&osName, &osVersion, &osFamily, &osConfidence,
 		&device.Status, &networkID, &hostname, &device.CreatedAt, &device.UpdatedAt,
-		&lastSeenOnlineAt, &portScanStartedAt, &portScanEndedAt, &webScanEndedAt,
+		&lastSeenOnlineAt, &portScanStartedAt, &portScanEndedAt, &webScanEndedAt, &upnpInfo, &snmpInfo, &switchPort, &trustState, &identity, &sensorID,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -321,9 +363,41 @@ func (r *SQLiteDeviceRepository) FindByID(ctx context.Context, id string) (*mode
 	if deviceType.Valid {
 		device.DeviceType = models.DeviceType(deviceType.String)
 	}
+	if upnpInfo.Valid && upnpInfo.String != "" {
+		var info models.UPnPInfo
+		if err := json.Unmarshal([]byte(upnpInfo.String), &info); err == nil {
+			device.UPnP = &info
+		}
+	}
+	if snmpInfo.Valid && snmpInfo.String != "" {
+		var info models.SNMPInfo
+		if err := json.Unmarshal([]byte(snmpInfo.String), &info); err == nil {
+			device.SNMP = &info
+		}
+	}
+	if switchPort.Valid && switchPort.String != "" {
+		var info models.SwitchPortInfo
+		if err := json.Unmarshal([]byte(switchPort.String), &info); err == nil {
+			device.SwitchPort = &info
+		}
+	}
+	if identity.Valid && identity.String != "" {
+		var info models.DeviceIdentity
+		if err := json.Unmarshal([]byte(identity.String), &info); err == nil {
+			device.Identity = &info
+		}
+	}
+	// Devices stored before the baseline existed have not been approved yet
+	device.TrustState = models.TrustStateNew
+	if trustState.Valid && trustState.String != "" {
+		device.TrustState = models.TrustState(trustState.String)
+	}
 	if hostname.Valid {
 		device.Hostname = &hostname.String
 	}
+	if sensorID.Valid {
+		device.SensorID = &sensorID.String
+	}
 	if lastSeenOnlineAt.Valid {
 		device.LastSeenOnlineAt = &lastSeenOnlineAt.Time
 	}
@@ -355,7 +429,7 @@ func (r *SQLiteDeviceRepository) FindByID(ctx context.Context, id string) (*mode
 	}
 
 	portsQuery := `
-	SELECT number, protocol, state, service
+	SELECT number, protocol, state, service, version
 	FROM ports WHERE device_id = ?`
 
 	portRows, err := tx.QueryContext(ctx, portsQuery, device.ID)
@@ -366,9 +440,11 @@ func (r *SQLiteDeviceRepository) FindByID(ctx context.Context, id string) (*mode
 
 	for portRows.Next() {
 		var port models.Port
-		if err := portRows.Scan(&port.Number, &port.Protocol, &port.State, &port.Service); err != nil {
+		var version sql.NullString
+		if err := portRows.Scan(&port.Number, &port.Protocol, &port.State, &port.Service, &version); err != nil {
 			return nil, fmt.Errorf("error scanning port: %w", err)
 		}
+		port.Version = version.String
 		device.Ports = append(device.Ports, port)
 	}
 	
@@ -423,9 +499,9 @@ func (r *SQLiteDeviceRepository) FindByID(ctx context.Context, id string) (*mode
 	return &device, nil
 }
 
-// FindByIP finds a device by IP address
+// FindByIP finds the device last seen at an IP address
 func (r *SQLiteDeviceRepository) FindByIP(ctx context.Context, ip string) (*models.Device, error) {
-	query := `SELECT id FROM devices WHERE ipv4 = ?`
+	query := `SELECT id FROM devices WHERE ipv4 = ? ORDER BY last_seen_online_at DESC LIMIT 1`
 	row := r.db.QueryRowContext(ctx, query, ip)
 
 	var id string
@@ -474,23 +550,50 @@ func (r *SQLiteDeviceRepository) CreateOrUpdate(ctx context.Context, device *mod
 	}
 	defer tx.Rollback()
 
+	if err := saveDevice(ctx, tx, device); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return device, nil
+}
+
+// saveDevice updates the stored device with the same ID, or else the one last seen at
+// the same IP address, and inserts the device when there is neither
+func saveDevice(ctx context.Context, tx *sql.Tx, device *models.Device) error {
+	var err error
 	now := time.Now()
 	device.UpdatedAt = now
 
 	// Convert strings to *string
 	networkIDPtr := stringToPtr(device.NetworkID)
 
-	// Check if a device with this IP address already exists
+	// A device matched by MAC or identity keeps its ID while moving to a new IP
 	var existingID string
-	err = tx.QueryRowContext(ctx, "SELECT id FROM devices WHERE ipv4 = ?", device.IPv4).Scan(&existingID)
-	if err != nil && err != sql.ErrNoRows {
-		return nil, fmt.Errorf("error checking if device with IP exists: %w", err)
+	deviceExists := false
+	if device.ID != "" {
+		err = tx.QueryRowContext(ctx, "SELECT id FROM devices WHERE id = ?", device.ID).Scan(&existingID)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("error checking if device exists: %w", err)
+		}
+		deviceExists = err != sql.ErrNoRows
 	}
 
-	deviceExists := err != sql.ErrNoRows
+	// Otherwise check if a device with this IP address already exists. Devices split
+	// apart by MAC can share an address, the one seen last is updated.
+	if !deviceExists {
+		err = tx.QueryRowContext(ctx, "SELECT id FROM devices WHERE ipv4 = ? ORDER BY last_seen_online_at DESC LIMIT 1", device.IPv4).Scan(&existingID)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("error checking if device with IP exists: %w", err)
+		}
+		deviceExists = err != sql.ErrNoRows
+	}
 
 	if deviceExists {
-		// Update existing device with the same IP address
+		// Update the existing device
 		device.ID = existingID
 		
 		// Get the existing created_at timestamp and preserve device type/OS if not provided
@@ -498,12 +601,15 @@ func (r *SQLiteDeviceRepository) CreateOrUpdate(ctx context.Context, device *mod
 		var existingDeviceType sql.NullString
 		var existingOsName, existingOsVersion, existingOsFamily sql.NullString
 		var existingOsConfidence sql.NullInt64
+		var existingUPnPInfo, existingSNMPInfo, existingSwitchPort, existingTrustState, existingIdentity, existingSensorID sql.NullString
+		var existingLinkLocal, existingUniqueLocal, existingGlobal, existingIPv6Addresses sql.NullString
 		
 		err = tx.QueryRowContext(ctx, 
-			"SELECT created_at, device_type, os_name, os_version, os_family, os_confidence FROM devices WHERE id = ?", 
-			device.ID).Scan(&createdAt, &existingDeviceType, &existingOsName, &existingOsVersion, &existingOsFamily, &existingOsConfidence)
+			"SELECT created_at, device_type, os_name, os_version, os_family, os_confidence, upnp_info, snmp_info, switch_port, trust_state, identity, ipv6_link_local, ipv6_unique_local, ipv6_global, ipv6_addresses, sensor_id FROM devices WHERE id = ?", 
+			device.ID).Scan(&createdAt, &existingDeviceType, &existingOsName, &existingOsVersion, &existingOsFamily, &existingOsConfidence, &existingUPnPInfo, &existingSNMPInfo, &existingSwitchPort, &existingTrustState, &existingIdentity,
+			&existingLinkLocal, &existingUniqueLocal, &existingGlobal, &existingIPv6Addresses, &existingSensorID)
 		if err != nil {
-			return nil, fmt.Errorf("error getting existing device data: %w", err)
+			return fmt.Errorf("error getting existing device data: %w", err)
 		}
 		device.CreatedAt = createdAt
 		
@@ -529,12 +635,66 @@ func (r *SQLiteDeviceRepository) CreateOrUpdate(ctx context.Context, device *mod
 			}
 		}
 
+		// Preserve existing UPnP description if not provided in update
+		if device.UPnP == nil && existingUPnPInfo.Valid && existingUPnPInfo.String != "" {
+			var info models.UPnPInfo
+			if err := json.Unmarshal([]byte(existingUPnPInfo.String), &info); err == nil {
+				device.UPnP = &info
+			}
+		}
+
+		// Preserve existing SNMP data if not provided in update
+		if device.SNMP == nil && existingSNMPInfo.Valid && existingSNMPInfo.String != "" {
+			var info models.SNMPInfo
+			if err := json.Unmarshal([]byte(existingSNMPInfo.String), &info); err == nil {
+				device.SNMP = &info
+			}
+		}
+
+		// Preserve existing switch port if not provided in update
+		if device.SwitchPort == nil && existingSwitchPort.Valid && existingSwitchPort.String != "" {
+			var info models.SwitchPortInfo
+			if err := json.Unmarshal([]byte(existingSwitchPort.String), &info); err == nil {
+				device.SwitchPort = &info
+			}
+		}
+
+		// Preserve existing identity signals if not provided in update
+		if device.Identity == nil && existingIdentity.Valid && existingIdentity.String != "" {
+			var info models.DeviceIdentity
+			if err := json.Unmarshal([]byte(existingIdentity.String), &info); err == nil {
+				device.Identity = &info
+			}
+		}
+
+		// Preserve existing IPv6 addresses if none are provided, sweeps only know the IPv4
+		// address and expired addresses are removed by ExpireIPv6Addresses
+		if !device.HasIPv6() {
+			device.IPv6LinkLocal = nullStringPtr(existingLinkLocal)
+			device.IPv6UniqueLocal = nullStringPtr(existingUniqueLocal)
+			device.IPv6Global = nullStringPtr(existingGlobal)
+			if existingIPv6Addresses.Valid && existingIPv6Addresses.String != "" {
+				json.Unmarshal([]byte(existingIPv6Addresses.String), &device.IPv6Addresses)
+			}
+		}
+
+		// Local scans do not know which agent reported the device
+		if device.SensorID == nil {
+			device.SensorID = nullStringPtr(existingSensorID)
+		}
+
+		// Trust states are changed through TrustRepository.SetTrustState only, so a stale
+		// copy of the device written back by a scan cannot undo an approval
+		if existingTrustState.Valid && existingTrustState.String != "" {
+			device.TrustState = models.TrustState(existingTrustState.String)
+		}
+
 		query := `
-		UPDATE devices SET name = ?, comment = ?, mac = ?, vendor = ?, device_type = ?, 
+		UPDATE devices SET ipv4 = ?, name = ?, comment = ?, mac = ?, vendor = ?, device_type = ?, 
 			os_name = ?, os_version = ?, os_family = ?, os_confidence = ?,
 			status = ?, network_id = ?, hostname = ?, updated_at = ?, last_seen_online_at = ?, 
 			port_scan_started_at = ?, port_scan_ended_at = ?, web_scan_ended_at = ?,
-			ipv6_link_local = ?, ipv6_unique_local = ?, ipv6_global = ?, ipv6_addresses = ?
+			ipv6_link_local = ?, ipv6_unique_local = ?, ipv6_global = ?, ipv6_addresses = ?, upnp_info = ?, snmp_info = ?, switch_port = ?, trust_state = ?, identity = ?, sensor_id = ?
 		WHERE id = ?`
 
 		// Prepare OS fields
@@ -564,23 +724,24 @@ func (r *SQLiteDeviceRepository) CreateOrUpdate(ctx context.Context, device *mod
 		}
 
 		_, err = tx.ExecContext(ctx, query,
-			device.Name, nullableString(device.Comment), nullableString(device.MAC), nullableString(device.Vendor), 
+			device.IPv4, device.Name, nullableString(device.Comment), nullableString(device.MAC), nullableString(device.Vendor), 
 			string(device.DeviceType), osName, osVersion, osFamily, osConfidence,
 			device.Status, networkIDPtr, nullableString(device.Hostname),
 			device.UpdatedAt, nullableTime(device.LastSeenOnlineAt),
 			nullableTime(device.PortScanStartedAt), nullableTime(device.PortScanEndedAt), nullableTime(device.WebScanEndedAt),
 			nullableString(device.IPv6LinkLocal), nullableString(device.IPv6UniqueLocal), nullableString(device.IPv6Global), ipv6AddressesJSON,
+			nullableJSON(device.UPnP), nullableJSON(device.SNMP), nullableJSON(device.SwitchPort), string(device.TrustState), nullableJSON(device.Identity), nullableString(device.SensorID),
 			device.ID,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("error updating device: %w", err)
+			return fmt.Errorf("error updating device: %w", err)
 		}
 
 		// Only delete existing ports if new ports are being provided
 		if len(device.Ports) > 0 {
 			_, err = tx.ExecContext(ctx, "DELETE FROM ports WHERE device_id = ?", device.ID)
 			if err != nil {
-				return nil, fmt.Errorf("error deleting device ports: %w", err)
+				return fmt.Errorf("error deleting device ports: %w", err)
 			}
 		}
 
@@ -588,7 +749,7 @@ func (r *SQLiteDeviceRepository) CreateOrUpdate(ctx context.Context, device *mod
 		if len(device.WebServices) > 0 {
 			_, err = tx.ExecContext(ctx, "DELETE FROM web_services WHERE device_id = ?", device.ID)
 			if err != nil {
-				return nil, fmt.Errorf("error deleting device web services: %w", err)
+				return fmt.Errorf("error deleting device web services: %w", err)
 			}
 		}
 	} else {
@@ -598,59 +759,76 @@ func (r *SQLiteDeviceRepository) CreateOrUpdate(ctx context.Context, device *mod
 		}
 		device.CreatedAt = now
 
-		query := `
-		INSERT INTO devices (id, name, comment, ipv4, mac, vendor, device_type, 
-			os_name, os_version, os_family, os_confidence,
-			status, network_id, hostname, created_at, updated_at, last_seen_online_at, 
-			port_scan_started_at, port_scan_ended_at, web_scan_ended_at,
-			ipv6_link_local, ipv6_unique_local, ipv6_global, ipv6_addresses)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-
-		// Prepare OS fields for insert
-		var osName, osVersion, osFamily sql.NullString
-		var osConfidence sql.NullInt64
-		if device.OS != nil {
-			if device.OS.Name != "" {
-				osName = sql.NullString{String: device.OS.Name, Valid: true}
-			}
-			if device.OS.Version != "" {
-				osVersion = sql.NullString{String: device.OS.Version, Valid: true}
-			}
-			if device.OS.Family != "" {
-				osFamily = sql.NullString{String: device.OS.Family, Valid: true}
-			}
-			if device.OS.Confidence > 0 {
-				osConfidence = sql.NullInt64{Int64: int64(device.OS.Confidence), Valid: true}
-			}
+		if err := insertDevice(ctx, tx, device); err != nil {
+			return err
 		}
+	}
 
-		// Prepare IPv6 JSON for insert
-		var ipv6AddressesJSON sql.NullString
-		if len(device.IPv6Addresses) > 0 {
-			if jsonBytes, err := json.Marshal(device.IPv6Addresses); err == nil {
-				ipv6AddressesJSON = sql.NullString{String: string(jsonBytes), Valid: true}
-			}
+	if err := registerIPv6Addresses(ctx, tx, device, now); err != nil {
+		return err
+	}
+	return insertDeviceChildren(ctx, tx, device)
+}
+
+// insertDevice inserts a new device row with the device's ID
+func insertDevice(ctx context.Context, tx *sql.Tx, device *models.Device) error {
+	query := `
+	INSERT INTO devices (id, name, comment, ipv4, mac, vendor, device_type, 
+		os_name, os_version, os_family, os_confidence,
+		status, network_id, hostname, created_at, updated_at, last_seen_online_at, 
+		port_scan_started_at, port_scan_ended_at, web_scan_ended_at,
+		ipv6_link_local, ipv6_unique_local, ipv6_global, ipv6_addresses, upnp_info, snmp_info, switch_port, trust_state, identity, sensor_id)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	// Prepare OS fields for insert
+	var osName, osVersion, osFamily sql.NullString
+	var osConfidence sql.NullInt64
+	if device.OS != nil {
+		if device.OS.Name != "" {
+			osName = sql.NullString{String: device.OS.Name, Valid: true}
+		}
+		if device.OS.Version != "" {
+			osVersion = sql.NullString{String: device.OS.Version, Valid: true}
 		}
+		if device.OS.Family != "" {
+			osFamily = sql.NullString{String: device.OS.Family, Valid: true}
+		}
+		if device.OS.Confidence > 0 {
+			osConfidence = sql.NullInt64{Int64: int64(device.OS.Confidence), Valid: true}
+		}
+	}
 
-		_, err = tx.ExecContext(ctx, query,
-			device.ID, device.Name, nullableString(device.Comment), device.IPv4, nullableString(device.MAC), nullableString(device.Vendor),
-			string(device.DeviceType), osName, osVersion, osFamily, osConfidence,
-			device.Status, networkIDPtr, nullableString(device.Hostname),
-			device.CreatedAt, device.UpdatedAt, nullableTime(device.LastSeenOnlineAt),
-			nullableTime(device.PortScanStartedAt), nullableTime(device.PortScanEndedAt), nullableTime(device.WebScanEndedAt),
-			nullableString(device.IPv6LinkLocal), nullableString(device.IPv6UniqueLocal), nullableString(device.IPv6Global), ipv6AddressesJSON,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("error inserting device: %w", err)
+	// Prepare IPv6 JSON for insert
+	var ipv6AddressesJSON sql.NullString
+	if len(device.IPv6Addresses) > 0 {
+		if jsonBytes, err := json.Marshal(device.IPv6Addresses); err == nil {
+			ipv6AddressesJSON = sql.NullString{String: string(jsonBytes), Valid: true}
 		}
 	}
 
+	_, err := tx.ExecContext(ctx, query,
+		device.ID, device.Name, nullableString(device.Comment), device.IPv4, nullableString(device.MAC), nullableString(device.Vendor),
+		string(device.DeviceType), osName, osVersion, osFamily, osConfidence,
+		device.Status, stringToPtr(device.NetworkID), nullableString(device.Hostname),
+		device.CreatedAt, device.UpdatedAt, nullableTime(device.LastSeenOnlineAt),
+		nullableTime(device.PortScanStartedAt), nullableTime(device.PortScanEndedAt), nullableTime(device.WebScanEndedAt),
+		nullableString(device.IPv6LinkLocal), nullableString(device.IPv6UniqueLocal), nullableString(device.IPv6Global), ipv6AddressesJSON,
+		nullableJSON(device.UPnP), nullableJSON(device.SNMP), nullableJSON(device.SwitchPort), string(device.TrustState), nullableJSON(device.Identity), nullableString(device.SensorID),
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting device: %w", err)
+	}
+	return nil
+}
+
+// insertDeviceChildren stores the ports and web services of a device
+func insertDeviceChildren(ctx context.Context, tx *sql.Tx, device *models.Device) error {
 	if len(device.Ports) > 0 {
-		portQuery := `INSERT INTO ports (device_id, number, protocol, state, service) VALUES (?, ?, ?, ?, ?)`
+		portQuery := `INSERT INTO ports (device_id, number, protocol, state, service, version) VALUES (?, ?, ?, ?, ?, ?)`
 		for _, port := range device.Ports {
-			_, err = tx.ExecContext(ctx, portQuery, device.ID, port.Number, port.Protocol, port.State, port.Service)
+			_, err := tx.ExecContext(ctx, portQuery, device.ID, port.Number, port.Protocol, port.State, port.Service, nullableString(&port.Version))
 			if err != nil {
-				return nil, fmt.Errorf("error inserting port: %w", err)
+				return fmt.Errorf("error inserting port: %w", err)
 			}
 		}
 	}
@@ -659,24 +837,20 @@ func (r *SQLiteDeviceRepository) CreateOrUpdate(ctx context.Context, device *mod
 	if len(device.WebServices) > 0 {
 		webServiceQuery := `INSERT INTO web_services (device_id, url, title, server, status_code, content_type, size, screenshot, port, protocol, scanned_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 		for _, ws := range device.WebServices {
-			_, err = tx.ExecContext(ctx, webServiceQuery, device.ID, ws.URL, nullableString(&ws.Title), nullableString(&ws.Server), ws.StatusCode, nullableString(&ws.ContentType), ws.Size, nullableString(&ws.Screenshot), ws.Port, ws.Protocol, ws.ScannedAt)
+			_, err := tx.ExecContext(ctx, webServiceQuery, device.ID, ws.URL, nullableString(&ws.Title), nullableString(&ws.Server), ws.StatusCode, nullableString(&ws.ContentType), ws.Size, nullableString(&ws.Screenshot), ws.Port, ws.Protocol, ws.ScannedAt)
 			if err != nil {
-				return nil, fmt.Errorf("error inserting web service: %w", err)
+				return fmt.Errorf("error inserting web service: %w", err)
 			}
 		}
 	}
 
-	if err = tx.Commit(); err != nil {
-		return nil, fmt.Errorf("error committing transaction: %w", err)
-	}
-
-	return device, nil
+	return nil
 }
 
 // UpdateDeviceStatuses updates device statuses based on last seen time
-func (r *SQLiteDeviceRepository) UpdateDeviceStatuses(ctx context.Context, timeout time.Duration) error {
+func (r *SQLiteDeviceRepository) UpdateDeviceStatuses(ctx context.Context, idleAfter, offlineAfter time.Duration) error {
 	now := time.Now()
-	offlineThreshold := now.Add(-timeout)
+	offlineThreshold := now.Add(-offlineAfter)
 
 	query := `
 	UPDATE devices 
@@ -692,8 +866,8 @@ func (r *SQLiteDeviceRepository) UpdateDeviceStatuses(ctx context.Context, timeo
 		return fmt.Errorf("error updating device statuses: %w", err)
 	}
 
-	// Set devices to idle after 1 minute of inactivity
-	idleThreshold := now.Add(-1 * time.Minute)
+	// Set devices to idle after a shorter period of inactivity
+	idleThreshold := now.Add(-idleAfter)
 	query = `
 	UPDATE devices 
 	SET status = ?, updated_at = ?
@@ -719,7 +893,43 @@ func (r *SQLiteDeviceRepository) DeleteByID(ctx context.Context, id string) erro
 	}
 	defer tx.Rollback()
 
-	_, err = tx.ExecContext(ctx, "DELETE FROM ports WHERE device_id = ?", id)
+	if err := deleteDevice(ctx, tx, id); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ReplacePorts stores the ports of a completed port scan in place of the stored
+// ones. Unlike saving the device, no ports clears the stored ports.
+func (r *SQLiteDeviceRepository) ReplacePorts(ctx context.Context, deviceID string, ports []models.Port) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM ports WHERE device_id = ?", deviceID); err != nil {
+		return fmt.Errorf("error deleting device ports: %w", err)
+	}
+	if err := insertDeviceChildren(ctx, tx, &models.Device{ID: deviceID, Ports: ports}); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// deleteDevice removes a device row together with its ports, web services and
+// inventory assignments. Event logs are kept.
+func deleteDevice(ctx context.Context, tx *sql.Tx, id string) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM ports WHERE device_id = ?", id)
 	if err != nil {
 		return fmt.Errorf("error deleting device ports: %w", err)
 	}
@@ -729,15 +939,17 @@ func (r *SQLiteDeviceRepository) DeleteByID(ctx context.Context, id string) erro
 		return fmt.Errorf("error deleting device web services: %w", err)
 	}
 
+	for _, table := range []string{"device_tags", "device_group_members", "device_custom_values"} {
+		_, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE device_id = ?", id)
+		if err != nil {
+			return fmt.Errorf("error deleting device %s: %w", table, err)
+		}
+	}
+
 	_, err = tx.ExecContext(ctx, "DELETE FROM devices WHERE id = ?", id)
 	if err != nil {
 		return fmt.Errorf("error deleting device: %w", err)
 	}
-
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("error committing transaction: %w", err)
-	}
-
 	return nil
 }
 
@@ -988,7 +1200,7 @@ func (r *SQLiteSystemStatusRepository) FindLatest(ctx context.Context) (*models.
 			status.Geolocation = &geo
 		} else if err != sql.ErrNoRows {
 			// Log the error but don't fail the whole query
-			log.Printf("Warning: failed to load geolocation for IP %s: %v", *status.PublicIP, err)
+			logger.Warnf("Failed to load geolocation for IP %s: %v", *status.PublicIP, err)
 		}
 	}
 
@@ -1014,6 +1226,20 @@ func nullableTime(t *time.Time) sql.NullTime {
 	return sql.NullTime{Time: *t, Valid: true}
 }
 
+// nullableJSON serializes optional structured device data for storage in a TEXT column
+func nullableJSON(value interface{}) sql.NullString {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		return sql.NullString{}
+	}
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		logger.Errorf("Error marshaling %T: %v", value, err)
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(jsonBytes), Valid: true}
+}
+
 // Converts a string to a pointer to string
 func stringToPtr(s string) *string {
 	if s == "" {
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reconya-ai/models"
+	"time"
+)
+
+// TrustRepository stores the authorized MAC baseline and per-network lockdown mode
+type TrustRepository struct {
+	db *sql.DB
+}
+
+func NewTrustRepository(db *sql.DB) *TrustRepository {
+	return &TrustRepository{db: db}
+}
+
+// ListBaseline returns all baseline entries ordered by MAC
+func (r *TrustRepository) ListBaseline(ctx context.Context) ([]*models.BaselineEntry, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, mac, network_id, label, created_at FROM mac_baseline ORDER BY mac, network_id`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying MAC baseline: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*models.BaselineEntry
+	for rows.Next() {
+		var entry models.BaselineEntry
+		var label sql.NullString
+		if err := rows.Scan(&entry.ID, &entry.MAC, &entry.NetworkID, &label, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning MAC baseline entry: %w", err)
+		}
+		entry.Label = label.String
+		entries = append(entries, &entry)
+	}
+	return entries, rows.Err()
+}
+
+// AddBaseline stores baseline entries and returns how many were new. Entries for a
+// MAC and network that are already in the baseline only get their label updated.
+func (r *TrustRepository) AddBaseline(ctx context.Context, entries []*models.BaselineEntry) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	added := 0
+	for _, entry := range entries {
+		if entry.ID == "" {
+			entry.ID = GenerateID()
+		}
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = time.Now()
+		}
+
+		result, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO mac_baseline (id, mac, network_id, label, created_at) VALUES (?, ?, ?, ?, ?)`,
+			entry.ID, entry.MAC, entry.NetworkID, nullableString(&entry.Label), entry.CreatedAt)
+		if err != nil {
+			return 0, fmt.Errorf("failed to add MAC baseline entry: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			added++
+			continue
+		}
+		if entry.Label != "" {
+			if _, err := tx.ExecContext(ctx, `UPDATE mac_baseline SET label = ? WHERE mac = ? AND network_id = ?`,
+				entry.Label, entry.MAC, entry.NetworkID); err != nil {
+				return 0, fmt.Errorf("failed to update MAC baseline entry: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+// DeleteBaseline removes a baseline entry
+func (r *TrustRepository) DeleteBaseline(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM mac_baseline WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete MAC baseline entry: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetLockdown turns lockdown mode on or off for a network
+func (r *TrustRepository) SetLockdown(ctx context.Context, networkID string, enabled bool) error {
+	result, err := r.db.ExecContext(ctx, `UPDATE networks SET lockdown = ?, updated_at = ? WHERE id = ?`, enabled, time.Now(), networkID)
+	if err != nil {
+		return fmt.Errorf("failed to update network lockdown: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetTrustState changes the trust state of a device
+func (r *TrustRepository) SetTrustState(ctx context.Context, deviceID string, state models.TrustState) error {
+	result, err := r.db.ExecContext(ctx, `UPDATE devices SET trust_state = ? WHERE id = ?`, string(state), deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to update device trust state: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
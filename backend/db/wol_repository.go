@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reconya-ai/models"
+	"time"
+)
+
+// WakeScheduleRepository stores scheduled Wake-on-LAN jobs. SecureOn passwords are
+// expected to be encrypted by the caller before they reach the repository.
+type WakeScheduleRepository struct {
+	db *sql.DB
+}
+
+func NewWakeScheduleRepository(db *sql.DB) *WakeScheduleRepository {
+	return &WakeScheduleRepository{db: db}
+}
+
+const wakeScheduleColumns = `id, device_ids, network_id, run_at, port, interface, broadcast_address,
+	secure_on_password, status, error, created_at, updated_at`
+
+// FindByID retrieves a single schedule
+func (r *WakeScheduleRepository) FindByID(ctx context.Context, id string) (*models.WakeSchedule, error) {
+	query := `SELECT ` + wakeScheduleColumns + ` FROM wake_schedules WHERE id = ?`
+	row := r.db.QueryRowContext(ctx, query, id)
+
+	schedule, err := scanWakeSchedule(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find wake schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// FindAll returns all schedules, the next to run first
+func (r *WakeScheduleRepository) FindAll(ctx context.Context) ([]*models.WakeSchedule, error) {
+	query := `SELECT ` + wakeScheduleColumns + ` FROM wake_schedules ORDER BY run_at DESC`
+	return r.query(ctx, query)
+}
+
+// FindDue returns the pending schedules whose time has come
+func (r *WakeScheduleRepository) FindDue(ctx context.Context, now time.Time) ([]*models.WakeSchedule, error) {
+	query := `SELECT ` + wakeScheduleColumns + ` FROM wake_schedules WHERE status = ? AND run_at <= ? ORDER BY run_at ASC`
+	return r.query(ctx, query, string(models.WakeSchedulePending), now)
+}
+
+func (r *WakeScheduleRepository) query(ctx context.Context, query string, args ...interface{}) ([]*models.WakeSchedule, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying wake schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []*models.WakeSchedule
+	for rows.Next() {
+		schedule, err := scanWakeSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning wake schedule: %w", err)
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	return schedules, rows.Err()
+}
+
+// Upsert creates or updates a schedule
+func (r *WakeScheduleRepository) Upsert(ctx context.Context, schedule *models.WakeSchedule) error {
+	if schedule.ID == "" {
+		schedule.ID = GenerateID()
+	}
+
+	now := time.Now()
+	if schedule.CreatedAt.IsZero() {
+		schedule.CreatedAt = now
+	}
+	schedule.UpdatedAt = now
+
+	query := `
+		INSERT INTO wake_schedules (` + wakeScheduleColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			device_ids = excluded.device_ids,
+			network_id = excluded.network_id,
+			run_at = excluded.run_at,
+			port = excluded.port,
+			interface = excluded.interface,
+			broadcast_address = excluded.broadcast_address,
+			secure_on_password = excluded.secure_on_password,
+			status = excluded.status,
+			error = excluded.error,
+			updated_at = excluded.updated_at
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		schedule.ID, nullableJSON(schedule.DeviceIDs), nullableString(&schedule.NetworkID), schedule.RunAt,
+		schedule.Options.Port, nullableString(&schedule.Options.Interface), nullableString(&schedule.Options.BroadcastAddress),
+		nullableString(&schedule.Options.SecureOnPassword), string(schedule.Status), nullableString(&schedule.Error),
+		schedule.CreatedAt, schedule.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to upsert wake schedule: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a schedule
+func (r *WakeScheduleRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM wake_schedules WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete wake schedule: %w", err)
+	}
+	return nil
+}
+
+func scanWakeSchedule(row rowScanner) (*models.WakeSchedule, error) {
+	var schedule models.WakeSchedule
+	var status string
+	var deviceIDs, networkID, iface, broadcast, password, scheduleError sql.NullString
+
+	err := row.Scan(&schedule.ID, &deviceIDs, &networkID, &schedule.RunAt, &schedule.Options.Port, &iface, &broadcast,
+		&password, &status, &scheduleError, &schedule.CreatedAt, &schedule.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if deviceIDs.Valid && deviceIDs.String != "" {
+		if err := json.Unmarshal([]byte(deviceIDs.String), &schedule.DeviceIDs); err != nil {
+			return nil, fmt.Errorf("invalid device_ids: %w", err)
+		}
+	}
+	schedule.NetworkID = networkID.String
+	schedule.Options.Interface = iface.String
+	schedule.Options.BroadcastAddress = broadcast.String
+	schedule.Options.SecureOnPassword = password.String
+	schedule.Status = models.WakeScheduleStatus(status)
+	schedule.Error = scheduleError.String
+
+	return &schedule, nil
+}
@@ -0,0 +1,320 @@
+package adhoc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/network"
+	"reconya-ai/internal/oneshot"
+	"reconya-ai/internal/scanprofile"
+	"reconya-ai/internal/util"
+	"reconya-ai/models"
+)
+
+var logger = logging.For("adhoc")
+
+// AdhocScanService scans addresses outside the saved networks through the full
+// discovery, port, web and fingerprint pipeline. Results stay in a scratch area
+// until they are promoted into a network or discarded. Scans run one at a time.
+type AdhocScanService struct {
+	Repository     *db.AdhocScanRepository
+	Runner         *oneshot.Runner
+	NetworkService *network.NetworkService
+	DeviceService  *device.DeviceService
+	// ScanProfiles resolves the profile a scan asks for, nil offers the built-in ones
+	ScanProfiles *scanprofile.ScanProfileService
+	// Concurrency is how many hosts of a scan are enriched at once
+	Concurrency int
+
+	mutex   sync.Mutex
+	ctx     context.Context
+	cancels map[string]context.CancelFunc
+	slot    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func NewAdhocScanService(repository *db.AdhocScanRepository, runner *oneshot.Runner, networkService *network.NetworkService, deviceService *device.DeviceService, scanProfiles *scanprofile.ScanProfileService, concurrency int) *AdhocScanService {
+	return &AdhocScanService{
+		Repository:     repository,
+		Runner:         runner,
+		NetworkService: networkService,
+		DeviceService:  deviceService,
+		ScanProfiles:   scanProfiles,
+		Concurrency:    concurrency,
+		cancels:        make(map[string]context.CancelFunc),
+		slot:           make(chan struct{}, 1),
+	}
+}
+
+// Run accepts scans until ctx is cancelled. Scans a previous process left
+// unfinished are marked failed, their partial results are kept.
+func (s *AdhocScanService) Run(ctx context.Context) error {
+	failed, err := util.RetryOnLockWithResult(func() (int64, error) {
+		return s.Repository.FailActive(ctx, "interrupted by a restart")
+	})
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		logger.Infof("Marked %d interrupted ad-hoc scans as failed", failed)
+	}
+
+	s.mutex.Lock()
+	s.ctx = ctx
+	s.mutex.Unlock()
+
+	<-ctx.Done()
+	s.wg.Wait()
+	return nil
+}
+
+// Start queues a scan of the targets, see ParseTargets for what is accepted
+func (s *AdhocScanService) Start(input, profileName string) (*models.AdhocScan, error) {
+	targets, err := ParseTargets(input)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := s.ScanProfiles.Resolve(profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ctx == nil || s.ctx.Err() != nil {
+		return nil, fmt.Errorf("ad-hoc scans are not accepted right now")
+	}
+
+	scan, err := util.RetryOnLockWithResult(func() (*models.AdhocScan, error) {
+		return s.Repository.Create(context.Background(), &models.AdhocScan{
+			Targets: targets,
+			Profile: profile.Name,
+			Status:  models.AdhocScanQueued,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.cancels[scan.ID] = cancel
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.forget(scan.ID)
+		s.run(ctx, scan, profile)
+	}()
+	logger.Infof("Queued ad-hoc scan %s of %v with the %s profile", scan.ID, targets, profile.Name)
+	return scan, nil
+}
+
+func (s *AdhocScanService) run(ctx context.Context, scan *models.AdhocScan, profile *models.ScanProfile) {
+	select {
+	case s.slot <- struct{}{}:
+		defer func() { <-s.slot }()
+	case <-ctx.Done():
+		s.finish(scan, ctx.Err())
+		return
+	}
+
+	now := time.Now()
+	scan.Status = models.AdhocScanRunning
+	scan.StartedAt = &now
+	s.save(scan)
+
+	var runErr error
+	for i, target := range scan.Targets {
+		result, err := s.Runner.Run(ctx, oneshot.Options{Target: target, Profile: profile, Concurrency: s.Concurrency})
+		if result != nil {
+			scan.Devices = append(scan.Devices, result.Devices...)
+			scan.Errors = append(scan.Errors, result.Errors...)
+		}
+		if ctx.Err() != nil {
+			runErr = ctx.Err()
+			break
+		}
+		if err != nil {
+			// One unreachable target does not fail the others
+			scan.Errors = append(scan.Errors, fmt.Sprintf("%s: %v", target, err))
+		}
+		scan.Progress = (i + 1) * 100 / len(scan.Targets)
+		s.save(scan)
+	}
+	if runErr == nil && len(scan.Devices) == 0 && len(scan.Errors) > 0 {
+		runErr = errors.New("no target could be scanned")
+	}
+	s.finish(scan, runErr)
+}
+
+func (s *AdhocScanService) finish(scan *models.AdhocScan, err error) {
+	now := time.Now()
+	scan.FinishedAt = &now
+	switch {
+	case errors.Is(err, context.Canceled):
+		scan.Status = models.AdhocScanCancelled
+	case err != nil:
+		scan.Status = models.AdhocScanFailed
+		scan.Error = err.Error()
+	default:
+		scan.Status = models.AdhocScanCompleted
+		scan.Progress = 100
+	}
+	s.save(scan)
+	logger.Infof("Ad-hoc scan %s %s with %d devices", scan.ID, scan.Status, len(scan.Devices))
+}
+
+func (s *AdhocScanService) save(scan *models.AdhocScan) {
+	err := util.RetryOnLock(func() error {
+		return s.Repository.Update(context.Background(), scan)
+	})
+	if err != nil && err != db.ErrNotFound {
+		logger.Errorf("Failed to save ad-hoc scan %s: %v", scan.ID, err)
+	}
+}
+
+func (s *AdhocScanService) forget(id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if cancel, ok := s.cancels[id]; ok {
+		cancel()
+		delete(s.cancels, id)
+	}
+}
+
+// FindAll lists the scans, newest first
+func (s *AdhocScanService) FindAll() ([]*models.AdhocScan, error) {
+	return s.Repository.FindAll(context.Background())
+}
+
+// FindByID returns a scan with its devices, or nil when it does not exist
+func (s *AdhocScanService) FindByID(id string) (*models.AdhocScan, error) {
+	scan, err := s.Repository.FindByID(context.Background(), id)
+	if err == db.ErrNotFound {
+		return nil, nil
+	}
+	return scan, err
+}
+
+// Cancel stops a waiting or running scan, the hosts found so far are kept
+func (s *AdhocScanService) Cancel(id string) error {
+	s.mutex.Lock()
+	cancel, ok := s.cancels[id]
+	s.mutex.Unlock()
+	if !ok {
+		return fmt.Errorf("scan is not running")
+	}
+	cancel()
+	return nil
+}
+
+// Discard stops the scan if needed and deletes it with its devices
+func (s *AdhocScanService) Discard(id string) error {
+	s.forget(id)
+	err := util.RetryOnLock(func() error {
+		return s.Repository.Delete(context.Background(), id)
+	})
+	if err == db.ErrNotFound {
+		return fmt.Errorf("scan not found")
+	}
+	return err
+}
+
+// Promote moves devices of a finished scan into a network, given by ID or by a
+// CIDR that is created when unknown. Without ips every device is promoted.
+// Devices outside the network stay in the scratch area, the scan is marked
+// promoted once none are left.
+func (s *AdhocScanService) Promote(id, networkID, cidr string, ips []string) (*models.AdhocPromotion, error) {
+	scan, err := s.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if scan == nil {
+		return nil, fmt.Errorf("scan not found")
+	}
+	if scan.Status.Active() {
+		return nil, fmt.Errorf("scan is still running")
+	}
+	if len(scan.Devices) == 0 {
+		return nil, fmt.Errorf("scan has no devices to promote")
+	}
+
+	target, err := s.resolveNetwork(networkID, cidr)
+	if err != nil {
+		return nil, err
+	}
+	_, ipNet, err := net.ParseCIDR(target.CIDR)
+	if err != nil {
+		return nil, fmt.Errorf("network %s has an invalid CIDR: %w", target.CIDR, err)
+	}
+
+	selected := make(map[string]bool)
+	for _, ip := range ips {
+		selected[ip] = true
+	}
+
+	promotion := &models.AdhocPromotion{NetworkID: target.ID, Promoted: []string{}}
+	var remaining []models.Device
+	for _, d := range scan.Devices {
+		if len(selected) > 0 && !selected[d.IPv4] {
+			remaining = append(remaining, d)
+			continue
+		}
+		if ip := net.ParseIP(d.IPv4); ip == nil || !ipNet.Contains(ip) {
+			promotion.Skipped = append(promotion.Skipped, fmt.Sprintf("%s: outside %s", d.IPv4, target.CIDR))
+			remaining = append(remaining, d)
+			continue
+		}
+
+		d.ID = ""
+		d.NetworkID = target.ID
+		if _, err := util.RetryOnLockWithResult(func() (*models.Device, error) {
+			return s.DeviceService.CreateOrUpdate(&d)
+		}); err != nil {
+			promotion.Skipped = append(promotion.Skipped, fmt.Sprintf("%s: %v", d.IPv4, err))
+			remaining = append(remaining, d)
+			continue
+		}
+		promotion.Promoted = append(promotion.Promoted, d.IPv4)
+	}
+
+	scan.Devices = remaining
+	if len(remaining) == 0 {
+		scan.Status = models.AdhocScanPromoted
+		scan.NetworkID = target.ID
+	}
+	if err := util.RetryOnLock(func() error {
+		return s.Repository.Update(context.Background(), scan)
+	}); err != nil {
+		return promotion, err
+	}
+	logger.Infof("Promoted %d devices of ad-hoc scan %s into network %s", len(promotion.Promoted), id, target.CIDR)
+	return promotion, nil
+}
+
+func (s *AdhocScanService) resolveNetwork(networkID, cidr string) (*models.Network, error) {
+	if networkID != "" {
+		n, err := s.NetworkService.FindByID(networkID)
+		if err != nil {
+			return nil, err
+		}
+		if n == nil {
+			return nil, fmt.Errorf("network not found")
+		}
+		return n, nil
+	}
+	if cidr == "" {
+		return nil, fmt.Errorf("a network ID or CIDR is required")
+	}
+	normalized, err := oneshot.NormalizeTarget(cidr)
+	if err != nil {
+		return nil, err
+	}
+	return s.NetworkService.FindOrCreate(normalized)
+}
@@ -0,0 +1,71 @@
+package adhoc
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"reconya-ai/internal/oneshot"
+	"reconya-ai/internal/util"
+)
+
+// maxAddresses caps a single ad-hoc scan at a /16, larger ranges belong in a network
+const maxAddresses = 1 << 16
+
+// ParseTargets turns a list of addresses, CIDRs and ranges separated by commas,
+// spaces or new lines into the CIDRs to sweep. A range is either two addresses,
+// 10.0.0.5-10.0.0.20, or an address and a last octet, 10.0.0.5-20.
+func ParseTargets(input string) ([]string, error) {
+	fields := strings.FieldsFunc(input, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no targets given")
+	}
+
+	var targets []string
+	seen := make(map[string]bool)
+	total := 0
+	for _, field := range fields {
+		var cidrs []string
+		if strings.Contains(field, "-") {
+			var err error
+			if cidrs, err = parseRange(field); err != nil {
+				return nil, err
+			}
+		} else {
+			cidr, err := oneshot.NormalizeTarget(field)
+			if err != nil {
+				return nil, err
+			}
+			cidrs = []string{cidr}
+		}
+
+		for _, cidr := range cidrs {
+			if seen[cidr] {
+				continue
+			}
+			seen[cidr] = true
+			_, ipNet, _ := net.ParseCIDR(cidr)
+			ones, _ := ipNet.Mask.Size()
+			total += 1 << (32 - ones)
+			if total > maxAddresses {
+				return nil, fmt.Errorf("targets cover more than %d addresses, add a network instead", maxAddresses)
+			}
+			targets = append(targets, cidr)
+		}
+	}
+	return targets, nil
+}
+
+// parseRange covers an inclusive address range with the fewest CIDRs
+func parseRange(value string) ([]string, error) {
+	start, end, err := util.ParseIPv4Range(value)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(end)-uint64(start)+1 > maxAddresses {
+		return nil, fmt.Errorf("range %q covers more than %d addresses, add a network instead", value, maxAddresses)
+	}
+	return util.RangeToCIDRs(start, end), nil
+}
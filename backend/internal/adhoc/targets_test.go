@@ -0,0 +1,43 @@
+package adhoc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTargets(t *testing.T) {
+	targets, err := ParseTargets("10.8.0.5, 192.168.1.0/30\n10.8.0.5;172.16.0.7")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.8.0.5/32", "192.168.1.0/30", "172.16.0.7/32"}, targets)
+
+	targets, err = ParseTargets("192.168.50.10-20")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"192.168.50.10/31", "192.168.50.12/30", "192.168.50.16/30", "192.168.50.20/32"}, targets)
+
+	targets, err = ParseTargets("10.0.0.0-10.0.1.255")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/23"}, targets)
+
+	targets, err = ParseTargets("10.0.0.255-10.0.1.0")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.255/32", "10.0.1.0/32"}, targets)
+}
+
+func TestParseTargetsRejects(t *testing.T) {
+	for _, input := range []string{
+		"",
+		" , ",
+		"printer.lan",
+		"fe80::1",
+		"10.0.0.20-10",
+		"10.0.0.1-10.0.0.x",
+		"10.0.0.0/8",
+		"10.0.0.0-10.2.0.0",
+		"10.0.0.0/16, 10.1.0.1",
+	} {
+		_, err := ParseTargets(input)
+		assert.Error(t, err, input)
+	}
+}
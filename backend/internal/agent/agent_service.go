@@ -0,0 +1,344 @@
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/config"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/eventlog"
+	"reconya-ai/internal/exclusion"
+	"reconya-ai/internal/network"
+	"reconya-ai/models"
+)
+
+var (
+	ErrUnauthorized       = errors.New("agent authentication failed")
+	ErrEnrollmentDisabled = errors.New("agent enrollment is not configured on this server")
+	ErrNetworkNotAssigned = errors.New("network is not assigned to this agent")
+	ErrInvalidName        = errors.New("invalid agent name")
+)
+
+// AgentService runs on the central server. It enrolls remote sensor agents, hands
+// them the networks to scan and feeds their results into the device pipeline.
+type AgentService struct {
+	Repository      *db.AgentRepository
+	DeviceService   *device.DeviceService
+	NetworkService  *network.NetworkService
+	EventLogService *eventlog.EventLogService
+	Config          *config.Config
+	// Exclusions are handed to the agents and applied to their reports
+	Exclusions      *exclusion.ExclusionService
+	checkInInterval time.Duration
+	scanInterval    time.Duration
+}
+
+func NewAgentService(repository *db.AgentRepository, deviceService *device.DeviceService, networkService *network.NetworkService, eventLogService *eventlog.EventLogService, cfg *config.Config) *AgentService {
+	return &AgentService{
+		Repository:      repository,
+		DeviceService:   deviceService,
+		NetworkService:  networkService,
+		EventLogService: eventLogService,
+		Config:          cfg,
+		checkInInterval: 30 * time.Second,
+		scanInterval:    5 * time.Minute,
+	}
+}
+
+// Enroll registers an agent presenting the enrollment token and returns its API
+// key. An agent enrolling again under the same name gets a new key, so a sensor
+// that lost its state can rejoin.
+func (s *AgentService) Enroll(req EnrollRequest) (*EnrollResponse, error) {
+	if s.Config == nil || s.Config.AgentEnrollmentToken == "" {
+		return nil, ErrEnrollmentDisabled
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Token), []byte(s.Config.AgentEnrollmentToken)) != 1 {
+		return nil, ErrUnauthorized
+	}
+	name, err := validName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := generateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	agent, err := s.Repository.FindByName(ctx, name)
+	switch {
+	case err == db.ErrNotFound:
+		agent = &models.Agent{Name: name, Hostname: req.Hostname, Platform: req.Platform, EnrolledVia: "token"}
+		if err := s.Repository.Create(ctx, agent, hashKey(key)); err != nil {
+			return nil, err
+		}
+		s.logEvent(models.AgentEnrolled, fmt.Sprintf("Agent %s enrolled with the enrollment token", name))
+	case err != nil:
+		return nil, err
+	default:
+		if err := s.Repository.SetKeyHash(ctx, agent.ID, hashKey(key)); err != nil {
+			return nil, err
+		}
+		s.logEvent(models.AgentEnrolled, fmt.Sprintf("Agent %s enrolled again, its previous key was revoked", name))
+	}
+
+	return &EnrollResponse{AgentID: agent.ID, APIKey: key}, nil
+}
+
+// Authenticate identifies the agent behind a request. A verified client certificate
+// identifies the agent by its common name and enrolls it on first use, otherwise
+// the API key handed out at enrollment is required.
+func (s *AgentService) Authenticate(cert *x509.Certificate, apiKey string) (*models.Agent, error) {
+	ctx := context.Background()
+	if cert != nil {
+		return s.certificateAgent(ctx, cert)
+	}
+	if apiKey == "" {
+		return nil, ErrUnauthorized
+	}
+
+	agent, err := s.Repository.FindByKeyHash(ctx, hashKey(apiKey))
+	if err == db.ErrNotFound {
+		return nil, ErrUnauthorized
+	}
+	return agent, err
+}
+
+func (s *AgentService) certificateAgent(ctx context.Context, cert *x509.Certificate) (*models.Agent, error) {
+	name, err := validName(cert.Subject.CommonName)
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+
+	agent, err := s.Repository.FindByName(ctx, name)
+	if err != db.ErrNotFound {
+		return agent, err
+	}
+
+	agent = &models.Agent{Name: name, EnrolledVia: "certificate", NetworkIDs: []string{}}
+	if err := s.Repository.Create(ctx, agent, ""); err != nil {
+		return nil, err
+	}
+	s.logEvent(models.AgentEnrolled, fmt.Sprintf("Agent %s enrolled with a client certificate", name))
+	return agent, nil
+}
+
+// CheckIn records that an agent is alive and returns the networks it should scan
+// with the exclusion rules to apply
+func (s *AgentService) CheckIn(agent *models.Agent, req CheckInRequest, remoteAddr string) (*CheckInResponse, error) {
+	agent.Hostname = req.Hostname
+	agent.Platform = req.Platform
+	agent.LastError = req.LastError
+	agent.RemoteAddr = remoteAddr
+	if err := s.Repository.RecordCheckIn(context.Background(), agent, time.Now()); err != nil {
+		return nil, err
+	}
+
+	response := &CheckInResponse{
+		AgentID:                agent.ID,
+		Networks:               make([]AssignedNetwork, 0, len(agent.NetworkIDs)),
+		ScanIntervalSeconds:    int(s.scanInterval.Seconds()),
+		CheckInIntervalSeconds: int(s.checkInInterval.Seconds()),
+	}
+	for _, networkID := range agent.NetworkIDs {
+		n, err := s.NetworkService.FindByID(networkID)
+		if err != nil {
+			return nil, err
+		}
+		// Agents sweep IPv4 networks only
+		if n == nil || n.CIDR == "" || n.AddressFamily == models.AddressFamilyIPv6 {
+			continue
+		}
+		response.Networks = append(response.Networks, AssignedNetwork{ID: n.ID, CIDR: n.CIDR, Name: n.Name})
+	}
+	if s.Exclusions != nil {
+		rules, err := s.Exclusions.FindAll()
+		if err != nil {
+			return nil, err
+		}
+		response.Exclusions = rules
+	}
+	return response, nil
+}
+
+// IngestReport saves the devices an agent found on one of its networks through the
+// regular device pipeline, tagged with the agent. Only what a scan can observe is
+// taken from the report, names, comments and trust states stay with the server.
+// Hosts the exclusion rules keep out of discovery are rejected and ports from
+// hosts that may not be port scanned are dropped, should an outdated agent send them.
+func (s *AgentService) IngestReport(agent *models.Agent, report Report) (*ReportResponse, error) {
+	if !agent.HasNetwork(report.NetworkID) {
+		return nil, ErrNetworkNotAssigned
+	}
+	n, err := s.NetworkService.FindByID(report.NetworkID)
+	if err != nil {
+		return nil, err
+	}
+	if n == nil {
+		return nil, ErrNetworkNotAssigned
+	}
+	_, ipNet, err := net.ParseCIDR(n.CIDR)
+	if err != nil {
+		return nil, fmt.Errorf("network %s has no valid CIDR: %v", n.ID, err)
+	}
+
+	response := &ReportResponse{}
+	for _, reported := range report.Devices {
+		ip := net.ParseIP(reported.IPv4)
+		if ip == nil || ip.To4() == nil || !ipNet.Contains(ip) {
+			response.Rejected++
+			continue
+		}
+
+		var mac string
+		if reported.MAC != nil {
+			mac = *reported.MAC
+		}
+		if rule := s.Exclusions.Blocked(models.ExcludeDiscovery, ip.String(), mac); rule != nil {
+			logger.Infof("Agent %s: rejected %s: %v", agent.Name, ip, exclusion.BlockedError(rule, models.ExcludeDiscovery))
+			response.Rejected++
+			continue
+		}
+		if len(reported.Ports) > 0 && s.Exclusions.Blocked(models.ExcludePortScan, ip.String(), mac) != nil {
+			reported.Ports = nil
+		}
+
+		sensorID := agent.ID
+		d := &models.Device{
+			IPv4:              ip.String(),
+			MAC:               reported.MAC,
+			Vendor:            reported.Vendor,
+			Hostname:          reported.Hostname,
+			Ports:             reported.Ports,
+			Identity:          reported.Identity,
+			NetworkID:         n.ID,
+			SensorID:          &sensorID,
+			PortScanStartedAt: reported.PortScanStartedAt,
+			PortScanEndedAt:   reported.PortScanEndedAt,
+		}
+		if len(d.Ports) > 0 {
+			s.DeviceService.PerformDeviceFingerprinting(d)
+		}
+
+		saved, err := s.DeviceService.CreateOrUpdate(d)
+		if err != nil {
+			logger.Errorf("Agent %s: failed to save device %s: %v", agent.Name, d.IPv4, err)
+			response.Rejected++
+			continue
+		}
+		response.Accepted++
+
+		if s.EventLogService != nil {
+			deviceID := saved.ID
+			if err := s.EventLogService.CreateOne(&models.EventLog{Type: models.DeviceOnline, DeviceID: &deviceID}); err != nil {
+				logger.Errorf("Error creating device online event log: %v", err)
+			}
+		}
+	}
+
+	if err := s.Repository.RecordReport(context.Background(), agent.ID, time.Now()); err != nil {
+		return nil, err
+	}
+	logger.Infof("Agent %s reported %d devices on network %s (%d rejected)", agent.Name, response.Accepted, n.CIDR, response.Rejected)
+	return response, nil
+}
+
+// List returns every agent with its health
+func (s *AgentService) List() ([]*models.Agent, error) {
+	agents, err := s.Repository.FindAll(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, agent := range agents {
+		agent.Health = agent.HealthAt(now, s.checkInInterval)
+	}
+	return agents, nil
+}
+
+// AssignNetworks replaces the networks an agent scans
+func (s *AgentService) AssignNetworks(agentID string, networkIDs []string) (*models.Agent, error) {
+	ctx := context.Background()
+	if _, err := s.Repository.FindByID(ctx, agentID); err != nil {
+		return nil, err
+	}
+	for _, networkID := range networkIDs {
+		n, err := s.NetworkService.FindByID(networkID)
+		if err != nil {
+			return nil, err
+		}
+		if n == nil {
+			return nil, fmt.Errorf("network %s not found", networkID)
+		}
+	}
+
+	if err := s.Repository.SetNetworks(ctx, agentID, networkIDs); err != nil {
+		return nil, err
+	}
+	agent, err := s.Repository.FindByID(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	agent.Health = agent.HealthAt(time.Now(), s.checkInInterval)
+	return agent, nil
+}
+
+// Revoke removes an agent, its API key stops working immediately. Certificate
+// enrolled agents come back on their next check-in unless their certificate is
+// no longer trusted.
+func (s *AgentService) Revoke(agentID string) error {
+	ctx := context.Background()
+	agent, err := s.Repository.FindByID(ctx, agentID)
+	if err != nil {
+		return err
+	}
+	if err := s.Repository.Delete(ctx, agentID); err != nil {
+		return err
+	}
+	s.logEvent(models.AgentRevoked, fmt.Sprintf("Agent %s revoked", agent.Name))
+	return nil
+}
+
+func (s *AgentService) logEvent(eventType models.EEventLogType, description string) {
+	if s.EventLogService == nil {
+		return
+	}
+	if err := s.EventLogService.Log(eventType, description, ""); err != nil {
+		logger.Errorf("Failed to log %s event: %v", eventType, err)
+	}
+}
+
+func validName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: a name is required", ErrInvalidName)
+	}
+	if len(name) > 64 {
+		return "", fmt.Errorf("%w: at most 64 characters are allowed", ErrInvalidName)
+	}
+	return name, nil
+}
+
+func generateKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate agent key: %w", err)
+	}
+	return hex.EncodeToString(key), nil
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
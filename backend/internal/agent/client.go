@@ -0,0 +1,141 @@
+package agent
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"reconya-ai/internal/config"
+)
+
+// Client talks to the agent API of the central server. Requests are authenticated
+// with the API key from enrollment, the client certificate when one is configured,
+// or both.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	apiKey     string
+}
+
+func NewClient(cfg *config.AgentConfig) (*Client, error) {
+	serverURL, err := url.Parse(cfg.ServerURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server URL: %w", err)
+	}
+	if serverURL.Scheme != "https" || serverURL.Host == "" {
+		return nil, fmt.Errorf("server URL must be an https URL, got %q", cfg.ServerURL)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return &Client{
+		baseURL: strings.TrimSuffix(serverURL.String(), "/"),
+		httpClient: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+		},
+	}, nil
+}
+
+// SetAPIKey sets the key sent with every request
+func (c *Client) SetAPIKey(key string) {
+	c.apiKey = key
+}
+
+func (c *Client) Enroll(req EnrollRequest) (*EnrollResponse, error) {
+	var response EnrollResponse
+	if err := c.post(EnrollPath, req, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (c *Client) CheckIn(req CheckInRequest) (*CheckInResponse, error) {
+	var response CheckInResponse
+	if err := c.post(CheckInPath, req, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (c *Client) Report(report Report) (*ReportResponse, error) {
+	var response ReportResponse
+	if err := c.post(ReportPath, report, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// StatusError is returned when the server answers with an error status
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.StatusCode, e.Message)
+}
+
+func (c *Client) post(path string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &failure) == nil && failure.Error != "" {
+			message = failure.Error
+		}
+		return &StatusError{StatusCode: resp.StatusCode, Message: message}
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"reconya-ai/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewClientRequiresHTTPS(t *testing.T) {
+	_, err := NewClient(&config.AgentConfig{ServerURL: "http://reconya.example:3008"})
+	assert.Error(t, err)
+
+	_, err = NewClient(&config.AgentConfig{ServerURL: "https://reconya.example:3008"})
+	assert.NoError(t, err)
+}
+
+func TestClientSendsAPIKeyAndParsesErrors(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer secret-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "agent authentication failed"})
+			return
+		}
+		assert.Equal(t, CheckInPath, r.URL.Path)
+		json.NewEncoder(w).Encode(CheckInResponse{AgentID: "agent-1", Networks: []AssignedNetwork{{ID: "n1", CIDR: "10.0.0.0/24"}}})
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, caPEM, 0600))
+
+	client, err := NewClient(&config.AgentConfig{ServerURL: server.URL + "/", CAFile: caFile})
+	require.NoError(t, err)
+
+	_, err = client.CheckIn(CheckInRequest{})
+	var statusErr *StatusError
+	require.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusUnauthorized, statusErr.StatusCode)
+	assert.Equal(t, "agent authentication failed", statusErr.Message)
+
+	client.SetAPIKey("secret-key")
+	response, err := client.CheckIn(CheckInRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "agent-1", response.AgentID)
+	require.Len(t, response.Networks, 1)
+	assert.Equal(t, "10.0.0.0/24", response.Networks[0].CIDR)
+}
@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"time"
+
+	"reconya-ai/models"
+)
+
+// Paths of the agent API on the central server
+const (
+	EnrollPath  = "/api/agents/enroll"
+	CheckInPath = "/api/agents/checkin"
+	ReportPath  = "/api/agents/report"
+)
+
+// EnrollRequest exchanges the enrollment token for an API key. Agents using a
+// client certificate enroll with an empty token.
+type EnrollRequest struct {
+	Token    string `json:"token,omitempty"`
+	Name     string `json:"name"`
+	Hostname string `json:"hostname,omitempty"`
+	Platform string `json:"platform,omitempty"`
+}
+
+type EnrollResponse struct {
+	AgentID string `json:"agent_id"`
+	// APIKey is only returned once, certificate enrolled agents get none
+	APIKey string `json:"api_key,omitempty"`
+}
+
+// CheckInRequest is sent periodically so the server can track agent health
+type CheckInRequest struct {
+	Hostname string `json:"hostname,omitempty"`
+	Platform string `json:"platform,omitempty"`
+	// LastError is the most recent scan or delivery error, empty when healthy
+	LastError string `json:"last_error,omitempty"`
+}
+
+// AssignedNetwork is a network the server wants the agent to scan
+type AssignedNetwork struct {
+	ID   string `json:"id"`
+	CIDR string `json:"cidr"`
+	Name string `json:"name,omitempty"`
+}
+
+type CheckInResponse struct {
+	AgentID  string            `json:"agent_id"`
+	Networks []AssignedNetwork `json:"networks"`
+	// ScanIntervalSeconds is how long to wait between sweeps of a network
+	ScanIntervalSeconds int `json:"scan_interval_seconds"`
+	// CheckInIntervalSeconds is how long to wait between check-ins
+	CheckInIntervalSeconds int `json:"check_in_interval_seconds"`
+	// Exclusions are the exclusion rules the agent must apply to its scans
+	Exclusions []*models.ExclusionRule `json:"exclusions,omitempty"`
+}
+
+// Report carries the devices found in one sweep of an assigned network
+type Report struct {
+	NetworkID  string          `json:"network_id"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	Devices    []models.Device `json:"devices"`
+}
+
+type ReportResponse struct {
+	Accepted int `json:"accepted"`
+	Rejected int `json:"rejected"`
+}
@@ -0,0 +1,361 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"reconya-ai/internal/config"
+	"reconya-ai/internal/exclusion"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/pingsweep"
+	"reconya-ai/internal/portscan"
+	"reconya-ai/internal/scanner"
+	"reconya-ai/internal/util"
+	"reconya-ai/models"
+)
+
+var logger = logging.For("agent")
+
+// maxPendingReports bounds the reports kept while the server is unreachable
+const maxPendingReports = 20
+
+// sensorState is kept in AgentConfig.StatePath between restarts
+type sensorState struct {
+	AgentID string `json:"agent_id"`
+	APIKey  string `json:"api_key,omitempty"`
+}
+
+// Sensor is the agent side of remote scanning. It runs the ping sweep and port scan
+// services against the networks the server assigns and delivers the devices found.
+type Sensor struct {
+	Config           *config.AgentConfig
+	Client           *Client
+	PingSweepService *pingsweep.PingSweepService
+	PortScanService  *portscan.PortScanService
+	hostname         string
+	state            sensorState
+	networks         []AssignedNetwork
+	checkInInterval  time.Duration
+	scanInterval     time.Duration
+	// portScanInterval is how long to wait before port scanning the same host again
+	portScanInterval time.Duration
+	lastScan         map[string]time.Time
+	lastPortScan     map[string]time.Time
+	pending          []Report
+	lastError        string
+	mutex            sync.Mutex
+}
+
+func NewSensor(cfg *config.AgentConfig, client *Client, pingSweepService *pingsweep.PingSweepService, portScanService *portscan.PortScanService) *Sensor {
+	hostname, _ := os.Hostname()
+	return &Sensor{
+		Config:           cfg,
+		Client:           client,
+		PingSweepService: pingSweepService,
+		PortScanService:  portScanService,
+		hostname:         hostname,
+		checkInInterval:  30 * time.Second,
+		scanInterval:     5 * time.Minute,
+		portScanInterval: time.Hour,
+		lastScan:         make(map[string]time.Time),
+		lastPortScan:     make(map[string]time.Time),
+	}
+}
+
+// Run enrolls the sensor if needed, then checks in and scans the assigned networks
+// until done is closed
+func (s *Sensor) Run(done <-chan bool) {
+	if err := s.loadState(); err != nil {
+		logger.Warnf("Agent state could not be loaded, enrolling again: %v", err)
+	}
+
+	for {
+		err := s.enroll()
+		if err == nil {
+			break
+		}
+		logger.Warnf("Agent enrollment failed, retrying in a minute: %v", err)
+		select {
+		case <-done:
+			return
+		case <-time.After(time.Minute):
+		}
+	}
+	logger.Infof("Agent %s enrolled as %s with %s", s.Config.Name, s.state.AgentID, s.Config.ServerURL)
+
+	go s.scanLoop(done)
+
+	for {
+		if err := s.checkIn(); err != nil {
+			logger.Errorf("Agent check-in failed: %v", err)
+		}
+		s.flushPending()
+
+		select {
+		case <-done:
+			return
+		case <-time.After(s.currentCheckInInterval()):
+		}
+	}
+}
+
+// enroll exchanges the enrollment token for an API key, or registers the client
+// certificate, unless the sensor enrolled before
+func (s *Sensor) enroll() error {
+	if s.state.AgentID != "" && (s.state.APIKey != "" || s.Config.CertFile != "") {
+		s.Client.SetAPIKey(s.state.APIKey)
+		return nil
+	}
+	if s.Config.EnrollmentToken == "" && s.Config.CertFile == "" {
+		return errors.New("AGENT_ENROLLMENT_TOKEN or a client certificate is required to enroll")
+	}
+
+	response, err := s.Client.Enroll(EnrollRequest{
+		Token:    s.Config.EnrollmentToken,
+		Name:     s.Config.Name,
+		Hostname: s.hostname,
+		Platform: platform(),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.state = sensorState{AgentID: response.AgentID, APIKey: response.APIKey}
+	s.Client.SetAPIKey(response.APIKey)
+	return s.saveState()
+}
+
+func (s *Sensor) checkIn() error {
+	s.mutex.Lock()
+	lastError := s.lastError
+	s.mutex.Unlock()
+
+	response, err := s.Client.CheckIn(CheckInRequest{Hostname: s.hostname, Platform: platform(), LastError: lastError})
+	if err != nil {
+		// A revoked key needs a new enrollment
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusUnauthorized && s.state.APIKey != "" {
+			s.state = sensorState{}
+			if enrollErr := s.enroll(); enrollErr != nil {
+				return fmt.Errorf("%v, enrolling again failed: %v", err, enrollErr)
+			}
+			return nil
+		}
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.networks = response.Networks
+	// The rules come with the networks, so they apply from the first sweep
+	s.PingSweepService.Exclusions.SetRules(response.Exclusions)
+	if response.CheckInIntervalSeconds > 0 {
+		s.checkInInterval = time.Duration(response.CheckInIntervalSeconds) * time.Second
+	}
+	if response.ScanIntervalSeconds > 0 {
+		s.scanInterval = time.Duration(response.ScanIntervalSeconds) * time.Second
+	}
+	return nil
+}
+
+func (s *Sensor) currentCheckInInterval() time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.checkInInterval
+}
+
+func (s *Sensor) scanLoop(done <-chan bool) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		for _, n := range s.dueNetworks(time.Now()) {
+			s.scanNetwork(n)
+		}
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dueNetworks returns the assigned networks not swept within the scan interval
+func (s *Sensor) dueNetworks(now time.Time) []AssignedNetwork {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var due []AssignedNetwork
+	for _, n := range s.networks {
+		if last, ok := s.lastScan[n.ID]; ok && now.Sub(last) < s.scanInterval {
+			continue
+		}
+		s.lastScan[n.ID] = now
+		due = append(due, n)
+	}
+	return due
+}
+
+// scanNetwork sweeps a network, port scans the hosts not scanned recently and
+// delivers the report
+func (s *Sensor) scanNetwork(n AssignedNetwork) {
+	logger.Infof("Agent scanning network %s", n.CIDR)
+	startedAt := time.Now()
+
+	devices, err := s.PingSweepService.ExecuteSweepScanCommand(n.CIDR)
+	if err != nil {
+		s.setLastError(fmt.Sprintf("sweep of %s failed: %v", n.CIDR, err))
+		return
+	}
+
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, 3)
+	for i := range devices {
+		d := &devices[i]
+		// Randomized MACs are correlated by name on the server, same as local sweeps
+		if d.MAC != nil && util.IsRandomizedMAC(*d.MAC) {
+			if name := scanner.MDNSReverseLookup(d.IPv4, 500*time.Millisecond); name != "" {
+				d.Identity = &models.DeviceIdentity{MDNSName: name}
+			}
+		}
+		if !s.portScanDue(d.IPv4, startedAt) {
+			continue
+		}
+
+		wg.Add(1)
+		workers <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-workers }()
+			s.portScan(d)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{NetworkID: n.ID, StartedAt: startedAt, FinishedAt: time.Now(), Devices: devices}
+	s.deliver(report)
+}
+
+func (s *Sensor) portScanDue(ip string, now time.Time) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if last, ok := s.lastPortScan[ip]; ok && now.Sub(last) < s.portScanInterval {
+		return false
+	}
+	s.lastPortScan[ip] = now
+	return true
+}
+
+func (s *Sensor) portScan(d *models.Device) {
+	startedAt := time.Now()
+	var mac string
+	if d.MAC != nil {
+		mac = *d.MAC
+	}
+	ports, vendor, hostname, err := s.PortScanService.ExecutePortScanWith(context.Background(), d.IPv4, portscan.PortScanOptions{MAC: mac})
+	if errors.Is(err, exclusion.ErrExcluded) {
+		logger.Infof("Agent skipped the port scan of %s: %v", d.IPv4, err)
+		return
+	}
+	if err != nil {
+		logger.Errorf("Agent port scan of %s failed: %v", d.IPv4, err)
+		return
+	}
+
+	endedAt := time.Now()
+	d.Ports = ports
+	d.PortScanStartedAt = &startedAt
+	d.PortScanEndedAt = &endedAt
+	if vendor != "" && d.Vendor == nil {
+		d.Vendor = &vendor
+	}
+	if hostname != "" && d.Hostname == nil {
+		d.Hostname = &hostname
+	}
+}
+
+// deliver sends a report, keeping it for the next check-in when the server is not
+// reachable
+func (s *Sensor) deliver(report Report) {
+	response, err := s.Client.Report(report)
+	if err != nil {
+		s.setLastError(fmt.Sprintf("delivering report for network %s failed: %v", report.NetworkID, err))
+		s.mutex.Lock()
+		s.pending = append(s.pending, report)
+		if len(s.pending) > maxPendingReports {
+			s.pending = s.pending[len(s.pending)-maxPendingReports:]
+		}
+		s.mutex.Unlock()
+		return
+	}
+	s.setLastError("")
+	logger.Infof("Agent delivered %d devices for network %s (%d rejected)", response.Accepted, report.NetworkID, response.Rejected)
+}
+
+// flushPending retries reports that could not be delivered, oldest first
+func (s *Sensor) flushPending() {
+	s.mutex.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.mutex.Unlock()
+
+	for i, report := range pending {
+		if _, err := s.Client.Report(report); err != nil {
+			var statusErr *StatusError
+			if errors.As(err, &statusErr) && statusErr.StatusCode < http.StatusInternalServerError {
+				// The server refused the report, sending it again will not help
+				logger.Warnf("Agent dropped report for network %s: %v", report.NetworkID, err)
+				continue
+			}
+			s.mutex.Lock()
+			s.pending = append(pending[i:], s.pending...)
+			s.mutex.Unlock()
+			return
+		}
+	}
+}
+
+func (s *Sensor) setLastError(message string) {
+	if message != "" {
+		logger.Errorf("Agent error: %s", message)
+	}
+	s.mutex.Lock()
+	s.lastError = message
+	s.mutex.Unlock()
+}
+
+func (s *Sensor) loadState() error {
+	data, err := os.ReadFile(s.Config.StatePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &s.state)
+}
+
+func (s *Sensor) saveState() error {
+	if err := os.MkdirAll(filepath.Dir(s.Config.StatePath), 0700); err != nil {
+		return fmt.Errorf("failed to create agent state directory: %w", err)
+	}
+	data, err := json.Marshal(s.state)
+	if err != nil {
+		return err
+	}
+	// The API key authenticates the agent, keep it private
+	return os.WriteFile(s.Config.StatePath, data, 0600)
+}
+
+func platform() string {
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
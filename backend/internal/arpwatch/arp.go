@@ -0,0 +1,120 @@
+package arpwatch
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	etherTypeARP  = 0x0806
+	etherTypeVLAN = 0x8100
+)
+
+// parseARPFrame reads the sender binding from an Ethernet frame carrying an IPv4 ARP
+// packet. Probes sent from 0.0.0.0 carry no binding and are skipped. A packet whose
+// sender and target address are the same is a gratuitous ARP.
+func parseARPFrame(frame []byte, now time.Time) (Observation, bool) {
+	if len(frame) < 14 {
+		return Observation{}, false
+	}
+	offset := 12
+	etherType := binary.BigEndian.Uint16(frame[offset:])
+	if etherType == etherTypeVLAN && len(frame) >= 18 {
+		offset += 4
+		etherType = binary.BigEndian.Uint16(frame[offset:])
+	}
+	if etherType != etherTypeARP {
+		return Observation{}, false
+	}
+
+	packet := frame[offset+2:]
+	if len(packet) < 28 {
+		return Observation{}, false
+	}
+	hardwareType := binary.BigEndian.Uint16(packet[0:])
+	protocolType := binary.BigEndian.Uint16(packet[2:])
+	operation := binary.BigEndian.Uint16(packet[6:])
+	if hardwareType != 1 || protocolType != 0x0800 || packet[4] != 6 || packet[5] != 4 {
+		return Observation{}, false
+	}
+	if operation != 1 && operation != 2 {
+		return Observation{}, false
+	}
+
+	senderMAC := net.HardwareAddr(packet[8:14])
+	senderIP := net.IP(packet[14:18])
+	targetIP := net.IP(packet[24:28])
+	if senderIP.IsUnspecified() {
+		return Observation{}, false
+	}
+
+	return Observation{
+		IP:         senderIP.String(),
+		MAC:        strings.ToUpper(senderMAC.String()),
+		Gratuitous: senderIP.Equal(targetIP),
+		Time:       now,
+	}, true
+}
+
+// readARPTable returns the complete entries of the kernel ARP table. It returns
+// nothing on systems without /proc/net/arp.
+func readARPTable() []Observation {
+	file, err := os.Open("/proc/net/arp")
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	return parseARPTable(file, time.Now())
+}
+
+// parseARPTable parses /proc/net/arp, skipping the header and incomplete entries
+func parseARPTable(r io.Reader, now time.Time) []Observation {
+	var observations []Observation
+	scanner := bufio.NewScanner(r)
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[2] == "0x0" {
+			continue
+		}
+		if _, ok := normalizeMAC(fields[3]); !ok {
+			continue
+		}
+		observations = append(observations, Observation{
+			IP:   fields[0],
+			MAC:  strings.ToUpper(fields[3]),
+			Time: now,
+		})
+	}
+	return observations
+}
+
+// gatewayInterface finds the local interface on the gateway's subnet, so the listener
+// only watches the LAN the gateway is on
+func gatewayInterface(gateway string) *net.Interface {
+	ip := net.ParseIP(gateway)
+	if ip == nil {
+		return nil
+	}
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	for i := range interfaces {
+		addrs, err := interfaces[i].Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.Contains(ip) {
+				return &interfaces[i]
+			}
+		}
+	}
+	return nil
+}
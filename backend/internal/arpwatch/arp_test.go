@@ -0,0 +1,69 @@
+package arpwatch
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func arpFrame(operation byte, senderMAC, senderIP, targetIP []byte) []byte {
+	frame := []byte{
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, // destination
+		0x00, 0x11, 0x22, 0x33, 0x44, 0x55, // source
+		0x08, 0x06, // ARP
+		0x00, 0x01, 0x08, 0x00, 6, 4, 0x00, operation,
+	}
+	frame = append(frame, senderMAC...)
+	frame = append(frame, senderIP...)
+	frame = append(frame, 0, 0, 0, 0, 0, 0)
+	return append(frame, targetIP...)
+}
+
+func TestParseARPFrame(t *testing.T) {
+	now := time.Now()
+	mac := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+
+	obs, ok := parseARPFrame(arpFrame(2, mac, []byte{192, 168, 1, 1}, []byte{192, 168, 1, 50}), now)
+	require.True(t, ok)
+	assert.Equal(t, "192.168.1.1", obs.IP)
+	assert.Equal(t, "00:11:22:33:44:55", obs.MAC)
+	assert.False(t, obs.Gratuitous)
+	assert.Equal(t, now, obs.Time)
+
+	obs, ok = parseARPFrame(arpFrame(1, mac, []byte{192, 168, 1, 7}, []byte{192, 168, 1, 7}), now)
+	require.True(t, ok)
+	assert.True(t, obs.Gratuitous)
+
+	_, ok = parseARPFrame(arpFrame(1, mac, []byte{0, 0, 0, 0}, []byte{192, 168, 1, 7}), now)
+	assert.False(t, ok, "probes carry no binding")
+
+	vlan := arpFrame(2, mac, []byte{10, 0, 0, 1}, []byte{10, 0, 0, 2})
+	vlan = append(vlan[:12], append([]byte{0x81, 0x00, 0x00, 0x0a}, vlan[12:]...)...)
+	obs, ok = parseARPFrame(vlan, now)
+	require.True(t, ok)
+	assert.Equal(t, "10.0.0.1", obs.IP)
+
+	ipv4 := arpFrame(2, mac, []byte{10, 0, 0, 1}, []byte{10, 0, 0, 2})
+	ipv4[12], ipv4[13] = 0x08, 0x00
+	_, ok = parseARPFrame(ipv4, now)
+	assert.False(t, ok)
+
+	_, ok = parseARPFrame(arpFrame(2, mac, []byte{10, 0, 0, 1}, []byte{10, 0, 0, 2})[:30], now)
+	assert.False(t, ok)
+}
+
+func TestParseARPTable(t *testing.T) {
+	table := `IP address       HW type     Flags       HW address            Mask     Device
+192.168.1.1      0x1         0x2         00:00:5e:00:53:01     *        eth0
+192.168.1.20     0x1         0x0         00:00:00:00:00:00     *        eth0
+192.168.1.30     0x1         0x2         aa:bb:cc:dd:ee:ff     *        eth0
+`
+	observations := parseARPTable(strings.NewReader(table), time.Now())
+	require.Len(t, observations, 2)
+	assert.Equal(t, "192.168.1.1", observations[0].IP)
+	assert.Equal(t, "00:00:5E:00:53:01", observations[0].MAC)
+	assert.Equal(t, "AA:BB:CC:DD:EE:FF", observations[1].MAC)
+}
@@ -0,0 +1,139 @@
+package arpwatch
+
+import (
+	"strings"
+
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/eventlog"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/topology"
+	"reconya-ai/models"
+)
+
+var logger = logging.For("arpwatch")
+
+// ARPWatchService watches IP to MAC bindings for signs of ARP spoofing. Bindings come
+// from each sweep, the kernel ARP table and, when the sensor may open raw sockets,
+// ARP packets on the gateway's LAN. Findings are logged against every known device
+// involved.
+type ARPWatchService struct {
+	DeviceService   *device.DeviceService
+	EventLogService *eventlog.EventLogService
+	Detector        *Detector
+}
+
+func NewARPWatchService(deviceService *device.DeviceService, eventLogService *eventlog.EventLogService) *ARPWatchService {
+	return &ARPWatchService{
+		DeviceService:   deviceService,
+		EventLogService: eventLogService,
+		Detector:        NewDetector(DefaultConfig()),
+	}
+}
+
+// CheckSweep compares the bindings found by a sweep and the kernel ARP table with
+// those seen before
+func (s *ARPWatchService) CheckSweep(devices []models.Device) {
+	s.Detector.SetGateway(topology.DefaultGateway())
+
+	var observations []Observation
+	for _, d := range devices {
+		if d.MAC != nil && *d.MAC != "" {
+			observations = append(observations, Observation{IP: d.IPv4, MAC: *d.MAC})
+		}
+	}
+	observations = append(observations, readARPTable()...)
+	s.Observe(observations...)
+}
+
+// Listen watches ARP packets until done is closed. Without permission to open a raw
+// socket the service falls back to checking sweeps only.
+func (s *ARPWatchService) Listen(done <-chan bool) {
+	gateway := topology.DefaultGateway()
+	s.Detector.SetGateway(gateway)
+
+	iface := gatewayInterface(gateway)
+	if iface != nil {
+		logger.Infof("ARP watch listening on %s", iface.Name)
+	}
+	if err := listenARP(iface, done, func(obs Observation) { s.Observe(obs) }); err != nil {
+		logger.Warnf("ARP watch listener unavailable, checking sweeps only: %v", err)
+	}
+}
+
+// Observe feeds bindings to the detector and logs the findings they raise
+func (s *ARPWatchService) Observe(observations ...Observation) {
+	var findings []Finding
+	for _, obs := range observations {
+		findings = append(findings, s.Detector.Observe(obs)...)
+	}
+	for _, finding := range findings {
+		s.report(finding)
+	}
+}
+
+func (s *ARPWatchService) report(finding Finding) {
+	logger.Warn(finding.Description)
+
+	eventType := eventType(finding.Kind)
+	deviceIDs := s.involvedDevices(finding)
+	if len(deviceIDs) == 0 {
+		s.logEvent(eventType, finding.Description, "")
+		return
+	}
+	for _, deviceID := range deviceIDs {
+		s.logEvent(eventType, finding.Description, deviceID)
+	}
+}
+
+// involvedDevices returns the known devices holding one of the finding's addresses
+func (s *ARPWatchService) involvedDevices(finding Finding) []string {
+	devices, err := s.DeviceService.FindAll()
+	if err != nil {
+		logger.Errorf("Failed to load devices for ARP finding: %v", err)
+		return nil
+	}
+
+	var ids []string
+	seen := make(map[string]bool)
+	for _, d := range devices {
+		involved := false
+		for _, ip := range finding.IPs {
+			if d.IPv4 == ip {
+				involved = true
+			}
+		}
+		for _, mac := range finding.MACs {
+			if d.MAC != nil && strings.EqualFold(*d.MAC, mac) {
+				involved = true
+			}
+		}
+		if involved && !seen[d.ID] {
+			seen[d.ID] = true
+			ids = append(ids, d.ID)
+		}
+	}
+	return ids
+}
+
+func (s *ARPWatchService) logEvent(eventType models.EEventLogType, description, deviceID string) {
+	if s.EventLogService == nil {
+		return
+	}
+	if err := s.EventLogService.Log(eventType, description, deviceID); err != nil {
+		logger.Errorf("Failed to log %s event: %v", eventType, err)
+	}
+}
+
+func eventType(kind FindingKind) models.EEventLogType {
+	switch kind {
+	case IPConflict:
+		return models.IPConflictDetected
+	case MACClaimsManyIPs:
+		return models.MACClaimsManyIPs
+	case GatewayMACChanged:
+		return models.GatewayMACChanged
+	case GratuitousStorm:
+		return models.GratuitousARPStorm
+	}
+	return models.Alert
+}
@@ -0,0 +1,253 @@
+package arpwatch
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FindingKind names the suspicious ARP behaviour a finding describes
+type FindingKind string
+
+const (
+	IPConflict        FindingKind = "ip_conflict"
+	MACClaimsManyIPs  FindingKind = "mac_many_ips"
+	GatewayMACChanged FindingKind = "gateway_mac_changed"
+	GratuitousStorm   FindingKind = "gratuitous_arp_storm"
+)
+
+// Observation is one IP to MAC binding, taken from a sweep, the kernel ARP table or
+// an ARP packet seen on the wire
+type Observation struct {
+	IP         string
+	MAC        string
+	Gratuitous bool
+	Time       time.Time
+}
+
+// Finding describes suspicious ARP behaviour and the addresses involved
+type Finding struct {
+	Kind        FindingKind
+	IPs         []string
+	MACs        []string
+	Description string
+}
+
+// Config holds the windows and thresholds the detector works with
+type Config struct {
+	// Window is how long an IP to MAC binding is remembered
+	Window time.Duration
+	// MaxIPsPerMAC is how many addresses one MAC may claim within the window
+	MaxIPsPerMAC int
+	// StormThreshold gratuitous ARPs from one MAC within StormWindow are a storm
+	StormThreshold int
+	StormWindow    time.Duration
+	// ReportInterval is how long to wait before reporting the same finding again
+	ReportInterval time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Window:         10 * time.Minute,
+		MaxIPsPerMAC:   4,
+		StormThreshold: 10,
+		StormWindow:    10 * time.Second,
+		ReportInterval: time.Hour,
+	}
+}
+
+// Detector compares IP to MAC bindings over time. It flags an address answered by
+// more than one MAC, a MAC claiming many addresses, a change of the gateway's MAC
+// and bursts of gratuitous ARPs. Observations may arrive from several goroutines.
+type Detector struct {
+	config     Config
+	gatewayIP  string
+	gatewayMAC string
+	byIP       map[string]map[string]time.Time
+	byMAC      map[string]map[string]time.Time
+	gratuitous map[string][]time.Time
+	reported   map[string]time.Time
+	pruned     time.Time
+	mutex      sync.Mutex
+}
+
+func NewDetector(config Config) *Detector {
+	return &Detector{
+		config:     config,
+		byIP:       make(map[string]map[string]time.Time),
+		byMAC:      make(map[string]map[string]time.Time),
+		gratuitous: make(map[string][]time.Time),
+		reported:   make(map[string]time.Time),
+	}
+}
+
+// SetGateway sets the address of the default gateway whose MAC is watched. The
+// gateway MAC is learned again when the address changes.
+func (d *Detector) SetGateway(ip string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if ip != d.gatewayIP {
+		d.gatewayIP = ip
+		d.gatewayMAC = ""
+	}
+}
+
+// Observe records a binding and returns the findings it raises. A finding already
+// reported within the report interval is not returned again.
+func (d *Detector) Observe(obs Observation) []Finding {
+	mac, ok := normalizeMAC(obs.MAC)
+	ip := net.ParseIP(obs.IP)
+	if !ok || ip == nil || ip.To4() == nil || ip.IsUnspecified() {
+		return nil
+	}
+	addr := ip.To4().String()
+	now := obs.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.prune(now)
+
+	var findings []Finding
+
+	macs := d.record(d.byIP, addr, mac, now)
+	if len(macs) > 1 {
+		sorted := sortedKeys(macs)
+		findings = d.report(findings, now, "ip|"+addr+"|"+strings.Join(sorted, ","), Finding{
+			Kind:        IPConflict,
+			IPs:         []string{addr},
+			MACs:        sorted,
+			Description: fmt.Sprintf("Possible ARP spoofing: IP %s answered from %d MACs (%s) within %s", addr, len(sorted), strings.Join(sorted, ", "), d.config.Window),
+		})
+	}
+
+	ips := d.record(d.byMAC, mac, addr, now)
+	if len(ips) > d.config.MaxIPsPerMAC {
+		sorted := sortedKeys(ips)
+		findings = d.report(findings, now, "mac|"+mac, Finding{
+			Kind:        MACClaimsManyIPs,
+			IPs:         sorted,
+			MACs:        []string{mac},
+			Description: fmt.Sprintf("Possible ARP spoofing: MAC %s claimed %d IPs (%s) within %s", mac, len(sorted), strings.Join(sorted, ", "), d.config.Window),
+		})
+	}
+
+	if addr == d.gatewayIP {
+		if d.gatewayMAC != "" && d.gatewayMAC != mac {
+			previous := d.gatewayMAC
+			findings = d.report(findings, now, "gateway|"+previous+"|"+mac, Finding{
+				Kind:        GatewayMACChanged,
+				IPs:         []string{addr},
+				MACs:        []string{previous, mac},
+				Description: fmt.Sprintf("Possible man-in-the-middle: gateway %s changed MAC from %s to %s", addr, previous, mac),
+			})
+		}
+		d.gatewayMAC = mac
+	}
+
+	if obs.Gratuitous {
+		recent := d.gratuitous[mac][:0]
+		for _, t := range d.gratuitous[mac] {
+			if now.Sub(t) < d.config.StormWindow {
+				recent = append(recent, t)
+			}
+		}
+		recent = append(recent, now)
+		d.gratuitous[mac] = recent
+		if len(recent) >= d.config.StormThreshold {
+			findings = d.report(findings, now, "storm|"+mac, Finding{
+				Kind:        GratuitousStorm,
+				IPs:         []string{addr},
+				MACs:        []string{mac},
+				Description: fmt.Sprintf("Gratuitous ARP storm: MAC %s sent %d gratuitous ARPs for %s within %s", mac, len(recent), addr, d.config.StormWindow),
+			})
+		}
+	}
+
+	return findings
+}
+
+// record stores value under key and returns the values seen within the window
+func (d *Detector) record(index map[string]map[string]time.Time, key, value string, now time.Time) map[string]time.Time {
+	values := index[key]
+	if values == nil {
+		values = make(map[string]time.Time)
+		index[key] = values
+	}
+	for v, seen := range values {
+		if now.Sub(seen) > d.config.Window {
+			delete(values, v)
+		}
+	}
+	values[value] = now
+	return values
+}
+
+// prune drops bindings, gratuitous ARP times and reports that have aged out, so
+// addresses seen once do not stay in memory. It sweeps at most once per window.
+func (d *Detector) prune(now time.Time) {
+	if now.Sub(d.pruned) < d.config.Window {
+		return
+	}
+	d.pruned = now
+
+	for _, index := range []map[string]map[string]time.Time{d.byIP, d.byMAC} {
+		for key, values := range index {
+			for v, seen := range values {
+				if now.Sub(seen) > d.config.Window {
+					delete(values, v)
+				}
+			}
+			if len(values) == 0 {
+				delete(index, key)
+			}
+		}
+	}
+	for mac, times := range d.gratuitous {
+		if len(times) == 0 || now.Sub(times[len(times)-1]) >= d.config.StormWindow {
+			delete(d.gratuitous, mac)
+		}
+	}
+	for key, last := range d.reported {
+		if now.Sub(last) >= d.config.ReportInterval {
+			delete(d.reported, key)
+		}
+	}
+}
+
+func (d *Detector) report(findings []Finding, now time.Time, key string, finding Finding) []Finding {
+	if last, ok := d.reported[key]; ok && now.Sub(last) < d.config.ReportInterval {
+		return findings
+	}
+	d.reported[key] = now
+	return append(findings, finding)
+}
+
+// normalizeMAC returns the MAC in the upper case form used for devices, skipping
+// the all zero and broadcast addresses found in incomplete ARP entries
+func normalizeMAC(value string) (string, bool) {
+	hw, err := net.ParseMAC(strings.TrimSpace(value))
+	if err != nil || len(hw) != 6 {
+		return "", false
+	}
+	mac := strings.ToUpper(hw.String())
+	if mac == "00:00:00:00:00:00" || mac == "FF:FF:FF:FF:FF:FF" {
+		return "", false
+	}
+	return mac, true
+}
+
+func sortedKeys(values map[string]time.Time) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,112 @@
+package arpwatch
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetector_IPConflict(t *testing.T) {
+	detector := NewDetector(DefaultConfig())
+	start := time.Now()
+
+	assert.Empty(t, detector.Observe(Observation{IP: "192.168.1.10", MAC: "00:11:22:33:44:55", Time: start}))
+	assert.Empty(t, detector.Observe(Observation{IP: "192.168.1.10", MAC: "00:11:22:33:44:55", Time: start.Add(time.Minute)}))
+
+	findings := detector.Observe(Observation{IP: "192.168.1.10", MAC: "66:77:88:99:aa:bb", Time: start.Add(2 * time.Minute)})
+	require.Len(t, findings, 1)
+	assert.Equal(t, IPConflict, findings[0].Kind)
+	assert.Equal(t, []string{"192.168.1.10"}, findings[0].IPs)
+	assert.Equal(t, []string{"00:11:22:33:44:55", "66:77:88:99:AA:BB"}, findings[0].MACs)
+
+	// The same conflict is reported once per report interval
+	assert.Empty(t, detector.Observe(Observation{IP: "192.168.1.10", MAC: "00:11:22:33:44:55", Time: start.Add(3 * time.Minute)}))
+
+	// An address handed to a new device after the window is not a conflict
+	assert.Empty(t, detector.Observe(Observation{IP: "192.168.1.20", MAC: "00:11:22:33:44:56", Time: start}))
+	assert.Empty(t, detector.Observe(Observation{IP: "192.168.1.20", MAC: "00:11:22:33:44:57", Time: start.Add(time.Hour)}))
+}
+
+func TestDetector_MACClaimsManyIPs(t *testing.T) {
+	detector := NewDetector(DefaultConfig())
+	start := time.Now()
+
+	var findings []Finding
+	for i := 1; i <= 5; i++ {
+		findings = detector.Observe(Observation{IP: fmt.Sprintf("10.0.0.%d", i), MAC: "de:ad:be:ef:00:01", Time: start.Add(time.Duration(i) * time.Second)})
+	}
+	require.Len(t, findings, 1)
+	assert.Equal(t, MACClaimsManyIPs, findings[0].Kind)
+	assert.Equal(t, []string{"DE:AD:BE:EF:00:01"}, findings[0].MACs)
+	assert.Len(t, findings[0].IPs, 5)
+}
+
+func TestDetector_GatewayMACChanged(t *testing.T) {
+	detector := NewDetector(DefaultConfig())
+	detector.SetGateway("192.168.1.1")
+	start := time.Now()
+
+	assert.Empty(t, detector.Observe(Observation{IP: "192.168.1.1", MAC: "00:00:5e:00:53:01", Time: start}))
+
+	// Long after the old binding expired the change is still reported
+	findings := detector.Observe(Observation{IP: "192.168.1.1", MAC: "00:00:5e:00:53:02", Time: start.Add(time.Hour)})
+	require.Len(t, findings, 1)
+	assert.Equal(t, GatewayMACChanged, findings[0].Kind)
+	assert.Equal(t, []string{"00:00:5E:00:53:01", "00:00:5E:00:53:02"}, findings[0].MACs)
+
+	// A new gateway address is learned again
+	detector.SetGateway("192.168.1.254")
+	assert.Empty(t, detector.Observe(Observation{IP: "192.168.1.254", MAC: "00:00:5e:00:53:03", Time: start.Add(2 * time.Hour)}))
+}
+
+func TestDetector_GratuitousStorm(t *testing.T) {
+	config := DefaultConfig()
+	detector := NewDetector(config)
+	start := time.Now()
+
+	// A few announcements after a link comes up are normal
+	for i := 0; i < 3; i++ {
+		assert.Empty(t, detector.Observe(Observation{IP: "10.0.0.5", MAC: "00:11:22:33:44:55", Gratuitous: true, Time: start.Add(time.Duration(i) * time.Second)}))
+	}
+
+	var findings []Finding
+	for i := 0; i < config.StormThreshold; i++ {
+		findings = append(findings, detector.Observe(Observation{IP: "10.0.0.5", MAC: "00:11:22:33:44:55", Gratuitous: true, Time: start.Add(time.Minute + time.Duration(i)*100*time.Millisecond)})...)
+	}
+	require.Len(t, findings, 1)
+	assert.Equal(t, GratuitousStorm, findings[0].Kind)
+}
+
+func TestDetector_IgnoresInvalidBindings(t *testing.T) {
+	detector := NewDetector(DefaultConfig())
+	assert.Empty(t, detector.Observe(Observation{IP: "10.0.0.1", MAC: "00:00:00:00:00:00"}))
+	assert.Empty(t, detector.Observe(Observation{IP: "10.0.0.1", MAC: "ff:ff:ff:ff:ff:ff"}))
+	assert.Empty(t, detector.Observe(Observation{IP: "0.0.0.0", MAC: "00:11:22:33:44:55"}))
+	assert.Empty(t, detector.Observe(Observation{IP: "fe80::1", MAC: "00:11:22:33:44:55"}))
+	assert.Empty(t, detector.Observe(Observation{IP: "10.0.0.1", MAC: "not-a-mac"}))
+}
+
+func TestDetector_ForgetsStaleEntries(t *testing.T) {
+	config := DefaultConfig()
+	detector := NewDetector(config)
+	start := time.Now()
+
+	for i := 0; i < 50; i++ {
+		ip := fmt.Sprintf("10.0.1.%d", i)
+		detector.Observe(Observation{IP: ip, MAC: fmt.Sprintf("00:11:22:33:44:%02x", i), Gratuitous: true, Time: start})
+	}
+	detector.Observe(Observation{IP: "10.0.1.1", MAC: "66:77:88:99:aa:bb", Time: start})
+	assert.Len(t, detector.byIP, 50)
+	assert.Len(t, detector.gratuitous, 50)
+	assert.Len(t, detector.reported, 1)
+
+	later := start.Add(config.ReportInterval + config.Window)
+	detector.Observe(Observation{IP: "10.0.2.1", MAC: "00:11:22:33:55:01", Time: later})
+	assert.Len(t, detector.byIP, 1)
+	assert.Len(t, detector.byMAC, 1)
+	assert.Empty(t, detector.gratuitous)
+	assert.Empty(t, detector.reported)
+}
@@ -0,0 +1,56 @@
+package arpwatch
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// listenARP reads ARP packets from a raw socket until done is closed, on one
+// interface or on every interface when iface is nil. Opening the socket needs root
+// or CAP_NET_RAW.
+func listenARP(iface *net.Interface, done <-chan bool, handle func(Observation)) error {
+	protocol := htons(etherTypeARP)
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW, int(protocol))
+	if err != nil {
+		return fmt.Errorf("failed to open ARP socket: %v", err)
+	}
+	defer syscall.Close(fd)
+
+	if iface != nil {
+		if err := syscall.Bind(fd, &syscall.SockaddrLinklayer{Protocol: protocol, Ifindex: iface.Index}); err != nil {
+			return fmt.Errorf("failed to bind ARP socket to %s: %v", iface.Name, err)
+		}
+	}
+
+	// Wake up every second to notice shutdown
+	timeout := syscall.Timeval{Sec: 1}
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &timeout); err != nil {
+		return fmt.Errorf("failed to set ARP socket timeout: %v", err)
+	}
+
+	buffer := make([]byte, 1514)
+	for {
+		select {
+		case <-done:
+			return nil
+		default:
+		}
+
+		n, _, err := syscall.Recvfrom(fd, buffer, 0)
+		if err != nil {
+			if err == syscall.EAGAIN || err == syscall.EINTR {
+				continue
+			}
+			return fmt.Errorf("failed to read ARP packet: %v", err)
+		}
+		if obs, ok := parseARPFrame(buffer[:n], time.Now()); ok {
+			handle(obs)
+		}
+	}
+}
+
+func htons(value uint16) uint16 {
+	return value<<8 | value>>8
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package arpwatch
+
+import (
+	"fmt"
+	"net"
+)
+
+func listenARP(iface *net.Interface, done <-chan bool, handle func(Observation)) error {
+	return fmt.Errorf("listening for ARP packets is only supported on Linux")
+}
@@ -2,9 +2,12 @@ package config
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 
+	"reconya-ai/internal/logging"
+
 	"github.com/joho/godotenv"
 )
 
@@ -16,62 +19,206 @@ const (
 
 type Config struct {
 	JwtKey       []byte
+	// SecretKey encrypts credentials stored in the database (SNMP communities etc.)
+	SecretKey    []byte
 	Port         string
 	DatabaseType DatabaseType
 	// SQLite config
 	SQLitePath   string
+	// Wake-on-LAN defaults, overridable per request
+	WakeOnLANPort      int
+	WakeOnLANInterface string
+	// Remote sensor agents enroll with AgentEnrollmentToken, or with a client
+	// certificate signed by AgentClientCAFile when the server runs TLS
+	AgentEnrollmentToken string
+	TLSCertFile          string
+	TLSKeyFile           string
+	AgentClientCAFile    string
+	// MetricsToken, when set, is required as a bearer token to scrape /metrics
+	MetricsToken string
 	// Common configs
 	Username     string
 	Password     string
 	DatabaseName string
+	// ConfigFile is the YAML file the configuration was read from, if any
+	ConfigFile string
+	// Log is the logging configuration as loaded, runtime level changes are not reflected
+	Log LogConfig
+	// tuning is changed by Reload, use Tuning() to read it. It is a pointer so
+	// copies of a Config share it.
+	tuning *tuningState
 }
 
+// LoadConfig reads the configuration file named by RECONYA_CONFIG, or reconya.yaml
+// when present, and overrides it with environment variables. All invalid values are
+// reported together.
 func LoadConfig() (*Config, error) {
 	// Try to load .env file but don't fail if it doesn't exist
 	// This allows using environment variables directly in Docker
 	_ = godotenv.Load()
 
-	databaseName := os.Getenv("DATABASE_NAME")
-	if databaseName == "" {
-		return nil, fmt.Errorf("DATABASE_NAME environment variable is not set")
+	f, path, err := loadFile()
+	if err != nil {
+		return nil, err
+	}
+	if err := f.validate(); err != nil {
+		if path != "" {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return nil, err
 	}
 
-	username := os.Getenv("LOGIN_USERNAME")
-	password := os.Getenv("LOGIN_PASSWORD")
-	if username == "" || password == "" {
-		return nil, fmt.Errorf("LOGIN_USERNAME or LOGIN_PASSWORD environment variables are not set")
+	config := newConfig(f)
+	config.ConfigFile = path
+	return config, nil
+}
+
+func newConfig(f File) *Config {
+	// Credentials at rest are encrypted with SECRET_ENCRYPTION_KEY, falling back to the JWT secret
+	secretKey := f.Auth.EncryptionKey
+	if secretKey == "" {
+		secretKey = f.Auth.JWTSecret
 	}
 
-	jwtSecret := os.Getenv("JWT_SECRET_KEY")
-	if jwtSecret == "" {
-		return nil, fmt.Errorf("JWT_SECRET_KEY environment variable is not set")
+	// Default to a data directory in the current directory
+	sqlitePath := f.Database.SQLitePath
+	if sqlitePath == "" {
+		sqlitePath = filepath.Join("data", fmt.Sprintf("%s.db", f.Database.Name))
 	}
 
-	// Get port (default to 3008)
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "3008"
+	config := &Config{
+		JwtKey:               []byte(f.Auth.JWTSecret),
+		SecretKey:            []byte(secretKey),
+		Port:                 f.Server.Port,
+		DatabaseType:         SQLite,
+		SQLitePath:           sqlitePath,
+		WakeOnLANPort:        f.WakeOnLAN.Port,
+		WakeOnLANInterface:   f.WakeOnLAN.Interface,
+		AgentEnrollmentToken: f.Agents.EnrollmentToken,
+		TLSCertFile:          f.Server.TLSCertFile,
+		TLSKeyFile:           f.Server.TLSKeyFile,
+		AgentClientCAFile:    f.Agents.ClientCAFile,
+		MetricsToken:         f.Metrics.Token,
+		Username:             f.Auth.Username,
+		Password:             f.Auth.Password,
+		DatabaseName:         f.Database.Name,
+		Log:                  f.logConfig(),
+		tuning:               &tuningState{effective: f},
 	}
+	config.SetTuning(f.tuning())
+	return config
+}
+
+// AgentConfig configures `reconya agent`, a sensor that scans the networks assigned
+// to it by a central server and reports the results there
+type AgentConfig struct {
+	// ServerURL is the https URL of the central reconya server
+	ServerURL string
+	// Name identifies the agent on the server, it defaults to the hostname
+	Name string
+	// EnrollmentToken is exchanged for an API key on first start
+	EnrollmentToken string
+	// CAFile verifies the server certificate instead of the system roots
+	CAFile string
+	// CertFile and KeyFile are a client certificate for mutual TLS
+	CertFile string
+	KeyFile  string
+	// StatePath keeps the agent ID and API key between restarts
+	StatePath string
+}
 
-	// Set database type to SQLite
-	dbType := string(SQLite)
+func LoadAgentConfig() (*AgentConfig, error) {
+	_ = godotenv.Load()
 
-	config := &Config{
-		JwtKey:       []byte(jwtSecret),
-		Port:         port,
-		DatabaseType: DatabaseType(dbType),
-		Username:     username,
-		Password:     password,
-		DatabaseName: databaseName,
+	serverURL := os.Getenv("RECONYA_SERVER_URL")
+	if serverURL == "" {
+		return nil, fmt.Errorf("RECONYA_SERVER_URL environment variable is not set")
 	}
 
-	// Configure SQLite database
-	sqlitePath := os.Getenv("SQLITE_PATH")
-	if sqlitePath == "" {
-		// Default to a data directory in the current directory
-		sqlitePath = filepath.Join("data", fmt.Sprintf("%s.db", databaseName))
+	name := os.Getenv("AGENT_NAME")
+	if name == "" {
+		hostname, err := os.Hostname()
+		if err != nil || hostname == "" {
+			return nil, fmt.Errorf("AGENT_NAME environment variable is not set")
+		}
+		name = hostname
 	}
-	config.SQLitePath = sqlitePath
 
+	config := &AgentConfig{
+		ServerURL:       serverURL,
+		Name:            name,
+		EnrollmentToken: os.Getenv("AGENT_ENROLLMENT_TOKEN"),
+		CAFile:          os.Getenv("AGENT_CA_FILE"),
+		CertFile:        os.Getenv("AGENT_CERT_FILE"),
+		KeyFile:         os.Getenv("AGENT_KEY_FILE"),
+		StatePath:       os.Getenv("AGENT_STATE_PATH"),
+	}
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		return nil, fmt.Errorf("AGENT_CERT_FILE and AGENT_KEY_FILE must be set together")
+	}
+	if config.StatePath == "" {
+		config.StatePath = filepath.Join("data", "agent.json")
+	}
+
+	return config, nil
+}
+
+// LoadTuning reads only the scanner settings from the file and environment, for
+// `reconya scan` which needs neither credentials nor a database. The returned
+// Config holds nothing else.
+func LoadTuning() (*Config, error) {
+	_ = godotenv.Load()
+
+	f, path, err := loadFile()
+	if err != nil {
+		return nil, err
+	}
+	if problems := f.tuningProblems(); len(problems) > 0 {
+		return nil, validationError(problems)
+	}
+	config := &Config{ConfigFile: path}
+	config.SetTuning(f.tuning())
 	return config, nil
 }
+
+// LogConfig configures logging for the server and agents alike
+type LogConfig struct {
+	// Format is text or json
+	Format string
+	// Level is the default level, Levels overrides it per component
+	Level  slog.Level
+	Levels map[string]slog.Level
+	// BufferSize is how many recent entries the log viewer keeps
+	BufferSize int
+}
+
+// LoadLogConfig reads only the logging section, so logging is set up before the
+// rest of the configuration is validated
+func LoadLogConfig() (*LogConfig, error) {
+	_ = godotenv.Load()
+
+	f, _, err := loadFile()
+	if err != nil {
+		return nil, err
+	}
+	if problems := f.loggingProblems(); len(problems) > 0 {
+		return nil, validationError(problems)
+	}
+	config := f.logConfig()
+	return &config, nil
+}
+
+// logConfig converts the logging section, which must have been validated
+func (f *File) logConfig() LogConfig {
+	level, _ := logging.ParseLevel(f.Logging.Level)
+	levels := make(map[string]slog.Level, len(f.Logging.Levels))
+	for component, name := range f.Logging.Levels {
+		levels[component], _ = logging.ParseLevel(name)
+	}
+	return LogConfig{
+		Format:     f.Logging.Format,
+		Level:      level,
+		Levels:     levels,
+		BufferSize: f.Logging.BufferSize,
+	}
+}
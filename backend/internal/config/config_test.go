@@ -0,0 +1,190 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// envVars are cleared for every test so the environment of the machine running
+// the tests does not leak in, an empty variable counts as unset
+var envVars = []string{
+	"PORT", "TLS_CERT_FILE", "TLS_KEY_FILE", "DATABASE_NAME", "SQLITE_PATH",
+	"LOGIN_USERNAME", "LOGIN_PASSWORD", "JWT_SECRET_KEY", "SECRET_ENCRYPTION_KEY",
+	"AGENT_ENROLLMENT_TOKEN", "AGENT_CLIENT_CA_FILE", "METRICS_TOKEN", "WOL_PORT",
+	"WOL_INTERFACE", "LOG_FORMAT", "LOG_LEVEL", "LOG_LEVELS", "LOG_BUFFER_SIZE",
+	"SWEEP_INTERVAL", "SWEEP_TIMEOUT", "SWEEP_RETRY_TIMEOUT", "DEVICE_IDLE_AFTER",
+	"DEVICE_OFFLINE_AFTER", "PORT_SCAN_WORKERS", "PORT_SCAN_TIMEOUT", "PORT_SCAN_PORTS",
+	"SCREENSHOT_TOOLS",
+}
+
+const minimalFile = `
+database:
+  name: test
+auth:
+  username: admin
+  password: secret
+  jwt_secret: jwt-secret
+`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	for _, name := range envVars {
+		t.Setenv(name, "")
+	}
+	path := filepath.Join(t.TempDir(), "reconya.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	t.Setenv("RECONYA_CONFIG", path)
+	return path
+}
+
+func TestLoadConfigAppliesDefaults(t *testing.T) {
+	path := writeConfig(t, minimalFile)
+
+	cfg, err := LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, path, cfg.ConfigFile)
+	assert.Equal(t, "3008", cfg.Port)
+	assert.Equal(t, filepath.Join("data", "test.db"), cfg.SQLitePath)
+	assert.Equal(t, []byte("jwt-secret"), cfg.SecretKey)
+	assert.Equal(t, DefaultTuning(), cfg.Tuning())
+}
+
+func TestEnvironmentOverridesTheFile(t *testing.T) {
+	writeConfig(t, minimalFile+`
+server:
+  port: "4000"
+scanning:
+  sweep_interval: 1m
+`)
+	t.Setenv("PORT", "5000")
+	t.Setenv("SWEEP_INTERVAL", "45s")
+	t.Setenv("SCREENSHOT_TOOLS", "firefox, chromedp")
+
+	cfg, err := LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "5000", cfg.Port)
+	assert.Equal(t, 45*time.Second, cfg.Tuning().SweepInterval)
+	assert.Equal(t, []string{"firefox", "chromedp"}, cfg.Tuning().ScreenshotTools)
+}
+
+func TestInvalidValuesAreReportedTogether(t *testing.T) {
+	writeConfig(t, minimalFile+`
+server:
+  port: "99999"
+scanning:
+  idle_after: 5m
+  offline_after: 1m
+  port_scan_ports: "80,90-70"
+screenshots:
+  tools: [chromedp, paint]
+`)
+
+	_, err := LoadConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "server.port (PORT)")
+	assert.Contains(t, err.Error(), "scanning.offline_after (DEVICE_OFFLINE_AFTER)")
+	assert.Contains(t, err.Error(), `invalid port or range "90-70"`)
+	assert.Contains(t, err.Error(), `unknown tool "paint"`)
+}
+
+func TestUnknownKeysAndBadDurationsAreRejected(t *testing.T) {
+	writeConfig(t, minimalFile+`
+scanning:
+  sweep_intervall: 30s
+`)
+	_, err := LoadConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sweep_intervall")
+
+	writeConfig(t, minimalFile+`
+scanning:
+  sweep_interval: often
+`)
+	_, err = LoadConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"often" is not a duration`)
+}
+
+func TestMissingRequiredValues(t *testing.T) {
+	writeConfig(t, "")
+
+	_, err := LoadConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "database.name (DATABASE_NAME) is not set")
+	assert.Contains(t, err.Error(), "auth.jwt_secret (JWT_SECRET_KEY) is not set")
+}
+
+func TestReloadAppliesSafeChangesOnly(t *testing.T) {
+	path := writeConfig(t, minimalFile)
+	cfg, err := LoadConfig()
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte(minimalFile+`
+server:
+  port: "4000"
+scanning:
+  sweep_interval: 2m
+  port_scan_workers: 8
+`), 0600))
+
+	result, err := cfg.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"scanning.sweep_interval"}, result.Applied)
+	assert.Equal(t, []string{"scanning.port_scan_workers", "server.port"}, result.RestartRequired)
+	assert.Equal(t, 2*time.Minute, cfg.Tuning().SweepInterval)
+	assert.Equal(t, "3008", cfg.Port)
+	assert.Equal(t, 3, cfg.Effective().Scanning.PortScanWorkers)
+
+	// Copies of the configuration see reloaded values too
+	copied := *cfg
+	assert.Equal(t, 2*time.Minute, copied.Tuning().SweepInterval)
+}
+
+func TestReloadKeepsTheRunningConfigurationWhenInvalid(t *testing.T) {
+	path := writeConfig(t, minimalFile)
+	cfg, err := LoadConfig()
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte(minimalFile+`
+scanning:
+  sweep_interval: 1s
+`), 0600))
+
+	_, err = cfg.Reload()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "scanning.sweep_interval (SWEEP_INTERVAL) must be at least 5s")
+	assert.Equal(t, 30*time.Second, cfg.Tuning().SweepInterval)
+}
+
+func TestEffectiveRedactsSecrets(t *testing.T) {
+	writeConfig(t, minimalFile+`
+metrics:
+  token: scrape-me
+`)
+	cfg, err := LoadConfig()
+	require.NoError(t, err)
+
+	effective := cfg.Effective()
+	assert.Equal(t, "admin", effective.Auth.Username)
+	assert.Equal(t, redacted, effective.Auth.Password)
+	assert.Equal(t, redacted, effective.Auth.JWTSecret)
+	assert.Equal(t, redacted, effective.Metrics.Token)
+	assert.Equal(t, "", effective.Agents.EnrollmentToken)
+}
+
+func TestExampleFileIsValid(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "reconya.example.yaml"))
+	require.NoError(t, err)
+
+	f := defaultFile()
+	require.NoError(t, decodeFile(data, &f))
+	f.Auth.Password = "secret"
+	f.Auth.JWTSecret = "jwt-secret"
+	require.NoError(t, f.validate())
+	assert.Equal(t, defaultFile().Scanning, f.Scanning)
+}
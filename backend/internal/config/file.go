@@ -0,0 +1,397 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"reconya-ai/internal/logging"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigFile is read from the working directory when RECONYA_CONFIG is not set
+const DefaultConfigFile = "reconya.yaml"
+
+// redacted replaces secrets in the effective configuration
+const redacted = "[redacted]"
+
+// File is the schema of the YAML configuration file, see reconya.example.yaml.
+// Every key is optional. Environment variables override the file and defaults
+// fill in whatever neither sets.
+type File struct {
+	Server struct {
+		Port        string `yaml:"port" json:"port"`
+		TLSCertFile string `yaml:"tls_cert_file" json:"tls_cert_file"`
+		TLSKeyFile  string `yaml:"tls_key_file" json:"tls_key_file"`
+	} `yaml:"server" json:"server"`
+	Database struct {
+		Name       string `yaml:"name" json:"name"`
+		SQLitePath string `yaml:"sqlite_path" json:"sqlite_path"`
+	} `yaml:"database" json:"database"`
+	Auth struct {
+		Username  string `yaml:"username" json:"username"`
+		Password  string `yaml:"password" json:"password"`
+		JWTSecret string `yaml:"jwt_secret" json:"jwt_secret"`
+		// EncryptionKey encrypts stored credentials, it defaults to the JWT secret
+		EncryptionKey string `yaml:"encryption_key" json:"encryption_key"`
+	} `yaml:"auth" json:"auth"`
+	Agents struct {
+		EnrollmentToken string `yaml:"enrollment_token" json:"enrollment_token"`
+		ClientCAFile    string `yaml:"client_ca_file" json:"client_ca_file"`
+	} `yaml:"agents" json:"agents"`
+	Metrics struct {
+		Token string `yaml:"token" json:"token"`
+	} `yaml:"metrics" json:"metrics"`
+	WakeOnLAN struct {
+		Port      int    `yaml:"port" json:"port"`
+		Interface string `yaml:"interface" json:"interface"`
+	} `yaml:"wake_on_lan" json:"wake_on_lan"`
+	Logging struct {
+		Format     string            `yaml:"format" json:"format"`
+		Level      string            `yaml:"level" json:"level"`
+		Levels     map[string]string `yaml:"levels" json:"levels"`
+		BufferSize int               `yaml:"buffer_size" json:"buffer_size"`
+	} `yaml:"logging" json:"logging"`
+	Scanning struct {
+		SweepInterval      Duration `yaml:"sweep_interval" json:"sweep_interval"`
+		SweepTimeout       Duration `yaml:"sweep_timeout" json:"sweep_timeout"`
+		SweepRetryTimeout  Duration `yaml:"sweep_retry_timeout" json:"sweep_retry_timeout"`
+		IdleAfter          Duration `yaml:"idle_after" json:"idle_after"`
+		OfflineAfter       Duration `yaml:"offline_after" json:"offline_after"`
+		PortScanWorkers    int      `yaml:"port_scan_workers" json:"port_scan_workers"`
+		WebScanWorkers     int      `yaml:"web_scan_workers" json:"web_scan_workers"`
+		FingerprintWorkers int      `yaml:"fingerprint_workers" json:"fingerprint_workers"`
+		PortScanTimeout    Duration `yaml:"port_scan_timeout" json:"port_scan_timeout"`
+		PortScanPorts      string   `yaml:"port_scan_ports" json:"port_scan_ports"`
+	} `yaml:"scanning" json:"scanning"`
+	Screenshots struct {
+		Tools []string `yaml:"tools" json:"tools"`
+	} `yaml:"screenshots" json:"screenshots"`
+}
+
+// Duration is a time.Duration written as "30s" or "5m" in the file and the API
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := time.ParseDuration(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %q is not a duration such as 30s or 5m", value.Line, value.Value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(time.Duration(d).String())), nil
+}
+
+func defaultFile() File {
+	var f File
+	f.Server.Port = "3008"
+	f.WakeOnLAN.Port = 9
+	f.Logging.Format = logging.FormatText
+	f.Logging.Level = "info"
+	f.Logging.BufferSize = logging.DefaultBufferSize
+
+	tuning := DefaultTuning()
+	f.Scanning.SweepInterval = Duration(tuning.SweepInterval)
+	f.Scanning.SweepTimeout = Duration(tuning.SweepTimeout)
+	f.Scanning.SweepRetryTimeout = Duration(tuning.SweepRetryTimeout)
+	f.Scanning.IdleAfter = Duration(tuning.IdleAfter)
+	f.Scanning.OfflineAfter = Duration(tuning.OfflineAfter)
+	f.Scanning.PortScanWorkers = tuning.PortScanWorkers
+	f.Scanning.WebScanWorkers = tuning.WebScanWorkers
+	f.Scanning.FingerprintWorkers = tuning.FingerprintWorkers
+	f.Scanning.PortScanTimeout = Duration(tuning.PortScanTimeout)
+	f.Scanning.PortScanPorts = tuning.PortScanPorts
+	f.Screenshots.Tools = tuning.ScreenshotTools
+	return f
+}
+
+// configPath is the file named by RECONYA_CONFIG, or reconya.yaml when it exists
+func configPath() (string, error) {
+	if path := os.Getenv("RECONYA_CONFIG"); path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("RECONYA_CONFIG: %w", err)
+		}
+		return path, nil
+	}
+	if _, err := os.Stat(DefaultConfigFile); err == nil {
+		return DefaultConfigFile, nil
+	}
+	return "", nil
+}
+
+// loadFile reads the defaults, then the config file, then the environment
+func loadFile() (File, string, error) {
+	f := defaultFile()
+	path, err := configPath()
+	if err != nil {
+		return f, "", err
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return f, path, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := decodeFile(data, &f); err != nil {
+			return f, path, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+	if err := applyEnv(&f); err != nil {
+		return f, path, err
+	}
+	return f, path, nil
+}
+
+// decodeFile decodes YAML into f, rejecting unknown keys so typos are not ignored
+func decodeFile(data []byte, f *File) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(f); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// applyEnv overrides the file with the environment variables that are set
+func applyEnv(f *File) error {
+	var problems []string
+	str := func(name string, dst *string) {
+		if value := os.Getenv(name); value != "" {
+			*dst = value
+		}
+	}
+	num := func(name string, dst *int) {
+		if value := os.Getenv(name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s must be a number, got %q", name, value))
+				return
+			}
+			*dst = parsed
+		}
+	}
+	duration := func(name string, dst *Duration) {
+		if value := os.Getenv(name); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s must be a duration such as 30s or 5m, got %q", name, value))
+				return
+			}
+			*dst = Duration(parsed)
+		}
+	}
+
+	str("PORT", &f.Server.Port)
+	str("TLS_CERT_FILE", &f.Server.TLSCertFile)
+	str("TLS_KEY_FILE", &f.Server.TLSKeyFile)
+	str("DATABASE_NAME", &f.Database.Name)
+	str("SQLITE_PATH", &f.Database.SQLitePath)
+	str("LOGIN_USERNAME", &f.Auth.Username)
+	str("LOGIN_PASSWORD", &f.Auth.Password)
+	str("JWT_SECRET_KEY", &f.Auth.JWTSecret)
+	str("SECRET_ENCRYPTION_KEY", &f.Auth.EncryptionKey)
+	str("AGENT_ENROLLMENT_TOKEN", &f.Agents.EnrollmentToken)
+	str("AGENT_CLIENT_CA_FILE", &f.Agents.ClientCAFile)
+	str("METRICS_TOKEN", &f.Metrics.Token)
+	num("WOL_PORT", &f.WakeOnLAN.Port)
+	str("WOL_INTERFACE", &f.WakeOnLAN.Interface)
+	str("LOG_FORMAT", &f.Logging.Format)
+	str("LOG_LEVEL", &f.Logging.Level)
+	if value := os.Getenv("LOG_LEVELS"); value != "" {
+		f.Logging.Levels = map[string]string{}
+		for _, pair := range strings.Split(value, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			component, level, ok := strings.Cut(pair, "=")
+			if component = strings.TrimSpace(component); !ok || component == "" {
+				problems = append(problems, fmt.Sprintf("LOG_LEVELS must be written as component=level, got %q", pair))
+				continue
+			}
+			f.Logging.Levels[component] = strings.TrimSpace(level)
+		}
+	}
+	num("LOG_BUFFER_SIZE", &f.Logging.BufferSize)
+	duration("SWEEP_INTERVAL", &f.Scanning.SweepInterval)
+	duration("SWEEP_TIMEOUT", &f.Scanning.SweepTimeout)
+	duration("SWEEP_RETRY_TIMEOUT", &f.Scanning.SweepRetryTimeout)
+	duration("DEVICE_IDLE_AFTER", &f.Scanning.IdleAfter)
+	duration("DEVICE_OFFLINE_AFTER", &f.Scanning.OfflineAfter)
+	num("PORT_SCAN_WORKERS", &f.Scanning.PortScanWorkers)
+	num("WEB_SCAN_WORKERS", &f.Scanning.WebScanWorkers)
+	num("FINGERPRINT_WORKERS", &f.Scanning.FingerprintWorkers)
+	duration("PORT_SCAN_TIMEOUT", &f.Scanning.PortScanTimeout)
+	str("PORT_SCAN_PORTS", &f.Scanning.PortScanPorts)
+	if value := os.Getenv("SCREENSHOT_TOOLS"); value != "" {
+		f.Screenshots.Tools = nil
+		for _, tool := range strings.Split(value, ",") {
+			if tool = strings.TrimSpace(tool); tool != "" {
+				f.Screenshots.Tools = append(f.Screenshots.Tools, tool)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return validationError(problems)
+	}
+	return nil
+}
+
+var portListPattern = regexp.MustCompile(`^\d+(-\d+)?(,\d+(-\d+)?)*$`)
+
+// validate reports every invalid value at once, naming the file key and its variable
+func (f *File) validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if f.Database.Name == "" {
+		add("database.name (DATABASE_NAME) is not set")
+	}
+	if f.Auth.Username == "" || f.Auth.Password == "" {
+		add("auth.username and auth.password (LOGIN_USERNAME, LOGIN_PASSWORD) are not set")
+	}
+	if f.Auth.JWTSecret == "" {
+		add("auth.jwt_secret (JWT_SECRET_KEY) is not set")
+	}
+	if port, err := strconv.Atoi(f.Server.Port); err != nil || port < 1 || port > 65535 {
+		add("server.port (PORT) must be a port number between 1 and 65535, got %q", f.Server.Port)
+	}
+	if (f.Server.TLSCertFile == "") != (f.Server.TLSKeyFile == "") {
+		add("server.tls_cert_file and server.tls_key_file (TLS_CERT_FILE, TLS_KEY_FILE) must be set together")
+	}
+	if f.Agents.ClientCAFile != "" && f.Server.TLSCertFile == "" {
+		add("agents.client_ca_file (AGENT_CLIENT_CA_FILE) requires server.tls_cert_file and server.tls_key_file")
+	}
+	if f.WakeOnLAN.Port < 1 || f.WakeOnLAN.Port > 65535 {
+		add("wake_on_lan.port (WOL_PORT) must be a port number between 1 and 65535, got %d", f.WakeOnLAN.Port)
+	}
+
+	problems = append(problems, f.loggingProblems()...)
+	problems = append(problems, f.tuningProblems()...)
+
+	if len(problems) > 0 {
+		return validationError(problems)
+	}
+	return nil
+}
+
+// tuningProblems validates the scanning and screenshot sections, which is all the
+// command line scanner needs
+func (f *File) tuningProblems() []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	scanning := f.Scanning
+	if time.Duration(scanning.SweepInterval) < 5*time.Second {
+		add("scanning.sweep_interval (SWEEP_INTERVAL) must be at least 5s, got %v", time.Duration(scanning.SweepInterval))
+	}
+	if scanning.SweepTimeout <= 0 || scanning.SweepRetryTimeout <= 0 || scanning.PortScanTimeout <= 0 {
+		add("scanning timeouts (SWEEP_TIMEOUT, SWEEP_RETRY_TIMEOUT, PORT_SCAN_TIMEOUT) must be positive")
+	}
+	if scanning.IdleAfter <= 0 {
+		add("scanning.idle_after (DEVICE_IDLE_AFTER) must be positive, got %v", time.Duration(scanning.IdleAfter))
+	}
+	if scanning.OfflineAfter <= scanning.IdleAfter {
+		add("scanning.offline_after (DEVICE_OFFLINE_AFTER) must be longer than scanning.idle_after, got %v and %v",
+			time.Duration(scanning.OfflineAfter), time.Duration(scanning.IdleAfter))
+	}
+	if scanning.PortScanWorkers < 1 || scanning.PortScanWorkers > 64 {
+		add("scanning.port_scan_workers (PORT_SCAN_WORKERS) must be between 1 and 64, got %d", scanning.PortScanWorkers)
+	}
+	if scanning.WebScanWorkers < 1 || scanning.WebScanWorkers > 64 {
+		add("scanning.web_scan_workers (WEB_SCAN_WORKERS) must be between 1 and 64, got %d", scanning.WebScanWorkers)
+	}
+	if scanning.FingerprintWorkers < 1 || scanning.FingerprintWorkers > 64 {
+		add("scanning.fingerprint_workers (FINGERPRINT_WORKERS) must be between 1 and 64, got %d", scanning.FingerprintWorkers)
+	}
+	if err := ValidatePortList(scanning.PortScanPorts); err != nil {
+		add("scanning.port_scan_ports (PORT_SCAN_PORTS): %v", err)
+	}
+
+	if len(f.Screenshots.Tools) == 0 {
+		add("screenshots.tools (SCREENSHOT_TOOLS) must list at least one tool")
+	}
+	seen := map[string]bool{}
+	for _, tool := range f.Screenshots.Tools {
+		if !isScreenshotTool(tool) {
+			add("screenshots.tools (SCREENSHOT_TOOLS): unknown tool %q, expected one of %s", tool, strings.Join(ScreenshotTools, ", "))
+		} else if seen[tool] {
+			add("screenshots.tools (SCREENSHOT_TOOLS): %q is listed twice", tool)
+		}
+		seen[tool] = true
+	}
+	return problems
+}
+
+// loggingProblems validates the logging section on its own, it is needed before
+// the rest of the configuration is checked
+func (f *File) loggingProblems() []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if f.Logging.Format != logging.FormatText && f.Logging.Format != logging.FormatJSON {
+		add("logging.format (LOG_FORMAT) must be text or json, got %q", f.Logging.Format)
+	}
+	if _, err := logging.ParseLevel(f.Logging.Level); err != nil {
+		add("logging.level (LOG_LEVEL): %v", err)
+	}
+	for component, level := range f.Logging.Levels {
+		if _, err := logging.ParseLevel(level); err != nil || level == "" {
+			add("logging.levels.%s (LOG_LEVELS): expected debug, info, warn or error, got %q", component, level)
+		}
+	}
+	if f.Logging.BufferSize < 1 {
+		add("logging.buffer_size (LOG_BUFFER_SIZE) must be a positive number, got %d", f.Logging.BufferSize)
+	}
+	return problems
+}
+
+// ValidatePortList checks an nmap port list such as 22,80,8000-8100
+func ValidatePortList(ports string) error {
+	if !portListPattern.MatchString(ports) {
+		return fmt.Errorf("expected ports and ranges such as 22,80,8000-8100")
+	}
+	for _, part := range strings.Split(ports, ",") {
+		low, high, isRange := strings.Cut(part, "-")
+		first, _ := strconv.Atoi(low)
+		last := first
+		if isRange {
+			last, _ = strconv.Atoi(high)
+		}
+		if first < 1 || last > 65535 || first > last {
+			return fmt.Errorf("invalid port or range %q", part)
+		}
+	}
+	return nil
+}
+
+func validationError(problems []string) error {
+	return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
+}
+
+// redact hides secrets that are set, leaving empty ones visible
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redacted
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"reconya-ai/internal/logging"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// reloadable are the settings Reload applies while running. Everything else, such
+// as the port, database, secrets or the number of port scan workers, needs a restart.
+var reloadable = []string{
+	"scanning.sweep_interval",
+	"scanning.sweep_timeout",
+	"scanning.sweep_retry_timeout",
+	"scanning.idle_after",
+	"scanning.offline_after",
+	"scanning.port_scan_timeout",
+	"scanning.port_scan_ports",
+	"screenshots.tools",
+	"logging.level",
+	"logging.levels",
+}
+
+// ReloadResult lists the settings that changed, by their key in the config file
+type ReloadResult struct {
+	Applied []string `json:"applied"`
+	// RestartRequired changed in the file or environment but keep their old value
+	// until reconya restarts
+	RestartRequired []string `json:"restart_required"`
+}
+
+// Reload reads the configuration again and applies the changes that are safe while
+// running: scanner tuning, screenshot tools and log levels. When the new
+// configuration is invalid nothing changes.
+func (c *Config) Reload() (*ReloadResult, error) {
+	if c.tuning == nil {
+		return nil, fmt.Errorf("configuration was not loaded from the file and environment")
+	}
+	state := c.tuning
+	state.reloadLock.Lock()
+	defer state.reloadLock.Unlock()
+
+	_ = godotenv.Load()
+	f, path, err := loadFile()
+	if err == nil {
+		err = f.validate()
+	}
+	if err != nil {
+		if path != "" {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return nil, err
+	}
+
+	result := &ReloadResult{Applied: []string{}, RestartRequired: []string{}}
+	for _, key := range changedKeys(state.effective, f) {
+		if isReloadable(key) {
+			result.Applied = append(result.Applied, key)
+		} else {
+			result.RestartRequired = append(result.RestartRequired, key)
+		}
+	}
+
+	c.SetTuning(f.tuning())
+	logConfig := f.logConfig()
+	logging.SetLevels(logConfig.Level, logConfig.Levels)
+
+	// Worker counts keep their startup values until a restart
+	before := state.effective.Scanning
+	state.effective.Scanning = f.Scanning
+	state.effective.Scanning.PortScanWorkers = before.PortScanWorkers
+	state.effective.Scanning.WebScanWorkers = before.WebScanWorkers
+	state.effective.Scanning.FingerprintWorkers = before.FingerprintWorkers
+	state.effective.Screenshots = f.Screenshots
+	state.effective.Logging.Level = f.Logging.Level
+	state.effective.Logging.Levels = f.Logging.Levels
+	return result, nil
+}
+
+// Effective is the configuration in use with secrets redacted. Log levels changed
+// through the API since the last load are not included.
+func (c *Config) Effective() File {
+	if c.tuning == nil {
+		return defaultFile()
+	}
+	c.tuning.reloadLock.Lock()
+	f := c.tuning.effective
+	c.tuning.reloadLock.Unlock()
+
+	f.Auth.Password = redact(f.Auth.Password)
+	f.Auth.JWTSecret = redact(f.Auth.JWTSecret)
+	f.Auth.EncryptionKey = redact(f.Auth.EncryptionKey)
+	f.Agents.EnrollmentToken = redact(f.Agents.EnrollmentToken)
+	f.Metrics.Token = redact(f.Metrics.Token)
+	f.Screenshots.Tools = append([]string(nil), f.Screenshots.Tools...)
+	levels := make(map[string]string, len(f.Logging.Levels))
+	for component, level := range f.Logging.Levels {
+		levels[component] = level
+	}
+	f.Logging.Levels = levels
+	return f
+}
+
+func isReloadable(key string) bool {
+	for _, prefix := range reloadable {
+		if key == prefix || strings.HasPrefix(key, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// changedKeys compares two configurations key by key, as written in the file
+func changedKeys(old, new File) []string {
+	before, after := flatten(old), flatten(new)
+	var keys []string
+	for key, value := range after {
+		if !reflect.DeepEqual(before[key], value) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func flatten(f File) map[string]interface{} {
+	data, _ := yaml.Marshal(f)
+	var tree map[string]interface{}
+	_ = yaml.Unmarshal(data, &tree)
+	values := map[string]interface{}{}
+	var walk func(prefix string, node interface{})
+	walk = func(prefix string, node interface{}) {
+		if children, ok := node.(map[string]interface{}); ok && len(children) > 0 {
+			for key, child := range children {
+				walk(prefix+"."+key, child)
+			}
+			return
+		}
+		values[strings.TrimPrefix(prefix, ".")] = node
+	}
+	walk("", tree)
+	return values
+}
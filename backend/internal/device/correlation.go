@@ -0,0 +1,142 @@
+package device
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"reconya-ai/models"
+)
+
+// correlationThreshold is the confidence at which a randomized MAC is attributed to a
+// known device instead of creating a new one
+const correlationThreshold = 60
+
+// Weights of the individual identity signals. A single strong signal (a DHCP client
+// identifier) is enough to merge, weaker ones need to agree with each other.
+var correlationWeights = map[string]int{
+	"dhcp_client_id": 60,
+	"ipv6_iid":       50,
+	"mdns_name":      45,
+	"hostname":       35,
+}
+
+// CorrelateDevice scores the candidates against a device seen with a randomized MAC
+// and returns the best match. The correlation is marked as merged only when the
+// confidence reaches the threshold and no other candidate scores as high.
+func CorrelateDevice(incoming *models.Device, candidates []*models.Device) (*models.Device, *models.DeviceCorrelation) {
+	var best *models.Device
+	var bestSignals []string
+	bestScore, tie := 0, false
+
+	for _, candidate := range candidates {
+		if candidate.ID == incoming.ID {
+			continue
+		}
+		signals := matchingSignals(incoming, candidate)
+		score := 0
+		for _, signal := range signals {
+			score += correlationWeights[signal]
+		}
+		if score > 100 {
+			score = 100
+		}
+
+		switch {
+		case score > bestScore:
+			best, bestSignals, bestScore, tie = candidate, signals, score, false
+		case score == bestScore && score > 0:
+			tie = true
+		}
+	}
+
+	if best == nil {
+		return nil, nil
+	}
+	return best, &models.DeviceCorrelation{
+		DeviceID:   best.ID,
+		Confidence: bestScore,
+		Signals:    bestSignals,
+		Merged:     bestScore >= correlationThreshold && !tie,
+	}
+}
+
+func matchingSignals(a, b *models.Device) []string {
+	var signals []string
+	ai, bi := identityOf(a), identityOf(b)
+
+	if ai.DHCPClientID != "" && strings.EqualFold(ai.DHCPClientID, bi.DHCPClientID) {
+		signals = append(signals, "dhcp_client_id")
+	}
+	if name := normalizeName(ai.MDNSName); name != "" && name == normalizeName(bi.MDNSName) {
+		signals = append(signals, "mdns_name")
+	}
+	if a.Hostname != nil && b.Hostname != nil {
+		if name := normalizeName(*a.Hostname); name != "" && name == normalizeName(*b.Hostname) {
+			signals = append(signals, "hostname")
+		}
+	}
+
+	theirs := make(map[string]bool)
+	for _, iid := range StableInterfaceIDs(b) {
+		theirs[iid] = true
+	}
+	for _, iid := range StableInterfaceIDs(a) {
+		if theirs[iid] {
+			signals = append(signals, "ipv6_iid")
+			break
+		}
+	}
+
+	sort.Strings(signals)
+	return signals
+}
+
+// StableInterfaceIDs returns the IPv6 interface identifiers of a device that survive a
+// MAC change. EUI-64 identifiers are derived from the MAC and change with it, so they
+// are left out.
+func StableInterfaceIDs(d *models.Device) []string {
+	var addresses []string
+	for _, addr := range []*string{d.IPv6LinkLocal, d.IPv6UniqueLocal, d.IPv6Global} {
+		if addr != nil {
+			addresses = append(addresses, *addr)
+		}
+	}
+	addresses = append(addresses, d.IPv6Addresses...)
+
+	seen := make(map[string]bool)
+	var iids []string
+	for _, addr := range addresses {
+		ip := net.ParseIP(strings.SplitN(addr, "%", 2)[0])
+		if ip == nil || ip.To4() != nil {
+			continue
+		}
+		iid := ip.To16()[8:]
+		if iid[3] == 0xff && iid[4] == 0xfe {
+			continue
+		}
+		// Manually assigned identifiers like ::1 are shared by many devices
+		if iid[0]|iid[1]|iid[2]|iid[3]|iid[4]|iid[5] == 0 {
+			continue
+		}
+		key := net.HardwareAddr(iid).String()
+		if !seen[key] {
+			seen[key] = true
+			iids = append(iids, key)
+		}
+	}
+	return iids
+}
+
+func identityOf(d *models.Device) models.DeviceIdentity {
+	if d.Identity == nil {
+		return models.DeviceIdentity{}
+	}
+	return *d.Identity
+}
+
+func normalizeName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.TrimSuffix(name, ".")
+	return strings.TrimSuffix(name, ".local")
+}
@@ -0,0 +1,93 @@
+package device
+
+import (
+	"testing"
+
+	"reconya-ai/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorrelateDevice(t *testing.T) {
+	strp := func(s string) *string { return &s }
+
+	incoming := &models.Device{
+		Hostname:   strp("annas-iphone"),
+		IPv6Global: strp("2001:db8::8c1a:2bff:4e10:93aa"),
+		Identity:   &models.DeviceIdentity{MDNSName: "Annas-iPhone.local", MACRandomized: true},
+	}
+
+	t.Run("AgreeingSignalsMerge", func(t *testing.T) {
+		phone := &models.Device{ID: "phone", Hostname: strp("Annas-iPhone"), Identity: &models.DeviceIdentity{MDNSName: "annas-iphone.local."}}
+		laptop := &models.Device{ID: "laptop", Hostname: strp("work-laptop")}
+
+		match, correlation := CorrelateDevice(incoming, []*models.Device{laptop, phone})
+		require.NotNil(t, correlation)
+		assert.Equal(t, phone, match)
+		assert.Equal(t, "phone", correlation.DeviceID)
+		assert.Equal(t, 80, correlation.Confidence)
+		assert.Equal(t, []string{"hostname", "mdns_name"}, correlation.Signals)
+		assert.True(t, correlation.Merged)
+	})
+
+	t.Run("WeakMatchIsNotMerged", func(t *testing.T) {
+		phone := &models.Device{ID: "phone", Hostname: strp("annas-iphone")}
+
+		match, correlation := CorrelateDevice(incoming, []*models.Device{phone})
+		require.NotNil(t, correlation)
+		assert.Equal(t, phone, match)
+		assert.Equal(t, 35, correlation.Confidence)
+		assert.False(t, correlation.Merged)
+	})
+
+	t.Run("AmbiguousMatchIsNotMerged", func(t *testing.T) {
+		a := &models.Device{ID: "a", Hostname: strp("annas-iphone"), Identity: &models.DeviceIdentity{MDNSName: "annas-iphone.local"}}
+		b := &models.Device{ID: "b", Hostname: strp("annas-iphone"), Identity: &models.DeviceIdentity{MDNSName: "annas-iphone.local"}}
+
+		_, correlation := CorrelateDevice(incoming, []*models.Device{a, b})
+		require.NotNil(t, correlation)
+		assert.Equal(t, 80, correlation.Confidence)
+		assert.False(t, correlation.Merged)
+	})
+
+	t.Run("StrongSignalsAreCapped", func(t *testing.T) {
+		client := &models.Device{
+			Identity:   &models.DeviceIdentity{DHCPClientID: "01:8c:1a:2b:4e:10:93", MDNSName: "annas-iphone.local"},
+			IPv6Global: strp("2001:db8::8c1a:2bff:4e10:93aa"),
+		}
+		known := &models.Device{
+			ID:            "phone",
+			Identity:      &models.DeviceIdentity{DHCPClientID: "01:8C:1A:2B:4E:10:93"},
+			IPv6Addresses: []string{"fe80::8c1a:2bff:4e10:93aa%eth0"},
+		}
+
+		_, correlation := CorrelateDevice(client, []*models.Device{known})
+		require.NotNil(t, correlation)
+		assert.Equal(t, 100, correlation.Confidence)
+		assert.Equal(t, []string{"dhcp_client_id", "ipv6_iid"}, correlation.Signals)
+		assert.True(t, correlation.Merged)
+	})
+
+	t.Run("NoSignals", func(t *testing.T) {
+		other := &models.Device{ID: "other", Hostname: strp("printer")}
+		match, correlation := CorrelateDevice(incoming, []*models.Device{other})
+		assert.Nil(t, match)
+		assert.Nil(t, correlation)
+	})
+}
+
+func TestStableInterfaceIDs(t *testing.T) {
+	strp := func(s string) *string { return &s }
+
+	d := &models.Device{
+		IPv6LinkLocal: strp("fe80::8c1a:2bff:4e10:93aa"),
+		IPv6Global:    strp("2001:db8::8c1a:2bff:4e10:93aa"),
+		IPv6Addresses: []string{
+			"fe80::21a:2bff:fe3c:4d5e", // EUI-64, changes with the MAC
+			"2001:db8::1",              // manually assigned
+			"192.168.1.10",
+		},
+	}
+	assert.Equal(t, []string{"8c:1a:2b:ff:4e:10:93:aa"}, StableInterfaceIDs(d))
+}
@@ -3,7 +3,6 @@ package device
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"reconya-ai/internal/config"
 	"reconya-ai/models"
@@ -73,7 +72,7 @@ func (h *DeviceHandlers) GetAllDevices(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Returning %d devices from all networks", len(foundDevices))
+	logger.Debugf("Returning %d devices from all networks", len(foundDevices))
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(foundDevices)
 }
@@ -87,7 +86,7 @@ func (h *DeviceHandlers) CleanupDeviceNames(w http.ResponseWriter, r *http.Reque
 
 	err := h.Service.CleanupAllDeviceNames()
 	if err != nil {
-		log.Printf("Device name cleanup failed: %v", err)
+		logger.Errorf("Device name cleanup failed: %v", err)
 		http.Error(w, fmt.Sprintf("Cleanup failed: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,60 @@
+package device
+
+import (
+	"sort"
+	"strings"
+
+	"reconya-ai/internal/metrics"
+	"reconya-ai/models"
+)
+
+// RegisterMetrics reports the inventory by status, type and network on /metrics.
+// The counts are read from the database when the metrics are scraped.
+func (s *DeviceService) RegisterMetrics() {
+	metrics.NewCollector("reconya_devices", "Devices in the inventory by status, type and network",
+		metrics.GaugeType, []string{"status", "type", "network"}, s.collectInventoryMetrics)
+}
+
+func (s *DeviceService) collectInventoryMetrics(emit func(value float64, labelValues ...string)) {
+	devices, err := s.FindAll()
+	if err != nil {
+		logger.Errorf("Failed to collect device metrics: %v", err)
+		return
+	}
+
+	// Networks are labeled like sweeps, by CIDR
+	networkLabels := make(map[string]string)
+	if s.networkService != nil {
+		networks, err := s.networkService.FindAll()
+		if err != nil {
+			logger.Errorf("Failed to collect device metrics: %v", err)
+			return
+		}
+		for _, n := range networks {
+			label := n.CIDR
+			if label == "" {
+				label = n.GetIPv6Prefix()
+			}
+			networkLabels[n.ID] = label
+		}
+	}
+
+	counts := make(map[string]float64)
+	for _, d := range devices {
+		deviceType := d.DeviceType
+		if deviceType == "" {
+			deviceType = models.DeviceTypeUnknown
+		}
+		key := strings.Join([]string{string(d.Status), string(deviceType), networkLabels[d.NetworkID]}, "\xff")
+		counts[key]++
+	}
+
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		emit(counts[key], strings.Split(key, "\xff")...)
+	}
+}
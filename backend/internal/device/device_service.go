@@ -5,20 +5,39 @@ import (
 	"context"
 	"encoding/xml"
 	"fmt"
-	"log"
 	"net"
 	"reconya-ai/db"
 	"reconya-ai/internal/config"
 	"reconya-ai/internal/fingerprint"
+	"reconya-ai/internal/logging"
 	"reconya-ai/internal/network"
 	"reconya-ai/internal/oui"
 	"reconya-ai/internal/util"
 	"reconya-ai/models"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
+var logger = logging.For("device")
+
+const (
+	ipv6StaleAfter  = 24 * time.Hour
+	ipv6ExpireAfter = 7 * 24 * time.Hour
+	// ipv6PurgeAfter is how long expired addresses are kept for reference
+	ipv6PurgeAfter = 30 * 24 * time.Hour
+	// dhcpClientIDTTL is how long a DHCP client identifier seen for a MAC is applied
+	// to sightings of that MAC, longer than common lease times
+	dhcpClientIDTTL = 24 * time.Hour
+)
+
+// dhcpClientID is a client identifier captured from DHCP traffic
+type dhcpClientID struct {
+	id     string
+	seenAt time.Time
+}
+
 type DeviceService struct {
 	Config             *config.Config
 	repository         db.DeviceRepository
@@ -26,6 +45,8 @@ type DeviceService struct {
 	dbManager          *db.DBManager
 	fingerprintService *fingerprint.FingerprintService
 	ouiService         *oui.OUIService
+	dhcpClientIDs      map[string]dhcpClientID
+	dhcpMutex          sync.Mutex
 }
 
 func NewDeviceService(deviceRepo db.DeviceRepository, networkService *network.NetworkService, cfg *config.Config, dbManager *db.DBManager, ouiService *oui.OUIService) *DeviceService {
@@ -36,6 +57,7 @@ func NewDeviceService(deviceRepo db.DeviceRepository, networkService *network.Ne
 		dbManager:          dbManager,
 		fingerprintService: fingerprint.NewFingerprintService(),
 		ouiService:         ouiService,
+		dhcpClientIDs:      make(map[string]dhcpClientID),
 	}
 }
 
@@ -60,7 +82,7 @@ func (s *DeviceService) CreateOrUpdate(device *models.Device) (*models.Device, e
 
 	// Skip network and broadcast addresses
 	if s.isNetworkOrBroadcastAddress(device.IPv4, network.CIDR) {
-		log.Printf("Skipping network/broadcast address: %s", device.IPv4)
+		logger.Debugf("Skipping network/broadcast address: %s", device.IPv4)
 		return nil, fmt.Errorf("network or broadcast address not allowed: %s", device.IPv4)
 	}
 
@@ -70,12 +92,22 @@ func (s *DeviceService) CreateOrUpdate(device *models.Device) (*models.Device, e
 		return nil, err
 	}
 
+	// Addresses get reassigned and devices split apart by MAC can share one, so a
+	// device at this IP with another MAC is only updated when no device has this MAC
+	if existingDevice != nil && device.MAC != nil && *device.MAC != "" &&
+		existingDevice.MAC != nil && *existingDevice.MAC != "" && !strings.EqualFold(*existingDevice.MAC, *device.MAC) {
+		if existingByMAC, err := s.FindDeviceByMAC(*device.MAC); err == nil && existingByMAC != nil {
+			existingDevice = existingByMAC
+			device.ID = existingByMAC.ID
+		}
+	}
+
 	// If no device found by IP and we have a MAC address, try to find by MAC
 	// This handles cases where a device changes IP but keeps the same MAC (DHCP reassignment)
 	if existingDevice == nil && device.MAC != nil && *device.MAC != "" {
 		existingByMAC, err := s.FindDeviceByMAC(*device.MAC)
 		if err == nil && existingByMAC != nil {
-			log.Printf("Found existing device by MAC %s, updating IP from %s to %s", 
+			logger.Infof("Found existing device by MAC %s, updating IP from %s to %s", 
 				*device.MAC, existingByMAC.IPv4, device.IPv4)
 			
 			// Update the existing device's IP address and other fields
@@ -92,6 +124,30 @@ func (s *DeviceService) CreateOrUpdate(device *models.Device) (*models.Device, e
 		}
 	}
 
+	if device.MAC != nil && *device.MAC != "" {
+		if clientID := s.DHCPClientID(*device.MAC); clientID != "" {
+			if device.Identity == nil {
+				device.Identity = &models.DeviceIdentity{}
+			}
+			device.Identity.DHCPClientID = clientID
+		}
+	}
+
+	// Randomized MACs change between networks and over time, so fall back to the
+	// remaining identity signals to recognize a device seen with a new one
+	if device.MAC != nil && util.IsRandomizedMAC(*device.MAC) {
+		if device.Identity == nil {
+			device.Identity = &models.DeviceIdentity{}
+		}
+		device.Identity.MACRandomized = true
+		if existingDevice == nil {
+			existingDevice = s.correlateRandomizedMAC(device, currentTime)
+		}
+	}
+	if existingDevice != nil {
+		device.Identity = mergeIdentity(device, existingDevice)
+	}
+
 	s.setTimestamps(device, existingDevice, currentTime)
 
 	// Set status if not already set
@@ -107,6 +163,12 @@ func (s *DeviceService) CreateOrUpdate(device *models.Device) (*models.Device, e
 		if (device.Comment == nil || *device.Comment == "") && existingDevice.Comment != nil && *existingDevice.Comment != "" {
 			device.Comment = existingDevice.Comment
 		}
+		// The trust state follows the device whether it was matched by IP or by MAC
+		if device.TrustState == "" {
+			device.TrustState = existingDevice.TrustState
+		}
+	} else if device.TrustState == "" {
+		device.TrustState = models.TrustStateNew
 	}
 
 	// Leave device name empty if not explicitly set
@@ -115,6 +177,151 @@ func (s *DeviceService) CreateOrUpdate(device *models.Device) (*models.Device, e
 	return s.dbManager.CreateOrUpdateDevice(s.repository, context.Background(), device)
 }
 
+// correlateRandomizedMAC looks for a known device on the same network matching a
+// device seen with an unknown randomized MAC. The correlation is recorded on the
+// device either way, the matched device is returned only when it is strong enough.
+func (s *DeviceService) correlateRandomizedMAC(device *models.Device, currentTime time.Time) *models.Device {
+	devices, err := s.FindByNetworkID(device.NetworkID)
+	if err != nil {
+		logger.Errorf("Failed to load devices for MAC correlation: %v", err)
+		return nil
+	}
+
+	// A device seen within the last minute is present at its own address and cannot
+	// be the one showing up here with a new MAC
+	candidates := make([]*models.Device, 0, len(devices))
+	for i := range devices {
+		if devices[i].LastSeenOnlineAt != nil && currentTime.Sub(*devices[i].LastSeenOnlineAt) < time.Minute {
+			continue
+		}
+		candidates = append(candidates, &devices[i])
+	}
+
+	match, correlation := CorrelateDevice(device, candidates)
+	if correlation == nil {
+		return nil
+	}
+	correlation.CorrelatedAt = currentTime
+	device.Identity.Correlation = correlation
+	if !correlation.Merged {
+		logger.Infof("Randomized MAC %s at %s possibly belongs to %s (%d%% via %s), keeping it separate",
+			*device.MAC, device.IPv4, match.IPv4, correlation.Confidence, strings.Join(correlation.Signals, ", "))
+		return nil
+	}
+
+	logger.Infof("Randomized MAC %s at %s matched to device %s (%d%% via %s)",
+		*device.MAC, device.IPv4, match.IPv4, correlation.Confidence, strings.Join(correlation.Signals, ", "))
+	device.ID = match.ID
+	return match
+}
+
+// RecordDHCPClientID remembers the DHCP client identifier (option 61) a MAC was
+// seen with. The next sighting of the MAC carries it into correlation, and a known
+// device with the MAC gets it right away so devices reappearing under a new
+// randomized MAC can be matched against it.
+func (s *DeviceService) RecordDHCPClientID(mac, clientID string) error {
+	hardwareAddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return err
+	}
+	mac = strings.ToUpper(hardwareAddr.String())
+	now := time.Now()
+
+	s.dhcpMutex.Lock()
+	for key, entry := range s.dhcpClientIDs {
+		if now.Sub(entry.seenAt) > dhcpClientIDTTL {
+			delete(s.dhcpClientIDs, key)
+		}
+	}
+	s.dhcpClientIDs[mac] = dhcpClientID{id: clientID, seenAt: now}
+	s.dhcpMutex.Unlock()
+
+	devices, err := s.FindAll()
+	if err != nil {
+		return err
+	}
+	for _, d := range devices {
+		if d.MAC == nil || !strings.EqualFold(*d.MAC, mac) {
+			continue
+		}
+		if d.Identity != nil && d.Identity.DHCPClientID == clientID {
+			return nil
+		}
+		if d.Identity == nil {
+			d.Identity = &models.DeviceIdentity{}
+		}
+		d.Identity.DHCPClientID = clientID
+		logger.Debugf("Device %s sent DHCP client identifier %s", d.IPv4, clientID)
+		return s.UpdateDeviceRecord(d)
+	}
+	return nil
+}
+
+// DHCPClientID returns the DHCP client identifier recently seen for a MAC, if any
+func (s *DeviceService) DHCPClientID(mac string) string {
+	hardwareAddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return ""
+	}
+	s.dhcpMutex.Lock()
+	defer s.dhcpMutex.Unlock()
+	entry, ok := s.dhcpClientIDs[strings.ToUpper(hardwareAddr.String())]
+	if !ok || time.Since(entry.seenAt) > dhcpClientIDTTL {
+		return ""
+	}
+	return entry.id
+}
+
+// mergeIdentity combines the identity signals of an incoming sighting with those
+// already stored, remembering the previous MAC when it changed
+func mergeIdentity(device, existing *models.Device) *models.DeviceIdentity {
+	if device.Identity == nil && existing.Identity == nil && (device.MAC == nil || existing.MAC == nil || *device.MAC == *existing.MAC) {
+		return nil
+	}
+
+	merged := models.DeviceIdentity{}
+	if existing.Identity != nil {
+		merged = *existing.Identity
+		merged.PreviousMACs = append([]string(nil), existing.Identity.PreviousMACs...)
+	}
+	if device.Identity != nil {
+		if device.Identity.MDNSName != "" {
+			merged.MDNSName = device.Identity.MDNSName
+		}
+		if device.Identity.DHCPClientID != "" {
+			merged.DHCPClientID = device.Identity.DHCPClientID
+		}
+		if device.Identity.Correlation != nil {
+			merged.Correlation = device.Identity.Correlation
+		}
+	}
+
+	if device.MAC != nil && *device.MAC != "" {
+		merged.MACRandomized = util.IsRandomizedMAC(*device.MAC)
+		if existing.MAC != nil && *existing.MAC != "" && !strings.EqualFold(*existing.MAC, *device.MAC) {
+			merged.PreviousMACs = appendPreviousMAC(merged.PreviousMACs, *existing.MAC, *device.MAC)
+		}
+	}
+	return &merged
+}
+
+// maxPreviousMACs bounds the MAC history kept for devices that rotate their MAC often
+const maxPreviousMACs = 10
+
+func appendPreviousMAC(previous []string, mac, current string) []string {
+	filtered := previous[:0]
+	for _, p := range previous {
+		if !strings.EqualFold(p, mac) && !strings.EqualFold(p, current) {
+			filtered = append(filtered, p)
+		}
+	}
+	filtered = append(filtered, mac)
+	if len(filtered) > maxPreviousMACs {
+		filtered = filtered[len(filtered)-maxPreviousMACs:]
+	}
+	return filtered
+}
+
 func (s *DeviceService) setTimestamps(device, existingDevice *models.Device, currentTime time.Time) {
 	if existingDevice == nil || existingDevice.CreatedAt.IsZero() {
 		device.CreatedAt = currentTime
@@ -125,7 +332,7 @@ func (s *DeviceService) setTimestamps(device, existingDevice *models.Device, cur
 }
 
 func (s *DeviceService) ParseFromNmap(bufferStream string) []models.Device {
-	log.Println("Starting Nmap parse")
+	logger.Debugf("Starting Nmap parse")
 	var devices []models.Device
 	lines := strings.Split(bufferStream, "\n")
 
@@ -149,9 +356,9 @@ func (s *DeviceService) ParseFromNmap(bufferStream string) []models.Device {
 				}
 
 				if device.Hostname != nil {
-					log.Printf("Found device - IP: %s, Hostname: %s", device.IPv4, *device.Hostname)
+					logger.Debugf("Found device - IP: %s, Hostname: %s", device.IPv4, *device.Hostname)
 				} else {
-					log.Printf("Found device - IP: %s, Hostname: <nil>", device.IPv4)
+					logger.Debugf("Found device - IP: %s, Hostname: <nil>", device.IPv4)
 				}
 
 			}
@@ -161,7 +368,7 @@ func (s *DeviceService) ParseFromNmap(bufferStream string) []models.Device {
 				if len(macParts) >= 3 {
 					mac := macParts[2]
 					device.MAC = &mac
-					log.Printf("Device MAC Address: %s", *device.MAC)
+					logger.Debugf("Device MAC Address: %s", *device.MAC)
 				}
 			}
 
@@ -169,18 +376,18 @@ func (s *DeviceService) ParseFromNmap(bufferStream string) []models.Device {
 		}
 	}
 
-	log.Printf("Finished parsing Nmap output. Total devices found: %d", len(devices))
+	logger.Debugf("Finished parsing Nmap output. Total devices found: %d", len(devices))
 	return devices
 }
 
 func (s *DeviceService) ParseFromNmapXML(xmlOutput string) []models.Device {
-	log.Println("Starting Nmap XML parse")
+	logger.Debugf("Starting Nmap XML parse")
 	var devices []models.Device
 	var nmapXML models.NmapXML
 
 	err := xml.Unmarshal([]byte(xmlOutput), &nmapXML)
 	if err != nil {
-		log.Printf("Error parsing Nmap XML output: %v", err)
+		logger.Errorf("Error parsing Nmap XML output: %v", err)
 		// Fallback to text parsing
 		return s.ParseFromNmap(xmlOutput)
 	}
@@ -207,18 +414,18 @@ func (s *DeviceService) ParseFromNmapXML(xmlOutput string) []models.Device {
 		// Set MAC address if found
 		if macAddress != "" {
 			device.MAC = &macAddress
-			log.Printf("Found MAC Address: %s for IP: %s", macAddress, device.IPv4)
+			logger.Debugf("Found MAC Address: %s for IP: %s", macAddress, device.IPv4)
 		}
 
 		// Set vendor info if found from Nmap
 		if vendor != "" {
 			device.Vendor = &vendor
-			log.Printf("Found Vendor from Nmap: %s for IP: %s", vendor, device.IPv4)
+			logger.Debugf("Found Vendor from Nmap: %s for IP: %s", vendor, device.IPv4)
 		} else if macAddress != "" && s.ouiService != nil {
 			// Fallback to OUI lookup if Nmap didn't provide vendor info
 			if ouiVendor := s.ouiService.LookupVendor(macAddress); ouiVendor != "" {
 				device.Vendor = &ouiVendor
-				log.Printf("Found Vendor from OUI: %s for MAC: %s (IP: %s)", ouiVendor, macAddress, device.IPv4)
+				logger.Debugf("Found Vendor from OUI: %s for MAC: %s (IP: %s)", ouiVendor, macAddress, device.IPv4)
 			}
 		}
 
@@ -226,10 +433,10 @@ func (s *DeviceService) ParseFromNmapXML(xmlOutput string) []models.Device {
 		if len(host.Hostnames) > 0 && host.Hostnames[0].Name != "" {
 			hostname := host.Hostnames[0].Name
 			device.Hostname = &hostname
-			log.Printf("Found Hostname: %s for IP: %s", hostname, device.IPv4)
+			logger.Debugf("Found Hostname: %s for IP: %s", hostname, device.IPv4)
 		}
 
-		log.Printf("Found device - IP: %s, MAC: %v, Vendor: %v, Hostname: %v",
+		logger.Debugf("Found device - IP: %s, MAC: %v, Vendor: %v, Hostname: %v",
 			device.IPv4,
 			func() string {
 				if device.MAC != nil {
@@ -256,13 +463,13 @@ func (s *DeviceService) ParseFromNmapXML(xmlOutput string) []models.Device {
 		devices = append(devices, device)
 	}
 
-	log.Printf("Finished parsing Nmap XML output. Total devices found: %d", len(devices))
+	logger.Debugf("Finished parsing Nmap XML output. Total devices found: %d", len(devices))
 	return devices
 }
 
 func (s *DeviceService) EligibleForPortScan(device *models.Device) bool {
 	if device == nil {
-		log.Println("Warning: Attempted to check port scan eligibility for a nil device")
+		logger.Warnf("Attempted to check port scan eligibility for a nil device")
 		return false
 	}
 
@@ -315,7 +522,7 @@ func (s *DeviceService) FindByID(deviceID string) (*models.Device, error) {
 		return nil, nil
 	}
 	if err != nil {
-		log.Printf("Error finding device with ID %s: %v", deviceID, err)
+		logger.Errorf("Error finding device with ID %s: %v", deviceID, err)
 		return nil, err
 	}
 	return device, nil
@@ -330,18 +537,18 @@ func (s *DeviceService) Delete(deviceID string) error {
 		return fmt.Errorf("device not found")
 	}
 	if err != nil {
-		log.Printf("Error finding device with ID %s for deletion: %v", deviceID, err)
+		logger.Errorf("Error finding device with ID %s for deletion: %v", deviceID, err)
 		return err
 	}
 	
 	// Delete the device (this will cascade to ports and web services)
 	err = s.repository.DeleteByID(ctx, deviceID)
 	if err != nil {
-		log.Printf("Error deleting device with ID %s: %v", deviceID, err)
+		logger.Errorf("Error deleting device with ID %s: %v", deviceID, err)
 		return err
 	}
 	
-	log.Printf("Successfully deleted device %s (%s)", device.IPv4, deviceID)
+	logger.Infof("Successfully deleted device %s (%s)", device.IPv4, deviceID)
 	return nil
 }
 
@@ -355,7 +562,7 @@ func (s *DeviceService) DeleteByNetworkID(networkID string) error {
 		return fmt.Errorf("failed to find devices for network %s: %v", networkID, err)
 	}
 	
-	log.Printf("Deleting %d devices from network %s", len(devices), networkID)
+	logger.Infof("Deleting %d devices from network %s", len(devices), networkID)
 	
 	// Delete each device
 	var errors []string
@@ -368,18 +575,18 @@ func (s *DeviceService) DeleteByNetworkID(networkID string) error {
 			continue
 		}
 		deletedCount++
-		log.Printf("Deleted device %s (%s)", device.IPv4, device.ID)
+		logger.Infof("Deleted device %s (%s)", device.IPv4, device.ID)
 	}
 	
 	if len(errors) > 0 {
-		log.Printf("Deleted %d devices with %d errors", deletedCount, len(errors))
+		logger.Errorf("Deleted %d devices with %d errors", deletedCount, len(errors))
 		for _, errMsg := range errors {
-			log.Printf("Error: %s", errMsg)
+			logger.Errorf("Error: %s", errMsg)
 		}
 		return fmt.Errorf("deleted %d devices but encountered %d errors", deletedCount, len(errors))
 	}
 	
-	log.Printf("Successfully deleted all %d devices from network %s", deletedCount, networkID)
+	logger.Infof("Successfully deleted all %d devices from network %s", deletedCount, networkID)
 	return nil
 }
 
@@ -390,7 +597,7 @@ func (s *DeviceService) FindByIPv4(ipv4 string) (*models.Device, error) {
 		return nil, nil
 	}
 	if err != nil {
-		log.Printf("Error finding device with IPv4 %s: %v", ipv4, err)
+		logger.Errorf("Error finding device with IPv4 %s: %v", ipv4, err)
 		return nil, err
 	}
 	return device, nil
@@ -452,12 +659,12 @@ func (s *DeviceService) FindAllForNetwork(cidr string) ([]models.Device, error)
 			})
 
 			if err != nil {
-				log.Printf("Error updating device network ID: %v", err)
+				logger.Errorf("Error updating device network ID: %v", err)
 			} else {
 				deviceValues = append(deviceValues, *d)
 			}
 		} else {
-			log.Printf("Skipping device %s (network ID mismatch)", d.IPv4)
+			logger.Debugf("Skipping device %s (network ID mismatch)", d.IPv4)
 		}
 	}
 
@@ -516,7 +723,7 @@ func (s *DeviceService) FindOnlineDevicesForNetwork(cidr string) ([]models.Devic
 			})
 
 			if err != nil {
-				log.Printf("Error updating device network ID: %v", err)
+				logger.Errorf("Error updating device network ID: %v", err)
 			}
 			shouldInclude = true
 		}
@@ -527,7 +734,7 @@ func (s *DeviceService) FindOnlineDevicesForNetwork(cidr string) ([]models.Devic
 	}
 
 	sortDevicesByIP(deviceValues)
-	log.Printf("Filtered to %d active devices (online/idle)", len(deviceValues))
+	logger.Debugf("Filtered to %d active devices (online/idle)", len(deviceValues))
 	return deviceValues, nil
 }
 
@@ -543,6 +750,11 @@ func (s *DeviceService) isNetworkOrBroadcastAddress(ipStr, cidrStr string) bool
 		return false // Can't parse CIDR, include the IP
 	}
 
+	// /31 and /32 networks have no network or broadcast address, every one is a host
+	if ones, bits := network.Mask.Size(); bits == 32 && ones >= 31 {
+		return false
+	}
+
 	// Check if it's the network address
 	if ip.Equal(network.IP) {
 		return true
@@ -569,8 +781,10 @@ func (s *DeviceService) UpdateDeviceStatuses() error {
 	defer cancel()
 
 	// Use DB manager to serialize database access
-	// Device status transitions: online -> idle after 1 minute, idle/online -> offline after 3 minutes
-	return s.dbManager.UpdateDeviceStatuses(s.repository, ctx, 3*time.Minute)
+	// Device status transitions: online -> idle after IdleAfter, idle/online -> offline
+	// after OfflineAfter, 1 and 3 minutes by default
+	tuning := s.Config.Tuning()
+	return s.dbManager.UpdateDeviceStatuses(s.repository, ctx, tuning.IdleAfter, tuning.OfflineAfter)
 }
 
 // PerformDeviceFingerprinting analyzes device characteristics to determine type and OS
@@ -598,38 +812,33 @@ func (s *DeviceService) UpdateDevice(deviceID string, name *string, comment *str
 }
 
 func (s *DeviceService) PerformDeviceFingerprinting(device *models.Device) {
-	log.Printf("Starting device fingerprinting for %s", device.IPv4)
+	logger.Debugf("Starting device fingerprinting for %s", device.IPv4)
 	s.fingerprintService.AnalyzeDevice(device)
 }
 
+// FingerprintService returns the fingerprinter of the device service, so the
+// other stages share its settings
+func (s *DeviceService) FingerprintService() *fingerprint.FingerprintService {
+	return s.fingerprintService
+}
+
+// PerformDeviceFingerprintingWith fingerprints a device with the OS detection
+// setting and timing of a scan profile
+func (s *DeviceService) PerformDeviceFingerprintingWith(ctx context.Context, device *models.Device, profile *models.ScanProfile) {
+	s.fingerprintService.AnalyzeProfile(ctx, device, profile)
+}
+
 // CleanupAllDeviceNames clears the names of all devices in the database
 // IPv6-specific methods
 func (s *DeviceService) FindDeviceByIPv6(ipv6Address string) (*models.Device, error) {
-	devices, err := s.FindAll()
+	device, err := s.repository.FindByIPv6(context.Background(), ipv6Address)
+	if err == db.ErrNotFound {
+		return nil, fmt.Errorf("device not found with IPv6 address: %s", ipv6Address)
+	}
 	if err != nil {
 		return nil, err
 	}
-	
-	for _, device := range devices {
-		if device.IPv6LinkLocal != nil && *device.IPv6LinkLocal == ipv6Address {
-			return device, nil
-		}
-		if device.IPv6UniqueLocal != nil && *device.IPv6UniqueLocal == ipv6Address {
-			return device, nil
-		}
-		if device.IPv6Global != nil && *device.IPv6Global == ipv6Address {
-			return device, nil
-		}
-		
-		// Check additional IPv6 addresses
-		for _, addr := range device.IPv6Addresses {
-			if addr == ipv6Address {
-				return device, nil
-			}
-		}
-	}
-	
-	return nil, fmt.Errorf("device not found with IPv6 address: %s", ipv6Address)
+	return device, nil
 }
 
 func (s *DeviceService) FindDeviceByMAC(macAddress string) (*models.Device, error) {
@@ -676,28 +885,45 @@ func (s *DeviceService) UpdateDeviceIPv6Addresses(deviceID string, ipv6Addresses
 	return err
 }
 
+// GetDevicesByIPv6Prefix returns the devices holding an unexpired address inside
+// an IPv6 prefix given in CIDR notation
 func (s *DeviceService) GetDevicesByIPv6Prefix(prefix string) ([]models.Device, error) {
-	devices, err := s.FindAll()
+	_, ipNet, err := net.ParseCIDR(prefix)
+	if err != nil || ipNet.IP.To4() != nil {
+		return nil, fmt.Errorf("invalid IPv6 prefix: %s", prefix)
+	}
+	
+	devices, err := s.repository.FindByIPv6Prefix(context.Background(), ipNet)
 	if err != nil {
 		return nil, err
 	}
 	
-	var result []models.Device
-	for _, device := range devices {
-		if device.HasIPv6() {
-			allAddresses := device.GetAllIPv6Addresses()
-			for _, addr := range allAddresses {
-				if strings.HasPrefix(addr, prefix) {
-					result = append(result, *device)
-					break
-				}
-			}
-		}
+	result := make([]models.Device, len(devices))
+	for i, device := range devices {
+		result[i] = *device
 	}
-	
 	return result, nil
 }
 
+// RecordIPv6Address notes that a device was seen using an IPv6 address, source tells
+// where the sighting came from (models.IPv6SourceNDP and friends)
+func (s *DeviceService) RecordIPv6Address(deviceID, address, source string) error {
+	return s.repository.RecordIPv6Address(context.Background(), deviceID, address, source, time.Now())
+}
+
+// GetIPv6Addresses returns every IPv6 address recorded for a device with its state
+func (s *DeviceService) GetIPv6Addresses(deviceID string) ([]models.DeviceIPv6Address, error) {
+	return s.repository.FindIPv6Addresses(context.Background(), deviceID)
+}
+
+// ExpireIPv6Addresses ages out IPv6 addresses devices stopped using. Privacy and
+// temporary addresses are replaced daily, so addresses turn stale after a day unseen
+// and are removed from their device after a week.
+func (s *DeviceService) ExpireIPv6Addresses() (int, error) {
+	now := time.Now()
+	return s.repository.ExpireIPv6Addresses(context.Background(), now.Add(-ipv6StaleAfter), now.Add(-ipv6ExpireAfter), now.Add(-ipv6PurgeAfter))
+}
+
 func (s *DeviceService) CreateDevice(device *models.Device) error {
 	// Generate ID if not set
 	if device.ID == "" {
@@ -714,6 +940,20 @@ func (s *DeviceService) CreateDevice(device *models.Device) error {
 	return err
 }
 
+// LookupVendor resolves the vendor of a MAC address from the OUI database
+func (s *DeviceService) LookupVendor(macAddress string) string {
+	if s.ouiService == nil || macAddress == "" {
+		return ""
+	}
+	return s.ouiService.LookupVendor(macAddress)
+}
+
+// ReplacePorts stores the ports a completed port scan found, an empty list clears
+// the ports of the device
+func (s *DeviceService) ReplacePorts(deviceID string, ports []models.Port) error {
+	return s.repository.ReplacePorts(context.Background(), deviceID, ports)
+}
+
 func (s *DeviceService) UpdateDeviceRecord(device *models.Device) error {
 	device.UpdatedAt = time.Now()
 	_, err := s.repository.CreateOrUpdate(context.Background(), device)
@@ -760,16 +1000,16 @@ func (s *DeviceService) CleanupNetworkBroadcastDevices() error {
 		
 		// Check if this device is a network/broadcast address
 		if s.isNetworkOrBroadcastAddress(device.IPv4, network.CIDR) {
-			log.Printf("Cleaning up network/broadcast device: %s", device.IPv4)
+			logger.Infof("Cleaning up network/broadcast device: %s", device.IPv4)
 			if err := s.repository.DeleteByID(ctx, device.ID); err != nil {
-				log.Printf("Failed to delete device %s: %v", device.IPv4, err)
+				logger.Errorf("Failed to delete device %s: %v", device.IPv4, err)
 			} else {
 				deletedCount++
 			}
 		}
 	}
 	
-	log.Printf("Cleaned up %d network/broadcast address devices", deletedCount)
+	logger.Infof("Cleaned up %d network/broadcast address devices", deletedCount)
 	return nil
 }
 
@@ -782,7 +1022,7 @@ func (s *DeviceService) CleanupAllDeviceNames() error {
 		return fmt.Errorf("failed to fetch devices: %v", err)
 	}
 	
-	log.Printf("Starting device name cleanup for %d devices", len(devices))
+	logger.Infof("Starting device name cleanup for %d devices", len(devices))
 	
 	// Update each device to clear the name
 	var errors []string
@@ -797,101 +1037,17 @@ func (s *DeviceService) CleanupAllDeviceNames() error {
 			continue
 		}
 		
-		log.Printf("Cleared name for device %s", device.IPv4)
-	}
-	
-	if len(errors) > 0 {
-		log.Printf("Device name cleanup completed with %d errors", len(errors))
-		for _, errMsg := range errors {
-			log.Printf("Error: %s", errMsg)
-		}
-		return fmt.Errorf("cleanup completed with %d errors", len(errors))
-	}
-	
-	log.Printf("Device name cleanup completed successfully for %d devices", len(devices))
-	return nil
-}
-
-// CleanupDuplicateDevices finds and removes duplicate devices with the same MAC address
-// Keeps the most recently updated device and preserves user-set names and comments
-func (s *DeviceService) CleanupDuplicateDevices() error {
-	ctx := context.Background()
-	
-	// Get all devices
-	devices, err := s.repository.FindAll(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to fetch devices: %v", err)
-	}
-	
-	log.Printf("Starting duplicate device cleanup for %d devices", len(devices))
-	
-	// Group devices by MAC address (skip devices without MAC)
-	macGroups := make(map[string][]*models.Device)
-	for _, device := range devices {
-		if device.MAC != nil && *device.MAC != "" {
-			macGroups[*device.MAC] = append(macGroups[*device.MAC], device)
-		}
-	}
-	
-	var deletedCount int
-	var errors []string
-	
-	// Process each MAC group
-	for mac, deviceGroup := range macGroups {
-		if len(deviceGroup) <= 1 {
-			continue // No duplicates
-		}
-		
-		log.Printf("Found %d devices with MAC %s", len(deviceGroup), mac)
-		
-		// Sort by UpdatedAt to find the most recent
-		sort.Slice(deviceGroup, func(i, j int) bool {
-			return deviceGroup[i].UpdatedAt.After(deviceGroup[j].UpdatedAt)
-		})
-		
-		keeper := deviceGroup[0] // Most recently updated
-		duplicates := deviceGroup[1:]
-		
-		// Preserve user-set data from duplicates
-		for _, duplicate := range duplicates {
-			if duplicate.Name != "" && keeper.Name == "" {
-				keeper.Name = duplicate.Name
-			}
-			if duplicate.Comment != nil && *duplicate.Comment != "" && 
-			   (keeper.Comment == nil || *keeper.Comment == "") {
-				keeper.Comment = duplicate.Comment
-			}
-		}
-		
-		// Update the keeper with preserved data
-		_, err := s.repository.CreateOrUpdate(ctx, keeper)
-		if err != nil {
-			errors = append(errors, fmt.Sprintf("Failed to update keeper device %s: %v", keeper.IPv4, err))
-			continue
-		}
-		
-		// Delete duplicates
-		for _, duplicate := range duplicates {
-			log.Printf("Removing duplicate device %s (MAC: %s, keeping %s)", 
-				duplicate.IPv4, mac, keeper.IPv4)
-			
-			err := s.repository.DeleteByID(ctx, duplicate.ID)
-			if err != nil {
-				errors = append(errors, fmt.Sprintf("Failed to delete duplicate device %s: %v", duplicate.IPv4, err))
-				continue
-			}
-			deletedCount++
-		}
+		logger.Infof("Cleared name for device %s", device.IPv4)
 	}
 	
 	if len(errors) > 0 {
-		log.Printf("Duplicate cleanup completed with %d duplicates removed and %d errors", deletedCount, len(errors))
+		logger.Errorf("Device name cleanup completed with %d errors", len(errors))
 		for _, errMsg := range errors {
-			log.Printf("Error: %s", errMsg)
+			logger.Errorf("Error: %s", errMsg)
 		}
 		return fmt.Errorf("cleanup completed with %d errors", len(errors))
 	}
 	
-	log.Printf("Duplicate device cleanup completed successfully, removed %d duplicates", deletedCount)
+	logger.Infof("Device name cleanup completed successfully for %d devices", len(devices))
 	return nil
 }
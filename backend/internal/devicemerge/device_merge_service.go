@@ -0,0 +1,241 @@
+package devicemerge
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/eventlog"
+	"reconya-ai/internal/logging"
+	"reconya-ai/models"
+)
+
+var logger = logging.For("devicemerge")
+
+// DeviceMergeService corrects how sightings were grouped into devices. Merges and
+// splits are recorded as operations that can be undone as long as no later operation
+// touched the same devices.
+type DeviceMergeService struct {
+	Repository      *db.DeviceOperationRepository
+	DeviceService   *device.DeviceService
+	EventLogService *eventlog.EventLogService
+	mutex           sync.Mutex
+}
+
+func NewDeviceMergeService(repository *db.DeviceOperationRepository, deviceService *device.DeviceService, eventLogService *eventlog.EventLogService) *DeviceMergeService {
+	return &DeviceMergeService{
+		Repository:      repository,
+		DeviceService:   deviceService,
+		EventLogService: eventLogService,
+	}
+}
+
+// Merge combines the removed device into the kept one, moving its ports, web
+// services, event logs, IPv6 addresses, names, comments, tags, groups and custom
+// field values
+func (s *DeviceMergeService) Merge(keptID, removedID string) (*models.DeviceOperation, error) {
+	if keptID == removedID {
+		return nil, fmt.Errorf("a device cannot be merged with itself")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	kept, err := s.findDevice(keptID)
+	if err != nil {
+		return nil, err
+	}
+	removed, err := s.findDevice(removedID)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := mergeDevices(kept, removed)
+	op := &models.DeviceOperation{
+		Type:          models.DeviceOperationMerge,
+		DeviceID:      kept.ID,
+		OtherDeviceID: removed.ID,
+		Description:   fmt.Sprintf("Merged device [%s] into [%s]", deviceLabel(removed), deviceLabel(kept)),
+		Snapshot:      &models.DeviceOperationSnapshot{Device: *kept, Removed: removed},
+	}
+	if err := s.Repository.Merge(context.Background(), op, merged); err != nil {
+		return nil, err
+	}
+
+	logger.Info(op.Description)
+	s.logEvent(models.DevicesMerged, op.Description, kept.ID)
+	return op, nil
+}
+
+// Split moves one MAC address of a device out to a new device at the same address
+func (s *DeviceMergeService) Split(deviceID, mac string) (*models.DeviceOperation, error) {
+	mac = strings.TrimSpace(mac)
+	if mac == "" {
+		return nil, fmt.Errorf("a MAC address is required")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	original, err := s.findDevice(deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	kept, created, err := splitDevice(original, mac, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	created.ID = db.GenerateID()
+	if created.Vendor == nil {
+		if vendor := s.DeviceService.LookupVendor(mac); vendor != "" {
+			created.Vendor = &vendor
+		}
+	}
+
+	op := &models.DeviceOperation{
+		Type:          models.DeviceOperationSplit,
+		DeviceID:      original.ID,
+		OtherDeviceID: created.ID,
+		MAC:           *created.MAC,
+		Description:   fmt.Sprintf("Split MAC %s off device [%s]", *created.MAC, deviceLabel(original)),
+		Snapshot:      &models.DeviceOperationSnapshot{Device: *original},
+	}
+	if err := s.Repository.Split(context.Background(), op, kept, created); err != nil {
+		return nil, err
+	}
+
+	logger.Info(op.Description)
+	s.logEvent(models.DeviceSplit, op.Description, original.ID)
+	s.logEvent(models.DeviceSplit, op.Description, created.ID)
+	return op, nil
+}
+
+// Undo reverts an operation. Operations are undone newest first, an operation cannot
+// be undone while a later one that touched the same devices is still in effect.
+func (s *DeviceMergeService) Undo(operationID string) (*models.DeviceOperation, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ctx := context.Background()
+	op, err := s.Repository.FindByID(ctx, operationID)
+	if err != nil {
+		return nil, err
+	}
+	if op.UndoneAt != nil {
+		return nil, fmt.Errorf("operation was already undone")
+	}
+	if op.Snapshot == nil {
+		return nil, fmt.Errorf("operation has no snapshot to undo from")
+	}
+
+	operations, err := s.Repository.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, later := range operations {
+		if later.ID == op.ID || later.UndoneAt != nil || !later.CreatedAt.After(op.CreatedAt) {
+			continue
+		}
+		if touches(later, op.DeviceID) || touches(later, op.OtherDeviceID) {
+			return nil, fmt.Errorf("undo the later operation %q first", later.Description)
+		}
+	}
+
+	switch op.Type {
+	case models.DeviceOperationMerge:
+		err = s.Repository.UndoMerge(ctx, op)
+	case models.DeviceOperationSplit:
+		err = s.Repository.UndoSplit(ctx, op)
+	default:
+		err = fmt.Errorf("unknown operation type %q", op.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	description := "Undid: " + op.Description
+	logger.Info(description)
+	s.logEvent(models.DeviceOperationUndone, description, op.DeviceID)
+	if op.Type == models.DeviceOperationMerge {
+		s.logEvent(models.DeviceOperationUndone, description, op.OtherDeviceID)
+	}
+	return op, nil
+}
+
+// ListOperations returns the recorded operations newest first, optionally only those
+// involving one device
+func (s *DeviceMergeService) ListOperations(deviceID string) ([]*models.DeviceOperation, error) {
+	return s.Repository.List(context.Background(), deviceID)
+}
+
+// MergeDuplicates merges devices that share a MAC address into the one updated most
+// recently. Each merge is recorded and can be undone like a manual one.
+func (s *DeviceMergeService) MergeDuplicates() ([]*models.DeviceOperation, error) {
+	devices, err := s.DeviceService.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch devices: %v", err)
+	}
+
+	groups := make(map[string][]*models.Device)
+	for _, d := range devices {
+		if d.MAC != nil && *d.MAC != "" {
+			mac := strings.ToLower(*d.MAC)
+			groups[mac] = append(groups[mac], d)
+		}
+	}
+
+	var operations []*models.DeviceOperation
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].UpdatedAt.After(group[j].UpdatedAt)
+		})
+		for _, duplicate := range group[1:] {
+			op, err := s.Merge(group[0].ID, duplicate.ID)
+			if err != nil {
+				return operations, err
+			}
+			operations = append(operations, op)
+		}
+	}
+	return operations, nil
+}
+
+func (s *DeviceMergeService) findDevice(id string) (*models.Device, error) {
+	d, err := s.DeviceService.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, fmt.Errorf("device %s: %w", id, db.ErrNotFound)
+	}
+	return d, nil
+}
+
+func (s *DeviceMergeService) logEvent(eventType models.EEventLogType, description, deviceID string) {
+	if s.EventLogService == nil {
+		return
+	}
+	if err := s.EventLogService.Log(eventType, description, deviceID); err != nil {
+		logger.Errorf("Failed to log %s event: %v", eventType, err)
+	}
+}
+
+func touches(op *models.DeviceOperation, deviceID string) bool {
+	return op.DeviceID == deviceID || op.OtherDeviceID == deviceID
+}
+
+func deviceLabel(d *models.Device) string {
+	if d.Name != "" {
+		return d.Name + " " + d.IPv4
+	}
+	return d.IPv4
+}
@@ -0,0 +1,240 @@
+package devicemerge
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"reconya-ai/internal/util"
+	"reconya-ai/models"
+)
+
+// mergeDevices combines two devices into the kept one. The address, MAC and status
+// come from whichever device was seen last, the other MAC is remembered as a previous
+// MAC. Names and other details of the kept device win, gaps are filled from the
+// removed device and ports, web services and IPv6 addresses are combined.
+func mergeDevices(kept, removed *models.Device) *models.Device {
+	merged := *kept
+	if seenAfter(removed, kept) {
+		merged.IPv4 = removed.IPv4
+		merged.MAC = removed.MAC
+		merged.Vendor = removed.Vendor
+		merged.NetworkID = removed.NetworkID
+		merged.Status = removed.Status
+		merged.LastSeenOnlineAt = removed.LastSeenOnlineAt
+	}
+
+	if merged.Name == "" {
+		merged.Name = removed.Name
+	}
+	merged.Comment = mergeComments(kept.Comment, removed.Comment)
+	if merged.Hostname == nil {
+		merged.Hostname = removed.Hostname
+	}
+	if merged.DeviceType == "" || merged.DeviceType == models.DeviceTypeUnknown {
+		merged.DeviceType = removed.DeviceType
+	}
+	if merged.OS == nil {
+		merged.OS = removed.OS
+	}
+	if merged.UPnP == nil {
+		merged.UPnP = removed.UPnP
+	}
+	if merged.SNMP == nil {
+		merged.SNMP = removed.SNMP
+	}
+	if merged.SwitchPort == nil {
+		merged.SwitchPort = removed.SwitchPort
+	}
+	if merged.IPv6LinkLocal == nil {
+		merged.IPv6LinkLocal = removed.IPv6LinkLocal
+	}
+	if merged.IPv6UniqueLocal == nil {
+		merged.IPv6UniqueLocal = removed.IPv6UniqueLocal
+	}
+	if merged.IPv6Global == nil {
+		merged.IPv6Global = removed.IPv6Global
+	}
+	merged.IPv6Addresses = unionStrings(kept.IPv6Addresses, removed.IPv6Addresses)
+
+	// A decision already made about either device is kept
+	if merged.TrustState == "" || merged.TrustState == models.TrustStateNew {
+		merged.TrustState = removed.TrustState
+	}
+
+	merged.Ports = append([]models.Port(nil), kept.Ports...)
+	for _, port := range removed.Ports {
+		if !hasPort(merged.Ports, port) {
+			merged.Ports = append(merged.Ports, port)
+		}
+	}
+	merged.WebServices = append([]models.WebService(nil), kept.WebServices...)
+	for _, ws := range removed.WebServices {
+		if !hasWebService(merged.WebServices, ws.URL) {
+			merged.WebServices = append(merged.WebServices, ws)
+		}
+	}
+
+	merged.Identity = mergeIdentities(&merged, kept, removed)
+	return &merged
+}
+
+func mergeIdentities(merged, kept, removed *models.Device) *models.DeviceIdentity {
+	identity := models.DeviceIdentity{}
+	if kept.Identity != nil {
+		identity = *kept.Identity
+	}
+	if removed.Identity != nil {
+		if identity.MDNSName == "" {
+			identity.MDNSName = removed.Identity.MDNSName
+		}
+		if identity.DHCPClientID == "" {
+			identity.DHCPClientID = removed.Identity.DHCPClientID
+		}
+	}
+
+	var macs []string
+	for _, d := range []*models.Device{kept, removed} {
+		if d.MAC != nil && *d.MAC != "" {
+			macs = append(macs, *d.MAC)
+		}
+		if d.Identity != nil {
+			macs = append(macs, d.Identity.PreviousMACs...)
+		}
+	}
+	identity.PreviousMACs = nil
+	identity.MACRandomized = false
+	for _, mac := range macs {
+		if merged.MAC != nil && strings.EqualFold(mac, *merged.MAC) {
+			continue
+		}
+		if !containsFold(identity.PreviousMACs, mac) {
+			identity.PreviousMACs = append(identity.PreviousMACs, mac)
+		}
+	}
+	if merged.MAC != nil {
+		identity.MACRandomized = util.IsRandomizedMAC(*merged.MAC)
+	}
+
+	if !identity.MACRandomized && identity.MDNSName == "" && identity.DHCPClientID == "" &&
+		len(identity.PreviousMACs) == 0 && identity.Correlation == nil {
+		return nil
+	}
+	return &identity
+}
+
+// splitDevice moves one MAC of a device out to a new device at the same address. The
+// original device keeps its history. When the split MAC is the current one, the new
+// device takes over the online state and the original device falls back to the MAC
+// it was seen with before.
+func splitDevice(device *models.Device, mac string, now time.Time) (*models.Device, *models.Device, error) {
+	var previous []string
+	if device.Identity != nil {
+		previous = device.Identity.PreviousMACs
+	}
+	isCurrent := device.MAC != nil && strings.EqualFold(*device.MAC, mac)
+	if !isCurrent && !containsFold(previous, mac) {
+		return nil, nil, fmt.Errorf("device has never been seen with MAC %s", mac)
+	}
+	if len(previous) == 0 {
+		return nil, nil, fmt.Errorf("device has only been seen with one MAC address, there is nothing to split")
+	}
+
+	kept := *device
+	identity := *device.Identity
+	identity.PreviousMACs = nil
+	for _, p := range previous {
+		if !strings.EqualFold(p, mac) {
+			identity.PreviousMACs = append(identity.PreviousMACs, p)
+		}
+	}
+
+	splitMAC := mac
+	created := &models.Device{
+		IPv4:       device.IPv4,
+		MAC:        &splitMAC,
+		NetworkID:  device.NetworkID,
+		Status:     models.DeviceStatusOffline,
+		TrustState: models.TrustStateNew,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if util.IsRandomizedMAC(mac) {
+		created.Identity = &models.DeviceIdentity{MACRandomized: true}
+	}
+
+	if isCurrent {
+		created.Vendor = device.Vendor
+		created.Status = device.Status
+		created.LastSeenOnlineAt = device.LastSeenOnlineAt
+
+		last := identity.PreviousMACs[len(identity.PreviousMACs)-1]
+		identity.PreviousMACs = identity.PreviousMACs[:len(identity.PreviousMACs)-1]
+		kept.MAC = &last
+		kept.Vendor = nil
+		kept.Status = models.DeviceStatusOffline
+	}
+	if kept.MAC != nil {
+		identity.MACRandomized = util.IsRandomizedMAC(*kept.MAC)
+	}
+	kept.Identity = &identity
+	return &kept, created, nil
+}
+
+// seenAfter reports whether a was seen online more recently than b
+func seenAfter(a, b *models.Device) bool {
+	if a.LastSeenOnlineAt == nil {
+		return false
+	}
+	return b.LastSeenOnlineAt == nil || a.LastSeenOnlineAt.After(*b.LastSeenOnlineAt)
+}
+
+func mergeComments(a, b *string) *string {
+	switch {
+	case b == nil || *b == "":
+		return a
+	case a == nil || *a == "" || *a == *b:
+		return b
+	}
+	combined := *a + "\n" + *b
+	return &combined
+}
+
+func hasPort(ports []models.Port, port models.Port) bool {
+	for _, p := range ports {
+		if p.Number == port.Number && p.Protocol == port.Protocol {
+			return true
+		}
+	}
+	return false
+}
+
+func hasWebService(services []models.WebService, url string) bool {
+	for _, ws := range services {
+		if ws.URL == url {
+			return true
+		}
+	}
+	return false
+}
+
+func unionStrings(a, b []string) []string {
+	var result []string
+	for _, values := range [][]string{a, b} {
+		for _, v := range values {
+			if !containsFold(result, v) {
+				result = append(result, v)
+			}
+		}
+	}
+	return result
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package devicemerge
+
+import (
+	"testing"
+	"time"
+
+	"reconya-ai/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func strp(s string) *string { return &s }
+
+func TestMergeDevices(t *testing.T) {
+	earlier := time.Now().Add(-time.Hour)
+	later := time.Now()
+
+	kept := &models.Device{
+		ID:               "kept",
+		Name:             "Laptop",
+		Comment:          strp("desk 4"),
+		IPv4:             "10.0.0.5",
+		MAC:              strp("00:11:22:33:44:55"),
+		Status:           models.DeviceStatusOffline,
+		LastSeenOnlineAt: &earlier,
+		TrustState:       models.TrustStateNew,
+		Ports:            []models.Port{{Number: "22", Protocol: "tcp"}},
+		IPv6Addresses:    []string{"fe80::1"},
+	}
+	removed := &models.Device{
+		ID:               "removed",
+		Comment:          strp("wifi"),
+		IPv4:             "10.0.0.9",
+		MAC:              strp("8a:11:22:33:44:55"),
+		Hostname:         strp("laptop"),
+		Status:           models.DeviceStatusOnline,
+		LastSeenOnlineAt: &later,
+		TrustState:       models.TrustStateApproved,
+		Ports:            []models.Port{{Number: "22", Protocol: "tcp"}, {Number: "443", Protocol: "tcp"}},
+		IPv6Addresses:    []string{"FE80::1", "2001:db8::9"},
+		Identity:         &models.DeviceIdentity{MDNSName: "laptop.local", PreviousMACs: []string{"9e:00:00:00:00:01"}},
+	}
+
+	merged := mergeDevices(kept, removed)
+
+	assert.Equal(t, "kept", merged.ID)
+	assert.Equal(t, "Laptop", merged.Name)
+	assert.Equal(t, "desk 4\nwifi", *merged.Comment)
+	assert.Equal(t, "laptop", *merged.Hostname)
+	// The removed device was seen last, so its address and MAC are current
+	assert.Equal(t, "10.0.0.9", merged.IPv4)
+	assert.Equal(t, "8a:11:22:33:44:55", *merged.MAC)
+	assert.Equal(t, models.DeviceStatusOnline, merged.Status)
+	assert.Equal(t, models.TrustStateApproved, merged.TrustState)
+	assert.Len(t, merged.Ports, 2)
+	assert.Equal(t, []string{"fe80::1", "2001:db8::9"}, merged.IPv6Addresses)
+
+	require.NotNil(t, merged.Identity)
+	assert.True(t, merged.Identity.MACRandomized)
+	assert.Equal(t, "laptop.local", merged.Identity.MDNSName)
+	assert.Equal(t, []string{"00:11:22:33:44:55", "9e:00:00:00:00:01"}, merged.Identity.PreviousMACs)
+
+	// The inputs are left untouched
+	assert.Len(t, kept.Ports, 1)
+	assert.Nil(t, kept.Identity)
+}
+
+func TestSplitDevice(t *testing.T) {
+	now := time.Now()
+	device := &models.Device{
+		ID:       "phone",
+		IPv4:     "10.0.0.7",
+		MAC:      strp("aa:00:00:00:00:02"),
+		Vendor:   strp("Example"),
+		Status:   models.DeviceStatusOnline,
+		Identity: &models.DeviceIdentity{PreviousMACs: []string{"00:16:3e:00:00:01", "00:16:3e:00:00:02"}},
+	}
+
+	t.Run("PreviousMAC", func(t *testing.T) {
+		kept, created, err := splitDevice(device, "00:16:3E:00:00:01", now)
+		require.NoError(t, err)
+		assert.Equal(t, "aa:00:00:00:00:02", *kept.MAC)
+		assert.Equal(t, []string{"00:16:3e:00:00:02"}, kept.Identity.PreviousMACs)
+		assert.Equal(t, "10.0.0.7", created.IPv4)
+		assert.Equal(t, "00:16:3E:00:00:01", *created.MAC)
+		assert.Equal(t, models.DeviceStatusOffline, created.Status)
+		assert.Equal(t, models.TrustStateNew, created.TrustState)
+	})
+
+	t.Run("CurrentMAC", func(t *testing.T) {
+		kept, created, err := splitDevice(device, "aa:00:00:00:00:02", now)
+		require.NoError(t, err)
+		assert.Equal(t, "00:16:3e:00:00:02", *kept.MAC)
+		assert.Equal(t, models.DeviceStatusOffline, kept.Status)
+		assert.Equal(t, []string{"00:16:3e:00:00:01"}, kept.Identity.PreviousMACs)
+		assert.Equal(t, models.DeviceStatusOnline, created.Status)
+		assert.True(t, created.Identity.MACRandomized)
+	})
+
+	t.Run("UnknownMAC", func(t *testing.T) {
+		_, _, err := splitDevice(device, "00:00:00:00:00:99", now)
+		assert.Error(t, err)
+
+		single := &models.Device{MAC: strp("00:16:3e:00:00:01")}
+		_, _, err = splitDevice(single, "00:16:3e:00:00:01", now)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,156 @@
+package dhcp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"reconya-ai/models"
+)
+
+// Problem is an offer that does not match what is expected on the network
+type Problem struct {
+	Offer       models.DHCPOffer
+	Rogue       bool
+	Description string
+}
+
+// checkOffers compares the offers collected on a network with its allowlist. Without
+// an allowlist the server recorded by an earlier probe is expected, and when none
+// was recorded yet a single answering server is trusted. It returns the legitimate
+// offer, if any, and one problem per unexpected server or mismatching offer.
+func checkOffers(network *models.Network, offers []models.DHCPOffer) (*models.DHCPOffer, []Problem) {
+	expected := network.DHCPAllowlist
+	if len(expected) == 0 && network.DHCPServer != nil {
+		expected = []models.DHCPExpectedServer{expectationFromOffer(network.DHCPServer)}
+	}
+
+	var problems []Problem
+	if len(expected) == 0 {
+		servers := distinctServers(offers)
+		if len(servers) == 1 {
+			return &offers[0], nil
+		}
+		for _, offer := range offers {
+			problems = append(problems, Problem{
+				Offer:       offer,
+				Rogue:       true,
+				Description: fmt.Sprintf("%d DHCP servers (%s) answered on network %s, add the legitimate one to the allowlist", len(servers), strings.Join(servers, ", "), network.GetDisplayName()),
+			})
+		}
+		return nil, problems
+	}
+
+	var legitimate *models.DHCPOffer
+	for i, offer := range offers {
+		expectation := findExpectation(expected, offer.ServerIP)
+		if expectation == nil {
+			problems = append(problems, Problem{
+				Offer:       offer,
+				Rogue:       true,
+				Description: fmt.Sprintf("Rogue DHCP server %s%s offered %s on network %s (router %s, DNS %s)", offer.ServerIP, macSuffix(offer.ServerMAC), offer.OfferedIP, network.GetDisplayName(), listOrNone(offer.Routers), listOrNone(offer.DNSServers)),
+			})
+			continue
+		}
+
+		if mismatches := compareOffer(expectation, &offer); len(mismatches) > 0 {
+			problems = append(problems, Problem{
+				Offer:       offer,
+				Description: fmt.Sprintf("DHCP server %s on network %s offered unexpected %s", offer.ServerIP, network.GetDisplayName(), strings.Join(mismatches, ", ")),
+			})
+			continue
+		}
+		if legitimate == nil {
+			legitimate = &offers[i]
+		}
+	}
+	return legitimate, problems
+}
+
+// compareOffer describes each option of the offer that differs from the expectation
+func compareOffer(expected *models.DHCPExpectedServer, offer *models.DHCPOffer) []string {
+	var mismatches []string
+	if expected.ServerMAC != "" && offer.ServerMAC != "" && !strings.EqualFold(expected.ServerMAC, offer.ServerMAC) {
+		mismatches = append(mismatches, fmt.Sprintf("server MAC %s instead of %s", offer.ServerMAC, expected.ServerMAC))
+	}
+	if expected.SubnetMask != "" && expected.SubnetMask != offer.SubnetMask {
+		mismatches = append(mismatches, fmt.Sprintf("subnet mask %s instead of %s", valueOrNone(offer.SubnetMask), expected.SubnetMask))
+	}
+	if len(expected.Routers) > 0 && !sameSet(expected.Routers, offer.Routers) {
+		mismatches = append(mismatches, fmt.Sprintf("router %s instead of %s", listOrNone(offer.Routers), strings.Join(expected.Routers, ", ")))
+	}
+	if len(expected.DNSServers) > 0 && !sameSet(expected.DNSServers, offer.DNSServers) {
+		mismatches = append(mismatches, fmt.Sprintf("DNS %s instead of %s", listOrNone(offer.DNSServers), strings.Join(expected.DNSServers, ", ")))
+	}
+	return mismatches
+}
+
+// expectationFromOffer turns a recorded offer into the options expected from then on
+func expectationFromOffer(offer *models.DHCPOffer) models.DHCPExpectedServer {
+	return models.DHCPExpectedServer{
+		ServerIP:   offer.ServerIP,
+		ServerMAC:  offer.ServerMAC,
+		SubnetMask: offer.SubnetMask,
+		Routers:    offer.Routers,
+		DNSServers: offer.DNSServers,
+	}
+}
+
+func findExpectation(expected []models.DHCPExpectedServer, serverIP string) *models.DHCPExpectedServer {
+	for i := range expected {
+		if expected[i].ServerIP == serverIP {
+			return &expected[i]
+		}
+	}
+	return nil
+}
+
+func distinctServers(offers []models.DHCPOffer) []string {
+	seen := make(map[string]bool)
+	var servers []string
+	for _, offer := range offers {
+		if !seen[offer.ServerIP] {
+			seen[offer.ServerIP] = true
+			servers = append(servers, offer.ServerIP)
+		}
+	}
+	sort.Strings(servers)
+	return servers
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	x := append([]string(nil), a...)
+	y := append([]string(nil), b...)
+	sort.Strings(x)
+	sort.Strings(y)
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func macSuffix(mac string) string {
+	if mac == "" {
+		return ""
+	}
+	return " (" + mac + ")"
+}
+
+func listOrNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ", ")
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
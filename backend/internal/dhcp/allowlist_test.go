@@ -0,0 +1,64 @@
+package dhcp
+
+import (
+	"testing"
+
+	"reconya-ai/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckOffers(t *testing.T) {
+	office := models.DHCPOffer{ServerIP: "192.168.1.1", ServerMAC: "00:11:22:33:44:55", SubnetMask: "255.255.255.0", Routers: []string{"192.168.1.1"}, DNSServers: []string{"192.168.1.1", "1.1.1.1"}}
+	rogue := models.DHCPOffer{ServerIP: "192.168.0.1", SubnetMask: "255.255.255.0", Routers: []string{"192.168.0.1"}}
+
+	t.Run("SingleServerIsTrustedWithoutAllowlist", func(t *testing.T) {
+		legitimate, problems := checkOffers(&models.Network{CIDR: "192.168.1.0/24"}, []models.DHCPOffer{office})
+		require.NotNil(t, legitimate)
+		assert.Equal(t, "192.168.1.1", legitimate.ServerIP)
+		assert.Empty(t, problems)
+	})
+
+	t.Run("SeveralServersWithoutAllowlist", func(t *testing.T) {
+		legitimate, problems := checkOffers(&models.Network{CIDR: "192.168.1.0/24"}, []models.DHCPOffer{office, rogue})
+		assert.Nil(t, legitimate)
+		require.Len(t, problems, 2)
+		assert.True(t, problems[0].Rogue)
+	})
+
+	t.Run("RecordedServerIsExpected", func(t *testing.T) {
+		network := &models.Network{CIDR: "192.168.1.0/24", DHCPServer: &office}
+		legitimate, problems := checkOffers(network, []models.DHCPOffer{rogue, office})
+		require.NotNil(t, legitimate)
+		assert.Equal(t, "192.168.1.1", legitimate.ServerIP)
+		require.Len(t, problems, 1)
+		assert.True(t, problems[0].Rogue)
+		assert.Contains(t, problems[0].Description, "Rogue DHCP server 192.168.0.1")
+	})
+
+	t.Run("OptionsAreCompared", func(t *testing.T) {
+		network := &models.Network{CIDR: "192.168.1.0/24", DHCPAllowlist: []models.DHCPExpectedServer{{
+			ServerIP:   "192.168.1.1",
+			DNSServers: []string{"1.1.1.1", "192.168.1.1"},
+		}}}
+		legitimate, problems := checkOffers(network, []models.DHCPOffer{office})
+		assert.NotNil(t, legitimate, "the order of DNS servers does not matter and unset options are not checked")
+		assert.Empty(t, problems)
+
+		network.DHCPAllowlist[0].Routers = []string{"192.168.1.254"}
+		network.DHCPAllowlist[0].ServerMAC = "00:11:22:33:44:66"
+		legitimate, problems = checkOffers(network, []models.DHCPOffer{office})
+		assert.Nil(t, legitimate)
+		require.Len(t, problems, 1)
+		assert.False(t, problems[0].Rogue)
+		assert.Contains(t, problems[0].Description, "router 192.168.1.1 instead of 192.168.1.254")
+		assert.Contains(t, problems[0].Description, "server MAC")
+	})
+
+	t.Run("NoOffers", func(t *testing.T) {
+		legitimate, problems := checkOffers(&models.Network{CIDR: "192.168.1.0/24"}, nil)
+		assert.Nil(t, legitimate)
+		assert.Empty(t, problems)
+	})
+}
@@ -0,0 +1,136 @@
+package dhcp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeVLAN = 0x8100
+	protocolUDP   = 17
+	serverPort    = 67
+)
+
+// ClientMessage is what a DHCP client tells about itself in a DISCOVER, REQUEST or
+// INFORM
+type ClientMessage struct {
+	MAC string
+	// ClientID is option 61 in hex, type byte first, empty when the client sent none
+	ClientID string
+	Hostname string
+	// RequestedIP is the address the client asks for or already holds, if any
+	RequestedIP string
+}
+
+// parseClientFrame returns the DHCP payload of an Ethernet frame carrying a UDP
+// packet for the DHCP server port
+func parseClientFrame(frame []byte) ([]byte, bool) {
+	if len(frame) < 14 {
+		return nil, false
+	}
+	offset := 12
+	etherType := binary.BigEndian.Uint16(frame[offset:])
+	if etherType == etherTypeVLAN && len(frame) >= 18 {
+		offset += 4
+		etherType = binary.BigEndian.Uint16(frame[offset:])
+	}
+	if etherType != etherTypeIPv4 {
+		return nil, false
+	}
+
+	ip := frame[offset+2:]
+	if len(ip) < 20 || ip[0]>>4 != 4 || ip[9] != protocolUDP {
+		return nil, false
+	}
+	// Fragments other than the first carry no UDP header
+	if binary.BigEndian.Uint16(ip[6:])&0x1fff != 0 {
+		return nil, false
+	}
+	headerLen := int(ip[0]&0x0f) * 4
+	if headerLen < 20 || len(ip) < headerLen+8 {
+		return nil, false
+	}
+	udp := ip[headerLen:]
+	if binary.BigEndian.Uint16(udp[2:]) != serverPort {
+		return nil, false
+	}
+	return udp[8:], true
+}
+
+// parseClientMessage reads a message a client sent to the servers. Offers and acks
+// from servers, and client messages without a MAC, are rejected.
+func parseClientMessage(packet []byte) (*ClientMessage, error) {
+	if len(packet) < headerLength+len(magicCookie) {
+		return nil, fmt.Errorf("packet too short")
+	}
+	if packet[0] != opRequest {
+		return nil, fmt.Errorf("not a DHCP request")
+	}
+	if packet[1] != 1 || packet[2] != 6 {
+		return nil, fmt.Errorf("not an Ethernet client")
+	}
+	if string(packet[headerLength:headerLength+4]) != string(magicCookie) {
+		return nil, fmt.Errorf("missing DHCP magic cookie")
+	}
+
+	mac := net.HardwareAddr(packet[28:34])
+	message := &ClientMessage{MAC: strings.ToUpper(mac.String())}
+	if clientIP := net.IP(packet[12:16]); !clientIP.IsUnspecified() {
+		message.RequestedIP = clientIP.String()
+	}
+
+	messageType := 0
+	err := forEachOption(packet[headerLength+4:], func(code byte, value []byte) {
+		switch code {
+		case optionMessageType:
+			if len(value) == 1 {
+				messageType = int(value[0])
+			}
+		case optionClientID:
+			if len(value) > 1 {
+				message.ClientID = hexID(value)
+			}
+		case optionHostname:
+			message.Hostname = strings.TrimRight(string(value), "\x00")
+		case optionRequestedIP:
+			if len(value) == 4 {
+				message.RequestedIP = net.IP(value).String()
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch messageType {
+	case messageDiscover, messageRequest, messageInform:
+	default:
+		return nil, fmt.Errorf("not a DHCP client message")
+	}
+	if isZero(mac) {
+		return nil, fmt.Errorf("no client MAC")
+	}
+	return message, nil
+}
+
+// hexID formats a client identifier the way DHCP servers log it, 01:aa:bb:...
+func hexID(value []byte) string {
+	parts := make([]string, len(value))
+	for i, b := range value {
+		parts[i] = hex.EncodeToString([]byte{b})
+	}
+	return strings.Join(parts, ":")
+}
+
+func isZero(mac net.HardwareAddr) bool {
+	for _, b := range mac {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package dhcp
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// listenClients reads the DHCP messages clients broadcast to the servers from a raw
+// socket until done is closed, on one interface or on every interface when iface is
+// nil. Opening the socket needs root or CAP_NET_RAW.
+func listenClients(iface *net.Interface, done <-chan bool, handle func(*ClientMessage)) error {
+	protocol := htons(etherTypeIPv4)
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW, int(protocol))
+	if err != nil {
+		return fmt.Errorf("failed to open DHCP listener socket: %v", err)
+	}
+	defer syscall.Close(fd)
+
+	if iface != nil {
+		if err := syscall.Bind(fd, &syscall.SockaddrLinklayer{Protocol: protocol, Ifindex: iface.Index}); err != nil {
+			return fmt.Errorf("failed to bind DHCP listener socket to %s: %v", iface.Name, err)
+		}
+	}
+
+	// Wake up every second to notice shutdown
+	timeout := syscall.Timeval{Sec: 1}
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &timeout); err != nil {
+		return fmt.Errorf("failed to set DHCP listener socket timeout: %v", err)
+	}
+
+	buffer := make([]byte, 1514)
+	for {
+		select {
+		case <-done:
+			return nil
+		default:
+		}
+
+		n, _, err := syscall.Recvfrom(fd, buffer, 0)
+		if err != nil {
+			if err == syscall.EAGAIN || err == syscall.EINTR {
+				continue
+			}
+			return fmt.Errorf("failed to read DHCP packet: %v", err)
+		}
+		payload, ok := parseClientFrame(buffer[:n])
+		if !ok {
+			continue
+		}
+		if message, err := parseClientMessage(payload); err == nil {
+			handle(message)
+		}
+	}
+}
+
+func htons(value uint16) uint16 {
+	return value<<8 | value>>8
+}
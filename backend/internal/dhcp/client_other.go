@@ -0,0 +1,12 @@
+//go:build !linux
+
+package dhcp
+
+import (
+	"fmt"
+	"net"
+)
+
+func listenClients(iface *net.Interface, done <-chan bool, handle func(*ClientMessage)) error {
+	return fmt.Errorf("listening for DHCP clients is only supported on Linux")
+}
@@ -0,0 +1,74 @@
+package dhcp
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildClientMessage(mac net.HardwareAddr, options ...byte) []byte {
+	packet := make([]byte, headerLength)
+	packet[0] = opRequest
+	packet[1] = 1
+	packet[2] = 6
+	copy(packet[28:34], mac)
+	packet = append(packet, magicCookie...)
+	packet = append(packet, options...)
+	return append(packet, optionEnd)
+}
+
+// frame wraps a DHCP payload in Ethernet, IPv4 and UDP headers, optionally VLAN tagged
+func frame(payload []byte, dstPort uint16, vlan bool) []byte {
+	f := make([]byte, 12)
+	if vlan {
+		f = append(f, 0x81, 0x00, 0x00, 0x0a)
+	}
+	f = append(f, 0x08, 0x00)
+	ip := make([]byte, 20)
+	ip[0] = 0x45
+	ip[9] = protocolUDP
+	udp := make([]byte, 8)
+	binary.BigEndian.PutUint16(udp[0:], 68)
+	binary.BigEndian.PutUint16(udp[2:], dstPort)
+	f = append(f, ip...)
+	f = append(f, udp...)
+	return append(f, payload...)
+}
+
+func TestParseClientMessage(t *testing.T) {
+	mac, _ := net.ParseMAC("8e:1a:2b:3c:4d:5e")
+	packet := buildClientMessage(mac,
+		optionMessageType, 1, messageRequest,
+		optionClientID, 7, 0x01, 0x8e, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e,
+		optionHostname, 5, 'a', 'n', 'n', 'a', 0,
+		optionRequestedIP, 4, 192, 168, 1, 23,
+	)
+
+	payload, ok := parseClientFrame(frame(packet, serverPort, true))
+	require.True(t, ok)
+	message, err := parseClientMessage(payload)
+	require.NoError(t, err)
+	assert.Equal(t, "8E:1A:2B:3C:4D:5E", message.MAC)
+	assert.Equal(t, "01:8e:1a:2b:3c:4d:5e", message.ClientID)
+	assert.Equal(t, "anna", message.Hostname)
+	assert.Equal(t, "192.168.1.23", message.RequestedIP)
+
+	_, ok = parseClientFrame(frame(packet, 68, false))
+	assert.False(t, ok, "only packets for the server port are read")
+
+	withoutID, err := parseClientMessage(buildClientMessage(mac, optionMessageType, 1, messageDiscover))
+	require.NoError(t, err)
+	assert.Empty(t, withoutID.ClientID)
+
+	_, err = parseClientMessage(buildOffer(1, optionMessageType, 1, messageOffer))
+	assert.Error(t, err, "server replies are not client messages")
+	_, err = parseClientMessage(buildClientMessage(mac, optionMessageType, 1, messageOffer))
+	assert.Error(t, err)
+	_, err = parseClientMessage(buildClientMessage(net.HardwareAddr{0, 0, 0, 0, 0, 0}, optionMessageType, 1, messageDiscover))
+	assert.Error(t, err)
+	_, err = parseClientMessage(buildClientMessage(mac, optionMessageType, 1, messageDiscover, optionClientID, 9, 1))
+	assert.Error(t, err, "truncated options are rejected")
+}
@@ -0,0 +1,245 @@
+package dhcp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/eventlog"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/network"
+	"reconya-ai/models"
+)
+
+var logger = logging.For("dhcp")
+
+// DHCPService probes the networks attached to the sensor for DHCP servers. Offers are
+// compared with each network's allowlist, unexpected servers and options raise
+// events and the legitimate server is recorded on the network.
+type DHCPService struct {
+	Repository      *db.DHCPRepository
+	NetworkService  *network.NetworkService
+	DeviceService   *device.DeviceService
+	EventLogService *eventlog.EventLogService
+	timeout         time.Duration
+	// reportInterval is how long to wait before reporting the same problem again
+	reportInterval time.Duration
+	reported       map[string]time.Time
+	results        map[string]*models.DHCPProbeResult
+	mutex          sync.Mutex
+}
+
+func NewDHCPService(repository *db.DHCPRepository, networkService *network.NetworkService, deviceService *device.DeviceService, eventLogService *eventlog.EventLogService) *DHCPService {
+	return &DHCPService{
+		Repository:      repository,
+		NetworkService:  networkService,
+		DeviceService:   deviceService,
+		EventLogService: eventLogService,
+		timeout:         5 * time.Second,
+		reportInterval:  time.Hour,
+		reported:        make(map[string]time.Time),
+		results:         make(map[string]*models.DHCPProbeResult),
+	}
+}
+
+// ProbeAll probes every IPv4 network attached to the sensor
+func (s *DHCPService) ProbeAll() {
+	networks, err := s.NetworkService.FindAll()
+	if err != nil {
+		logger.Errorf("Failed to load networks for DHCP probe: %v", err)
+		return
+	}
+	for i := range networks {
+		n := &networks[i]
+		if n.CIDR == "" || n.AddressFamily == models.AddressFamilyIPv6 {
+			continue
+		}
+		if _, err := localInterface(n); err != nil {
+			continue
+		}
+		if _, err := s.probe(n); err != nil {
+			logger.Errorf("DHCP probe on network %s failed: %v", n.CIDR, err)
+		}
+	}
+}
+
+// Probe sends a DHCP DISCOVER on one network and checks the offers it collects
+func (s *DHCPService) Probe(networkID string) (*models.DHCPProbeResult, error) {
+	n, err := s.findNetwork(networkID)
+	if err != nil {
+		return nil, err
+	}
+	return s.probe(n)
+}
+
+func (s *DHCPService) probe(n *models.Network) (*models.DHCPProbeResult, error) {
+	iface, err := localInterface(n)
+	if err != nil {
+		return nil, err
+	}
+	offers, err := discover(iface, s.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	result := s.Evaluate(n, offers)
+	result.Interface = iface.Name
+	return result, nil
+}
+
+// Evaluate checks the offers collected on a network against its allowlist, logs an
+// event for each problem and records the legitimate server on the network
+func (s *DHCPService) Evaluate(n *models.Network, offers []models.DHCPOffer) *models.DHCPProbeResult {
+	for i := range offers {
+		if offers[i].ServerMAC == "" {
+			offers[i].ServerMAC = s.serverMAC(offers[i].ServerIP)
+		}
+	}
+
+	legitimate, problems := checkOffers(n, offers)
+	result := &models.DHCPProbeResult{
+		NetworkID: n.ID,
+		Offers:    offers,
+		Problems:  []string{},
+		ProbedAt:  time.Now(),
+	}
+	if result.Offers == nil {
+		result.Offers = []models.DHCPOffer{}
+	}
+
+	for _, problem := range problems {
+		result.Problems = append(result.Problems, problem.Description)
+		if !s.shouldReport(n.ID + "|" + problem.Description) {
+			continue
+		}
+		logger.Warn(problem.Description)
+		eventType := models.DHCPOptionsMismatch
+		if problem.Rogue {
+			eventType = models.RogueDHCPServer
+		}
+		s.logEvent(eventType, problem.Description, s.serverDeviceID(problem.Offer))
+	}
+
+	if legitimate != nil {
+		if err := s.Repository.RecordServer(context.Background(), n.ID, legitimate); err != nil {
+			logger.Errorf("Failed to record DHCP server for network %s: %v", n.CIDR, err)
+		}
+	}
+
+	s.mutex.Lock()
+	s.results[n.ID] = result
+	s.mutex.Unlock()
+	return result
+}
+
+// Listen watches the DHCP messages clients broadcast until done is closed and hands
+// their client identifiers to the device service, which uses them to recognize
+// devices with randomized MACs. Without permission to open a raw socket nothing is
+// captured.
+func (s *DHCPService) Listen(done <-chan bool) {
+	if err := listenClients(nil, done, s.ObserveClient); err != nil {
+		logger.Warnf("DHCP client listener unavailable, client identifiers are not captured: %v", err)
+	}
+}
+
+// ObserveClient records the client identifier of a DHCP client message
+func (s *DHCPService) ObserveClient(message *ClientMessage) {
+	if message.ClientID == "" {
+		return
+	}
+	if err := s.DeviceService.RecordDHCPClientID(message.MAC, message.ClientID); err != nil {
+		logger.Errorf("Failed to record DHCP client identifier of %s: %v", message.MAC, err)
+	}
+}
+
+// LastResult returns the most recent probe result for a network, or nil before the
+// first probe
+func (s *DHCPService) LastResult(networkID string) *models.DHCPProbeResult {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.results[networkID]
+}
+
+// SetAllowlist replaces the DHCP servers expected on a network
+func (s *DHCPService) SetAllowlist(networkID string, servers []models.DHCPExpectedServer) error {
+	for i := range servers {
+		ip := net.ParseIP(servers[i].ServerIP)
+		if ip == nil || ip.To4() == nil {
+			return fmt.Errorf("invalid DHCP server address %q", servers[i].ServerIP)
+		}
+		servers[i].ServerIP = ip.String()
+		if servers[i].ServerMAC != "" {
+			mac, err := net.ParseMAC(servers[i].ServerMAC)
+			if err != nil {
+				return fmt.Errorf("invalid DHCP server MAC %q", servers[i].ServerMAC)
+			}
+			servers[i].ServerMAC = strings.ToUpper(mac.String())
+		}
+	}
+
+	n, err := s.findNetwork(networkID)
+	if err != nil {
+		return err
+	}
+	if err := s.Repository.SetAllowlist(context.Background(), n.ID, servers); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.reported = make(map[string]time.Time)
+	s.mutex.Unlock()
+	s.logEvent(models.NetworkUpdated, fmt.Sprintf("DHCP allowlist for network %s set to %d servers", n.GetDisplayName(), len(servers)), "")
+	return nil
+}
+
+func (s *DHCPService) findNetwork(networkID string) (*models.Network, error) {
+	n, err := s.NetworkService.FindByID(networkID)
+	if err != nil {
+		return nil, err
+	}
+	if n == nil {
+		return nil, fmt.Errorf("network %s: %w", networkID, db.ErrNotFound)
+	}
+	return n, nil
+}
+
+// serverMAC looks up the MAC of a DHCP server among the known devices
+func (s *DHCPService) serverMAC(serverIP string) string {
+	d, err := s.DeviceService.FindByIPv4(serverIP)
+	if err != nil || d == nil || d.MAC == nil {
+		return ""
+	}
+	return *d.MAC
+}
+
+func (s *DHCPService) serverDeviceID(offer models.DHCPOffer) string {
+	d, err := s.DeviceService.FindByIPv4(offer.ServerIP)
+	if err != nil || d == nil {
+		return ""
+	}
+	return d.ID
+}
+
+func (s *DHCPService) shouldReport(key string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if last, ok := s.reported[key]; ok && time.Since(last) < s.reportInterval {
+		return false
+	}
+	s.reported[key] = time.Now()
+	return true
+}
+
+func (s *DHCPService) logEvent(eventType models.EEventLogType, description, deviceID string) {
+	if s.EventLogService == nil {
+		return
+	}
+	if err := s.EventLogService.Log(eventType, description, deviceID); err != nil {
+		logger.Errorf("Failed to log %s event: %v", eventType, err)
+	}
+}
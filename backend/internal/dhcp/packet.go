@@ -0,0 +1,145 @@
+package dhcp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"reconya-ai/models"
+)
+
+const (
+	opRequest = 1
+	opReply   = 2
+
+	optionPad          = 0
+	optionSubnetMask   = 1
+	optionRouter       = 3
+	optionDNS          = 6
+	optionLeaseTime    = 51
+	optionHostname     = 12
+	optionRequestedIP  = 50
+	optionMessageType  = 53
+	optionServerID     = 54
+	optionParameters   = 55
+	optionClientID     = 61
+	optionEnd          = 255
+	messageDiscover    = 1
+	messageOffer       = 2
+	messageRequest     = 3
+	messageInform      = 8
+	headerLength       = 236
+	minimumPacketBytes = 300
+)
+
+var magicCookie = []byte{99, 130, 83, 99}
+
+// buildDiscover builds a DHCPDISCOVER asking servers to broadcast their offers, so the
+// probe receives them without holding an address
+func buildDiscover(xid uint32, mac net.HardwareAddr) []byte {
+	packet := make([]byte, headerLength, minimumPacketBytes)
+	packet[0] = opRequest
+	packet[1] = 1 // Ethernet
+	packet[2] = 6
+	binary.BigEndian.PutUint32(packet[4:], xid)
+	binary.BigEndian.PutUint16(packet[10:], 0x8000) // broadcast flag
+	copy(packet[28:44], mac)
+
+	packet = append(packet, magicCookie...)
+	packet = append(packet, optionMessageType, 1, messageDiscover)
+	packet = append(packet, optionParameters, 5, optionSubnetMask, optionRouter, optionDNS, optionLeaseTime, optionServerID)
+	packet = append(packet, optionEnd)
+	for len(packet) < minimumPacketBytes {
+		packet = append(packet, optionPad)
+	}
+	return packet
+}
+
+// parseOffer reads a DHCPOFFER answering the probe with the given transaction ID.
+// The server is taken from the server identifier option, falling back to the
+// address the offer came from.
+func parseOffer(packet []byte, xid uint32, from net.IP, now time.Time) (*models.DHCPOffer, error) {
+	if len(packet) < headerLength+len(magicCookie) {
+		return nil, fmt.Errorf("packet too short")
+	}
+	if packet[0] != opReply {
+		return nil, fmt.Errorf("not a DHCP reply")
+	}
+	if binary.BigEndian.Uint32(packet[4:]) != xid {
+		return nil, fmt.Errorf("transaction ID does not match")
+	}
+	if string(packet[headerLength:headerLength+4]) != string(magicCookie) {
+		return nil, fmt.Errorf("missing DHCP magic cookie")
+	}
+
+	offer := &models.DHCPOffer{
+		OfferedIP: net.IP(packet[16:20]).String(),
+		SeenAt:    now,
+	}
+	messageType := 0
+	err := forEachOption(packet[headerLength+4:], func(code byte, value []byte) {
+		switch code {
+		case optionMessageType:
+			if len(value) == 1 {
+				messageType = int(value[0])
+			}
+		case optionServerID:
+			if len(value) == 4 {
+				offer.ServerIP = net.IP(value).String()
+			}
+		case optionSubnetMask:
+			if len(value) == 4 {
+				offer.SubnetMask = net.IP(value).String()
+			}
+		case optionRouter:
+			offer.Routers = ipList(value)
+		case optionDNS:
+			offer.DNSServers = ipList(value)
+		case optionLeaseTime:
+			if len(value) == 4 {
+				offer.LeaseSeconds = int(binary.BigEndian.Uint32(value))
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if messageType != messageOffer {
+		return nil, fmt.Errorf("not a DHCPOFFER")
+	}
+	if offer.ServerIP == "" && from != nil {
+		offer.ServerIP = from.String()
+	}
+	return offer, nil
+}
+
+// forEachOption calls fn with the code and value of each option until the end option
+func forEachOption(options []byte, fn func(code byte, value []byte)) error {
+	for i := 0; i < len(options); {
+		code := options[i]
+		if code == optionEnd {
+			break
+		}
+		if code == optionPad {
+			i++
+			continue
+		}
+		if i+1 >= len(options) || i+2+int(options[i+1]) > len(options) {
+			return fmt.Errorf("truncated option %d", code)
+		}
+		value := options[i+2 : i+2+int(options[i+1])]
+		i += 2 + len(value)
+		fn(code, value)
+	}
+	return nil
+}
+
+func ipList(value []byte) []string {
+	var ips []string
+	for i := 0; i+4 <= len(value); i += 4 {
+		ips = append(ips, net.IP(value[i:i+4]).String())
+	}
+	return ips
+}
@@ -0,0 +1,73 @@
+package dhcp
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildOffer(xid uint32, options ...byte) []byte {
+	packet := make([]byte, headerLength)
+	packet[0] = opReply
+	binary.BigEndian.PutUint32(packet[4:], xid)
+	copy(packet[16:20], net.IPv4(192, 168, 1, 100).To4())
+	packet = append(packet, magicCookie...)
+	packet = append(packet, options...)
+	return append(packet, optionEnd)
+}
+
+func TestBuildDiscover(t *testing.T) {
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+	packet := buildDiscover(0xdeadbeef, mac)
+
+	assert.GreaterOrEqual(t, len(packet), minimumPacketBytes)
+	assert.Equal(t, byte(opRequest), packet[0])
+	assert.Equal(t, uint32(0xdeadbeef), binary.BigEndian.Uint32(packet[4:]))
+	assert.Equal(t, uint16(0x8000), binary.BigEndian.Uint16(packet[10:]), "offers are broadcast back")
+	assert.Equal(t, []byte(mac), packet[28:34])
+	assert.Equal(t, magicCookie, packet[headerLength:headerLength+4])
+	assert.Equal(t, []byte{optionMessageType, 1, messageDiscover}, packet[headerLength+4:headerLength+7])
+}
+
+func TestParseOffer(t *testing.T) {
+	now := time.Now()
+	packet := buildOffer(42,
+		optionMessageType, 1, messageOffer,
+		optionPad,
+		optionServerID, 4, 192, 168, 1, 1,
+		optionSubnetMask, 4, 255, 255, 255, 0,
+		optionRouter, 4, 192, 168, 1, 1,
+		optionDNS, 8, 1, 1, 1, 1, 8, 8, 8, 8,
+		optionLeaseTime, 4, 0, 0, 0x0e, 0x10,
+	)
+
+	offer, err := parseOffer(packet, 42, net.IPv4(192, 168, 1, 2), now)
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.1", offer.ServerIP, "the server identifier wins over the source address")
+	assert.Equal(t, "192.168.1.100", offer.OfferedIP)
+	assert.Equal(t, "255.255.255.0", offer.SubnetMask)
+	assert.Equal(t, []string{"192.168.1.1"}, offer.Routers)
+	assert.Equal(t, []string{"1.1.1.1", "8.8.8.8"}, offer.DNSServers)
+	assert.Equal(t, 3600, offer.LeaseSeconds)
+	assert.Equal(t, now, offer.SeenAt)
+
+	offer, err = parseOffer(buildOffer(42, optionMessageType, 1, messageOffer), 42, net.IPv4(10, 0, 0, 1), now)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", offer.ServerIP)
+
+	_, err = parseOffer(packet, 43, nil, now)
+	assert.Error(t, err, "answers to other clients are ignored")
+
+	_, err = parseOffer(buildOffer(42, optionMessageType, 1, 5), 42, nil, now)
+	assert.Error(t, err, "only offers are collected")
+
+	_, err = parseOffer(buildOffer(42, optionMessageType, 1, messageOffer, optionDNS, 8, 1, 1), 42, nil, now)
+	assert.Error(t, err)
+
+	_, err = parseOffer(packet[:100], 42, nil, now)
+	assert.Error(t, err)
+}
@@ -0,0 +1,77 @@
+package dhcp
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+
+	"reconya-ai/models"
+)
+
+// collectOffers broadcasts a DHCPDISCOVER on conn and returns the first offer of
+// each server that answers before the timeout
+func collectOffers(conn net.PacketConn, mac net.HardwareAddr, timeout time.Duration) ([]models.DHCPOffer, error) {
+	xid := rand.Uint32()
+	broadcast := &net.UDPAddr{IP: net.IPv4bcast, Port: 67}
+	if _, err := conn.WriteTo(buildDiscover(xid, mac), broadcast); err != nil {
+		return nil, fmt.Errorf("failed to send DHCP discover: %v", err)
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	var offers []models.DHCPOffer
+	seen := make(map[string]bool)
+	buffer := make([]byte, 1500)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				return offers, nil
+			}
+			return offers, fmt.Errorf("failed to read DHCP offer: %v", err)
+		}
+
+		var from net.IP
+		if udpAddr, ok := addr.(*net.UDPAddr); ok {
+			from = udpAddr.IP
+		}
+		offer, err := parseOffer(buffer[:n], xid, from, time.Now())
+		if err != nil || seen[offer.ServerIP] {
+			continue
+		}
+		seen[offer.ServerIP] = true
+		offers = append(offers, *offer)
+	}
+}
+
+// localInterface finds the interface with an address inside the network, which the
+// probe is sent from
+func localInterface(network *models.Network) (*net.Interface, error) {
+	_, ipNet, err := net.ParseCIDR(network.CIDR)
+	if err != nil {
+		return nil, fmt.Errorf("invalid network CIDR %s: %v", network.CIDR, err)
+	}
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for i := range interfaces {
+		iface := &interfaces[i]
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ip, ok := addr.(*net.IPNet); ok && ip.IP.To4() != nil && ipNet.Contains(ip.IP) {
+				return iface, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("network %s is not attached to this sensor", network.CIDR)
+}
@@ -0,0 +1,43 @@
+package dhcp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	"reconya-ai/models"
+)
+
+// discover sends a DHCPDISCOVER out of iface and collects the offers. The socket is
+// bound to the DHCP client port on that interface, which needs root or
+// CAP_NET_BIND_SERVICE and CAP_NET_RAW.
+func discover(iface *net.Interface, timeout time.Duration) ([]models.DHCPOffer, error) {
+	config := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var sockErr error
+			err := c.Control(func(fd uintptr) {
+				if sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); sockErr != nil {
+					return
+				}
+				if sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1); sockErr != nil {
+					return
+				}
+				sockErr = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, iface.Name)
+			})
+			if err != nil {
+				return err
+			}
+			return sockErr
+		},
+	}
+
+	conn, err := config.ListenPacket(context.Background(), "udp4", ":68")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DHCP client socket on %s: %v", iface.Name, err)
+	}
+	defer conn.Close()
+
+	return collectOffers(conn, iface.HardwareAddr, timeout)
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package dhcp
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"reconya-ai/models"
+)
+
+func discover(iface *net.Interface, timeout time.Duration) ([]models.DHCPOffer, error) {
+	return nil, fmt.Errorf("DHCP probing is only supported on Linux")
+}
@@ -3,13 +3,15 @@ package eventlog
 import (
 	"context"
 	"fmt"
-	"log"
 	"reconya-ai/db"
 	"reconya-ai/internal/device"
+	"reconya-ai/internal/logging"
 	"reconya-ai/models"
 	"time"
 )
 
+var logger = logging.For("eventlog")
+
 type EventLogService struct {
 	repository    db.EventLogRepository
 	DeviceService *device.DeviceService
@@ -45,7 +47,7 @@ func (s *EventLogService) generateDescription(eventLog models.EventLog) string {
 	if eventLog.DeviceID != nil {
 		device, err := s.DeviceService.FindByID(*eventLog.DeviceID)
 		if err != nil {
-			log.Printf("Error fetching device information: %v", err)
+			logger.Errorf("Error fetching device information: %v", err)
 		} else if device != nil && device.IPv4 != "" {
 			deviceInfo = device.IPv4
 		}
@@ -82,9 +84,27 @@ func (s *EventLogService) generateDescription(eventLog models.EventLog) string {
 		return eventLog.Description // Use the custom description for scan events
 	case models.ScanStopped:
 		return eventLog.Description // Use the custom description for scan events
+	case models.WakeOnLANSent, models.DeviceWoke, models.DeviceWakeFailed:
+		return eventLog.Description // Use the custom description for Wake-on-LAN events
+	case models.UnapprovedDeviceSeen, models.DeviceTrustChanged:
+		return eventLog.Description // Use the custom description for device trust events
+	case models.DevicesMerged, models.DeviceSplit, models.DeviceOperationUndone:
+		return eventLog.Description // Use the custom description for device merge and split events
+	case models.IPConflictDetected, models.MACClaimsManyIPs, models.GatewayMACChanged, models.GratuitousARPStorm:
+		return eventLog.Description // Use the custom description for ARP spoofing alerts
+	case models.RogueDHCPServer, models.DHCPOptionsMismatch:
+		return eventLog.Description // Use the custom description for DHCP probe alerts
+	case models.AgentEnrolled, models.AgentRevoked:
+		return eventLog.Description // Use the custom description for remote sensor agent events
 	case models.Warning:
+		if eventLog.Description != "" {
+			return eventLog.Description
+		}
 		return "Warning event occurred"
 	case models.Alert:
+		if eventLog.Description != "" {
+			return eventLog.Description
+		}
 		return "Alert event occurred"
 	default:
 		return fmt.Sprintf("System event: %s", string(eventLog.Type))
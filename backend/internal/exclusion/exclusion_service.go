@@ -0,0 +1,361 @@
+package exclusion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/util"
+	"reconya-ai/models"
+)
+
+var logger = logging.For("exclusion")
+
+// ErrExcluded is returned by scans an exclusion rule does not allow
+var ErrExcluded = errors.New("excluded by an exclusion rule")
+
+// ErrRulesUnavailable is returned while the rules have never been loaded, every
+// stage is skipped until they are
+var ErrRulesUnavailable = errors.New("exclusion rules are not loaded")
+
+// unavailable is what Blocked returns while the rules are not loaded
+var unavailable = &models.ExclusionRule{}
+
+// BlockedError wraps ErrExcluded with the rule that blocked a stage
+func BlockedError(rule *models.ExclusionRule, stage models.ExclusionStage) error {
+	if rule == unavailable {
+		return fmt.Errorf("%w, %s skipped: %v", ErrExcluded, stage, ErrRulesUnavailable)
+	}
+	return fmt.Errorf("%w, %s blocked by the %s", ErrExcluded, stage, rule.Summary())
+}
+
+// ExclusionService keeps scan stages away from the hosts matched by the exclusion
+// rules and audits the rules and what they blocked. Its checks accept a nil
+// service, which excludes nothing, so services leave their Exclusions field unset
+// for the CLI and tests. Without a repository, as on agents, the rules come from
+// SetRules and blocks are only logged.
+type ExclusionService struct {
+	Repository *db.ExclusionRepository
+	// RefreshInterval is how long loaded rules are used before they are read again,
+	// so rules following a device see its new address. Changing a rule reloads them
+	// right away.
+	RefreshInterval time.Duration
+
+	mutex    sync.Mutex
+	rules    *RuleSet
+	loaded   bool
+	loadedAt time.Time
+	changed  bool
+}
+
+func NewExclusionService(repository *db.ExclusionRepository) *ExclusionService {
+	return &ExclusionService{Repository: repository, RefreshInterval: time.Minute}
+}
+
+// Validate checks a rule and normalizes its address and stages
+func Validate(rule *models.ExclusionRule) error {
+	switch rule.Scope {
+	case models.ExclusionGlobal:
+		if rule.NetworkID != "" || rule.DeviceID != "" {
+			return fmt.Errorf("global rules have no network or device")
+		}
+		if strings.TrimSpace(rule.Address) == "" {
+			return fmt.Errorf("global rules need an address, range or MAC address")
+		}
+	case models.ExclusionNetwork:
+		if rule.NetworkID == "" || rule.DeviceID != "" {
+			return fmt.Errorf("network rules need a network and no device")
+		}
+	case models.ExclusionDevice:
+		if rule.DeviceID == "" || rule.NetworkID != "" {
+			return fmt.Errorf("device rules need a device and no network")
+		}
+		if rule.Address != "" {
+			return fmt.Errorf("device rules match the address and MAC of their device, leave the address empty")
+		}
+	default:
+		return fmt.Errorf("unknown scope %q, use global, network or device", rule.Scope)
+	}
+
+	rule.Address = strings.TrimSpace(rule.Address)
+	if rule.Address != "" {
+		if _, _, err := parseAddress(rule.Address); err != nil {
+			return err
+		}
+		if mac, err := net.ParseMAC(rule.Address); err == nil {
+			rule.Address = mac.String()
+		}
+	}
+
+	if len(rule.Stages) == 0 {
+		return fmt.Errorf("select at least one stage to block")
+	}
+	var stages []models.ExclusionStage
+	for _, stage := range models.ExclusionStages {
+		for _, requested := range rule.Stages {
+			if requested == stage {
+				stages = append(stages, stage)
+				break
+			}
+		}
+	}
+	for _, requested := range rule.Stages {
+		known := false
+		for _, stage := range models.ExclusionStages {
+			known = known || requested == stage
+		}
+		if !known {
+			return fmt.Errorf("unknown stage %q", requested)
+		}
+	}
+	rule.Stages = stages
+	return nil
+}
+
+// FindAll lists the rules, oldest first
+func (s *ExclusionService) FindAll() ([]*models.ExclusionRule, error) {
+	return s.Repository.FindAll(context.Background())
+}
+
+// FindAudit lists the audit trail of a rule, or of all rules when ruleID is empty
+func (s *ExclusionService) FindAudit(ruleID string, limit int) ([]*models.ExclusionAuditEntry, error) {
+	if limit <= 0 {
+		limit = 200
+	}
+	return s.Repository.FindAudit(context.Background(), ruleID, limit)
+}
+
+// Create adds a rule on behalf of actor
+func (s *ExclusionService) Create(rule *models.ExclusionRule, actor string) (*models.ExclusionRule, error) {
+	if err := Validate(rule); err != nil {
+		return nil, err
+	}
+	created, err := util.RetryOnLockWithResult(func() (*models.ExclusionRule, error) {
+		return s.Repository.Create(context.Background(), rule, actor)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate()
+	logger.Infof("%s added the %s", actor, created.Summary())
+	return created, nil
+}
+
+// Update replaces a rule on behalf of actor
+func (s *ExclusionService) Update(id string, rule *models.ExclusionRule, actor string) (*models.ExclusionRule, error) {
+	existing, err := s.Repository.FindByID(context.Background(), id)
+	if err != nil {
+		return nil, err
+	}
+	rule.ID = id
+	rule.CreatedBy = existing.CreatedBy
+	rule.CreatedAt = existing.CreatedAt
+	if err := Validate(rule); err != nil {
+		return nil, err
+	}
+	if err := util.RetryOnLock(func() error {
+		return s.Repository.Update(context.Background(), rule, actor)
+	}); err != nil {
+		return nil, err
+	}
+	s.invalidate()
+	logger.Infof("%s changed exclusion rule %s to the %s", actor, id, rule.Summary())
+	return rule, nil
+}
+
+// Delete removes a rule on behalf of actor, its audit trail stays
+func (s *ExclusionService) Delete(id, actor string) error {
+	if err := util.RetryOnLock(func() error {
+		return s.Repository.Delete(context.Background(), id, actor)
+	}); err != nil {
+		return err
+	}
+	s.invalidate()
+	logger.Infof("%s deleted exclusion rule %s", actor, id)
+	return nil
+}
+
+// SetRules replaces the rules of a service without a repository
+func (s *ExclusionService) SetRules(rules []*models.ExclusionRule) {
+	if s == nil {
+		return
+	}
+	set := NewRuleSet(rules)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.loaded || set.Len() != s.rules.Len() {
+		logger.Infof("Applying %d exclusion rules", set.Len())
+	}
+	s.rules = set
+	s.loaded = true
+}
+
+// invalidate makes the next check read the rules again
+func (s *ExclusionService) invalidate() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.changed = true
+}
+
+// Rules returns the current rules, read again once a rule changed or the refresh
+// interval passed. When they cannot be read the last ones are used, and before
+// they were ever loaded ErrRulesUnavailable is returned so callers scan nothing.
+func (s *ExclusionService) Rules() (*RuleSet, error) {
+	if s == nil {
+		return nil, nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.Repository == nil || (s.loaded && !s.changed && time.Since(s.loadedAt) < s.RefreshInterval) {
+		if !s.loaded {
+			return nil, ErrRulesUnavailable
+		}
+		return s.rules, nil
+	}
+
+	rules, err := util.RetryOnLockWithResult(func() ([]*models.ExclusionRule, error) {
+		return s.Repository.FindAll(context.Background())
+	})
+	if err != nil {
+		if !s.loaded {
+			logger.Errorf("Failed to load exclusion rules, skipping every stage: %v", err)
+			return nil, fmt.Errorf("%w: %v", ErrRulesUnavailable, err)
+		}
+		logger.Errorf("Failed to load exclusion rules, using the last ones: %v", err)
+		return s.rules, nil
+	}
+	s.rules = NewRuleSet(rules)
+	s.loaded = true
+	s.loadedAt = time.Now()
+	s.changed = false
+	return s.rules, nil
+}
+
+// Blocked returns the rule keeping a stage away from a host and audits it, nil
+// when the stage may run. Every stage is blocked while the rules are not loaded.
+func (s *ExclusionService) Blocked(stage models.ExclusionStage, ip, mac string) *models.ExclusionRule {
+	rules, err := s.Rules()
+	if err != nil {
+		return unavailable
+	}
+	rule := rules.Match(stage, ip, mac)
+	if rule != nil {
+		s.record(rule, stage, hostName(ip, mac))
+	}
+	return rule
+}
+
+// Filter drops the devices a stage may not report, such as hosts discovery found
+// before their MAC address was known. All are dropped while the rules are not loaded.
+func (s *ExclusionService) Filter(stage models.ExclusionStage, devices []models.Device) []models.Device {
+	rules, err := s.Rules()
+	if err != nil {
+		return devices[:0]
+	}
+	if rules.Len() == 0 {
+		return devices
+	}
+	kept := devices[:0]
+	for _, d := range devices {
+		mac := ""
+		if d.MAC != nil {
+			mac = *d.MAC
+		}
+		if rule := rules.Match(stage, d.IPv4, mac); rule != nil {
+			s.record(rule, stage, hostName(d.IPv4, mac))
+			continue
+		}
+		kept = append(kept, d)
+	}
+	return kept
+}
+
+// SkipFunc returns a check for scanners that go through the addresses themselves,
+// such as the native scanner. The rules are read once, when they are not loaded
+// every address is skipped.
+func (s *ExclusionService) SkipFunc(stage models.ExclusionStage) func(ip string) bool {
+	rules, err := s.Rules()
+	if err != nil {
+		return func(string) bool { return true }
+	}
+	return func(ip string) bool {
+		rule := rules.Match(stage, ip, "")
+		if rule != nil {
+			s.record(rule, stage, ip)
+		}
+		return rule != nil
+	}
+}
+
+// NmapArgs writes the addresses inside target that a stage must skip to a file
+// and returns the nmap --excludefile option for it, with a cleanup that removes
+// the file. There are no options when nothing in target is excluded, and an error
+// when the rules are not loaded.
+func (s *ExclusionService) NmapArgs(stage models.ExclusionStage, target string) ([]string, func(), error) {
+	rules, err := s.Rules()
+	if err != nil {
+		return nil, func() {}, err
+	}
+	exclusions, err := rules.Excluded(stage, target)
+	if err != nil || len(exclusions) == 0 {
+		return nil, func() {}, err
+	}
+	var cidrs []string
+	seen := make(map[string]bool)
+	for _, exclusion := range exclusions {
+		for _, cidr := range exclusion.CIDRs {
+			if !seen[cidr] {
+				seen[cidr] = true
+				cidrs = append(cidrs, cidr)
+			}
+		}
+	}
+
+	file, err := os.CreateTemp("", "reconya-exclude-*.txt")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create the nmap exclude file: %w", err)
+	}
+	cleanup := func() { os.Remove(file.Name()) }
+	if _, err := file.WriteString(strings.Join(cidrs, "\n") + "\n"); err != nil {
+		file.Close()
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to write the nmap exclude file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to write the nmap exclude file: %w", err)
+	}
+
+	for _, exclusion := range exclusions {
+		s.record(exclusion.Rule, stage, strings.Join(exclusion.CIDRs, ","))
+	}
+	logger.Debugf("Excluding %s from the %s of %s", strings.Join(cidrs, ", "), stage, target)
+	return []string{"--excludefile", file.Name()}, cleanup, nil
+}
+
+// record audits a block, repeated blocks of the same address add to one entry
+func (s *ExclusionService) record(rule *models.ExclusionRule, stage models.ExclusionStage, target string) {
+	logger.Debugf("Exclusion rule %s kept %s away from %s", rule.ID, stage, target)
+	if s == nil || s.Repository == nil {
+		return
+	}
+	if err := util.RetryOnLock(func() error {
+		return s.Repository.RecordBlock(context.Background(), rule.ID, stage, target)
+	}); err != nil {
+		logger.Errorf("Failed to audit exclusion rule %s: %v", rule.ID, err)
+	}
+}
+
+func hostName(ip, mac string) string {
+	if ip == "" {
+		return mac
+	}
+	return ip
+}
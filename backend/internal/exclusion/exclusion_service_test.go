@@ -0,0 +1,99 @@
+package exclusion
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"reconya-ai/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	rule := &models.ExclusionRule{
+		Scope:   models.ExclusionGlobal,
+		Address: " AA-BB-CC-00-00-01 ",
+		Stages:  []models.ExclusionStage{models.ExcludeWeb, models.ExcludeDiscovery},
+	}
+	require.NoError(t, Validate(rule))
+	assert.Equal(t, "aa:bb:cc:00:00:01", rule.Address)
+	assert.Equal(t, []models.ExclusionStage{models.ExcludeDiscovery, models.ExcludeWeb}, rule.Stages)
+
+	invalid := []*models.ExclusionRule{
+		{Scope: models.ExclusionGlobal, Stages: []models.ExclusionStage{models.ExcludeWeb}},
+		{Scope: models.ExclusionGlobal, Address: "10.0.0.1", NetworkID: "n1", Stages: []models.ExclusionStage{models.ExcludeWeb}},
+		{Scope: models.ExclusionGlobal, Address: "10.0.0.300", Stages: []models.ExclusionStage{models.ExcludeWeb}},
+		{Scope: models.ExclusionGlobal, Address: "10.0.0.9-1", Stages: []models.ExclusionStage{models.ExcludeWeb}},
+		{Scope: models.ExclusionGlobal, Address: "10.0.0.1"},
+		{Scope: models.ExclusionGlobal, Address: "10.0.0.1", Stages: []models.ExclusionStage{"traceroute"}},
+		{Scope: models.ExclusionNetwork, Stages: []models.ExclusionStage{models.ExcludeWeb}},
+		{Scope: models.ExclusionDevice, DeviceID: "d1", Address: "10.0.0.1", Stages: []models.ExclusionStage{models.ExcludeWeb}},
+		{Scope: "site", Address: "10.0.0.1", Stages: []models.ExclusionStage{models.ExcludeWeb}},
+	}
+	for _, rule := range invalid {
+		assert.Error(t, Validate(rule), "%+v", rule)
+	}
+}
+
+func TestExclusionService_WithoutRepository(t *testing.T) {
+	var disabled *ExclusionService
+	args, cleanup, err := disabled.NmapArgs(models.ExcludeDiscovery, "10.0.0.0/24")
+	require.NoError(t, err)
+	cleanup()
+	assert.Nil(t, args)
+	assert.Nil(t, disabled.Blocked(models.ExcludePortScan, "10.0.0.5", ""))
+
+	service := NewExclusionService(nil)
+	service.SetRules([]*models.ExclusionRule{
+		{ID: "pumps", Scope: models.ExclusionGlobal, Address: "10.0.0.8-11", Stages: []models.ExclusionStage{models.ExcludeDiscovery, models.ExcludePortScan}},
+	})
+
+	args, cleanup, err = service.NmapArgs(models.ExcludeDiscovery, "10.0.0.0/24")
+	require.NoError(t, err)
+	require.Len(t, args, 2)
+	assert.Equal(t, "--excludefile", args[0])
+	content, err := os.ReadFile(args[1])
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.8/30\n", string(content))
+	cleanup()
+	_, err = os.Stat(args[1])
+	assert.True(t, os.IsNotExist(err), "cleanup removes the exclude file")
+
+	args, _, err = service.NmapArgs(models.ExcludeDiscovery, "10.0.1.0/24")
+	require.NoError(t, err)
+	assert.Nil(t, args, "no option when nothing in the target is excluded")
+
+	skip := service.SkipFunc(models.ExcludeDiscovery)
+	assert.True(t, skip("10.0.0.9"))
+	assert.False(t, skip("10.0.0.12"))
+
+	mac := "aa:bb:cc:00:00:01"
+	devices := service.Filter(models.ExcludeDiscovery, []models.Device{{IPv4: "10.0.0.7"}, {IPv4: "10.0.0.10", MAC: &mac}})
+	require.Len(t, devices, 1)
+	assert.Equal(t, "10.0.0.7", devices[0].IPv4)
+
+	rule := service.Blocked(models.ExcludePortScan, "10.0.0.11", "")
+	require.NotNil(t, rule)
+	err = BlockedError(rule, models.ExcludePortScan)
+	assert.True(t, errors.Is(err, ErrExcluded))
+	assert.Nil(t, service.Blocked(models.ExcludeWeb, "10.0.0.11", ""))
+}
+
+func TestExclusionService_FailsClosedBeforeRulesLoad(t *testing.T) {
+	service := NewExclusionService(nil)
+
+	_, err := service.Rules()
+	assert.ErrorIs(t, err, ErrRulesUnavailable)
+	rule := service.Blocked(models.ExcludeWeb, "10.0.0.5", "")
+	require.NotNil(t, rule)
+	assert.ErrorIs(t, BlockedError(rule, models.ExcludeWeb), ErrExcluded)
+	assert.Empty(t, service.Filter(models.ExcludeDiscovery, []models.Device{{IPv4: "10.0.0.5"}}))
+	assert.True(t, service.SkipFunc(models.ExcludeDiscovery)("10.0.0.5"))
+	_, _, err = service.NmapArgs(models.ExcludeDiscovery, "10.0.0.0/24")
+	assert.ErrorIs(t, err, ErrRulesUnavailable)
+
+	service.SetRules(nil)
+	assert.Nil(t, service.Blocked(models.ExcludeWeb, "10.0.0.5", ""), "an empty rule set excludes nothing")
+}
@@ -0,0 +1,228 @@
+package exclusion
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+
+	"reconya-ai/internal/util"
+	"reconya-ai/models"
+)
+
+// ipRange is an inclusive range of IPv4 addresses
+type ipRange struct {
+	first, last uint32
+}
+
+func (r ipRange) contains(ip uint32) bool {
+	return ip >= r.first && ip <= r.last
+}
+
+// intersect returns the addresses in both ranges
+func (r ipRange) intersect(other ipRange) (ipRange, bool) {
+	first, last := max(r.first, other.first), min(r.last, other.last)
+	return ipRange{first, last}, first <= last
+}
+
+// entry is a rule turned into the addresses and MAC it matches
+type entry struct {
+	rule   *models.ExclusionRule
+	ranges []ipRange
+	mac    string
+	// within limits network rules to their network
+	within *ipRange
+}
+
+// RuleSet matches hosts against the exclusion rules. A nil set excludes nothing.
+type RuleSet struct {
+	entries []entry
+}
+
+// NewRuleSet compiles the rules, those that cannot be matched, such as device
+// rules whose device is gone, are left out
+func NewRuleSet(rules []*models.ExclusionRule) *RuleSet {
+	set := &RuleSet{}
+	for _, rule := range rules {
+		e, err := compile(rule)
+		if err != nil {
+			logger.Warnf("Ignoring exclusion rule %s: %v", rule.ID, err)
+			continue
+		}
+		set.entries = append(set.entries, e)
+	}
+	return set
+}
+
+// Len is the number of rules in the set
+func (s *RuleSet) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.entries)
+}
+
+func compile(rule *models.ExclusionRule) (entry, error) {
+	e := entry{rule: rule}
+	switch rule.Scope {
+	case models.ExclusionDevice:
+		if ip, ok := parseIPv4(rule.DeviceIPv4); ok {
+			e.ranges = append(e.ranges, ipRange{ip, ip})
+		}
+		if mac, err := net.ParseMAC(rule.DeviceMAC); err == nil {
+			e.mac = mac.String()
+		}
+		if len(e.ranges) == 0 && e.mac == "" {
+			return e, fmt.Errorf("device %s has no address", rule.DeviceID)
+		}
+		return e, nil
+
+	case models.ExclusionNetwork:
+		network, err := parseCIDR(rule.NetworkCIDR)
+		if err != nil {
+			return e, fmt.Errorf("network %s has no IPv4 CIDR", rule.NetworkID)
+		}
+		e.within = &network
+		if rule.Address == "" {
+			e.ranges = []ipRange{network}
+			return e, nil
+		}
+	}
+
+	ranges, mac, err := parseAddress(rule.Address)
+	if err != nil {
+		return e, err
+	}
+	e.mac = mac
+	if mac != "" {
+		for _, known := range rule.KnownIPs {
+			if ip, ok := parseIPv4(known); ok {
+				ranges = append(ranges, ipRange{ip, ip})
+			}
+		}
+	}
+	for _, r := range ranges {
+		if e.within != nil {
+			var ok bool
+			if r, ok = r.intersect(*e.within); !ok {
+				continue
+			}
+		}
+		e.ranges = append(e.ranges, r)
+	}
+	return e, nil
+}
+
+// parseAddress reads the address of a rule: an IPv4 address, CIDR or range, or a MAC
+func parseAddress(address string) ([]ipRange, string, error) {
+	address = strings.TrimSpace(address)
+	if mac, err := net.ParseMAC(address); err == nil {
+		if len(mac) != 6 {
+			return nil, "", fmt.Errorf("%q is not an Ethernet MAC address", address)
+		}
+		return nil, mac.String(), nil
+	}
+	if strings.Contains(address, "/") {
+		r, err := parseCIDR(address)
+		if err != nil {
+			return nil, "", err
+		}
+		return []ipRange{r}, "", nil
+	}
+	if strings.Contains(address, "-") {
+		first, last, err := util.ParseIPv4Range(address)
+		if err != nil {
+			return nil, "", err
+		}
+		return []ipRange{{first, last}}, "", nil
+	}
+	ip, ok := parseIPv4(address)
+	if !ok {
+		return nil, "", fmt.Errorf("%q is not an IPv4 address, CIDR, range or MAC address", address)
+	}
+	return []ipRange{{ip, ip}}, "", nil
+}
+
+func parseIPv4(value string) (uint32, bool) {
+	ip := net.ParseIP(strings.TrimSpace(value)).To4()
+	if ip == nil {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(ip), true
+}
+
+func parseCIDR(value string) (ipRange, error) {
+	_, ipNet, err := net.ParseCIDR(strings.TrimSpace(value))
+	if err != nil || ipNet.IP.To4() == nil {
+		return ipRange{}, fmt.Errorf("%q is not an IPv4 CIDR", value)
+	}
+	first := binary.BigEndian.Uint32(ipNet.IP.To4())
+	ones, _ := ipNet.Mask.Size()
+	return ipRange{first, first | uint32(uint64(1)<<(32-ones)-1)}, nil
+}
+
+// Match returns the first rule keeping a stage away from the host, nil when the
+// stage may run. Either the address or the MAC may be unknown.
+func (s *RuleSet) Match(stage models.ExclusionStage, ip, mac string) *models.ExclusionRule {
+	if s == nil {
+		return nil
+	}
+	address, hasIP := parseIPv4(ip)
+	if parsed, err := net.ParseMAC(strings.TrimSpace(mac)); err == nil {
+		mac = parsed.String()
+	} else {
+		mac = ""
+	}
+
+	for _, e := range s.entries {
+		if !e.rule.Blocks(stage) {
+			continue
+		}
+		if hasIP {
+			for _, r := range e.ranges {
+				if r.contains(address) {
+					return e.rule
+				}
+			}
+		}
+		if mac != "" && e.mac == mac && (e.within == nil || !hasIP || e.within.contains(address)) {
+			return e.rule
+		}
+	}
+	return nil
+}
+
+// Exclusion is the part of a scan target one rule keeps a stage away from
+type Exclusion struct {
+	Rule  *models.ExclusionRule
+	CIDRs []string
+}
+
+// Excluded returns the CIDRs inside target that a stage must skip, by rule. MAC
+// rules only cover the addresses their devices were last seen at.
+func (s *RuleSet) Excluded(stage models.ExclusionStage, target string) ([]Exclusion, error) {
+	if s == nil {
+		return nil, nil
+	}
+	scope, err := parseCIDR(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var exclusions []Exclusion
+	for _, e := range s.entries {
+		if !e.rule.Blocks(stage) {
+			continue
+		}
+		exclusion := Exclusion{Rule: e.rule}
+		for _, r := range e.ranges {
+			if overlap, ok := r.intersect(scope); ok {
+				exclusion.CIDRs = append(exclusion.CIDRs, util.RangeToCIDRs(overlap.first, overlap.last)...)
+			}
+		}
+		if len(exclusion.CIDRs) > 0 {
+			exclusions = append(exclusions, exclusion)
+		}
+	}
+	return exclusions, nil
+}
@@ -0,0 +1,78 @@
+package exclusion
+
+import (
+	"testing"
+
+	"reconya-ai/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleSet_Match(t *testing.T) {
+	portScan := []models.ExclusionStage{models.ExcludePortScan}
+	rules := []*models.ExclusionRule{
+		{ID: "ip", Scope: models.ExclusionGlobal, Address: "10.0.0.5", Stages: portScan},
+		{ID: "range", Scope: models.ExclusionGlobal, Address: "10.0.0.20-29", Stages: []models.ExclusionStage{models.ExcludeWeb}},
+		{ID: "cidr", Scope: models.ExclusionGlobal, Address: "10.1.0.0/16", Stages: []models.ExclusionStage{models.ExcludeDiscovery}},
+		{ID: "network", Scope: models.ExclusionNetwork, NetworkID: "n1", NetworkCIDR: "192.168.1.0/24", Stages: []models.ExclusionStage{models.ExcludeOSDetection}},
+		{ID: "partial", Scope: models.ExclusionNetwork, NetworkID: "n2", NetworkCIDR: "192.168.2.0/24", Address: "192.168.0.0/16", Stages: portScan},
+		{ID: "device", Scope: models.ExclusionDevice, DeviceID: "d1", DeviceIPv4: "172.16.0.9", DeviceMAC: "AA:BB:CC:00:00:01", Stages: portScan},
+		{ID: "mac", Scope: models.ExclusionGlobal, Address: "aa:bb:cc:00:00:02", KnownIPs: []string{"172.16.0.50"}, Stages: portScan},
+		{ID: "gone", Scope: models.ExclusionDevice, DeviceID: "d2", Stages: portScan},
+	}
+	set := NewRuleSet(rules)
+	assert.Equal(t, 7, set.Len(), "the rule of a deleted device is left out")
+
+	matched := func(stage models.ExclusionStage, ip, mac string) string {
+		if rule := set.Match(stage, ip, mac); rule != nil {
+			return rule.ID
+		}
+		return ""
+	}
+
+	assert.Equal(t, "ip", matched(models.ExcludePortScan, "10.0.0.5", ""))
+	assert.Equal(t, "", matched(models.ExcludeDiscovery, "10.0.0.5", ""), "only the stages of a rule are blocked")
+	assert.Equal(t, "range", matched(models.ExcludeWeb, "10.0.0.29", ""))
+	assert.Equal(t, "range", matched(models.ExcludeScreenshots, "10.0.0.20", ""), "hosts without web fetches get no screenshots")
+	assert.Equal(t, "", matched(models.ExcludeWeb, "10.0.0.30", ""))
+	assert.Equal(t, "cidr", matched(models.ExcludeDiscovery, "10.1.200.3", ""))
+
+	assert.Equal(t, "network", matched(models.ExcludeOSDetection, "192.168.1.77", ""))
+	assert.Equal(t, "partial", matched(models.ExcludePortScan, "192.168.2.1", ""))
+	assert.Equal(t, "", matched(models.ExcludePortScan, "192.168.3.1", ""), "network rules stop at their network")
+
+	assert.Equal(t, "device", matched(models.ExcludePortScan, "172.16.0.9", ""))
+	assert.Equal(t, "device", matched(models.ExcludePortScan, "172.16.0.10", "aa-bb-cc-00-00-01"), "device rules follow the MAC")
+	assert.Equal(t, "mac", matched(models.ExcludePortScan, "172.16.0.50", ""), "MAC rules cover the known addresses")
+	assert.Equal(t, "mac", matched(models.ExcludePortScan, "", "AA:BB:CC:00:00:02"))
+	assert.Equal(t, "", matched(models.ExcludePortScan, "8.8.8.8", "aa:bb:cc:00:00:03"))
+
+	var empty *RuleSet
+	assert.Nil(t, empty.Match(models.ExcludePortScan, "10.0.0.5", ""))
+}
+
+func TestRuleSet_Excluded(t *testing.T) {
+	discovery := []models.ExclusionStage{models.ExcludeDiscovery}
+	set := NewRuleSet([]*models.ExclusionRule{
+		{ID: "range", Scope: models.ExclusionGlobal, Address: "10.0.0.250-10.0.1.3", Stages: discovery},
+		{ID: "mac", Scope: models.ExclusionGlobal, Address: "aa:bb:cc:00:00:02", KnownIPs: []string{"10.0.1.9"}, Stages: discovery},
+		{ID: "unseen", Scope: models.ExclusionGlobal, Address: "aa:bb:cc:00:00:03", Stages: discovery},
+		{ID: "ports", Scope: models.ExclusionGlobal, Address: "10.0.1.0/24", Stages: []models.ExclusionStage{models.ExcludePortScan}},
+	})
+
+	exclusions, err := set.Excluded(models.ExcludeDiscovery, "10.0.1.0/24")
+	require.NoError(t, err)
+	require.Len(t, exclusions, 2)
+	assert.Equal(t, "range", exclusions[0].Rule.ID)
+	assert.Equal(t, []string{"10.0.1.0/30"}, exclusions[0].CIDRs, "ranges are cut to the target")
+	assert.Equal(t, "mac", exclusions[1].Rule.ID)
+	assert.Equal(t, []string{"10.0.1.9/32"}, exclusions[1].CIDRs)
+
+	exclusions, err = set.Excluded(models.ExcludeDiscovery, "192.168.0.0/24")
+	require.NoError(t, err)
+	assert.Empty(t, exclusions)
+
+	_, err = set.Excluded(models.ExcludeDiscovery, "not a network")
+	assert.Error(t, err)
+}
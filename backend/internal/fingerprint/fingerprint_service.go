@@ -3,8 +3,9 @@ package fingerprint
 import (
 	"context"
 	"encoding/xml"
-	"log"
 	"os/exec"
+	"reconya-ai/internal/exclusion"
+	"reconya-ai/internal/logging"
 	"reconya-ai/models"
 	"regexp"
 	"strconv"
@@ -12,7 +13,12 @@ import (
 	"time"
 )
 
-type FingerprintService struct{}
+var logger = logging.For("fingerprint")
+
+type FingerprintService struct {
+	// Exclusions skip OS detection
+	Exclusions *exclusion.ExclusionService
+}
 
 func NewFingerprintService() *FingerprintService {
 	return &FingerprintService{}
@@ -20,13 +26,34 @@ func NewFingerprintService() *FingerprintService {
 
 // AnalyzeDevice performs comprehensive device fingerprinting
 func (f *FingerprintService) AnalyzeDevice(device *models.Device) {
-	log.Printf("Starting device fingerprinting for %s", device.IPv4)
+	f.Analyze(device, true)
+}
+
+// Analyze fingerprints the device, running nmap OS detection only when osDetection
+// is set since it is slow and works best as root
+func (f *FingerprintService) Analyze(device *models.Device, osDetection bool) {
+	f.AnalyzeContext(context.Background(), device, osDetection)
+}
+
+// AnalyzeContext is Analyze with OS detection stopped when ctx is cancelled
+func (f *FingerprintService) AnalyzeContext(ctx context.Context, device *models.Device, osDetection bool) {
+	f.analyze(ctx, device, osDetection, nil)
+}
+
+// AnalyzeProfile fingerprints the device with the OS detection setting, timing
+// and rate limits of a scan profile
+func (f *FingerprintService) AnalyzeProfile(ctx context.Context, device *models.Device, profile *models.ScanProfile) {
+	f.analyze(ctx, device, profile.OSDetection, profile.NmapTimingArgs())
+}
+
+func (f *FingerprintService) analyze(ctx context.Context, device *models.Device, osDetection bool, timing []string) {
+	logger.Debugf("Starting device fingerprinting for %s", device.IPv4)
 	
 	// 1. Vendor-based device type detection
 	deviceType := f.detectDeviceTypeFromVendor(device.Vendor)
 	if deviceType != models.DeviceTypeUnknown {
 		device.DeviceType = deviceType
-		log.Printf("Device type detected from vendor: %s", deviceType)
+		logger.Debugf("Device type detected from vendor: %s", deviceType)
 	}
 	
 	// 2. Port-based service detection
@@ -34,7 +61,7 @@ func (f *FingerprintService) AnalyzeDevice(device *models.Device) {
 		if device.DeviceType == models.DeviceTypeUnknown {
 			device.DeviceType = portBasedType
 		}
-		log.Printf("Device type detected from ports: %s", portBasedType)
+		logger.Debugf("Device type detected from ports: %s", portBasedType)
 	}
 	
 	// 3. Hostname-based detection
@@ -42,7 +69,7 @@ func (f *FingerprintService) AnalyzeDevice(device *models.Device) {
 		if device.DeviceType == models.DeviceTypeUnknown {
 			device.DeviceType = hostnameType
 		}
-		log.Printf("Device type detected from hostname: %s", hostnameType)
+		logger.Debugf("Device type detected from hostname: %s", hostnameType)
 	}
 	
 	// 4. Web service-based detection
@@ -50,13 +77,28 @@ func (f *FingerprintService) AnalyzeDevice(device *models.Device) {
 		if device.DeviceType == models.DeviceTypeUnknown {
 			device.DeviceType = webType
 		}
-		log.Printf("Device type detected from web services: %s", webType)
+		logger.Debugf("Device type detected from web services: %s", webType)
 	}
 	
-	// 5. Nmap OS detection (more intensive)
-	if osInfo := f.performNmapOSDetection(device.IPv4); osInfo != nil {
+	// 5. SNMP system description
+	f.AnalyzeSNMP(device)
+	
+	// 6. Nmap OS detection (more intensive)
+	if osDetection {
+		var mac string
+		if device.MAC != nil {
+			mac = *device.MAC
+		}
+		if rule := f.Exclusions.Blocked(models.ExcludeOSDetection, device.IPv4, mac); rule != nil {
+			logger.Infof("Skipping OS detection for %s: %v", device.IPv4, exclusion.BlockedError(rule, models.ExcludeOSDetection))
+			osDetection = false
+		}
+	}
+	if !osDetection {
+		logger.Debugf("Skipping OS detection for %s", device.IPv4)
+	} else if osInfo := f.performNmapOSDetection(ctx, device.IPv4, timing); osInfo != nil && (device.OS == nil || osInfo.Confidence >= device.OS.Confidence) {
 		device.OS = osInfo
-		log.Printf("OS detected: %s %s (confidence: %d%%)", osInfo.Name, osInfo.Version, osInfo.Confidence)
+		logger.Debugf("OS detected: %s %s (confidence: %d%%)", osInfo.Name, osInfo.Version, osInfo.Confidence)
 		
 		// Refine device type based on OS
 		if osBasedType := f.detectDeviceTypeFromOS(osInfo); osBasedType != models.DeviceTypeUnknown {
@@ -71,7 +113,7 @@ func (f *FingerprintService) AnalyzeDevice(device *models.Device) {
 		device.DeviceType = models.DeviceTypeWorkstation // Default fallback
 	}
 	
-	log.Printf("Final device fingerprint - Type: %s, OS: %v", device.DeviceType, device.OS)
+	logger.Debugf("Final device fingerprint - Type: %s, OS: %v", device.DeviceType, device.OS)
 }
 
 // detectDeviceTypeFromVendor identifies device type based on MAC vendor
@@ -292,19 +334,21 @@ func (f *FingerprintService) detectDeviceTypeFromOS(os *models.DeviceOS) models.
 	return models.DeviceTypeUnknown
 }
 
-// performNmapOSDetection runs nmap OS detection
-func (f *FingerprintService) performNmapOSDetection(ipv4 string) *models.DeviceOS {
-	log.Printf("Performing nmap OS detection for %s", ipv4)
+// performNmapOSDetection runs nmap OS detection with the given timing options,
+// nmap's defaults when there are none
+func (f *FingerprintService) performNmapOSDetection(parent context.Context, ipv4 string, timing []string) *models.DeviceOS {
+	logger.Debugf("Performing nmap OS detection for %s", ipv4)
 	
 	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	ctx, cancel := context.WithTimeout(parent, 2*time.Minute)
 	defer cancel()
 	
 	// Run nmap OS detection
-	cmd := exec.CommandContext(ctx, "nmap", "-O", "-sT", "--osscan-guess", "-oX", "-", ipv4)
+	args := append([]string{"-O", "-sT", "--osscan-guess"}, timing...)
+	cmd := exec.CommandContext(ctx, "nmap", append(args, "-oX", "-", ipv4)...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		log.Printf("nmap OS detection failed for %s: %v", ipv4, err)
+		logger.Errorf("nmap OS detection failed for %s: %v", ipv4, err)
 		return nil
 	}
 	
@@ -332,7 +376,7 @@ func (f *FingerprintService) parseNmapOSOutput(xmlOutput string) *models.DeviceO
 	
 	err := xml.Unmarshal([]byte(xmlOutput), &nmapXML)
 	if err != nil {
-		log.Printf("Error parsing nmap OS XML: %v", err)
+		logger.Errorf("Error parsing nmap OS XML: %v", err)
 		return nil
 	}
 	
@@ -383,4 +427,110 @@ func (f *FingerprintService) extractVersionFromOSName(osName string) string {
 	}
 	
 	return ""
-}
\ No newline at end of file
+}
+// snmpEnterpriseTypes maps IANA private enterprise numbers found in sysObjectID to device types
+var snmpEnterpriseTypes = map[string]models.DeviceType{
+	"9":     models.DeviceTypeRouter,      // Cisco
+	"2636":  models.DeviceTypeRouter,      // Juniper
+	"14988": models.DeviceTypeRouter,      // MikroTik
+	"2011":  models.DeviceTypeRouter,      // Huawei
+	"12356": models.DeviceTypeFirewall,    // Fortinet
+	"25461": models.DeviceTypeFirewall,    // Palo Alto Networks
+	"41112": models.DeviceTypeAccessPoint, // Ubiquiti
+	"14823": models.DeviceTypeAccessPoint, // Aruba
+	"6574":  models.DeviceTypeNAS,         // Synology
+	"24681": models.DeviceTypeNAS,         // QNAP
+	"1248":  models.DeviceTypePrinter,     // Epson
+	"367":   models.DeviceTypePrinter,     // Ricoh
+	"2435":  models.DeviceTypePrinter,     // Brother
+	"641":   models.DeviceTypePrinter,     // Lexmark
+	"253":   models.DeviceTypePrinter,     // Xerox
+	"6876":  models.DeviceTypeServer,      // VMware
+}
+
+// AnalyzeSNMP refines device type and OS from SNMP system data. It is cheap and
+// can be called on its own after a poll, without running nmap.
+func (f *FingerprintService) AnalyzeSNMP(device *models.Device) {
+	if device.SNMP == nil {
+		return
+	}
+
+	if snmpType := f.detectDeviceTypeFromSNMP(device.SNMP); snmpType != models.DeviceTypeUnknown {
+		if device.DeviceType == models.DeviceTypeUnknown || device.DeviceType == "" {
+			device.DeviceType = snmpType
+		}
+		logger.Debugf("Device type detected from SNMP: %s", snmpType)
+	}
+
+	if device.OS == nil {
+		if osInfo := f.detectOSFromSysDescr(device.SNMP.SysDescr); osInfo != nil {
+			device.OS = osInfo
+			logger.Debugf("OS detected from SNMP sysDescr: %s %s", osInfo.Name, osInfo.Version)
+		}
+	}
+}
+
+// detectDeviceTypeFromSNMP identifies device type from sysObjectID and sysDescr
+func (f *FingerprintService) detectDeviceTypeFromSNMP(info *models.SNMPInfo) models.DeviceType {
+	descrLower := strings.ToLower(info.SysDescr)
+
+	switch {
+	case strings.Contains(descrLower, "switch") || strings.Contains(descrLower, "catalyst") || strings.Contains(descrLower, "procurve"):
+		return models.DeviceTypeSwitch
+	case strings.Contains(descrLower, "printer") || strings.Contains(descrLower, "laserjet") || strings.Contains(descrLower, "officejet"):
+		return models.DeviceTypePrinter
+	case strings.Contains(descrLower, "routeros") || strings.Contains(descrLower, "router"):
+		return models.DeviceTypeRouter
+	case strings.Contains(descrLower, "firewall") || strings.Contains(descrLower, "fortigate") || strings.Contains(descrLower, "pfsense"):
+		return models.DeviceTypeFirewall
+	case strings.Contains(descrLower, "access point") || strings.Contains(descrLower, "unifi"):
+		return models.DeviceTypeAccessPoint
+	case strings.Contains(descrLower, "windows") && strings.Contains(descrLower, "server"):
+		return models.DeviceTypeServer
+	}
+
+	// Enterprise OIDs look like .1.3.6.1.4.1.<enterprise>.…
+	oid := strings.TrimPrefix(info.SysObjectID, ".")
+	if strings.HasPrefix(oid, "1.3.6.1.4.1.") {
+		parts := strings.Split(strings.TrimPrefix(oid, "1.3.6.1.4.1."), ".")
+		if deviceType, ok := snmpEnterpriseTypes[parts[0]]; ok {
+			return deviceType
+		}
+	}
+
+	return models.DeviceTypeUnknown
+}
+
+// detectOSFromSysDescr extracts the operating system from common sysDescr formats
+func (f *FingerprintService) detectOSFromSysDescr(sysDescr string) *models.DeviceOS {
+	if sysDescr == "" {
+		return nil
+	}
+
+	descrLower := strings.ToLower(sysDescr)
+	fields := strings.Fields(sysDescr)
+
+	switch {
+	case strings.Contains(descrLower, "cisco ios"):
+		osInfo := &models.DeviceOS{Name: "Cisco IOS", Family: "IOS", Confidence: 90}
+		if match := regexp.MustCompile(`Version ([0-9][^\s,]*)`).FindStringSubmatch(sysDescr); len(match) > 1 {
+			osInfo.Version = match[1]
+		}
+		return osInfo
+	case strings.Contains(descrLower, "junos"):
+		return &models.DeviceOS{Name: "Junos", Family: "Junos", Confidence: 90}
+	case strings.Contains(descrLower, "routeros"):
+		osInfo := &models.DeviceOS{Name: "RouterOS", Family: "RouterOS", Confidence: 90}
+		if match := regexp.MustCompile(`RouterOS ([0-9][^\s]*)`).FindStringSubmatch(sysDescr); len(match) > 1 {
+			osInfo.Version = match[1]
+		}
+		return osInfo
+	case strings.Contains(descrLower, "windows"):
+		return &models.DeviceOS{Name: "Windows", Family: "Windows", Version: f.extractVersionFromOSName(sysDescr), Confidence: 80}
+	case len(fields) >= 3 && (fields[0] == "Linux" || fields[0] == "FreeBSD" || fields[0] == "Darwin"):
+		// net-snmp default: "<sysname> <hostname> <release> <version> <machine>"
+		return &models.DeviceOS{Name: fields[0], Family: fields[0], Version: fields[2], Confidence: 90}
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+
+	"reconya-ai/models"
+)
+
+// ToCSV writes annotated devices as CSV with one column per custom field.
+// networks maps network IDs to the CIDR shown in the network column.
+func ToCSV(devices []*models.Device, fields []*models.CustomField, networks map[string]string) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	header := []string{"ip", "mac", "hostname", "name", "vendor", "type", "status", "network", "tags", "groups"}
+	for _, field := range fields {
+		header = append(header, field.Name)
+	}
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+
+	for _, d := range devices {
+		record := []string{
+			d.IPv4,
+			stringValue(d.MAC),
+			stringValue(d.Hostname),
+			d.Name,
+			stringValue(d.Vendor),
+			string(d.DeviceType),
+			string(d.Status),
+			networks[d.NetworkID],
+			strings.Join(d.Tags, ";"),
+			strings.Join(d.Groups, ";"),
+		}
+		for _, field := range fields {
+			record = append(record, d.CustomFields[field.Name])
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
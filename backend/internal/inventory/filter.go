@@ -0,0 +1,215 @@
+package inventory
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"reconya-ai/models"
+)
+
+var fieldNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// DeviceFilter selects devices by tags, group and custom field values
+type DeviceFilter struct {
+	// Tags must all be present on a device
+	Tags []string
+	// Group matches devices in the group or any of its subgroups
+	Group string
+	// Fields maps custom field names to the value they must have
+	Fields map[string]string
+}
+
+// ParseDeviceFilter reads ?tag=a&tag=b (or tag=a,b), ?group=Office/Floor 2 and
+// ?field.owner=alice from a query string
+func ParseDeviceFilter(query url.Values) DeviceFilter {
+	filter := DeviceFilter{Fields: make(map[string]string)}
+	for _, value := range query["tag"] {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				filter.Tags = append(filter.Tags, tag)
+			}
+		}
+	}
+	filter.Group = NormalizeGroupPath(query.Get("group"))
+	for key, values := range query {
+		if name := strings.TrimPrefix(key, "field."); name != key && name != "" && len(values) > 0 {
+			filter.Fields[NormalizeFieldName(name)] = strings.TrimSpace(values[0])
+		}
+	}
+	return filter
+}
+
+// IsEmpty reports whether the filter matches every device
+func (f DeviceFilter) IsEmpty() bool {
+	return len(f.Tags) == 0 && f.Group == "" && len(f.Fields) == 0
+}
+
+// Matches checks an annotated device against the filter. Comparisons ignore case.
+func (f DeviceFilter) Matches(d *models.Device) bool {
+	for _, tag := range f.Tags {
+		if !containsFold(d.Tags, tag) {
+			return false
+		}
+	}
+
+	if f.Group != "" {
+		found := false
+		for _, path := range d.Groups {
+			if strings.EqualFold(path, f.Group) || strings.HasPrefix(strings.ToLower(path), strings.ToLower(f.Group)+models.GroupPathSeparator) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for name, value := range f.Fields {
+		if !strings.EqualFold(d.CustomFields[name], value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns the devices matching the filter
+func (f DeviceFilter) Apply(devices []*models.Device) []*models.Device {
+	if f.IsEmpty() {
+		return devices
+	}
+	filtered := make([]*models.Device, 0, len(devices))
+	for _, d := range devices {
+		if f.Matches(d) {
+			filtered = append(filtered, d)
+		}
+	}
+	return filtered
+}
+
+// NormalizeGroupPath trims whitespace around each path segment and drops empty segments
+func NormalizeGroupPath(path string) string {
+	var segments []string
+	for _, segment := range strings.Split(path, models.GroupPathSeparator) {
+		if segment = strings.TrimSpace(segment); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return strings.Join(segments, models.GroupPathSeparator)
+}
+
+// NormalizeFieldName turns a label like "Asset Tag" into the field name asset_tag
+func NormalizeFieldName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+}
+
+// GroupPaths computes the full path of every group from the parent links
+func GroupPaths(groups []*models.DeviceGroup) map[string]string {
+	byID := make(map[string]*models.DeviceGroup, len(groups))
+	for _, group := range groups {
+		byID[group.ID] = group
+	}
+
+	paths := make(map[string]string, len(groups))
+	for _, group := range groups {
+		segments := []string{}
+		seen := make(map[string]bool)
+		for current := group; current != nil && !seen[current.ID]; {
+			seen[current.ID] = true
+			segments = append([]string{current.Name}, segments...)
+			if current.ParentID == nil {
+				break
+			}
+			current = byID[*current.ParentID]
+		}
+		paths[group.ID] = strings.Join(segments, models.GroupPathSeparator)
+	}
+	return paths
+}
+
+// ValidateCustomField checks a field definition and normalizes its name and options
+func ValidateCustomField(field *models.CustomField) error {
+	if field.Name == "" {
+		field.Name = field.Label
+	}
+	field.Name = NormalizeFieldName(field.Name)
+	if !fieldNamePattern.MatchString(field.Name) {
+		return fmt.Errorf("field name must contain only letters, digits and underscores")
+	}
+	if field.Label == "" {
+		field.Label = field.Name
+	}
+
+	switch field.Type {
+	case models.CustomFieldString, models.CustomFieldNumber, models.CustomFieldDate:
+		field.Options = nil
+	case models.CustomFieldEnum:
+		options := []string{}
+		for _, option := range field.Options {
+			if option = strings.TrimSpace(option); option != "" && !containsFold(options, option) {
+				options = append(options, option)
+			}
+		}
+		if len(options) == 0 {
+			return fmt.Errorf("enum field %s needs at least one option", field.Name)
+		}
+		field.Options = options
+	default:
+		return fmt.Errorf("unsupported field type %q, use string, number, date or enum", field.Type)
+	}
+	return nil
+}
+
+// ValidateCustomValue checks a value against its field type and returns it normalized.
+// Empty values are valid and clear the field.
+func ValidateCustomValue(field *models.CustomField, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+
+	switch field.Type {
+	case models.CustomFieldNumber:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("%s must be a number", field.Name)
+		}
+		return strconv.FormatFloat(number, 'f', -1, 64), nil
+	case models.CustomFieldDate:
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return "", fmt.Errorf("%s must be a date like 2024-01-31", field.Name)
+		}
+		return date.Format("2006-01-02"), nil
+	case models.CustomFieldEnum:
+		for _, option := range field.Options {
+			if strings.EqualFold(option, value) {
+				return option, nil
+			}
+		}
+		return "", fmt.Errorf("%s must be one of %s", field.Name, strings.Join(field.Options, ", "))
+	}
+	return value, nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortGroups orders groups by path so parents come before their subgroups
+func sortGroups(groups []*models.DeviceGroup) {
+	sort.Slice(groups, func(i, j int) bool {
+		return strings.ToLower(groups[i].Path) < strings.ToLower(groups[j].Path)
+	})
+}
@@ -0,0 +1,148 @@
+package inventory
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"reconya-ai/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDeviceFilter(t *testing.T) {
+	query, err := url.ParseQuery("tag=prod,%20web&tag=dmz&group=%20Office%20/%20Floor%202/&field.Asset-Tag=A1&network_id=x")
+	require.NoError(t, err)
+
+	filter := ParseDeviceFilter(query)
+	assert.Equal(t, []string{"prod", "web", "dmz"}, filter.Tags)
+	assert.Equal(t, "Office/Floor 2", filter.Group)
+	assert.Equal(t, map[string]string{"asset_tag": "A1"}, filter.Fields)
+	assert.False(t, filter.IsEmpty())
+
+	assert.True(t, ParseDeviceFilter(url.Values{}).IsEmpty())
+}
+
+func TestDeviceFilterMatches(t *testing.T) {
+	printer := &models.Device{
+		Tags:         []string{"Prod", "printer"},
+		Groups:       []string{"Office/Floor 2/Printers"},
+		CustomFields: map[string]string{"owner": "Alice"},
+	}
+	untagged := &models.Device{}
+
+	tests := []struct {
+		name   string
+		filter DeviceFilter
+		want   bool
+	}{
+		{"empty filter", DeviceFilter{}, true},
+		{"tag ignores case", DeviceFilter{Tags: []string{"prod"}}, true},
+		{"all tags required", DeviceFilter{Tags: []string{"prod", "web"}}, false},
+		{"exact group", DeviceFilter{Group: "office/floor 2/printers"}, true},
+		{"parent group", DeviceFilter{Group: "Office"}, true},
+		{"group name prefix is not a parent", DeviceFilter{Group: "Office/Floor"}, false},
+		{"custom field", DeviceFilter{Fields: map[string]string{"owner": "alice"}}, true},
+		{"custom field mismatch", DeviceFilter{Fields: map[string]string{"owner": "bob"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Matches(printer))
+		})
+	}
+
+	filtered := DeviceFilter{Tags: []string{"printer"}}.Apply([]*models.Device{printer, untagged})
+	assert.Equal(t, []*models.Device{printer}, filtered)
+}
+
+func TestGroupPaths(t *testing.T) {
+	office := &models.DeviceGroup{ID: "1", Name: "Office"}
+	floor := &models.DeviceGroup{ID: "2", Name: "Floor 2", ParentID: &office.ID}
+	printers := &models.DeviceGroup{ID: "3", Name: "Printers", ParentID: &floor.ID}
+
+	paths := GroupPaths([]*models.DeviceGroup{printers, office, floor})
+	assert.Equal(t, "Office", paths["1"])
+	assert.Equal(t, "Office/Floor 2", paths["2"])
+	assert.Equal(t, "Office/Floor 2/Printers", paths["3"])
+
+	// A parent cycle must not loop forever
+	a := &models.DeviceGroup{ID: "a", Name: "A", ParentID: strPtr("b")}
+	b := &models.DeviceGroup{ID: "b", Name: "B", ParentID: strPtr("a")}
+	paths = GroupPaths([]*models.DeviceGroup{a, b})
+	assert.Equal(t, "B/A", paths["a"])
+}
+
+func TestValidateCustomField(t *testing.T) {
+	field := &models.CustomField{Label: "Asset Tag", Type: models.CustomFieldString, Options: []string{"x"}}
+	require.NoError(t, ValidateCustomField(field))
+	assert.Equal(t, "asset_tag", field.Name)
+	assert.Nil(t, field.Options)
+
+	enum := &models.CustomField{Name: "criticality", Type: models.CustomFieldEnum, Options: []string{" low", "High", "high", ""}}
+	require.NoError(t, ValidateCustomField(enum))
+	assert.Equal(t, []string{"low", "High"}, enum.Options)
+
+	assert.Error(t, ValidateCustomField(&models.CustomField{Name: "criticality", Type: models.CustomFieldEnum}))
+	assert.Error(t, ValidateCustomField(&models.CustomField{Name: "owner", Type: "bool"}))
+	assert.Error(t, ValidateCustomField(&models.CustomField{Name: "owner!", Type: models.CustomFieldString}))
+}
+
+func TestValidateCustomValue(t *testing.T) {
+	number := &models.CustomField{Name: "rack_unit", Type: models.CustomFieldNumber}
+	date := &models.CustomField{Name: "purchased", Type: models.CustomFieldDate}
+	enum := &models.CustomField{Name: "criticality", Type: models.CustomFieldEnum, Options: []string{"Low", "High"}}
+
+	tests := []struct {
+		field   *models.CustomField
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{number, " 42.50 ", "42.5", false},
+		{number, "forty", "", true},
+		{date, "2024-02-29", "2024-02-29", false},
+		{date, "29/02/2024", "", true},
+		{enum, "high", "High", false},
+		{enum, "medium", "", true},
+		{enum, "", "", false},
+	}
+
+	for _, tt := range tests {
+		got, err := ValidateCustomValue(tt.field, tt.value)
+		if tt.wantErr {
+			assert.Error(t, err, tt.value)
+			continue
+		}
+		require.NoError(t, err, tt.value)
+		assert.Equal(t, tt.want, got)
+	}
+}
+
+func TestToCSV(t *testing.T) {
+	mac := "00:11:22:33:44:55"
+	devices := []*models.Device{{
+		IPv4:         "192.168.1.10",
+		MAC:          &mac,
+		Name:         "printer, 2nd floor",
+		Status:       models.DeviceStatusOnline,
+		NetworkID:    "n1",
+		Tags:         []string{"prod", "printer"},
+		Groups:       []string{"Office/Floor 2"},
+		CustomFields: map[string]string{"owner": "alice"},
+	}}
+	fields := []*models.CustomField{{Name: "owner"}, {Name: "asset_tag"}}
+
+	output, err := ToCSV(devices, fields, map[string]string{"n1": "192.168.1.0/24"})
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, "ip,mac,hostname,name,vendor,type,status,network,tags,groups,owner,asset_tag", lines[0])
+	assert.Equal(t, `192.168.1.10,00:11:22:33:44:55,,"printer, 2nd floor",,,online,192.168.1.0/24,prod;printer,Office/Floor 2,alice,`, lines[1])
+}
+
+func strPtr(s string) *string {
+	return &s
+}
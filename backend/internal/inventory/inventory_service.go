@@ -0,0 +1,346 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/device"
+	"reconya-ai/models"
+)
+
+// InventoryService manages tags, device groups and custom fields and attaches them to devices
+type InventoryService struct {
+	Repository    *db.InventoryRepository
+	DeviceService *device.DeviceService
+}
+
+func NewInventoryService(repository *db.InventoryRepository, deviceService *device.DeviceService) *InventoryService {
+	return &InventoryService{
+		Repository:    repository,
+		DeviceService: deviceService,
+	}
+}
+
+// BulkUpdate describes changes applied to every device in DeviceIDs. Tags and
+// groups are created on demand, an empty custom field value clears the field.
+type BulkUpdate struct {
+	DeviceIDs    []string          `json:"device_ids"`
+	AddTags      []string          `json:"add_tags,omitempty"`
+	RemoveTags   []string          `json:"remove_tags,omitempty"`
+	AddGroups    []string          `json:"add_groups,omitempty"`
+	RemoveGroups []string          `json:"remove_groups,omitempty"`
+	CustomFields map[string]string `json:"custom_fields,omitempty"`
+}
+
+func (s *InventoryService) ListTags() ([]*models.Tag, error) {
+	return s.Repository.ListTags(context.Background())
+}
+
+// SaveTag creates a tag, or updates it when the ID is set
+func (s *InventoryService) SaveTag(tag *models.Tag) (*models.Tag, error) {
+	tag.Name = strings.TrimSpace(tag.Name)
+	if tag.Name == "" || strings.Contains(tag.Name, ",") {
+		return nil, fmt.Errorf("tag name must be non-empty and must not contain commas")
+	}
+
+	ctx := context.Background()
+	existing, err := s.Repository.FindTagByName(ctx, tag.Name)
+	if err != nil && err != db.ErrNotFound {
+		return nil, err
+	}
+	if existing != nil && existing.ID != tag.ID {
+		return nil, fmt.Errorf("tag %s already exists", existing.Name)
+	}
+
+	if err := s.Repository.UpsertTag(ctx, tag); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+func (s *InventoryService) DeleteTag(id string) error {
+	return s.Repository.DeleteTag(context.Background(), id)
+}
+
+// ListGroups returns all groups with their full paths, sorted by path
+func (s *InventoryService) ListGroups() ([]*models.DeviceGroup, error) {
+	groups, err := s.Repository.ListGroups(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	paths := GroupPaths(groups)
+	for _, group := range groups {
+		group.Path = paths[group.ID]
+	}
+	sortGroups(groups)
+	return groups, nil
+}
+
+// EnsureGroup returns the group at path, creating it and any missing parents
+func (s *InventoryService) EnsureGroup(path string) (*models.DeviceGroup, error) {
+	path = NormalizeGroupPath(path)
+	if path == "" {
+		return nil, fmt.Errorf("group path must not be empty")
+	}
+
+	groups, err := s.ListGroups()
+	if err != nil {
+		return nil, err
+	}
+	byPath := make(map[string]*models.DeviceGroup, len(groups))
+	for _, group := range groups {
+		byPath[strings.ToLower(group.Path)] = group
+	}
+
+	ctx := context.Background()
+	var parent *models.DeviceGroup
+	segments := strings.Split(path, models.GroupPathSeparator)
+	for i, name := range segments {
+		current := strings.Join(segments[:i+1], models.GroupPathSeparator)
+		if group, ok := byPath[strings.ToLower(current)]; ok {
+			parent = group
+			continue
+		}
+
+		group := &models.DeviceGroup{Name: name, Path: current}
+		if parent != nil {
+			group.ParentID = &parent.ID
+		}
+		if err := s.Repository.CreateGroup(ctx, group); err != nil {
+			return nil, err
+		}
+		byPath[strings.ToLower(current)] = group
+		parent = group
+	}
+	return parent, nil
+}
+
+// DeleteGroup removes a group together with its subgroups
+func (s *InventoryService) DeleteGroup(id string) error {
+	groups, err := s.Repository.ListGroups(context.Background())
+	if err != nil {
+		return err
+	}
+
+	children := make(map[string][]string)
+	found := false
+	for _, group := range groups {
+		if group.ID == id {
+			found = true
+		}
+		if group.ParentID != nil {
+			children[*group.ParentID] = append(children[*group.ParentID], group.ID)
+		}
+	}
+	if !found {
+		return db.ErrNotFound
+	}
+
+	// Collect the subtree breadth first and delete it leaves first
+	subtree := []string{id}
+	for i := 0; i < len(subtree); i++ {
+		subtree = append(subtree, children[subtree[i]]...)
+	}
+	for i, j := 0, len(subtree)-1; i < j; i, j = i+1, j-1 {
+		subtree[i], subtree[j] = subtree[j], subtree[i]
+	}
+	return s.Repository.DeleteGroups(context.Background(), subtree)
+}
+
+func (s *InventoryService) ListCustomFields() ([]*models.CustomField, error) {
+	return s.Repository.ListCustomFields(context.Background())
+}
+
+// SaveCustomField validates and stores a custom field definition
+func (s *InventoryService) SaveCustomField(field *models.CustomField) (*models.CustomField, error) {
+	if err := ValidateCustomField(field); err != nil {
+		return nil, err
+	}
+
+	fields, err := s.ListCustomFields()
+	if err != nil {
+		return nil, err
+	}
+	for _, existing := range fields {
+		if existing.Name == field.Name && existing.ID != field.ID {
+			return nil, fmt.Errorf("custom field %s already exists", field.Name)
+		}
+	}
+
+	if err := s.Repository.UpsertCustomField(context.Background(), field); err != nil {
+		return nil, err
+	}
+	return field, nil
+}
+
+func (s *InventoryService) DeleteCustomField(id string) error {
+	return s.Repository.DeleteCustomField(context.Background(), id)
+}
+
+// Annotate fills in the tags, group paths and custom field values of the given devices
+func (s *InventoryService) Annotate(devices []*models.Device) error {
+	if len(devices) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	tags, err := s.Repository.DeviceTags(ctx)
+	if err != nil {
+		return err
+	}
+	groupIDs, err := s.Repository.DeviceGroupIDs(ctx)
+	if err != nil {
+		return err
+	}
+	values, err := s.Repository.DeviceCustomValues(ctx)
+	if err != nil {
+		return err
+	}
+	groups, err := s.Repository.ListGroups(ctx)
+	if err != nil {
+		return err
+	}
+	fields, err := s.Repository.ListCustomFields(ctx)
+	if err != nil {
+		return err
+	}
+
+	paths := GroupPaths(groups)
+	fieldNames := make(map[string]string, len(fields))
+	for _, field := range fields {
+		fieldNames[field.ID] = field.Name
+	}
+
+	for _, d := range devices {
+		d.Tags = tags[d.ID]
+
+		d.Groups = nil
+		for _, groupID := range groupIDs[d.ID] {
+			if path, ok := paths[groupID]; ok {
+				d.Groups = append(d.Groups, path)
+			}
+		}
+
+		d.CustomFields = nil
+		for fieldID, value := range values[d.ID] {
+			name, ok := fieldNames[fieldID]
+			if !ok {
+				continue
+			}
+			if d.CustomFields == nil {
+				d.CustomFields = make(map[string]string)
+			}
+			d.CustomFields[name] = value
+		}
+	}
+	return nil
+}
+
+// ApplyBulk applies tag, group and custom field changes to a set of devices.
+// Everything is validated before anything is written.
+func (s *InventoryService) ApplyBulk(update BulkUpdate) error {
+	if len(update.DeviceIDs) == 0 {
+		return fmt.Errorf("no devices selected")
+	}
+	for _, deviceID := range update.DeviceIDs {
+		d, err := s.DeviceService.FindByID(deviceID)
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			return fmt.Errorf("device %s: %w", deviceID, db.ErrNotFound)
+		}
+	}
+
+	fields, err := s.ListCustomFields()
+	if err != nil {
+		return err
+	}
+	fieldsByName := make(map[string]*models.CustomField, len(fields))
+	for _, field := range fields {
+		fieldsByName[field.Name] = field
+	}
+	values := make(map[string]string, len(update.CustomFields))
+	for name, value := range update.CustomFields {
+		field, ok := fieldsByName[NormalizeFieldName(name)]
+		if !ok {
+			return fmt.Errorf("unknown custom field %s", name)
+		}
+		normalized, err := ValidateCustomValue(field, value)
+		if err != nil {
+			return err
+		}
+		values[field.ID] = normalized
+	}
+
+	ctx := context.Background()
+	for _, name := range update.AddTags {
+		tag, err := s.findOrCreateTag(name)
+		if err != nil {
+			return err
+		}
+		if err := s.Repository.AssignTag(ctx, update.DeviceIDs, tag.ID, true); err != nil {
+			return err
+		}
+	}
+	for _, name := range update.RemoveTags {
+		tag, err := s.Repository.FindTagByName(ctx, strings.TrimSpace(name))
+		if err == db.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.Repository.AssignTag(ctx, update.DeviceIDs, tag.ID, false); err != nil {
+			return err
+		}
+	}
+
+	for _, path := range update.AddGroups {
+		group, err := s.EnsureGroup(path)
+		if err != nil {
+			return err
+		}
+		if err := s.Repository.AssignGroup(ctx, update.DeviceIDs, group.ID, true); err != nil {
+			return err
+		}
+	}
+	if len(update.RemoveGroups) > 0 {
+		groups, err := s.ListGroups()
+		if err != nil {
+			return err
+		}
+		for _, path := range update.RemoveGroups {
+			path = NormalizeGroupPath(path)
+			for _, group := range groups {
+				if !strings.EqualFold(group.Path, path) {
+					continue
+				}
+				if err := s.Repository.AssignGroup(ctx, update.DeviceIDs, group.ID, false); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for fieldID, value := range values {
+		if err := s.Repository.SetCustomValue(ctx, update.DeviceIDs, fieldID, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *InventoryService) findOrCreateTag(name string) (*models.Tag, error) {
+	name = strings.TrimSpace(name)
+	tag, err := s.Repository.FindTagByName(context.Background(), name)
+	if err == nil {
+		return tag, nil
+	}
+	if err != db.ErrNotFound {
+		return nil, err
+	}
+	return s.SaveTag(&models.Tag{Name: name})
+}
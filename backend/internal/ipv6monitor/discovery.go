@@ -0,0 +1,499 @@
+package ipv6monitor
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+
+	"reconya-ai/models"
+)
+
+const (
+	// raWait is how long router advertisements are collected before SLAAC targets
+	// are guessed from the announced prefixes
+	raWait = time.Second
+	// replyWait is how long replies are collected after the last probe was sent
+	replyWait = 2 * time.Second
+	// maxTargets caps the neighbor solicitations sent per interface
+	maxTargets = 1024
+)
+
+// DiscoveredHost is an IPv6 host that answered, or announced itself, during active
+// discovery
+type DiscoveredHost struct {
+	IP        string   `json:"ip"`
+	MAC       string   `json:"mac,omitempty"`
+	Interface string   `json:"interface"`
+	Sources   []string `json:"sources"` // "echo", "mld", "ndp", "ra"
+}
+
+// DiscoveryResult is the outcome of one active discovery run on a network
+type DiscoveryResult struct {
+	NetworkID  string                `json:"network_id"`
+	Interfaces []string              `json:"interfaces"`
+	Hosts      []DiscoveredHost      `json:"hosts"`
+	Routers    []RouterAdvertisement `json:"routers"`
+	Targets    int                   `json:"targets"` // SLAAC addresses probed with neighbor solicitations
+	StartedAt  time.Time             `json:"started_at"`
+	Duration   float64               `json:"duration_seconds"`
+}
+
+// linkProbe collects what one interface learns during discovery
+type linkProbe struct {
+	iface   net.Interface
+	own     map[string]bool
+	mu      sync.Mutex
+	hosts   map[string]*DiscoveredHost
+	routers map[string]*RouterAdvertisement
+}
+
+// RunDiscovery actively discovers IPv6 hosts on an IPv6-enabled network, at most
+// once per discovery interval. It is called by the scan manager after every sweep.
+func (s *IPv6MonitorService) RunDiscovery(network *models.Network) {
+	if network == nil || !network.IsIPv6Enabled() {
+		return
+	}
+
+	s.mu.Lock()
+	if last, ok := s.lastDiscovery[network.ID]; ok && time.Since(last) < s.discoveryInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastDiscovery[network.ID] = time.Now()
+	s.mu.Unlock()
+
+	result, err := s.Discover(network)
+	if err != nil {
+		s.logger.Errorf("IPv6 discovery on network %s failed: %v", network.GetDisplayName(), err)
+		return
+	}
+	s.logger.Infof("IPv6 discovery on network %s found %d hosts and %d routers (%d SLAAC targets probed)",
+		network.GetDisplayName(), len(result.Hosts), len(result.Routers), result.Targets)
+}
+
+// Discover probes the links of a network for IPv6 hosts: an echo request to all
+// nodes, a router solicitation, an MLD general query and neighbor solicitations for
+// SLAAC addresses guessed from the announced prefixes and the known devices. Hosts
+// found are merged into the device inventory.
+func (s *IPv6MonitorService) Discover(network *models.Network) (*DiscoveryResult, error) {
+	ifaces, err := linkInterfaces(network)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DiscoveryResult{
+		NetworkID:  network.ID,
+		Interfaces: []string{},
+		Hosts:      []DiscoveredHost{},
+		Routers:    []RouterAdvertisement{},
+		StartedAt:  time.Now(),
+	}
+	var lastErr error
+	for _, iface := range ifaces {
+		probe, targets, err := s.probeLink(iface, network)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		result.Interfaces = append(result.Interfaces, iface.Name)
+		result.Targets += targets
+		for _, host := range probe.hosts {
+			result.Hosts = append(result.Hosts, *host)
+		}
+		for _, ra := range probe.routers {
+			result.Routers = append(result.Routers, *ra)
+		}
+	}
+	if len(result.Interfaces) == 0 {
+		return nil, lastErr
+	}
+	sort.Slice(result.Hosts, func(i, j int) bool { return result.Hosts[i].IP < result.Hosts[j].IP })
+	sort.Slice(result.Routers, func(i, j int) bool { return result.Routers[i].Router < result.Routers[j].Router })
+
+	s.fillMissingMACs(result.Hosts)
+	for _, host := range result.Hosts {
+		s.processIPv6Device(discoveredDevice(host))
+	}
+
+	result.Duration = time.Since(result.StartedAt).Seconds()
+	s.mu.Lock()
+	s.discoveries[network.ID] = result
+	for i := range result.Routers {
+		s.routerAdverts[result.Routers[i].Router] = &result.Routers[i]
+	}
+	s.mu.Unlock()
+	return result, nil
+}
+
+// LastDiscovery returns the most recent discovery result for a network, or nil
+// before the first run
+func (s *IPv6MonitorService) LastDiscovery(networkID string) *DiscoveryResult {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.discoveries[networkID]
+}
+
+// RouterAdvertisements returns the last advertisement heard from each router
+func (s *IPv6MonitorService) RouterAdvertisements() []RouterAdvertisement {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.routerAdvertsLocked()
+}
+
+// probeLink runs discovery on one interface and returns what it collected and the
+// number of SLAAC targets probed
+func (s *IPv6MonitorService) probeLink(iface net.Interface, network *models.Network) (*linkProbe, int, error) {
+	conn, err := icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	if err != nil {
+		return nil, 0, fmt.Errorf("opening ICMPv6 socket (needs CAP_NET_RAW): %w", err)
+	}
+	defer conn.Close()
+
+	p := conn.IPv6PacketConn()
+	p.SetMulticastInterface(&iface)
+	p.SetMulticastHopLimit(255) // NDP messages must arrive with hop limit 255
+	p.SetHopLimit(255)
+	p.SetControlMessage(ipv6.FlagInterface, true)
+	// MLDv2 reports are sent to ff02::16 and only delivered to members of that group
+	p.JoinGroup(&iface, &net.IPAddr{IP: allMLDv2Routers})
+
+	var filter ipv6.ICMPFilter
+	filter.SetAll(true)
+	for _, t := range []ipv6.ICMPType{ipv6.ICMPTypeEchoReply, ipv6.ICMPTypeMulticastListenerReport, ipv6.ICMPTypeVersion2MulticastListenerReport,
+		ipv6.ICMPTypeRouterAdvertisement, ipv6.ICMPTypeNeighborSolicitation, ipv6.ICMPTypeNeighborAdvertisement} {
+		filter.Accept(t)
+	}
+	p.SetICMPFilter(&filter)
+
+	probe := &linkProbe{
+		iface:   iface,
+		own:     ownAddresses(iface),
+		hosts:   make(map[string]*DiscoveredHost),
+		routers: make(map[string]*RouterAdvertisement),
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 1500)
+		for {
+			n, cm, src, err := p.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if cm != nil && cm.IfIndex != iface.Index {
+				continue
+			}
+			addr, ok := src.(*net.IPAddr)
+			if !ok {
+				continue
+			}
+			probe.record(buf[:n], addr.IP)
+		}
+	}()
+
+	send := func(message []byte, dst net.IP) {
+		if _, err := p.WriteTo(message, nil, &net.IPAddr{IP: dst, Zone: iface.Name}); err != nil {
+			s.logger.Errorf("IPv6 discovery send to %s on %s failed: %v", dst, iface.Name, err)
+		}
+	}
+	send(buildRouterSolicitation(iface.HardwareAddr), allRouters)
+	send(buildEchoRequest(uint16(os.Getpid()), 1), allNodes)
+	if err := sendMLDQuery(iface, buildMLDQuery()); err != nil {
+		s.logger.Infof("MLD query on %s skipped: %v", iface.Name, err)
+	}
+
+	time.Sleep(raWait)
+
+	targets := s.SolicitationTargets(network, probe.prefixes(network), probe.addresses())
+	for i, target := range targets {
+		send(buildNeighborSolicitation(target, iface.HardwareAddr), solicitedNodeAddress(target))
+		// Pace the solicitations so switches and hosts are not flooded
+		if i%64 == 63 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	time.Sleep(replyWait)
+	conn.Close()
+	<-done
+	return probe, len(targets), nil
+}
+
+// record adds the host behind an ICMPv6 message to the probe
+func (l *linkProbe) record(message []byte, src net.IP) {
+	sighting, ok := parseICMPv6(message, src)
+	if !ok || l.own[sighting.IP] {
+		return
+	}
+	if ip := net.ParseIP(sighting.IP); ip == nil || ip.IsUnspecified() || ip.IsMulticast() {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	host, ok := l.hosts[sighting.IP]
+	if !ok {
+		host = &DiscoveredHost{IP: sighting.IP, Interface: l.iface.Name}
+		l.hosts[sighting.IP] = host
+	}
+	if host.MAC == "" {
+		host.MAC = sighting.MAC
+	}
+	if !containsString(host.Sources, sighting.Source) {
+		host.Sources = append(host.Sources, sighting.Source)
+	}
+	if sighting.Router != nil {
+		sighting.Router.Interface = l.iface.Name
+		l.routers[sighting.IP] = sighting.Router
+	}
+}
+
+// prefixes returns the /64 prefixes to guess SLAAC addresses in: the network's
+// configured prefix, the prefixes of the interface's own addresses and the prefixes
+// routers announced for SLAAC
+func (l *linkProbe) prefixes(network *models.Network) []*net.IPNet {
+	var prefixes []*net.IPNet
+	seen := make(map[string]bool)
+	add := func(prefix *net.IPNet) {
+		if prefix == nil || prefix.IP.To4() != nil || prefix.IP.IsLinkLocalUnicast() {
+			return
+		}
+		if ones, _ := prefix.Mask.Size(); ones > 64 {
+			prefix = &net.IPNet{IP: prefix.IP.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}
+		}
+		if !seen[prefix.String()] {
+			seen[prefix.String()] = true
+			prefixes = append(prefixes, prefix)
+		}
+	}
+
+	if value := network.GetIPv6Prefix(); value != "" {
+		if _, prefix, err := net.ParseCIDR(value); err == nil {
+			add(prefix)
+		}
+	}
+	if addrs, err := l.iface.Addrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				add(&net.IPNet{IP: ipNet.IP.Mask(ipNet.Mask), Mask: ipNet.Mask})
+			}
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, ra := range l.routers {
+		for _, announced := range ra.Prefixes {
+			if !announced.Autonomous {
+				continue
+			}
+			if _, prefix, err := net.ParseCIDR(announced.Prefix); err == nil {
+				add(prefix)
+			}
+		}
+	}
+	return prefixes
+}
+
+// addresses returns the addresses heard so far. Their interface identifiers are
+// reused in the global prefixes, which finds hosts whose link-local and global
+// addresses share an identifier.
+func (l *linkProbe) addresses() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	addresses := make([]string, 0, len(l.hosts))
+	for ip := range l.hosts {
+		addresses = append(addresses, ip)
+	}
+	return addresses
+}
+
+// SolicitationTargets returns the SLAAC addresses discovery sends neighbor
+// solicitations to, guessed for the prefixes from the devices known on the network
+// and the addresses seen on the link. Devices the exclusion rules keep discovery
+// away from are not targeted.
+func (s *IPv6MonitorService) SolicitationTargets(network *models.Network, prefixes []*net.IPNet, seen []string) []net.IP {
+	macs, known, protected := s.knownAddresses(network)
+	var targets []net.IP
+	for _, target := range guessTargets(prefixes, macs, append(known, seen...), maxTargets) {
+		if !protected[string(target.To16()[8:])] {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// knownAddresses returns the MACs and IPv6 addresses of the devices already known on
+// the network, and the interface identifiers of the protected devices left out
+func (s *IPv6MonitorService) knownAddresses(network *models.Network) ([]string, []string, map[string]bool) {
+	devices, err := s.deviceService.FindByNetworkID(network.ID)
+	if err != nil {
+		s.logger.Errorf("Failed to load devices for IPv6 discovery: %v", err)
+		return nil, nil, nil
+	}
+
+	var macs, known []string
+	protected := make(map[string]bool)
+	for _, d := range devices {
+		var mac string
+		if d.MAC != nil {
+			mac = *d.MAC
+		}
+		var addresses []string
+		for _, addr := range []*string{d.IPv6LinkLocal, d.IPv6UniqueLocal, d.IPv6Global} {
+			if addr != nil {
+				addresses = append(addresses, *addr)
+			}
+		}
+		addresses = append(addresses, d.IPv6Addresses...)
+
+		if s.Exclusions.Blocked(models.ExcludeDiscovery, d.IPv4, mac) != nil {
+			if hw, err := net.ParseMAC(mac); err == nil && len(hw) == 6 {
+				protected[string(eui64InterfaceID(hw))] = true
+			}
+			for _, addr := range addresses {
+				if ip := net.ParseIP(strings.SplitN(addr, "%", 2)[0]); ip != nil && ip.To4() == nil {
+					protected[string(ip.To16()[8:])] = true
+				}
+			}
+			continue
+		}
+		if mac != "" {
+			macs = append(macs, mac)
+		}
+		known = append(known, addresses...)
+	}
+	return macs, known, protected
+}
+
+// fillMissingMACs takes the MACs of hosts that answered without a link-layer
+// address option from the kernel neighbor cache, which the probes just refreshed
+func (s *IPv6MonitorService) fillMissingMACs(hosts []DiscoveredHost) {
+	missing := false
+	for _, host := range hosts {
+		if host.MAC == "" {
+			missing = true
+			break
+		}
+	}
+	if !missing {
+		return
+	}
+
+	neighbors := make(map[string]string)
+	for _, d := range s.readNDPTable() {
+		for _, addr := range []string{d.LinkLocal, d.UniqueLocal, d.Global} {
+			if addr != "" {
+				neighbors[addr] = d.MAC
+			}
+		}
+	}
+	for i := range hosts {
+		if hosts[i].MAC == "" {
+			hosts[i].MAC = neighbors[hosts[i].IP]
+		}
+	}
+}
+
+// discoveredDevice turns a discovered host into the record the device processor
+// merges into the inventory
+func discoveredDevice(host DiscoveredHost) IPv6Device {
+	device := IPv6Device{
+		MAC:       host.MAC,
+		Interface: host.Interface,
+		Timestamp: time.Now(),
+		Source:    models.IPv6SourceActive,
+	}
+	ip := net.ParseIP(host.IP)
+	switch {
+	case ip.IsLinkLocalUnicast():
+		device.LinkLocal = host.IP
+	case isUniqueLocal(ip):
+		device.UniqueLocal = host.IP
+	default:
+		device.Global = host.IP
+	}
+	return device
+}
+
+// linkInterfaces returns the up, multicast capable interfaces attached to a network,
+// matched by the network's IPv6 prefix or IPv4 CIDR. Interfaces without a
+// link-local address cannot send NDP and are left out.
+func linkInterfaces(network *models.Network) ([]net.Interface, error) {
+	var nets []*net.IPNet
+	for _, value := range []string{network.GetIPv6Prefix(), network.CIDR} {
+		if value == "" {
+			continue
+		}
+		if _, ipNet, err := net.ParseCIDR(value); err == nil {
+			nets = append(nets, ipNet)
+		}
+	}
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network interfaces: %w", err)
+	}
+
+	var matched []net.Interface
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		linkLocal, attached := false, false
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			if ipNet.IP.To4() == nil && ipNet.IP.IsLinkLocalUnicast() {
+				linkLocal = true
+			}
+			for _, n := range nets {
+				if n.Contains(ipNet.IP) {
+					attached = true
+				}
+			}
+		}
+		if linkLocal && attached {
+			matched = append(matched, iface)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("no IPv6-capable interface attached to network %s", network.GetDisplayName())
+	}
+	return matched, nil
+}
+
+func ownAddresses(iface net.Interface) map[string]bool {
+	own := make(map[string]bool)
+	if addrs, err := iface.Addrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				own[ipNet.IP.String()] = true
+			}
+		}
+	}
+	return own
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,16 +3,21 @@ package ipv6monitor
 import (
 	"context"
 	"fmt"
-	"log"
 	"net"
 	"os/exec"
 	"regexp"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+
 	"reconya-ai/internal/device"
+	"reconya-ai/internal/exclusion"
+	"reconya-ai/internal/logging"
 	"reconya-ai/internal/network"
 	"reconya-ai/models"
 )
@@ -20,7 +25,7 @@ import (
 type IPv6MonitorService struct {
 	deviceService  *device.DeviceService
 	networkService *network.NetworkService
-	logger         *log.Logger
+	logger         *logging.Logger
 	
 	// Monitoring state
 	isRunning      bool
@@ -37,6 +42,19 @@ type IPv6MonitorService struct {
 	// Channels for async processing
 	deviceChan chan IPv6Device
 	wg         sync.WaitGroup
+	
+	// Active discovery
+	discoveryInterval time.Duration
+	lastDiscovery     map[string]time.Time
+	discoveries       map[string]*DiscoveryResult
+	routerAdverts     map[string]*RouterAdvertisement
+	
+	// neighborEvents is set while netlink delivers neighbor table changes, which
+	// makes polling the neighbor table unnecessary
+	neighborEvents bool
+	
+	// Exclusions skip neighbor solicitations
+	Exclusions *exclusion.ExclusionService
 }
 
 type IPv6Device struct {
@@ -47,7 +65,7 @@ type IPv6Device struct {
 	Interface      string    `json:"interface"`
 	Hostname       string    `json:"hostname"`
 	Timestamp      time.Time `json:"timestamp"`
-	Source         string    `json:"source"` // "ndp", "interface", "multicast"
+	Source         string    `json:"source"` // one of the models.IPv6Source values
 }
 
 type IPv6Address struct {
@@ -57,7 +75,7 @@ type IPv6Address struct {
 	MAC       string `json:"mac"`
 }
 
-func NewIPv6MonitorService(deviceService *device.DeviceService, networkService *network.NetworkService, logger *log.Logger) *IPv6MonitorService {
+func NewIPv6MonitorService(deviceService *device.DeviceService, networkService *network.NetworkService, logger *logging.Logger) *IPv6MonitorService {
 	ctx, cancel := context.WithCancel(context.Background())
 	
 	return &IPv6MonitorService{
@@ -71,6 +89,10 @@ func NewIPv6MonitorService(deviceService *device.DeviceService, networkService *
 		linkLocalEnabled: true,
 		multicastEnabled: true,
 		deviceChan:       make(chan IPv6Device, 100),
+		discoveryInterval: 5 * time.Minute,
+		lastDiscovery:     make(map[string]time.Time),
+		discoveries:       make(map[string]*DiscoveryResult),
+		routerAdverts:     make(map[string]*RouterAdvertisement),
 	}
 }
 
@@ -82,7 +104,7 @@ func (s *IPv6MonitorService) Start() error {
 		return fmt.Errorf("IPv6 monitor service is already running")
 	}
 	
-	s.logger.Println("Starting IPv6 passive monitoring service...")
+	s.logger.Infof("Starting IPv6 passive monitoring service...")
 	
 	// Auto-detect network interfaces and prefixes
 	if err := s.detectNetworkConfiguration(); err != nil {
@@ -107,8 +129,8 @@ func (s *IPv6MonitorService) Start() error {
 		go s.monitorMulticastTraffic()
 	}
 	
-	s.logger.Printf("IPv6 passive monitoring started for interfaces: %v", s.monitorInterfaces)
-	s.logger.Printf("Monitoring IPv6 prefixes: %v", s.hostPrefixes)
+	s.logger.Infof("IPv6 passive monitoring started for interfaces: %v", s.monitorInterfaces)
+	s.logger.Infof("Monitoring IPv6 prefixes: %v", s.hostPrefixes)
 	
 	return nil
 }
@@ -121,7 +143,7 @@ func (s *IPv6MonitorService) Stop() error {
 		return nil
 	}
 	
-	s.logger.Println("Stopping IPv6 passive monitoring service...")
+	s.logger.Infof("Stopping IPv6 passive monitoring service...")
 	
 	s.cancel()
 	s.isRunning = false
@@ -130,7 +152,7 @@ func (s *IPv6MonitorService) Stop() error {
 	close(s.deviceChan)
 	s.wg.Wait()
 	
-	s.logger.Println("IPv6 passive monitoring service stopped")
+	s.logger.Infof("IPv6 passive monitoring service stopped")
 	return nil
 }
 
@@ -194,12 +216,28 @@ func (s *IPv6MonitorService) monitorNDPTable() {
 		case <-s.ctx.Done():
 			return
 		case <-ticker.C:
-			s.scanNDPTable()
+			s.mu.RLock()
+			neighborEvents := s.neighborEvents
+			s.mu.RUnlock()
+			if !neighborEvents {
+				s.scanNDPTable()
+			}
 		}
 	}
 }
 
 func (s *IPv6MonitorService) scanNDPTable() {
+	for _, device := range s.readNDPTable() {
+		select {
+		case s.deviceChan <- device:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// readNDPTable returns the entries of the kernel neighbor cache
+func (s *IPv6MonitorService) readNDPTable() []IPv6Device {
 	var cmd *exec.Cmd
 	
 	switch runtime.GOOS {
@@ -208,24 +246,32 @@ func (s *IPv6MonitorService) scanNDPTable() {
 	case "darwin":
 		cmd = exec.Command("ndp", "-an")
 	default:
-		s.logger.Printf("IPv6 NDP monitoring not supported on %s", runtime.GOOS)
-		return
+		s.logger.Infof("IPv6 NDP monitoring not supported on %s", runtime.GOOS)
+		return nil
 	}
 	
 	output, err := cmd.Output()
 	if err != nil {
-		s.logger.Printf("Failed to get NDP table: %v", err)
-		return
+		s.logger.Errorf("Failed to get NDP table: %v", err)
+		return nil
 	}
 	
-	devices := s.parseNDPOutput(string(output))
-	for _, device := range devices {
-		select {
-		case s.deviceChan <- device:
-		case <-s.ctx.Done():
-			return
-		}
-	}
+	return s.parseNDPOutput(string(output))
+}
+
+// SetNeighborEvents tells the monitor whether neighbor table changes are delivered
+// as they happen. Polling the neighbor table is only a fallback for when they are not.
+func (s *IPv6MonitorService) SetNeighborEvents(active bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.neighborEvents = active
+}
+
+// ProcessNeighbor merges an IPv6 neighbor table entry into the device inventory
+func (s *IPv6MonitorService) ProcessNeighbor(ip, mac, iface string) {
+	device := discoveredDevice(DiscoveredHost{IP: ip, MAC: mac, Interface: iface})
+	device.Source = models.IPv6SourceNDP
+	s.processIPv6Device(device)
 }
 
 func (s *IPv6MonitorService) parseNDPOutput(output string) []IPv6Device {
@@ -250,7 +296,7 @@ func (s *IPv6MonitorService) parseNDPOutput(output string) []IPv6Device {
 func (s *IPv6MonitorService) parseNDPLine(line string) *IPv6Device {
 	var device IPv6Device
 	device.Timestamp = time.Now()
-	device.Source = "ndp"
+	device.Source = models.IPv6SourceNDP
 	
 	switch runtime.GOOS {
 	case "linux":
@@ -317,7 +363,7 @@ func (s *IPv6MonitorService) monitorNetworkInterfaces() {
 func (s *IPv6MonitorService) scanNetworkInterfaces() {
 	interfaces, err := net.Interfaces()
 	if err != nil {
-		s.logger.Printf("Failed to get network interfaces: %v", err)
+		s.logger.Errorf("Failed to get network interfaces: %v", err)
 		return
 	}
 	
@@ -334,7 +380,7 @@ func (s *IPv6MonitorService) scanNetworkInterfaces() {
 		var device IPv6Device
 		device.Interface = iface.Name
 		device.Timestamp = time.Now()
-		device.Source = "interface"
+		device.Source = models.IPv6SourceInterface
 		device.MAC = iface.HardwareAddr.String()
 		
 		for _, addr := range addrs {
@@ -368,15 +414,71 @@ func (s *IPv6MonitorService) scanNetworkInterfaces() {
 	}
 }
 
+// monitorMulticastTraffic listens for the ICMPv6 traffic hosts send on their own:
+// router advertisements, MLD reports and neighbor discovery messages
 func (s *IPv6MonitorService) monitorMulticastTraffic() {
 	defer s.wg.Done()
 	
-	// This would require raw socket access and is more complex
-	// For now, we'll implement a placeholder that could be extended
-	s.logger.Println("IPv6 multicast monitoring placeholder - would require raw socket implementation")
+	conn, err := icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	if err != nil {
+		s.logger.Warnf("IPv6 multicast monitoring disabled, ICMPv6 socket needs CAP_NET_RAW: %v", err)
+		<-s.ctx.Done()
+		return
+	}
+	defer conn.Close()
+	
+	p := conn.IPv6PacketConn()
+	p.SetControlMessage(ipv6.FlagInterface, true)
+	for _, name := range s.monitorInterfaces {
+		if iface, err := net.InterfaceByName(name); err == nil {
+			p.JoinGroup(iface, &net.IPAddr{IP: allMLDv2Routers})
+		}
+	}
 	
-	// Keep the goroutine alive until context is done
-	<-s.ctx.Done()
+	buf := make([]byte, 1500)
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		default:
+		}
+		
+		// Wake up every second to notice shutdown
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, cm, src, err := p.ReadFrom(buf)
+		if err != nil {
+			continue
+		}
+		addr, ok := src.(*net.IPAddr)
+		if !ok {
+			continue
+		}
+		sighting, ok := parseICMPv6(buf[:n], addr.IP)
+		if !ok || sighting.MAC == "" {
+			continue
+		}
+		
+		host := DiscoveredHost{IP: sighting.IP, MAC: sighting.MAC}
+		if cm != nil {
+			if iface, err := net.InterfaceByIndex(cm.IfIndex); err == nil {
+				host.Interface = iface.Name
+			}
+		}
+		if sighting.Router != nil {
+			sighting.Router.Interface = host.Interface
+			s.mu.Lock()
+			s.routerAdverts[sighting.IP] = sighting.Router
+			s.mu.Unlock()
+		}
+		
+		device := discoveredDevice(host)
+		device.Source = models.IPv6SourceMulticast
+		select {
+		case s.deviceChan <- device:
+		case <-s.ctx.Done():
+			return
+		}
+	}
 }
 
 func (s *IPv6MonitorService) deviceProcessor() {
@@ -413,7 +515,7 @@ func (s *IPv6MonitorService) processIPv6Device(ipv6Device IPv6Device) {
 	if ipv6Device.MAC != "" {
 		existingDevice, err = s.deviceService.FindDeviceByMAC(ipv6Device.MAC)
 		if err != nil {
-			s.logger.Printf("Error finding device by MAC: %v", err)
+			s.logger.Errorf("Error finding device by MAC: %v", err)
 		}
 	}
 	
@@ -456,6 +558,15 @@ func (s *IPv6MonitorService) updateDeviceIPv6(device *models.Device, ipv6Device
 		updated = true
 	}
 	
+	// Keep further addresses, such as temporary or additional SLAAC addresses
+	for _, addr := range []string{ipv6Device.LinkLocal, ipv6Device.UniqueLocal, ipv6Device.Global} {
+		if addr == "" || hasIPv6Address(device, addr) {
+			continue
+		}
+		device.AddIPv6Address(addr)
+		updated = true
+	}
+	
 	// Update status to online
 	if device.Status != models.DeviceStatusOnline {
 		device.Status = models.DeviceStatusOnline
@@ -473,9 +584,20 @@ func (s *IPv6MonitorService) updateDeviceIPv6(device *models.Device, ipv6Device
 	if updated {
 		device.UpdatedAt = time.Now()
 		if err := s.deviceService.UpdateDeviceRecord(device); err != nil {
-			s.logger.Printf("Failed to update device with IPv6 info: %v", err)
+			s.logger.Errorf("Failed to update device with IPv6 info: %v", err)
 		} else {
-			s.logger.Printf("Updated device %s with IPv6 addresses", device.Name)
+			s.logger.Infof("Updated device %s with IPv6 addresses", device.Name)
+		}
+	}
+	
+	// Refresh when each address was last seen, so addresses the device stopped
+	// using age out
+	for _, addr := range []string{ipv6Device.LinkLocal, ipv6Device.UniqueLocal, ipv6Device.Global} {
+		if addr == "" {
+			continue
+		}
+		if err := s.deviceService.RecordIPv6Address(device.ID, addr, ipv6Device.Source); err != nil {
+			s.logger.Errorf("Failed to record IPv6 address %s: %v", addr, err)
 		}
 	}
 }
@@ -483,7 +605,7 @@ func (s *IPv6MonitorService) updateDeviceIPv6(device *models.Device, ipv6Device
 func (s *IPv6MonitorService) createIPv6Device(ipv6Device IPv6Device) {
 	// Skip creating IPv6-only devices for now to avoid 0.0.0.0 entries
 	// Instead, log that we found an IPv6-only device
-	s.logger.Printf("Found IPv6-only device (not creating): MAC=%s, IPv6=%s", 
+	s.logger.Debugf("Found IPv6-only device (not creating): MAC=%s, IPv6=%s", 
 		ipv6Device.MAC, 
 		func() string {
 			if ipv6Device.Global != "" {
@@ -500,6 +622,20 @@ func (s *IPv6MonitorService) createIPv6Device(ipv6Device IPv6Device) {
 }
 
 // Helper functions
+func hasIPv6Address(device *models.Device, addr string) bool {
+	for _, known := range []*string{device.IPv6LinkLocal, device.IPv6UniqueLocal, device.IPv6Global} {
+		if known != nil && *known == addr {
+			return true
+		}
+	}
+	for _, known := range device.IPv6Addresses {
+		if known == addr {
+			return true
+		}
+	}
+	return false
+}
+
 func isUniqueLocal(ip net.IP) bool {
 	// Unique Local addresses: fc00::/7 (fc00:: to fdff::)
 	return ip[0] == 0xfc || ip[0] == 0xfd
@@ -543,6 +679,26 @@ func (s *IPv6MonitorService) GetMonitoringStatus() map[string]interface{} {
 		"host_prefixes":      s.hostPrefixes,
 		"link_local_enabled": s.linkLocalEnabled,
 		"multicast_enabled":  s.multicastEnabled,
+		"neighbor_events":    s.neighborEvents,
 		"queue_size":         len(s.deviceChan),
+		"router_advertisements": s.routerAdvertsLocked(),
+		"last_discovery":     s.discoveriesLocked(),
+	}
+}
+
+func (s *IPv6MonitorService) discoveriesLocked() map[string]*DiscoveryResult {
+	discoveries := make(map[string]*DiscoveryResult, len(s.discoveries))
+	for networkID, result := range s.discoveries {
+		discoveries[networkID] = result
+	}
+	return discoveries
+}
+
+func (s *IPv6MonitorService) routerAdvertsLocked() []RouterAdvertisement {
+	ras := make([]RouterAdvertisement, 0, len(s.routerAdverts))
+	for _, ra := range s.routerAdverts {
+		ras = append(ras, *ra)
 	}
+	sort.Slice(ras, func(i, j int) bool { return ras[i].Router < ras[j].Router })
+	return ras
 }
\ No newline at end of file
@@ -0,0 +1,44 @@
+package ipv6monitor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+
+	"golang.org/x/net/ipv6"
+)
+
+// routerAlertHopOpts is a hop-by-hop options header carrying the router alert MLD
+// queries need, padded to 8 bytes
+var routerAlertHopOpts = []byte{0, 0, 5, 2, 0, 0, 1, 0}
+
+// sendMLDQuery sends an MLD general query to all nodes on the interface. Queries must
+// carry a router alert and hop limit 1, so they go out on their own socket.
+func sendMLDQuery(iface net.Interface, query []byte) error {
+	var sockErr error
+	config := net.ListenConfig{Control: func(network, address string, c syscall.RawConn) error {
+		err := c.Control(func(fd uintptr) {
+			sockErr = syscall.SetsockoptString(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_HOPOPTS, string(routerAlertHopOpts))
+		})
+		if err != nil {
+			return err
+		}
+		return sockErr
+	}}
+	conn, err := config.ListenPacket(context.Background(), "ip6:ipv6-icmp", "::")
+	if err != nil {
+		return fmt.Errorf("failed to open MLD socket: %v", err)
+	}
+	defer conn.Close()
+
+	p := ipv6.NewPacketConn(conn)
+	if err := p.SetMulticastInterface(&iface); err != nil {
+		return err
+	}
+	if err := p.SetMulticastHopLimit(1); err != nil {
+		return err
+	}
+	_, err = p.WriteTo(query, nil, &net.IPAddr{IP: allNodes, Zone: iface.Name})
+	return err
+}
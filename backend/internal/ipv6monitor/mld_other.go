@@ -0,0 +1,12 @@
+//go:build !linux
+
+package ipv6monitor
+
+import (
+	"fmt"
+	"net"
+)
+
+func sendMLDQuery(iface net.Interface, query []byte) error {
+	return fmt.Errorf("sending MLD queries is only supported on Linux")
+}
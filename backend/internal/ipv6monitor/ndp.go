@@ -0,0 +1,282 @@
+package ipv6monitor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// ICMPv6 message types used for discovery
+const (
+	icmpEchoRequest        = 128
+	icmpEchoReply          = 129
+	icmpMLDQuery           = 130
+	icmpMLDReport          = 131
+	icmpRouterSolicitation = 133
+	icmpRouterAdvert       = 134
+	icmpNeighborSolicit    = 135
+	icmpNeighborAdvert     = 136
+	icmpMLDv2Report        = 143
+
+	ndpOptionSourceLinkAddr = 1
+	ndpOptionTargetLinkAddr = 2
+	ndpOptionPrefixInfo     = 3
+	ndpOptionRDNSS          = 25
+)
+
+var (
+	allNodes        = net.ParseIP("ff02::1")
+	allRouters      = net.ParseIP("ff02::2")
+	allMLDv2Routers = net.ParseIP("ff02::16")
+)
+
+// RouterAdvertisement is what a router announced on a link
+type RouterAdvertisement struct {
+	Router         string     `json:"router"`
+	MAC            string     `json:"mac,omitempty"`
+	Interface      string     `json:"interface"`
+	RouterLifetime int        `json:"router_lifetime"` // seconds, 0 means not a default router
+	Managed        bool       `json:"managed"`         // addresses are handed out by DHCPv6
+	OtherConfig    bool       `json:"other_config"`    // other settings come from DHCPv6
+	Prefixes       []RAPrefix `json:"prefixes"`
+	RDNSS          []string   `json:"rdnss,omitempty"`
+	RDNSSLifetime  int        `json:"rdnss_lifetime,omitempty"`
+	ReceivedAt     time.Time  `json:"received_at"`
+}
+
+// RAPrefix is a prefix announced in a router advertisement
+type RAPrefix struct {
+	Prefix            string `json:"prefix"`
+	OnLink            bool   `json:"on_link"`
+	Autonomous        bool   `json:"autonomous"` // hosts configure addresses with SLAAC
+	ValidLifetime     int    `json:"valid_lifetime"`
+	PreferredLifetime int    `json:"preferred_lifetime"`
+}
+
+// icmpSighting is a host, and possibly a router advertisement, learned from one
+// ICMPv6 message
+type icmpSighting struct {
+	IP     string
+	MAC    string
+	Source string
+	Router *RouterAdvertisement
+}
+
+func buildEchoRequest(id, seq uint16) []byte {
+	message := []byte{icmpEchoRequest, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(message[4:], id)
+	binary.BigEndian.PutUint16(message[6:], seq)
+	return append(message, []byte("reconya")...)
+}
+
+func buildRouterSolicitation(mac net.HardwareAddr) []byte {
+	message := []byte{icmpRouterSolicitation, 0, 0, 0, 0, 0, 0, 0}
+	return appendLinkAddrOption(message, ndpOptionSourceLinkAddr, mac)
+}
+
+func buildNeighborSolicitation(target net.IP, mac net.HardwareAddr) []byte {
+	message := []byte{icmpNeighborSolicit, 0, 0, 0, 0, 0, 0, 0}
+	message = append(message, target.To16()...)
+	return appendLinkAddrOption(message, ndpOptionSourceLinkAddr, mac)
+}
+
+// buildMLDQuery builds an MLDv2 general query. Listeners answer with reports for every
+// group they joined, sent to ff02::16.
+func buildMLDQuery() []byte {
+	message := []byte{icmpMLDQuery, 0, 0, 0}
+	message = binary.BigEndian.AppendUint16(message, 1000) // maximum response delay in ms
+	message = append(message, 0, 0)
+	message = append(message, net.IPv6unspecified...)
+	return append(message, 2, 125, 0, 0) // robustness 2, interval 125s, no sources
+}
+
+func appendLinkAddrOption(message []byte, option byte, mac net.HardwareAddr) []byte {
+	if len(mac) != 6 {
+		return message
+	}
+	message = append(message, option, 1)
+	return append(message, mac...)
+}
+
+// parseICMPv6 reads the host behind an ICMPv6 message received from src. Echo replies,
+// MLD reports, neighbor solicitations and advertisements and router advertisements
+// are understood.
+func parseICMPv6(message []byte, src net.IP) (icmpSighting, bool) {
+	if len(message) < 4 || src == nil || src.IsUnspecified() {
+		return icmpSighting{}, false
+	}
+	sighting := icmpSighting{IP: src.String()}
+
+	switch message[0] {
+	case icmpEchoReply:
+		sighting.Source = "echo"
+	case icmpMLDReport, icmpMLDv2Report:
+		sighting.Source = "mld"
+	case icmpNeighborSolicit:
+		if len(message) < 24 {
+			return icmpSighting{}, false
+		}
+		sighting.Source = "ndp"
+		sighting.MAC = linkAddrOption(message[24:], ndpOptionSourceLinkAddr)
+	case icmpNeighborAdvert:
+		if len(message) < 24 {
+			return icmpSighting{}, false
+		}
+		sighting.Source = "ndp"
+		sighting.IP = net.IP(message[8:24]).String()
+		sighting.MAC = linkAddrOption(message[24:], ndpOptionTargetLinkAddr)
+	case icmpRouterAdvert:
+		ra, err := parseRouterAdvertisement(message, src)
+		if err != nil {
+			return icmpSighting{}, false
+		}
+		sighting.Source = "ra"
+		sighting.MAC = ra.MAC
+		sighting.Router = ra
+	default:
+		return icmpSighting{}, false
+	}
+	return sighting, true
+}
+
+// parseRouterAdvertisement reads the flags, lifetime, prefixes and DNS servers of a
+// router advertisement
+func parseRouterAdvertisement(message []byte, src net.IP) (*RouterAdvertisement, error) {
+	if len(message) < 16 || message[0] != icmpRouterAdvert {
+		return nil, fmt.Errorf("not a router advertisement")
+	}
+	ra := &RouterAdvertisement{
+		Router:         src.String(),
+		Managed:        message[5]&0x80 != 0,
+		OtherConfig:    message[5]&0x40 != 0,
+		RouterLifetime: int(binary.BigEndian.Uint16(message[6:])),
+		Prefixes:       []RAPrefix{},
+		ReceivedAt:     time.Now(),
+	}
+
+	err := walkOptions(message[16:], func(option byte, value []byte) {
+		switch option {
+		case ndpOptionSourceLinkAddr:
+			if len(value) >= 6 {
+				ra.MAC = strings.ToUpper(net.HardwareAddr(value[:6]).String())
+			}
+		case ndpOptionPrefixInfo:
+			if len(value) < 30 || value[0] > 128 {
+				return
+			}
+			mask := net.CIDRMask(int(value[0]), 128)
+			prefix := net.IPNet{IP: net.IP(value[14:30]).Mask(mask), Mask: mask}
+			ra.Prefixes = append(ra.Prefixes, RAPrefix{
+				Prefix:            prefix.String(),
+				OnLink:            value[1]&0x80 != 0,
+				Autonomous:        value[1]&0x40 != 0,
+				ValidLifetime:     int(binary.BigEndian.Uint32(value[2:])),
+				PreferredLifetime: int(binary.BigEndian.Uint32(value[6:])),
+			})
+		case ndpOptionRDNSS:
+			if len(value) < 6 {
+				return
+			}
+			ra.RDNSSLifetime = int(binary.BigEndian.Uint32(value[2:]))
+			for i := 6; i+16 <= len(value); i += 16 {
+				ra.RDNSS = append(ra.RDNSS, net.IP(value[i:i+16]).String())
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ra, nil
+}
+
+// walkOptions calls fn with the type and value of each NDP option. Option lengths
+// are in units of 8 bytes and include the type and length bytes.
+func walkOptions(options []byte, fn func(option byte, value []byte)) error {
+	for len(options) >= 2 {
+		length := int(options[1]) * 8
+		if length == 0 || length > len(options) {
+			return fmt.Errorf("malformed NDP option %d", options[0])
+		}
+		fn(options[0], options[2:length])
+		options = options[length:]
+	}
+	return nil
+}
+
+func linkAddrOption(options []byte, want byte) string {
+	mac := ""
+	walkOptions(options, func(option byte, value []byte) {
+		if option == want && len(value) >= 6 && mac == "" {
+			mac = strings.ToUpper(net.HardwareAddr(value[:6]).String())
+		}
+	})
+	return mac
+}
+
+// solicitedNodeAddress returns the multicast group a neighbor solicitation for the
+// target is sent to
+func solicitedNodeAddress(target net.IP) net.IP {
+	ip := net.ParseIP("ff02::1:ff00:0")
+	copy(ip[13:], target.To16()[13:])
+	return ip
+}
+
+// eui64InterfaceID returns the modified EUI-64 interface identifier SLAAC derives
+// from a MAC address
+func eui64InterfaceID(mac net.HardwareAddr) []byte {
+	if len(mac) != 6 {
+		return nil
+	}
+	return []byte{mac[0] ^ 0x02, mac[1], mac[2], 0xff, 0xfe, mac[3], mac[4], mac[5]}
+}
+
+// guessTargets builds addresses hosts probably configured with SLAAC: the EUI-64
+// address of each known MAC and the interface identifier of each known address in
+// every /64 prefix. Known addresses are left out and at most limit targets are
+// returned.
+func guessTargets(prefixes []*net.IPNet, macs []string, known []string, limit int) []net.IP {
+	skip := make(map[string]bool)
+	var iids [][]byte
+	for _, addr := range known {
+		ip := net.ParseIP(strings.SplitN(addr, "%", 2)[0])
+		if ip == nil || ip.To4() != nil {
+			continue
+		}
+		skip[ip.String()] = true
+		iid := ip.To16()[8:]
+		// Manually assigned identifiers like ::1 are not derived from the host
+		if iid[0]|iid[1]|iid[2]|iid[3]|iid[4]|iid[5] != 0 {
+			iids = append(iids, iid)
+		}
+	}
+	for _, value := range macs {
+		if mac, err := net.ParseMAC(value); err == nil {
+			if iid := eui64InterfaceID(mac); iid != nil {
+				iids = append(iids, iid)
+			}
+		}
+	}
+
+	var targets []net.IP
+	for _, prefix := range prefixes {
+		if ones, bits := prefix.Mask.Size(); bits != 128 || ones > 64 {
+			continue
+		}
+		for _, iid := range iids {
+			target := make(net.IP, net.IPv6len)
+			copy(target, prefix.IP.To16()[:8])
+			copy(target[8:], iid)
+			if skip[target.String()] {
+				continue
+			}
+			skip[target.String()] = true
+			targets = append(targets, target)
+			if len(targets) >= limit {
+				return targets
+			}
+		}
+	}
+	return targets
+}
@@ -0,0 +1,124 @@
+package ipv6monitor
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func routerAdvert() []byte {
+	message := []byte{icmpRouterAdvert, 0, 0, 0, 64, 0xc0, 0x07, 0x08, 0, 0, 0, 0, 0, 0, 0, 0}
+	// Source link-layer address
+	message = append(message, ndpOptionSourceLinkAddr, 1, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55)
+	// Prefix information for 2001:db8:1::/64, on-link and autonomous
+	prefix := []byte{ndpOptionPrefixInfo, 4, 64, 0xc0}
+	prefix = binary.BigEndian.AppendUint32(prefix, 86400)
+	prefix = binary.BigEndian.AppendUint32(prefix, 14400)
+	prefix = append(prefix, 0, 0, 0, 0)
+	prefix = append(prefix, net.ParseIP("2001:db8:1::")...)
+	message = append(message, prefix...)
+	// Recursive DNS server
+	rdnss := []byte{ndpOptionRDNSS, 3, 0, 0}
+	rdnss = binary.BigEndian.AppendUint32(rdnss, 600)
+	rdnss = append(rdnss, net.ParseIP("2001:db8:1::53")...)
+	return append(message, rdnss...)
+}
+
+func TestParseRouterAdvertisement(t *testing.T) {
+	ra, err := parseRouterAdvertisement(routerAdvert(), net.ParseIP("fe80::1"))
+	require.NoError(t, err)
+	assert.Equal(t, "fe80::1", ra.Router)
+	assert.Equal(t, "00:11:22:33:44:55", ra.MAC)
+	assert.Equal(t, 1800, ra.RouterLifetime)
+	assert.True(t, ra.Managed)
+	assert.True(t, ra.OtherConfig)
+	require.Len(t, ra.Prefixes, 1)
+	assert.Equal(t, RAPrefix{Prefix: "2001:db8:1::/64", OnLink: true, Autonomous: true, ValidLifetime: 86400, PreferredLifetime: 14400}, ra.Prefixes[0])
+	assert.Equal(t, []string{"2001:db8:1::53"}, ra.RDNSS)
+	assert.Equal(t, 600, ra.RDNSSLifetime)
+
+	truncated := routerAdvert()
+	truncated[17] = 9 // option claims more bytes than the message holds
+	_, err = parseRouterAdvertisement(truncated, net.ParseIP("fe80::1"))
+	assert.Error(t, err)
+}
+
+func TestParseICMPv6(t *testing.T) {
+	src := net.ParseIP("fe80::211:22ff:fe33:4455")
+
+	sighting, ok := parseICMPv6([]byte{icmpEchoReply, 0, 0, 0, 0, 1, 0, 1}, src)
+	require.True(t, ok)
+	assert.Equal(t, icmpSighting{IP: "fe80::211:22ff:fe33:4455", Source: "echo"}, sighting)
+
+	sighting, ok = parseICMPv6([]byte{icmpMLDv2Report, 0, 0, 0, 0, 0, 0, 0}, src)
+	require.True(t, ok)
+	assert.Equal(t, "mld", sighting.Source)
+
+	// A neighbor advertisement names the target address and its link-layer address
+	target := net.ParseIP("2001:db8:1::211:22ff:fe33:4455")
+	advert := append([]byte{icmpNeighborAdvert, 0, 0, 0, 0x60, 0, 0, 0}, target...)
+	advert = append(advert, ndpOptionTargetLinkAddr, 1, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55)
+	sighting, ok = parseICMPv6(advert, src)
+	require.True(t, ok)
+	assert.Equal(t, "2001:db8:1:0:211:22ff:fe33:4455", sighting.IP)
+	assert.Equal(t, "00:11:22:33:44:55", sighting.MAC)
+	assert.Equal(t, "ndp", sighting.Source)
+
+	sighting, ok = parseICMPv6(routerAdvert(), net.ParseIP("fe80::1"))
+	require.True(t, ok)
+	assert.Equal(t, "ra", sighting.Source)
+	require.NotNil(t, sighting.Router)
+	assert.Equal(t, "00:11:22:33:44:55", sighting.MAC)
+
+	_, ok = parseICMPv6([]byte{icmpEchoRequest, 0, 0, 0, 0, 1, 0, 1}, src)
+	assert.False(t, ok, "requests from other scanners are not hosts answering")
+	_, ok = parseICMPv6([]byte{icmpNeighborSolicit, 0, 0, 0, 0, 0, 0, 0}, net.IPv6unspecified)
+	assert.False(t, ok, "duplicate address detection comes from the unspecified address")
+}
+
+func TestBuildNeighborSolicitation(t *testing.T) {
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+	target := net.ParseIP("2001:db8:1::1")
+
+	message := buildNeighborSolicitation(target, mac)
+	require.Len(t, message, 32)
+	assert.Equal(t, byte(icmpNeighborSolicit), message[0])
+	assert.Equal(t, target.To16(), net.IP(message[8:24]))
+	assert.Equal(t, "00:11:22:33:44:55", linkAddrOption(message[24:], ndpOptionSourceLinkAddr))
+
+	assert.Len(t, buildMLDQuery(), 28)
+	assert.Len(t, buildRouterSolicitation(mac), 16)
+	assert.Len(t, buildRouterSolicitation(nil), 8, "interfaces without a MAC send no link-layer option")
+}
+
+func TestSolicitedNodeAddress(t *testing.T) {
+	assert.Equal(t, "ff02::1:ff33:4455", solicitedNodeAddress(net.ParseIP("2001:db8::211:22ff:fe33:4455")).String())
+}
+
+func TestEUI64InterfaceID(t *testing.T) {
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+	assert.Equal(t, []byte{0x02, 0x11, 0x22, 0xff, 0xfe, 0x33, 0x44, 0x55}, eui64InterfaceID(mac))
+	assert.Nil(t, eui64InterfaceID(nil))
+}
+
+func TestGuessTargets(t *testing.T) {
+	_, prefix, _ := net.ParseCIDR("2001:db8:1::/64")
+	_, narrow, _ := net.ParseCIDR("2001:db8:2::/112")
+	known := []string{
+		"fe80::aabb:ccff:fedd:eeff%eth0",
+		"2001:db8:1::211:22ff:fe33:4455", // already known, not probed again
+		"2001:db8:1::1",                  // manually assigned, its identifier is not reused
+	}
+
+	targets := guessTargets([]*net.IPNet{prefix, narrow}, []string{"00:11:22:33:44:55"}, known, 100)
+	var got []string
+	for _, target := range targets {
+		got = append(got, target.String())
+	}
+	assert.Equal(t, []string{"2001:db8:1:0:aabb:ccff:fedd:eeff"}, got)
+
+	assert.Len(t, guessTargets([]*net.IPNet{prefix}, []string{"00:11:22:33:44:55", "00:11:22:33:44:66"}, nil, 1), 1)
+}
@@ -0,0 +1,378 @@
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/metrics"
+	"reconya-ai/internal/util"
+	"reconya-ai/models"
+)
+
+var logger = logging.For("jobqueue")
+
+var (
+	jobsTotal = metrics.NewCounterVec("reconya_scan_jobs_total",
+		"Scan jobs run by type and result", "type", "result")
+	jobDuration = metrics.NewHistogramVec("reconya_scan_job_duration_seconds",
+		"Duration of scan jobs by type", metrics.ScanBuckets, "type")
+	workersBusy = metrics.NewGaugeVec("reconya_scan_job_workers_busy",
+		"Scan job workers currently running a job", "type")
+)
+
+const (
+	// DefaultMaxAttempts is used for jobs enqueued without a limit
+	DefaultMaxAttempts = 3
+	// pollInterval bounds how long a due retry waits for a worker when nothing wakes the pool
+	pollInterval = 5 * time.Second
+	// retention is how long finished jobs stay listed
+	retention = 7 * 24 * time.Hour
+)
+
+// Handler runs one job. It should stop when ctx is cancelled, which happens when
+// the job is cancelled or reconYa shuts down.
+type Handler func(ctx context.Context, job *models.ScanJob) error
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a handler error that retrying cannot fix, such as a deleted device
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// Backoff is the delay before retrying a job that failed its nth attempt:
+// 30s, 2m, 8m, then 15m
+func Backoff(attempt int) time.Duration {
+	delay := 30 * time.Second
+	for i := 1; i < attempt && delay < 15*time.Minute; i++ {
+		delay *= 4
+	}
+	if delay > 15*time.Minute {
+		delay = 15 * time.Minute
+	}
+	return delay
+}
+
+type runKey struct{}
+
+// Progress records how far the job running with ctx is, percent from 0 to 100.
+// It does nothing outside a job.
+func Progress(ctx context.Context, percent int, stage string) {
+	job, ok := ctx.Value(runKey{}).(*runningJob)
+	if !ok {
+		return
+	}
+	if err := job.service.Repository.SetProgress(context.Background(), job.id, percent, stage); err != nil {
+		logger.Warnf("Error recording progress of job %s: %v", job.id, err)
+	}
+}
+
+// SetResult stores what the job running with ctx reports back, encoded as JSON
+func SetResult(ctx context.Context, result interface{}) error {
+	job, ok := ctx.Value(runKey{}).(*runningJob)
+	if !ok {
+		return nil
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return util.RetryOnLock(func() error {
+		return job.service.Repository.SetResult(context.Background(), job.id, encoded)
+	})
+}
+
+type runningJob struct {
+	service *JobQueueService
+	id      string
+}
+
+type pool struct {
+	jobType models.ScanJobType
+	workers int
+	handler Handler
+	wake    chan struct{}
+}
+
+// JobQueueService runs port, web and fingerprint scans from the scan_jobs table.
+// Each job type has its own pool of workers, so a slow web scan never holds up
+// port scans, and jobs survive restarts.
+type JobQueueService struct {
+	Repository *db.ScanJobRepository
+	pools      map[models.ScanJobType]*pool
+	running    map[string]context.CancelFunc
+	mutex      sync.Mutex
+}
+
+func NewJobQueueService(repository *db.ScanJobRepository) *JobQueueService {
+	service := &JobQueueService{
+		Repository: repository,
+		pools:      make(map[models.ScanJobType]*pool),
+		running:    make(map[string]context.CancelFunc),
+	}
+	service.registerMetrics()
+	return service
+}
+
+// Register sets the handler and number of workers for a job type. It must be
+// called before Run.
+func (s *JobQueueService) Register(jobType models.ScanJobType, workers int, handler Handler) {
+	if workers < 1 {
+		workers = 1
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pools[jobType] = &pool{jobType: jobType, workers: workers, handler: handler, wake: make(chan struct{}, workers)}
+}
+
+// Enqueue adds a job for a device. When the device already has a waiting or
+// running job of the same type that job is returned instead, with its priority
+// raised if the new one is more urgent.
+func (s *JobQueueService) Enqueue(job *models.ScanJob) (*models.ScanJob, error) {
+	if job.DeviceID == "" {
+		return nil, fmt.Errorf("scan job has no device")
+	}
+	if _, ok := s.pool(job.Type); !ok {
+		return nil, fmt.Errorf("unknown scan job type %q", job.Type)
+	}
+	if job.MaxAttempts < 1 {
+		job.MaxAttempts = DefaultMaxAttempts
+	}
+
+	var created bool
+	stored, err := util.RetryOnLockWithResult(func() (*models.ScanJob, error) {
+		stored, isNew, err := s.Repository.Enqueue(context.Background(), job)
+		created = isNew
+		return stored, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		logger.Debugf("Queued %s job %s for device %s with priority %d", stored.Type, stored.ID, stored.DeviceID, stored.Priority)
+	}
+	s.wake(stored.Type)
+	return stored, nil
+}
+
+// EnqueueDevice is a shorthand for a job without a payload
+func (s *JobQueueService) EnqueueDevice(jobType models.ScanJobType, deviceID string, priority int) (*models.ScanJob, error) {
+	return s.Enqueue(&models.ScanJob{Type: jobType, DeviceID: deviceID, Priority: priority})
+}
+
+// FindByID returns a job, db.ErrNotFound when it does not exist
+func (s *JobQueueService) FindByID(id string) (*models.ScanJob, error) {
+	return s.Repository.FindByID(context.Background(), id)
+}
+
+// List returns the jobs matching the filter
+func (s *JobQueueService) List(filter models.ScanJobFilter) ([]*models.ScanJob, error) {
+	return s.Repository.FindAll(context.Background(), filter)
+}
+
+// Cancel removes a waiting job from the queue or stops a running one. Finished
+// jobs return db.ErrNotFound.
+func (s *JobQueueService) Cancel(id string) (*models.ScanJob, error) {
+	job, err := s.Repository.Cancel(context.Background(), id)
+	if err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	if cancel, ok := s.running[id]; ok {
+		cancel()
+	}
+	s.mutex.Unlock()
+	logger.Infof("Cancelled %s job %s for device %s", job.Type, job.ID, job.DeviceID)
+	return job, nil
+}
+
+// SetPriority re-prioritizes a waiting or running job
+func (s *JobQueueService) SetPriority(id string, priority int) (*models.ScanJob, error) {
+	job, err := s.Repository.SetPriority(context.Background(), id, priority)
+	if err != nil {
+		return nil, err
+	}
+	s.wake(job.Type)
+	return job, nil
+}
+
+// Run requeues jobs interrupted by the last shutdown and runs the worker pools
+// until ctx is cancelled. Jobs still running then are requeued on the next start.
+func (s *JobQueueService) Run(ctx context.Context) error {
+	requeued, err := s.Repository.RequeueRunning(ctx)
+	if err != nil {
+		return err
+	}
+	if requeued > 0 {
+		logger.Infof("Requeued %d scan jobs interrupted by the last shutdown", requeued)
+	}
+
+	s.mutex.Lock()
+	pools := make([]*pool, 0, len(s.pools))
+	for _, p := range s.pools {
+		pools = append(pools, p)
+	}
+	s.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	for _, p := range pools {
+		logger.Infof("Starting %d %s workers", p.workers, p.jobType)
+		for i := 0; i < p.workers; i++ {
+			wg.Add(1)
+			go func(p *pool) {
+				defer wg.Done()
+				s.work(ctx, p)
+			}(p)
+		}
+	}
+
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return nil
+		case <-cleanup.C:
+			if removed, err := s.Repository.DeleteFinishedBefore(ctx, time.Now().Add(-retention)); err != nil {
+				logger.Errorf("Error removing finished scan jobs: %v", err)
+			} else if removed > 0 {
+				logger.Debugf("Removed %d finished scan jobs", removed)
+			}
+		}
+	}
+}
+
+func (s *JobQueueService) work(ctx context.Context, p *pool) {
+	for ctx.Err() == nil {
+		job, err := s.Repository.ClaimNext(ctx, p.jobType, time.Now())
+		if err != nil && ctx.Err() == nil {
+			logger.Errorf("Error claiming %s job: %v", p.jobType, err)
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+			case <-p.wake:
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+		s.execute(ctx, p, job)
+	}
+}
+
+func (s *JobQueueService) execute(ctx context.Context, p *pool, job *models.ScanJob) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	jobCtx = context.WithValue(jobCtx, runKey{}, &runningJob{service: s, id: job.ID})
+	s.mutex.Lock()
+	s.running[job.ID] = cancel
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.running, job.ID)
+		s.mutex.Unlock()
+		cancel()
+	}()
+
+	logger.Debugf("Running %s job %s for device %s, attempt %d of %d", job.Type, job.ID, job.DeviceID, job.Attempts, job.MaxAttempts)
+	workersBusy.Inc(string(job.Type))
+	startedAt := time.Now()
+	err := p.handler(jobCtx, job)
+	jobDuration.Observe(time.Since(startedAt).Seconds(), string(job.Type))
+	workersBusy.Dec(string(job.Type))
+
+	switch {
+	case ctx.Err() != nil:
+		// Shutting down, the job stays running and is requeued on the next start
+		return
+	case jobCtx.Err() != nil:
+		jobsTotal.Inc(string(job.Type), "cancelled")
+		return
+	case err == nil:
+		jobsTotal.Inc(string(job.Type), "completed")
+		s.finish(job, models.ScanJobCompleted, "")
+		return
+	}
+
+	var permanent permanentError
+	if job.Attempts < job.MaxAttempts && !errors.As(err, &permanent) {
+		delay := Backoff(job.Attempts)
+		logger.Warnf("%s job %s for device %s failed, retrying in %v: %v", job.Type, job.ID, job.DeviceID, delay, err)
+		jobsTotal.Inc(string(job.Type), "retried")
+		if retryErr := util.RetryOnLock(func() error {
+			return s.Repository.Retry(context.Background(), job.ID, err.Error(), time.Now().Add(delay))
+		}); retryErr != nil {
+			logger.Errorf("Error requeueing %s job %s: %v", job.Type, job.ID, retryErr)
+		}
+		return
+	}
+	logger.Errorf("%s job %s for device %s failed: %v", job.Type, job.ID, job.DeviceID, err)
+	jobsTotal.Inc(string(job.Type), "failed")
+	s.finish(job, models.ScanJobFailed, err.Error())
+}
+
+func (s *JobQueueService) finish(job *models.ScanJob, status models.ScanJobStatus, jobError string) {
+	err := util.RetryOnLock(func() error {
+		return s.Repository.Finish(context.Background(), job.ID, status, jobError)
+	})
+	if err != nil {
+		logger.Errorf("Error finishing %s job %s: %v", job.Type, job.ID, err)
+	}
+}
+
+func (s *JobQueueService) pool(jobType models.ScanJobType) (*pool, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	p, ok := s.pools[jobType]
+	return p, ok
+}
+
+// wake lets an idle worker of the pool claim a new job without waiting for the poll
+func (s *JobQueueService) wake(jobType models.ScanJobType) {
+	p, ok := s.pool(jobType)
+	if !ok {
+		return
+	}
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// registerMetrics reports the queue of the most recently created service
+func (s *JobQueueService) registerMetrics() {
+	metrics.NewCollector("reconya_scan_jobs", "Waiting and running scan jobs by type and status", metrics.GaugeType,
+		[]string{"type", "status"}, func(emit func(float64, ...string)) {
+			counts, err := s.Repository.CountActive(context.Background())
+			if err != nil {
+				logger.Debugf("Error counting scan jobs for metrics: %v", err)
+				return
+			}
+			for _, jobType := range models.ScanJobTypes {
+				for _, status := range []models.ScanJobStatus{models.ScanJobQueued, models.ScanJobRunning} {
+					emit(float64(counts[jobType][status]), string(jobType), string(status))
+				}
+			}
+		})
+	metrics.NewCollector("reconya_scan_job_workers", "Scan job workers started by type", metrics.GaugeType,
+		[]string{"type"}, func(emit func(float64, ...string)) {
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+			for _, jobType := range models.ScanJobTypes {
+				if p, ok := s.pools[jobType]; ok {
+					emit(float64(p.workers), string(jobType))
+				}
+			}
+		})
+}
@@ -0,0 +1,111 @@
+package logging
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is a log record kept for the log viewer
+type Entry struct {
+	// Seq increases with every entry, clients pass the last one they saw as After
+	Seq       uint64            `json:"seq"`
+	Time      time.Time         `json:"time"`
+	Level     string            `json:"level"`
+	Component string            `json:"component,omitempty"`
+	Message   string            `json:"message"`
+	Attrs     map[string]string `json:"attrs,omitempty"`
+	level     slog.Level
+}
+
+func newEntry(record slog.Record, component string, attrs []slog.Attr) Entry {
+	entry := Entry{
+		Time:      record.Time,
+		Level:     LevelName(record.Level),
+		Component: component,
+		Message:   record.Message,
+		level:     record.Level,
+	}
+	if len(attrs) > 0 {
+		entry.Attrs = make(map[string]string, len(attrs))
+		for _, attr := range attrs {
+			entry.Attrs[attr.Key] = attr.Value.Resolve().String()
+		}
+	}
+	return entry
+}
+
+// Filter selects buffered entries. Zero values match everything but MinLevel, whose
+// zero value is info.
+type Filter struct {
+	// After skips entries up to and including this sequence number
+	After     uint64
+	MinLevel  slog.Level
+	Component string
+	// Query matches the message case insensitively
+	Query string
+	// Limit keeps the newest entries
+	Limit int
+}
+
+func (f Filter) matches(entry Entry) bool {
+	if entry.Seq <= f.After || entry.level < f.MinLevel {
+		return false
+	}
+	if f.Component != "" && entry.Component != f.Component {
+		return false
+	}
+	if f.Query != "" && !strings.Contains(strings.ToLower(entry.Message), strings.ToLower(f.Query)) {
+		return false
+	}
+	return true
+}
+
+// Buffer is a fixed size ring of the latest entries
+type Buffer struct {
+	entries []Entry
+	next    int
+	full    bool
+	seq     uint64
+	mutex   sync.Mutex
+}
+
+func NewBuffer(size int) *Buffer {
+	return &Buffer{entries: make([]Entry, size)}
+}
+
+// Add stores an entry, overwriting the oldest one when the buffer is full
+func (b *Buffer) Add(entry Entry) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.seq++
+	entry.Seq = b.seq
+	b.entries[b.next] = entry
+	b.next = (b.next + 1) % len(b.entries)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// Entries returns the entries matching filter, oldest first
+func (b *Buffer) Entries(filter Filter) []Entry {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	ordered := b.entries[:b.next]
+	if b.full {
+		ordered = append(append([]Entry(nil), b.entries[b.next:]...), b.entries[:b.next]...)
+	}
+
+	matched := []Entry{}
+	for _, entry := range ordered {
+		if filter.matches(entry) {
+			matched = append(matched, entry)
+		}
+	}
+	if filter.Limit > 0 && len(matched) > filter.Limit {
+		matched = matched[len(matched)-filter.Limit:]
+	}
+	return matched
+}
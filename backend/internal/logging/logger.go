@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+var (
+	registry      = map[string]*Logger{}
+	registryMutex sync.Mutex
+)
+
+// Logger logs for one component. The printf style helpers only format the message
+// when its level is enabled, structured attributes go through the embedded slog.Logger.
+type Logger struct {
+	*slog.Logger
+	component string
+}
+
+// For returns the logger of a component, such as "portscan" or "web". Components
+// show up in the log viewer once they have a logger.
+func For(component string) *Logger {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if logger, ok := registry[component]; ok {
+		return logger
+	}
+	logger := &Logger{
+		Logger:    slog.New(&handler{state: std, component: component}),
+		component: component,
+	}
+	registry[component] = logger
+	return logger
+}
+
+// Component is the name the logger was created with
+func (l *Logger) Component() string {
+	return l.component
+}
+
+// With returns a logger of the same component that adds attributes to every message
+func (l *Logger) With(args ...interface{}) *Logger {
+	return &Logger{Logger: l.Logger.With(args...), component: l.component}
+}
+
+func (l *Logger) logf(level slog.Level, format string, args ...interface{}) {
+	ctx := context.Background()
+	if !l.Enabled(ctx, level) {
+		return
+	}
+	l.Log(ctx, level, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.logf(slog.LevelDebug, format, args...)
+}
+
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.logf(slog.LevelInfo, format, args...)
+}
+
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.logf(slog.LevelWarn, format, args...)
+}
+
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.logf(slog.LevelError, format, args...)
+}
+
+// Fatalf logs at error level and exits
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+	l.Log(context.Background(), slog.LevelError, fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
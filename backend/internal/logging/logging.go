@@ -0,0 +1,289 @@
+// Package logging is reconya's structured logger. Every message carries a level and
+// the component that logged it, levels can be changed per component at runtime and
+// the latest entries are kept in memory for the log viewer.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+
+	// DefaultBufferSize is how many entries the log viewer can show
+	DefaultBufferSize = 2000
+)
+
+// Options configure the output installed by Setup
+type Options struct {
+	// Format is FormatText or FormatJSON
+	Format string
+	// Level applies to components without their own level
+	Level slog.Level
+	// Levels overrides the level of single components
+	Levels map[string]slog.Level
+	// BufferSize is the capacity of the in-memory ring buffer
+	BufferSize int
+	Output     io.Writer
+}
+
+type state struct {
+	mutex        sync.RWMutex
+	output       slog.Handler
+	defaultLevel slog.Level
+	levels       map[string]slog.Level
+	buffer       *Buffer
+}
+
+var std = &state{
+	output:       slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}),
+	defaultLevel: slog.LevelInfo,
+	levels:       map[string]slog.Level{},
+	buffer:       NewBuffer(DefaultBufferSize),
+}
+
+// Setup replaces the output and levels of every logger, including the ones already
+// returned by For. Messages of the standard log package are routed through it too.
+func Setup(opts Options) error {
+	if opts.Output == nil {
+		opts.Output = os.Stdout
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultBufferSize
+	}
+
+	handlerOptions := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var output slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", FormatText:
+		output = slog.NewTextHandler(opts.Output, handlerOptions)
+	case FormatJSON:
+		output = slog.NewJSONHandler(opts.Output, handlerOptions)
+	default:
+		return fmt.Errorf("unknown log format %q, expected text or json", opts.Format)
+	}
+
+	levels := make(map[string]slog.Level, len(opts.Levels))
+	for component, level := range opts.Levels {
+		levels[component] = level
+	}
+
+	std.mutex.Lock()
+	std.output = output
+	std.defaultLevel = opts.Level
+	std.levels = levels
+	std.buffer = NewBuffer(opts.BufferSize)
+	std.mutex.Unlock()
+
+	// log.Printf calls that are left, from dependencies for instance, log at info
+	slog.SetDefault(slog.New(&handler{state: std}))
+	log.SetFlags(0)
+	return nil
+}
+
+func (s *state) levelFor(component string) slog.Level {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if level, ok := s.levels[component]; ok {
+		return level
+	}
+	return s.defaultLevel
+}
+
+// SetLevel changes the level of a component, an empty component changes the default
+func SetLevel(component string, level slog.Level) {
+	std.mutex.Lock()
+	defer std.mutex.Unlock()
+	if component == "" {
+		std.defaultLevel = level
+		return
+	}
+	std.levels[component] = level
+}
+
+// SetLevels replaces the default level and every component level, as a reloaded
+// configuration does
+func SetLevels(defaultLevel slog.Level, levels map[string]slog.Level) {
+	copied := make(map[string]slog.Level, len(levels))
+	for component, level := range levels {
+		copied[component] = level
+	}
+	std.mutex.Lock()
+	defer std.mutex.Unlock()
+	std.defaultLevel = defaultLevel
+	std.levels = copied
+}
+
+// ResetLevel makes a component follow the default level again
+func ResetLevel(component string) {
+	std.mutex.Lock()
+	defer std.mutex.Unlock()
+	delete(std.levels, component)
+}
+
+// ComponentLevel is the level of a component as reported by Levels
+type ComponentLevel struct {
+	Component string `json:"component"`
+	Level     string `json:"level"`
+	// Default is true when the component has no level of its own
+	Default bool `json:"default"`
+}
+
+// Levels reports the default level and the level of every known component
+func Levels() (string, []ComponentLevel) {
+	known := map[string]bool{}
+	registryMutex.Lock()
+	for name := range registry {
+		known[name] = true
+	}
+	registryMutex.Unlock()
+
+	std.mutex.RLock()
+	defer std.mutex.RUnlock()
+	for name := range std.levels {
+		known[name] = true
+	}
+	names := make([]string, 0, len(known))
+	for name := range known {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	levels := make([]ComponentLevel, 0, len(names))
+	for _, name := range names {
+		level, ok := std.levels[name]
+		if !ok {
+			level = std.defaultLevel
+		}
+		levels = append(levels, ComponentLevel{Component: name, Level: LevelName(level), Default: !ok})
+	}
+	return LevelName(std.defaultLevel), levels
+}
+
+// Recent returns the buffered entries matching the filter, oldest first
+func Recent(filter Filter) []Entry {
+	std.mutex.RLock()
+	buffer := std.buffer
+	std.mutex.RUnlock()
+	return buffer.Entries(filter)
+}
+
+// ParseLevel accepts debug, info, warn, warning and error in any case
+func ParseLevel(value string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", value)
+}
+
+// ParseLevels parses per component levels written as "portscan=debug,web=warn"
+func ParseLevels(value string) (map[string]slog.Level, error) {
+	levels := map[string]slog.Level{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		component, levelName, ok := strings.Cut(pair, "=")
+		component = strings.TrimSpace(component)
+		if !ok || component == "" {
+			return nil, fmt.Errorf("invalid component level %q, expected component=level", pair)
+		}
+		level, err := ParseLevel(levelName)
+		if err != nil {
+			return nil, err
+		}
+		levels[component] = level
+	}
+	return levels, nil
+}
+
+// LevelName is the lower case name of a level as accepted by ParseLevel
+func LevelName(level slog.Level) string {
+	switch {
+	case level < slog.LevelInfo:
+		return "debug"
+	case level < slog.LevelWarn:
+		return "info"
+	case level < slog.LevelError:
+		return "warn"
+	}
+	return "error"
+}
+
+// handler applies the level of its component, writes to the configured output and
+// keeps a copy of every record it lets through in the buffer
+type handler struct {
+	state     *state
+	component string
+	attrs     []slog.Attr
+	group     string
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.state.levelFor(h.component)
+}
+
+func (h *handler) Handle(ctx context.Context, record slog.Record) error {
+	attrs := make([]slog.Attr, 0, len(h.attrs)+record.NumAttrs())
+	attrs = append(attrs, h.attrs...)
+	record.Attrs(func(attr slog.Attr) bool {
+		if h.group != "" {
+			attr.Key = h.group + "." + attr.Key
+		}
+		attrs = append(attrs, attr)
+		return true
+	})
+
+	h.state.mutex.RLock()
+	output, buffer := h.state.output, h.state.buffer
+	h.state.mutex.RUnlock()
+
+	buffer.Add(newEntry(record, h.component, attrs))
+
+	out := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	if h.component != "" {
+		out.AddAttrs(slog.String("component", h.component))
+	}
+	out.AddAttrs(attrs...)
+	return output.Handle(ctx, out)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append(append([]slog.Attr(nil), h.attrs...), attrs...)
+	if h.group != "" {
+		for i := len(h.attrs); i < len(clone.attrs); i++ {
+			clone.attrs[i].Key = h.group + "." + clone.attrs[i].Key
+		}
+	}
+	return &clone
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	if clone.group != "" {
+		name = clone.group + "." + name
+	}
+	clone.group = name
+	return &clone
+}
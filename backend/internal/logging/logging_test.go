@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTest(t *testing.T, opts Options) *bytes.Buffer {
+	t.Helper()
+	var output bytes.Buffer
+	opts.Output = &output
+	require.NoError(t, Setup(opts))
+	return &output
+}
+
+func TestJSONOutputCarriesLevelAndComponent(t *testing.T) {
+	output := setupTest(t, Options{Format: FormatJSON, Level: slog.LevelInfo})
+
+	For("portscan").Warnf("nmap exited with %d", 1)
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(output.Bytes(), &line))
+	assert.Equal(t, "WARN", line["level"])
+	assert.Equal(t, "portscan", line["component"])
+	assert.Equal(t, "nmap exited with 1", line["msg"])
+}
+
+func TestComponentLevelsOverrideTheDefault(t *testing.T) {
+	output := setupTest(t, Options{
+		Level:  slog.LevelInfo,
+		Levels: map[string]slog.Level{"scanner": slog.LevelDebug},
+	})
+
+	For("scanner").Debugf("probing %s", "192.168.1.1")
+	For("web").Debugf("hidden")
+	assert.Contains(t, output.String(), "probing 192.168.1.1")
+	assert.NotContains(t, output.String(), "hidden")
+
+	SetLevel("web", slog.LevelDebug)
+	SetLevel("scanner", slog.LevelError)
+	For("web").Debugf("now visible")
+	For("scanner").Infof("now hidden")
+	assert.Contains(t, output.String(), "now visible")
+	assert.NotContains(t, output.String(), "now hidden")
+
+	ResetLevel("web")
+	defaultLevel, levels := Levels()
+	assert.Equal(t, "info", defaultLevel)
+	assert.Contains(t, levels, ComponentLevel{Component: "scanner", Level: "error"})
+	assert.Contains(t, levels, ComponentLevel{Component: "web", Level: "info", Default: true})
+}
+
+func TestStandardLogIsRouted(t *testing.T) {
+	output := setupTest(t, Options{Format: FormatText})
+
+	log.Printf("legacy message")
+
+	assert.True(t, strings.Contains(output.String(), "level=INFO"))
+	assert.Contains(t, output.String(), `msg="legacy message"`)
+	require.NotEmpty(t, Recent(Filter{Query: "legacy"}))
+}
+
+func TestRecentFiltersBufferedEntries(t *testing.T) {
+	setupTest(t, Options{Level: slog.LevelDebug, BufferSize: 3})
+	logger := For("ipv6monitor")
+
+	logger.Debugf("one")
+	logger.Infof("two")
+	logger.With("interface", "eth0").Errorf("three")
+	For("web").Warnf("four")
+
+	entries := Recent(Filter{})
+	require.Len(t, entries, 3)
+	assert.Equal(t, "two", entries[0].Message)
+	assert.Equal(t, "four", entries[2].Message)
+
+	errors := Recent(Filter{MinLevel: slog.LevelError})
+	require.Len(t, errors, 1)
+	assert.Equal(t, "eth0", errors[0].Attrs["interface"])
+
+	assert.Len(t, Recent(Filter{Component: "ipv6monitor"}), 2)
+	assert.Len(t, Recent(Filter{After: entries[1].Seq}), 1)
+	assert.Len(t, Recent(Filter{Limit: 1}), 1)
+}
+
+func TestParseLevels(t *testing.T) {
+	levels, err := ParseLevels("portscan=debug, web=WARN,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]slog.Level{"portscan": slog.LevelDebug, "web": slog.LevelWarn}, levels)
+
+	_, err = ParseLevels("portscan")
+	assert.Error(t, err)
+	_, err = ParseLevels("portscan=verbose")
+	assert.Error(t, err)
+}
@@ -0,0 +1,330 @@
+// Package metrics keeps counters, gauges and histograms about reconya's own health
+// and writes them in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Type string
+
+const (
+	CounterType   Type = "counter"
+	GaugeType     Type = "gauge"
+	HistogramType Type = "histogram"
+)
+
+var (
+	// DefaultBuckets suit operations taking milliseconds to seconds
+	DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	// ScanBuckets suit nmap runs taking seconds to minutes
+	ScanBuckets = []float64{1, 2.5, 5, 10, 20, 30, 60, 90, 120, 180, 300}
+)
+
+// Default is the registry served on /metrics. Metrics created with the New functions
+// are registered on it.
+var Default = NewRegistry()
+
+type family interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry holds metric families by name
+type Registry struct {
+	families map[string]family
+	mutex    sync.RWMutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+// register adds a family, replacing one registered under the same name so that
+// services created again, as in tests, keep reporting
+func (r *Registry) register(f family) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.families[f.name()] = f
+}
+
+// Write writes every family in the text exposition format, sorted by name
+func (r *Registry) Write(w io.Writer) error {
+	r.mutex.RLock()
+	families := make([]family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mutex.RUnlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name() < families[j].name() })
+
+	buffered := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(buffered)
+	}
+	return buffered.Flush()
+}
+
+type desc struct {
+	metricName string
+	help       string
+	typ        Type
+	labels     []string
+}
+
+func (d *desc) name() string {
+	return d.metricName
+}
+
+func (d *desc) writeHeader(w *bufio.Writer) {
+	help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.metricName, help, d.metricName, d.typ)
+}
+
+// labelValues pads or cuts values to the declared labels
+func (d *desc) labelValues(values []string) []string {
+	normalized := make([]string, len(d.labels))
+	copy(normalized, values)
+	return normalized
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+}
+
+// vec holds the series of a counter or gauge
+type vec struct {
+	desc
+	series map[string]*series
+	mutex  sync.Mutex
+}
+
+func newVec(name, help string, typ Type, labels []string) vec {
+	return vec{desc: desc{metricName: name, help: help, typ: typ, labels: labels}, series: make(map[string]*series)}
+}
+
+func (v *vec) update(labelValues []string, apply func(current float64) float64) {
+	values := v.labelValues(labelValues)
+	key := strings.Join(values, "\xff")
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labelValues: values}
+		v.series[key] = s
+	}
+	s.value = apply(s.value)
+}
+
+func (v *vec) write(w *bufio.Writer) {
+	v.writeHeader(w)
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	// Unlabeled metrics are reported as zero before their first update
+	if len(v.labels) == 0 && len(v.series) == 0 {
+		writeSample(w, v.metricName, nil, nil, 0)
+		return
+	}
+	for _, key := range sortedKeys(v.series) {
+		s := v.series[key]
+		writeSample(w, v.metricName, v.labels, s.labelValues, s.value)
+	}
+}
+
+// CounterVec counts events, partitioned by labels
+type CounterVec struct {
+	vec
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{vec: newVec(name, help, CounterType, labels)}
+	Default.register(c)
+	return c
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increases the counter, negative values are ignored
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return
+	}
+	c.update(labelValues, func(current float64) float64 { return current + value })
+}
+
+// GaugeVec holds values that go up and down, partitioned by labels
+type GaugeVec struct {
+	vec
+}
+
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{vec: newVec(name, help, GaugeType, labels)}
+	Default.register(g)
+	return g
+}
+
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.update(labelValues, func(float64) float64 { return value })
+}
+
+func (g *GaugeVec) Add(value float64, labelValues ...string) {
+	g.update(labelValues, func(current float64) float64 { return current + value })
+}
+
+func (g *GaugeVec) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+func (g *GaugeVec) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+// HistogramVec samples observations such as durations into buckets
+type HistogramVec struct {
+	desc
+	buckets []float64
+	series  map[string]*histogramSeries
+	mutex   sync.Mutex
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	h := &HistogramVec{
+		desc:    desc{metricName: name, help: help, typ: HistogramType, labels: labels},
+		buckets: sorted,
+		series:  make(map[string]*histogramSeries),
+	}
+	Default.register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	values := h.labelValues(labelValues)
+	key := strings.Join(values, "\xff")
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{labelValues: values, counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.writeHeader(w)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.labels) == 0 && len(h.series) == 0 {
+		h.series[""] = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+	}
+
+	bucketLabels := append(append([]string(nil), h.labels...), "le")
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		for i, bound := range h.buckets {
+			writeSample(w, h.metricName+"_bucket", bucketLabels, append(append([]string(nil), s.labelValues...), formatValue(bound)), float64(s.counts[i]))
+		}
+		writeSample(w, h.metricName+"_bucket", bucketLabels, append(append([]string(nil), s.labelValues...), "+Inf"), float64(s.count))
+		writeSample(w, h.metricName+"_sum", h.labels, s.labelValues, s.sum)
+		writeSample(w, h.metricName+"_count", h.labels, s.labelValues, float64(s.count))
+	}
+}
+
+// Collector reports values computed when the metrics are scraped, such as queue
+// lengths or inventory counts
+type Collector struct {
+	desc
+	collect func(emit func(value float64, labelValues ...string))
+}
+
+// NewCollector registers a family whose samples come from collect at scrape time
+func NewCollector(name, help string, typ Type, labels []string, collect func(emit func(value float64, labelValues ...string))) *Collector {
+	c := &Collector{desc: desc{metricName: name, help: help, typ: typ, labels: labels}, collect: collect}
+	Default.register(c)
+	return c
+}
+
+// NewGaugeFunc registers an unlabeled gauge read from fn at scrape time
+func NewGaugeFunc(name, help string, fn func() float64) *Collector {
+	return NewCollector(name, help, GaugeType, nil, func(emit func(float64, ...string)) {
+		emit(fn())
+	})
+}
+
+func (c *Collector) write(w *bufio.Writer) {
+	c.writeHeader(w)
+	c.collect(func(value float64, labelValues ...string) {
+		writeSample(w, c.metricName, c.labels, c.labelValues(labelValues), value)
+	})
+}
+
+func writeSample(w *bufio.Writer, name string, labels, labelValues []string, value float64) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, label, escapeLabelValue(labelValues[i]))
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatValue(value))
+	w.WriteByte('\n')
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
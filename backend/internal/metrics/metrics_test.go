@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T) string {
+	var out strings.Builder
+	require.NoError(t, Default.Write(&out))
+	return out.String()
+}
+
+func TestCounterAndGaugeExposition(t *testing.T) {
+	counter := NewCounterVec("test_events_total", "Events seen", "result")
+	counter.Inc("success")
+	counter.Add(2, "success")
+	counter.Inc(`bad"value`)
+	counter.Add(-5, "success")
+
+	gauge := NewGaugeVec("test_queue_depth", "Queued items")
+	gauge.Inc()
+	gauge.Inc()
+	gauge.Dec()
+
+	out := scrape(t)
+	assert.Contains(t, out, "# HELP test_events_total Events seen\n# TYPE test_events_total counter\n")
+	assert.Contains(t, out, `test_events_total{result="success"} 3`+"\n")
+	assert.Contains(t, out, `test_events_total{result="bad\"value"} 1`+"\n")
+	assert.Contains(t, out, "# TYPE test_queue_depth gauge\ntest_queue_depth 1\n")
+}
+
+func TestUnlabeledMetricsStartAtZero(t *testing.T) {
+	NewCounterVec("test_unused_total", "Never incremented")
+	assert.Contains(t, scrape(t), "test_unused_total 0\n")
+}
+
+func TestHistogramBucketsAreCumulative(t *testing.T) {
+	histogram := NewHistogramVec("test_duration_seconds", "Durations", []float64{1, 0.1}, "network")
+	histogram.Observe(0.05, "10.0.0.0/24")
+	histogram.Observe(0.5, "10.0.0.0/24")
+	histogram.Observe(5, "10.0.0.0/24")
+
+	out := scrape(t)
+	assert.Contains(t, out, `test_duration_seconds_bucket{network="10.0.0.0/24",le="0.1"} 1`+"\n")
+	assert.Contains(t, out, `test_duration_seconds_bucket{network="10.0.0.0/24",le="1"} 2`+"\n")
+	assert.Contains(t, out, `test_duration_seconds_bucket{network="10.0.0.0/24",le="+Inf"} 3`+"\n")
+	assert.Contains(t, out, `test_duration_seconds_sum{network="10.0.0.0/24"} 5.55`+"\n")
+	assert.Contains(t, out, `test_duration_seconds_count{network="10.0.0.0/24"} 3`+"\n")
+}
+
+func TestCollectorsAreReadAtScrapeTime(t *testing.T) {
+	depth := 0.0
+	NewGaugeFunc("test_live_depth", "Live depth", func() float64 { return depth })
+	NewCollector("test_devices", "Devices", GaugeType, []string{"status", "type"}, func(emit func(float64, ...string)) {
+		emit(4, "online", "router")
+		emit(1, "offline")
+	})
+
+	depth = 7
+	out := scrape(t)
+	assert.Contains(t, out, "test_live_depth 7\n")
+	assert.Contains(t, out, `test_devices{status="online",type="router"} 4`+"\n")
+	assert.Contains(t, out, `test_devices{status="offline",type=""} 1`+"\n")
+}
+
+func TestRegisteringAgainReplacesTheFamily(t *testing.T) {
+	NewGaugeFunc("test_replaced", "First", func() float64 { return 1 })
+	NewGaugeFunc("test_replaced", "Second", func() float64 { return 2 })
+
+	out := scrape(t)
+	assert.Equal(t, 1, strings.Count(out, "# TYPE test_replaced gauge"))
+	assert.Contains(t, out, "test_replaced 2\n")
+}
@@ -0,0 +1,144 @@
+package neighbor
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"time"
+)
+
+// Netlink constants used to read neighbor messages. They are defined here so the
+// parser builds and is tested on every platform.
+const (
+	rtmNewNeigh = 28
+	rtmDelNeigh = 29
+
+	ndaDst    = 1
+	ndaLLAddr = 2
+
+	afInet  = 2
+	afInet6 = 10
+
+	ndmsgLength  = 12
+	rtattrLength = 4
+)
+
+// Neighbor unreachability detection states of a kernel neighbor entry
+const (
+	nudIncomplete = 0x01
+	nudReachable  = 0x02
+	nudStale      = 0x04
+	nudDelay      = 0x08
+	nudProbe      = 0x10
+	nudFailed     = 0x20
+	nudNoARP      = 0x40
+	nudPermanent  = 0x80
+)
+
+var stateNames = []struct {
+	state uint16
+	name  string
+}{
+	{nudIncomplete, "incomplete"},
+	{nudReachable, "reachable"},
+	{nudStale, "stale"},
+	{nudDelay, "delay"},
+	{nudProbe, "probe"},
+	{nudFailed, "failed"},
+	{nudNoARP, "noarp"},
+	{nudPermanent, "permanent"},
+}
+
+// Sighting is a neighbor table entry added, changed or removed by the kernel
+type Sighting struct {
+	IP        string    `json:"ip"`
+	MAC       string    `json:"mac,omitempty"`
+	IfIndex   int       `json:"if_index"`
+	Interface string    `json:"interface,omitempty"`
+	IPv6      bool      `json:"ipv6"`
+	State     string    `json:"state"`
+	Deleted   bool      `json:"deleted"`
+	Time      time.Time `json:"time"`
+}
+
+// Reachable reports whether the kernel recently confirmed the neighbor answers
+func (s Sighting) Reachable() bool {
+	return !s.Deleted && s.State == "reachable"
+}
+
+// Resolved reports whether the entry holds a usable link-layer address, that is
+// resolution did not fail and the entry was not removed
+func (s Sighting) Resolved() bool {
+	if s.Deleted || s.MAC == "" {
+		return false
+	}
+	switch s.State {
+	case "incomplete", "failed", "noarp":
+		return false
+	}
+	return true
+}
+
+// stateName returns the name of a NUD state
+func stateName(state uint16) string {
+	for _, s := range stateNames {
+		if state&s.state != 0 {
+			return s.name
+		}
+	}
+	return "none"
+}
+
+// parseNeighborMessage reads an RTM_NEWNEIGH or RTM_DELNEIGH message body: an ndmsg
+// followed by route attributes carrying the address and link-layer address
+func parseNeighborMessage(messageType uint16, body []byte, now time.Time) (Sighting, bool) {
+	if messageType != rtmNewNeigh && messageType != rtmDelNeigh {
+		return Sighting{}, false
+	}
+	if len(body) < ndmsgLength {
+		return Sighting{}, false
+	}
+	family := body[0]
+	if family != afInet && family != afInet6 {
+		return Sighting{}, false
+	}
+
+	sighting := Sighting{
+		IfIndex: int(int32(binary.NativeEndian.Uint32(body[4:]))),
+		IPv6:    family == afInet6,
+		State:   stateName(binary.NativeEndian.Uint16(body[8:])),
+		Deleted: messageType == rtmDelNeigh,
+		Time:    now,
+	}
+
+	attrs := body[ndmsgLength:]
+	for len(attrs) >= rtattrLength {
+		length := int(binary.NativeEndian.Uint16(attrs[0:]))
+		attrType := binary.NativeEndian.Uint16(attrs[2:])
+		if length < rtattrLength || length > len(attrs) {
+			return Sighting{}, false
+		}
+		value := attrs[rtattrLength:length]
+		switch attrType {
+		case ndaDst:
+			if len(value) == net.IPv4len || len(value) == net.IPv6len {
+				sighting.IP = net.IP(value).String()
+			}
+		case ndaLLAddr:
+			if len(value) == 6 {
+				sighting.MAC = strings.ToUpper(net.HardwareAddr(value).String())
+			}
+		}
+		// Attributes are aligned to 4 bytes
+		aligned := (length + 3) &^ 3
+		if aligned > len(attrs) {
+			break
+		}
+		attrs = attrs[aligned:]
+	}
+
+	if sighting.IP == "" {
+		return Sighting{}, false
+	}
+	return sighting, true
+}
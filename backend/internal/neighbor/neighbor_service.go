@@ -0,0 +1,213 @@
+package neighbor
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/ipv6monitor"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/network"
+	"reconya-ai/models"
+)
+
+var logger = logging.For("neighbor")
+
+// NeighborService follows the kernel neighbor tables over netlink and marks devices
+// present as soon as the kernel resolves or confirms them. While the subscription
+// is down the IPv6 monitor falls back to polling the neighbor table.
+type NeighborService struct {
+	DeviceService      *device.DeviceService
+	NetworkService     *network.NetworkService
+	IPv6MonitorService *ipv6monitor.IPv6MonitorService
+	// presenceInterval is how often the same neighbor refreshes its device
+	presenceInterval time.Duration
+	// subscribe follows the neighbor tables, replaced in tests
+	subscribe        func(done <-chan bool, ready func(), handle func(Sighting)) error
+	retryInterval    time.Duration
+	networksTTL      time.Duration
+	pushed           map[string]time.Time
+	interfaces       map[int]string
+	networks         []models.Network
+	networksLoadedAt time.Time
+	active           bool
+	mutex            sync.Mutex
+}
+
+func NewNeighborService(deviceService *device.DeviceService, networkService *network.NetworkService, ipv6MonitorService *ipv6monitor.IPv6MonitorService) *NeighborService {
+	return &NeighborService{
+		DeviceService:      deviceService,
+		NetworkService:     networkService,
+		IPv6MonitorService: ipv6MonitorService,
+		presenceInterval:   30 * time.Second,
+		subscribe:          subscribe,
+		retryInterval:      time.Minute,
+		networksTTL:        time.Minute,
+		pushed:             make(map[string]time.Time),
+		interfaces:         make(map[int]string),
+	}
+}
+
+// Run subscribes to neighbor table changes until done is closed. The service is
+// active only while subscribed; when the subscription fails it is retried, and
+// polling covers the gap.
+func (s *NeighborService) Run(done <-chan bool) {
+	for {
+		err := s.subscribe(done, func() { s.setActive(true) }, s.Handle)
+		s.setActive(false)
+		if err == nil {
+			return
+		}
+		logger.Warnf("Neighbor table events unavailable, polling instead: %v", err)
+
+		select {
+		case <-done:
+			return
+		case <-time.After(s.retryInterval):
+		}
+	}
+}
+
+// Active reports whether neighbor table events are being received
+func (s *NeighborService) Active() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.active
+}
+
+func (s *NeighborService) setActive(active bool) {
+	s.mutex.Lock()
+	changed := s.active != active
+	s.active = active
+	if !active {
+		// A new subscription starts with a fresh dump, so every entry is pushed again
+		s.pushed = make(map[string]time.Time)
+	}
+	s.mutex.Unlock()
+
+	if changed && s.IPv6MonitorService != nil {
+		s.IPv6MonitorService.SetNeighborEvents(active)
+	}
+}
+
+// Handle marks the device behind a neighbor entry present. Entries are pushed when
+// they first resolve and then whenever the kernel confirms them reachable, at most
+// once per presence interval.
+func (s *NeighborService) Handle(sighting Sighting) {
+	key := sighting.IP + "|" + sighting.MAC
+	if !sighting.Resolved() {
+		if sighting.Deleted {
+			s.mutex.Lock()
+			delete(s.pushed, key)
+			s.mutex.Unlock()
+		}
+		return
+	}
+
+	s.mutex.Lock()
+	last, seen := s.pushed[key]
+	if seen && (!sighting.Reachable() || time.Since(last) < s.presenceInterval) {
+		s.mutex.Unlock()
+		return
+	}
+	s.pushed[key] = time.Now()
+	s.mutex.Unlock()
+
+	sighting.Interface = s.interfaceName(sighting.IfIndex)
+	if sighting.IPv6 {
+		if s.IPv6MonitorService != nil {
+			s.IPv6MonitorService.ProcessNeighbor(sighting.IP, sighting.MAC, sighting.Interface)
+		}
+		return
+	}
+	s.markPresent(sighting)
+}
+
+// markPresent refreshes the device holding an IPv4 neighbor, or adds it when the
+// address belongs to a known network
+func (s *NeighborService) markPresent(sighting Sighting) {
+	existing, err := s.DeviceService.FindByIPv4(sighting.IP)
+	if err != nil {
+		logger.Errorf("Failed to look up neighbor %s: %v", sighting.IP, err)
+		return
+	}
+
+	// A device at this address with another MAC may have been replaced, which
+	// CreateOrUpdate sorts out
+	if existing != nil && (existing.MAC == nil || *existing.MAC == "" || strings.EqualFold(*existing.MAC, sighting.MAC)) {
+		now := time.Now()
+		existing.Status = models.DeviceStatusOnline
+		existing.LastSeenOnlineAt = &now
+		if existing.MAC == nil || *existing.MAC == "" {
+			existing.MAC = &sighting.MAC
+		}
+		if err := s.DeviceService.UpdateDeviceRecord(existing); err != nil {
+			logger.Errorf("Failed to update device %s from neighbor table: %v", sighting.IP, err)
+		}
+		return
+	}
+
+	networkID := ""
+	if existing != nil {
+		networkID = existing.NetworkID
+	} else if n := s.networkFor(sighting.IP); n != nil {
+		networkID = n.ID
+	}
+	if networkID == "" {
+		return
+	}
+
+	d := &models.Device{
+		IPv4:      sighting.IP,
+		MAC:       &sighting.MAC,
+		NetworkID: networkID,
+		Status:    models.DeviceStatusOnline,
+	}
+	if vendor := s.DeviceService.LookupVendor(sighting.MAC); vendor != "" {
+		d.Vendor = &vendor
+	}
+	if _, err := s.DeviceService.CreateOrUpdate(d); err != nil {
+		logger.Errorf("Failed to save neighbor %s: %v", sighting.IP, err)
+	}
+}
+
+// networkFor returns the known network containing an IPv4 address
+func (s *NeighborService) networkFor(ip string) *models.Network {
+	s.mutex.Lock()
+	if s.networks == nil || time.Since(s.networksLoadedAt) > s.networksTTL {
+		s.mutex.Unlock()
+		networks, err := s.NetworkService.FindAll()
+		if err != nil {
+			logger.Errorf("Failed to load networks for neighbor events: %v", err)
+			return nil
+		}
+		s.mutex.Lock()
+		s.networks = networks
+		s.networksLoadedAt = time.Now()
+	}
+	defer s.mutex.Unlock()
+
+	for i := range s.networks {
+		if s.networks[i].ContainsIPv4(ip) {
+			n := s.networks[i]
+			return &n
+		}
+	}
+	return nil
+}
+
+func (s *NeighborService) interfaceName(index int) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if name, ok := s.interfaces[index]; ok {
+		return name
+	}
+	iface, err := net.InterfaceByIndex(index)
+	if err != nil {
+		return ""
+	}
+	s.interfaces[index] = iface.Name
+	return iface.Name
+}
@@ -0,0 +1,90 @@
+package neighbor
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rtattr(attrType uint16, value []byte) []byte {
+	attr := make([]byte, rtattrLength, rtattrLength+len(value)+3)
+	binary.NativeEndian.PutUint16(attr[0:], uint16(rtattrLength+len(value)))
+	binary.NativeEndian.PutUint16(attr[2:], attrType)
+	attr = append(attr, value...)
+	for len(attr)%4 != 0 {
+		attr = append(attr, 0)
+	}
+	return attr
+}
+
+func neighborMessage(family byte, ifindex int, state uint16, ip net.IP, mac net.HardwareAddr) []byte {
+	body := make([]byte, ndmsgLength)
+	body[0] = family
+	binary.NativeEndian.PutUint32(body[4:], uint32(ifindex))
+	binary.NativeEndian.PutUint16(body[8:], state)
+	body = append(body, rtattr(ndaDst, ip)...)
+	if mac != nil {
+		body = append(body, rtattr(ndaLLAddr, mac)...)
+	}
+	return body
+}
+
+func TestParseNeighborMessage(t *testing.T) {
+	now := time.Now()
+	mac, _ := net.ParseMAC("02:00:00:00:00:02")
+
+	sighting, ok := parseNeighborMessage(rtmNewNeigh, neighborMessage(afInet, 3, nudReachable, net.ParseIP("10.9.0.2").To4(), mac), now)
+	require.True(t, ok)
+	assert.Equal(t, Sighting{IP: "10.9.0.2", MAC: "02:00:00:00:00:02", IfIndex: 3, State: "reachable", Time: now}, sighting)
+	assert.True(t, sighting.Reachable())
+	assert.True(t, sighting.Resolved())
+
+	sighting, ok = parseNeighborMessage(rtmNewNeigh, neighborMessage(afInet6, 3, nudStale, net.ParseIP("fe80::2"), mac), now)
+	require.True(t, ok)
+	assert.True(t, sighting.IPv6)
+	assert.Equal(t, "fe80::2", sighting.IP)
+	assert.Equal(t, "stale", sighting.State)
+	assert.False(t, sighting.Reachable())
+	assert.True(t, sighting.Resolved())
+
+	sighting, ok = parseNeighborMessage(rtmNewNeigh, neighborMessage(afInet, 3, nudIncomplete, net.ParseIP("10.9.0.3").To4(), nil), now)
+	require.True(t, ok)
+	assert.False(t, sighting.Resolved(), "entries still resolving have no MAC")
+
+	sighting, ok = parseNeighborMessage(rtmDelNeigh, neighborMessage(afInet, 3, nudReachable, net.ParseIP("10.9.0.2").To4(), mac), now)
+	require.True(t, ok)
+	assert.True(t, sighting.Deleted)
+	assert.False(t, sighting.Resolved())
+
+	_, ok = parseNeighborMessage(rtmNewNeigh, neighborMessage(7, 3, nudReachable, net.ParseIP("10.9.0.2").To4(), mac), now)
+	assert.False(t, ok, "only IPv4 and IPv6 entries are reported")
+
+	truncated := neighborMessage(afInet, 3, nudReachable, net.ParseIP("10.9.0.2").To4(), mac)
+	_, ok = parseNeighborMessage(rtmNewNeigh, truncated[:len(truncated)-4], now)
+	assert.False(t, ok)
+}
+
+func TestRun_ActiveOnlyWhileSubscribed(t *testing.T) {
+	s := NewNeighborService(nil, nil, nil)
+	done := make(chan bool)
+	attempts := 0
+	s.subscribe = func(done <-chan bool, ready func(), handle func(Sighting)) error {
+		attempts++
+		assert.False(t, s.Active(), "not active before the subscription is set up")
+		if attempts == 1 {
+			return assert.AnError
+		}
+		ready()
+		assert.True(t, s.Active())
+		return nil
+	}
+	s.retryInterval = time.Millisecond
+
+	s.Run(done)
+	assert.Equal(t, 2, attempts)
+	assert.False(t, s.Active())
+}
@@ -0,0 +1,89 @@
+package neighbor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// rtmgrpNeigh is the netlink multicast group carrying neighbor table changes
+const rtmgrpNeigh = 0x4
+
+// openNeighborSocket opens a netlink socket subscribed to neighbor table changes of
+// both address families and asks the kernel to dump the current table on it
+func openNeighborSocket() (int, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return -1, fmt.Errorf("failed to open netlink socket: %v", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: rtmgrpNeigh}); err != nil {
+		syscall.Close(fd)
+		return -1, fmt.Errorf("failed to subscribe to neighbor events: %v", err)
+	}
+
+	// Wake up every second to notice shutdown
+	timeout := syscall.Timeval{Sec: 1}
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &timeout); err != nil {
+		syscall.Close(fd)
+		return -1, fmt.Errorf("failed to set netlink socket timeout: %v", err)
+	}
+
+	// Entries that existed before the subscription are only seen through a dump
+	request := make([]byte, syscall.NLMSG_HDRLEN+ndmsgLength)
+	binary.NativeEndian.PutUint32(request[0:], uint32(len(request)))
+	binary.NativeEndian.PutUint16(request[4:], syscall.RTM_GETNEIGH)
+	binary.NativeEndian.PutUint16(request[6:], syscall.NLM_F_REQUEST|syscall.NLM_F_DUMP)
+	binary.NativeEndian.PutUint32(request[8:], 1)
+	request[syscall.NLMSG_HDRLEN] = syscall.AF_UNSPEC
+	if err := syscall.Sendto(fd, request, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		syscall.Close(fd)
+		return -1, fmt.Errorf("failed to dump neighbor table: %v", err)
+	}
+	return fd, nil
+}
+
+// readNeighborSocket reads neighbor messages until done is closed. It returns an
+// error when the socket fails, including when the kernel dropped messages because
+// the reader fell behind, so the caller can subscribe again and get a fresh dump.
+func readNeighborSocket(fd int, done <-chan bool, handle func(Sighting)) error {
+	buffer := make([]byte, 64*1024)
+	for {
+		select {
+		case <-done:
+			return nil
+		default:
+		}
+
+		n, _, err := syscall.Recvfrom(fd, buffer, 0)
+		if err != nil {
+			if err == syscall.EAGAIN || err == syscall.EINTR {
+				continue
+			}
+			return fmt.Errorf("failed to read neighbor events: %v", err)
+		}
+
+		messages, err := syscall.ParseNetlinkMessage(buffer[:n])
+		if err != nil {
+			continue
+		}
+		now := time.Now()
+		for _, message := range messages {
+			if sighting, ok := parseNeighborMessage(message.Header.Type, message.Data, now); ok {
+				handle(sighting)
+			}
+		}
+	}
+}
+
+// subscribe reports neighbor table changes until done is closed, calling ready once
+// the socket is subscribed
+func subscribe(done <-chan bool, ready func(), handle func(Sighting)) error {
+	fd, err := openNeighborSocket()
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+	ready()
+	return readNeighborSocket(fd, done, handle)
+}
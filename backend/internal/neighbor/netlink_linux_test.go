@@ -0,0 +1,123 @@
+package neighbor
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// inNetworkNamespace runs fn on a thread moved into a new network namespace. The
+// thread stays locked so it is discarded instead of returning to the scheduler.
+// fn must not call t.FailNow; its error fails the test from the test goroutine.
+func inNetworkNamespace(t *testing.T, fn func() error) {
+	if _, err := exec.LookPath("ip"); err != nil {
+		t.Skip("ip command not available")
+	}
+
+	skipped := make(chan string, 1)
+	failed := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		if err := syscall.Unshare(syscall.CLONE_NEWNET); err != nil {
+			skipped <- err.Error()
+			return
+		}
+		failed <- fn()
+	}()
+	select {
+	case reason := <-skipped:
+		t.Skipf("cannot create a network namespace: %s", reason)
+	case err := <-failed:
+		require.NoError(t, err)
+	}
+}
+
+func ip(args ...string) error {
+	out, err := exec.Command("ip", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ip %v: %v: %s", args, err, out)
+	}
+	return nil
+}
+
+func TestNeighborSocketReportsChanges(t *testing.T) {
+	inNetworkNamespace(t, func() error {
+		for _, args := range [][]string{
+			{"link", "add", "veth0", "type", "veth", "peer", "name", "veth1"},
+			{"link", "set", "veth0", "up"},
+			{"link", "set", "veth1", "up"},
+			{"addr", "add", "10.9.0.1/24", "dev", "veth0"},
+			{"neigh", "add", "10.9.0.5", "lladdr", "02:00:00:00:00:05", "dev", "veth0", "nud", "reachable"},
+		} {
+			if err := ip(args...); err != nil {
+				return err
+			}
+		}
+
+		fd, err := openNeighborSocket()
+		if err != nil {
+			return err
+		}
+		defer syscall.Close(fd)
+
+		sightings := make(chan Sighting, 16)
+		done := make(chan bool)
+		defer close(done)
+		go readNeighborSocket(fd, done, func(s Sighting) { sightings <- s })
+
+		next := func(match func(Sighting) bool) (Sighting, error) {
+			timeout := time.After(5 * time.Second)
+			for {
+				select {
+				case s := <-sightings:
+					if match(s) {
+						return s, nil
+					}
+				case <-timeout:
+					return Sighting{}, fmt.Errorf("timed out waiting for neighbor event")
+				}
+			}
+		}
+
+		// Entries present before subscribing arrive through the dump
+		s, err := next(func(s Sighting) bool { return s.IP == "10.9.0.5" })
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, "02:00:00:00:00:05", s.MAC)
+		assert.True(t, s.Reachable())
+
+		if err := ip("neigh", "add", "10.9.0.6", "lladdr", "02:00:00:00:00:06", "dev", "veth0", "nud", "stale"); err != nil {
+			return err
+		}
+		if s, err = next(func(s Sighting) bool { return s.IP == "10.9.0.6" }); err != nil {
+			return err
+		}
+		assert.Equal(t, "stale", s.State)
+		assert.True(t, s.Resolved())
+
+		if err := ip("-6", "neigh", "add", "fe80::6", "lladdr", "02:00:00:00:00:06", "dev", "veth0", "nud", "reachable"); err != nil {
+			return err
+		}
+		if s, err = next(func(s Sighting) bool { return s.IP == "fe80::6" }); err != nil {
+			return err
+		}
+		assert.True(t, s.IPv6)
+		assert.True(t, s.Reachable())
+
+		if err := ip("neigh", "del", "10.9.0.5", "dev", "veth0"); err != nil {
+			return err
+		}
+		if s, err = next(func(s Sighting) bool { return s.IP == "10.9.0.5" && s.Deleted }); err != nil {
+			return err
+		}
+		assert.False(t, s.Resolved())
+		return nil
+	})
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package neighbor
+
+import "fmt"
+
+func subscribe(done <-chan bool, ready func(), handle func(Sighting)) error {
+	return fmt.Errorf("neighbor table events are only available on Linux")
+}
@@ -2,13 +2,15 @@ package network
 
 import (
 	"context"
-	"log"
 	"reconya-ai/db"
 	"reconya-ai/internal/config"
+	"reconya-ai/internal/logging"
 	"reconya-ai/models"
 	"time"
 )
 
+var logger = logging.For("network")
+
 type NetworkService struct {
 	Config     *config.Config
 	Repository db.NetworkRepository
@@ -33,13 +35,13 @@ func (s *NetworkService) Create(name, cidr, description string) (*models.Network
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}
-	log.Printf("NetworkService.Create: Creating network with CIDR=%s, Name=%s", cidr, name)
+	logger.Debugf("NetworkService.Create: Creating network with CIDR=%s, Name=%s", cidr, name)
 	result, err := s.dbManager.CreateOrUpdateNetwork(s.Repository, context.Background(), network)
 	if err != nil {
-		log.Printf("NetworkService.Create: Error from dbManager: %v", err)
+		logger.Errorf("NetworkService.Create: Error from dbManager: %v", err)
 		return nil, err
 	}
-	log.Printf("NetworkService.Create: Network saved successfully with ID=%s", result.ID)
+	logger.Debugf("NetworkService.Create: Network saved successfully with ID=%s", result.ID)
 	return result, nil
 }
 
@@ -78,17 +80,17 @@ func (s *NetworkService) FindByCIDR(cidr string) (*models.Network, error) {
 
 
 func (s *NetworkService) FindAll() ([]models.Network, error) {
-	log.Printf("NetworkService.FindAll: Fetching all networks")
+	logger.Debugf("NetworkService.FindAll: Fetching all networks")
 	networks, err := s.Repository.FindAll(context.Background())
 	if err != nil {
-		log.Printf("NetworkService.FindAll: Error from repository: %v", err)
+		logger.Errorf("NetworkService.FindAll: Error from repository: %v", err)
 		return nil, err
 	}
 	
-	log.Printf("NetworkService.FindAll: Found %d networks", len(networks))
+	logger.Debugf("NetworkService.FindAll: Found %d networks", len(networks))
 	result := make([]models.Network, len(networks))
 	for i, network := range networks {
-		log.Printf("NetworkService.FindAll: Network %d - ID=%s, CIDR=%s, Name=%s", i, network.ID, network.CIDR, network.Name)
+		logger.Debugf("NetworkService.FindAll: Network %d - ID=%s, CIDR=%s, Name=%s", i, network.ID, network.CIDR, network.Name)
 		result[i] = *network
 	}
 	return result, nil
@@ -111,19 +113,49 @@ func (s *NetworkService) Update(id, name, cidr, description string) (*models.Net
 	return s.dbManager.CreateOrUpdateNetwork(s.Repository, context.Background(), network)
 }
 
+// SetIPv6Prefix configures the IPv6 prefix of a network. With a prefix the network
+// becomes dual-stack, or IPv6-only when it has no IPv4 CIDR. An empty prefix turns
+// IPv6 off again.
+func (s *NetworkService) SetIPv6Prefix(id, prefix string) (*models.Network, error) {
+	network, err := s.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if network == nil {
+		return nil, db.ErrNotFound
+	}
+	
+	if prefix == "" {
+		network.IPv6Prefix = nil
+		network.AddressFamily = models.AddressFamilyIPv4
+	} else {
+		network.IPv6Prefix = &prefix
+		network.AddressFamily = models.AddressFamilyDual
+		if network.CIDR == "" {
+			network.AddressFamily = models.AddressFamilyIPv6
+		}
+	}
+	if err := network.ValidateNetworkAddresses(); err != nil {
+		return nil, err
+	}
+	network.UpdatedAt = time.Now()
+	
+	return s.dbManager.CreateOrUpdateNetwork(s.Repository, context.Background(), network)
+}
+
 func (s *NetworkService) Delete(id string) error {
 	return s.Repository.Delete(context.Background(), id)
 }
 
 func (s *NetworkService) GetDeviceCount(networkID string) (int, error) {
-	log.Printf("NetworkService.GetDeviceCount: Counting devices for network %s", networkID)
+	logger.Debugf("NetworkService.GetDeviceCount: Counting devices for network %s", networkID)
 	
 	count, err := s.Repository.GetDeviceCount(context.Background(), networkID)
 	if err != nil {
-		log.Printf("NetworkService.GetDeviceCount: Error counting devices: %v", err)
+		logger.Errorf("NetworkService.GetDeviceCount: Error counting devices: %v", err)
 		return 0, err
 	}
 	
-	log.Printf("NetworkService.GetDeviceCount: Found %d devices for network %s", count, networkID)
+	logger.Debugf("NetworkService.GetDeviceCount: Found %d devices for network %s", count, networkID)
 	return count, nil
 }
@@ -3,17 +3,19 @@ package nicidentifier
 import (
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"net/http"
 	"reconya-ai/internal/config"
 	"reconya-ai/internal/device"
 	"reconya-ai/internal/eventlog"
+	"reconya-ai/internal/logging"
 	"reconya-ai/internal/network"
 	"reconya-ai/internal/systemstatus"
 	"reconya-ai/models"
 )
 
+var logger = logging.For("nicidentifier")
+
 type NicIdentifierService struct {
 	NetworkService      *network.NetworkService
 	SystemStatusService *systemstatus.SystemStatusService
@@ -38,19 +40,19 @@ func NewNicIdentifierService(
 }
 
 func (s *NicIdentifierService) Identify() {
-	log.Printf("Attempting network identification")
+	logger.Debugf("Attempting network identification")
 	nic := s.getLocalNic()
-	fmt.Printf("NIC: %v\n", nic)
+	logger.Debugf("NIC: %v", nic)
 	
 	// Check for new networks and suggest creation
 	s.CheckForNewNetworks()
 	
 	publicIP, err := s.getPublicIp()
 	if err != nil {
-		log.Printf("Failed to get public IP: %v", err)
+		logger.Errorf("Failed to get public IP: %v", err)
 		return
 	}
-	log.Printf("Public IP Address found: [%v]", publicIP)
+	logger.Infof("Public IP Address found: [%v]", publicIP)
 
 	// Try to find an existing network for the primary NIC for system status
 	var networkEntity *models.Network
@@ -62,17 +64,17 @@ func (s *NicIdentifierService) Identify() {
 			if ip4 != nil {
 				// Calculate /24 network
 				cidr := fmt.Sprintf("%d.%d.%d.0/24", ip4[0], ip4[1], ip4[2])
-				log.Printf("Looking for existing network for primary NIC: %s", cidr)
+				logger.Debugf("Looking for existing network for primary NIC: %s", cidr)
 				
 				// Only look for existing network, don't create automatically
 				existing, err := s.NetworkService.FindByCIDR(cidr)
 				if err != nil {
-					log.Printf("Error searching for network %s: %v", cidr, err)
+					logger.Errorf("Error searching for network %s: %v", cidr, err)
 				} else if existing != nil {
-					log.Printf("Found existing network: %s", existing.CIDR)
+					logger.Debugf("Found existing network: %s", existing.CIDR)
 					networkEntity = existing
 				} else {
-					log.Printf("No existing network found for %s - will be suggested via UI", cidr)
+					logger.Infof("No existing network found for %s - will be suggested via UI", cidr)
 				}
 			}
 		}
@@ -86,7 +88,7 @@ func (s *NicIdentifierService) Identify() {
 
 	savedDevice, err := s.DeviceService.CreateOrUpdate(&localDevice)
 	if err != nil {
-		log.Printf("Failed to save or update local device: %v", err)
+		logger.Errorf("Failed to save or update local device: %v", err)
 		return
 	}
 
@@ -105,15 +107,15 @@ func (s *NicIdentifierService) Identify() {
 		geo, err := s.SystemStatusService.FetchGeolocation(publicIP)
 		if err == nil && geo != nil {
 			systemStatus.Geolocation = geo
-			log.Printf("Added geolocation for public IP %s: %s, %s", publicIP, geo.City, geo.Country)
+			logger.Infof("Added geolocation for public IP %s: %s, %s", publicIP, geo.City, geo.Country)
 		} else if err != nil {
-			log.Printf("Failed to fetch geolocation for public IP %s: %v", publicIP, err)
+			logger.Errorf("Failed to fetch geolocation for public IP %s: %v", publicIP, err)
 		}
 	}
 
 	_, err = s.SystemStatusService.CreateOrUpdate(&systemStatus)
 	if err != nil {
-		log.Printf("Failed to create or update system status: %v", err)
+		logger.Errorf("Failed to create or update system status: %v", err)
 		return
 	}
 
@@ -131,7 +133,7 @@ func (s *NicIdentifierService) Identify() {
 func (s *NicIdentifierService) getLocalNic() models.NIC {
 	interfaces, err := net.Interfaces()
 	if err != nil {
-		log.Println("Error getting network interfaces:", err)
+		logger.Errorf("Error getting network interfaces: %v", err)
 		return models.NIC{}
 	}
 
@@ -139,26 +141,26 @@ func (s *NicIdentifierService) getLocalNic() models.NIC {
 	var dockerInterfaces []models.NIC
 
 	for _, iface := range interfaces {
-		fmt.Printf("Checking interface: %s\n", iface.Name)
+		logger.Debugf("Checking interface: %s", iface.Name)
 		if iface.Flags&net.FlagUp == 0 {
-			fmt.Printf("Skipping %s: interface is down\n", iface.Name)
+			logger.Debugf("Skipping %s: interface is down", iface.Name)
 			continue
 		}
 		if iface.Flags&net.FlagLoopback != 0 {
-			fmt.Printf("Skipping %s: interface is loopback\n", iface.Name)
+			logger.Debugf("Skipping %s: interface is loopback", iface.Name)
 			continue
 		}
 
 		addrs, err := iface.Addrs()
 		if err != nil {
-			fmt.Printf("Skipping %s: error getting addresses: %v\n", iface.Name, err)
+			logger.Warnf("Skipping %s: error getting addresses: %v", iface.Name, err)
 			continue
 		}
 
 		for _, addr := range addrs {
 			ip, _, err := net.ParseCIDR(addr.String())
 			if err != nil || ip.To4() == nil {
-				fmt.Printf("Skipping address %s on %s: not a valid IPv4\n", addr.String(), iface.Name)
+				logger.Debugf("Skipping address %s on %s: not a valid IPv4", addr.String(), iface.Name)
 				continue
 			}
 
@@ -167,10 +169,10 @@ func (s *NicIdentifierService) getLocalNic() models.NIC {
 				
 				// Check if this is a Docker or container network
 				if s.isDockerOrContainerNetwork(ip.String()) {
-					fmt.Printf("Found Docker/container interface: %s with IPv4: %s\n", iface.Name, ip.String())
+					logger.Debugf("Found Docker/container interface: %s with IPv4: %s", iface.Name, ip.String())
 					dockerInterfaces = append(dockerInterfaces, nic)
 				} else {
-					fmt.Printf("Found potential host interface: %s with IPv4: %s\n", iface.Name, ip.String())
+					logger.Debugf("Found potential host interface: %s with IPv4: %s", iface.Name, ip.String())
 					candidates = append(candidates, nic)
 				}
 			}
@@ -182,18 +184,18 @@ func (s *NicIdentifierService) getLocalNic() models.NIC {
 		// Prioritize common home/office networks
 		for _, nic := range candidates {
 			if s.isCommonPrivateNetwork(nic.IPv4) {
-				fmt.Printf("Selected preferred interface: %s with IPv4: %s\n", nic.Name, nic.IPv4)
+				logger.Debugf("Selected preferred interface: %s with IPv4: %s", nic.Name, nic.IPv4)
 				return nic
 			}
 		}
 		// If no common private networks, return first candidate
-		fmt.Printf("Selected first non-Docker interface: %s with IPv4: %s\n", candidates[0].Name, candidates[0].IPv4)
+		logger.Debugf("Selected first non-Docker interface: %s with IPv4: %s", candidates[0].Name, candidates[0].IPv4)
 		return candidates[0]
 	}
 
 	// Fallback to Docker interfaces if no others available
 	if len(dockerInterfaces) > 0 {
-		fmt.Printf("Using Docker interface as fallback: %s with IPv4: %s\n", dockerInterfaces[0].Name, dockerInterfaces[0].IPv4)
+		logger.Debugf("Using Docker interface as fallback: %s with IPv4: %s", dockerInterfaces[0].Name, dockerInterfaces[0].IPv4)
 		return dockerInterfaces[0]
 	}
 
@@ -279,11 +281,11 @@ func (s *NicIdentifierService) getPublicIp() (string, error) {
 
 // CheckForNewNetworks detects new networks from active NICs and suggests creation
 func (s *NicIdentifierService) CheckForNewNetworks() {
-	log.Printf("Checking for new networks...")
+	logger.Debugf("Checking for new networks...")
 	
 	interfaces, err := net.Interfaces()
 	if err != nil {
-		log.Printf("Error getting network interfaces for network detection: %v", err)
+		logger.Errorf("Error getting network interfaces for network detection: %v", err)
 		return
 	}
 
@@ -319,7 +321,7 @@ func (s *NicIdentifierService) CheckForNewNetworks() {
 			networkCIDR := ipNet.String()
 			detectedNetworks = append(detectedNetworks, networkCIDR)
 			
-			log.Printf("Detected active network: %s on interface %s", networkCIDR, iface.Name)
+			logger.Infof("Detected active network: %s on interface %s", networkCIDR, iface.Name)
 		}
 	}
 	
@@ -334,7 +336,7 @@ func (s *NicIdentifierService) checkAndSuggestNetwork(networkCIDR string) {
 	// Parse the network to get the base network address
 	_, ipNet, err := net.ParseCIDR(networkCIDR)
 	if err != nil {
-		log.Printf("Error parsing network CIDR %s: %v", networkCIDR, err)
+		logger.Errorf("Error parsing network CIDR %s: %v", networkCIDR, err)
 		return
 	}
 	
@@ -343,22 +345,22 @@ func (s *NicIdentifierService) checkAndSuggestNetwork(networkCIDR string) {
 	ones, _ := ipNet.Mask.Size()
 	baseNetworkCIDR := fmt.Sprintf("%s/%d", networkAddr, ones)
 	
-	log.Printf("Checking if network %s exists (derived from %s)", baseNetworkCIDR, networkCIDR)
+	logger.Debugf("Checking if network %s exists (derived from %s)", baseNetworkCIDR, networkCIDR)
 	
 	// Check if this network already exists
 	existing, err := s.NetworkService.FindByCIDR(baseNetworkCIDR)
 	if err != nil {
-		log.Printf("Error checking existing network %s: %v", baseNetworkCIDR, err)
+		logger.Errorf("Error checking existing network %s: %v", baseNetworkCIDR, err)
 		return
 	}
 	
 	if existing != nil {
-		log.Printf("Network %s already exists, skipping suggestion", baseNetworkCIDR)
+		logger.Debugf("Network %s already exists, skipping suggestion", baseNetworkCIDR)
 		return
 	}
 	
 	// Network doesn't exist - log suggestion event
-	log.Printf("New network detected: %s", baseNetworkCIDR)
+	logger.Infof("New network detected: %s", baseNetworkCIDR)
 	s.EventLogService.CreateOne(&models.EventLog{
 		Type:        models.NewNetworkDetected,
 		Description: fmt.Sprintf("New network %s detected. Consider creating it for scanning.", baseNetworkCIDR),
@@ -371,7 +373,7 @@ func (s *NicIdentifierService) GetDetectedNetworks() []DetectedNetwork {
 	
 	interfaces, err := net.Interfaces()
 	if err != nil {
-		log.Printf("Error getting network interfaces: %v", err)
+		logger.Errorf("Error getting network interfaces: %v", err)
 		return detected
 	}
 
@@ -0,0 +1,196 @@
+package oneshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"reconya-ai/internal/exclusion"
+	"reconya-ai/internal/fingerprint"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/pingsweep"
+	"reconya-ai/internal/portscan"
+	"reconya-ai/internal/webservice"
+	"reconya-ai/models"
+)
+
+var logger = logging.For("oneshot")
+
+// Options configure a single run
+type Options struct {
+	// Target is a CIDR or a single IPv4 address
+	Target string
+	// Profile selects the discovery methods, pacing and stages, nil is the standard profile
+	Profile *models.ScanProfile
+	// Concurrency is how many hosts are port scanned and fingerprinted at once
+	Concurrency int
+}
+
+// Result is the outcome of a run, devices are sorted by IP
+type Result struct {
+	Target     string          `json:"target"`
+	Profile    string          `json:"profile"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	Devices    []models.Device `json:"devices"`
+	// Errors are per host failures, the rest of the run carries on
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Runner runs the discovery, port scan, web and fingerprint pipeline once, using
+// the same services as the server without storing anything
+type Runner struct {
+	PingSweepService   *pingsweep.PingSweepService
+	PortScanService    *portscan.PortScanService
+	WebService         *webservice.WebService
+	FingerprintService *fingerprint.FingerprintService
+}
+
+func NewRunner(pingSweepService *pingsweep.PingSweepService, portScanService *portscan.PortScanService, webService *webservice.WebService, fingerprintService *fingerprint.FingerprintService) *Runner {
+	return &Runner{
+		PingSweepService:   pingSweepService,
+		PortScanService:    portScanService,
+		WebService:         webService,
+		FingerprintService: fingerprintService,
+	}
+}
+
+// NormalizeTarget turns a single address into a /32 and checks CIDRs are IPv4
+func NormalizeTarget(target string) (string, error) {
+	target = strings.TrimSpace(target)
+	if ip := net.ParseIP(target); ip != nil {
+		if ip.To4() == nil {
+			return "", fmt.Errorf("%s is not an IPv4 address", target)
+		}
+		return ip.String() + "/32", nil
+	}
+	ip, ipNet, err := net.ParseCIDR(target)
+	if err != nil {
+		return "", fmt.Errorf("%q is not an IPv4 address or CIDR", target)
+	}
+	if ip.To4() == nil {
+		return "", fmt.Errorf("%s is not an IPv4 network", target)
+	}
+	return ipNet.String(), nil
+}
+
+// Run sweeps the target and enriches every host found. Cancelling ctx stops
+// starting new hosts, those already running finish.
+func (r *Runner) Run(ctx context.Context, opts Options) (*Result, error) {
+	target, err := NormalizeTarget(opts.Target)
+	if err != nil {
+		return nil, err
+	}
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	profile := opts.Profile
+	if profile == nil {
+		profile = models.DefaultScanProfile()
+	}
+
+	result := &Result{Target: target, Profile: profile.Name, StartedAt: time.Now()}
+	logger.Infof("Sweeping %s with the %s profile", target, profile.Name)
+	devices, err := r.PingSweepService.ExecuteSweep(target, profile)
+	if err != nil {
+		return nil, fmt.Errorf("discovery failed: %w", err)
+	}
+	logger.Infof("Found %d hosts on %s", len(devices), target)
+
+	var (
+		wg         sync.WaitGroup
+		mutex      sync.Mutex
+		slots      = make(chan struct{}, concurrency)
+		hostErrors []string
+	)
+hosts:
+	for i := range devices {
+		select {
+		case <-ctx.Done():
+			break hosts
+		case slots <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(device *models.Device) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if err := r.enrich(device, profile); err != nil {
+				mutex.Lock()
+				hostErrors = append(hostErrors, fmt.Sprintf("%s: %v", device.IPv4, err))
+				mutex.Unlock()
+			}
+		}(&devices[i])
+	}
+	wg.Wait()
+
+	sort.Slice(devices, func(i, j int) bool {
+		return ipLess(devices[i].IPv4, devices[j].IPv4)
+	})
+	sort.Strings(hostErrors)
+	result.Devices = devices
+	result.Errors = hostErrors
+	result.FinishedAt = time.Now()
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+	return result, nil
+}
+
+// enrich runs the stages of the profile on one host. A failed port scan skips the
+// web stage but the host is still fingerprinted from what discovery found.
+func (r *Runner) enrich(device *models.Device, profile *models.ScanProfile) error {
+	now := time.Now()
+	device.Status = models.DeviceStatusOnline
+	device.LastSeenOnlineAt = &now
+
+	var scanErr error
+	if profile.PortScan {
+		device.PortScanStartedAt = &now
+		var mac string
+		if device.MAC != nil {
+			mac = *device.MAC
+		}
+		ports, vendor, hostname, err := r.PortScanService.ExecutePortScanWith(context.Background(), device.IPv4, portscan.PortScanOptions{Profile: profile, MAC: mac})
+		ended := time.Now()
+		device.PortScanEndedAt = &ended
+		if errors.Is(err, exclusion.ErrExcluded) {
+			logger.Infof("Skipping the port scan of %s: %v", device.IPv4, err)
+		} else if err != nil {
+			scanErr = fmt.Errorf("port scan failed: %w", err)
+		} else {
+			device.Ports = ports
+			if vendor != "" && device.Vendor == nil {
+				device.Vendor = &vendor
+			}
+			if hostname != "" && device.Hostname == nil {
+				device.Hostname = &hostname
+			}
+		}
+	}
+
+	if profile.Web && scanErr == nil && len(device.Ports) > 0 {
+		webInfos := r.WebService.ScanWebServicesWithScreenshots(device, profile.Screenshots)
+		device.WebServices = r.PortScanService.ToWebServices(webInfos)
+		ended := time.Now()
+		device.WebScanEndedAt = &ended
+	}
+
+	r.FingerprintService.AnalyzeProfile(context.Background(), device, profile)
+	return scanErr
+}
+
+// ipLess orders dotted IPv4 addresses numerically
+func ipLess(a, b string) bool {
+	ipA, ipB := net.ParseIP(a).To4(), net.ParseIP(b).To4()
+	if ipA == nil || ipB == nil {
+		return a < b
+	}
+	return string(ipA) < string(ipB)
+}
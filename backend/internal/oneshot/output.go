@@ -0,0 +1,239 @@
+package oneshot
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"reconya-ai/models"
+)
+
+// Formats are the output formats `reconya scan -format` accepts
+var Formats = []string{"table", "json", "ndjson", "xml"}
+
+// Write prints the result in one of Formats
+func Write(w io.Writer, format string, result *Result) error {
+	switch format {
+	case "table":
+		return writeTable(w, result)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	case "ndjson":
+		return writeNDJSON(w, result)
+	case "xml":
+		return writeNmapXML(w, result)
+	}
+	return fmt.Errorf("unknown output format %q, expected one of %s", format, strings.Join(Formats, ", "))
+}
+
+func writeTable(w io.Writer, result *Result) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "IP\tMAC\tVENDOR\tHOSTNAME\tTYPE\tOS\tOPEN PORTS\tWEB")
+	for _, device := range result.Devices {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			device.IPv4,
+			orDash(device.MAC),
+			orDash(device.Vendor),
+			orDash(device.Hostname),
+			dash(string(device.DeviceType)),
+			dash(osName(device.OS)),
+			dash(strings.Join(openPorts(device.Ports), ",")),
+			dash(webSummary(device.WebServices)))
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\n%d hosts up on %s, %s profile, finished in %s\n",
+		len(result.Devices), result.Target, result.Profile,
+		result.FinishedAt.Sub(result.StartedAt).Round(100*time.Millisecond))
+	for _, message := range result.Errors {
+		fmt.Fprintf(w, "error: %s\n", message)
+	}
+	return nil
+}
+
+// writeNDJSON writes one device per line so results can be streamed into jq
+func writeNDJSON(w io.Writer, result *Result) error {
+	encoder := json.NewEncoder(w)
+	for _, device := range result.Devices {
+		if err := encoder.Encode(device); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nmapRun follows the nmap XML schema closely enough for tools that import nmap
+// scans, such as Metasploit's db_import or ndiff
+type nmapRun struct {
+	XMLName  xml.Name   `xml:"nmaprun"`
+	Scanner  string     `xml:"scanner,attr"`
+	Args     string     `xml:"args,attr"`
+	Start    int64      `xml:"start,attr"`
+	StartStr string     `xml:"startstr,attr"`
+	Version  string     `xml:"version,attr"`
+	Hosts    []nmapHost `xml:"host"`
+	RunStats struct {
+		Finished struct {
+			Time    int64  `xml:"time,attr"`
+			TimeStr string `xml:"timestr,attr"`
+			Exit    string `xml:"exit,attr"`
+		} `xml:"finished"`
+		Hosts struct {
+			Up    int `xml:"up,attr"`
+			Down  int `xml:"down,attr"`
+			Total int `xml:"total,attr"`
+		} `xml:"hosts"`
+	} `xml:"runstats"`
+}
+
+type nmapHost struct {
+	Status struct {
+		State  string `xml:"state,attr"`
+		Reason string `xml:"reason,attr"`
+	} `xml:"status"`
+	Addresses []nmapAddress `xml:"address"`
+	Hostnames []nmapName    `xml:"hostnames>hostname"`
+	Ports     []nmapPort    `xml:"ports>port"`
+	OSMatches []nmapOSMatch `xml:"os>osmatch,omitempty"`
+}
+
+type nmapAddress struct {
+	Addr     string `xml:"addr,attr"`
+	AddrType string `xml:"addrtype,attr"`
+	Vendor   string `xml:"vendor,attr,omitempty"`
+}
+
+type nmapName struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type nmapPort struct {
+	Protocol string `xml:"protocol,attr"`
+	PortID   string `xml:"portid,attr"`
+	State    struct {
+		State string `xml:"state,attr"`
+	} `xml:"state"`
+	Service struct {
+		Name string `xml:"name,attr,omitempty"`
+	} `xml:"service"`
+}
+
+type nmapOSMatch struct {
+	Name     string `xml:"name,attr"`
+	Accuracy int    `xml:"accuracy,attr"`
+}
+
+func writeNmapXML(w io.Writer, result *Result) error {
+	run := nmapRun{
+		Scanner:  "reconya",
+		Args:     "reconya scan -profile " + result.Profile + " " + result.Target,
+		Start:    result.StartedAt.Unix(),
+		StartStr: result.StartedAt.Format("Mon Jan 2 15:04:05 2006"),
+		Version:  "1.0",
+	}
+	for _, device := range result.Devices {
+		var host nmapHost
+		host.Status.State = "up"
+		host.Status.Reason = "reconya"
+		host.Addresses = append(host.Addresses, nmapAddress{Addr: device.IPv4, AddrType: "ipv4"})
+		if device.MAC != nil {
+			address := nmapAddress{Addr: strings.ToUpper(*device.MAC), AddrType: "mac"}
+			if device.Vendor != nil {
+				address.Vendor = *device.Vendor
+			}
+			host.Addresses = append(host.Addresses, address)
+		}
+		if device.Hostname != nil && *device.Hostname != "" {
+			host.Hostnames = append(host.Hostnames, nmapName{Name: *device.Hostname, Type: "PTR"})
+		}
+		for _, port := range device.Ports {
+			var p nmapPort
+			p.Protocol = port.Protocol
+			p.PortID = port.Number
+			p.State.State = port.State
+			p.Service.Name = port.Service
+			host.Ports = append(host.Ports, p)
+		}
+		if device.OS != nil && device.OS.Name != "" {
+			host.OSMatches = append(host.OSMatches, nmapOSMatch{Name: osName(device.OS), Accuracy: device.OS.Confidence})
+		}
+		run.Hosts = append(run.Hosts, host)
+	}
+	run.RunStats.Finished.Time = result.FinishedAt.Unix()
+	run.RunStats.Finished.TimeStr = result.FinishedAt.Format("Mon Jan 2 15:04:05 2006")
+	run.RunStats.Finished.Exit = "success"
+	run.RunStats.Hosts.Up = len(result.Devices)
+	run.RunStats.Hosts.Total = len(result.Devices)
+
+	if _, err := io.WriteString(w, xml.Header+"<!DOCTYPE nmaprun>\n"); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(run); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func openPorts(ports []models.Port) []string {
+	var open []string
+	for _, port := range ports {
+		if port.State != "open" {
+			continue
+		}
+		if port.Protocol != "" && port.Protocol != "tcp" {
+			open = append(open, port.Number+"/"+port.Protocol)
+		} else {
+			open = append(open, port.Number)
+		}
+	}
+	return open
+}
+
+func webSummary(webServices []models.WebService) string {
+	var titles []string
+	for _, ws := range webServices {
+		summary := ws.URL + " " + strconv.Itoa(ws.StatusCode)
+		if ws.Title != "" {
+			summary += " " + strconv.Quote(ws.Title)
+		}
+		titles = append(titles, summary)
+	}
+	return strings.Join(titles, "; ")
+}
+
+func osName(os *models.DeviceOS) string {
+	if os == nil || os.Name == "" {
+		return ""
+	}
+	if os.Version != "" && !strings.Contains(os.Name, os.Version) {
+		return os.Name + " " + os.Version
+	}
+	return os.Name
+}
+
+func orDash(value *string) string {
+	if value == nil {
+		return "-"
+	}
+	return dash(*value)
+}
+
+func dash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,122 @@
+package oneshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"reconya-ai/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func strPtr(s string) *string { return &s }
+
+func testResult() *Result {
+	started := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	return &Result{
+		Target:     "192.168.1.0/24",
+		Profile:    "standard",
+		StartedAt:  started,
+		FinishedAt: started.Add(42 * time.Second),
+		Devices: []models.Device{
+			{
+				IPv4:       "192.168.1.1",
+				MAC:        strPtr("aa:bb:cc:dd:ee:ff"),
+				Vendor:     strPtr("Ubiquiti"),
+				Hostname:   strPtr("gateway.lan"),
+				DeviceType: models.DeviceTypeRouter,
+				OS:         &models.DeviceOS{Name: "Linux", Version: "5.4", Confidence: 92},
+				Ports: []models.Port{
+					{Number: "22", Protocol: "tcp", State: "open", Service: "ssh"},
+					{Number: "443", Protocol: "tcp", State: "open", Service: "https"},
+					{Number: "8080", Protocol: "tcp", State: "filtered", Service: "http-proxy"},
+				},
+				WebServices: []models.WebService{{URL: "https://192.168.1.1:443", Title: "UniFi", StatusCode: 200}},
+			},
+			{IPv4: "192.168.1.20", DeviceType: models.DeviceTypeWorkstation},
+		},
+		Errors: []string{"192.168.1.20: port scan failed: context deadline exceeded"},
+	}
+}
+
+func TestTableOutput(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, "table", testResult()))
+
+	lines := strings.Split(out.String(), "\n")
+	assert.True(t, strings.HasPrefix(lines[0], "IP "))
+	assert.Contains(t, lines[1], "gateway.lan")
+	assert.Contains(t, lines[1], "Linux 5.4")
+	assert.Contains(t, lines[1], "22,443 ")
+	assert.NotContains(t, lines[1], "8080")
+	assert.Contains(t, lines[1], `https://192.168.1.1:443 200 "UniFi"`)
+	assert.Contains(t, out.String(), "2 hosts up on 192.168.1.0/24, standard profile, finished in 42s")
+	assert.Contains(t, out.String(), "error: 192.168.1.20: port scan failed")
+}
+
+func TestJSONOutputs(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, "json", testResult()))
+	var decoded Result
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, "192.168.1.0/24", decoded.Target)
+	assert.Len(t, decoded.Devices, 2)
+
+	out.Reset()
+	require.NoError(t, Write(&out, "ndjson", testResult()))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	var device models.Device
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &device))
+	assert.Equal(t, "192.168.1.20", device.IPv4)
+}
+
+func TestNmapXMLOutputParsesAsNmap(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, "xml", testResult()))
+	assert.True(t, strings.HasPrefix(out.String(), "<?xml"))
+	assert.Contains(t, out.String(), `<osmatch name="Linux 5.4" accuracy="92">`)
+
+	// The scanner's own nmap parser reads the output back
+	var parsed models.NmapXML
+	require.NoError(t, xml.Unmarshal(out.Bytes(), &parsed))
+	require.Len(t, parsed.Hosts, 2)
+	host := parsed.Hosts[0]
+	assert.Equal(t, "192.168.1.1", host.Addresses[0].Addr)
+	assert.Equal(t, "AA:BB:CC:DD:EE:FF", host.Addresses[1].Addr)
+	assert.Equal(t, "Ubiquiti", host.Addresses[1].Vendor)
+	assert.Equal(t, "gateway.lan", host.Hostnames[0].Name)
+	require.Len(t, host.Ports, 3)
+	assert.Equal(t, "443", host.Ports[1].PortID)
+	assert.Equal(t, "open", host.Ports[1].State.State)
+	assert.Empty(t, parsed.Hosts[1].Ports)
+}
+
+func TestUnknownFormat(t *testing.T) {
+	assert.Error(t, Write(&bytes.Buffer{}, "csv", testResult()))
+}
+
+func TestNormalizeTarget(t *testing.T) {
+	target, err := NormalizeTarget("10.0.0.7")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.7/32", target)
+
+	target, err = NormalizeTarget(" 192.168.1.77/24 ")
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.0/24", target)
+
+	for _, invalid := range []string{"fe80::1", "2001:db8::/64", "example.com", ""} {
+		_, err := NormalizeTarget(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestIPOrdering(t *testing.T) {
+	assert.True(t, ipLess("10.0.0.2", "10.0.0.10"))
+	assert.False(t, ipLess("10.0.1.1", "10.0.0.200"))
+}
@@ -4,15 +4,22 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/metrics"
+	"reconya-ai/internal/util"
 	"strings"
 	"sync"
 	"time"
 )
 
+var logger = logging.For("oui")
+
+var lookupsTotal = metrics.NewCounterVec("reconya_oui_lookups_total",
+	"Vendor lookups in the OUI database by result", "result")
+
 // OUIService handles MAC address to vendor lookup using IEEE OUI database
 type OUIService struct {
 	ouiMap   map[string]string
@@ -39,12 +46,12 @@ func (s *OUIService) Initialize() error {
 	
 	// Check if we need to download/update the OUI database
 	if s.shouldUpdateOUI(ouiFile) {
-		log.Println("Downloading IEEE OUI database...")
+		logger.Infof("Downloading IEEE OUI database...")
 		if err := s.downloadOUIDatabase(ouiFile); err != nil {
-			log.Printf("Failed to download OUI database: %v", err)
+			logger.Errorf("Failed to download OUI database: %v", err)
 			// Continue with existing file if download fails
 		} else {
-			log.Println("IEEE OUI database downloaded successfully")
+			logger.Infof("IEEE OUI database downloaded successfully")
 		}
 	}
 	
@@ -53,7 +60,7 @@ func (s *OUIService) Initialize() error {
 		return fmt.Errorf("failed to load OUI database: %w", err)
 	}
 	
-	log.Printf("Loaded %d OUI entries into memory", len(s.ouiMap))
+	logger.Infof("Loaded %d OUI entries into memory", len(s.ouiMap))
 	return nil
 }
 
@@ -167,6 +174,11 @@ func (s *OUIService) LookupVendor(macAddress string) string {
 	if macAddress == "" {
 		return ""
 	}
+
+	// Locally administered (randomized) MACs have no registered OUI
+	if util.IsRandomizedMAC(macAddress) {
+		return ""
+	}
 	
 	// Extract OUI (first 6 characters after removing separators)
 	oui := s.extractOUI(macAddress)
@@ -179,9 +191,11 @@ func (s *OUIService) LookupVendor(macAddress string) string {
 	
 	vendor, exists := s.ouiMap[oui]
 	if !exists {
+		lookupsTotal.Inc("miss")
 		return ""
 	}
 	
+	lookupsTotal.Inc("hit")
 	return vendor
 }
 
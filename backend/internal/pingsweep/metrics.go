@@ -0,0 +1,29 @@
+package pingsweep
+
+import (
+	"reconya-ai/internal/metrics"
+	"reconya-ai/models"
+)
+
+var (
+	sweepDuration = metrics.NewHistogramVec("reconya_sweep_duration_seconds",
+		"Duration of ping sweeps by network", metrics.ScanBuckets, "network")
+	sweepHostsFound = metrics.NewGaugeVec("reconya_sweep_hosts_found",
+		"Hosts found by the last ping sweep of a network", "network")
+	sweepsTotal = metrics.NewCounterVec("reconya_sweeps_total",
+		"Ping sweeps by network and result", "network", "result")
+	sweepStrategies = metrics.NewCounterVec("reconya_sweep_strategy_attempts_total",
+		"nmap sweep strategy attempts by result, empty means the strategy ran but found no hosts", "strategy", "result")
+)
+
+// recordStrategy counts the outcome of one nmap strategy in executeWithFallback
+func recordStrategy(strategy string, devices []models.Device, err error) {
+	switch {
+	case err != nil:
+		sweepStrategies.Inc(strategy, "failure")
+	case len(devices) == 0:
+		sweepStrategies.Inc(strategy, "empty")
+	default:
+		sweepStrategies.Inc(strategy, "success")
+	}
+}
@@ -3,28 +3,33 @@ package pingsweep
 import (
 	"context"
 	"fmt"
-	"log"
 	"os/exec"
 	"reconya-ai/internal/config"
 	"reconya-ai/internal/device"
 	"reconya-ai/internal/eventlog"
+	"reconya-ai/internal/exclusion"
+	"reconya-ai/internal/logging"
 	"reconya-ai/internal/network"
 	"reconya-ai/internal/portscan"
 	"reconya-ai/internal/scanner"
+	"reconya-ai/internal/snmp"
 	"reconya-ai/models"
 	"strings"
-	"sync"
 	"time"
 )
 
+var logger = logging.For("scanner")
+
 type PingSweepService struct {
 	Config          *config.Config
 	DeviceService   *device.DeviceService
 	EventLogService *eventlog.EventLogService
 	NetworkService  *network.NetworkService
 	PortScanService *portscan.PortScanService
-	portScanQueue   chan models.Device
-	portScanWorkers sync.WaitGroup
+	// SNMPService supplies the credentials for SNMP hostname lookups, without it none are sent
+	SNMPService *snmp.SNMPService
+	// Exclusions skip discovery
+	Exclusions *exclusion.ExclusionService
 }
 
 func NewPingSweepService(
@@ -34,36 +39,53 @@ func NewPingSweepService(
 	networkService *network.NetworkService,
 	portScanService *portscan.PortScanService) *PingSweepService {
 	
-	service := &PingSweepService{
+	return &PingSweepService{
 		Config:          cfg,
 		DeviceService:   deviceService,
 		EventLogService: eventLogService,
 		NetworkService:  networkService,
 		PortScanService: portScanService,
-		portScanQueue:   make(chan models.Device, 100), // Buffer for 100 devices
 	}
-	
-	// Start 3 port scan workers
-	service.startPortScanWorkers(3)
-	
-	return service
 }
 // Run method is deprecated - use the scan manager to control scanning
 // This method is kept for compatibility but should not be called directly
 func (s *PingSweepService) Run() {
-	log.Println("PingSweepService.Run() is deprecated - scanning is now controlled by scan manager")
+	logger.Infof("PingSweepService.Run() is deprecated - scanning is now controlled by scan manager")
 }
 
+// ExecuteSweepScanCommand sweeps a network with the built-in standard profile
 func (s *PingSweepService) ExecuteSweepScanCommand(network string) ([]models.Device, error) {
-	log.Printf("Executing nmap command on network: %s", network)
+	return s.ExecuteSweep(network, nil)
+}
+
+// ExecuteSweep finds the hosts of a network with the discovery methods, timing
+// and rate limits of the profile, nil is the built-in standard profile
+func (s *PingSweepService) ExecuteSweep(network string, profile *models.ScanProfile) ([]models.Device, error) {
+	if profile == nil {
+		profile = models.DefaultScanProfile()
+	}
+	logger.Infof("Executing nmap command on network: %s with the %s profile", network, profile.Name)
 	
+	exclude, cleanup, err := s.Exclusions.NmapArgs(models.ExcludeDiscovery, network)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply the exclusion rules: %w", err)
+	}
+	defer cleanup()
+
 	// Try multiple scan strategies for different environments
-	devices, err := s.executeWithFallback(network)
+	startedAt := time.Now()
+	devices, err := s.executeWithFallback(network, profile, exclude)
+	sweepDuration.Observe(time.Since(startedAt).Seconds(), network)
 	if err != nil {
+		sweepsTotal.Inc(network, "failure")
 		return nil, err
 	}
+	sweepsTotal.Inc(network, "success")
+	// Hosts nmap could only tell apart by their MAC address are dropped here
+	devices = s.Exclusions.Filter(models.ExcludeDiscovery, devices)
+	sweepHostsFound.Set(float64(len(devices)), network)
 
-	log.Printf("nmap command succeeded. Found %d devices", len(devices))
+	logger.Infof("nmap command succeeded. Found %d devices", len(devices))
 
 	// If we didn't get hostnames from nmap, try to enhance with additional methods
 	for i, device := range devices {
@@ -71,7 +93,7 @@ func (s *PingSweepService) ExecuteSweepScanCommand(network string) ([]models.Dev
 			// Try to get hostname using additional methods
 			if hostname := s.tryGetHostname(device.IPv4); hostname != "" {
 				devices[i].Hostname = &hostname
-				log.Printf("Enhanced hostname detection found: %s for IP: %s", hostname, device.IPv4)
+				logger.Debugf("Enhanced hostname detection found: %s for IP: %s", hostname, device.IPv4)
 			}
 		}
 	}
@@ -79,59 +101,99 @@ func (s *PingSweepService) ExecuteSweepScanCommand(network string) ([]models.Dev
 	return devices, nil
 }
 
-// executeWithFallback tries different scan strategies based on environment
-func (s *PingSweepService) executeWithFallback(network string) ([]models.Device, error) {
-	// Skip native scanner - it's too slow for large networks
-	log.Printf("Skipping native Go scanner, using nmap directly")
-
-	// Strategy 1: Try sudo with IP packets (works on most systems, gets MAC/vendor)
-	devices, err := s.tryNmapCommand([]string{"sudo", "nmap", "-sn", "--send-ip", "-T4", "-n", "-oX", "-", network})
-	if err == nil && len(devices) > 0 {
-		log.Printf("Sudo IP scan successful, found %d devices", len(devices))
-		return devices, nil
-	}
-	log.Printf("Sudo IP scan failed or found no devices: %v", err)
+// discoveryStrategy is one command for a discovery method, nil args run the
+// native scanner
+type discoveryStrategy struct {
+	name string
+	args []string
+}
 
-	// Strategy 3: Try IP packets without sudo (may still get some MAC info)
-	devices, err = s.tryNmapCommand([]string{"nmap", "-sn", "--send-ip", "-T4", "-oX", "-", network})
-	if err == nil && len(devices) > 0 {
-		log.Printf("IP scan without sudo successful, found %d devices", len(devices))
-		return devices, nil
+// discoveryStrategies turns the discovery methods of a profile into the commands
+// tried in order. ICMP and ARP run with sudo first, which also gets MAC addresses.
+// The exclude options keep nmap away from the excluded hosts.
+func discoveryStrategies(network string, profile *models.ScanProfile, exclude []string) []discoveryStrategy {
+	timing := profile.NmapTimingArgs()
+	nmap := func(sudo bool, probe ...string) []string {
+		var args []string
+		if sudo {
+			args = append(args, "sudo")
+		}
+		args = append(args, "nmap", "-sn")
+		args = append(args, probe...)
+		args = append(args, timing...)
+		if sudo {
+			args = append(args, "-n")
+		}
+		args = append(args, exclude...)
+		return append(args, "-oX", "-", network)
 	}
-	log.Printf("IP scan without sudo failed or found no devices: %v", err)
 
-	// Strategy 4: Try ARP scan with sudo (best for local networks but needs interface access)
-	devices, err = s.tryNmapCommand([]string{"sudo", "nmap", "-sn", "-PR", "-T4", "-n", "-oX", "-", network})
-	if err == nil && len(devices) > 0 {
-		log.Printf("Sudo ARP scan successful, found %d devices", len(devices))
-		return devices, nil
+	var strategies []discoveryStrategy
+	for _, method := range profile.Discovery {
+		switch method {
+		case models.DiscoveryICMP:
+			strategies = append(strategies,
+				discoveryStrategy{"sudo_ip", nmap(true, "--send-ip")},
+				discoveryStrategy{"ip", nmap(false, "--send-ip")})
+		case models.DiscoveryARP:
+			strategies = append(strategies,
+				discoveryStrategy{"sudo_arp", nmap(true, "-PR")},
+				discoveryStrategy{"arp", nmap(false, "-PR")})
+		case models.DiscoveryTCP:
+			strategies = append(strategies, discoveryStrategy{"tcp_syn", nmap(false, "-PS"+profile.DiscoveryPorts)})
+		case models.DiscoveryNative:
+			strategies = append(strategies, discoveryStrategy{name: "native"})
+		}
 	}
-	log.Printf("Sudo ARP scan failed or found no devices: %v", err)
+	return strategies
+}
 
-	// Strategy 5: Try ARP scan without sudo
-	devices, err = s.tryNmapCommand([]string{"nmap", "-sn", "-PR", "-T4", "-oX", "-", network})
-	if err == nil && len(devices) > 0 {
-		log.Printf("ARP scan without sudo successful, found %d devices", len(devices))
-		return devices, nil
+// executeWithFallback tries the strategies of the profile until one finds hosts
+func (s *PingSweepService) executeWithFallback(network string, profile *models.ScanProfile, exclude []string) ([]models.Device, error) {
+	tuning := s.Config.Tuning()
+	timeout, retryTimeout := tuning.SweepTimeout, tuning.SweepRetryTimeout
+	if profile.DiscoveryTimeout > 0 {
+		timeout = time.Duration(profile.DiscoveryTimeout) * time.Second
+		retryTimeout = timeout
 	}
-	log.Printf("ARP scan without sudo failed or found no devices: %v", err)
 
-	// Strategy 6: Last resort - TCP SYN scan on common ports (minimal info but finds hosts)
-	devices, err = s.tryNmapCommand([]string{"nmap", "-sn", "-PS80,443,22,21,23,25,53,110,111,135,139,143,993,995", "-T4", "-oX", "-", network})
-	if err == nil && len(devices) > 0 {
-		log.Printf("TCP SYN probe scan successful, found %d devices", len(devices))
-		return devices, nil
+	for _, strategy := range discoveryStrategies(network, profile, exclude) {
+		var devices []models.Device
+		var err error
+		if strategy.args == nil {
+			devices, err = s.tryNativeScanner(network)
+		} else {
+			devices, err = s.tryNmapCommand(strategy.args, timeout, retryTimeout)
+		}
+		recordStrategy(strategy.name, devices, err)
+		if err == nil && len(devices) > 0 {
+			logger.Infof("Discovery strategy %s successful, found %d devices", strategy.name, len(devices))
+			return devices, nil
+		}
+		logger.Warnf("Discovery strategy %s failed or found no devices: %v", strategy.name, err)
 	}
-	log.Printf("TCP SYN probe scan failed or found no devices: %v", err)
 
 	return nil, fmt.Errorf("all scan strategies failed for network %s", network)
 }
 
 // tryNativeScanner uses the native Go scanner for network discovery
 func (s *PingSweepService) tryNativeScanner(network string) ([]models.Device, error) {
-	log.Printf("Trying native Go scanner on network: %s", network)
+	logger.Infof("Trying native Go scanner on network: %s", network)
 	
 	nativeScanner := scanner.NewNativeScanner()
+	if s.Exclusions != nil {
+		nativeScanner.Exclusions = s.Exclusions
+		nativeScanner.SetExclude(s.Exclusions.SkipFunc(models.ExcludeDiscovery))
+	}
+	if s.SNMPService != nil {
+		if known, err := s.NetworkService.FindByCIDR(network); err == nil && known != nil {
+			creds, err := s.SNMPService.CredentialsForNetwork(known.ID)
+			if err != nil {
+				logger.Errorf("Error loading SNMP credentials for %s: %v", network, err)
+			}
+			nativeScanner.SetSNMPCredentials(creds)
+		}
+	}
 	devices, err := nativeScanner.ScanNetwork(network)
 	if err != nil {
 		return nil, err
@@ -141,11 +203,11 @@ func (s *PingSweepService) tryNativeScanner(network string) ([]models.Device, er
 }
 
 // tryNmapCommand executes a specific nmap command with automatic retry on timeout
-func (s *PingSweepService) tryNmapCommand(args []string) ([]models.Device, error) {
-	log.Printf("Trying nmap command: %s", strings.Join(args, " "))
+func (s *PingSweepService) tryNmapCommand(args []string, timeout, retryTimeout time.Duration) ([]models.Device, error) {
+	logger.Debugf("Trying nmap command: %s", strings.Join(args, " "))
 	
-	// First attempt with 20-second timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	// First attempt with the sweep timeout, 20 seconds by default
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 	
 	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
@@ -153,7 +215,7 @@ func (s *PingSweepService) tryNmapCommand(args []string) ([]models.Device, error
 	
 	// If timeout occurred and command doesn't already have -n flag, retry with -n
 	if err != nil && ctx.Err() == context.DeadlineExceeded {
-		log.Printf("nmap command timed out, checking if we can retry with -n flag")
+		logger.Warnf("nmap command timed out, checking if we can retry with -n flag")
 		
 		// Check if -n flag is already present
 		hasNoResolve := false
@@ -166,7 +228,7 @@ func (s *PingSweepService) tryNmapCommand(args []string) ([]models.Device, error
 		
 		// If no -n flag present, retry with -n to skip DNS resolution
 		if !hasNoResolve {
-			log.Printf("Retrying nmap command with -n flag to skip DNS resolution")
+			logger.Warnf("Retrying nmap command with -n flag to skip DNS resolution")
 			
 			// Build new args with -n flag after the command name
 			retryArgs := []string{args[0]} // command (nmap or sudo)
@@ -179,10 +241,10 @@ func (s *PingSweepService) tryNmapCommand(args []string) ([]models.Device, error
 				retryArgs = append(retryArgs, args[1:]...) // rest of args
 			}
 			
-			log.Printf("Retry command: %s", strings.Join(retryArgs, " "))
+			logger.Debugf("Retry command: %s", strings.Join(retryArgs, " "))
 			
-			// Retry with 90-second timeout for Raspberry Pi compatibility
-			retryCtx, retryCancel := context.WithTimeout(context.Background(), 90*time.Second)
+			// Retry with a longer timeout, 90 seconds by default, for Raspberry Pi compatibility
+			retryCtx, retryCancel := context.WithTimeout(context.Background(), retryTimeout)
 			defer retryCancel()
 			
 			retryCmd := exec.CommandContext(retryCtx, retryArgs[0], retryArgs[1:]...)
@@ -190,18 +252,18 @@ func (s *PingSweepService) tryNmapCommand(args []string) ([]models.Device, error
 			
 			if err != nil {
 				if retryCtx.Err() == context.DeadlineExceeded {
-					log.Printf("nmap retry also timed out after 90 seconds")
+					logger.Warnf("nmap retry also timed out after %v", retryTimeout)
 					return nil, fmt.Errorf("nmap command timed out even with -n flag")
 				}
-				log.Printf("nmap retry command failed: %v, output: %s", err, string(output))
+				logger.Errorf("nmap retry command failed: %v, output: %s", err, string(output))
 				return nil, err
 			}
 		} else {
-			log.Printf("nmap command already has -n flag and still timed out")
-			return nil, fmt.Errorf("nmap command timed out after 20 seconds")
+			logger.Warnf("nmap command already has -n flag and still timed out")
+			return nil, fmt.Errorf("nmap command timed out after %v", timeout)
 		}
 	} else if err != nil {
-		log.Printf("nmap command failed: %v, output: %s", err, string(output))
+		logger.Errorf("nmap command failed: %v, output: %s", err, string(output))
 		return nil, err
 	}
 
@@ -209,7 +271,7 @@ func (s *PingSweepService) tryNmapCommand(args []string) ([]models.Device, error
 		return nil, fmt.Errorf("nmap returned empty output")
 	}
 
-	log.Printf("nmap command output length: %d bytes", len(output))
+	logger.Debugf("nmap command output length: %d bytes", len(output))
 	
 	devices := s.DeviceService.ParseFromNmapXML(string(output))
 	return devices, nil
@@ -298,28 +360,3 @@ func (s *PingSweepService) tryDigReverseLookup(ip string) string {
 	
 	return ""
 }
-
-// startPortScanWorkers starts background workers for port scanning
-func (s *PingSweepService) startPortScanWorkers(numWorkers int) {
-	log.Printf("Starting %d port scan workers", numWorkers)
-	
-	for i := 0; i < numWorkers; i++ {
-		s.portScanWorkers.Add(1)
-		go s.portScanWorker(i)
-	}
-}
-
-// portScanWorker continuously processes devices from the port scan queue
-func (s *PingSweepService) portScanWorker(workerID int) {
-	defer s.portScanWorkers.Done()
-	
-	log.Printf("Port scan worker %d started", workerID)
-	
-	for device := range s.portScanQueue {
-		log.Printf("Worker %d: Starting port scan for device %s", workerID, device.IPv4)
-		s.PortScanService.Run(device)
-		log.Printf("Worker %d: Completed port scan for device %s", workerID, device.IPv4)
-	}
-	
-	log.Printf("Port scan worker %d stopped", workerID)
-}
@@ -0,0 +1,36 @@
+package pingsweep
+
+import (
+	"testing"
+
+	"reconya-ai/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscoveryStrategies(t *testing.T) {
+	var names []string
+	for _, strategy := range discoveryStrategies("10.0.0.0/24", models.DefaultScanProfile(), nil) {
+		names = append(names, strategy.name)
+	}
+	assert.Equal(t, []string{"sudo_ip", "ip", "sudo_arp", "arp", "tcp_syn"}, names)
+
+	profile := &models.ScanProfile{
+		Discovery:      []models.DiscoveryMethod{models.DiscoveryTCP, models.DiscoveryNative},
+		DiscoveryPorts: "22,443",
+		Timing:         2,
+		MaxRate:        20,
+	}
+	strategies := discoveryStrategies("10.0.0.0/24", profile, nil)
+	if assert.Len(t, strategies, 2) {
+		assert.Equal(t, []string{"nmap", "-sn", "-PS22,443", "-T2", "--max-rate", "20", "-oX", "-", "10.0.0.0/24"}, strategies[0].args)
+		assert.Equal(t, "native", strategies[1].name)
+		assert.Nil(t, strategies[1].args)
+	}
+
+	arp := discoveryStrategies("10.0.0.0/24", &models.ScanProfile{Discovery: []models.DiscoveryMethod{models.DiscoveryARP}, Timing: 4}, nil)
+	assert.Equal(t, []string{"sudo", "nmap", "-sn", "-PR", "-T4", "-n", "-oX", "-", "10.0.0.0/24"}, arp[0].args)
+
+	excluded := discoveryStrategies("10.0.0.0/24", profile, []string{"--excludefile", "/tmp/exclude.txt"})
+	assert.Equal(t, []string{"nmap", "-sn", "-PS22,443", "-T2", "--max-rate", "20", "--excludefile", "/tmp/exclude.txt", "-oX", "-", "10.0.0.0/24"}, excluded[0].args)
+}
@@ -3,76 +3,139 @@ package portscan
 import (
 	"context"
 	"encoding/xml"
-	"log"
+	"errors"
+	"fmt"
 	"os/exec"
 	"strings"
 	"time"
 
+	"reconya-ai/internal/config"
 	"reconya-ai/internal/eventlog"
+	"reconya-ai/internal/exclusion"
+	"reconya-ai/internal/jobqueue"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/metrics"
+	"reconya-ai/internal/scanprofile"
 	"reconya-ai/internal/util"
 	"reconya-ai/internal/webservice"
 	"reconya-ai/models"
 )
 
+var logger = logging.For("portscan")
+
 // DeviceServicePortScanner defines the interface for device-related operations needed by PortScanService.
 type DeviceServicePortScanner interface {
+	FindByID(deviceID string) (*models.Device, error)
 	FindByIPv4(ipv4 string) (*models.Device, error)
 	CreateOrUpdate(device *models.Device) (*models.Device, error)
+	ReplacePorts(deviceID string, ports []models.Port) error
 	EligibleForPortScan(device *models.Device) bool
-	PerformDeviceFingerprinting(device *models.Device)
+	PerformDeviceFingerprintingWith(ctx context.Context, device *models.Device, profile *models.ScanProfile)
 }
 
+var (
+	portScansInProgress = metrics.NewGaugeVec("reconya_portscans_in_progress",
+		"Port scans currently running")
+	portScanDuration = metrics.NewHistogramVec("reconya_portscan_duration_seconds",
+		"Duration of nmap port scans by result", metrics.ScanBuckets, "result")
+)
+
 type PortScanService struct {
 	DeviceService      DeviceServicePortScanner
 	EventLogService    *eventlog.EventLogService
 	WebService         *webservice.WebService
+	Config             *config.Config
 	ScreenshotsEnabled bool // Global setting for automated scans - defaults to false for performance
+	// ScanProfiles picks the profile of the device's network, nil uses the standard profile
+	ScanProfiles *scanprofile.ScanProfileService
+	// Exclusions skip port scans
+	Exclusions *exclusion.ExclusionService
 }
 
-func NewPortScanService(deviceService DeviceServicePortScanner, eventLogService *eventlog.EventLogService) *PortScanService {
+func NewPortScanService(deviceService DeviceServicePortScanner, eventLogService *eventlog.EventLogService, cfg *config.Config) *PortScanService {
 	return &PortScanService{
 		DeviceService:      deviceService,
 		EventLogService:    eventLogService,
-		WebService:         webservice.NewWebService(),
+		WebService:         webservice.NewWebService(cfg),
+		Config:             cfg,
 		ScreenshotsEnabled: false, // Default to disabled for automated scans to improve performance
 	}
 }
 
-func (s *PortScanService) Run(requestedDevice models.Device) {
-	deviceIDStr := requestedDevice.ID
-	log.Printf("Starting port scan for IP [%s]", requestedDevice.IPv4)
-	
-	// Use retry logic for creating event log
-	err := util.RetryOnLock(func() error {
-		return s.EventLogService.CreateOne(&models.EventLog{
-			Type:     models.PortScanStarted,
-			DeviceID: &deviceIDStr,
-		})
+// RegisterJobs makes the queue run the port, fingerprint and web stages through
+// this service. A finished port scan queues the other two for the device with
+// the same priority.
+func (s *PortScanService) RegisterJobs(queue *jobqueue.JobQueueService) {
+	tuning := s.Config.Tuning()
+	queue.Register(models.ScanJobPortScan, tuning.PortScanWorkers, func(ctx context.Context, job *models.ScanJob) error {
+		device, err := s.ScanPorts(ctx, job.DeviceID)
+		if err != nil {
+			return err
+		}
+		if _, err := queue.EnqueueDevice(models.ScanJobFingerprint, job.DeviceID, job.Priority); err != nil {
+			logger.Errorf("Error queueing fingerprint for IP [%s]: %v", device.IPv4, err)
+		}
+		if len(device.Ports) > 0 {
+			if _, err := queue.EnqueueDevice(models.ScanJobWebScan, job.DeviceID, job.Priority); err != nil {
+				logger.Errorf("Error queueing web scan for IP [%s]: %v", device.IPv4, err)
+			}
+		}
+		return nil
 	})
-	
+	queue.Register(models.ScanJobFingerprint, tuning.FingerprintWorkers, func(ctx context.Context, job *models.ScanJob) error {
+		return s.Fingerprint(ctx, job.DeviceID)
+	})
+	queue.Register(models.ScanJobWebScan, tuning.WebScanWorkers, func(ctx context.Context, job *models.ScanJob) error {
+		return s.ScanWeb(ctx, job.DeviceID)
+	})
+}
+
+// findDevice loads a device for a job, a device deleted since it was queued is a
+// permanent failure
+func (s *PortScanService) findDevice(deviceID string) (*models.Device, error) {
+	device, err := s.DeviceService.FindByID(deviceID)
 	if err != nil {
-		log.Printf("Error creating port scan started event log: %v", err)
+		return nil, err
 	}
+	if device == nil || device.IPv4 == "" {
+		return nil, jobqueue.Permanent(fmt.Errorf("device %s not found", deviceID))
+	}
+	return device, nil
+}
 
-	device, err := s.DeviceService.FindByIPv4(requestedDevice.IPv4)
+// ScanPorts port scans a device and saves the ports found, the stored ports are
+// replaced even when none are open so a completed scan differs from no scan.
+// Devices whose network profile has no port scan, or that an exclusion rule
+// protects, are left as they are.
+func (s *PortScanService) ScanPorts(ctx context.Context, deviceID string) (*models.Device, error) {
+	device, err := s.findDevice(deviceID)
 	if err != nil {
-		log.Printf("Error finding device: %v", err)
-		return
+		return nil, err
 	}
-
-	if device == nil || device.IPv4 == "" {
-		log.Printf("No device found for IP: %s", device.IPv4)
-		return
+	profile := s.ScanProfiles.ForNetworkID(device.NetworkID)
+	if !profile.PortScan {
+		logger.Infof("Skipping port scan for IP [%s], the %s profile has none", device.IPv4, profile.Name)
+		return device, nil
 	}
 
-	ports, vendor, hostname, err := s.ExecutePortScan(device.IPv4)
+	logger.Infof("Starting port scan for IP [%s] with the %s profile", device.IPv4, profile.Name)
+	portScansInProgress.Inc()
+	defer portScansInProgress.Dec()
+	s.logEvent(models.PortScanStarted, deviceID)
+
+	ports, vendor, hostname, err := s.ExecutePortScanWith(ctx, device.IPv4, PortScanOptions{Profile: profile, MAC: deviceMAC(device)})
+	if errors.Is(err, exclusion.ErrExcluded) {
+		logger.Infof("Skipping port scan for IP [%s]: %v", device.IPv4, err)
+		return device, nil
+	}
 	if err != nil {
-		log.Printf("Error executing port scan: %v", err)
-		return
+		return nil, fmt.Errorf("port scan of %s failed: %w", device.IPv4, err)
 	}
 
-	// Always update ports when a portscan completes, even if no ports are found
-	// This distinguishes between "no scan performed" and "scan completed with no open ports"
+	// Reload the device, a sweep may have updated it during the scan
+	if device, err = s.findDevice(deviceID); err != nil {
+		return nil, err
+	}
 	device.Ports = ports
 	if vendor != "" {
 		device.Vendor = &vendor
@@ -80,84 +143,195 @@ func (s *PortScanService) Run(requestedDevice models.Device) {
 	if hostname != "" {
 		device.Hostname = &hostname
 	}
-	
-	// Set port scan ended timestamp
 	now := time.Now()
 	device.PortScanEndedAt = &now
-	
-	// Perform device fingerprinting before saving (analyzes ports, vendor, etc.)
-	log.Printf("Performing device fingerprinting for IP [%s]", device.IPv4)
-	s.DeviceService.PerformDeviceFingerprinting(device)
-	
-	// Use retry logic for saving device with updated ports and fingerprint data
+
 	updatedDevice, err := util.RetryOnLockWithResult(func() (*models.Device, error) {
 		return s.DeviceService.CreateOrUpdate(device)
 	})
-	
 	if err != nil {
-		log.Printf("Error saving device with updated ports: %v", err)
-		return
-	}
-	log.Printf("Port scan for IP [%s] completed. Found ports: %+v, Type: %s, Vendor: %s", device.IPv4, ports, device.DeviceType, vendor)
-	
-	// Start web service scanning if we found open ports
-	if len(ports) > 0 {
-		if s.ScreenshotsEnabled {
-			log.Printf("Starting web service scan with screenshots for IP [%s]", device.IPv4)
-			s.scanWebServicesWithScreenshots(updatedDevice)
-		} else {
-			log.Printf("Starting web service scan without screenshots for IP [%s]", device.IPv4)
-			s.scanWebServices(updatedDevice)
-		}
+		return nil, fmt.Errorf("error saving device with updated ports: %w", err)
 	}
-	
-	// Use retry logic for creating event log
+	// Saving keeps the stored ports when none are given, a completed scan replaces them
 	err = util.RetryOnLock(func() error {
+		return s.DeviceService.ReplacePorts(updatedDevice.ID, ports)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error saving device ports: %w", err)
+	}
+	updatedDevice.Ports = ports
+	logger.Infof("Port scan for IP [%s] completed. Found ports: %+v, Vendor: %s", device.IPv4, ports, vendor)
+	s.logEvent(models.PortScanCompleted, deviceID)
+	return updatedDevice, nil
+}
+
+// Fingerprint works out the type and OS of a device from what is known about it
+func (s *PortScanService) Fingerprint(ctx context.Context, deviceID string) error {
+	device, err := s.findDevice(deviceID)
+	if err != nil {
+		return err
+	}
+	profile := s.ScanProfiles.ForNetworkID(device.NetworkID)
+	logger.Infof("Performing device fingerprinting for IP [%s] with the %s profile", device.IPv4, profile.Name)
+	s.DeviceService.PerformDeviceFingerprintingWith(ctx, device, profile)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err = util.RetryOnLockWithResult(func() (*models.Device, error) {
+		return s.DeviceService.CreateOrUpdate(device)
+	})
+	if err != nil {
+		return fmt.Errorf("error saving device fingerprint: %w", err)
+	}
+	logger.Infof("Fingerprinting for IP [%s] completed. Type: %s", device.IPv4, device.DeviceType)
+	return nil
+}
+
+// ScanWeb fetches the web pages served on the open ports of a device
+func (s *PortScanService) ScanWeb(ctx context.Context, deviceID string) error {
+	device, err := s.findDevice(deviceID)
+	if err != nil {
+		return err
+	}
+	if len(device.Ports) == 0 {
+		return nil
+	}
+	profile := s.ScanProfiles.ForNetworkID(device.NetworkID)
+	if !profile.Web {
+		logger.Infof("Skipping web service scan for IP [%s], the %s profile has none", device.IPv4, profile.Name)
+		return nil
+	}
+
+	var webInfos []webservice.WebInfo
+	if profile.Screenshots || s.ScreenshotsEnabled {
+		logger.Infof("Starting web service scan with screenshots for IP [%s]", device.IPv4)
+		webInfos = s.WebService.ScanWebServicesWithScreenshots(device, true)
+	} else {
+		logger.Infof("Starting web service scan without screenshots for IP [%s]", device.IPv4)
+		webInfos = s.WebService.ScanWebServices(device)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.saveWebServices(device, webInfos)
+}
+
+func deviceMAC(device *models.Device) string {
+	if device.MAC == nil {
+		return ""
+	}
+	return *device.MAC
+}
+
+func (s *PortScanService) logEvent(eventType models.EEventLogType, deviceID string) {
+	if s.EventLogService == nil {
+		return
+	}
+	err := util.RetryOnLock(func() error {
 		return s.EventLogService.CreateOne(&models.EventLog{
-			Type:     models.PortScanCompleted,
-			DeviceID: &deviceIDStr,
+			Type:     eventType,
+			DeviceID: &deviceID,
 		})
 	})
-	
 	if err != nil {
-		log.Printf("Error creating port scan completed event log: %v", err)
+		logger.Errorf("Error creating %s event log: %v", eventType, err)
 	}
 }
 
 func (s *PortScanService) ExecutePortScan(ipv4 string) ([]models.Port, string, string, error) {
-	// Use optimized scan options with timeout
-	// -sT: TCP connect scan (reliable), -T4: aggressive timing
-	// Custom port list: top 100 most common ports + SNMP ports (161,162)
-	log.Printf("Running optimized port scan for IP %s (top 100 ports + SNMP, 2min timeout)", ipv4)
-	
-	// Top 100 ports list + 161,162 for SNMP
-	portList := "1,3-4,6-7,9,13,17,19-26,30,32-33,37,42-43,49,53,70,79-85,88-90,99-100,106,109-111,113,119,125,135,139,143-144,146,161-162,179,199,211-212,222,254-256,259,264,280,301,306,311,340,366,389,406-407,416,417,425,427,443-445,458,464-465,481,497,500,512-515,524,541,543-545,548,554-555,563,587,593,616-617,625,631,636,646,648,666-668,683,687,691,700,705,711,714,720,722,726,749,765,777,783,787,800-801,808,843,873,880,888,898,900-903,911-912,981,987,990,992-993,995,999-1002,1007,1009-1011,1021-1100,1102,1104-1108,1110-1114,1117,1119,1121-1124,1126,1130-1132,1137-1138,1141,1145,1147-1149,1151-1152,1154,1163-1166,1169,1174-1175,1183,1185-1187,1192,1198-1199,1201,1213,1216-1218,1233-1234,1236,1244,1247-1248,1259,1271-1272,1277,1287,1296,1300-1301,1309-1311,1322,1328,1334,1352,1417,1433-1434,1443,1455,1461,1494,1500-1501,1503,1521,1524,1533,1556,1580,1583,1594,1600,1641,1658,1666,1687-1688,1700,1717-1721,1723,1755,1761,1782-1783,1801,1805,1812,1839-1840,1862-1864,1875,1900,1914,1935,1947,1971-1972,1974,1984,1998-2010,2013,2020-2022,2030,2033-2035,2038,2040-2043,2045-2049,2065,2068,2099-2100,2103,2105-2107,2111,2119,2121,2126,2135,2144,2160-2161,2170,2179,2190-2191,2196,2200,2222,2251,2260,2288,2301,2323,2366,2381-2383,2393-2394,2399,2401,2492,2500,2522,2525,2557,2601-2602,2604-2605,2607-2608,2638,2701-2702,2710,2717-2718,2725,2800,2809,2811,2869,2875,2909-2910,2920,2967-2968,2998,3000-3001,3003,3005-3007,3011,3013,3017,3030-3031,3052,3071,3077,3128,3168,3211,3221,3260-3261,3268-3269,3283,3300-3301,3306,3322-3325,3333,3351,3367,3369-3372,3389-3390,3404,3476,3493,3517,3527,3546,3551,3580,3659,3689-3690,3703,3737,3766,3784,3800-3801,3809,3814,3826-3828,3851,3869,3871,3878,3880,3889,3905,3914,3918,3920,3945,3971,3986,3995,3998,4000-4006,4045,4111,4125-4126,4129,4224,4242,4279,4321,4343,4443-4446,4449,4550,4567,4662,4848,4899-4900,4998,5000-5004,5009,5030,5033,5050-5051,5054,5060-5061,5080,5087,5100-5102,5120,5190,5200,5214,5221-5222,5225-5226,5269,5280,5298,5357,5405,5414,5431-5432,5440,5500,5510,5544,5550,5555,5560,5566,5631,5633,5666,5678-5679,5718,5730,5800-5802,5810-5811,5815,5822,5825,5850,5859,5862,5877,5900-5904,5906-5907,5910-5911,5915,5922,5925,5950,5952,5959-5963,5987-5989,5998-6007,6009,6025,6059,6100-6101,6106,6112,6123,6129,6156,6346,6389,6502,6510,6543,6547,6565-6567,6580,6646,6666-6669,6689,6692,6699,6779,6788-6789,6792,6839,6881,6901,6969,7000-7002,7004,7007,7019,7025,7070,7100,7103,7106,7200-7201,7402,7435,7443,7496,7512,7625,7627,7676,7741,7777-7778,7800,7911,7920-7921,7937-7938,7999-8002,8007-8011,8021-8022,8031,8042,8045,8080-8090,8093,8099-8100,8180-8181,8192-8194,8200,8222,8254,8290-8292,8300,8333,8383,8400,8402,8443,8500,8600,8649,8651-8652,8654,8701,8800,8873,8888,8899,8994,9000-9003,9009-9011,9040,9050,9071,9080-9081,9090-9091,9099-9103,9110-9111,9200,9207,9220,9290,9415,9418,9485,9500,9502-9503,9535,9575,9593-9595,9618,9666,9876-9878,9898,9900,9917,9929,9943-9944,9968,9998-10004,10009-10010,10012,10024-10025,10082,10180,10215,10243,10566,10616-10617,10621,10626,10628-10629,10778,11110-11111,11967,12000,12174,12265,12345,13456,13722,13782-13783,14000,14238,14441-14442,15000,15002-15004,15660,15742,16000-16001,16012,16016,16018,16080,16113,16992-16993,17877,17988,18040,18101,18988,19101,19283,19315,19350,19780,19801,19842,20000,20005,20031,20221-20222,20828,21571,22939,23502,24444,24800,25734-25735,26214,27000,27352-27353,27355-27356,27715,28201,30000,30718,30951,31038,31337,32768-32785,33354,33899,34571-34573,35500,38292,40193,40911,41511,42510,44176,44442-44443,44501,45100,48080,49152-49161,49163,49165,49167,49175-49176,49400,49999-50003,50006,50300,50389,50500,50636,50800,51103,51493,52673,52822,52848,52869,54045,54328,55055-55056,55555,55600,56737-56738,57294,57797,58080,60020,60443,61532,61900,62078,63331,64623,64680,65000,65129,65389"
-	
-	// Create context with 2 minute timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	return s.ExecutePortScanContext(context.Background(), ipv4)
+}
+
+// ExecutePortScanContext runs nmap against one address, cancelling ctx kills it
+func (s *PortScanService) ExecutePortScanContext(parent context.Context, ipv4 string) ([]models.Port, string, string, error) {
+	return s.ExecutePortScanWith(parent, ipv4, PortScanOptions{})
+}
+
+// PortScanOptions widen a port scan beyond the configured defaults
+type PortScanOptions struct {
+	// AllPorts scans every TCP port instead of the configured list
+	AllPorts bool
+	// ServiceVersions probes open ports for product and version with -sV
+	ServiceVersions bool
+	// Profile sets the ports, timing, rate limits and timeout, nil is the standard profile
+	Profile *models.ScanProfile
+	// MAC of the host, when known, is matched against the exclusion rules too
+	MAC string
+}
+
+// ExecutePortScanWith runs nmap against one address with the given options. An
+// address the exclusion rules protect is not scanned and an error wrapping
+// exclusion.ErrExcluded is returned.
+func (s *PortScanService) ExecutePortScanWith(parent context.Context, ipv4 string, opts PortScanOptions) ([]models.Port, string, string, error) {
+	if rule := s.Exclusions.Blocked(models.ExcludePortScan, ipv4, opts.MAC); rule != nil {
+		return nil, "", "", exclusion.BlockedError(rule, models.ExcludePortScan)
+	}
+	args, timeout := portScanArgs(s.Config.Tuning(), ipv4, opts)
+	logger.Infof("Running port scan for IP %s (%v timeout): nmap %s", ipv4, timeout, strings.Join(args, " "))
+
+	ctx, cancel := context.WithTimeout(parent, timeout)
 	defer cancel()
 	
-	cmd := exec.CommandContext(ctx, "nmap", "-sT", "-T4", "-p", portList, "-oX", "-", ipv4)
+	startedAt := time.Now()
+	cmd := exec.CommandContext(ctx, "nmap", args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
+		if parent.Err() != nil {
+			portScanDuration.Observe(time.Since(startedAt).Seconds(), "cancelled")
+			return nil, "", "", parent.Err()
+		}
 		if ctx.Err() == context.DeadlineExceeded {
-			log.Printf("Port scan timeout for %s after 2 minutes", ipv4)
+			portScanDuration.Observe(time.Since(startedAt).Seconds(), "timeout")
+			logger.Warnf("Port scan timeout for %s after %v", ipv4, timeout)
 			return nil, "", "", ctx.Err()
 		}
-		log.Printf("nmap error: %v, output: %s", err, string(output))
+		portScanDuration.Observe(time.Since(startedAt).Seconds(), "failure")
+		logger.Errorf("nmap error: %v, output: %s", err, string(output))
 		return nil, "", "", err
 	}
+	portScanDuration.Observe(time.Since(startedAt).Seconds(), "success")
 
-	log.Printf("Scan completed for %s, parsing results", ipv4)
+	logger.Debugf("Scan completed for %s, parsing results", ipv4)
 	ports, vendor, hostname := s.ParseNmapOutput(string(output))
 	return ports, vendor, hostname, nil
 }
 
+// portScanArgs builds the nmap arguments, a TCP connect scan (-sT) of the profile
+// ports or the configured list. The timeout is the profile's or the configured
+// one, ten times longer for all ports without a profile timeout and twice that
+// again with version probing.
+func portScanArgs(tuning config.Tuning, ipv4 string, opts PortScanOptions) ([]string, time.Duration) {
+	profile := opts.Profile
+	if profile == nil {
+		profile = models.DefaultScanProfile()
+	}
+	ports, timeout := tuning.PortScanPorts, tuning.PortScanTimeout
+	if profile.Ports != "" {
+		ports = profile.Ports
+	}
+	if profile.PortScanTimeout > 0 {
+		timeout = time.Duration(profile.PortScanTimeout) * time.Second
+	}
+	if opts.AllPorts && ports != "1-65535" {
+		ports = "1-65535"
+		timeout *= 10
+	}
+
+	args := append([]string{"-sT"}, profile.NmapTimingArgs()...)
+	args = append(args, "-p", ports)
+	if opts.ServiceVersions || profile.ServiceVersions {
+		args = append(args, "-sV")
+		timeout *= 2
+	}
+	return append(args, "-oX", "-", ipv4), timeout
+}
+
 func (s *PortScanService) ParseNmapOutput(output string) ([]models.Port, string, string) {
 	var nmapXML models.NmapXML
 	err := xml.Unmarshal([]byte(output), &nmapXML)
 	if err != nil {
-		log.Printf("Error parsing Nmap XML output: %v", err)
+		logger.Errorf("Error parsing Nmap XML output: %v", err)
 		return nil, "", ""
 	}
 
@@ -181,6 +355,7 @@ func (s *PortScanService) ParseNmapOutput(output string) ([]models.Port, string,
 				Protocol: xmlPort.Protocol,
 				State:    xmlPort.State.State,
 				Service:  xmlPort.Service.Name,
+				Version:  serviceVersion(xmlPort.Service),
 			}
 			ports = append(ports, port)
 		}
@@ -188,34 +363,48 @@ func (s *PortScanService) ParseNmapOutput(output string) ([]models.Port, string,
 	return ports, vendor, hostname
 }
 
-// scanWebServices scans for web services on the device and updates the device with web info (no screenshots)
-func (s *PortScanService) scanWebServices(device *models.Device) {
-	if device == nil {
-		return
+// serviceVersion joins what nmap -sV reports about a service, such as
+// "OpenSSH 8.9p1 (Ubuntu Linux; protocol 2.0)"
+func serviceVersion(service models.NmapXMLService) string {
+	version := strings.TrimSpace(service.Product + " " + service.Version)
+	if service.ExtraInfo != "" {
+		version = strings.TrimSpace(version + " (" + service.ExtraInfo + ")")
 	}
-
-	webInfos := s.WebService.ScanWebServices(device)
-	s.saveWebServices(device, webInfos)
+	return version
 }
 
-// scanWebServicesWithScreenshots scans for web services on the device with screenshot capture
-func (s *PortScanService) scanWebServicesWithScreenshots(device *models.Device) {
-	if device == nil {
-		return
+// saveWebServices saves web service information to the device
+func (s *PortScanService) saveWebServices(device *models.Device, webInfos []webservice.WebInfo) error {
+	if len(webInfos) == 0 {
+		logger.Infof("No web services found on device %s", device.IPv4)
+		return nil
 	}
 
-	webInfos := s.WebService.ScanWebServicesWithScreenshots(device, s.ScreenshotsEnabled)
-	s.saveWebServices(device, webInfos)
-}
+	webServices := s.ToWebServices(webInfos)
 
-// saveWebServices saves web service information to the device
-func (s *PortScanService) saveWebServices(device *models.Device, webInfos []webservice.WebInfo) {
-	if len(webInfos) == 0 {
-		log.Printf("No web services found on device %s", device.IPv4)
-		return
+	// Update device with web services
+	device.WebServices = webServices
+	now := time.Now()
+	device.WebScanEndedAt = &now
+
+	// Save device with web services
+	_, err := util.RetryOnLockWithResult(func() (*models.Device, error) {
+		return s.DeviceService.CreateOrUpdate(device)
+	})
+
+	if err != nil {
+		return fmt.Errorf("error saving device with web services: %w", err)
+	}
+
+	logger.Infof("Web service scan completed for IP [%s]. Found %d web services", device.IPv4, len(webServices))
+	for _, ws := range webServices {
+		logger.Debugf("  - %s: %s (Status: %d)", ws.URL, ws.Title, ws.StatusCode)
 	}
+	return nil
+}
 
-	// Convert webservice.WebInfo to models.WebService
+// ToWebServices converts the web service scan results to the device model
+func (s *PortScanService) ToWebServices(webInfos []webservice.WebInfo) []models.WebService {
 	var webServices []models.WebService
 	for _, webInfo := range webInfos {
 		webService := models.WebService{
@@ -232,26 +421,7 @@ func (s *PortScanService) saveWebServices(device *models.Device, webInfos []webs
 		}
 		webServices = append(webServices, webService)
 	}
-
-	// Update device with web services
-	device.WebServices = webServices
-	now := time.Now()
-	device.WebScanEndedAt = &now
-
-	// Save device with web services
-	_, err := util.RetryOnLockWithResult(func() (*models.Device, error) {
-		return s.DeviceService.CreateOrUpdate(device)
-	})
-
-	if err != nil {
-		log.Printf("Error saving device with web services: %v", err)
-		return
-	}
-
-	log.Printf("Web service scan completed for IP [%s]. Found %d web services", device.IPv4, len(webServices))
-	for _, ws := range webServices {
-		log.Printf("  - %s: %s (Status: %d)", ws.URL, ws.Title, ws.StatusCode)
-	}
+	return webServices
 }
 
 // extractPortFromURL extracts port number from URL
@@ -0,0 +1,36 @@
+package portscan
+
+import (
+	"testing"
+	"time"
+
+	"reconya-ai/internal/config"
+	"reconya-ai/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPortScanArgs(t *testing.T) {
+	tuning := config.DefaultTuning()
+	tuning.PortScanPorts = "22,80"
+	tuning.PortScanTimeout = time.Minute
+
+	args, timeout := portScanArgs(tuning, "10.0.0.5", PortScanOptions{})
+	assert.Equal(t, []string{"-sT", "-T4", "-p", "22,80", "-oX", "-", "10.0.0.5"}, args)
+	assert.Equal(t, time.Minute, timeout)
+
+	args, timeout = portScanArgs(tuning, "10.0.0.5", PortScanOptions{AllPorts: true, ServiceVersions: true})
+	assert.Equal(t, []string{"-sT", "-T4", "-p", "1-65535", "-sV", "-oX", "-", "10.0.0.5"}, args)
+	assert.Equal(t, 20*time.Minute, timeout)
+
+	stealthy := &models.ScanProfile{Ports: "443", PortScanTimeout: 1800, Timing: 2, MaxRate: 20, ScanDelay: 500}
+	args, timeout = portScanArgs(tuning, "10.0.0.5", PortScanOptions{Profile: stealthy})
+	assert.Equal(t, []string{"-sT", "-T2", "--max-rate", "20", "--scan-delay", "500ms", "-p", "443", "-oX", "-", "10.0.0.5"}, args)
+	assert.Equal(t, 30*time.Minute, timeout)
+
+	// A profile that already scans every port keeps its own timeout for a full scan
+	deep := &models.ScanProfile{Ports: "1-65535", PortScanTimeout: 1200, Timing: 4, ServiceVersions: true}
+	args, timeout = portScanArgs(tuning, "10.0.0.5", PortScanOptions{AllPorts: true, Profile: deep})
+	assert.Equal(t, []string{"-sT", "-T4", "-p", "1-65535", "-sV", "-oX", "-", "10.0.0.5"}, args)
+	assert.Equal(t, 40*time.Minute, timeout)
+}
@@ -0,0 +1,109 @@
+package rescan
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"reconya-ai/models"
+)
+
+// Diff lists what changed on a device between two snapshots. Ports are compared
+// by number and protocol, only open ports count as opened or closed.
+func Diff(before, after *models.Device) models.DeviceDiff {
+	var diff models.DeviceDiff
+
+	field := func(name, old, new string) {
+		if old != new {
+			diff.Fields = append(diff.Fields, models.FieldChange{Field: name, Before: old, After: new})
+		}
+	}
+	field("hostname", deref(before.Hostname), deref(after.Hostname))
+	field("vendor", deref(before.Vendor), deref(after.Vendor))
+	field("device_type", string(before.DeviceType), string(after.DeviceType))
+	field("os", osString(before.OS), osString(after.OS))
+
+	oldPorts, newPorts := openPorts(before.Ports), openPorts(after.Ports)
+	for key, port := range newPorts {
+		old, ok := oldPorts[key]
+		if !ok {
+			diff.PortsOpened = append(diff.PortsOpened, port)
+		} else if old.Service != port.Service || old.Version != port.Version {
+			diff.PortsChanged = append(diff.PortsChanged, models.PortChange{Before: old, After: port})
+		}
+	}
+	for key, port := range oldPorts {
+		if _, ok := newPorts[key]; !ok {
+			diff.PortsClosed = append(diff.PortsClosed, port)
+		}
+	}
+	sortPorts(diff.PortsOpened)
+	sortPorts(diff.PortsClosed)
+	sort.Slice(diff.PortsChanged, func(i, j int) bool {
+		return portLess(diff.PortsChanged[i].After, diff.PortsChanged[j].After)
+	})
+
+	oldWeb, newWeb := webURLs(before.WebServices), webURLs(after.WebServices)
+	for url := range newWeb {
+		if !oldWeb[url] {
+			diff.WebAdded = append(diff.WebAdded, url)
+		}
+	}
+	for url := range oldWeb {
+		if !newWeb[url] {
+			diff.WebRemoved = append(diff.WebRemoved, url)
+		}
+	}
+	sort.Strings(diff.WebAdded)
+	sort.Strings(diff.WebRemoved)
+
+	return diff
+}
+
+func openPorts(ports []models.Port) map[string]models.Port {
+	open := make(map[string]models.Port)
+	for _, port := range ports {
+		if port.State == "open" {
+			open[port.Number+"/"+port.Protocol] = port
+		}
+	}
+	return open
+}
+
+func sortPorts(ports []models.Port) {
+	sort.Slice(ports, func(i, j int) bool { return portLess(ports[i], ports[j]) })
+}
+
+func portLess(a, b models.Port) bool {
+	numberA, _ := strconv.Atoi(a.Number)
+	numberB, _ := strconv.Atoi(b.Number)
+	if numberA != numberB {
+		return numberA < numberB
+	}
+	return a.Protocol < b.Protocol
+}
+
+func webURLs(webServices []models.WebService) map[string]bool {
+	urls := make(map[string]bool)
+	for _, ws := range webServices {
+		urls[ws.URL] = true
+	}
+	return urls
+}
+
+func osString(os *models.DeviceOS) string {
+	if os == nil || os.Name == "" {
+		return ""
+	}
+	if os.Version != "" {
+		return fmt.Sprintf("%s %s", os.Name, os.Version)
+	}
+	return os.Name
+}
+
+func deref(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
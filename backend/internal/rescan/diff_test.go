@@ -0,0 +1,91 @@
+package rescan
+
+import (
+	"testing"
+
+	"reconya-ai/internal/scanprofile"
+	"reconya-ai/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestDiff(t *testing.T) {
+	before := &models.Device{
+		Hostname:   strPtr("nas.lan"),
+		DeviceType: models.DeviceTypeWorkstation,
+		Ports: []models.Port{
+			{Number: "22", Protocol: "tcp", State: "open", Service: "ssh"},
+			{Number: "80", Protocol: "tcp", State: "open", Service: "http"},
+			{Number: "445", Protocol: "tcp", State: "open", Service: "microsoft-ds"},
+			{Number: "8080", Protocol: "tcp", State: "filtered", Service: "http-proxy"},
+		},
+		WebServices: []models.WebService{{URL: "http://10.0.0.5:80"}},
+	}
+	after := &models.Device{
+		Hostname:   strPtr("nas.lan"),
+		Vendor:     strPtr("Synology"),
+		DeviceType: models.DeviceTypeNAS,
+		OS:         &models.DeviceOS{Name: "Linux", Version: "4.4"},
+		Ports: []models.Port{
+			{Number: "22", Protocol: "tcp", State: "open", Service: "ssh", Version: "OpenSSH 8.2"},
+			{Number: "80", Protocol: "tcp", State: "open", Service: "http"},
+			{Number: "5000", Protocol: "tcp", State: "open", Service: "upnp"},
+			{Number: "443", Protocol: "tcp", State: "open", Service: "https"},
+		},
+		WebServices: []models.WebService{{URL: "http://10.0.0.5:80"}, {URL: "https://10.0.0.5:443"}},
+	}
+
+	diff := Diff(before, after)
+	assert.False(t, diff.Empty())
+	assert.Equal(t, []models.FieldChange{
+		{Field: "vendor", Before: "", After: "Synology"},
+		{Field: "device_type", Before: "workstation", After: "nas"},
+		{Field: "os", Before: "", After: "Linux 4.4"},
+	}, diff.Fields)
+
+	require.Len(t, diff.PortsOpened, 2)
+	assert.Equal(t, "443", diff.PortsOpened[0].Number)
+	assert.Equal(t, "5000", diff.PortsOpened[1].Number)
+	require.Len(t, diff.PortsClosed, 1)
+	assert.Equal(t, "445", diff.PortsClosed[0].Number)
+	require.Len(t, diff.PortsChanged, 1)
+	assert.Equal(t, "OpenSSH 8.2", diff.PortsChanged[0].After.Version)
+	assert.Equal(t, []string{"https://10.0.0.5:443"}, diff.WebAdded)
+	assert.Empty(t, diff.WebRemoved)
+
+	assert.True(t, Diff(after, after).Empty())
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(models.RescanOptions{Ports: models.RescanPortsQuick}))
+	assert.NoError(t, Validate(models.RescanOptions{Ports: models.RescanPortsFull, ServiceVersions: true, OSDetection: true, Web: true, Screenshots: true}))
+	assert.NoError(t, Validate(models.RescanOptions{OSDetection: true}))
+
+	assert.Error(t, Validate(models.RescanOptions{}))
+	assert.Error(t, Validate(models.RescanOptions{Ports: "udp"}))
+	assert.Error(t, Validate(models.RescanOptions{OSDetection: true, ServiceVersions: true}))
+	assert.Error(t, Validate(models.RescanOptions{Ports: models.RescanPortsQuick, Screenshots: true}))
+}
+
+func TestFromProfile(t *testing.T) {
+	deep, err := scanprofile.Builtin("deep")
+	require.NoError(t, err)
+	assert.Equal(t, models.RescanOptions{Profile: "deep", Ports: models.RescanPortsFull, ServiceVersions: true, OSDetection: true, Web: true, Screenshots: true}, FromProfile(deep))
+
+	standard, err := scanprofile.Builtin("standard")
+	require.NoError(t, err)
+	assert.Equal(t, models.RescanOptions{Profile: "standard", Ports: models.RescanPortsQuick, OSDetection: true, Web: true}, FromProfile(standard))
+
+	otSafe, err := scanprofile.Builtin("ot-safe")
+	require.NoError(t, err)
+	assert.Error(t, Validate(FromProfile(otSafe)))
+}
+
+func TestPlan(t *testing.T) {
+	assert.Equal(t, []string{"port scan", "fingerprinting", "saving"}, plan(models.RescanOptions{Ports: models.RescanPortsQuick}))
+	assert.Equal(t, []string{"full port scan", "web services", "OS detection", "saving"},
+		plan(models.RescanOptions{Ports: models.RescanPortsFull, Web: true, OSDetection: true}))
+}
@@ -0,0 +1,276 @@
+package rescan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/exclusion"
+	"reconya-ai/internal/fingerprint"
+	"reconya-ai/internal/jobqueue"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/portscan"
+	"reconya-ai/internal/scanprofile"
+	"reconya-ai/internal/util"
+	"reconya-ai/models"
+)
+
+var logger = logging.For("rescan")
+
+// workers is how many devices are rescanned at once. Rescans have their own
+// pool so they start right away, whether or not a sweep is filling the queue.
+const workers = 2
+
+// RescanService rescans single devices on demand with the stages the user picks
+type RescanService struct {
+	DeviceService      *device.DeviceService
+	PortScanService    *portscan.PortScanService
+	FingerprintService *fingerprint.FingerprintService
+	JobQueue           *jobqueue.JobQueueService
+	ScanProfiles       *scanprofile.ScanProfileService
+}
+
+func NewRescanService(deviceService *device.DeviceService, portScanService *portscan.PortScanService, fingerprintService *fingerprint.FingerprintService, jobQueue *jobqueue.JobQueueService, scanProfiles *scanprofile.ScanProfileService) *RescanService {
+	service := &RescanService{
+		DeviceService:      deviceService,
+		PortScanService:    portScanService,
+		FingerprintService: fingerprintService,
+		JobQueue:           jobQueue,
+		ScanProfiles:       scanProfiles,
+	}
+	jobQueue.Register(models.ScanJobRescan, workers, service.run)
+	return service
+}
+
+// Validate checks the stages make sense together
+func Validate(opts models.RescanOptions) error {
+	switch opts.Ports {
+	case models.RescanPortsNone, models.RescanPortsQuick, models.RescanPortsFull:
+	default:
+		return fmt.Errorf("invalid port range %q, use quick or full", opts.Ports)
+	}
+	if opts.ServiceVersions && opts.Ports == models.RescanPortsNone {
+		return fmt.Errorf("service versions need a port scan")
+	}
+	if opts.Screenshots && !opts.Web {
+		return fmt.Errorf("screenshots need the web stage")
+	}
+	if opts.Ports == models.RescanPortsNone && !opts.OSDetection && !opts.Web {
+		return fmt.Errorf("select at least one stage")
+	}
+	return nil
+}
+
+// FromProfile takes the stages of a rescan from a scan profile. Built-in
+// profiles that were never stored are referred to by name.
+func FromProfile(profile *models.ScanProfile) models.RescanOptions {
+	ref := profile.ID
+	if ref == "" {
+		ref = profile.Name
+	}
+	opts := models.RescanOptions{
+		Profile:         ref,
+		ServiceVersions: profile.ServiceVersions,
+		OSDetection:     profile.OSDetection,
+		Web:             profile.Web,
+		Screenshots:     profile.Screenshots,
+	}
+	if profile.PortScan {
+		opts.Ports = models.RescanPortsQuick
+		if profile.Ports == "1-65535" {
+			opts.Ports = models.RescanPortsFull
+		}
+	}
+	return opts
+}
+
+// Start queues a rescan ahead of the sweep jobs. A device has one rescan at a
+// time, asking again while one waits replaces its stages and returns the same job.
+func (s *RescanService) Start(deviceID string, opts models.RescanOptions) (*models.ScanJob, error) {
+	profileName := ""
+	if opts.Profile != "" {
+		profile, err := s.ScanProfiles.Resolve(opts.Profile)
+		if err != nil {
+			return nil, err
+		}
+		opts = FromProfile(profile)
+		profileName = profile.Name
+	}
+	if err := Validate(opts); err != nil {
+		if profileName != "" {
+			return nil, fmt.Errorf("the %s profile cannot rescan a device: %w", profileName, err)
+		}
+		return nil, err
+	}
+	d, err := s.DeviceService.FindByID(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, fmt.Errorf("device not found")
+	}
+
+	payload, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+	// A failed rescan is reported rather than retried, the user can start another
+	return s.JobQueue.Enqueue(&models.ScanJob{
+		Type:        models.ScanJobRescan,
+		DeviceID:    deviceID,
+		Priority:    models.ScanJobPriorityManual,
+		MaxAttempts: 1,
+		Payload:     payload,
+	})
+}
+
+func (s *RescanService) run(ctx context.Context, job *models.ScanJob) error {
+	var opts models.RescanOptions
+	if err := json.Unmarshal(job.Payload, &opts); err != nil {
+		return jobqueue.Permanent(fmt.Errorf("invalid rescan options: %w", err))
+	}
+	before, err := s.findDevice(job.DeviceID)
+	if err != nil {
+		return err
+	}
+	d, err := s.findDevice(job.DeviceID)
+	if err != nil {
+		return err
+	}
+	profile, err := s.profile(d, opts)
+	if err != nil {
+		return jobqueue.Permanent(err)
+	}
+	logger.Infof("Rescanning IP [%s] with %+v", d.IPv4, opts)
+
+	stages := plan(opts)
+	step := 0
+	next := func(stage string) {
+		jobqueue.Progress(ctx, step*100/len(stages), stage)
+		step++
+	}
+
+	portsScanned := false
+	if opts.Ports != models.RescanPortsNone {
+		next(stages[step])
+		var mac string
+		if d.MAC != nil {
+			mac = *d.MAC
+		}
+		ports, vendor, hostname, err := s.PortScanService.ExecutePortScanWith(ctx, d.IPv4, portscan.PortScanOptions{
+			AllPorts:        opts.Ports == models.RescanPortsFull,
+			ServiceVersions: opts.ServiceVersions,
+			Profile:         profile,
+			MAC:             mac,
+		})
+		switch {
+		case errors.Is(err, exclusion.ErrExcluded):
+			// The ports found before the rule was added are kept
+			logger.Infof("Skipping the port scan of IP [%s]: %v", d.IPv4, err)
+		case err != nil:
+			return fmt.Errorf("port scan failed: %w", err)
+		default:
+			portsScanned = true
+			d.Ports = ports
+			if vendor != "" {
+				d.Vendor = &vendor
+			}
+			if hostname != "" {
+				d.Hostname = &hostname
+			}
+			now := time.Now()
+			d.PortScanEndedAt = &now
+		}
+	}
+
+	if opts.Web {
+		next(stages[step])
+		if len(d.Ports) > 0 {
+			webInfos := s.PortScanService.WebService.ScanWebServicesWithScreenshots(d, opts.Screenshots)
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			d.WebServices = s.PortScanService.ToWebServices(webInfos)
+			now := time.Now()
+			d.WebScanEndedAt = &now
+		}
+	}
+
+	// The device type is always worked out again from what the stages found
+	next(stages[step])
+	s.FingerprintService.AnalyzeProfile(ctx, d, profile)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	next(stages[step])
+	saved, err := util.RetryOnLockWithResult(func() (*models.Device, error) {
+		return s.DeviceService.CreateOrUpdate(d)
+	})
+	if err != nil {
+		return fmt.Errorf("error saving device: %w", err)
+	}
+	// Saving keeps the stored ports when none are open, a completed scan replaces them
+	if portsScanned {
+		if err := util.RetryOnLock(func() error {
+			return s.DeviceService.ReplacePorts(saved.ID, d.Ports)
+		}); err != nil {
+			return fmt.Errorf("error saving ports: %w", err)
+		}
+	}
+	after, err := s.findDevice(job.DeviceID)
+	if err != nil {
+		return err
+	}
+
+	diff := Diff(before, after)
+	logger.Infof("Rescan of IP [%s] completed, %d fields, %d opened, %d closed and %d changed ports",
+		after.IPv4, len(diff.Fields), len(diff.PortsOpened), len(diff.PortsClosed), len(diff.PortsChanged))
+	return jobqueue.SetResult(ctx, diff)
+}
+
+// profile returns what paces the rescan: the chosen profile, or the timing and
+// rate limits of the network's profile with the stages the user picked
+func (s *RescanService) profile(d *models.Device, opts models.RescanOptions) (*models.ScanProfile, error) {
+	if opts.Profile != "" {
+		return s.ScanProfiles.Resolve(opts.Profile)
+	}
+	pace := *s.ScanProfiles.ForNetworkID(d.NetworkID)
+	pace.Ports = ""
+	pace.ServiceVersions = opts.ServiceVersions
+	pace.OSDetection = opts.OSDetection
+	return &pace, nil
+}
+
+// plan names the stages of a rescan in the order they run, for progress
+func plan(opts models.RescanOptions) []string {
+	var stages []string
+	if opts.Ports == models.RescanPortsFull {
+		stages = append(stages, "full port scan")
+	} else if opts.Ports == models.RescanPortsQuick {
+		stages = append(stages, "port scan")
+	}
+	if opts.Web {
+		stages = append(stages, "web services")
+	}
+	if opts.OSDetection {
+		stages = append(stages, "OS detection")
+	} else {
+		stages = append(stages, "fingerprinting")
+	}
+	return append(stages, "saving")
+}
+
+func (s *RescanService) findDevice(deviceID string) (*models.Device, error) {
+	d, err := s.DeviceService.FindByID(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, jobqueue.Permanent(fmt.Errorf("device %s not found", deviceID))
+	}
+	return d, nil
+}
@@ -1,16 +1,29 @@
 package scan
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"sync"
 	"time"
+	"reconya-ai/internal/logging"
 	"reconya-ai/models"
+	"reconya-ai/internal/arpwatch"
+	"reconya-ai/internal/jobqueue"
 	"reconya-ai/internal/pingsweep"
 	"reconya-ai/internal/network"
 	"reconya-ai/internal/ipv6monitor"
+	"reconya-ai/internal/upnp"
+	"reconya-ai/internal/snmp"
+	"reconya-ai/internal/topology"
+	"reconya-ai/internal/trust"
+	"reconya-ai/internal/wol"
+	"reconya-ai/internal/scanner"
+	"reconya-ai/internal/scanprofile"
+	"reconya-ai/internal/util"
 )
 
+var logger = logging.For("scanner")
+
 // ScanState represents the current state of the scanning system
 type ScanState struct {
 	IsRunning       bool              `json:"is_running"`
@@ -30,12 +43,20 @@ type ScanManager struct {
 	pingSweepService *pingsweep.PingSweepService
 	networkService  *network.NetworkService
 	ipv6MonitorService *ipv6monitor.IPv6MonitorService
+	upnpService     *upnp.UPnPService
+	snmpService     *snmp.SNMPService
+	topologyService *topology.TopologyService
+	wolService      *wol.WakeOnLANService
+	trustService    *trust.TrustService
+	arpWatchService *arpwatch.ARPWatchService
+	jobQueue        *jobqueue.JobQueueService
+	scanProfiles    *scanprofile.ScanProfileService
 	stopChannel     chan bool
 	done            chan bool
 }
 
 // NewScanManager creates a new scan manager
-func NewScanManager(pingSweepService *pingsweep.PingSweepService, networkService *network.NetworkService, ipv6MonitorService *ipv6monitor.IPv6MonitorService) *ScanManager {
+func NewScanManager(pingSweepService *pingsweep.PingSweepService, networkService *network.NetworkService, ipv6MonitorService *ipv6monitor.IPv6MonitorService, upnpService *upnp.UPnPService, snmpService *snmp.SNMPService, topologyService *topology.TopologyService, wolService *wol.WakeOnLANService, trustService *trust.TrustService, arpWatchService *arpwatch.ARPWatchService, jobQueue *jobqueue.JobQueueService, scanProfiles *scanprofile.ScanProfileService) *ScanManager {
 	return &ScanManager{
 		state: ScanState{
 			IsRunning: false,
@@ -44,6 +65,14 @@ func NewScanManager(pingSweepService *pingsweep.PingSweepService, networkService
 		pingSweepService: pingSweepService,
 		networkService:  networkService,
 		ipv6MonitorService: ipv6MonitorService,
+		upnpService:     upnpService,
+		snmpService:     snmpService,
+		topologyService: topologyService,
+		wolService:      wolService,
+		trustService:    trustService,
+		arpWatchService: arpWatchService,
+		jobQueue:        jobQueue,
+		scanProfiles:    scanProfiles,
 	}
 }
 
@@ -177,22 +206,22 @@ func (sm *ScanManager) StartScan(networkID string) error {
 		Description: fmt.Sprintf("Network scan started (%s)", network.CIDR),
 	})
 	if err != nil {
-		log.Printf("Error creating scan started event log: %v", err)
+		logger.Errorf("Error creating scan started event log: %v", err)
 	}
 
 	// Start the IPv6 monitoring service
 	if err := sm.ipv6MonitorService.Start(); err != nil {
-		log.Printf("Failed to start IPv6 monitoring service: %v", err)
+		logger.Errorf("Failed to start IPv6 monitoring service: %v", err)
 		sm.state.IPv6Monitoring = false
 	} else {
-		log.Printf("Started IPv6 monitoring service")
+		logger.Infof("Started IPv6 monitoring service")
 		sm.state.IPv6Monitoring = true
 	}
 
 	// Start the scanning goroutine
 	go sm.runScanLoop()
 
-	log.Printf("Started scanning network: %s (%s)", network.Name, network.CIDR)
+	logger.Infof("Started scanning network: %s (%s)", network.Name, network.CIDR)
 	return nil
 }
 
@@ -221,9 +250,9 @@ func (sm *ScanManager) StopScan() error {
 		
 		// Stop the IPv6 monitoring service
 		if err := sm.ipv6MonitorService.Stop(); err != nil {
-			log.Printf("Error stopping IPv6 monitoring service: %v", err)
+			logger.Errorf("Error stopping IPv6 monitoring service: %v", err)
 		} else {
-			log.Printf("Stopped IPv6 monitoring service")
+			logger.Infof("Stopped IPv6 monitoring service")
 		}
 		
 		sm.mutex.Lock()
@@ -233,30 +262,53 @@ func (sm *ScanManager) StopScan() error {
 		sm.state.CurrentNetwork = nil
 		sm.state.StartTime = nil
 		sm.state.IPv6Monitoring = false
-		log.Println("Scan stopped successfully")
+		logger.Infof("Scan stopped successfully")
 	}()
 
 	return nil
 }
 
+// Shutdown stops a running scan and waits for the scan loop to finish, giving up
+// when ctx expires
+func (sm *ScanManager) Shutdown(ctx context.Context) error {
+	sm.mutex.Lock()
+	running := sm.state.IsRunning
+	done := sm.done
+	sm.mutex.Unlock()
+
+	if !running {
+		return nil
+	}
+	// A scan that is already stopping only needs to be waited for
+	_ = sm.StopScan()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // runScanLoop runs the continuous scanning loop
 func (sm *ScanManager) runScanLoop() {
 	defer close(sm.done)
 
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
-
-	log.Printf("Starting scan loop for network: %s", sm.state.CurrentNetwork.CIDR)
+	logger.Infof("Starting scan loop for network: %s", sm.state.CurrentNetwork.CIDR)
 
 	// Run first scan immediately
 	sm.runSingleScan()
 
 	for {
+		// The interval is read for every sweep so a reloaded configuration applies
+		// from the next one
+		timer := time.NewTimer(sm.pingSweepService.Config.Tuning().SweepInterval)
 		select {
 		case <-sm.stopChannel:
-			log.Println("Scan loop received stop signal")
+			timer.Stop()
+			logger.Infof("Scan loop received stop signal")
 			return
-		case <-ticker.C:
+		case <-timer.C:
 			sm.runSingleScan()
 		}
 	}
@@ -272,39 +324,54 @@ func (sm *ScanManager) runSingleScan() {
 		return
 	}
 
-	log.Printf("Running scan on network: %s", network.CIDR)
+	// Looked up on every pass so a new profile applies from the next sweep
+	profile := sm.scanProfiles.ForNetworkID(network.ID)
+	logger.Infof("Running scan on network: %s with the %s profile", network.CIDR, profile.Name)
 	
 	// Log ping sweep started event
 	err := sm.pingSweepService.EventLogService.CreateOne(&models.EventLog{
 		Type: models.PingSweep,
 	})
 	if err != nil {
-		log.Printf("Error creating ping sweep started event log: %v", err)
+		logger.Errorf("Error creating ping sweep started event log: %v", err)
 	}
 	
 	// Execute the ping sweep with the current network
-	devices, err := sm.pingSweepService.ExecuteSweepScanCommand(network.CIDR)
-	if err != nil {
-		log.Printf("Error during ping sweep: %v", err)
-		return
+	sweepStartedAt := time.Now()
+	// IPv6-only networks have nothing to sweep, their hosts come from IPv6 discovery
+	var devices []models.Device
+	if network.CIDR != "" && network.AddressFamily != models.AddressFamilyIPv6 {
+		devices, err = sm.pingSweepService.ExecuteSweep(network.CIDR, profile)
+		if err != nil {
+			logger.Errorf("Error during ping sweep: %v", err)
+			return
+		}
 	}
 
-	log.Printf("Ping sweep found %d devices from scan", len(devices))
+	logger.Infof("Ping sweep found %d devices from scan", len(devices))
 
 	// Process the devices (similar to the original Run method)
 	for i, device := range devices {
-		log.Printf("Processing device %d/%d: %s", i+1, len(devices), device.IPv4)
+		logger.Debugf("Processing device %d/%d: %s", i+1, len(devices), device.IPv4)
 		
 		// Set the network ID for the device
 		device.NetworkID = network.ID
+
+		// Randomized MACs are correlated with known devices by name, so ask the
+		// device for its mDNS name before saving it
+		if device.MAC != nil && util.IsRandomizedMAC(*device.MAC) {
+			if name := scanner.MDNSReverseLookup(device.IPv4, 500*time.Millisecond); name != "" {
+				device.Identity = &models.DeviceIdentity{MDNSName: name}
+			}
+		}
 		
 		// Update device in database
 		updatedDevice, err := sm.pingSweepService.DeviceService.CreateOrUpdate(&device)
 		if err != nil {
-			log.Printf("Error updating device %s: %v", device.IPv4, err)
+			logger.Errorf("Error updating device %s: %v", device.IPv4, err)
 			continue
 		}
-		log.Printf("Successfully saved device: %s", device.IPv4)
+		logger.Debugf("Successfully saved device: %s", device.IPv4)
 
 		// Create event log
 		deviceIDStr := device.ID
@@ -313,17 +380,57 @@ func (sm *ScanManager) runSingleScan() {
 			DeviceID: &deviceIDStr,
 		})
 		if err != nil {
-			log.Printf("Error creating device online event log: %v", err)
+			logger.Errorf("Error creating device online event log: %v", err)
 		}
 
-		// Add to port scan queue if eligible
+		// Queue a port scan if eligible, the device keeps a single waiting job.
+		// Profiles without a port scan go straight to fingerprinting.
 		if sm.pingSweepService.DeviceService.EligibleForPortScan(updatedDevice) {
-			// Note: We'll need to expose the port scan queue from ping sweep service
-			// For now, let's trigger port scan directly
-			go sm.pingSweepService.PortScanService.Run(*updatedDevice)
+			jobType := models.ScanJobPortScan
+			if !profile.PortScan {
+				jobType = models.ScanJobFingerprint
+			}
+			if _, err := sm.jobQueue.EnqueueDevice(jobType, updatedDevice.ID, models.ScanJobPriorityNormal); err != nil {
+				logger.Errorf("Error queueing %s for %s: %v", jobType, updatedDevice.IPv4, err)
+			}
 		}
 	}
 
+	// Report whether devices woken over Wake-on-LAN showed up in this sweep
+	if sm.wolService != nil {
+		sm.wolService.VerifyWakes(network, sweepStartedAt)
+	}
+
+	// Approve baseline devices and report unapproved or blocked ones seen in this sweep
+	if sm.trustService != nil {
+		sm.trustService.CheckSweep(network, sweepStartedAt)
+	}
+
+	// Compare the IP to MAC bindings of this sweep with earlier ones to spot ARP spoofing
+	if sm.arpWatchService != nil {
+		sm.arpWatchService.CheckSweep(devices)
+	}
+
+	// Collect UPnP descriptions from SSDP responders (throttled inside the service)
+	if sm.upnpService != nil {
+		go sm.upnpService.Run(network)
+	}
+
+	// Poll SNMP agents when the network has credentials configured and the profile allows it
+	if sm.snmpService != nil && profile.SNMP {
+		go sm.snmpService.Run(network)
+	}
+
+	// Trace the routed path to the network for the topology graph (throttled inside the service)
+	if sm.topologyService != nil {
+		go sm.topologyService.RefreshRoutes(network)
+	}
+
+	// Look for IPv6 hosts that are not in the neighbor cache yet (throttled inside the service)
+	if sm.ipv6MonitorService != nil && network.IsIPv6Enabled() {
+		go sm.ipv6MonitorService.RunDiscovery(network)
+	}
+
 	// Update scan state
 	sm.mutex.Lock()
 	now := time.Now()
@@ -332,7 +439,7 @@ func (sm *ScanManager) runSingleScan() {
 	sm.mutex.Unlock()
 
 	duration := time.Since(*sm.state.StartTime)
-	log.Printf("Completed scan iteration %d for network %s. Found %d devices.", sm.state.ScanCount, network.CIDR, len(devices))
+	logger.Infof("Completed scan iteration %d for network %s. Found %d devices.", sm.state.ScanCount, network.CIDR, len(devices))
 
 	// Create event log for ping sweep completion
 	durationInSeconds := float64(duration.Seconds())
@@ -341,7 +448,7 @@ func (sm *ScanManager) runSingleScan() {
 		DurationSeconds: &durationInSeconds,
 	})
 	if err != nil {
-		log.Printf("Error creating ping sweep completion event log: %v", err)
+		logger.Errorf("Error creating ping sweep completion event log: %v", err)
 	}
 }
 
@@ -0,0 +1,99 @@
+package scanner
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// MDNSReverseLookup asks the multicast DNS responders on the local link for the name
+// of an IPv4 address. The query is sent from an ephemeral port, which makes the
+// responder answer by unicast (RFC 6762 legacy unicast). The .local suffix is kept.
+func MDNSReverseLookup(ip string, timeout time.Duration) string {
+	query, err := buildPTRQuery(ip)
+	if err != nil {
+		return ""
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+
+	if _, err := conn.WriteToUDP(query, mdnsGroup); err != nil {
+		return ""
+	}
+	// Some responders ignore the multicast query but answer one sent to them directly
+	conn.WriteToUDP(query, &net.UDPAddr{IP: net.ParseIP(ip), Port: mdnsGroup.Port})
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return ""
+		}
+		if name := parsePTRResponse(buf[:n], ip); name != "" {
+			return name
+		}
+	}
+}
+
+// reverseName returns the in-addr.arpa name of an IPv4 address
+func reverseName(ip string) (string, error) {
+	v4 := net.ParseIP(ip).To4()
+	if v4 == nil {
+		return "", fmt.Errorf("not an IPv4 address: %s", ip)
+	}
+	return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa.", v4[3], v4[2], v4[1], v4[0]), nil
+}
+
+func buildPTRQuery(ip string) ([]byte, error) {
+	name, err := reverseName(ip)
+	if err != nil {
+		return nil, err
+	}
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: uint16(rand.Intn(1 << 16))},
+		Questions: []dnsmessage.Question{{
+			Name:  qname,
+			Type:  dnsmessage.TypePTR,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	return msg.Pack()
+}
+
+// parsePTRResponse returns the name a response gives for the address, or an empty
+// string when the message is not an answer for it
+func parsePTRResponse(packet []byte, ip string) string {
+	want, err := reverseName(ip)
+	if err != nil {
+		return ""
+	}
+
+	var msg dnsmessage.Message
+	if err := msg.Unpack(packet); err != nil || !msg.Header.Response {
+		return ""
+	}
+	for _, answer := range msg.Answers {
+		ptr, ok := answer.Body.(*dnsmessage.PTRResource)
+		if !ok || !strings.EqualFold(answer.Header.Name.String(), want) {
+			continue
+		}
+		return strings.TrimSuffix(ptr.PTR.String(), ".")
+	}
+	return ""
+}
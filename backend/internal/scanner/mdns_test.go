@@ -0,0 +1,52 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestBuildPTRQuery(t *testing.T) {
+	packet, err := buildPTRQuery("192.168.1.20")
+	require.NoError(t, err)
+
+	var msg dnsmessage.Message
+	require.NoError(t, msg.Unpack(packet))
+	require.Len(t, msg.Questions, 1)
+	assert.Equal(t, "20.1.168.192.in-addr.arpa.", msg.Questions[0].Name.String())
+	assert.Equal(t, dnsmessage.TypePTR, msg.Questions[0].Type)
+
+	_, err = buildPTRQuery("fe80::1")
+	assert.Error(t, err)
+}
+
+func TestParsePTRResponse(t *testing.T) {
+	response := func(t *testing.T, owner, target string) []byte {
+		msg := dnsmessage.Message{
+			Header: dnsmessage.Header{Response: true, Authoritative: true},
+			Answers: []dnsmessage.Resource{{
+				Header: dnsmessage.ResourceHeader{
+					Name:  dnsmessage.MustNewName(owner),
+					Type:  dnsmessage.TypePTR,
+					Class: dnsmessage.ClassINET,
+					TTL:   120,
+				},
+				Body: &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName(target)},
+			}},
+		}
+		packet, err := msg.Pack()
+		require.NoError(t, err)
+		return packet
+	}
+
+	packet := response(t, "20.1.168.192.in-addr.arpa.", "Annas-iPhone.local.")
+	assert.Equal(t, "Annas-iPhone.local", parsePTRResponse(packet, "192.168.1.20"))
+	assert.Empty(t, parsePTRResponse(packet, "192.168.1.21"), "answers for other addresses are ignored")
+	assert.Empty(t, parsePTRResponse([]byte{0x01, 0x02}, "192.168.1.20"))
+
+	query, err := buildPTRQuery("192.168.1.20")
+	require.NoError(t, err)
+	assert.Empty(t, parsePTRResponse(query, "192.168.1.20"), "queries are not answers")
+}
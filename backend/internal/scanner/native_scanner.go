@@ -4,29 +4,39 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"reconya-ai/internal/exclusion"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/snmp"
+	"reconya-ai/internal/util"
 	"reconya-ai/models"
 
 	"golang.org/x/net/icmp"
 	"golang.org/x/net/ipv4"
 )
 
+var logger = logging.For("scanner")
+
 type NativeScanner struct {
+	// Exclusions skip SNMP and web hostname probes
+	Exclusions               *exclusion.ExclusionService
 	timeout                  time.Duration
 	concurrent               int
 	enableMACLookup          bool
 	enableHostnameLookup     bool
 	enableOnlineVendorLookup bool
+	snmpCredentials          []*models.SNMPCredential
+	skip                     func(ip string) bool
 }
 
 type ScanResult struct {
@@ -58,9 +68,20 @@ func (s *NativeScanner) SetOptions(timeout time.Duration, concurrent int, enable
 	s.enableOnlineVendorLookup = enableOnlineVendor
 }
 
+// SetSNMPCredentials sets the credentials of the scanned network tried for hostname
+// lookups, without any no SNMP requests are sent
+func (s *NativeScanner) SetSNMPCredentials(creds []*models.SNMPCredential) {
+	s.snmpCredentials = creds
+}
+
+// SetExclude sets a check for the addresses that must not be probed
+func (s *NativeScanner) SetExclude(skip func(ip string) bool) {
+	s.skip = skip
+}
+
 // ScanNetwork performs a ping sweep on the given CIDR network
 func (s *NativeScanner) ScanNetwork(network string) ([]models.Device, error) {
-	log.Printf("Starting native Go network scan on: %s", network)
+	logger.Infof("Starting native Go network scan on: %s", network)
 
 	// Parse the network CIDR
 	_, ipNet, err := net.ParseCIDR(network)
@@ -70,7 +91,19 @@ func (s *NativeScanner) ScanNetwork(network string) ([]models.Device, error) {
 
 	// Generate all IPs in the network
 	ips := s.generateIPList(ipNet)
-	log.Printf("Scanning %d IP addresses", len(ips))
+	if s.skip != nil {
+		allowed := ips[:0]
+		for _, ip := range ips {
+			if !s.skip(ip) {
+				allowed = append(allowed, ip)
+			}
+		}
+		if skipped := len(ips) - len(allowed); skipped > 0 {
+			logger.Infof("Skipping %d excluded IP addresses", skipped)
+		}
+		ips = allowed
+	}
+	logger.Infof("Scanning %d IP addresses", len(ips))
 
 	// Create channels for work distribution
 	ipChan := make(chan string, len(ips))
@@ -120,11 +153,11 @@ func (s *NativeScanner) ScanNetwork(network string) ([]models.Device, error) {
 			}
 
 			devices = append(devices, device)
-			log.Printf("Found online device: %s (RTT: %v)", result.IP, result.RTT)
+			logger.Debugf("Found online device: %s (RTT: %v)", result.IP, result.RTT)
 		}
 	}
 
-	log.Printf("Native scan completed. Found %d online devices", len(devices))
+	logger.Infof("Native scan completed. Found %d online devices", len(devices))
 	return devices, nil
 }
 
@@ -157,7 +190,7 @@ func (s *NativeScanner) scanIP(ip string) ScanResult {
 			result.MAC, result.Vendor = s.getMACInfo(ip)
 		}
 		if s.enableHostnameLookup {
-			result.Hostname = s.getHostname(ip)
+			result.Hostname = s.getHostname(ip, result.MAC)
 		}
 	}
 
@@ -239,7 +272,7 @@ func (s *NativeScanner) tryTCPConnect(ip string) (bool, time.Duration) {
 	start := time.Now()
 
 	for _, port := range commonPorts {
-		address := fmt.Sprintf("%s:%d", ip, port)
+		address := net.JoinHostPort(ip, strconv.Itoa(port))
 		conn, err := net.DialTimeout("tcp", address, time.Millisecond*500)
 		if err == nil {
 			conn.Close()
@@ -259,7 +292,7 @@ func (s *NativeScanner) getMACInfo(ip string) (string, string) {
 		return mac, vendor
 	}
 
-	// Approach 2: Wake-on-LAN packet trigger + ARP lookup
+	// Approach 2: UDP packet trigger + ARP lookup
 	if mac, vendor := s.triggerARPAndLookup(ip); mac != "" {
 		return mac, vendor
 	}
@@ -394,6 +427,11 @@ func (s *NativeScanner) lookupVendor(mac string) string {
 		return ""
 	}
 
+	// Locally administered (randomized) MACs have no registered OUI, so skip the lookups
+	if util.IsRandomizedMAC(mac) {
+		return ""
+	}
+
 	// Extract OUI (first 3 octets)
 	oui := strings.ReplaceAll(mac[:8], ":", "")
 	oui = strings.ToUpper(oui)
@@ -483,7 +521,7 @@ func (s *NativeScanner) lookupVendorOnline(oui string) string {
 }
 
 // getHostname attempts to resolve hostname for the IP using multiple methods
-func (s *NativeScanner) getHostname(ip string) string {
+func (s *NativeScanner) getHostname(ip, mac string) string {
 	// Method 1: Standard reverse DNS lookup
 	if hostname := s.reverseDNSLookup(ip); hostname != "" {
 		return hostname
@@ -500,12 +538,12 @@ func (s *NativeScanner) getHostname(ip string) string {
 	}
 
 	// Method 4: SNMP system name (if available)
-	if hostname := s.snmpSystemName(ip); hostname != "" {
+	if hostname := s.snmpSystemName(ip, mac); hostname != "" {
 		return hostname
 	}
 
 	// Method 5: HTTP banner grabbing
-	if hostname := s.httpBannerHostname(ip); hostname != "" {
+	if hostname := s.httpBannerHostname(ip, mac); hostname != "" {
 		return hostname
 	}
 
@@ -580,47 +618,33 @@ func (s *NativeScanner) netBIOSLookup(ip string) string {
 
 // mDNSLookup attempts mDNS/Bonjour resolution
 func (s *NativeScanner) mDNSLookup(ip string) string {
-	// Try common mDNS queries
-	mdnsNames := []string{
-		ip + ".local",
-		// Could add more patterns here
-	}
-
-	for _, name := range mdnsNames {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*500)
-		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, name)
-		cancel()
-
-		if err == nil {
-			for _, addr := range addrs {
-				if addr.IP.String() == ip {
-					return strings.TrimSuffix(name, ".local")
-				}
-			}
-		}
-	}
-
-	return ""
+	return strings.TrimSuffix(MDNSReverseLookup(ip, time.Millisecond*500), ".local")
 }
 
 // snmpSystemName attempts to get system name via SNMP
-func (s *NativeScanner) snmpSystemName(ip string) string {
-	// This would require an SNMP library - simplified version
-	// In practice, you'd use a library like "github.com/soniah/gosnmp"
-
-	// Try connecting to SNMP port to see if it's available
-	conn, err := net.DialTimeout("udp", ip+":161", time.Millisecond*500)
-	if err != nil {
+func (s *NativeScanner) snmpSystemName(ip, mac string) string {
+	if len(s.snmpCredentials) == 0 || s.Exclusions.Blocked(models.ExcludeSNMP, ip, mac) != nil {
 		return ""
 	}
-	conn.Close()
-
-	// For now, return empty - would need proper SNMP implementation
+	for _, cred := range s.snmpCredentials {
+		client, err := snmp.NewClient(ip, cred, time.Millisecond*500)
+		if err != nil {
+			continue
+		}
+		info, err := client.SystemInfo()
+		client.Close()
+		if err == nil {
+			return info.SysName
+		}
+	}
 	return ""
 }
 
 // httpBannerHostname attempts to extract hostname from HTTP headers
-func (s *NativeScanner) httpBannerHostname(ip string) string {
+func (s *NativeScanner) httpBannerHostname(ip, mac string) string {
+	if s.Exclusions.Blocked(models.ExcludeWeb, ip, mac) != nil {
+		return ""
+	}
 	client := &http.Client{
 		Timeout: time.Second * 2,
 		Transport: &http.Transport{
@@ -0,0 +1,67 @@
+package scanner
+
+import (
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"reconya-ai/internal/exclusion"
+	"reconya-ai/models"
+	"reconya-ai/tests/testutils"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSNMPSystemName(t *testing.T) {
+	agent := testutils.NewSNMPAgent(t, "s3cret", []gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.2.1.1.5.0", Type: gosnmp.OctetString, Value: []byte("core-sw1")},
+	})
+
+	s := NewNativeScanner()
+	assert.Empty(t, s.snmpSystemName("127.0.0.1", ""))
+	assert.Zero(t, agent.Requests(), "no community is guessed without credentials")
+
+	s.SetSNMPCredentials([]*models.SNMPCredential{
+		{Version: models.SNMPVersion2c, Community: "wrong", Port: agent.Port()},
+		{Version: models.SNMPVersion2c, Community: "s3cret", Port: agent.Port()},
+	})
+	assert.Equal(t, "core-sw1", s.snmpSystemName("127.0.0.1", ""))
+}
+
+func TestHostnameProbesSkipExcludedHosts(t *testing.T) {
+	agent := testutils.NewSNMPAgent(t, "s3cret", []gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.2.1.1.5.0", Type: gosnmp.OctetString, Value: []byte("core-sw1")},
+	})
+	// The banner probe only tries the common web ports
+	listener, err := net.Listen("tcp", "127.0.0.1:8080")
+	if err != nil {
+		t.Skipf("port 8080 is not available: %v", err)
+	}
+	var requests atomic.Int32
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Server", "Apache (web1.example.com)")
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	s := NewNativeScanner()
+	s.SetSNMPCredentials([]*models.SNMPCredential{{Version: models.SNMPVersion2c, Community: "s3cret", Port: agent.Port()}})
+	s.Exclusions = exclusion.NewExclusionService(nil)
+	s.Exclusions.SetRules([]*models.ExclusionRule{
+		{ID: "plc", Scope: models.ExclusionGlobal, Address: "127.0.0.1", Stages: []models.ExclusionStage{models.ExcludeSNMP, models.ExcludeWeb}},
+	})
+
+	assert.Empty(t, s.snmpSystemName("127.0.0.1", ""))
+	assert.Empty(t, s.httpBannerHostname("127.0.0.1", ""))
+	assert.Zero(t, agent.Requests())
+	assert.Zero(t, requests.Load())
+
+	s.Exclusions.SetRules(nil)
+	assert.Equal(t, "core-sw1", s.snmpSystemName("127.0.0.1", ""))
+	require.NotEmpty(t, s.httpBannerHostname("127.0.0.1", ""))
+	assert.NotZero(t, requests.Load())
+}
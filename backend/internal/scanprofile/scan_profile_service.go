@@ -0,0 +1,235 @@
+package scanprofile
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/config"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/network"
+	"reconya-ai/internal/util"
+	"reconya-ai/models"
+)
+
+var logger = logging.For("scanprofile")
+
+// ScanProfileService manages the scan profiles and picks the one a scan uses.
+// A nil service hands out the built-in standard profile, for the CLI and tests.
+type ScanProfileService struct {
+	Repository     *db.ScanProfileRepository
+	NetworkService *network.NetworkService
+}
+
+func NewScanProfileService(repository *db.ScanProfileRepository, networkService *network.NetworkService) *ScanProfileService {
+	return &ScanProfileService{
+		Repository:     repository,
+		NetworkService: networkService,
+	}
+}
+
+// SeedBuiltins stores the built-in profiles, updating them to this version
+func (s *ScanProfileService) SeedBuiltins() error {
+	for _, profile := range models.BuiltinScanProfiles() {
+		profile := profile
+		if err := util.RetryOnLock(func() error {
+			return s.Repository.SeedBuiltin(context.Background(), &profile)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Builtin returns a built-in profile by name, as shipped
+func Builtin(name string) (*models.ScanProfile, error) {
+	for _, profile := range models.BuiltinScanProfiles() {
+		if profile.Name == name {
+			return &profile, nil
+		}
+	}
+	return nil, fmt.Errorf("scan profile %q not found", name)
+}
+
+// BuiltinNames lists the built-in profiles in order
+func BuiltinNames() []string {
+	var names []string
+	for _, profile := range models.BuiltinScanProfiles() {
+		names = append(names, profile.Name)
+	}
+	return names
+}
+
+// Validate checks a profile can be turned into scanner options
+func Validate(profile *models.ScanProfile) error {
+	if strings.TrimSpace(profile.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(profile.Discovery) == 0 {
+		return fmt.Errorf("select at least one discovery method")
+	}
+	for _, method := range profile.Discovery {
+		known := false
+		for _, candidate := range models.DiscoveryMethods {
+			known = known || method == candidate
+		}
+		if !known {
+			return fmt.Errorf("unknown discovery method %q", method)
+		}
+		if method == models.DiscoveryTCP && profile.DiscoveryPorts == "" {
+			return fmt.Errorf("tcp discovery needs discovery ports")
+		}
+	}
+	if profile.DiscoveryPorts != "" {
+		if err := config.ValidatePortList(profile.DiscoveryPorts); err != nil {
+			return fmt.Errorf("discovery ports: %w", err)
+		}
+	}
+	if profile.Ports != "" {
+		if err := config.ValidatePortList(profile.Ports); err != nil {
+			return fmt.Errorf("ports: %w", err)
+		}
+	}
+	if profile.Timing < 0 || profile.Timing > 5 {
+		return fmt.Errorf("timing must be between 0 and 5")
+	}
+	if profile.MaxRate < 0 || profile.ScanDelay < 0 || profile.DiscoveryTimeout < 0 || profile.PortScanTimeout < 0 {
+		return fmt.Errorf("rates, delays and timeouts cannot be negative")
+	}
+	if !profile.PortScan && (profile.ServiceVersions || profile.Web) {
+		return fmt.Errorf("service versions and web pages need the port scan")
+	}
+	if profile.Screenshots && !profile.Web {
+		return fmt.Errorf("screenshots need web pages")
+	}
+	return nil
+}
+
+// FindAll lists the profiles, built-in ones first
+func (s *ScanProfileService) FindAll() ([]*models.ScanProfile, error) {
+	return s.Repository.FindAll(context.Background())
+}
+
+// Resolve finds a profile by ID or name, empty is the default profile
+func (s *ScanProfileService) Resolve(ref string) (*models.ScanProfile, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		ref = models.DefaultScanProfileName
+	}
+	if s == nil {
+		return Builtin(ref)
+	}
+
+	profile, err := s.Repository.FindByID(context.Background(), ref)
+	if err == db.ErrNotFound {
+		profile, err = s.Repository.FindByName(context.Background(), ref)
+	}
+	if err == db.ErrNotFound {
+		return nil, fmt.Errorf("scan profile %q not found", ref)
+	}
+	return profile, err
+}
+
+// ForNetwork returns the profile a network is scanned with. A missing profile
+// falls back to the default so scanning carries on.
+func (s *ScanProfileService) ForNetwork(network *models.Network) *models.ScanProfile {
+	ref := ""
+	if network != nil {
+		ref = network.ScanProfileID
+	}
+	profile, err := s.Resolve(ref)
+	if err != nil && ref != "" {
+		logger.Warnf("Scan profile of network %s unavailable, using the default: %v", network.CIDR, err)
+		profile, err = s.Resolve("")
+	}
+	if err != nil {
+		logger.Errorf("Default scan profile unavailable, using the built-in one: %v", err)
+		return models.DefaultScanProfile()
+	}
+	return profile
+}
+
+// ForNetworkID is ForNetwork for the network a device belongs to
+func (s *ScanProfileService) ForNetworkID(networkID string) *models.ScanProfile {
+	if s == nil || networkID == "" {
+		return s.ForNetwork(nil)
+	}
+	n, err := s.NetworkService.FindByID(networkID)
+	if err != nil {
+		logger.Warnf("Failed to load network %s for its scan profile: %v", networkID, err)
+	}
+	return s.ForNetwork(n)
+}
+
+// Create adds a custom profile
+func (s *ScanProfileService) Create(profile *models.ScanProfile) (*models.ScanProfile, error) {
+	profile.Builtin = false
+	if err := Validate(profile); err != nil {
+		return nil, err
+	}
+	return util.RetryOnLockWithResult(func() (*models.ScanProfile, error) {
+		return s.Repository.Create(context.Background(), profile)
+	})
+}
+
+// Update replaces the settings of a custom profile
+func (s *ScanProfileService) Update(id string, profile *models.ScanProfile) (*models.ScanProfile, error) {
+	existing, err := s.Repository.FindByID(context.Background(), id)
+	if err != nil {
+		return nil, err
+	}
+	if existing.Builtin {
+		return nil, fmt.Errorf("built-in profiles cannot be changed, create a copy instead")
+	}
+	profile.ID = id
+	profile.Builtin = false
+	profile.CreatedAt = existing.CreatedAt
+	if err := Validate(profile); err != nil {
+		return nil, err
+	}
+	if err := util.RetryOnLock(func() error {
+		return s.Repository.Update(context.Background(), profile)
+	}); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// Delete removes a custom profile, its networks go back to the default
+func (s *ScanProfileService) Delete(id string) error {
+	existing, err := s.Repository.FindByID(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	if existing.Builtin {
+		return fmt.Errorf("built-in profiles cannot be deleted")
+	}
+	return util.RetryOnLock(func() error {
+		return s.Repository.Delete(context.Background(), id)
+	})
+}
+
+// SetNetworkProfile assigns a profile, by ID or name, to a network. Empty goes
+// back to the default profile.
+func (s *ScanProfileService) SetNetworkProfile(networkID, ref string) (*models.ScanProfile, error) {
+	profileID := ""
+	if strings.TrimSpace(ref) != "" {
+		profile, err := s.Resolve(ref)
+		if err != nil {
+			return nil, err
+		}
+		profileID = profile.ID
+	}
+	if err := util.RetryOnLock(func() error {
+		return s.Repository.SetNetworkProfile(context.Background(), networkID, profileID)
+	}); err != nil {
+		return nil, err
+	}
+	profile, err := s.Resolve(profileID)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("Network %s now uses the %s scan profile", networkID, profile.Name)
+	return profile, nil
+}
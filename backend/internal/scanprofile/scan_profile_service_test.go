@@ -0,0 +1,73 @@
+package scanprofile
+
+import (
+	"testing"
+
+	"reconya-ai/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltinsAreValid(t *testing.T) {
+	assert.Equal(t, []string{"quick", "standard", "deep", "stealthy", "ot-safe"}, BuiltinNames())
+	for _, profile := range models.BuiltinScanProfiles() {
+		profile := profile
+		assert.NoError(t, Validate(&profile), profile.Name)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *models.ScanProfile {
+		return &models.ScanProfile{
+			Name:      "printers",
+			Discovery: []models.DiscoveryMethod{models.DiscoveryARP},
+			PortScan:  true,
+			Ports:     "80,443,631,9100",
+			Timing:    3,
+			Web:       true,
+		}
+	}
+	require.NoError(t, Validate(valid()))
+
+	for name, change := range map[string]func(p *models.ScanProfile){
+		"no name":               func(p *models.ScanProfile) { p.Name = " " },
+		"no discovery":          func(p *models.ScanProfile) { p.Discovery = nil },
+		"unknown discovery":     func(p *models.ScanProfile) { p.Discovery = []models.DiscoveryMethod{"udp"} },
+		"tcp without ports":     func(p *models.ScanProfile) { p.Discovery = []models.DiscoveryMethod{models.DiscoveryTCP} },
+		"bad ports":             func(p *models.ScanProfile) { p.Ports = "80-" },
+		"timing out of range":   func(p *models.ScanProfile) { p.Timing = 6 },
+		"negative rate":         func(p *models.ScanProfile) { p.MaxRate = -1 },
+		"web without port scan": func(p *models.ScanProfile) { p.PortScan = false },
+		"screenshots alone":     func(p *models.ScanProfile) { p.Web, p.Screenshots = false, true },
+	} {
+		profile := valid()
+		change(profile)
+		assert.Error(t, Validate(profile), name)
+	}
+}
+
+func TestNilServiceUsesBuiltins(t *testing.T) {
+	var service *ScanProfileService
+
+	profile, err := service.Resolve("")
+	require.NoError(t, err)
+	assert.Equal(t, models.DefaultScanProfileName, profile.Name)
+
+	profile, err = service.Resolve("ot-safe")
+	require.NoError(t, err)
+	assert.False(t, profile.PortScan)
+
+	_, err = service.Resolve("missing")
+	assert.Error(t, err)
+
+	assert.Equal(t, models.DefaultScanProfileName, service.ForNetworkID("").Name)
+	assert.Equal(t, models.DefaultScanProfileName, service.ForNetwork(&models.Network{ScanProfileID: "missing"}).Name)
+}
+
+func TestNmapTimingArgs(t *testing.T) {
+	stealthy, err := Builtin("stealthy")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-T2", "--max-rate", "20", "--scan-delay", "500ms"}, stealthy.NmapTimingArgs())
+	assert.Equal(t, []string{"-T4"}, models.DefaultScanProfile().NmapTimingArgs())
+}
@@ -2,14 +2,16 @@ package settings
 
 import (
 	"errors"
-	"log"
 	"reconya-ai/db"
+	"reconya-ai/internal/logging"
 	"reconya-ai/models"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+var logger = logging.For("settings")
+
 // SettingsService handles settings operations
 type SettingsService struct {
 	repo db.SettingsRepository
@@ -41,7 +43,7 @@ func (s *SettingsService) GetUserSettings(userID string) (*models.Settings, erro
 		// Save default settings to database
 		err = s.repo.Create(settings)
 		if err != nil {
-			log.Printf("Error creating default settings for user %s: %v", userID, err)
+			logger.Errorf("Error creating default settings for user %s: %v", userID, err)
 			return settings, nil // Return defaults even if save fails
 		}
 	}
@@ -88,7 +90,7 @@ func (s *SettingsService) UpdateUserSettings(userID string, updates map[string]i
 func (s *SettingsService) AreScreenshotsEnabled(userID string) bool {
 	settings, err := s.GetUserSettings(userID)
 	if err != nil {
-		log.Printf("Error getting settings for user %s: %v", userID, err)
+		logger.Errorf("Error getting settings for user %s: %v", userID, err)
 		return true // Default to enabled if error occurs
 	}
 	
@@ -0,0 +1,366 @@
+package snmp
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"reconya-ai/models"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// SNMPv2-MIB system group and IF-MIB columns
+const (
+	oidSysDescr    = ".1.3.6.1.2.1.1.1.0"
+	oidSysObjectID = ".1.3.6.1.2.1.1.2.0"
+	oidSysUpTime   = ".1.3.6.1.2.1.1.3.0"
+	oidSysContact  = ".1.3.6.1.2.1.1.4.0"
+	oidSysName     = ".1.3.6.1.2.1.1.5.0"
+	oidSysLocation = ".1.3.6.1.2.1.1.6.0"
+
+	oidIfDescr       = ".1.3.6.1.2.1.2.2.1.2"
+	oidIfType        = ".1.3.6.1.2.1.2.2.1.3"
+	oidIfMtu         = ".1.3.6.1.2.1.2.2.1.4"
+	oidIfSpeed       = ".1.3.6.1.2.1.2.2.1.5"
+	oidIfPhysAddress = ".1.3.6.1.2.1.2.2.1.6"
+	oidIfAdminStatus = ".1.3.6.1.2.1.2.2.1.7"
+	oidIfOperStatus  = ".1.3.6.1.2.1.2.2.1.8"
+	oidIfName        = ".1.3.6.1.2.1.31.1.1.1.1"
+	oidIfHighSpeed   = ".1.3.6.1.2.1.31.1.1.1.15"
+	oidIfAlias       = ".1.3.6.1.2.1.31.1.1.1.18"
+)
+
+// Client wraps a gosnmp connection configured from a stored credential
+type Client struct {
+	snmp    *gosnmp.GoSNMP
+	version models.SNMPVersion
+}
+
+// NewClient builds a client for the given target. The credential must already be decrypted.
+func NewClient(target string, cred *models.SNMPCredential, timeout time.Duration) (*Client, error) {
+	port := cred.Port
+	if port == 0 {
+		port = 161
+	}
+
+	g := &gosnmp.GoSNMP{
+		Target:             target,
+		Port:               uint16(port),
+		Transport:          "udp",
+		Timeout:            timeout,
+		Retries:            1,
+		MaxOids:            gosnmp.MaxOids,
+		MaxRepetitions:     25,
+		ExponentialTimeout: false,
+	}
+
+	switch cred.Version {
+	case models.SNMPVersion1:
+		g.Version = gosnmp.Version1
+		g.Community = cred.Community
+	case models.SNMPVersion2c, "":
+		g.Version = gosnmp.Version2c
+		g.Community = cred.Community
+	case models.SNMPVersion3:
+		g.Version = gosnmp.Version3
+		g.SecurityModel = gosnmp.UserSecurityModel
+		params := &gosnmp.UsmSecurityParameters{UserName: cred.Username}
+		flags := gosnmp.NoAuthNoPriv
+
+		if cred.AuthPassphrase != "" {
+			authProtocol, err := parseAuthProtocol(cred.AuthProtocol)
+			if err != nil {
+				return nil, err
+			}
+			params.AuthenticationProtocol = authProtocol
+			params.AuthenticationPassphrase = cred.AuthPassphrase
+			flags = gosnmp.AuthNoPriv
+
+			if cred.PrivPassphrase != "" {
+				privProtocol, err := parsePrivProtocol(cred.PrivProtocol)
+				if err != nil {
+					return nil, err
+				}
+				params.PrivacyProtocol = privProtocol
+				params.PrivacyPassphrase = cred.PrivPassphrase
+				flags = gosnmp.AuthPriv
+			}
+		}
+
+		g.MsgFlags = flags
+		g.SecurityParameters = params
+	default:
+		return nil, fmt.Errorf("unsupported SNMP version: %s", cred.Version)
+	}
+
+	if err := g.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", target, err)
+	}
+
+	version := cred.Version
+	if version == "" {
+		version = models.SNMPVersion2c
+	}
+	return &Client{snmp: g, version: version}, nil
+}
+
+// Close releases the underlying socket
+func (c *Client) Close() {
+	if c.snmp.Conn != nil {
+		c.snmp.Conn.Close()
+	}
+}
+
+// SystemInfo reads the SNMPv2-MIB system group
+func (c *Client) SystemInfo() (*models.SNMPInfo, error) {
+	oids := []string{oidSysDescr, oidSysObjectID, oidSysUpTime, oidSysContact, oidSysName, oidSysLocation}
+	result, err := c.snmp.Get(oids)
+	if err != nil {
+		return nil, err
+	}
+	if result.Error != gosnmp.NoError {
+		return nil, fmt.Errorf("SNMP error: %s", result.Error)
+	}
+
+	info := &models.SNMPInfo{
+		Version:  c.version,
+		PolledAt: time.Now(),
+	}
+
+	for _, variable := range result.Variables {
+		if variable.Type == gosnmp.NoSuchObject || variable.Type == gosnmp.NoSuchInstance || variable.Type == gosnmp.Null {
+			continue
+		}
+		switch normalizeOID(variable.Name) {
+		case oidSysDescr:
+			info.SysDescr = pduString(variable)
+		case oidSysObjectID:
+			info.SysObjectID = pduString(variable)
+		case oidSysUpTime:
+			// sysUpTime is in hundredths of a second
+			info.UptimeSeconds = gosnmp.ToBigInt(variable.Value).Int64() / 100
+		case oidSysContact:
+			info.SysContact = pduString(variable)
+		case oidSysName:
+			info.SysName = pduString(variable)
+		case oidSysLocation:
+			info.SysLocation = pduString(variable)
+		}
+	}
+
+	return info, nil
+}
+
+// Interfaces walks the IF-MIB ifTable and ifXTable
+func (c *Client) Interfaces() ([]models.SNMPInterface, error) {
+	byIndex := make(map[int]*models.SNMPInterface)
+	var order []int
+
+	get := func(index int) *models.SNMPInterface {
+		iface, ok := byIndex[index]
+		if !ok {
+			iface = &models.SNMPInterface{Index: index}
+			byIndex[index] = iface
+			order = append(order, index)
+		}
+		return iface
+	}
+
+	columns := []string{oidIfDescr, oidIfType, oidIfMtu, oidIfSpeed, oidIfPhysAddress, oidIfAdminStatus, oidIfOperStatus, oidIfName, oidIfHighSpeed, oidIfAlias}
+	for _, column := range columns {
+		pdus, err := c.Walk(column)
+		if err != nil {
+			// ifXTable is optional on older agents, but ifTable errors are fatal
+			if strings.HasPrefix(column, ".1.3.6.1.2.1.31.") {
+				continue
+			}
+			return nil, err
+		}
+
+		for _, pdu := range pdus {
+			index, ok := TableIndex(pdu.Name, column)
+			if !ok {
+				continue
+			}
+			i, err := strconv.Atoi(index)
+			if err != nil {
+				continue
+			}
+			iface := get(i)
+
+			switch column {
+			case oidIfDescr:
+				iface.Descr = pduString(pdu)
+			case oidIfType:
+				iface.Type = int(gosnmp.ToBigInt(pdu.Value).Int64())
+			case oidIfMtu:
+				iface.MTU = int(gosnmp.ToBigInt(pdu.Value).Int64())
+			case oidIfSpeed:
+				if iface.SpeedMbps == 0 {
+					iface.SpeedMbps = gosnmp.ToBigInt(pdu.Value).Int64() / 1000000
+				}
+			case oidIfHighSpeed:
+				if speed := gosnmp.ToBigInt(pdu.Value).Int64(); speed > 0 {
+					iface.SpeedMbps = speed
+				}
+			case oidIfPhysAddress:
+				iface.MAC = FormatMAC(pdu.Value)
+			case oidIfAdminStatus:
+				iface.AdminStatus = ifStatus(gosnmp.ToBigInt(pdu.Value).Int64())
+			case oidIfOperStatus:
+				iface.OperStatus = ifStatus(gosnmp.ToBigInt(pdu.Value).Int64())
+			case oidIfName:
+				iface.Name = pduString(pdu)
+			case oidIfAlias:
+				iface.Alias = pduString(pdu)
+			}
+		}
+	}
+
+	interfaces := make([]models.SNMPInterface, 0, len(order))
+	for _, index := range order {
+		interfaces = append(interfaces, *byIndex[index])
+	}
+	return interfaces, nil
+}
+
+// Walk returns all variables below a subtree, using GETBULK where the version allows it
+func (c *Client) Walk(rootOID string) ([]gosnmp.SnmpPDU, error) {
+	if c.version == models.SNMPVersion1 {
+		return c.snmp.WalkAll(rootOID)
+	}
+	return c.snmp.BulkWalkAll(rootOID)
+}
+
+// TableIndex returns the index suffix of a table column OID
+func TableIndex(name, column string) (string, bool) {
+	name = normalizeOID(name)
+	prefix := column + "."
+	if !strings.HasPrefix(name, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(name, prefix), true
+}
+
+// FormatMAC renders an OCTET STRING physical address as colon-separated hex
+func FormatMAC(value interface{}) string {
+	b, ok := value.([]byte)
+	if !ok || len(b) != 6 {
+		return ""
+	}
+	return strings.ToUpper(net.HardwareAddr(b).String())
+}
+
+func normalizeOID(oid string) string {
+	if !strings.HasPrefix(oid, ".") {
+		return "." + oid
+	}
+	return oid
+}
+
+func pduString(pdu gosnmp.SnmpPDU) string {
+	switch v := pdu.Value.(type) {
+	case []byte:
+		return strings.TrimSpace(strings.ToValidUTF8(string(v), ""))
+	case string:
+		return strings.TrimSpace(v)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func ifStatus(status int64) string {
+	switch status {
+	case 1:
+		return "up"
+	case 2:
+		return "down"
+	case 3:
+		return "testing"
+	case 5:
+		return "dormant"
+	case 6:
+		return "notPresent"
+	case 7:
+		return "lowerLayerDown"
+	default:
+		return "unknown"
+	}
+}
+
+func parseAuthProtocol(protocol string) (gosnmp.SnmpV3AuthProtocol, error) {
+	switch strings.ToUpper(protocol) {
+	case "MD5":
+		return gosnmp.MD5, nil
+	case "SHA", "":
+		return gosnmp.SHA, nil
+	case "SHA224":
+		return gosnmp.SHA224, nil
+	case "SHA256":
+		return gosnmp.SHA256, nil
+	case "SHA384":
+		return gosnmp.SHA384, nil
+	case "SHA512":
+		return gosnmp.SHA512, nil
+	default:
+		return gosnmp.NoAuth, fmt.Errorf("unsupported SNMPv3 auth protocol: %s", protocol)
+	}
+}
+
+func parsePrivProtocol(protocol string) (gosnmp.SnmpV3PrivProtocol, error) {
+	switch strings.ToUpper(protocol) {
+	case "DES":
+		return gosnmp.DES, nil
+	case "AES", "":
+		return gosnmp.AES, nil
+	case "AES192":
+		return gosnmp.AES192, nil
+	case "AES256":
+		return gosnmp.AES256, nil
+	case "AES192C":
+		return gosnmp.AES192C, nil
+	case "AES256C":
+		return gosnmp.AES256C, nil
+	default:
+		return gosnmp.NoPriv, fmt.Errorf("unsupported SNMPv3 privacy protocol: %s", protocol)
+	}
+}
+
+// ValidateCredential checks a credential before it is stored
+func ValidateCredential(cred *models.SNMPCredential) error {
+	switch cred.Version {
+	case models.SNMPVersion1, models.SNMPVersion2c:
+		if cred.Community == "" {
+			return fmt.Errorf("community string is required for SNMPv%s", cred.Version)
+		}
+	case models.SNMPVersion3:
+		if cred.Username == "" {
+			return fmt.Errorf("username is required for SNMPv3")
+		}
+		if cred.PrivPassphrase != "" && cred.AuthPassphrase == "" {
+			return fmt.Errorf("SNMPv3 privacy requires authentication")
+		}
+		if cred.AuthPassphrase != "" {
+			if _, err := parseAuthProtocol(cred.AuthProtocol); err != nil {
+				return err
+			}
+		}
+		if cred.PrivPassphrase != "" {
+			if _, err := parsePrivProtocol(cred.PrivProtocol); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported SNMP version: %s", cred.Version)
+	}
+
+	if cred.Port < 0 || cred.Port > 65535 {
+		return fmt.Errorf("invalid SNMP port: %d", cred.Port)
+	}
+	return nil
+}
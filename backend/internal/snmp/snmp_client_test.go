@@ -0,0 +1,176 @@
+package snmp
+
+import (
+	"testing"
+	"time"
+
+	"reconya-ai/models"
+	"reconya-ai/tests/testutils"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSwitchMIB() []gosnmp.SnmpPDU {
+	return []gosnmp.SnmpPDU{
+		{Name: oidSysDescr, Type: gosnmp.OctetString, Value: []byte("Cisco IOS Software, C2960 Software, Version 15.0(2)SE11")},
+		{Name: oidSysObjectID, Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.4.1.9.1.1208"},
+		{Name: oidSysUpTime, Type: gosnmp.TimeTicks, Value: uint32(123456)},
+		{Name: oidSysContact, Type: gosnmp.OctetString, Value: []byte("noc@example.com")},
+		{Name: oidSysName, Type: gosnmp.OctetString, Value: []byte("core-sw1")},
+		{Name: oidSysLocation, Type: gosnmp.OctetString, Value: []byte("Rack 3")},
+
+		{Name: oidIfDescr + ".1", Type: gosnmp.OctetString, Value: []byte("GigabitEthernet0/1")},
+		{Name: oidIfDescr + ".2", Type: gosnmp.OctetString, Value: []byte("GigabitEthernet0/2")},
+		{Name: oidIfType + ".1", Type: gosnmp.Integer, Value: 6},
+		{Name: oidIfType + ".2", Type: gosnmp.Integer, Value: 6},
+		{Name: oidIfMtu + ".1", Type: gosnmp.Integer, Value: 1500},
+		{Name: oidIfMtu + ".2", Type: gosnmp.Integer, Value: 1500},
+		{Name: oidIfSpeed + ".1", Type: gosnmp.Gauge32, Value: uint(1000000000)},
+		{Name: oidIfSpeed + ".2", Type: gosnmp.Gauge32, Value: uint(100000000)},
+		{Name: oidIfPhysAddress + ".1", Type: gosnmp.OctetString, Value: []byte{0x00, 0x1b, 0x63, 0x01, 0x02, 0x03}},
+		{Name: oidIfPhysAddress + ".2", Type: gosnmp.OctetString, Value: []byte{0x00, 0x1b, 0x63, 0x01, 0x02, 0x04}},
+		{Name: oidIfAdminStatus + ".1", Type: gosnmp.Integer, Value: 1},
+		{Name: oidIfAdminStatus + ".2", Type: gosnmp.Integer, Value: 1},
+		{Name: oidIfOperStatus + ".1", Type: gosnmp.Integer, Value: 1},
+		{Name: oidIfOperStatus + ".2", Type: gosnmp.Integer, Value: 2},
+		{Name: oidIfName + ".1", Type: gosnmp.OctetString, Value: []byte("Gi0/1")},
+		{Name: oidIfName + ".2", Type: gosnmp.OctetString, Value: []byte("Gi0/2")},
+		{Name: oidIfHighSpeed + ".1", Type: gosnmp.Gauge32, Value: uint(1000)},
+		{Name: oidIfAlias + ".1", Type: gosnmp.OctetString, Value: []byte("uplink")},
+	}
+}
+
+func TestClientSystemInfoAndInterfaces(t *testing.T) {
+	agent := testutils.NewSNMPAgent(t, "s3cret", testSwitchMIB())
+
+	for _, version := range []models.SNMPVersion{models.SNMPVersion1, models.SNMPVersion2c} {
+		t.Run("v"+string(version), func(t *testing.T) {
+			cred := &models.SNMPCredential{Version: version, Community: "s3cret", Port: agent.Port()}
+			client, err := NewClient("127.0.0.1", cred, time.Second)
+			require.NoError(t, err)
+			defer client.Close()
+
+			info, err := client.SystemInfo()
+			require.NoError(t, err)
+			assert.Equal(t, "core-sw1", info.SysName)
+			assert.Equal(t, "Rack 3", info.SysLocation)
+			assert.Equal(t, "noc@example.com", info.SysContact)
+			assert.Equal(t, ".1.3.6.1.4.1.9.1.1208", info.SysObjectID)
+			assert.Equal(t, int64(1234), info.UptimeSeconds)
+			assert.Equal(t, version, info.Version)
+
+			interfaces, err := client.Interfaces()
+			require.NoError(t, err)
+			require.Len(t, interfaces, 2)
+
+			assert.Equal(t, 1, interfaces[0].Index)
+			assert.Equal(t, "Gi0/1", interfaces[0].Name)
+			assert.Equal(t, "uplink", interfaces[0].Alias)
+			assert.Equal(t, "00:1B:63:01:02:03", interfaces[0].MAC)
+			assert.Equal(t, int64(1000), interfaces[0].SpeedMbps)
+			assert.Equal(t, "up", interfaces[0].OperStatus)
+
+			assert.Equal(t, int64(100), interfaces[1].SpeedMbps)
+			assert.Equal(t, "down", interfaces[1].OperStatus)
+		})
+	}
+}
+
+func TestClientWrongCommunityTimesOut(t *testing.T) {
+	agent := testutils.NewSNMPAgent(t, "s3cret", testSwitchMIB())
+
+	cred := &models.SNMPCredential{Version: models.SNMPVersion2c, Community: "public", Port: agent.Port()}
+	client, err := NewClient("127.0.0.1", cred, 200*time.Millisecond)
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.SystemInfo()
+	assert.Error(t, err)
+}
+
+func TestValidateCredential(t *testing.T) {
+	assert.NoError(t, ValidateCredential(&models.SNMPCredential{Version: models.SNMPVersion2c, Community: "public"}))
+	assert.Error(t, ValidateCredential(&models.SNMPCredential{Version: models.SNMPVersion2c}))
+	assert.Error(t, ValidateCredential(&models.SNMPCredential{Version: "4", Community: "public"}))
+	assert.NoError(t, ValidateCredential(&models.SNMPCredential{Version: models.SNMPVersion3, Username: "admin", AuthProtocol: "SHA256", AuthPassphrase: "authpass", PrivProtocol: "AES", PrivPassphrase: "privpass"}))
+	assert.Error(t, ValidateCredential(&models.SNMPCredential{Version: models.SNMPVersion3, Username: "admin", PrivPassphrase: "privpass"}))
+	assert.Error(t, ValidateCredential(&models.SNMPCredential{Version: models.SNMPVersion3, Username: "admin", AuthProtocol: "SHA3", AuthPassphrase: "authpass"}))
+}
+
+func testRouterSwitchMIB() []gosnmp.SnmpPDU {
+	pdus := testSwitchMIB()
+	return append(pdus,
+		// ipNetToMediaTable: two remote hosts on ifIndex 1, one invalid entry
+		gosnmp.SnmpPDU{Name: oidIPNetToMediaPhysAddress + ".1.10.20.0.5", Type: gosnmp.OctetString, Value: []byte{0xaa, 0xbb, 0xcc, 0x00, 0x00, 0x05}},
+		gosnmp.SnmpPDU{Name: oidIPNetToMediaPhysAddress + ".1.10.20.0.6", Type: gosnmp.OctetString, Value: []byte{0xaa, 0xbb, 0xcc, 0x00, 0x00, 0x06}},
+		gosnmp.SnmpPDU{Name: oidIPNetToMediaPhysAddress + ".1.10.20.0.7", Type: gosnmp.OctetString, Value: []byte{0xaa, 0xbb, 0xcc, 0x00, 0x00, 0x07}},
+		gosnmp.SnmpPDU{Name: oidIPNetToMediaType + ".1.10.20.0.5", Type: gosnmp.Integer, Value: 3},
+		gosnmp.SnmpPDU{Name: oidIPNetToMediaType + ".1.10.20.0.6", Type: gosnmp.Integer, Value: 4},
+		gosnmp.SnmpPDU{Name: oidIPNetToMediaType + ".1.10.20.0.7", Type: gosnmp.Integer, Value: 2},
+
+		// Bridge port 2 maps to ifIndex 2, Q-BRIDGE entries in VLAN 10
+		gosnmp.SnmpPDU{Name: oidDot1dBasePortIfIndex + ".1", Type: gosnmp.Integer, Value: 1},
+		gosnmp.SnmpPDU{Name: oidDot1dBasePortIfIndex + ".2", Type: gosnmp.Integer, Value: 2},
+		gosnmp.SnmpPDU{Name: oidDot1qTpFdbPort + ".10.170.187.204.0.0.5", Type: gosnmp.Integer, Value: 2},
+		gosnmp.SnmpPDU{Name: oidDot1qTpFdbPort + ".10.0.27.99.1.2.3", Type: gosnmp.Integer, Value: 0},
+		gosnmp.SnmpPDU{Name: oidDot1qTpFdbStatus + ".10.170.187.204.0.0.5", Type: gosnmp.Integer, Value: 3},
+		gosnmp.SnmpPDU{Name: oidDot1qTpFdbStatus + ".10.0.27.99.1.2.3", Type: gosnmp.Integer, Value: 4},
+
+		// LLDP neighbor on local port 1 with management address 10.20.0.1
+		gosnmp.SnmpPDU{Name: oidLldpLocPortID + ".1", Type: gosnmp.OctetString, Value: []byte("Gi0/1")},
+		gosnmp.SnmpPDU{Name: oidLldpRemChassisIDSubtype + ".0.1.1", Type: gosnmp.Integer, Value: 4},
+		gosnmp.SnmpPDU{Name: oidLldpRemChassisID + ".0.1.1", Type: gosnmp.OctetString, Value: []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}},
+		gosnmp.SnmpPDU{Name: oidLldpRemPortIDSubtype + ".0.1.1", Type: gosnmp.Integer, Value: 5},
+		gosnmp.SnmpPDU{Name: oidLldpRemPortID + ".0.1.1", Type: gosnmp.OctetString, Value: []byte("Gi1/0/48")},
+		gosnmp.SnmpPDU{Name: oidLldpRemSysName + ".0.1.1", Type: gosnmp.OctetString, Value: []byte("dist-sw1")},
+		gosnmp.SnmpPDU{Name: oidLldpRemManAddrIfSubtype + ".0.1.1.1.4.10.20.0.1", Type: gosnmp.Integer, Value: 2},
+
+		// CDP neighbor on ifIndex 2
+		gosnmp.SnmpPDU{Name: oidCdpCacheAddress + ".2.1", Type: gosnmp.OctetString, Value: []byte{10, 20, 0, 9}},
+		gosnmp.SnmpPDU{Name: oidCdpCacheDeviceID + ".2.1", Type: gosnmp.OctetString, Value: []byte("SEP001122334455")},
+		gosnmp.SnmpPDU{Name: oidCdpCacheDevicePort + ".2.1", Type: gosnmp.OctetString, Value: []byte("Port 1")},
+		gosnmp.SnmpPDU{Name: oidCdpCachePlatform + ".2.1", Type: gosnmp.OctetString, Value: []byte("Cisco IP Phone 7945")},
+	)
+}
+
+func TestClientTables(t *testing.T) {
+	agent := testutils.NewSNMPAgent(t, "s3cret", testRouterSwitchMIB())
+
+	cred := &models.SNMPCredential{Version: models.SNMPVersion2c, Community: "s3cret", Port: agent.Port()}
+	client, err := NewClient("127.0.0.1", cred, time.Second)
+	require.NoError(t, err)
+	defer client.Close()
+
+	arp, err := client.ARPTable()
+	require.NoError(t, err)
+	assert.Equal(t, []ARPEntry{
+		{IfIndex: 1, IP: "10.20.0.5", MAC: "AA:BB:CC:00:00:05"},
+		{IfIndex: 1, IP: "10.20.0.6", MAC: "AA:BB:CC:00:00:06"},
+	}, arp)
+
+	fdb, err := client.BridgeFDB()
+	require.NoError(t, err)
+	assert.Equal(t, []FDBEntry{{MAC: "AA:BB:CC:00:00:05", BridgePort: 2, IfIndex: 2, VLAN: 10}}, fdb)
+
+	lldp, err := client.LLDPNeighbors()
+	require.NoError(t, err)
+	require.Len(t, lldp, 1)
+	assert.Equal(t, models.SNMPNeighbor{
+		Protocol:      models.NeighborProtocolLLDP,
+		LocalPort:     "Gi0/1",
+		ChassisID:     "00:11:22:33:44:55",
+		RemotePort:    "Gi1/0/48",
+		RemoteSysName: "dist-sw1",
+		RemoteAddress: "10.20.0.1",
+	}, lldp[0])
+
+	cdp, err := client.CDPNeighbors()
+	require.NoError(t, err)
+	require.Len(t, cdp, 1)
+	assert.Equal(t, 2, cdp[0].LocalIfIndex)
+	assert.Equal(t, "10.20.0.9", cdp[0].RemoteAddress)
+	assert.Equal(t, "SEP001122334455", cdp[0].RemoteSysName)
+	assert.Equal(t, "Cisco IP Phone 7945", cdp[0].RemotePlatform)
+}
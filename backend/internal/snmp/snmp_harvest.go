@@ -0,0 +1,219 @@
+package snmp
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"reconya-ai/models"
+)
+
+// SwitchPortAssignments maps devices to the switch port they were seen on
+type SwitchPortAssignments struct {
+	// ByIP holds LLDP/CDP neighbors, which identify the attached device directly
+	ByIP map[string]models.SwitchPortInfo
+	// ByMAC holds forwarding database entries
+	ByMAC map[string]models.SwitchPortInfo
+}
+
+// applyTables uses the ARP, forwarding and neighbor tables collected from a set of
+// polled agents to fill in MAC addresses, vendors, hostnames and switch ports of
+// known devices on any network
+func (s *SNMPService) applyTables(results []*PollResult) {
+	arp := MergeARPTables(results)
+	assignments := AssignSwitchPorts(results, time.Now())
+	names := neighborNames(results)
+
+	if len(arp) == 0 && len(assignments.ByIP) == 0 && len(assignments.ByMAC) == 0 && len(names) == 0 {
+		return
+	}
+
+	devices, err := s.DeviceService.FindAll()
+	if err != nil {
+		logger.Errorf("Error loading devices to apply SNMP tables: %v", err)
+		return
+	}
+
+	updated := 0
+	for _, d := range devices {
+		changed := false
+
+		if mac, ok := arp[d.IPv4]; ok && (d.MAC == nil || !strings.EqualFold(*d.MAC, mac)) {
+			if d.MAC != nil && *d.MAC != "" {
+				logger.Infof("ARP table reports new MAC %s for %s (was %s)", mac, d.IPv4, *d.MAC)
+			}
+			macAddress := mac
+			d.MAC = &macAddress
+			if vendor := s.DeviceService.LookupVendor(mac); vendor != "" {
+				d.Vendor = &vendor
+			}
+			changed = true
+		}
+
+		if (d.Hostname == nil || *d.Hostname == "") && names[d.IPv4] != "" {
+			hostname := names[d.IPv4]
+			d.Hostname = &hostname
+			changed = true
+		}
+
+		port, ok := assignments.ByIP[d.IPv4]
+		if !ok && d.MAC != nil {
+			port, ok = assignments.ByMAC[strings.ToUpper(*d.MAC)]
+		}
+		// A device is never reported as attached to itself. The assignment is rewritten
+		// even when unchanged so SeenAt tells stale ports apart.
+		if ok && port.SwitchDeviceID != d.ID {
+			d.SwitchPort = &port
+			changed = true
+		}
+
+		if !changed {
+			continue
+		}
+		if err := s.DeviceService.UpdateDeviceRecord(d); err != nil {
+			logger.Errorf("Error saving SNMP table data for %s: %v", d.IPv4, err)
+			continue
+		}
+		updated++
+	}
+
+	logger.Infof("Applied SNMP ARP/FDB/neighbor tables: %d ARP entries, %d switch port entries, %d devices updated",
+		len(arp), len(assignments.ByIP)+len(assignments.ByMAC), updated)
+}
+
+// MergeARPTables combines the ARP tables of all agents into an IP to MAC map.
+// The first agent reporting an address wins.
+func MergeARPTables(results []*PollResult) map[string]string {
+	arp := make(map[string]string)
+	for _, result := range results {
+		for _, entry := range result.ARP {
+			if _, ok := arp[entry.IP]; !ok {
+				arp[entry.IP] = entry.MAC
+			}
+		}
+	}
+	return arp
+}
+
+// AssignSwitchPorts decides which switch port each MAC address or neighbor is attached to.
+// Ports with an LLDP/CDP neighbor are treated as uplinks and ignored for forwarding
+// entries; when a MAC is learned on several switches the port with the fewest MACs wins,
+// since that is the one closest to the device.
+func AssignSwitchPorts(results []*PollResult, now time.Time) SwitchPortAssignments {
+	assignments := SwitchPortAssignments{
+		ByIP:  make(map[string]models.SwitchPortInfo),
+		ByMAC: make(map[string]models.SwitchPortInfo),
+	}
+	macCounts := make(map[string]int)
+	neighborMACs := make(map[string]models.SwitchPortInfo)
+
+	for _, result := range results {
+		if result.Device == nil || result.Info == nil {
+			continue
+		}
+
+		interfaces := make(map[int]models.SNMPInterface)
+		ownMACs := make(map[string]bool)
+		for _, iface := range result.Info.Interfaces {
+			interfaces[iface.Index] = iface
+			if iface.MAC != "" {
+				ownMACs[iface.MAC] = true
+			}
+		}
+
+		uplinks := make(map[int]bool)
+		for _, neighbor := range result.Info.Neighbors {
+			ifIndex := neighbor.LocalIfIndex
+			if ifIndex == 0 {
+				ifIndex = interfaceIndexByName(result.Info.Interfaces, neighbor.LocalPort)
+			}
+			if ifIndex != 0 {
+				uplinks[ifIndex] = true
+			}
+
+			source := models.SwitchPortSourceLLDP
+			if neighbor.Protocol == models.NeighborProtocolCDP {
+				source = models.SwitchPortSourceCDP
+			}
+			port := switchPortInfo(result, interfaces, ifIndex, neighbor.LocalPort, 0, source, now)
+			if neighbor.RemoteAddress != "" {
+				assignments.ByIP[neighbor.RemoteAddress] = port
+			} else if _, err := net.ParseMAC(neighbor.ChassisID); err == nil {
+				neighborMACs[strings.ToUpper(neighbor.ChassisID)] = port
+			}
+		}
+
+		portMACs := make(map[int]int)
+		for _, entry := range result.FDB {
+			portMACs[entry.BridgePort]++
+		}
+
+		for _, entry := range result.FDB {
+			if ownMACs[entry.MAC] || (entry.IfIndex != 0 && uplinks[entry.IfIndex]) {
+				continue
+			}
+			count := portMACs[entry.BridgePort]
+			if previous, ok := macCounts[entry.MAC]; ok && previous <= count {
+				continue
+			}
+			macCounts[entry.MAC] = count
+			assignments.ByMAC[entry.MAC] = switchPortInfo(result, interfaces, entry.IfIndex, "", entry.VLAN, models.SwitchPortSourceFDB, now)
+		}
+	}
+
+	// Neighbors identified only by chassis MAC are more reliable than forwarding entries
+	for mac, port := range neighborMACs {
+		assignments.ByMAC[mac] = port
+	}
+
+	return assignments
+}
+
+func switchPortInfo(result *PollResult, interfaces map[int]models.SNMPInterface, ifIndex int, fallbackName string, vlan int, source models.SwitchPortSource, now time.Time) models.SwitchPortInfo {
+	info := models.SwitchPortInfo{
+		SwitchDeviceID: result.Device.ID,
+		SwitchName:     result.Info.SysName,
+		SwitchIP:       result.Device.IPv4,
+		IfIndex:        ifIndex,
+		Port:           fallbackName,
+		VLAN:           vlan,
+		Source:         source,
+		SeenAt:         now,
+	}
+	if iface, ok := interfaces[ifIndex]; ok {
+		if iface.Name != "" {
+			info.Port = iface.Name
+		} else if iface.Descr != "" {
+			info.Port = iface.Descr
+		}
+	}
+	return info
+}
+
+func interfaceIndexByName(interfaces []models.SNMPInterface, name string) int {
+	if name == "" {
+		return 0
+	}
+	for _, iface := range interfaces {
+		if strings.EqualFold(iface.Name, name) || strings.EqualFold(iface.Descr, name) {
+			return iface.Index
+		}
+	}
+	return 0
+}
+
+// neighborNames maps neighbor management addresses to their advertised system names
+func neighborNames(results []*PollResult) map[string]string {
+	names := make(map[string]string)
+	for _, result := range results {
+		if result.Info == nil {
+			continue
+		}
+		for _, neighbor := range result.Info.Neighbors {
+			if neighbor.RemoteAddress != "" && neighbor.RemoteSysName != "" {
+				names[neighbor.RemoteAddress] = neighbor.RemoteSysName
+			}
+		}
+	}
+	return names
+}
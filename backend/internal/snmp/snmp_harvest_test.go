@@ -0,0 +1,81 @@
+package snmp
+
+import (
+	"testing"
+	"time"
+
+	"reconya-ai/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssignSwitchPorts(t *testing.T) {
+	now := time.Now()
+
+	access := &PollResult{
+		Device: &models.Device{ID: "access-sw", IPv4: "10.0.0.2"},
+		Info: &models.SNMPInfo{
+			SysName: "access-sw",
+			Interfaces: []models.SNMPInterface{
+				{Index: 1, Name: "Gi0/1", MAC: "00:00:00:00:02:01"},
+				{Index: 5, Name: "Gi0/5"},
+				{Index: 24, Name: "Gi0/24"},
+			},
+			Neighbors: []models.SNMPNeighbor{
+				{Protocol: models.NeighborProtocolLLDP, LocalPort: "Gi0/24", ChassisID: "00:00:00:00:01:01", RemoteAddress: "10.0.0.1"},
+			},
+		},
+		FDB: []FDBEntry{
+			{MAC: "AA:00:00:00:00:01", BridgePort: 5, IfIndex: 5, VLAN: 10},
+			// Learned through the uplink, must be ignored
+			{MAC: "AA:00:00:00:00:02", BridgePort: 24, IfIndex: 24, VLAN: 10},
+			// The switch's own interface
+			{MAC: "00:00:00:00:02:01", BridgePort: 1, IfIndex: 1},
+		},
+	}
+
+	core := &PollResult{
+		Device: &models.Device{ID: "core-sw", IPv4: "10.0.0.1"},
+		Info: &models.SNMPInfo{
+			SysName:    "core-sw",
+			Interfaces: []models.SNMPInterface{{Index: 1, Name: "Te1/1"}, {Index: 2, Name: "Te1/2"}},
+		},
+		FDB: []FDBEntry{
+			// Both MACs are behind the access switch on Te1/1, the access port is closer
+			{MAC: "AA:00:00:00:00:01", BridgePort: 1, IfIndex: 1},
+			{MAC: "AA:00:00:00:00:02", BridgePort: 1, IfIndex: 1},
+			{MAC: "AA:00:00:00:00:03", BridgePort: 1, IfIndex: 1},
+		},
+	}
+
+	assignments := AssignSwitchPorts([]*PollResult{access, core}, now)
+
+	port := assignments.ByMAC["AA:00:00:00:00:01"]
+	assert.Equal(t, "access-sw", port.SwitchDeviceID)
+	assert.Equal(t, "Gi0/5", port.Port)
+	assert.Equal(t, 10, port.VLAN)
+	assert.Equal(t, models.SwitchPortSourceFDB, port.Source)
+
+	port = assignments.ByMAC["AA:00:00:00:00:02"]
+	assert.Equal(t, "core-sw", port.SwitchDeviceID)
+	assert.Equal(t, "Te1/1", port.Port)
+
+	_, ok := assignments.ByMAC["00:00:00:00:02:01"]
+	assert.False(t, ok)
+
+	neighbor := assignments.ByIP["10.0.0.1"]
+	assert.Equal(t, "access-sw", neighbor.SwitchDeviceID)
+	assert.Equal(t, 24, neighbor.IfIndex)
+	assert.Equal(t, "Gi0/24", neighbor.Port)
+	assert.Equal(t, models.SwitchPortSourceLLDP, neighbor.Source)
+}
+
+func TestMergeARPTables(t *testing.T) {
+	results := []*PollResult{
+		{ARP: []ARPEntry{{IP: "10.1.0.5", MAC: "AA:00:00:00:00:05"}}},
+		{ARP: []ARPEntry{{IP: "10.1.0.5", MAC: "BB:00:00:00:00:05"}, {IP: "10.1.0.6", MAC: "AA:00:00:00:00:06"}}},
+	}
+
+	arp := MergeARPTables(results)
+	assert.Equal(t, map[string]string{"10.1.0.5": "AA:00:00:00:00:05", "10.1.0.6": "AA:00:00:00:00:06"}, arp)
+}
@@ -0,0 +1,352 @@
+package snmp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/exclusion"
+	"reconya-ai/internal/fingerprint"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/util"
+	"reconya-ai/models"
+)
+
+var logger = logging.For("snmp")
+
+type SNMPService struct {
+	Repository    *db.SNMPCredentialRepository
+	DeviceService *device.DeviceService
+	// Exclusions skip SNMP polling
+	Exclusions         *exclusion.ExclusionService
+	fingerprintService *fingerprint.FingerprintService
+	secretKey          []byte
+	timeout            time.Duration
+	pollInterval       time.Duration
+	workers            int
+	lastRun            map[string]time.Time
+	mutex              sync.Mutex
+}
+
+func NewSNMPService(repository *db.SNMPCredentialRepository, deviceService *device.DeviceService, secretKey []byte) *SNMPService {
+	return &SNMPService{
+		Repository:         repository,
+		DeviceService:      deviceService,
+		fingerprintService: fingerprint.NewFingerprintService(),
+		secretKey:          secretKey,
+		timeout:            2 * time.Second,
+		pollInterval:       15 * time.Minute,
+		workers:            10,
+		lastRun:            make(map[string]time.Time),
+	}
+}
+
+// SaveCredential validates and stores a credential with its secrets encrypted.
+// Empty secrets on an existing credential keep their stored value.
+func (s *SNMPService) SaveCredential(cred *models.SNMPCredential) (*models.SNMPCredential, error) {
+	ctx := context.Background()
+
+	if cred.ID != "" {
+		existing, err := s.Repository.FindByID(ctx, cred.ID)
+		if err != nil {
+			return nil, err
+		}
+		if existing.NetworkID != cred.NetworkID {
+			return nil, fmt.Errorf("credential belongs to a different network")
+		}
+		cred.CreatedAt = existing.CreatedAt
+		if err := s.decrypt(existing); err != nil {
+			return nil, err
+		}
+		if cred.Community == "" {
+			cred.Community = existing.Community
+		}
+		if cred.AuthPassphrase == "" {
+			cred.AuthPassphrase = existing.AuthPassphrase
+		}
+		if cred.PrivPassphrase == "" {
+			cred.PrivPassphrase = existing.PrivPassphrase
+		}
+	}
+
+	if cred.Port == 0 {
+		cred.Port = 161
+	}
+	if err := ValidateCredential(cred); err != nil {
+		return nil, err
+	}
+
+	stored := *cred
+	var err error
+	if stored.Community, err = util.EncryptSecret(s.secretKey, cred.Community); err != nil {
+		return nil, err
+	}
+	if stored.AuthPassphrase, err = util.EncryptSecret(s.secretKey, cred.AuthPassphrase); err != nil {
+		return nil, err
+	}
+	if stored.PrivPassphrase, err = util.EncryptSecret(s.secretKey, cred.PrivPassphrase); err != nil {
+		return nil, err
+	}
+
+	if err := s.Repository.Upsert(ctx, &stored); err != nil {
+		return nil, err
+	}
+
+	redacted := stored.Redacted()
+	return &redacted, nil
+}
+
+// ListCredentials returns the credentials of a network with secrets redacted
+func (s *SNMPService) ListCredentials(networkID string) ([]models.SNMPCredential, error) {
+	creds, err := s.Repository.FindByNetworkID(context.Background(), networkID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.SNMPCredential, 0, len(creds))
+	for _, cred := range creds {
+		result = append(result, cred.Redacted())
+	}
+	return result, nil
+}
+
+// DeleteCredential removes a single credential
+func (s *SNMPService) DeleteCredential(id string) error {
+	return s.Repository.Delete(context.Background(), id)
+}
+
+// DeleteCredentialsForNetwork removes all credentials of a network, used before the network itself is deleted
+func (s *SNMPService) DeleteCredentialsForNetwork(networkID string) error {
+	return s.Repository.DeleteByNetworkID(context.Background(), networkID)
+}
+
+// CredentialsForNetwork loads and decrypts the credentials of a network
+func (s *SNMPService) CredentialsForNetwork(networkID string) ([]*models.SNMPCredential, error) {
+	creds, err := s.Repository.FindByNetworkID(context.Background(), networkID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cred := range creds {
+		if err := s.decrypt(cred); err != nil {
+			return nil, fmt.Errorf("failed to decrypt SNMP credential %s: %w", cred.ID, err)
+		}
+	}
+	return creds, nil
+}
+
+func (s *SNMPService) decrypt(cred *models.SNMPCredential) error {
+	var err error
+	if cred.Community, err = util.DecryptSecret(s.secretKey, cred.Community); err != nil {
+		return err
+	}
+	if cred.AuthPassphrase, err = util.DecryptSecret(s.secretKey, cred.AuthPassphrase); err != nil {
+		return err
+	}
+	if cred.PrivPassphrase, err = util.DecryptSecret(s.secretKey, cred.PrivPassphrase); err != nil {
+		return err
+	}
+	return nil
+}
+
+// PollResult is everything read from a single agent in one poll
+type PollResult struct {
+	Info       *models.SNMPInfo
+	ARP        []ARPEntry
+	FDB        []FDBEntry
+	Credential *models.SNMPCredential
+	// Device is the polled device, set once the result has been stored
+	Device *models.Device
+}
+
+// Query polls a single target with each credential in turn and returns the first answer
+func (s *SNMPService) Query(target string, creds []*models.SNMPCredential) (*PollResult, error) {
+	var lastErr error
+	for _, cred := range creds {
+		result, err := s.queryWithCredential(target, cred)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		result.Credential = cred
+		return result, nil
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no SNMP credentials configured")
+	}
+	return nil, lastErr
+}
+
+func (s *SNMPService) queryWithCredential(target string, cred *models.SNMPCredential) (*PollResult, error) {
+	client, err := NewClient(target, cred, s.timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	info, err := client.SystemInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	interfaces, err := client.Interfaces()
+	if err != nil {
+		logger.Errorf("Error walking SNMP interfaces on %s: %v", target, err)
+	}
+	info.Interfaces = interfaces
+
+	// The remaining tables are optional, most hosts only implement a few of them
+	result := &PollResult{Info: info}
+	if lldp, err := client.LLDPNeighbors(); err == nil {
+		info.Neighbors = append(info.Neighbors, lldp...)
+	}
+	if cdp, err := client.CDPNeighbors(); err == nil {
+		info.Neighbors = append(info.Neighbors, cdp...)
+	}
+	if arp, err := client.ARPTable(); err == nil {
+		result.ARP = arp
+	}
+	if fdb, err := client.BridgeFDB(); err == nil {
+		result.FDB = fdb
+	}
+
+	return result, nil
+}
+
+// InterrogateDevice polls a device with its network's credentials and stores the result
+func (s *SNMPService) InterrogateDevice(deviceID string) (*models.Device, error) {
+	existing, err := s.DeviceService.FindByID(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, db.ErrNotFound
+	}
+	if existing.NetworkID == "" {
+		return nil, fmt.Errorf("device is not assigned to a network")
+	}
+	var mac string
+	if existing.MAC != nil {
+		mac = *existing.MAC
+	}
+	if rule := s.Exclusions.Blocked(models.ExcludeSNMP, existing.IPv4, mac); rule != nil {
+		return nil, exclusion.BlockedError(rule, models.ExcludeSNMP)
+	}
+
+	creds, err := s.CredentialsForNetwork(existing.NetworkID)
+	if err != nil {
+		return nil, err
+	}
+	if len(creds) == 0 {
+		return nil, fmt.Errorf("no SNMP credentials configured for this network")
+	}
+
+	result, err := s.Query(existing.IPv4, creds)
+	if err != nil {
+		return nil, fmt.Errorf("SNMP query failed: %w", err)
+	}
+
+	updated, err := s.apply(existing, result.Info)
+	if err != nil {
+		return nil, err
+	}
+	result.Device = updated
+
+	s.applyTables([]*PollResult{result})
+	return updated, nil
+}
+
+// Run polls every online device of the network that has not been polled recently.
+// Networks without credentials and hosts the exclusion rules protect are skipped.
+func (s *SNMPService) Run(network *models.Network) {
+	if network == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	if last, ok := s.lastRun[network.ID]; ok && time.Since(last) < s.pollInterval {
+		s.mutex.Unlock()
+		return
+	}
+	s.lastRun[network.ID] = time.Now()
+	s.mutex.Unlock()
+
+	creds, err := s.CredentialsForNetwork(network.ID)
+	if err != nil {
+		logger.Errorf("Error loading SNMP credentials for network %s: %v", network.CIDR, err)
+		return
+	}
+	if len(creds) == 0 {
+		return
+	}
+
+	devices, err := s.DeviceService.FindOnlineDevicesForNetwork(network.CIDR)
+	if err != nil {
+		logger.Errorf("Error loading devices for SNMP polling on %s: %v", network.CIDR, err)
+		return
+	}
+	devices = s.Exclusions.Filter(models.ExcludeSNMP, devices)
+
+	logger.Infof("Polling %d devices on %s via SNMP", len(devices), network.CIDR)
+
+	jobs := make(chan models.Device)
+	var wg sync.WaitGroup
+	var results []*PollResult
+	var resultsMutex sync.Mutex
+
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range jobs {
+				if net.ParseIP(d.IPv4) == nil {
+					continue
+				}
+				result, err := s.Query(d.IPv4, creds)
+				if err != nil {
+					continue
+				}
+
+				updated, err := s.apply(&d, result.Info)
+				if err != nil {
+					logger.Errorf("Error saving SNMP info for %s: %v", d.IPv4, err)
+					continue
+				}
+				result.Device = updated
+
+				resultsMutex.Lock()
+				results = append(results, result)
+				resultsMutex.Unlock()
+			}
+		}()
+	}
+
+	for _, d := range devices {
+		jobs <- d
+	}
+	close(jobs)
+	wg.Wait()
+
+	logger.Infof("SNMP polling on %s completed, %d devices answered", network.CIDR, len(results))
+
+	s.applyTables(results)
+}
+
+// apply stores SNMP data on a device and feeds it into naming and fingerprinting
+func (s *SNMPService) apply(d *models.Device, info *models.SNMPInfo) (*models.Device, error) {
+	d.SNMP = info
+
+	if (d.Hostname == nil || *d.Hostname == "") && info.SysName != "" {
+		hostname := info.SysName
+		d.Hostname = &hostname
+	}
+
+	s.fingerprintService.AnalyzeSNMP(d)
+
+	return s.DeviceService.CreateOrUpdate(d)
+}
@@ -0,0 +1,353 @@
+package snmp
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"reconya-ai/models"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// IP-MIB, BRIDGE-MIB, Q-BRIDGE-MIB, LLDP-MIB and CISCO-CDP-MIB columns
+const (
+	oidIPNetToMediaPhysAddress = ".1.3.6.1.2.1.4.22.1.2"
+	oidIPNetToMediaType        = ".1.3.6.1.2.1.4.22.1.4"
+
+	oidDot1dBasePortIfIndex = ".1.3.6.1.2.1.17.1.4.1.2"
+	oidDot1dTpFdbPort       = ".1.3.6.1.2.1.17.4.3.1.2"
+	oidDot1dTpFdbStatus     = ".1.3.6.1.2.1.17.4.3.1.3"
+	oidDot1qTpFdbPort       = ".1.3.6.1.2.1.17.7.1.2.2.1.2"
+	oidDot1qTpFdbStatus     = ".1.3.6.1.2.1.17.7.1.2.2.1.3"
+
+	oidLldpLocPortID           = ".1.0.8802.1.1.2.1.3.7.1.3"
+	oidLldpLocPortDesc         = ".1.0.8802.1.1.2.1.3.7.1.4"
+	oidLldpRemChassisIDSubtype = ".1.0.8802.1.1.2.1.4.1.1.4"
+	oidLldpRemChassisID        = ".1.0.8802.1.1.2.1.4.1.1.5"
+	oidLldpRemPortIDSubtype    = ".1.0.8802.1.1.2.1.4.1.1.6"
+	oidLldpRemPortID           = ".1.0.8802.1.1.2.1.4.1.1.7"
+	oidLldpRemPortDesc         = ".1.0.8802.1.1.2.1.4.1.1.8"
+	oidLldpRemSysName          = ".1.0.8802.1.1.2.1.4.1.1.9"
+	oidLldpRemSysDesc          = ".1.0.8802.1.1.2.1.4.1.1.10"
+	oidLldpRemManAddrIfSubtype = ".1.0.8802.1.1.2.1.4.2.1.3"
+
+	oidCdpCacheAddress    = ".1.3.6.1.4.1.9.9.23.1.2.1.1.4"
+	oidCdpCacheDeviceID   = ".1.3.6.1.4.1.9.9.23.1.2.1.1.6"
+	oidCdpCacheDevicePort = ".1.3.6.1.4.1.9.9.23.1.2.1.1.7"
+	oidCdpCachePlatform   = ".1.3.6.1.4.1.9.9.23.1.2.1.1.8"
+)
+
+// fdbStatusLearned is dot1dTpFdbStatus/dot1qTpFdbStatus learned(3)
+const fdbStatusLearned = 3
+
+// ARPEntry is a row of a router's ipNetToMediaTable
+type ARPEntry struct {
+	IfIndex int
+	IP      string
+	MAC     string
+}
+
+// FDBEntry is a learned MAC address in a switch's forwarding database
+type FDBEntry struct {
+	MAC        string
+	BridgePort int
+	IfIndex    int
+	VLAN       int
+}
+
+// ARPTable walks ipNetToMediaTable and returns dynamic and static entries
+func (c *Client) ARPTable() ([]ARPEntry, error) {
+	pdus, err := c.Walk(oidIPNetToMediaPhysAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	// ipNetToMediaType invalid(2) marks entries that are being flushed
+	invalid := make(map[string]bool)
+	if types, err := c.Walk(oidIPNetToMediaType); err == nil {
+		for _, pdu := range types {
+			if index, ok := TableIndex(pdu.Name, oidIPNetToMediaType); ok && gosnmp.ToBigInt(pdu.Value).Int64() == 2 {
+				invalid[index] = true
+			}
+		}
+	}
+
+	var entries []ARPEntry
+	for _, pdu := range pdus {
+		index, ok := TableIndex(pdu.Name, oidIPNetToMediaPhysAddress)
+		if !ok || invalid[index] {
+			continue
+		}
+
+		// Index is ifIndex.a.b.c.d
+		parts := strings.SplitN(index, ".", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		ifIndex, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
+		}
+		ip := net.ParseIP(parts[1])
+		mac := FormatMAC(pdu.Value)
+		if ip == nil || ip.To4() == nil || mac == "" || mac == "00:00:00:00:00:00" {
+			continue
+		}
+
+		entries = append(entries, ARPEntry{IfIndex: ifIndex, IP: ip.String(), MAC: mac})
+	}
+
+	return entries, nil
+}
+
+// BridgeFDB returns learned MAC addresses from the Q-BRIDGE-MIB, falling back to
+// the BRIDGE-MIB for switches without VLAN-aware forwarding tables
+func (c *Client) BridgeFDB() ([]FDBEntry, error) {
+	portIfIndex := make(map[int]int)
+	if pdus, err := c.Walk(oidDot1dBasePortIfIndex); err == nil {
+		for _, pdu := range pdus {
+			if index, ok := TableIndex(pdu.Name, oidDot1dBasePortIfIndex); ok {
+				if port, err := strconv.Atoi(index); err == nil {
+					portIfIndex[port] = int(gosnmp.ToBigInt(pdu.Value).Int64())
+				}
+			}
+		}
+	}
+
+	entries, err := c.walkFDB(oidDot1qTpFdbPort, oidDot1qTpFdbStatus, true)
+	if err != nil || len(entries) == 0 {
+		entries, err = c.walkFDB(oidDot1dTpFdbPort, oidDot1dTpFdbStatus, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range entries {
+		if ifIndex, ok := portIfIndex[entries[i].BridgePort]; ok {
+			entries[i].IfIndex = ifIndex
+		}
+	}
+	return entries, nil
+}
+
+func (c *Client) walkFDB(portOID, statusOID string, vlanIndexed bool) ([]FDBEntry, error) {
+	pdus, err := c.Walk(portOID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make(map[string]int64)
+	if statuses, err := c.Walk(statusOID); err == nil {
+		for _, pdu := range statuses {
+			if index, ok := TableIndex(pdu.Name, statusOID); ok {
+				status[index] = gosnmp.ToBigInt(pdu.Value).Int64()
+			}
+		}
+	}
+
+	var entries []FDBEntry
+	for _, pdu := range pdus {
+		index, ok := TableIndex(pdu.Name, portOID)
+		if !ok {
+			continue
+		}
+		// Skip self, management and static entries when the status column is available
+		if s, ok := status[index]; ok && s != fdbStatusLearned {
+			continue
+		}
+
+		parts := strings.Split(index, ".")
+		vlan := 0
+		if vlanIndexed {
+			// Index is fdbId.m1.m2.m3.m4.m5.m6
+			if len(parts) != 7 {
+				continue
+			}
+			vlan, _ = strconv.Atoi(parts[0])
+			parts = parts[1:]
+		}
+
+		mac, ok := macFromOIDParts(parts)
+		if !ok {
+			continue
+		}
+		port := int(gosnmp.ToBigInt(pdu.Value).Int64())
+		if port == 0 {
+			continue
+		}
+
+		entries = append(entries, FDBEntry{MAC: mac, BridgePort: port, VLAN: vlan})
+	}
+
+	return entries, nil
+}
+
+// LLDPNeighbors walks the LLDP-MIB remote systems table
+func (c *Client) LLDPNeighbors() ([]models.SNMPNeighbor, error) {
+	localPorts := make(map[string]string)
+	for _, column := range []string{oidLldpLocPortID, oidLldpLocPortDesc} {
+		pdus, err := c.Walk(column)
+		if err != nil {
+			continue
+		}
+		for _, pdu := range pdus {
+			if index, ok := TableIndex(pdu.Name, column); ok {
+				if value := pduString(pdu); value != "" && localPorts[index] == "" {
+					localPorts[index] = value
+				}
+			}
+		}
+	}
+
+	// Rows are indexed by timeMark.localPortNum.remIndex
+	neighbors := make(map[string]*models.SNMPNeighbor)
+	var order []string
+	columns := []string{oidLldpRemChassisIDSubtype, oidLldpRemChassisID, oidLldpRemPortIDSubtype, oidLldpRemPortID, oidLldpRemPortDesc, oidLldpRemSysName, oidLldpRemSysDesc}
+	chassisSubtype := make(map[string]int64)
+	portSubtype := make(map[string]int64)
+
+	for _, column := range columns {
+		pdus, err := c.Walk(column)
+		if err != nil {
+			return nil, err
+		}
+		for _, pdu := range pdus {
+			index, ok := TableIndex(pdu.Name, column)
+			if !ok || len(strings.Split(index, ".")) != 3 {
+				continue
+			}
+			neighbor, ok := neighbors[index]
+			if !ok {
+				localPort := strings.Split(index, ".")[1]
+				neighbor = &models.SNMPNeighbor{Protocol: models.NeighborProtocolLLDP, LocalPort: localPorts[localPort]}
+				if neighbor.LocalPort == "" {
+					neighbor.LocalPort = localPort
+				}
+				neighbors[index] = neighbor
+				order = append(order, index)
+			}
+
+			switch column {
+			case oidLldpRemChassisIDSubtype:
+				chassisSubtype[index] = gosnmp.ToBigInt(pdu.Value).Int64()
+			case oidLldpRemChassisID:
+				// Subtype columns are walked first, macAddress(4) chassis IDs are raw octets
+				if chassisSubtype[index] == 4 {
+					neighbor.ChassisID = FormatMAC(pdu.Value)
+				} else {
+					neighbor.ChassisID = pduString(pdu)
+				}
+			case oidLldpRemPortIDSubtype:
+				portSubtype[index] = gosnmp.ToBigInt(pdu.Value).Int64()
+			case oidLldpRemPortID:
+				if portSubtype[index] == 3 {
+					neighbor.RemotePort = FormatMAC(pdu.Value)
+				} else {
+					neighbor.RemotePort = pduString(pdu)
+				}
+			case oidLldpRemPortDesc:
+				if neighbor.RemotePort == "" {
+					neighbor.RemotePort = pduString(pdu)
+				}
+			case oidLldpRemSysName:
+				neighbor.RemoteSysName = pduString(pdu)
+			case oidLldpRemSysDesc:
+				neighbor.RemotePlatform = pduString(pdu)
+			}
+		}
+	}
+
+	// Management addresses are encoded in the index: timeMark.localPort.remIndex.subtype.len.addr
+	if pdus, err := c.Walk(oidLldpRemManAddrIfSubtype); err == nil {
+		for _, pdu := range pdus {
+			index, ok := TableIndex(pdu.Name, oidLldpRemManAddrIfSubtype)
+			if !ok {
+				continue
+			}
+			parts := strings.Split(index, ".")
+			if len(parts) != 9 || parts[3] != "1" || parts[4] != "4" {
+				continue
+			}
+			neighbor, ok := neighbors[strings.Join(parts[:3], ".")]
+			if !ok || neighbor.RemoteAddress != "" {
+				continue
+			}
+			if ip := net.ParseIP(strings.Join(parts[5:], ".")); ip != nil {
+				neighbor.RemoteAddress = ip.String()
+			}
+		}
+	}
+
+	result := make([]models.SNMPNeighbor, 0, len(order))
+	for _, index := range order {
+		result = append(result, *neighbors[index])
+	}
+	return result, nil
+}
+
+// CDPNeighbors walks the Cisco CDP cache table
+func (c *Client) CDPNeighbors() ([]models.SNMPNeighbor, error) {
+	// Rows are indexed by ifIndex.deviceIndex
+	neighbors := make(map[string]*models.SNMPNeighbor)
+	var order []string
+
+	for _, column := range []string{oidCdpCacheAddress, oidCdpCacheDeviceID, oidCdpCacheDevicePort, oidCdpCachePlatform} {
+		pdus, err := c.Walk(column)
+		if err != nil {
+			return nil, err
+		}
+		for _, pdu := range pdus {
+			index, ok := TableIndex(pdu.Name, column)
+			if !ok {
+				continue
+			}
+			parts := strings.Split(index, ".")
+			if len(parts) != 2 {
+				continue
+			}
+			neighbor, ok := neighbors[index]
+			if !ok {
+				ifIndex, _ := strconv.Atoi(parts[0])
+				neighbor = &models.SNMPNeighbor{Protocol: models.NeighborProtocolCDP, LocalIfIndex: ifIndex}
+				neighbors[index] = neighbor
+				order = append(order, index)
+			}
+
+			switch column {
+			case oidCdpCacheAddress:
+				if b, ok := pdu.Value.([]byte); ok && len(b) == 4 {
+					neighbor.RemoteAddress = net.IP(b).String()
+				}
+			case oidCdpCacheDeviceID:
+				neighbor.RemoteSysName = pduString(pdu)
+			case oidCdpCacheDevicePort:
+				neighbor.RemotePort = pduString(pdu)
+			case oidCdpCachePlatform:
+				neighbor.RemotePlatform = pduString(pdu)
+			}
+		}
+	}
+
+	result := make([]models.SNMPNeighbor, 0, len(order))
+	for _, index := range order {
+		result = append(result, *neighbors[index])
+	}
+	return result, nil
+}
+
+// macFromOIDParts decodes a MAC address encoded as six decimal OID sub-identifiers
+func macFromOIDParts(parts []string) (string, bool) {
+	if len(parts) != 6 {
+		return "", false
+	}
+	b := make([]byte, 6)
+	for i, part := range parts {
+		value, err := strconv.Atoi(part)
+		if err != nil || value < 0 || value > 255 {
+			return "", false
+		}
+		b[i] = byte(value)
+	}
+	return FormatMAC(b), true
+}
@@ -0,0 +1,311 @@
+// Package supervisor owns the lifecycle of reconya's background components. It
+// starts them one at a time in order, restarts the ones that fail with backoff and
+// stops them in reverse order when the root context is cancelled.
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"reconya-ai/internal/logging"
+)
+
+var logger = logging.For("supervisor")
+
+type State string
+
+const (
+	StateStarting State = "starting"
+	StateRunning  State = "running"
+	// StateRestarting components failed and wait for their backoff to pass
+	StateRestarting State = "restarting"
+	StateStopping   State = "stopping"
+	StateStopped    State = "stopped"
+)
+
+// Component is a long running part of the backend
+type Component struct {
+	Name string
+	// Run blocks until ctx is cancelled. Returning earlier, with or without an
+	// error, or panicking counts as a failure and the component is restarted.
+	Run func(ctx context.Context) error
+	// Check optionally tells whether a running component can serve, it is called
+	// for every readiness probe
+	Check func(ctx context.Context) error
+}
+
+// ComponentStatus is what /healthz and /readyz report for a component
+type ComponentStatus struct {
+	Name      string    `json:"name"`
+	State     State     `json:"state"`
+	Ready     bool      `json:"ready"`
+	Restarts  int       `json:"restarts"`
+	LastError string    `json:"last_error,omitempty"`
+	Since     time.Time `json:"since"`
+}
+
+type component struct {
+	Component
+	state     State
+	restarts  int
+	lastError string
+	since     time.Time
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+type Supervisor struct {
+	// MinBackoff and MaxBackoff bound the wait before restarting a failed component.
+	// The wait doubles with each failure and resets once a component stayed up
+	// for StableAfter.
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	StableAfter time.Duration
+	// CheckTimeout bounds each readiness check
+	CheckTimeout time.Duration
+	// StartTimeout is how long Start waits for a component to become ready before
+	// starting the next one anyway
+	StartTimeout time.Duration
+	components   []*component
+	started      bool
+	stopping     bool
+	mutex        sync.RWMutex
+}
+
+func NewSupervisor() *Supervisor {
+	return &Supervisor{
+		MinBackoff:   time.Second,
+		MaxBackoff:   time.Minute,
+		StableAfter:  time.Minute,
+		CheckTimeout: 2 * time.Second,
+		StartTimeout: 10 * time.Second,
+	}
+}
+
+// Add registers a component. Components start in the order they were added.
+func (s *Supervisor) Add(c Component) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.components = append(s.components, &component{Component: c, state: StateStopped, since: time.Now()})
+}
+
+// Start starts every component under ctx, waiting for each to become ready before
+// starting the next. Cancelling ctx stops them as well, but Stop should be called
+// to wait for them.
+func (s *Supervisor) Start(ctx context.Context) {
+	s.mutex.Lock()
+	if s.started {
+		s.mutex.Unlock()
+		return
+	}
+	s.started = true
+	components := append([]*component(nil), s.components...)
+	s.mutex.Unlock()
+
+	for _, c := range components {
+		componentCtx, cancel := context.WithCancel(ctx)
+		s.mutex.Lock()
+		c.cancel = cancel
+		c.done = make(chan struct{})
+		s.mutex.Unlock()
+
+		logger.Infof("Starting %s", c.Name)
+		go s.supervise(componentCtx, c)
+		s.waitReady(ctx, c)
+	}
+}
+
+// waitReady polls a starting component until it is running and passes its check,
+// ctx is cancelled or the start timeout passes
+func (s *Supervisor) waitReady(ctx context.Context, c *component) {
+	deadline := time.NewTimer(s.StartTimeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		s.mutex.RLock()
+		running := c.state == StateRunning
+		s.mutex.RUnlock()
+		if running && s.check(ctx, c) == nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline.C:
+			logger.Warnf("%s is not ready after %v, starting the next component", c.Name, s.StartTimeout)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop stops the components in reverse order, waiting for each until ctx expires.
+// It returns an error naming the components that did not stop in time.
+func (s *Supervisor) Stop(ctx context.Context) error {
+	s.mutex.Lock()
+	s.stopping = true
+	components := append([]*component(nil), s.components...)
+	s.mutex.Unlock()
+
+	var stuck []string
+	for i := len(components) - 1; i >= 0; i-- {
+		c := components[i]
+		s.mutex.RLock()
+		cancel, done := c.cancel, c.done
+		s.mutex.RUnlock()
+		if cancel == nil {
+			continue
+		}
+
+		logger.Infof("Stopping %s", c.Name)
+		s.setState(c, StateStopping, "")
+		cancel()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			logger.Warnf("%s did not stop before the shutdown deadline", c.Name)
+			stuck = append(stuck, c.Name)
+		}
+	}
+
+	if len(stuck) > 0 {
+		return fmt.Errorf("components did not stop in time: %v", stuck)
+	}
+	return nil
+}
+
+// supervise runs a component until its context is cancelled, restarting it with
+// backoff when it fails
+func (s *Supervisor) supervise(ctx context.Context, c *component) {
+	defer close(c.done)
+	defer s.setState(c, StateStopped, "")
+
+	backoff := s.MinBackoff
+	for {
+		s.setState(c, StateRunning, "")
+		startedAt := time.Now()
+		err := s.runOnce(ctx, c)
+		if ctx.Err() != nil {
+			if err != nil {
+				logger.Errorf("%s stopped with error: %v", c.Name, err)
+			} else {
+				logger.Infof("%s stopped", c.Name)
+			}
+			return
+		}
+
+		if err == nil {
+			err = errors.New("exited unexpectedly")
+		}
+		if time.Since(startedAt) >= s.StableAfter {
+			backoff = s.MinBackoff
+		}
+		logger.Errorf("%s failed, restarting in %v: %v", c.Name, backoff, err)
+		s.setState(c, StateRestarting, err.Error())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		s.mutex.Lock()
+		c.restarts++
+		s.mutex.Unlock()
+
+		backoff *= 2
+		if backoff > s.MaxBackoff {
+			backoff = s.MaxBackoff
+		}
+	}
+}
+
+func (s *Supervisor) runOnce(ctx context.Context, c *component) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.With("stack", string(debug.Stack())).Errorf("%s panic: %v", c.Name, r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return c.Run(ctx)
+}
+
+func (s *Supervisor) setState(c *component, state State, lastError string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if c.state == StateStopping && state == StateRunning {
+		return
+	}
+	c.state = state
+	c.since = time.Now()
+	if lastError != "" {
+		c.lastError = lastError
+	}
+}
+
+// Healthy tells whether the supervisor is running. Failed components do not make
+// it unhealthy, the supervisor restarts them itself.
+func (s *Supervisor) Healthy() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.started && !s.stopping
+}
+
+// Status reports every component, running the readiness checks of running ones
+func (s *Supervisor) Status(ctx context.Context) []ComponentStatus {
+	s.mutex.RLock()
+	components := append([]*component(nil), s.components...)
+	s.mutex.RUnlock()
+
+	statuses := make([]ComponentStatus, 0, len(components))
+	for _, c := range components {
+		s.mutex.RLock()
+		status := ComponentStatus{
+			Name:      c.Name,
+			State:     c.state,
+			Restarts:  c.restarts,
+			LastError: c.lastError,
+			Since:     c.since,
+		}
+		s.mutex.RUnlock()
+
+		status.Ready = status.State == StateRunning
+		if status.Ready {
+			if err := s.check(ctx, c); err != nil {
+				status.Ready = false
+				status.LastError = err.Error()
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// check runs the readiness check of a component, if it has one
+func (s *Supervisor) check(ctx context.Context, c *component) error {
+	if c.Check == nil {
+		return nil
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, s.CheckTimeout)
+	defer cancel()
+	return c.Check(checkCtx)
+}
+
+// Ready tells whether every component is running and passes its check
+func (s *Supervisor) Ready(ctx context.Context) (bool, []ComponentStatus) {
+	statuses := s.Status(ctx)
+	if !s.Healthy() {
+		return false, statuses
+	}
+	for _, status := range statuses {
+		if !status.Ready {
+			return false, statuses
+		}
+	}
+	return true, statuses
+}
@@ -0,0 +1,159 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSupervisor() *Supervisor {
+	s := NewSupervisor()
+	s.MinBackoff = time.Millisecond
+	s.MaxBackoff = 5 * time.Millisecond
+	s.StartTimeout = 50 * time.Millisecond
+	return s
+}
+
+func waitUntil(t *testing.T, condition func() bool) {
+	t.Helper()
+	require.Eventually(t, condition, 2*time.Second, time.Millisecond)
+}
+
+func statusOf(s *Supervisor, name string) ComponentStatus {
+	for _, status := range s.Status(context.Background()) {
+		if status.Name == name {
+			return status
+		}
+	}
+	return ComponentStatus{}
+}
+
+func TestFailedComponentsAreRestarted(t *testing.T) {
+	s := newTestSupervisor()
+	var mutex sync.Mutex
+	runs := 0
+	s.Add(Component{Name: "flaky", Run: func(ctx context.Context) error {
+		mutex.Lock()
+		runs++
+		run := runs
+		mutex.Unlock()
+		switch run {
+		case 1:
+			return errors.New("boom")
+		case 2:
+			panic("worse")
+		}
+		<-ctx.Done()
+		return nil
+	}})
+
+	s.Start(context.Background())
+	waitUntil(t, func() bool {
+		status := statusOf(s, "flaky")
+		return status.State == StateRunning && status.Restarts == 2
+	})
+	assert.Equal(t, "panic: worse", statusOf(s, "flaky").LastError)
+
+	ready, _ := s.Ready(context.Background())
+	assert.True(t, ready)
+	require.NoError(t, s.Stop(context.Background()))
+	assert.Equal(t, StateStopped, statusOf(s, "flaky").State)
+}
+
+func TestComponentsStopInReverseOrder(t *testing.T) {
+	s := newTestSupervisor()
+	var mutex sync.Mutex
+	var stopped []string
+	for _, name := range []string{"database", "scanner", "http"} {
+		name := name
+		s.Add(Component{Name: name, Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			mutex.Lock()
+			stopped = append(stopped, name)
+			mutex.Unlock()
+			return nil
+		}})
+	}
+
+	s.Start(context.Background())
+	waitUntil(t, func() bool { ready, _ := s.Ready(context.Background()); return ready })
+	require.NoError(t, s.Stop(context.Background()))
+	assert.Equal(t, []string{"http", "scanner", "database"}, stopped)
+	assert.False(t, s.Healthy())
+}
+
+func TestComponentsStartOnceThePreviousIsReady(t *testing.T) {
+	s := newTestSupervisor()
+	s.StartTimeout = 2 * time.Second
+	var databaseUp atomic.Bool
+	var startedBeforeDatabase atomic.Bool
+	s.Add(Component{
+		Name: "database",
+		Run: func(ctx context.Context) error {
+			time.Sleep(20 * time.Millisecond)
+			databaseUp.Store(true)
+			<-ctx.Done()
+			return nil
+		},
+		Check: func(ctx context.Context) error {
+			if !databaseUp.Load() {
+				return errors.New("not open yet")
+			}
+			return nil
+		},
+	})
+	s.Add(Component{Name: "scanner", Run: func(ctx context.Context) error {
+		startedBeforeDatabase.Store(!databaseUp.Load())
+		<-ctx.Done()
+		return nil
+	}})
+
+	s.Start(context.Background())
+	defer s.Stop(context.Background())
+	assert.Equal(t, StateRunning, statusOf(s, "scanner").State)
+	assert.False(t, startedBeforeDatabase.Load())
+}
+
+func TestFailingCheckMakesTheSupervisorNotReady(t *testing.T) {
+	s := newTestSupervisor()
+	s.Add(Component{
+		Name:  "database",
+		Run:   func(ctx context.Context) error { <-ctx.Done(); return nil },
+		Check: func(ctx context.Context) error { return errors.New("database is locked") },
+	})
+
+	s.Start(context.Background())
+	defer s.Stop(context.Background())
+	waitUntil(t, func() bool { return statusOf(s, "database").State == StateRunning })
+
+	ready, statuses := s.Ready(context.Background())
+	assert.False(t, ready)
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "database is locked", statuses[0].LastError)
+	assert.True(t, s.Healthy())
+}
+
+func TestStopGivesUpAtTheDeadline(t *testing.T) {
+	s := newTestSupervisor()
+	release := make(chan struct{})
+	defer close(release)
+	s.Add(Component{Name: "stuck", Run: func(ctx context.Context) error {
+		<-release
+		return nil
+	}})
+
+	s.Start(context.Background())
+	waitUntil(t, func() bool { return statusOf(s, "stuck").State == StateRunning })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := s.Stop(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "stuck")
+}
@@ -4,15 +4,21 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"reconya-ai/db"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/metrics"
 	"reconya-ai/models"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+var logger = logging.For("systemstatus")
+
+var geolocationLookups = metrics.NewCounterVec("reconya_geolocation_cache_lookups_total",
+	"Geolocation cache lookups by result", "result")
+
 type SystemStatusService struct {
 	repository    db.SystemStatusRepository
 	geoRepository *db.GeolocationRepository
@@ -58,21 +64,23 @@ func (s *SystemStatusService) FetchGeolocation(publicIP string) (*models.Geoloca
 	// Try to get from cache first
 	geo, err := s.geoRepository.FindByIP(context.Background(), publicIP)
 	if err == nil && geo != nil {
-		log.Printf("Geolocation cache hit for IP %s", publicIP)
+		geolocationLookups.Inc("hit")
+		logger.Debugf("Geolocation cache hit for IP %s", publicIP)
 		return geo, nil
 	}
 
 	// If not in cache, fetch from API
-	log.Printf("Geolocation cache miss for IP %s, fetching from API", publicIP)
+	geolocationLookups.Inc("miss")
+	logger.Debugf("Geolocation cache miss for IP %s, fetching from API", publicIP)
 	geo, err = s.fetchFromAPI(publicIP)
 	if err != nil {
-		log.Printf("Failed to fetch geolocation from API for IP %s: %v", publicIP, err)
+		logger.Errorf("Failed to fetch geolocation from API for IP %s: %v", publicIP, err)
 		return nil, err
 	}
 
 	// Save to cache
 	if err := s.geoRepository.Create(context.Background(), geo); err != nil {
-		log.Printf("Failed to cache geolocation for IP %s: %v", publicIP, err)
+		logger.Errorf("Failed to cache geolocation for IP %s: %v", publicIP, err)
 	}
 
 	return geo, nil
@@ -133,6 +141,6 @@ func (s *SystemStatusService) fetchFromAPI(publicIP string) (*models.Geolocation
 		ExpiresAt:   now.Add(30 * 24 * time.Hour), // Cache for 30 days
 	}
 
-	log.Printf("Fetched geolocation for IP %s: %s, %s, %s", publicIP, geo.City, geo.Region, geo.Country)
+	logger.Infof("Fetched geolocation for IP %s: %s, %s, %s", publicIP, geo.City, geo.Region, geo.Country)
 	return geo, nil
 }
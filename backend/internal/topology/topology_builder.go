@@ -0,0 +1,423 @@
+package topology
+
+import (
+	"strings"
+	"time"
+
+	"reconya-ai/models"
+)
+
+// ifTypeIEEE80211 is the IANAifType of wireless interfaces
+const ifTypeIEEE80211 = 71
+
+// BuildInput is everything the topology is inferred from
+type BuildInput struct {
+	Devices []*models.Device
+	// Gateways maps network IDs to the device ID of the network's default gateway
+	Gateways map[string]string
+	// Routes are traceroute paths from the sensor, as lists of hop addresses.
+	// Unanswered hops are empty strings.
+	Routes [][]string
+}
+
+type builder struct {
+	nodes     map[string]*models.TopologyNode
+	nodeOrder []string
+	links     map[string]*models.TopologyLink
+	linkOrder []string
+
+	devices map[string]*models.Device
+	byIP    map[string]*models.Device
+	byMAC   map[string]*models.Device
+	byName  map[string]*models.Device
+}
+
+// BuildTopology infers nodes and links from LLDP/CDP neighbors, switch port
+// assignments, default gateways and traceroute paths
+func BuildTopology(input BuildInput) *models.Topology {
+	b := &builder{
+		nodes:   make(map[string]*models.TopologyNode),
+		links:   make(map[string]*models.TopologyLink),
+		devices: make(map[string]*models.Device),
+		byIP:    make(map[string]*models.Device),
+		byMAC:   make(map[string]*models.Device),
+		byName:  make(map[string]*models.Device),
+	}
+
+	for _, d := range input.Devices {
+		b.index(d)
+	}
+	for _, d := range input.Devices {
+		b.addDevice(d)
+	}
+
+	for _, d := range input.Devices {
+		b.addNeighborLinks(d)
+	}
+	for _, d := range input.Devices {
+		b.addSwitchPortLink(d)
+	}
+
+	b.addGatewayLinks(input.Devices, input.Gateways)
+
+	for _, route := range input.Routes {
+		b.addRoute(route)
+	}
+
+	topology := &models.Topology{
+		Nodes:       make([]models.TopologyNode, 0, len(b.nodeOrder)),
+		Links:       make([]models.TopologyLink, 0, len(b.linkOrder)),
+		GeneratedAt: time.Now(),
+	}
+	for _, id := range b.nodeOrder {
+		topology.Nodes = append(topology.Nodes, *b.nodes[id])
+	}
+	for _, key := range b.linkOrder {
+		topology.Links = append(topology.Links, *b.links[key])
+	}
+	return topology
+}
+
+func (b *builder) index(d *models.Device) {
+	b.devices[d.ID] = d
+	if d.IPv4 != "" {
+		b.byIP[d.IPv4] = d
+	}
+	if d.MAC != nil && *d.MAC != "" {
+		b.byMAC[strings.ToUpper(*d.MAC)] = d
+	}
+	if d.Hostname != nil && *d.Hostname != "" {
+		b.byName[strings.ToLower(*d.Hostname)] = d
+	}
+	if d.SNMP != nil {
+		if d.SNMP.SysName != "" {
+			b.byName[strings.ToLower(d.SNMP.SysName)] = d
+		}
+		// Chassis IDs advertised over LLDP are usually one of the interface MACs
+		for _, iface := range d.SNMP.Interfaces {
+			if iface.MAC != "" {
+				if _, exists := b.byMAC[iface.MAC]; !exists {
+					b.byMAC[iface.MAC] = d
+				}
+			}
+		}
+	}
+}
+
+func (b *builder) addDevice(d *models.Device) string {
+	id := d.ID
+	if _, ok := b.nodes[id]; ok {
+		return id
+	}
+
+	node := &models.TopologyNode{
+		ID:         id,
+		Kind:       models.TopologyNodeDevice,
+		Label:      deviceLabel(d),
+		DeviceID:   d.ID,
+		IPv4:       d.IPv4,
+		DeviceType: d.DeviceType,
+		Status:     d.Status,
+		NetworkID:  d.NetworkID,
+	}
+	if d.MAC != nil {
+		node.MAC = *d.MAC
+	}
+	b.addNode(node)
+	return id
+}
+
+func (b *builder) addNode(node *models.TopologyNode) {
+	if _, ok := b.nodes[node.ID]; ok {
+		return
+	}
+	b.nodes[node.ID] = node
+	b.nodeOrder = append(b.nodeOrder, node.ID)
+}
+
+// addLink adds an undirected link, merging port information with a link already
+// reported from the other end
+func (b *builder) addLink(link models.TopologyLink) {
+	if link.Source == link.Target {
+		return
+	}
+
+	key := link.Source + "|" + link.Target
+	reverse := link.Target + "|" + link.Source
+	if existing, ok := b.links[key]; ok {
+		if existing.SourcePort == "" {
+			existing.SourcePort = link.SourcePort
+		}
+		if existing.TargetPort == "" {
+			existing.TargetPort = link.TargetPort
+		}
+		return
+	}
+	if existing, ok := b.links[reverse]; ok {
+		if existing.SourcePort == "" {
+			existing.SourcePort = link.TargetPort
+		}
+		if existing.TargetPort == "" {
+			existing.TargetPort = link.SourcePort
+		}
+		return
+	}
+
+	b.links[key] = &link
+	b.linkOrder = append(b.linkOrder, key)
+}
+
+func (b *builder) hasL2Link(id string) bool {
+	for _, link := range b.links {
+		if link.Type != models.TopologyLinkL3 && (link.Source == id || link.Target == id) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *builder) addNeighborLinks(d *models.Device) {
+	if d.SNMP == nil {
+		return
+	}
+
+	for _, neighbor := range d.SNMP.Neighbors {
+		targetID := b.resolveNeighbor(neighbor)
+		if targetID == "" {
+			continue
+		}
+
+		evidence := models.TopologyEvidenceLLDP
+		if neighbor.Protocol == models.NeighborProtocolCDP {
+			evidence = models.TopologyEvidenceCDP
+		}
+
+		localPort := neighbor.LocalPort
+		iface := findInterface(d, neighbor.LocalIfIndex, neighbor.LocalPort)
+		if localPort == "" && iface != nil {
+			localPort = interfaceName(*iface)
+		}
+
+		linkType := models.TopologyLinkL2
+		if iface != nil && iface.Type == ifTypeIEEE80211 {
+			linkType = models.TopologyLinkWireless
+		}
+
+		b.addLink(models.TopologyLink{
+			Source:     d.ID,
+			Target:     targetID,
+			Type:       linkType,
+			Evidence:   evidence,
+			SourcePort: localPort,
+			TargetPort: neighbor.RemotePort,
+		})
+	}
+}
+
+// resolveNeighbor maps an LLDP/CDP neighbor to a known device or creates a neighbor node
+func (b *builder) resolveNeighbor(neighbor models.SNMPNeighbor) string {
+	if d, ok := b.byIP[neighbor.RemoteAddress]; ok && neighbor.RemoteAddress != "" {
+		return d.ID
+	}
+	if d, ok := b.byMAC[strings.ToUpper(neighbor.ChassisID)]; ok && neighbor.ChassisID != "" {
+		return d.ID
+	}
+	if d, ok := b.byName[strings.ToLower(neighbor.RemoteSysName)]; ok && neighbor.RemoteSysName != "" {
+		return d.ID
+	}
+
+	key := neighbor.ChassisID
+	if key == "" {
+		key = neighbor.RemoteSysName
+	}
+	if key == "" {
+		key = neighbor.RemoteAddress
+	}
+	if key == "" {
+		return ""
+	}
+
+	label := neighbor.RemoteSysName
+	if label == "" {
+		label = key
+	}
+
+	node := &models.TopologyNode{
+		ID:    "neighbor:" + key,
+		Kind:  models.TopologyNodeNeighbor,
+		Label: label,
+		IPv4:  neighbor.RemoteAddress,
+	}
+	if neighbor.Protocol == models.NeighborProtocolLLDP && len(neighbor.ChassisID) == 17 {
+		node.MAC = neighbor.ChassisID
+	}
+	b.addNode(node)
+	return node.ID
+}
+
+func (b *builder) addSwitchPortLink(d *models.Device) {
+	if d.SwitchPort == nil {
+		return
+	}
+	sw, ok := b.devices[d.SwitchPort.SwitchDeviceID]
+	if !ok || sw.ID == d.ID {
+		return
+	}
+
+	evidence := models.TopologyEvidenceFDB
+	switch d.SwitchPort.Source {
+	case models.SwitchPortSourceLLDP:
+		evidence = models.TopologyEvidenceLLDP
+	case models.SwitchPortSourceCDP:
+		evidence = models.TopologyEvidenceCDP
+	}
+
+	linkType := models.TopologyLinkL2
+	if iface := findInterface(sw, d.SwitchPort.IfIndex, d.SwitchPort.Port); iface != nil && iface.Type == ifTypeIEEE80211 {
+		linkType = models.TopologyLinkWireless
+	} else if sw.DeviceType == models.DeviceTypeAccessPoint && isWirelessPortName(d.SwitchPort.Port) {
+		linkType = models.TopologyLinkWireless
+	}
+
+	b.addLink(models.TopologyLink{
+		Source:     sw.ID,
+		Target:     d.ID,
+		Type:       linkType,
+		Evidence:   evidence,
+		SourcePort: d.SwitchPort.Port,
+		VLAN:       d.SwitchPort.VLAN,
+	})
+}
+
+// addGatewayLinks connects devices without any known layer 2 attachment to their network's gateway
+func (b *builder) addGatewayLinks(devices []*models.Device, gateways map[string]string) {
+	for _, gatewayID := range gateways {
+		if node, ok := b.nodes[gatewayID]; ok {
+			node.Gateway = true
+		}
+	}
+
+	for _, d := range devices {
+		gatewayID, ok := gateways[d.NetworkID]
+		if !ok || gatewayID == d.ID {
+			continue
+		}
+		if _, ok := b.nodes[gatewayID]; !ok || b.hasL2Link(d.ID) {
+			continue
+		}
+		b.addLink(models.TopologyLink{
+			Source:   gatewayID,
+			Target:   d.ID,
+			Type:     models.TopologyLinkL3,
+			Evidence: models.TopologyEvidenceGateway,
+		})
+	}
+}
+
+func (b *builder) addRoute(hops []string) {
+	previous := ""
+	for _, hop := range hops {
+		if hop == "" {
+			// An unanswered hop breaks the chain, the routers on either side are not adjacent
+			previous = ""
+			continue
+		}
+
+		id := b.hopNode(hop)
+		if previous != "" {
+			b.addLink(models.TopologyLink{
+				Source:   previous,
+				Target:   id,
+				Type:     models.TopologyLinkL3,
+				Evidence: models.TopologyEvidenceTraceroute,
+			})
+		}
+		previous = id
+	}
+}
+
+func (b *builder) hopNode(ip string) string {
+	if d, ok := b.byIP[ip]; ok {
+		return d.ID
+	}
+	node := &models.TopologyNode{
+		ID:    "hop:" + ip,
+		Kind:  models.TopologyNodeHop,
+		Label: ip,
+		IPv4:  ip,
+	}
+	b.addNode(node)
+	return node.ID
+}
+
+// FilterNetwork keeps the nodes of a network plus everything directly linked to them
+func FilterNetwork(topology *models.Topology, networkID string) *models.Topology {
+	keep := make(map[string]bool)
+	for _, node := range topology.Nodes {
+		if node.NetworkID == networkID {
+			keep[node.ID] = true
+		}
+	}
+
+	filtered := &models.Topology{GeneratedAt: topology.GeneratedAt, Nodes: []models.TopologyNode{}, Links: []models.TopologyLink{}}
+	linked := make(map[string]bool)
+	for _, link := range topology.Links {
+		if keep[link.Source] || keep[link.Target] {
+			filtered.Links = append(filtered.Links, link)
+			linked[link.Source] = true
+			linked[link.Target] = true
+		}
+	}
+	for _, node := range topology.Nodes {
+		if keep[node.ID] || linked[node.ID] {
+			filtered.Nodes = append(filtered.Nodes, node)
+		}
+	}
+	return filtered
+}
+
+func findInterface(d *models.Device, ifIndex int, name string) *models.SNMPInterface {
+	if d.SNMP == nil {
+		return nil
+	}
+	for i := range d.SNMP.Interfaces {
+		iface := &d.SNMP.Interfaces[i]
+		if ifIndex != 0 && iface.Index == ifIndex {
+			return iface
+		}
+		if ifIndex == 0 && name != "" && (strings.EqualFold(iface.Name, name) || strings.EqualFold(iface.Descr, name)) {
+			return iface
+		}
+	}
+	return nil
+}
+
+func interfaceName(iface models.SNMPInterface) string {
+	if iface.Name != "" {
+		return iface.Name
+	}
+	return iface.Descr
+}
+
+func isWirelessPortName(name string) bool {
+	name = strings.ToLower(name)
+	for _, prefix := range []string{"wlan", "wifi", "ath", "radio", "wl"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func deviceLabel(d *models.Device) string {
+	if d.Name != "" {
+		return d.Name
+	}
+	if d.Hostname != nil && *d.Hostname != "" {
+		return *d.Hostname
+	}
+	if d.SNMP != nil && d.SNMP.SysName != "" {
+		return d.SNMP.SysName
+	}
+	return d.IPv4
+}
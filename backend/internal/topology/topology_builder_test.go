@@ -0,0 +1,171 @@
+package topology
+
+import (
+	"strings"
+	"testing"
+
+	"reconya-ai/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func strPtr(s string) *string { return &s }
+
+func testDevices() []*models.Device {
+	return []*models.Device{
+		{
+			ID: "router", IPv4: "10.0.0.1", NetworkID: "lan", DeviceType: models.DeviceTypeRouter,
+			Status: models.DeviceStatusOnline,
+		},
+		{
+			ID: "core-sw", IPv4: "10.0.0.2", NetworkID: "lan", DeviceType: models.DeviceTypeSwitch,
+			SNMP: &models.SNMPInfo{
+				SysName: "core-sw",
+				Interfaces: []models.SNMPInterface{
+					{Index: 1, Name: "Gi0/1", MAC: "00:00:00:00:02:01"},
+					{Index: 2, Name: "Gi0/2"},
+				},
+				Neighbors: []models.SNMPNeighbor{
+					{Protocol: models.NeighborProtocolLLDP, LocalIfIndex: 2, RemotePort: "eth0", RemoteAddress: "10.0.0.3"},
+					{Protocol: models.NeighborProtocolCDP, LocalIfIndex: 1, RemotePort: "Fa0/1", RemoteSysName: "unknown-phone"},
+				},
+			},
+		},
+		{
+			ID: "ap", IPv4: "10.0.0.3", NetworkID: "lan", DeviceType: models.DeviceTypeAccessPoint,
+			SNMP: &models.SNMPInfo{
+				SysName: "ap",
+				Interfaces: []models.SNMPInterface{
+					{Index: 1, Name: "eth0"},
+					{Index: 10, Name: "wlan0", Type: ifTypeIEEE80211},
+				},
+				// Reported from the other end as well, must not produce a second link
+				Neighbors: []models.SNMPNeighbor{
+					{Protocol: models.NeighborProtocolLLDP, LocalIfIndex: 1, RemotePort: "Gi0/2", ChassisID: "00:00:00:00:02:01"},
+				},
+			},
+		},
+		{
+			ID: "nas", IPv4: "10.0.0.20", NetworkID: "lan", MAC: strPtr("AA:00:00:00:00:20"),
+			SwitchPort: &models.SwitchPortInfo{SwitchDeviceID: "core-sw", IfIndex: 1, Port: "Gi0/1", VLAN: 10, Source: models.SwitchPortSourceFDB},
+		},
+		{
+			ID: "laptop", IPv4: "10.0.0.30", NetworkID: "lan",
+			SwitchPort: &models.SwitchPortInfo{SwitchDeviceID: "ap", IfIndex: 10, Port: "wlan0", Source: models.SwitchPortSourceFDB},
+		},
+		{ID: "printer", IPv4: "10.0.0.40", NetworkID: "lan"},
+		{ID: "remote", IPv4: "10.20.0.5", NetworkID: "branch"},
+	}
+}
+
+func findLink(topology *models.Topology, a, b string) *models.TopologyLink {
+	for i, link := range topology.Links {
+		if (link.Source == a && link.Target == b) || (link.Source == b && link.Target == a) {
+			return &topology.Links[i]
+		}
+	}
+	return nil
+}
+
+func TestBuildTopology(t *testing.T) {
+	topology := BuildTopology(BuildInput{
+		Devices:  testDevices(),
+		Gateways: map[string]string{"lan": "router"},
+		Routes:   [][]string{{"10.0.0.1", "", "172.16.0.1", "10.20.0.5"}},
+	})
+
+	t.Run("NeighborLinksAreMergedFromBothEnds", func(t *testing.T) {
+		link := findLink(topology, "core-sw", "ap")
+		require.NotNil(t, link)
+		assert.Equal(t, models.TopologyLinkL2, link.Type)
+		assert.Equal(t, models.TopologyEvidenceLLDP, link.Evidence)
+		assert.Equal(t, "core-sw", link.Source)
+		assert.Equal(t, "Gi0/2", link.SourcePort)
+		assert.Equal(t, "eth0", link.TargetPort)
+
+		count := 0
+		for _, l := range topology.Links {
+			if (l.Source == "core-sw" && l.Target == "ap") || (l.Source == "ap" && l.Target == "core-sw") {
+				count++
+			}
+		}
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("UnknownNeighborBecomesNode", func(t *testing.T) {
+		link := findLink(topology, "core-sw", "neighbor:unknown-phone")
+		require.NotNil(t, link)
+		assert.Equal(t, models.TopologyEvidenceCDP, link.Evidence)
+		assert.Equal(t, "Gi0/1", link.SourcePort)
+	})
+
+	t.Run("SwitchPortLinks", func(t *testing.T) {
+		link := findLink(topology, "core-sw", "nas")
+		require.NotNil(t, link)
+		assert.Equal(t, models.TopologyLinkL2, link.Type)
+		assert.Equal(t, models.TopologyEvidenceFDB, link.Evidence)
+		assert.Equal(t, 10, link.VLAN)
+
+		wireless := findLink(topology, "ap", "laptop")
+		require.NotNil(t, wireless)
+		assert.Equal(t, models.TopologyLinkWireless, wireless.Type)
+	})
+
+	t.Run("GatewayLinksOnlyForUnattachedDevices", func(t *testing.T) {
+		link := findLink(topology, "router", "printer")
+		require.NotNil(t, link)
+		assert.Equal(t, models.TopologyLinkL3, link.Type)
+		assert.Equal(t, models.TopologyEvidenceGateway, link.Evidence)
+
+		assert.Nil(t, findLink(topology, "router", "nas"))
+		assert.Nil(t, findLink(topology, "router", "remote"))
+
+		for _, node := range topology.Nodes {
+			assert.Equal(t, node.ID == "router", node.Gateway, node.ID)
+		}
+	})
+
+	t.Run("TracerouteHops", func(t *testing.T) {
+		// The unanswered second hop breaks the chain
+		assert.Nil(t, findLink(topology, "router", "hop:172.16.0.1"))
+
+		link := findLink(topology, "hop:172.16.0.1", "remote")
+		require.NotNil(t, link)
+		assert.Equal(t, models.TopologyEvidenceTraceroute, link.Evidence)
+	})
+
+	t.Run("FilterNetwork", func(t *testing.T) {
+		branch := FilterNetwork(topology, "branch")
+		ids := []string{}
+		for _, node := range branch.Nodes {
+			ids = append(ids, node.ID)
+		}
+		assert.ElementsMatch(t, []string{"remote", "hop:172.16.0.1"}, ids)
+		assert.Len(t, branch.Links, 1)
+	})
+}
+
+func TestExports(t *testing.T) {
+	topology := BuildTopology(BuildInput{
+		Devices:  testDevices(),
+		Gateways: map[string]string{"lan": "router"},
+	})
+
+	graphml, err := ToGraphML(topology)
+	require.NoError(t, err)
+	output := string(graphml)
+	assert.True(t, strings.HasPrefix(output, "<?xml"))
+	assert.Contains(t, output, `<graph id="reconya" edgedefault="undirected">`)
+	assert.Contains(t, output, `<node id="core-sw">`)
+	assert.Contains(t, output, `<edge id="e0" source="core-sw" target="ap">`)
+	assert.Contains(t, output, `<data key="type">wireless</data>`)
+
+	dot := ToDOT(topology)
+	assert.True(t, strings.HasPrefix(dot, "graph topology {"))
+	assert.Contains(t, dot, `"core-sw" [label="core-sw\n10.0.0.2"];`)
+	assert.Contains(t, dot, `"router" [label="10.0.0.1", peripheries=2];`)
+	assert.Contains(t, dot, `"core-sw" -- "ap" [label="Gi0/2 - eth0"];`)
+	assert.Contains(t, dot, `"router" -- "printer" [style=dashed];`)
+	assert.Contains(t, dot, `"ap" -- "laptop" [label="wlan0", style=dotted];`)
+}
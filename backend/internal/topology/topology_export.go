@@ -0,0 +1,175 @@
+package topology
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"reconya-ai/models"
+)
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+var graphMLKeys = []graphMLKey{
+	{ID: "label", For: "node", AttrName: "label", AttrType: "string"},
+	{ID: "kind", For: "node", AttrName: "kind", AttrType: "string"},
+	{ID: "ipv4", For: "node", AttrName: "ipv4", AttrType: "string"},
+	{ID: "mac", For: "node", AttrName: "mac", AttrType: "string"},
+	{ID: "device_type", For: "node", AttrName: "device_type", AttrType: "string"},
+	{ID: "status", For: "node", AttrName: "status", AttrType: "string"},
+	{ID: "gateway", For: "node", AttrName: "gateway", AttrType: "boolean"},
+	{ID: "type", For: "edge", AttrName: "type", AttrType: "string"},
+	{ID: "evidence", For: "edge", AttrName: "evidence", AttrType: "string"},
+	{ID: "source_port", For: "edge", AttrName: "source_port", AttrType: "string"},
+	{ID: "target_port", For: "edge", AttrName: "target_port", AttrType: "string"},
+	{ID: "vlan", For: "edge", AttrName: "vlan", AttrType: "int"},
+}
+
+// ToGraphML renders the topology as an undirected GraphML document
+func ToGraphML(topology *models.Topology) ([]byte, error) {
+	doc := graphML{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys:  graphMLKeys,
+		Graph: graphMLGraph{ID: "reconya", EdgeDefault: "undirected"},
+	}
+
+	for _, node := range topology.Nodes {
+		data := appendData(nil, "label", node.Label)
+		data = appendData(data, "kind", string(node.Kind))
+		data = appendData(data, "ipv4", node.IPv4)
+		data = appendData(data, "mac", node.MAC)
+		data = appendData(data, "device_type", string(node.DeviceType))
+		data = appendData(data, "status", string(node.Status))
+		if node.Gateway {
+			data = appendData(data, "gateway", "true")
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{ID: node.ID, Data: data})
+	}
+
+	for i, link := range topology.Links {
+		data := appendData(nil, "type", string(link.Type))
+		data = appendData(data, "evidence", string(link.Evidence))
+		data = appendData(data, "source_port", link.SourcePort)
+		data = appendData(data, "target_port", link.TargetPort)
+		if link.VLAN != 0 {
+			data = appendData(data, "vlan", strconv.Itoa(link.VLAN))
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			ID:     fmt.Sprintf("e%d", i),
+			Source: link.Source,
+			Target: link.Target,
+			Data:   data,
+		})
+	}
+
+	output, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), output...), nil
+}
+
+func appendData(data []graphMLData, key, value string) []graphMLData {
+	if value == "" {
+		return data
+	}
+	return append(data, graphMLData{Key: key, Value: value})
+}
+
+// ToDOT renders the topology as an undirected Graphviz graph. Routed links are dashed
+// and wireless links dotted.
+func ToDOT(topology *models.Topology) string {
+	var b strings.Builder
+	b.WriteString("graph topology {\n")
+	b.WriteString("  node [shape=box];\n")
+
+	for _, node := range topology.Nodes {
+		label := node.Label
+		if node.IPv4 != "" && node.IPv4 != label {
+			label += "\n" + node.IPv4
+		}
+		attrs := []string{"label=" + dotQuote(label)}
+		switch node.Kind {
+		case models.TopologyNodeNeighbor:
+			attrs = append(attrs, "style=dashed")
+		case models.TopologyNodeHop:
+			attrs = append(attrs, "shape=ellipse")
+		}
+		if node.Gateway {
+			attrs = append(attrs, "peripheries=2")
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", dotQuote(node.ID), strings.Join(attrs, ", "))
+	}
+
+	for _, link := range topology.Links {
+		attrs := []string{}
+		ports := []string{}
+		if link.SourcePort != "" || link.TargetPort != "" {
+			ports = append(ports, strings.Trim(link.SourcePort+" - "+link.TargetPort, " -"))
+		}
+		if link.VLAN != 0 {
+			ports = append(ports, fmt.Sprintf("vlan %d", link.VLAN))
+		}
+		if len(ports) > 0 {
+			attrs = append(attrs, "label="+dotQuote(strings.Join(ports, ", ")))
+		}
+		switch link.Type {
+		case models.TopologyLinkL3:
+			attrs = append(attrs, "style=dashed")
+		case models.TopologyLinkWireless:
+			attrs = append(attrs, "style=dotted")
+		}
+		fmt.Fprintf(&b, "  %s -- %s", dotQuote(link.Source), dotQuote(link.Target))
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, " [%s]", strings.Join(attrs, ", "))
+		}
+		b.WriteString(";\n")
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func dotQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return `"` + value + `"`
+}
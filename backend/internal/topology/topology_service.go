@@ -0,0 +1,174 @@
+package topology
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/network"
+	"reconya-ai/models"
+)
+
+var logger = logging.For("topology")
+
+type TopologyService struct {
+	DeviceService  *device.DeviceService
+	NetworkService *network.NetworkService
+	traceInterval  time.Duration
+	routes         map[string][]string
+	lastTrace      map[string]time.Time
+	mutex          sync.Mutex
+}
+
+func NewTopologyService(deviceService *device.DeviceService, networkService *network.NetworkService) *TopologyService {
+	return &TopologyService{
+		DeviceService:  deviceService,
+		NetworkService: networkService,
+		traceInterval:  30 * time.Minute,
+		routes:         make(map[string][]string),
+		lastTrace:      make(map[string]time.Time),
+	}
+}
+
+// Build returns the topology of all networks, or of a single network and its
+// directly attached infrastructure when networkID is set
+func (s *TopologyService) Build(networkID string) (*models.Topology, error) {
+	devices, err := s.DeviceService.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	networks, err := s.NetworkService.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	routes := make([][]string, 0, len(s.routes))
+	for _, route := range s.routes {
+		routes = append(routes, route)
+	}
+	s.mutex.Unlock()
+
+	topology := BuildTopology(BuildInput{
+		Devices:  devices,
+		Gateways: s.findGateways(networks, devices),
+		Routes:   routes,
+	})
+
+	if networkID != "" {
+		topology = FilterNetwork(topology, networkID)
+	}
+	return topology, nil
+}
+
+// RefreshRoutes traces the path from the sensor to the network's gateway so routed
+// hops show up in the topology. Tracing is throttled per network.
+func (s *TopologyService) RefreshRoutes(network *models.Network) {
+	if network == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	if last, ok := s.lastTrace[network.ID]; ok && time.Since(last) < s.traceInterval {
+		s.mutex.Unlock()
+		return
+	}
+	s.lastTrace[network.ID] = time.Now()
+	s.mutex.Unlock()
+
+	devices, err := s.DeviceService.FindByNetworkID(network.ID)
+	if err != nil {
+		logger.Errorf("Error loading devices for traceroute to %s: %v", network.CIDR, err)
+		return
+	}
+	devicePointers := make([]*models.Device, len(devices))
+	for i := range devices {
+		devicePointers[i] = &devices[i]
+	}
+
+	target := ""
+	if gateway := findGateway(network, devicePointers, DefaultGateway()); gateway != nil {
+		target = gateway.IPv4
+	} else {
+		for _, d := range devices {
+			if d.Status == models.DeviceStatusOnline {
+				target = d.IPv4
+				break
+			}
+		}
+	}
+	if target == "" {
+		return
+	}
+
+	hops, err := Traceroute(target)
+	if err != nil {
+		logger.Errorf("Traceroute to %s failed: %v", target, err)
+		return
+	}
+
+	s.mutex.Lock()
+	s.routes[network.ID] = hops
+	s.mutex.Unlock()
+	logger.Infof("Traceroute to %s for network %s: %d hops", target, network.CIDR, len(hops))
+}
+
+func (s *TopologyService) findGateways(networks []models.Network, devices []*models.Device) map[string]string {
+	byNetwork := make(map[string][]*models.Device)
+	for _, d := range devices {
+		byNetwork[d.NetworkID] = append(byNetwork[d.NetworkID], d)
+	}
+
+	defaultGateway := DefaultGateway()
+	gateways := make(map[string]string)
+	for i := range networks {
+		if gateway := findGateway(&networks[i], byNetwork[networks[i].ID], defaultGateway); gateway != nil {
+			gateways[networks[i].ID] = gateway.ID
+		}
+	}
+	return gateways
+}
+
+// findGateway picks a network's gateway: the sensor's default gateway when it is inside
+// the network, then a device announcing itself as an internet gateway, then a router or
+// firewall, then whatever holds the first address of the subnet
+func findGateway(network *models.Network, devices []*models.Device, defaultGateway string) *models.Device {
+	_, ipNet, err := net.ParseCIDR(network.CIDR)
+	if err != nil {
+		return nil
+	}
+
+	if ip := net.ParseIP(defaultGateway); ip != nil && ipNet.Contains(ip) {
+		for _, d := range devices {
+			if d.IPv4 == defaultGateway {
+				return d
+			}
+		}
+	}
+
+	for _, d := range devices {
+		if d.UPnP != nil && d.UPnP.IsInternetGateway() {
+			return d
+		}
+	}
+
+	for _, d := range devices {
+		if d.DeviceType == models.DeviceTypeRouter || d.DeviceType == models.DeviceTypeFirewall {
+			return d
+		}
+	}
+
+	first := ipNet.IP.To4()
+	if first == nil {
+		return nil
+	}
+	firstHost := net.IPv4(first[0], first[1], first[2], first[3]+1).String()
+	for _, d := range devices {
+		if d.IPv4 == firstHost {
+			return d
+		}
+	}
+	return nil
+}
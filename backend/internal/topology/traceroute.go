@@ -0,0 +1,119 @@
+package topology
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxTracerouteHops = 16
+
+// Traceroute runs the system traceroute towards target and returns the hop addresses.
+// Unanswered hops are returned as empty strings.
+func Traceroute(target string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "tracert", "-d", "-h", strconv.Itoa(maxTracerouteHops), "-w", "1000", target)
+	} else {
+		if _, err := exec.LookPath("traceroute"); err != nil {
+			return nil, fmt.Errorf("traceroute not available: %v", err)
+		}
+		cmd = exec.CommandContext(ctx, "traceroute", "-n", "-q", "1", "-w", "1", "-m", strconv.Itoa(maxTracerouteHops), target)
+	}
+
+	output, err := cmd.Output()
+	if err != nil && len(output) == 0 {
+		return nil, err
+	}
+	return ParseTraceroute(string(output)), nil
+}
+
+// ParseTraceroute extracts hop addresses from traceroute or tracert output
+func ParseTraceroute(output string) []string {
+	var hops []string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		hop, err := strconv.Atoi(fields[0])
+		if err != nil || hop != len(hops)+1 {
+			continue
+		}
+
+		address := ""
+		for _, field := range fields[1:] {
+			field = strings.Trim(field, "()[]")
+			if ip := net.ParseIP(field); ip != nil && ip.To4() != nil {
+				address = ip.String()
+				break
+			}
+		}
+		hops = append(hops, address)
+	}
+
+	// Trailing unanswered hops carry no information
+	for len(hops) > 0 && hops[len(hops)-1] == "" {
+		hops = hops[:len(hops)-1]
+	}
+	return hops
+}
+
+// DefaultGateway returns the IPv4 default gateway of the host running reconya
+func DefaultGateway() string {
+	switch runtime.GOOS {
+	case "linux":
+		content, err := os.ReadFile("/proc/net/route")
+		if err != nil {
+			return ""
+		}
+		return parseProcNetRoute(string(content))
+	case "darwin", "freebsd":
+		output, err := exec.Command("route", "-n", "get", "default").Output()
+		if err != nil {
+			return ""
+		}
+		for _, line := range strings.Split(string(output), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && fields[0] == "gateway:" && net.ParseIP(fields[1]) != nil {
+				return fields[1]
+			}
+		}
+	}
+	return ""
+}
+
+// parseProcNetRoute finds the default route in /proc/net/route, where addresses
+// are little endian hex
+func parseProcNetRoute(content string) string {
+	for _, line := range strings.Split(content, "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[2])
+		if err != nil || len(raw) != 4 {
+			continue
+		}
+		gateway := make(net.IP, 4)
+		binary.BigEndian.PutUint32(gateway, binary.LittleEndian.Uint32(raw))
+		if gateway.IsUnspecified() {
+			continue
+		}
+		return gateway.String()
+	}
+	return ""
+}
@@ -0,0 +1,45 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTraceroute(t *testing.T) {
+	t.Run("Unix", func(t *testing.T) {
+		output := `traceroute to 10.20.0.5 (10.20.0.5), 16 hops max, 60 byte packets
+ 1  192.168.1.1  0.512 ms
+ 2  *
+ 3  172.16.0.1  4.120 ms
+ 4  10.20.0.5  5.031 ms
+`
+		assert.Equal(t, []string{"192.168.1.1", "", "172.16.0.1", "10.20.0.5"}, ParseTraceroute(output))
+	})
+
+	t.Run("Windows", func(t *testing.T) {
+		output := `
+Tracing route to 10.20.0.5 over a maximum of 16 hops
+
+  1    <1 ms    <1 ms    <1 ms  192.168.1.1
+  2     3 ms     2 ms     3 ms  10.20.0.5
+
+Trace complete.
+`
+		assert.Equal(t, []string{"192.168.1.1", "10.20.0.5"}, ParseTraceroute(output))
+	})
+
+	t.Run("TrailingTimeoutsAreDropped", func(t *testing.T) {
+		output := " 1  192.168.1.1  0.5 ms\n 2  *\n 3  *\n"
+		assert.Equal(t, []string{"192.168.1.1"}, ParseTraceroute(output))
+	})
+}
+
+func TestParseProcNetRoute(t *testing.T) {
+	content := `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	0001A8C0	00000000	0001	0	0	0	00FFFFFF	0	0	0
+eth0	00000000	0101A8C0	0003	0	0	100	00000000	0	0	0
+`
+	assert.Equal(t, "192.168.1.1", parseProcNetRoute(content))
+	assert.Equal(t, "", parseProcNetRoute("Iface\tDestination\tGateway\n"))
+}
@@ -0,0 +1,47 @@
+package trust
+
+import (
+	"net"
+	"strings"
+
+	"reconya-ai/models"
+)
+
+// ParseMACList reads an authorized MAC list. Each line holds a MAC address optionally
+// followed by a label, separated by whitespace or a comma ("00:11:22:33:44:55,Alice laptop").
+// Blank lines and lines starting with # are skipped, lines without a valid MAC are
+// returned in invalid.
+func ParseMACList(text, networkID string) (entries []*models.BaselineEntry, invalid []string) {
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		field, label := line, ""
+		if i := strings.IndexAny(line, ", \t;"); i >= 0 {
+			field, label = line[:i], strings.Trim(line[i+1:], ", \t;\"")
+		}
+		mac, ok := NormalizeMAC(field)
+		if !ok {
+			invalid = append(invalid, line)
+			continue
+		}
+		if seen[mac] {
+			continue
+		}
+		seen[mac] = true
+		entries = append(entries, &models.BaselineEntry{MAC: mac, NetworkID: networkID, Label: label})
+	}
+	return entries, invalid
+}
+
+// NormalizeMAC returns a 48-bit MAC address in lowercase colon notation
+func NormalizeMAC(mac string) (string, bool) {
+	hw, err := net.ParseMAC(strings.Trim(strings.TrimSpace(mac), "\""))
+	if err != nil || len(hw) != 6 {
+		return "", false
+	}
+	return hw.String(), true
+}
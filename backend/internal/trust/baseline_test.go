@@ -0,0 +1,45 @@
+package trust
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMACList(t *testing.T) {
+	text := `# office laptops
+00:11:22:33:44:55 Alice laptop
+00-11-22-AA-BB-CC,"Bob desktop"
+0011.2233.4466
+not-a-mac
+
+00:11:22:33:44:55 duplicate
+mac,label
+`
+
+	entries, invalid := ParseMACList(text, "net-1")
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, "00:11:22:33:44:55", entries[0].MAC)
+	assert.Equal(t, "Alice laptop", entries[0].Label)
+	assert.Equal(t, "net-1", entries[0].NetworkID)
+	assert.Equal(t, "00:11:22:aa:bb:cc", entries[1].MAC)
+	assert.Equal(t, "Bob desktop", entries[1].Label)
+	assert.Equal(t, "00:11:22:33:44:66", entries[2].MAC)
+	assert.Empty(t, entries[2].Label)
+
+	assert.Equal(t, []string{"not-a-mac", "mac,label"}, invalid)
+}
+
+func TestNormalizeMAC(t *testing.T) {
+	mac, ok := NormalizeMAC(" AA:BB:CC:DD:EE:FF ")
+	assert.True(t, ok)
+	assert.Equal(t, "aa:bb:cc:dd:ee:ff", mac)
+
+	_, ok = NormalizeMAC("00:00:5e:00:53:01:02:03")
+	assert.False(t, ok, "EUI-64 addresses are not Ethernet MACs")
+
+	_, ok = NormalizeMAC("")
+	assert.False(t, ok)
+}
@@ -0,0 +1,316 @@
+package trust
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/eventlog"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/network"
+	"reconya-ai/models"
+)
+
+var logger = logging.For("trust")
+
+// TrustService keeps track of which devices are authorized on each network. Devices
+// found by the sweep start out as new, are approved automatically when their MAC is
+// in the baseline and raise events while they remain unapproved.
+type TrustService struct {
+	Repository      *db.TrustRepository
+	DeviceService   *device.DeviceService
+	NetworkService  *network.NetworkService
+	EventLogService *eventlog.EventLogService
+	// reportInterval is how long to wait before reporting the same device again
+	reportInterval time.Duration
+	reported       map[string]time.Time
+	mutex          sync.Mutex
+}
+
+func NewTrustService(repository *db.TrustRepository, deviceService *device.DeviceService, networkService *network.NetworkService, eventLogService *eventlog.EventLogService) *TrustService {
+	return &TrustService{
+		Repository:      repository,
+		DeviceService:   deviceService,
+		NetworkService:  networkService,
+		EventLogService: eventLogService,
+		reportInterval:  24 * time.Hour,
+		reported:        make(map[string]time.Time),
+	}
+}
+
+// SetTrustState changes the trust state of the given devices
+func (s *TrustService) SetTrustState(deviceIDs []string, state models.TrustState) error {
+	switch state {
+	case models.TrustStateNew, models.TrustStateApproved, models.TrustStateBlocked:
+	default:
+		return fmt.Errorf("invalid trust state %q, use new, approved or blocked", state)
+	}
+
+	for _, deviceID := range deviceIDs {
+		d, err := s.DeviceService.FindByID(deviceID)
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			return fmt.Errorf("device %s: %w", deviceID, db.ErrNotFound)
+		}
+		if d.TrustState == state {
+			continue
+		}
+		if err := s.setState(d, state, fmt.Sprintf("Device [%s] marked as %s", d.IPv4, state)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListBaseline returns the authorized MAC addresses
+func (s *TrustService) ListBaseline() ([]*models.BaselineEntry, error) {
+	return s.Repository.ListBaseline(context.Background())
+}
+
+// ImportBaseline adds a MAC list to the baseline, for one network or every network
+// when networkID is empty, and approves the new devices it covers. It returns the
+// number of MACs added and the lines that could not be parsed.
+func (s *TrustService) ImportBaseline(text, networkID string) (int, []string, error) {
+	if networkID != "" {
+		n, err := s.NetworkService.FindByID(networkID)
+		if err != nil {
+			return 0, nil, err
+		}
+		if n == nil {
+			return 0, nil, fmt.Errorf("network %s: %w", networkID, db.ErrNotFound)
+		}
+	}
+
+	entries, invalid := ParseMACList(text, networkID)
+	if len(entries) == 0 {
+		return 0, invalid, fmt.Errorf("no valid MAC addresses found")
+	}
+
+	added, err := s.Repository.AddBaseline(context.Background(), entries)
+	if err != nil {
+		return 0, invalid, err
+	}
+
+	devices, err := s.DeviceService.FindAll()
+	if err != nil {
+		return added, invalid, err
+	}
+	baseline, err := s.baselineIndex()
+	if err != nil {
+		return added, invalid, err
+	}
+	for _, d := range devices {
+		if d.TrustState == models.TrustStateNew && baseline.contains(d) {
+			if err := s.setState(d, models.TrustStateApproved, fmt.Sprintf("Device [%s] approved from the MAC baseline", d.IPv4)); err != nil {
+				return added, invalid, err
+			}
+		}
+	}
+	return added, invalid, nil
+}
+
+// DeleteBaseline removes a MAC from the baseline. Devices it approved stay approved.
+func (s *TrustService) DeleteBaseline(id string) error {
+	return s.Repository.DeleteBaseline(context.Background(), id)
+}
+
+// SetLockdown turns lockdown mode on or off for a network
+func (s *TrustService) SetLockdown(networkID string, enabled bool) error {
+	if err := s.Repository.SetLockdown(context.Background(), networkID, enabled); err != nil {
+		return err
+	}
+	state := "disabled"
+	if enabled {
+		state = "enabled"
+	}
+	n, err := s.NetworkService.FindByID(networkID)
+	if err == nil && n != nil {
+		s.logEvent(models.NetworkUpdated, fmt.Sprintf("Lockdown %s for network %s", state, n.GetDisplayName()), "")
+	}
+	return nil
+}
+
+// CheckSweep reviews the devices seen by a sweep that started at sweepStartedAt.
+// New devices whose MAC is in the baseline are approved. Remaining new devices are
+// reported as unapproved, or raise an alert when the network is in lockdown, and
+// blocked devices always raise an alert. Each device is reported at most once per
+// report interval.
+func (s *TrustService) CheckSweep(n *models.Network, sweepStartedAt time.Time) {
+	// Reload the network so a lockdown switched on during the sweep applies
+	if current, err := s.NetworkService.FindByID(n.ID); err == nil && current != nil {
+		n = current
+	}
+	devices, err := s.DeviceService.FindByNetworkID(n.ID)
+	if err != nil {
+		logger.Errorf("Failed to load devices for trust check on network %s: %v", n.CIDR, err)
+		return
+	}
+	baseline, err := s.baselineIndex()
+	if err != nil {
+		logger.Errorf("Failed to load MAC baseline: %v", err)
+		return
+	}
+
+	for i := range devices {
+		d := &devices[i]
+		if d.LastSeenOnlineAt == nil || d.LastSeenOnlineAt.Before(sweepStartedAt) {
+			continue
+		}
+
+		switch d.TrustState {
+		case models.TrustStateApproved:
+			continue
+		case models.TrustStateBlocked:
+			if s.shouldReport(d.ID) {
+				s.logEvent(models.Alert, fmt.Sprintf("Blocked device [%s] %s seen on network %s", d.IPv4, macOrUnknown(d), n.GetDisplayName()), d.ID)
+			}
+		default:
+			if baseline.contains(d) {
+				if err := s.setState(d, models.TrustStateApproved, fmt.Sprintf("Device [%s] approved from the MAC baseline", d.IPv4)); err != nil {
+					logger.Errorf("Failed to approve device %s: %v", d.IPv4, err)
+				}
+				continue
+			}
+			if !s.shouldReport(d.ID) {
+				continue
+			}
+			if n.Lockdown {
+				s.logEvent(models.Alert, fmt.Sprintf("Lockdown: unknown MAC %s at [%s] on network %s", macOrUnknown(d), d.IPv4, n.GetDisplayName()), d.ID)
+			} else {
+				s.logEvent(models.UnapprovedDeviceSeen, fmt.Sprintf("Unapproved device [%s] %s seen on network %s", d.IPv4, macOrUnknown(d), n.GetDisplayName()), d.ID)
+			}
+		}
+	}
+}
+
+// Report lists the new and blocked devices on a network, or on every network when
+// networkID is empty, most recently seen first
+func (s *TrustService) Report(networkID string) ([]*models.TrustReport, error) {
+	var networks []models.Network
+	if networkID != "" {
+		n, err := s.NetworkService.FindByID(networkID)
+		if err != nil {
+			return nil, err
+		}
+		if n == nil {
+			return nil, db.ErrNotFound
+		}
+		networks = append(networks, *n)
+	} else {
+		all, err := s.NetworkService.FindAll()
+		if err != nil {
+			return nil, err
+		}
+		networks = all
+	}
+
+	reports := make([]*models.TrustReport, 0, len(networks))
+	for _, n := range networks {
+		devices, err := s.DeviceService.FindByNetworkID(n.ID)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(devices, func(i, j int) bool {
+			return lastSeen(&devices[i]).After(lastSeen(&devices[j]))
+		})
+
+		report := &models.TrustReport{
+			NetworkID:   n.ID,
+			NetworkName: n.GetDisplayName(),
+			CIDR:        n.CIDR,
+			Lockdown:    n.Lockdown,
+			New:         []*models.Device{},
+			Blocked:     []*models.Device{},
+		}
+		for i := range devices {
+			switch devices[i].TrustState {
+			case models.TrustStateNew:
+				report.New = append(report.New, &devices[i])
+			case models.TrustStateBlocked:
+				report.Blocked = append(report.Blocked, &devices[i])
+			}
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+func (s *TrustService) setState(d *models.Device, state models.TrustState, description string) error {
+	if err := s.Repository.SetTrustState(context.Background(), d.ID, state); err != nil {
+		return err
+	}
+	d.TrustState = state
+	s.mutex.Lock()
+	delete(s.reported, d.ID)
+	s.mutex.Unlock()
+	s.logEvent(models.DeviceTrustChanged, description, d.ID)
+	return nil
+}
+
+func (s *TrustService) shouldReport(deviceID string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if last, ok := s.reported[deviceID]; ok && time.Since(last) < s.reportInterval {
+		return false
+	}
+	s.reported[deviceID] = time.Now()
+	return true
+}
+
+func (s *TrustService) logEvent(eventType models.EEventLogType, description, deviceID string) {
+	if s.EventLogService == nil {
+		return
+	}
+	if err := s.EventLogService.Log(eventType, description, deviceID); err != nil {
+		logger.Errorf("Failed to log %s event: %v", eventType, err)
+	}
+}
+
+// baselineIndex maps network IDs (empty for every network) to their authorized MACs
+type baselineIndex map[string]map[string]bool
+
+func (s *TrustService) baselineIndex() (baselineIndex, error) {
+	entries, err := s.Repository.ListBaseline(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	index := make(baselineIndex)
+	for _, entry := range entries {
+		if index[entry.NetworkID] == nil {
+			index[entry.NetworkID] = make(map[string]bool)
+		}
+		index[entry.NetworkID][entry.MAC] = true
+	}
+	return index, nil
+}
+
+func (b baselineIndex) contains(d *models.Device) bool {
+	if d.MAC == nil {
+		return false
+	}
+	mac, ok := NormalizeMAC(*d.MAC)
+	if !ok {
+		return false
+	}
+	return b[""][mac] || b[d.NetworkID][mac]
+}
+
+func macOrUnknown(d *models.Device) string {
+	if d.MAC == nil || *d.MAC == "" {
+		return "(MAC unknown)"
+	}
+	return *d.MAC
+}
+
+func lastSeen(d *models.Device) time.Time {
+	if d.LastSeenOnlineAt == nil {
+		return time.Time{}
+	}
+	return *d.LastSeenOnlineAt
+}
@@ -0,0 +1,501 @@
+package upnp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/exclusion"
+	"reconya-ai/internal/logging"
+	"reconya-ai/models"
+)
+
+var logger = logging.For("upnp")
+
+const (
+	ssdpMulticastAddr = "239.255.255.250:1900"
+	ssdpSearchTarget  = "ssdp:all"
+	// maxPortMappings bounds the GetGenericPortMappingEntry enumeration for misbehaving routers
+	maxPortMappings = 512
+)
+
+// SSDPResponse is a single reply to an M-SEARCH request
+type SSDPResponse struct {
+	Addr     string
+	Location string
+	Server   string
+	ST       string
+	USN      string
+}
+
+type UPnPService struct {
+	DeviceService *device.DeviceService
+	// Exclusions skip description and port mapping fetches
+	Exclusions        *exclusion.ExclusionService
+	client            *http.Client
+	searchTimeout     time.Duration
+	discoveryInterval time.Duration
+	lastRun           map[string]time.Time
+	mutex             sync.Mutex
+}
+
+func NewUPnPService(deviceService *device.DeviceService) *UPnPService {
+	return &UPnPService{
+		DeviceService: deviceService,
+		client: &http.Client{
+			Timeout: 5 * time.Second,
+			// A redirect could send the requests to a host other than the responder
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		searchTimeout:     3 * time.Second,
+		discoveryInterval: 10 * time.Minute,
+		lastRun:           make(map[string]time.Time),
+	}
+}
+
+// Run discovers UPnP devices on the given network and attaches their descriptions
+// to known devices. Discovery is throttled per network since SSDP replies change rarely.
+func (s *UPnPService) Run(network *models.Network) {
+	if network == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	if last, ok := s.lastRun[network.ID]; ok && time.Since(last) < s.discoveryInterval {
+		s.mutex.Unlock()
+		return
+	}
+	s.lastRun[network.ID] = time.Now()
+	s.mutex.Unlock()
+
+	_, ipNet, err := net.ParseCIDR(network.CIDR)
+	if err != nil {
+		logger.Infof("UPnP discovery skipped, invalid CIDR %s: %v", network.CIDR, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	responses, err := s.Discover(ctx)
+	if err != nil {
+		logger.Errorf("SSDP discovery failed: %v", err)
+		return
+	}
+
+	// Group advertised locations by responder address
+	locations := make(map[string][]SSDPResponse)
+	for _, response := range responses {
+		ip := net.ParseIP(response.Addr)
+		if ip == nil || !ipNet.Contains(ip) {
+			continue
+		}
+		locations[response.Addr] = append(locations[response.Addr], response)
+	}
+
+	logger.Infof("SSDP discovery found %d UPnP responders on network %s", len(locations), network.CIDR)
+
+	for addr, responses := range locations {
+		err := s.UpdateDevice(ctx, addr, responses)
+		if errors.Is(err, exclusion.ErrExcluded) {
+			logger.Infof("Skipping UPnP responder %s: %v", addr, err)
+		} else if err != nil {
+			logger.Errorf("Error saving UPnP info for %s: %v", addr, err)
+		}
+	}
+}
+
+// UpdateDevice fetches the descriptions advertised by one responder and stores the
+// most useful one on the known device at its address. Responders that are not known
+// devices yet are skipped, those the exclusion rules protect are not contacted and an
+// error wrapping exclusion.ErrExcluded is returned.
+func (s *UPnPService) UpdateDevice(ctx context.Context, addr string, responses []SSDPResponse) error {
+	existing, err := s.DeviceService.FindByIPv4(addr)
+	if err != nil || existing == nil {
+		logger.Debugf("UPnP responder %s is not a known device yet, skipping", addr)
+		return nil
+	}
+	var mac string
+	if existing.MAC != nil {
+		mac = *existing.MAC
+	}
+	if rule := s.Exclusions.Blocked(models.ExcludeUPnP, addr, mac); rule != nil {
+		return exclusion.BlockedError(rule, models.ExcludeUPnP)
+	}
+
+	info := s.describe(ctx, responses)
+	if info == nil {
+		return nil
+	}
+
+	existing.UPnP = info
+	if (existing.Vendor == nil || *existing.Vendor == "") && info.Manufacturer != "" {
+		manufacturer := info.Manufacturer
+		existing.Vendor = &manufacturer
+	}
+
+	if _, err := s.DeviceService.CreateOrUpdate(existing); err != nil {
+		return err
+	}
+	logger.Infof("UPnP device %s: %s (%s %s), %d services, %d port mappings",
+		addr, info.FriendlyName, info.Manufacturer, info.ModelName, len(info.Services), len(info.PortMappings))
+	return nil
+}
+
+// describe fetches the descriptions advertised by a single responder and returns the
+// most useful one, preferring Internet Gateway Devices so port mappings get collected
+func (s *UPnPService) describe(ctx context.Context, responses []SSDPResponse) *models.UPnPInfo {
+	var best *models.UPnPInfo
+	seen := make(map[string]bool)
+
+	for _, response := range responses {
+		if response.Location == "" || seen[response.Location] {
+			continue
+		}
+		seen[response.Location] = true
+
+		// Only follow descriptions served by the responder itself
+		if locationURL, err := url.Parse(response.Location); err != nil || locationURL.Hostname() != response.Addr {
+			logger.Debugf("Ignoring UPnP location %s advertised by %s", response.Location, response.Addr)
+			continue
+		}
+
+		info, err := s.FetchDescription(ctx, response.Location)
+		if err != nil {
+			logger.Errorf("Error fetching UPnP description from %s: %v", response.Location, err)
+			continue
+		}
+		info.Server = response.Server
+
+		if best == nil || (!best.IsInternetGateway() && info.IsInternetGateway()) {
+			best = info
+		}
+	}
+
+	if best != nil && best.IsInternetGateway() {
+		mappings, err := s.FetchPortMappings(ctx, best)
+		if err != nil {
+			logger.Errorf("Error listing port mappings from %s: %v", best.Location, err)
+		}
+		best.PortMappings = mappings
+	}
+
+	return best
+}
+
+// Discover sends an SSDP M-SEARCH and collects responses until the search timeout expires
+func (s *UPnPService) Discover(ctx context.Context) ([]SSDPResponse, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SSDP socket: %w", err)
+	}
+	defer conn.Close()
+
+	dst, err := net.ResolveUDPAddr("udp4", ssdpMulticastAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	request := buildMSearch(ssdpSearchTarget, int(s.searchTimeout.Seconds()))
+	// SSDP runs over UDP, so send the request twice as recommended by the UPnP spec
+	for i := 0; i < 2; i++ {
+		if _, err := conn.WriteTo(request, dst); err != nil {
+			return nil, fmt.Errorf("failed to send M-SEARCH: %w", err)
+		}
+	}
+
+	deadline := time.Now().Add(s.searchTimeout + time.Second)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+
+	var responses []SSDPResponse
+	seen := make(map[string]bool)
+	buf := make([]byte, 8192)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				break
+			}
+			return responses, err
+		}
+
+		response, err := ParseSSDPResponse(buf[:n])
+		if err != nil {
+			continue
+		}
+		response.Addr = from.IP.String()
+
+		key := response.Addr + "|" + response.Location + "|" + response.USN
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		responses = append(responses, *response)
+	}
+
+	return responses, nil
+}
+
+func buildMSearch(searchTarget string, mx int) []byte {
+	if mx < 1 {
+		mx = 1
+	}
+	return []byte("M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpMulticastAddr + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: " + strconv.Itoa(mx) + "\r\n" +
+		"ST: " + searchTarget + "\r\n" +
+		"USER-AGENT: reconYa UPnP/1.1\r\n\r\n")
+}
+
+// ParseSSDPResponse parses the HTTP-over-UDP reply to an M-SEARCH request
+func ParseSSDPResponse(data []byte) (*SSDPResponse, error) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected SSDP status: %d", resp.StatusCode)
+	}
+
+	return &SSDPResponse{
+		Location: resp.Header.Get("Location"),
+		Server:   resp.Header.Get("Server"),
+		ST:       resp.Header.Get("St"),
+		USN:      resp.Header.Get("Usn"),
+	}, nil
+}
+
+// FetchDescription downloads and parses the device description at an SSDP LOCATION
+func (s *UPnPService) FetchDescription(ctx context.Context, location string) (*models.UPnPInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseDeviceDescription(body, location)
+}
+
+type descriptionRoot struct {
+	URLBase string            `xml:"URLBase"`
+	Device  descriptionDevice `xml:"device"`
+}
+
+type descriptionDevice struct {
+	DeviceType   string               `xml:"deviceType"`
+	FriendlyName string               `xml:"friendlyName"`
+	Manufacturer string               `xml:"manufacturer"`
+	ModelName    string               `xml:"modelName"`
+	ModelNumber  string               `xml:"modelNumber"`
+	SerialNumber string               `xml:"serialNumber"`
+	UDN          string               `xml:"UDN"`
+	Services     []descriptionService `xml:"serviceList>service"`
+	Devices      []descriptionDevice  `xml:"deviceList>device"`
+}
+
+type descriptionService struct {
+	ServiceType string `xml:"serviceType"`
+	ServiceID   string `xml:"serviceId"`
+	ControlURL  string `xml:"controlURL"`
+	SCPDURL     string `xml:"SCPDURL"`
+}
+
+// ParseDeviceDescription parses a UPnP device description document. Services of embedded
+// devices are flattened into the root device so IGD connection services are reachable.
+// Control and SCPD URLs pointing away from the host serving the description are dropped.
+func ParseDeviceDescription(data []byte, location string) (*models.UPnPInfo, error) {
+	var root descriptionRoot
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("error parsing device description: %w", err)
+	}
+
+	base := location
+	if strings.TrimSpace(root.URLBase) != "" {
+		base = strings.TrimSpace(root.URLBase)
+	}
+
+	info := &models.UPnPInfo{
+		Location:     location,
+		DeviceType:   strings.TrimSpace(root.Device.DeviceType),
+		UDN:          strings.TrimSpace(root.Device.UDN),
+		FriendlyName: strings.TrimSpace(root.Device.FriendlyName),
+		Manufacturer: strings.TrimSpace(root.Device.Manufacturer),
+		ModelName:    strings.TrimSpace(root.Device.ModelName),
+		ModelNumber:  strings.TrimSpace(root.Device.ModelNumber),
+		SerialNumber: strings.TrimSpace(root.Device.SerialNumber),
+		DiscoveredAt: time.Now(),
+	}
+
+	var host string
+	if locationURL, err := url.Parse(location); err == nil {
+		host = locationURL.Hostname()
+	}
+	sameHost := func(ref string) string {
+		resolved := resolveURL(base, ref)
+		if resolvedURL, err := url.Parse(resolved); err != nil || resolvedURL.Hostname() != host {
+			return ""
+		}
+		return resolved
+	}
+
+	var collect func(d descriptionDevice)
+	collect = func(d descriptionDevice) {
+		for _, svc := range d.Services {
+			info.Services = append(info.Services, models.UPnPService{
+				ServiceType: strings.TrimSpace(svc.ServiceType),
+				ServiceID:   strings.TrimSpace(svc.ServiceID),
+				ControlURL:  sameHost(strings.TrimSpace(svc.ControlURL)),
+				SCPDURL:     sameHost(strings.TrimSpace(svc.SCPDURL)),
+			})
+		}
+		for _, child := range d.Devices {
+			collect(child)
+		}
+	}
+	collect(root.Device)
+
+	return info, nil
+}
+
+func resolveURL(base, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return baseURL.ResolveReference(refURL).String()
+}
+
+type portMappingEnvelope struct {
+	Entry struct {
+		RemoteHost     string `xml:"NewRemoteHost"`
+		ExternalPort   int    `xml:"NewExternalPort"`
+		Protocol       string `xml:"NewProtocol"`
+		InternalPort   int    `xml:"NewInternalPort"`
+		InternalClient string `xml:"NewInternalClient"`
+		Enabled        string `xml:"NewEnabled"`
+		Description    string `xml:"NewPortMappingDescription"`
+		LeaseDuration  int    `xml:"NewLeaseDuration"`
+	} `xml:"Body>GetGenericPortMappingEntryResponse"`
+}
+
+// FetchPortMappings enumerates active port mappings on an Internet Gateway Device
+// using GetGenericPortMappingEntry until the router reports an invalid index
+func (s *UPnPService) FetchPortMappings(ctx context.Context, info *models.UPnPInfo) ([]models.UPnPPortMapping, error) {
+	var mappings []models.UPnPPortMapping
+
+	for _, service := range info.Services {
+		if !models.IsWANConnectionService(service.ServiceType) || service.ControlURL == "" {
+			continue
+		}
+
+		for index := 0; index < maxPortMappings; index++ {
+			mapping, err := s.getGenericPortMappingEntry(ctx, service, index)
+			if err != nil {
+				if index == 0 {
+					return mappings, err
+				}
+				break
+			}
+			if mapping == nil {
+				break
+			}
+			mappings = append(mappings, *mapping)
+		}
+	}
+
+	return mappings, nil
+}
+
+func (s *UPnPService) getGenericPortMappingEntry(ctx context.Context, service models.UPnPService, index int) (*models.UPnPPortMapping, error) {
+	var serviceType bytes.Buffer
+	if err := xml.EscapeText(&serviceType, []byte(service.ServiceType)); err != nil {
+		return nil, err
+	}
+	body := `<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body><u:GetGenericPortMappingEntry xmlns:u="` + serviceType.String() + `">` +
+		`<NewPortMappingIndex>` + strconv.Itoa(index) + `</NewPortMappingIndex>` +
+		`</u:GetGenericPortMappingEntry></s:Body></s:Envelope>`
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, service.ControlURL, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", `"`+service.ServiceType+`#GetGenericPortMappingEntry"`)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Routers answer with a SOAP fault (SpecifiedArrayIndexInvalid) past the last entry
+	if resp.StatusCode == http.StatusInternalServerError {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err != nil {
+		return nil, err
+	}
+
+	var envelope portMappingEnvelope
+	if err := xml.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("error parsing port mapping response: %w", err)
+	}
+
+	entry := envelope.Entry
+	return &models.UPnPPortMapping{
+		RemoteHost:     strings.TrimSpace(entry.RemoteHost),
+		ExternalPort:   entry.ExternalPort,
+		Protocol:       strings.ToUpper(strings.TrimSpace(entry.Protocol)),
+		InternalPort:   entry.InternalPort,
+		InternalClient: strings.TrimSpace(entry.InternalClient),
+		Enabled:        entry.Enabled == "1" || strings.EqualFold(entry.Enabled, "true"),
+		Description:    strings.TrimSpace(entry.Description),
+		LeaseDuration:  entry.LeaseDuration,
+	}, nil
+}
@@ -0,0 +1,148 @@
+package upnp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const igdDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <friendlyName>Home Router</friendlyName>
+    <manufacturer>ACME</manufacturer>
+    <modelName>RT-1000</modelName>
+    <modelNumber>1.2</modelNumber>
+    <serialNumber>SN123456</serialNumber>
+    <UDN>uuid:1234</UDN>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <deviceList>
+          <device>
+            <deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+            <serviceList>
+              <service>
+                <serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType>
+                <serviceId>urn:upnp-org:serviceId:WANIPConn1</serviceId>
+                <controlURL>/ctl/IPConn</controlURL>
+                <SCPDURL>/WANIPCn.xml</SCPDURL>
+              </service>
+            </serviceList>
+          </device>
+        </deviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`
+
+func TestParseSSDPResponse(t *testing.T) {
+	data := "HTTP/1.1 200 OK\r\n" +
+		"CACHE-CONTROL: max-age=1800\r\n" +
+		"LOCATION: http://192.168.1.1:5000/rootDesc.xml\r\n" +
+		"SERVER: Linux UPnP/1.1 MiniUPnPd/2.2\r\n" +
+		"ST: upnp:rootdevice\r\n" +
+		"USN: uuid:1234::upnp:rootdevice\r\n\r\n"
+
+	response, err := ParseSSDPResponse([]byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, "http://192.168.1.1:5000/rootDesc.xml", response.Location)
+	assert.Equal(t, "Linux UPnP/1.1 MiniUPnPd/2.2", response.Server)
+	assert.Equal(t, "upnp:rootdevice", response.ST)
+	assert.Equal(t, "uuid:1234::upnp:rootdevice", response.USN)
+}
+
+func TestParseDeviceDescription(t *testing.T) {
+	info, err := ParseDeviceDescription([]byte(igdDescription), "http://192.168.1.1:5000/rootDesc.xml")
+	require.NoError(t, err)
+
+	assert.Equal(t, "Home Router", info.FriendlyName)
+	assert.Equal(t, "ACME", info.Manufacturer)
+	assert.Equal(t, "RT-1000", info.ModelName)
+	assert.Equal(t, "1.2", info.ModelNumber)
+	assert.Equal(t, "SN123456", info.SerialNumber)
+	require.Len(t, info.Services, 1)
+	assert.Equal(t, "http://192.168.1.1:5000/ctl/IPConn", info.Services[0].ControlURL)
+	assert.True(t, info.IsInternetGateway())
+}
+
+func TestParseDeviceDescription_DropsForeignURLs(t *testing.T) {
+	description := strings.Replace(igdDescription, `<root xmlns="urn:schemas-upnp-org:device-1-0">`,
+		`<root xmlns="urn:schemas-upnp-org:device-1-0"><URLBase>http://192.168.1.99/</URLBase>`, 1)
+	info, err := ParseDeviceDescription([]byte(description), "http://192.168.1.1:5000/rootDesc.xml")
+	require.NoError(t, err)
+	require.Len(t, info.Services, 1)
+	assert.Empty(t, info.Services[0].ControlURL, "the control URL points at another host")
+	assert.Empty(t, info.Services[0].SCPDURL)
+}
+
+func TestFetchDescription_DoesNotFollowRedirects(t *testing.T) {
+	var followed bool
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed = true
+		fmt.Fprint(w, igdDescription)
+	}))
+	defer target.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL+"/rootDesc.xml", http.StatusFound)
+	}))
+	defer server.Close()
+
+	_, err := NewUPnPService(nil).FetchDescription(context.Background(), server.URL+"/rootDesc.xml")
+	assert.Error(t, err)
+	assert.False(t, followed)
+}
+
+func TestFetchPortMappings(t *testing.T) {
+	entries := []string{"TCP|8080|192.168.1.50|80|web", "UDP|51820|192.168.1.60|51820|vpn"}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.Header.Get("SOAPAction"), "#GetGenericPortMappingEntry")
+		body, _ := io.ReadAll(r.Body)
+		assert.NotContains(t, string(body), "<injected/>", "the service type is escaped")
+
+		index := -1
+		for i := range entries {
+			if strings.Contains(string(body), fmt.Sprintf("<NewPortMappingIndex>%d</NewPortMappingIndex>", i)) {
+				index = i
+			}
+		}
+		if index < 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		parts := strings.Split(entries[index], "|")
+		fmt.Fprintf(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>`+
+			`<u:GetGenericPortMappingEntryResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">`+
+			`<NewRemoteHost></NewRemoteHost><NewExternalPort>%s</NewExternalPort><NewProtocol>%s</NewProtocol>`+
+			`<NewInternalPort>%s</NewInternalPort><NewInternalClient>%s</NewInternalClient><NewEnabled>1</NewEnabled>`+
+			`<NewPortMappingDescription>%s</NewPortMappingDescription><NewLeaseDuration>0</NewLeaseDuration>`+
+			`</u:GetGenericPortMappingEntryResponse></s:Body></s:Envelope>`, parts[1], parts[0], parts[3], parts[2], parts[4])
+	}))
+	defer server.Close()
+
+	info, err := ParseDeviceDescription([]byte(igdDescription), server.URL+"/rootDesc.xml")
+	require.NoError(t, err)
+
+	service := NewUPnPService(nil)
+	info.Services[0].ServiceType += `"><injected/><x a="`
+	mappings, err := service.FetchPortMappings(context.Background(), info)
+	require.NoError(t, err)
+	require.Len(t, mappings, 2)
+
+	assert.Equal(t, "TCP", mappings[0].Protocol)
+	assert.Equal(t, 8080, mappings[0].ExternalPort)
+	assert.Equal(t, "192.168.1.50", mappings[0].InternalClient)
+	assert.Equal(t, 80, mappings[0].InternalPort)
+	assert.True(t, mappings[0].Enabled)
+	assert.Equal(t, "vpn", mappings[1].Description)
+}
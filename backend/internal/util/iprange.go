@@ -0,0 +1,56 @@
+package util
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"net"
+	"strings"
+)
+
+// ParseIPv4Range reads an inclusive address range, either two addresses,
+// 10.0.0.5-10.0.0.20, or an address and a last octet, 10.0.0.5-20
+func ParseIPv4Range(value string) (uint32, uint32, error) {
+	parts := strings.SplitN(value, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("%q is not an IPv4 range", value)
+	}
+	first := net.ParseIP(strings.TrimSpace(parts[0])).To4()
+	if first == nil {
+		return 0, 0, fmt.Errorf("%q is not an IPv4 range", value)
+	}
+	lastPart := strings.TrimSpace(parts[1])
+	last := net.ParseIP(lastPart).To4()
+	if last == nil && !strings.Contains(lastPart, ".") {
+		last = net.ParseIP(fmt.Sprintf("%d.%d.%d.%s", first[0], first[1], first[2], lastPart)).To4()
+	}
+	if last == nil {
+		return 0, 0, fmt.Errorf("%q is not an IPv4 range", value)
+	}
+
+	start, end := binary.BigEndian.Uint32(first), binary.BigEndian.Uint32(last)
+	if start > end {
+		return 0, 0, fmt.Errorf("range %q ends before it starts", value)
+	}
+	return start, end, nil
+}
+
+// RangeToCIDRs covers an inclusive address range with the fewest CIDRs
+func RangeToCIDRs(start, end uint32) []string {
+	var cidrs []string
+	for current := uint64(start); current <= uint64(end); {
+		// The largest block aligned on current that still fits before end
+		size := 32
+		if current > 0 {
+			size = bits.TrailingZeros32(uint32(current))
+		}
+		for size > 0 && current+(1<<size)-1 > uint64(end) {
+			size--
+		}
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, uint32(current))
+		cidrs = append(cidrs, fmt.Sprintf("%s/%d", ip, 32-size))
+		current += 1 << size
+	}
+	return cidrs
+}
@@ -0,0 +1,14 @@
+package util
+
+import "net"
+
+// IsRandomizedMAC reports whether a unicast MAC address has the locally administered
+// (U/L) bit set. Such addresses are randomized by the client or assigned by software
+// and their first three octets do not identify a vendor.
+func IsRandomizedMAC(mac string) bool {
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) == 0 {
+		return false
+	}
+	return hw[0]&0x02 != 0 && hw[0]&0x01 == 0
+}
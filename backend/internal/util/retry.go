@@ -1,11 +1,14 @@
 package util
 
 import (
-	"log"
 	"strings"
 	"time"
+
+	"reconya-ai/internal/logging"
 )
 
+var logger = logging.For("database")
+
 // RetryOnLock retries the given function if it fails with a database lock error
 func RetryOnLock(operation func() error) error {
 	maxRetries := 3
@@ -22,7 +25,7 @@ func RetryOnLock(operation func() error) error {
 		if strings.Contains(err.Error(), "database is locked") {
 			// Exponential backoff: 100ms, 200ms, 400ms
 			delay := baseDelay * time.Duration(1<<i)
-			log.Printf("Database locked, retrying in %v...", delay)
+			logger.Warnf("Database locked, retrying in %v...", delay)
 			time.Sleep(delay)
 			continue
 		}
@@ -54,7 +57,7 @@ func RetryOnLockWithResult[T any](operation func() (T, error)) (T, error) {
 		if strings.Contains(err.Error(), "database is locked") {
 			// Exponential backoff: 100ms, 200ms, 400ms
 			delay := baseDelay * time.Duration(1<<i)
-			log.Printf("Database locked, retrying in %v...", delay)
+			logger.Warnf("Database locked, retrying in %v...", delay)
 			time.Sleep(delay)
 			continue
 		}
@@ -0,0 +1,176 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// APIAdhocScans lists the ad-hoc scans, newest first
+func (h *WebHandler) APIAdhocScans(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	scans, err := h.adhocScanService.FindAll()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load ad-hoc scans: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"scans":   scans,
+	})
+}
+
+// APIStartAdhocScan scans targets outside the saved networks: addresses, CIDRs
+// and ranges separated by commas or new lines, with the quick, standard or deep
+// profile. The devices found wait in the scratch area.
+func (h *WebHandler) APIStartAdhocScan(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	profile := strings.TrimSpace(r.FormValue("profile"))
+	if profile == "" {
+		profile = "standard"
+	}
+	scan, err := h.adhocScanService.Start(r.FormValue("targets"), profile)
+	if err != nil {
+		logger.Warnf("APIStartAdhocScan: Scan not started: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to start scan: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Scan queued",
+		"scan":    scan,
+	})
+}
+
+// APIAdhocScan returns an ad-hoc scan with the devices it found
+func (h *WebHandler) APIAdhocScan(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	scan, err := h.adhocScanService.FindByID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load ad-hoc scan: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if scan == nil {
+		http.Error(w, "Ad-hoc scan not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"scan":    scan,
+	})
+}
+
+// APICancelAdhocScan stops a waiting or running ad-hoc scan, keeping what it found
+func (h *WebHandler) APICancelAdhocScan(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.adhocScanService.Cancel(mux.Vars(r)["id"]); err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to cancel scan: %v", err),
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Scan cancelled",
+	})
+}
+
+// APIPromoteAdhocScan moves scratch devices into the network given by network_id,
+// or by cidr which is created when unknown. ips limits it to some devices.
+func (h *WebHandler) APIPromoteAdhocScan(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.ParseForm()
+	var ips []string
+	for _, value := range r.Form["ips"] {
+		for _, ip := range strings.Split(value, ",") {
+			if ip = strings.TrimSpace(ip); ip != "" {
+				ips = append(ips, ip)
+			}
+		}
+	}
+
+	id := mux.Vars(r)["id"]
+	promotion, err := h.adhocScanService.Promote(id, strings.TrimSpace(r.FormValue("network_id")), strings.TrimSpace(r.FormValue("cidr")), ips)
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		logger.Warnf("APIPromoteAdhocScan: Scan %s not promoted: %v", id, err)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to promote devices: %v", err),
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"message":   fmt.Sprintf("%d devices added to the network", len(promotion.Promoted)),
+		"promotion": promotion,
+	})
+}
+
+// APIDiscardAdhocScan deletes an ad-hoc scan and its scratch devices
+func (h *WebHandler) APIDiscardAdhocScan(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.adhocScanService.Discard(mux.Vars(r)["id"]); err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to discard scan: %v", err),
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Scan discarded",
+	})
+}
@@ -0,0 +1,228 @@
+package web
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/agent"
+	"reconya-ai/models"
+
+	"github.com/gorilla/mux"
+)
+
+// maxAgentReportSize bounds the body of a single agent report
+const maxAgentReportSize = 16 << 20
+
+// APIAgentEnroll registers a remote sensor. Agents send the enrollment token and get
+// an API key back, agents presenting a trusted client certificate need no token.
+func (h *WebHandler) APIAgentEnroll(w http.ResponseWriter, r *http.Request) {
+	var req agent.EnrollRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		writeAgentError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	var response *agent.EnrollResponse
+	if cert := agentCertificate(r); cert != nil && req.Token == "" {
+		a, err := h.agentService.Authenticate(cert, "")
+		if err != nil {
+			writeAgentServiceError(w, err)
+			return
+		}
+		response = &agent.EnrollResponse{AgentID: a.ID}
+	} else {
+		var err error
+		response, err = h.agentService.Enroll(req)
+		if err != nil {
+			writeAgentServiceError(w, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// APIAgentCheckIn records an agent's health and returns the networks it should scan
+func (h *WebHandler) APIAgentCheckIn(w http.ResponseWriter, r *http.Request) {
+	a, ok := h.authenticateAgent(w, r)
+	if !ok {
+		return
+	}
+
+	var req agent.CheckInRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		writeAgentError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	remoteAddr, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteAddr = r.RemoteAddr
+	}
+	response, err := h.agentService.CheckIn(a, req, remoteAddr)
+	if err != nil {
+		writeAgentServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// APIAgentReport takes the devices an agent found on one of its networks
+func (h *WebHandler) APIAgentReport(w http.ResponseWriter, r *http.Request) {
+	a, ok := h.authenticateAgent(w, r)
+	if !ok {
+		return
+	}
+
+	var report agent.Report
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAgentReportSize)).Decode(&report); err != nil {
+		writeAgentError(w, http.StatusBadRequest, "Invalid report")
+		return
+	}
+
+	response, err := h.agentService.IngestReport(a, report)
+	if err != nil {
+		writeAgentServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// APIAgents lists the remote sensor agents with their health and last check-in
+func (h *WebHandler) APIAgents(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	agents, err := h.agentService.List()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load agents: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if agents == nil {
+		agents = []*models.Agent{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"agents":  agents,
+	})
+}
+
+// APISetAgentNetworks assigns the networks given in network_ids to an agent,
+// replacing its previous assignment
+func (h *WebHandler) APISetAgentNetworks(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	a, err := h.agentService.AssignNetworks(mux.Vars(r)["id"], formList(r, "network_ids"))
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to assign networks: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"agent":   a,
+	})
+}
+
+// APIDeleteAgent revokes an agent
+func (h *WebHandler) APIDeleteAgent(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err := h.agentService.Revoke(mux.Vars(r)["id"])
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to revoke agent: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// authenticateAgent identifies the agent behind a request, answering 401 when the
+// request carries neither a trusted client certificate nor a valid API key
+func (h *WebHandler) authenticateAgent(w http.ResponseWriter, r *http.Request) (*models.Agent, bool) {
+	apiKey := ""
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		apiKey = strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	}
+
+	a, err := h.agentService.Authenticate(agentCertificate(r), apiKey)
+	if err != nil {
+		writeAgentServiceError(w, err)
+		return nil, false
+	}
+	return a, true
+}
+
+// agentCertificate returns the client certificate of a request when it was verified
+// against the agent CA
+func agentCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+func writeAgentServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, agent.ErrUnauthorized):
+		writeAgentError(w, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, agent.ErrEnrollmentDisabled), errors.Is(err, agent.ErrNetworkNotAssigned):
+		writeAgentError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, agent.ErrInvalidName):
+		writeAgentError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeAgentError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func writeAgentError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"error":   message,
+	})
+}
@@ -0,0 +1,54 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// APIEffectiveConfig returns the configuration in use after the file, environment
+// and defaults are merged, with secrets redacted
+func (h *WebHandler) APIEffectiveConfig(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"config_file": h.config.ConfigFile,
+		"config":      h.config.Effective(),
+	})
+}
+
+// APIReloadConfig reads the configuration file and environment again, as SIGHUP
+// does. An invalid configuration is rejected and the running one kept.
+func (h *WebHandler) APIReloadConfig(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	result, err := h.config.Reload()
+	if err != nil {
+		logger.Errorf("Configuration reload by %s failed: %v", user.Username, err)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.Infof("Configuration reloaded by %s, applied: %v, restart required: %v",
+		user.Username, result.Applied, result.RestartRequired)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":          true,
+		"applied":          result.Applied,
+		"restart_required": result.RestartRequired,
+	})
+}
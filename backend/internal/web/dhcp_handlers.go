@@ -0,0 +1,116 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"reconya-ai/db"
+	"reconya-ai/models"
+
+	"github.com/gorilla/mux"
+)
+
+// APINetworkDHCP returns the DHCP allowlist of a network, the legitimate server last
+// seen and the result of the latest probe
+func (h *WebHandler) APINetworkDHCP(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	networkID := mux.Vars(r)["id"]
+	network, err := h.networkService.FindByID(networkID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load network: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if network == nil {
+		http.Error(w, "Network not found", http.StatusNotFound)
+		return
+	}
+
+	allowlist := network.DHCPAllowlist
+	if allowlist == nil {
+		allowlist = []models.DHCPExpectedServer{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"allowlist":  allowlist,
+		"server":     network.DHCPServer,
+		"last_probe": h.dhcpService.LastResult(networkID),
+	})
+}
+
+// APISetNetworkDHCPAllowlist replaces the expected DHCP servers of a network with
+// {"servers": [{"server_ip": "192.168.1.1", "routers": ["192.168.1.1"], ...}]}
+func (h *WebHandler) APISetNetworkDHCPAllowlist(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var data struct {
+		Servers []models.DHCPExpectedServer `json:"servers"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	err := h.dhcpService.SetAllowlist(mux.Vars(r)["id"], data.Servers)
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, "Network not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to update DHCP allowlist: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("DHCP allowlist set to %d servers", len(data.Servers)),
+	})
+}
+
+// APIProbeNetworkDHCP sends a DHCP DISCOVER on a network now and returns the offers
+func (h *WebHandler) APIProbeNetworkDHCP(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	result, err := h.dhcpService.Probe(mux.Vars(r)["id"])
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, "Network not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("DHCP probe failed: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"result":  result,
+	})
+}
@@ -0,0 +1,167 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"reconya-ai/db"
+	"reconya-ai/models"
+
+	"github.com/gorilla/mux"
+)
+
+// APIExclusions lists the exclusion rules, oldest first
+func (h *WebHandler) APIExclusions(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	rules, err := h.exclusionService.FindAll()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load exclusion rules: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if rules == nil {
+		rules = []*models.ExclusionRule{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"rules":   rules,
+	})
+}
+
+// APICreateExclusion adds an exclusion rule, audited under the signed in user
+func (h *WebHandler) APICreateExclusion(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var rule models.ExclusionRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	saved, err := h.exclusionService.Create(&rule, user.Username)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to create exclusion rule: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"rule":    saved,
+	})
+}
+
+// APIUpdateExclusion replaces an exclusion rule, audited under the signed in user
+func (h *WebHandler) APIUpdateExclusion(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var rule models.ExclusionRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	saved, err := h.exclusionService.Update(mux.Vars(r)["id"], &rule, user.Username)
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, "Exclusion rule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to update exclusion rule: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"rule":    saved,
+	})
+}
+
+// APIDeleteExclusion deletes an exclusion rule, its audit trail is kept
+func (h *WebHandler) APIDeleteExclusion(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err := h.exclusionService.Delete(mux.Vars(r)["id"], user.Username)
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, "Exclusion rule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to delete exclusion rule: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Exclusion rule deleted successfully",
+	})
+}
+
+// APIExclusionAudit lists who changed the rules and what they blocked, most
+// recent first, for one rule with ?rule_id= or for all of them
+func (h *WebHandler) APIExclusionAudit(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit := 0
+	if value, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && value > 0 && value <= 1000 {
+		limit = value
+	}
+	entries, err := h.exclusionService.FindAudit(r.URL.Query().Get("rule_id"), limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load the exclusion audit: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []*models.ExclusionAuditEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"entries": entries,
+	})
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"html/template"
-	"log"
 	"math"
 	"net"
 	"net/http"
@@ -14,20 +13,37 @@ import (
 	"time"
 
 	"reconya-ai/db"
+	"reconya-ai/internal/adhoc"
+	"reconya-ai/internal/agent"
 	"reconya-ai/internal/config"
 	"reconya-ai/internal/device"
+	"reconya-ai/internal/devicemerge"
+	"reconya-ai/internal/dhcp"
 	"reconya-ai/internal/eventlog"
+	"reconya-ai/internal/exclusion"
+	"reconya-ai/internal/inventory"
+	"reconya-ai/internal/jobqueue"
+	"reconya-ai/internal/logging"
 	"reconya-ai/internal/network"
 	"reconya-ai/internal/nicidentifier"
+	"reconya-ai/internal/rescan"
+	"reconya-ai/internal/scanprofile"
 	"reconya-ai/internal/scan"
 	"reconya-ai/internal/settings"
+	"reconya-ai/internal/snmp"
+	"reconya-ai/internal/supervisor"
 	"reconya-ai/internal/systemstatus"
+	"reconya-ai/internal/topology"
+	"reconya-ai/internal/trust"
+	"reconya-ai/internal/wol"
 	"reconya-ai/models"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
 )
 
+var logger = logging.For("web")
+
 // Templates will be loaded from filesystem for now
 // TODO: Embed templates in production build
 
@@ -40,6 +56,20 @@ type WebHandler struct {
 	geolocationRepository *db.GeolocationRepository
 	settingsService       *settings.SettingsService
 	nicIdentifierService  *nicidentifier.NicIdentifierService
+	snmpService           *snmp.SNMPService
+	topologyService       *topology.TopologyService
+	wolService            *wol.WakeOnLANService
+	inventoryService      *inventory.InventoryService
+	trustService          *trust.TrustService
+	deviceMergeService    *devicemerge.DeviceMergeService
+	dhcpService           *dhcp.DHCPService
+	agentService          *agent.AgentService
+	jobQueue              *jobqueue.JobQueueService
+	rescanService         *rescan.RescanService
+	adhocScanService      *adhoc.AdhocScanService
+	scanProfileService    *scanprofile.ScanProfileService
+	exclusionService      *exclusion.ExclusionService
+	supervisor            *supervisor.Supervisor
 	templates             *template.Template
 	sessionStore          *sessions.CookieStore
 	config                *config.Config
@@ -89,6 +119,20 @@ func NewWebHandler(
 	geolocationRepository *db.GeolocationRepository,
 	settingsService *settings.SettingsService,
 	nicIdentifierService *nicidentifier.NicIdentifierService,
+	snmpService *snmp.SNMPService,
+	topologyService *topology.TopologyService,
+	wolService *wol.WakeOnLANService,
+	inventoryService *inventory.InventoryService,
+	trustService *trust.TrustService,
+	deviceMergeService *devicemerge.DeviceMergeService,
+	dhcpService *dhcp.DHCPService,
+	agentService *agent.AgentService,
+	jobQueue *jobqueue.JobQueueService,
+	rescanService *rescan.RescanService,
+	adhocScanService *adhoc.AdhocScanService,
+	scanProfileService *scanprofile.ScanProfileService,
+	exclusionService *exclusion.ExclusionService,
+	supervisor *supervisor.Supervisor,
 	config *config.Config,
 	sessionSecret string,
 ) *WebHandler {
@@ -191,6 +235,14 @@ func NewWebHandler(
 				return len(v)
 			case []*models.EventLog:
 				return len(v)
+			case []models.UPnPService:
+				return len(v)
+			case []models.UPnPPortMapping:
+				return len(v)
+			case []models.SNMPInterface:
+				return len(v)
+			case []models.SNMPNeighbor:
+				return len(v)
 			}
 			return 0
 		},
@@ -366,7 +418,7 @@ func NewWebHandler(
 
 	files := append(baseFiles, componentFiles...)
 	files = append(files, indexFile)
-	log.Printf("Found template files: %v", files)
+	logger.Debugf("Found template files: %v", files)
 
 	if len(files) == 0 {
 		panic("No template files found")
@@ -379,15 +431,15 @@ func NewWebHandler(
 
 	// Log template names for debugging
 	for _, t := range tmpl.Templates() {
-		log.Printf("Loaded template: %s", t.Name())
+		logger.Debugf("Loaded template: %s", t.Name())
 	}
 
 	// Debug: Try to find login.html specifically
 	loginTmpl := tmpl.Lookup("login.html")
 	if loginTmpl != nil {
-		log.Printf("Found login.html template: %s", loginTmpl.Name())
+		logger.Debugf("Found login.html template: %s", loginTmpl.Name())
 	} else {
-		log.Printf("ERROR: login.html template not found!")
+		logger.Errorf("login.html template not found")
 	}
 
 	store := sessions.NewCookieStore([]byte(sessionSecret))
@@ -408,6 +460,20 @@ func NewWebHandler(
 		geolocationRepository: geolocationRepository,
 		settingsService:       settingsService,
 		nicIdentifierService:  nicIdentifierService,
+		snmpService:           snmpService,
+		topologyService:       topologyService,
+		wolService:            wolService,
+		inventoryService:      inventoryService,
+		trustService:          trustService,
+		deviceMergeService:    deviceMergeService,
+		dhcpService:           dhcpService,
+		agentService:          agentService,
+		jobQueue:              jobQueue,
+		rescanService:         rescanService,
+		adhocScanService:      adhocScanService,
+		scanProfileService:    scanProfileService,
+		exclusionService:      exclusionService,
+		supervisor:            supervisor,
 		templates:             tmpl,
 		sessionStore:          store,
 		config:                config,
@@ -441,7 +507,7 @@ func (h *WebHandler) ServePage(pageName string) http.HandlerFunc {
 		}
 
 		if err := h.templates.ExecuteTemplate(w, "index.html", data); err != nil {
-			log.Printf("%s template execution error: %v", pageName, err)
+			logger.Errorf("%s template execution error: %v", pageName, err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 	}
@@ -470,14 +536,14 @@ func (h *WebHandler) Home(w http.ResponseWriter, r *http.Request) {
 	// Get system status from service
 	status, err := h.systemStatusService.GetLatest()
 	if err != nil {
-		log.Printf("Error getting system status for home page: %v", err)
+		logger.Errorf("Error getting system status for home page: %v", err)
 		// Fallback to mock data or handle gracefully
 		status = &models.SystemStatus{
 			NetworkID: "N/A",
 			PublicIP:  nil,
 		}
 	} else if status == nil {
-		log.Printf("No system status found in database for home page, using fallback")
+		logger.Infof("No system status found in database for home page, using fallback")
 		status = &models.SystemStatus{
 			NetworkID: "N/A",
 			PublicIP:  nil,
@@ -491,11 +557,11 @@ func (h *WebHandler) Home(w http.ResponseWriter, r *http.Request) {
 	var networkCIDR string = "N/A"
 
 	if currentNetwork != nil {
-		log.Printf("Home: currentNetwork is not nil, ID: %s", currentNetwork.ID)
+		logger.Debugf("Home: currentNetwork is not nil, ID: %s", currentNetwork.ID)
 		// Show devices from the currently selected/scanning network
 		devicesSlice, err := h.deviceService.FindByNetworkID(currentNetwork.ID)
 		if err != nil {
-			log.Printf("Error getting devices for home page system status %s: %v", currentNetwork.ID, err)
+			logger.Errorf("Error getting devices for home page system status %s: %v", currentNetwork.ID, err)
 			devices = []*models.Device{}
 		} else {
 			// Convert []models.Device to []*models.Device
@@ -506,11 +572,11 @@ func (h *WebHandler) Home(w http.ResponseWriter, r *http.Request) {
 		}
 		networkCIDR = currentNetwork.CIDR
 	} else {
-		log.Println("Home: currentNetwork is nil, falling back to all devices")
+		logger.Debugf("Home: currentNetwork is nil, falling back to all devices")
 		// If no network is selected, show all devices
 		devices, err = h.deviceService.FindAll()
 		if err != nil {
-			log.Printf("Error getting all devices for home page system status: %v", err)
+			logger.Errorf("Error getting all devices for home page system status: %v", err)
 			devices = []*models.Device{}
 		}
 	}
@@ -528,7 +594,7 @@ func (h *WebHandler) Home(w http.ResponseWriter, r *http.Request) {
 	// Get recent event logs
 	eventLogSlice, err := h.eventLogService.GetAll(20)
 	if err != nil {
-		log.Printf("Error getting event logs for home page: %v", err)
+		logger.Errorf("Error getting event logs for home page: %v", err)
 		eventLogSlice = []models.EventLog{} // Ensure it's an empty slice, not nil
 	}
 
@@ -541,7 +607,7 @@ func (h *WebHandler) Home(w http.ResponseWriter, r *http.Request) {
 	// Get networks list
 	networksSlice, err := h.networkService.FindAll()
 	if err != nil {
-		log.Printf("Error getting networks for home page: %v", err)
+		logger.Errorf("Error getting networks for home page: %v", err)
 		networksSlice = []models.Network{} // Ensure it's an empty slice, not nil
 	}
 
@@ -585,7 +651,7 @@ func (h *WebHandler) About(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.templates.ExecuteTemplate(w, "components/about.html", data); err != nil {
-		log.Printf("About template execution error: %v", err)
+		logger.Errorf("About template execution error: %v", err)
 		http.Error(w, fmt.Sprintf("Template error: %v", err), http.StatusInternalServerError)
 	}
 }
@@ -615,7 +681,7 @@ func (h *WebHandler) Login(w http.ResponseWriter, r *http.Request) {
 		// Use standalone login template to avoid conflicts
 		loginTmpl, err := template.ParseFiles("templates/standalone/login.html")
 		if err != nil {
-			log.Printf("Failed to parse standalone login template: %v", err)
+			logger.Errorf("Failed to parse standalone login template: %v", err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -630,7 +696,7 @@ func (h *WebHandler) Login(w http.ResponseWriter, r *http.Request) {
 			Username: "",
 		}
 		if err := loginTmpl.Execute(w, data); err != nil {
-			log.Printf("Template execution error: %v", err)
+			logger.Errorf("Template execution error: %v", err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 		return
@@ -674,10 +740,10 @@ func (h *WebHandler) Logout(w http.ResponseWriter, r *http.Request) {
 
 // API Handlers for HTMX
 func (h *WebHandler) APIDevices(w http.ResponseWriter, r *http.Request) {
-	log.Printf("APIDevices: Request received from %s", r.RemoteAddr)
+	logger.Debugf("APIDevices: Request received from %s", r.RemoteAddr)
 	session, _ := h.sessionStore.Get(r, "reconya-session")
 	user := h.getUserFromSession(session)
-	log.Printf("APIDevices: User session: %v", user != nil)
+	logger.Debugf("APIDevices: User session: %v", user != nil)
 	if user == nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusUnauthorized)
@@ -697,7 +763,7 @@ func (h *WebHandler) APIDevices(w http.ResponseWriter, r *http.Request) {
 		// Show devices from the currently selected/scanning network
 		devicesSlice, err = h.deviceService.FindByNetworkID(currentNetwork.ID)
 		if err != nil {
-			log.Printf("Error getting devices for network %s: %v", currentNetwork.ID, err)
+			logger.Errorf("Error getting devices for network %s: %v", currentNetwork.ID, err)
 			devicesSlice = []models.Device{}
 		}
 	} else {
@@ -710,15 +776,16 @@ func (h *WebHandler) APIDevices(w http.ResponseWriter, r *http.Request) {
 	for i := range devicesSlice {
 		devices[i] = &devicesSlice[i]
 	}
+	devices = h.annotateAndFilter(r, devices)
 
 	// Get user's screenshot setting
 	screenshotsEnabled := h.settingsService.AreScreenshotsEnabled(fmt.Sprintf("%d", user.ID))
 
 	viewMode := r.URL.Query().Get("view")
 
-	log.Printf("APIDevices: Found %d devices, viewMode: %s", len(devices), viewMode)
+	logger.Debugf("APIDevices: Found %d devices, viewMode: %s", len(devices), viewMode)
 	if len(devices) > 0 {
-		log.Printf("First device: ID=%s, IPv4=%s, Status=%s", devices[0].ID, devices[0].IPv4, devices[0].Status)
+		logger.Debugf("First device: ID=%s, IPv4=%s, Status=%s", devices[0].ID, devices[0].IPv4, devices[0].Status)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -728,7 +795,7 @@ func (h *WebHandler) APIDevices(w http.ResponseWriter, r *http.Request) {
 		"screenshotsEnabled": screenshotsEnabled,
 	}
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Failed to encode JSON response in APIDevices: %v", err)
+		logger.Errorf("Failed to encode JSON response in APIDevices: %v", err)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -760,11 +827,15 @@ func (h *WebHandler) APIDeviceModal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.inventoryService.Annotate([]*models.Device{device}); err != nil {
+		logger.Errorf("Failed to load inventory data for device %s: %v", device.ID, err)
+	}
+
 	// Get user's screenshot setting
 	screenshotsEnabled := h.settingsService.AreScreenshotsEnabled(fmt.Sprintf("%d", user.ID))
 
 	// Debug logging for IPv6 fields
-	log.Printf("Device %s IPv6 data: LinkLocal=%v, UniqueLocal=%v, Global=%v, Addresses=%v", 
+	logger.Debugf("Device %s IPv6 data: LinkLocal=%v, UniqueLocal=%v, Global=%v, Addresses=%v", 
 		device.ID, device.IPv6LinkLocal, device.IPv6UniqueLocal, device.IPv6Global, device.IPv6Addresses)
 
 	// Return JSON response
@@ -799,7 +870,7 @@ func (h *WebHandler) APIUpdateDevice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Updating device %s: name='%s', comment='%s'", deviceID, data.Name, data.Comment)
+	logger.Infof("Updating device %s: name='%s', comment='%s'", deviceID, data.Name, data.Comment)
 
 	var namePtr, commentPtr *string
 	if data.Name != "" {
@@ -811,12 +882,12 @@ func (h *WebHandler) APIUpdateDevice(w http.ResponseWriter, r *http.Request) {
 
 	device, err := h.deviceService.UpdateDevice(deviceID, namePtr, commentPtr)
 	if err != nil {
-		log.Printf("Failed to update device %s: %v", deviceID, err)
+		logger.Errorf("Failed to update device %s: %v", deviceID, err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("Successfully updated device %s", deviceID)
+	logger.Infof("Successfully updated device %s", deviceID)
 
 	// Return JSON response
 	w.Header().Set("Content-Type", "application/json")
@@ -861,7 +932,7 @@ func (h *WebHandler) APIDeleteDevice(w http.ResponseWriter, r *http.Request) {
 	// Delete the device
 	err = h.deviceService.Delete(deviceID)
 	if err != nil {
-		log.Printf("Failed to delete device %s: %v", deviceID, err)
+		logger.Errorf("Failed to delete device %s: %v", deviceID, err)
 		http.Error(w, fmt.Sprintf("Failed to delete device: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -869,7 +940,7 @@ func (h *WebHandler) APIDeleteDevice(w http.ResponseWriter, r *http.Request) {
 	// Log the event
 	h.eventLogService.Log(models.DeviceDeleted, fmt.Sprintf("Device %s deleted", device.IPv4), "")
 
-	log.Printf("Successfully deleted device %s (%s)", device.IPv4, deviceID)
+	logger.Infof("Successfully deleted device %s (%s)", device.IPv4, deviceID)
 
 	// Return empty response to remove the table row
 	w.WriteHeader(http.StatusOK)
@@ -905,8 +976,32 @@ func (h *WebHandler) APITestIPv6(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("IPv6 addresses added successfully"))
 }
 
+// APIDeviceIPv6Addresses lists the IPv6 addresses recorded for a device with when
+// they were first and last seen
+func (h *WebHandler) APIDeviceIPv6Addresses(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	deviceID := mux.Vars(r)["id"]
+	addresses, err := h.deviceService.GetIPv6Addresses(deviceID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load IPv6 addresses: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"addresses": addresses,
+	})
+}
+
 func (h *WebHandler) APISystemStatus(w http.ResponseWriter, r *http.Request) {
-	log.Println("APISystemStatus called")
+	logger.Debugf("APISystemStatus called")
 	session, _ := h.sessionStore.Get(r, "reconya-session")
 	user := h.getUserFromSession(session)
 	if user == nil {
@@ -917,21 +1012,21 @@ func (h *WebHandler) APISystemStatus(w http.ResponseWriter, r *http.Request) {
 	// Get system status from service
 	status, err := h.systemStatusService.GetLatest()
 	if err != nil {
-		log.Printf("Error getting system status: %v", err)
+		logger.Errorf("Error getting system status: %v", err)
 		// If service fails, create mock data for now
 		status = &models.SystemStatus{
 			NetworkID: "N/A",
 			PublicIP:  nil,
 		}
 	} else if status == nil {
-		log.Printf("No system status found in database, using fallback")
+		logger.Debugf("No system status found in database, using fallback")
 		// If no system status exists yet, create mock data
 		status = &models.SystemStatus{
 			NetworkID: "N/A",
 			PublicIP:  nil,
 		}
 	} else {
-		log.Printf("SystemStatus found: NetworkID=%s", status.NetworkID)
+		logger.Debugf("SystemStatus found: NetworkID=%s", status.NetworkID)
 	}
 
 	// Get current or selected network to determine which network to show
@@ -941,11 +1036,11 @@ func (h *WebHandler) APISystemStatus(w http.ResponseWriter, r *http.Request) {
 	var networkCIDR string = "N/A"
 
 	if currentNetwork != nil {
-		log.Printf("APISystemStatus: currentNetwork is not nil, ID: %s", currentNetwork.ID)
+		logger.Debugf("APISystemStatus: currentNetwork is not nil, ID: %s", currentNetwork.ID)
 		// Show devices from the currently selected/scanning network
 		devicesSlice, err := h.deviceService.FindByNetworkID(currentNetwork.ID)
 		if err != nil {
-			log.Printf("Error getting devices for system status %s: %v", currentNetwork.ID, err)
+			logger.Errorf("Error getting devices for system status %s: %v", currentNetwork.ID, err)
 			devices = []*models.Device{}
 		} else {
 			// Convert []models.Device to []*models.Device
@@ -956,11 +1051,11 @@ func (h *WebHandler) APISystemStatus(w http.ResponseWriter, r *http.Request) {
 		}
 		networkCIDR = currentNetwork.CIDR
 	} else {
-		log.Println("APISystemStatus: currentNetwork is nil, falling back to all devices")
+		logger.Debugf("APISystemStatus: currentNetwork is nil, falling back to all devices")
 		// If no network is selected, show all devices
 		devices, err = h.deviceService.FindAll()
 		if err != nil {
-			log.Printf("Error getting all devices for system status: %v", err)
+			logger.Errorf("Error getting all devices for system status: %v", err)
 			devices = []*models.Device{}
 		}
 	}
@@ -975,11 +1070,11 @@ func (h *WebHandler) APISystemStatus(w http.ResponseWriter, r *http.Request) {
 		ScanState:    &scanState,
 	}
 
-	log.Printf("APISystemStatus: returning data: %+v", data)
+	logger.Debugf("APISystemStatus: returning data: %+v", data)
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		log.Printf("Error encoding system status JSON: %v", err)
+		logger.Errorf("Error encoding system status JSON: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
@@ -1066,7 +1161,7 @@ func (h *WebHandler) APINetworkMap(w http.ResponseWriter, r *http.Request) {
 		// Show devices from the currently selected/scanning network
 		devicesSlice, err = h.deviceService.FindByNetworkID(currentNetwork.ID)
 		if err != nil {
-			log.Printf("Error getting devices for network map %s: %v", currentNetwork.ID, err)
+			logger.Errorf("Error getting devices for network map %s: %v", currentNetwork.ID, err)
 			devicesSlice = []models.Device{}
 		}
 	} else {
@@ -1119,19 +1214,16 @@ func (h *WebHandler) buildNetworkMap(devices []*models.Device) *NetworkMapData {
 		}
 	}
 
-	// Parse network CIDR from current scan network
+	// Parse network CIDR from the selected or scanning network, falling back to the
+	// network most of the devices belong to. Without either the map stays empty.
 	var baseIP string
 	var ipRange []int
-	currentNetwork := h.scanManager.GetCurrentNetwork()
+	currentNetwork := h.scanManager.GetSelectedOrCurrentNetwork()
+	if currentNetwork == nil {
+		currentNetwork = h.predominantNetwork(devices)
+	}
 	if currentNetwork != nil {
 		baseIP, ipRange = h.parseNetworkCIDR(currentNetwork.CIDR)
-	} else {
-		// Fallback if no network is selected
-		baseIP = "192.168.1"
-		ipRange = make([]int, 254)
-		for i := range ipRange {
-			ipRange[i] = i + 1
-		}
 	}
 
 	return &NetworkMapData{
@@ -1146,24 +1238,43 @@ func (h *WebHandler) buildNetworkMap(devices []*models.Device) *NetworkMapData {
 	}
 }
 
-// parseNetworkCIDR parses a CIDR string and returns base IP and host range
-func (h *WebHandler) parseNetworkCIDR(cidr string) (string, []int) {
-	// Default fallback
-	defaultBaseIP := "192.168.1"
-	defaultRange := make([]int, 254)
-	for i := 1; i <= 254; i++ {
-		defaultRange[i-1] = i
+// predominantNetwork returns the network most of the given devices belong to
+func (h *WebHandler) predominantNetwork(devices []*models.Device) *models.Network {
+	counts := make(map[string]int)
+	bestID := ""
+	for _, device := range devices {
+		if device.NetworkID == "" {
+			continue
+		}
+		counts[device.NetworkID]++
+		if counts[device.NetworkID] > counts[bestID] {
+			bestID = device.NetworkID
+		}
 	}
+	if bestID == "" {
+		return nil
+	}
+
+	network, err := h.networkService.FindByID(bestID)
+	if err != nil {
+		logger.Errorf("Error loading network %s for network map: %v", bestID, err)
+		return nil
+	}
+	return network
+}
 
+// parseNetworkCIDR parses a CIDR string and returns base IP and host range.
+// An empty base IP and range are returned when the CIDR cannot be mapped.
+func (h *WebHandler) parseNetworkCIDR(cidr string) (string, []int) {
 	if cidr == "" {
-		return defaultBaseIP, defaultRange
+		return "", nil
 	}
 
 	// Parse CIDR
 	_, ipNet, err := net.ParseCIDR(cidr)
 	if err != nil {
-		log.Printf("Error parsing CIDR %s: %v", cidr, err)
-		return defaultBaseIP, defaultRange
+		logger.Errorf("Error parsing CIDR %s: %v", cidr, err)
+		return "", nil
 	}
 
 	// Get network address
@@ -1172,8 +1283,8 @@ func (h *WebHandler) parseNetworkCIDR(cidr string) (string, []int) {
 	// Calculate subnet mask bits
 	ones, bits := ipNet.Mask.Size()
 	if bits != 32 {
-		log.Printf("Invalid network mask in CIDR %s", cidr)
-		return defaultBaseIP, defaultRange
+		logger.Warnf("Invalid network mask in CIDR %s", cidr)
+		return "", nil
 	}
 
 	// Calculate number of host addresses
@@ -1189,7 +1300,7 @@ func (h *WebHandler) parseNetworkCIDR(cidr string) (string, []int) {
 	// Generate base IP (network portion)
 	parts := strings.Split(networkIP.String(), ".")
 	if len(parts) < 3 {
-		return defaultBaseIP, defaultRange
+		return "", nil
 	}
 
 	// For /23 networks (like 192.168.10.0/23), we need to handle the range properly
@@ -1304,7 +1415,7 @@ func (h *WebHandler) APIDeviceList(w http.ResponseWriter, r *http.Request) {
 
 	devices, err := h.deviceService.FindAll()
 	if err != nil {
-		log.Printf("Failed to get devices: %v", err)
+		logger.Errorf("Failed to get devices: %v", err)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -1313,6 +1424,7 @@ func (h *WebHandler) APIDeviceList(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	devices = h.annotateAndFilter(r, devices)
 
 	// Get user's screenshot setting
 	screenshotsEnabled := h.settingsService.AreScreenshotsEnabled(fmt.Sprintf("%d", user.ID))
@@ -1325,7 +1437,7 @@ func (h *WebHandler) APIDeviceList(w http.ResponseWriter, r *http.Request) {
 		"success":           true,
 	}
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Failed to encode JSON response in APIDeviceList: %v", err)
+		logger.Errorf("Failed to encode JSON response in APIDeviceList: %v", err)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -1346,7 +1458,7 @@ func (h *WebHandler) APICleanupNetworkBroadcastDevices(w http.ResponseWriter, r
 
 	err := h.deviceService.CleanupNetworkBroadcastDevices()
 	if err != nil {
-		log.Printf("Error cleaning up network/broadcast devices: %v", err)
+		logger.Errorf("Error cleaning up network/broadcast devices: %v", err)
 		http.Error(w, "Failed to cleanup network/broadcast devices", http.StatusInternalServerError)
 		return
 	}
@@ -1370,7 +1482,7 @@ func (h *WebHandler) APICleanupDeviceNames(w http.ResponseWriter, r *http.Reques
 
 	err := h.deviceService.CleanupAllDeviceNames()
 	if err != nil {
-		log.Printf("Device name cleanup failed: %v", err)
+		logger.Errorf("Device name cleanup failed: %v", err)
 		http.Error(w, fmt.Sprintf("Cleanup failed: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -1388,15 +1500,15 @@ func (h *WebHandler) APINetworks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("APINetworks: Fetching networks for display")
+	logger.Debugf("APINetworks: Fetching networks for display")
 	// Get all networks from service
 	networksSlice, err := h.networkService.FindAll()
 	if err != nil {
-		log.Printf("APINetworks: Error getting networks: %v", err)
+		logger.Errorf("APINetworks: Error getting networks: %v", err)
 		networksSlice = []models.Network{} // Ensure it's an empty slice, not nil
 	}
 	
-	log.Printf("APINetworks: Retrieved %d networks from service", len(networksSlice))
+	logger.Debugf("APINetworks: Retrieved %d networks from service", len(networksSlice))
 
 	// Convert to pointer slice for template
 	networks := make([]*models.Network, len(networksSlice))
@@ -1416,7 +1528,7 @@ func (h *WebHandler) APINetworks(w http.ResponseWriter, r *http.Request) {
 		"scanState": scanState,
 	}
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Error encoding networks JSON: %v", err)
+		logger.Errorf("Error encoding networks JSON: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
@@ -1470,7 +1582,7 @@ func (h *WebHandler) APICreateNetwork(w http.ResponseWriter, r *http.Request) {
 	cidr := strings.TrimSpace(r.FormValue("cidr"))
 	description := strings.TrimSpace(r.FormValue("description"))
 	
-	log.Printf("APICreateNetwork: Received request - name=%s, cidr=%s, description=%s", name, cidr, description)
+	logger.Debugf("APICreateNetwork: Received request - name=%s, cidr=%s, description=%s", name, cidr, description)
 
 	// Validate CIDR
 	if cidr == "" {
@@ -1496,10 +1608,10 @@ func (h *WebHandler) APICreateNetwork(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create network
-	log.Printf("APICreateNetwork: Calling networkService.Create")
+	logger.Debugf("APICreateNetwork: Calling networkService.Create")
 	network, err := h.networkService.Create(name, cidr, description)
 	if err != nil {
-		log.Printf("APICreateNetwork: Error creating network: %v", err)
+		logger.Errorf("APICreateNetwork: Error creating network: %v", err)
 		w.Header().Set("Content-Type", "application/json")
 		response := map[string]interface{}{
 			"success": false,
@@ -1508,7 +1620,21 @@ func (h *WebHandler) APICreateNetwork(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(response)
 		return
 	}
-	log.Printf("APICreateNetwork: Network created successfully: ID=%s, CIDR=%s", network.ID, network.CIDR)
+	logger.Infof("APICreateNetwork: Network created successfully: ID=%s, CIDR=%s", network.ID, network.CIDR)
+
+	// Optional IPv6 prefix makes the network dual-stack
+	if ipv6Prefix := strings.TrimSpace(r.FormValue("ipv6_prefix")); ipv6Prefix != "" {
+		network, err = h.networkService.SetIPv6Prefix(network.ID, ipv6Prefix)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			response := map[string]interface{}{
+				"success": false,
+				"error": fmt.Sprintf("Failed to set IPv6 prefix: %v", err),
+			}
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+	}
 
 	// Log the event
 	h.eventLogService.Log(models.NetworkCreated, fmt.Sprintf("Network %s (%s) created", network.CIDR, network.Name), "")
@@ -1580,6 +1706,20 @@ func (h *WebHandler) APIUpdateNetwork(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The IPv6 prefix is only changed when the form sends it, an empty value turns IPv6 off
+	if _, ok := r.Form["ipv6_prefix"]; ok {
+		network, err = h.networkService.SetIPv6Prefix(networkID, strings.TrimSpace(r.FormValue("ipv6_prefix")))
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			response := map[string]interface{}{
+				"success": false,
+				"error": fmt.Sprintf("Failed to set IPv6 prefix: %v", err),
+			}
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+	}
+
 	// Log the event
 	h.eventLogService.Log(models.NetworkUpdated, fmt.Sprintf("Network %s (%s) updated", network.CIDR, network.Name), "")
 
@@ -1659,6 +1799,11 @@ func (h *WebHandler) APIDeleteNetwork(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Remove SNMP credentials that reference the network
+	if err := h.snmpService.DeleteCredentialsForNetwork(networkID); err != nil {
+		logger.Errorf("Failed to delete SNMP credentials for network %s: %v", networkID, err)
+	}
+
 	// Delete network
 	err = h.networkService.Delete(networkID)
 	if err != nil {
@@ -1728,7 +1873,7 @@ func (h *WebHandler) APINetworkDeleteInfo(w http.ResponseWriter, r *http.Request
 	// Get devices for this network to show in confirmation
 	devices, err := h.deviceService.FindByNetworkID(networkID)
 	if err != nil {
-		log.Printf("Error fetching devices for network %s: %v", networkID, err)
+		logger.Errorf("Error fetching devices for network %s: %v", networkID, err)
 		devices = []models.Device{} // Empty slice if error
 	}
 
@@ -1802,7 +1947,12 @@ func (h *WebHandler) APIForceDeleteNetwork(w http.ResponseWriter, r *http.Reques
 			http.Error(w, fmt.Sprintf("Failed to delete network devices: %v", err), http.StatusInternalServerError)
 			return
 		}
-		log.Printf("Deleted %d devices from network %s before network deletion", deviceCount, networkID)
+		logger.Infof("Deleted %d devices from network %s before network deletion", deviceCount, networkID)
+	}
+
+	// Remove SNMP credentials that reference the network
+	if err := h.snmpService.DeleteCredentialsForNetwork(networkID); err != nil {
+		logger.Errorf("Failed to delete SNMP credentials for network %s: %v", networkID, err)
 	}
 
 	// Now delete the network
@@ -1867,7 +2017,7 @@ func (h *WebHandler) APINetworkDeleteModal(w http.ResponseWriter, r *http.Reques
 	// Get devices for this network to show in confirmation
 	devices, err := h.deviceService.FindByNetworkID(networkID)
 	if err != nil {
-		log.Printf("Error fetching devices for network %s: %v", networkID, err)
+		logger.Errorf("Error fetching devices for network %s: %v", networkID, err)
 		devices = []models.Device{} // Empty slice if error
 	}
 
@@ -1917,21 +2067,21 @@ func (h *WebHandler) APIScanStatus(w http.ResponseWriter, r *http.Request) {
 
 // APIScanStart starts scanning a network
 func (h *WebHandler) APIScanStart(w http.ResponseWriter, r *http.Request) {
-	log.Printf("APIScanStart: Request received, method=%s", r.Method)
+	logger.Debugf("APIScanStart: Request received, method=%s", r.Method)
 	
 	session, _ := h.sessionStore.Get(r, "reconya-session")
 	user := h.getUserFromSession(session)
 	if user == nil {
-		log.Printf("APIScanStart: Unauthorized access attempt")
+		logger.Warnf("APIScanStart: Unauthorized access attempt")
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	networkID := r.FormValue("network-selector")
-	log.Printf("APIScanStart: Network ID from form: '%s'", networkID)
+	logger.Debugf("APIScanStart: Network ID from form: '%s'", networkID)
 	
 	if networkID == "" {
-		log.Printf("APIScanStart: No network ID provided")
+		logger.Infof("APIScanStart: No network ID provided")
 		w.Header().Set("Content-Type", "application/json")
 		response := map[string]interface{}{
 			"success": false,
@@ -2039,7 +2189,7 @@ func (h *WebHandler) APIScanControl(w http.ResponseWriter, r *http.Request) {
 	// Get networks and scan state
 	networksSlice, err := h.networkService.FindAll()
 	if err != nil {
-		log.Printf("Error getting networks for scan control: %v", err)
+		logger.Errorf("Error getting networks for scan control: %v", err)
 		networksSlice = []models.Network{}
 	}
 
@@ -2051,7 +2201,7 @@ func (h *WebHandler) APIScanControl(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Error encoding scan control JSON: %v", err)
+		logger.Errorf("Error encoding scan control JSON: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
@@ -2072,7 +2222,7 @@ func (h *WebHandler) APIScanControlWithError(w http.ResponseWriter, r *http.Requ
 	// Get networks and scan state
 	networksSlice, err := h.networkService.FindAll()
 	if err != nil {
-		log.Printf("Error getting networks for scan control: %v", err)
+		logger.Errorf("Error getting networks for scan control: %v", err)
 		networksSlice = []models.Network{}
 	}
 
@@ -2089,7 +2239,7 @@ func (h *WebHandler) APIScanControlWithError(w http.ResponseWriter, r *http.Requ
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		log.Printf("Error encoding scan control JSON: %v", err)
+		logger.Errorf("Error encoding scan control JSON: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
@@ -2114,7 +2264,7 @@ func (h *WebHandler) APIDashboardMetrics(w http.ResponseWriter, r *http.Request)
 		// Show devices from the currently selected/scanning network
 		devicesSlice, err := h.deviceService.FindByNetworkID(currentNetwork.ID)
 		if err != nil {
-			log.Printf("Error getting devices for dashboard metrics %s: %v", currentNetwork.ID, err)
+			logger.Errorf("Error getting devices for dashboard metrics %s: %v", currentNetwork.ID, err)
 			devices = []*models.Device{}
 		} else {
 			// Convert []models.Device to []*models.Device
@@ -2128,7 +2278,7 @@ func (h *WebHandler) APIDashboardMetrics(w http.ResponseWriter, r *http.Request)
 		// If no network is selected, show all devices
 		devices, err = h.deviceService.FindAll()
 		if err != nil {
-			log.Printf("Error getting all devices for dashboard metrics: %v", err)
+			logger.Errorf("Error getting all devices for dashboard metrics: %v", err)
 			devices = []*models.Device{}
 		}
 	}
@@ -2141,29 +2291,29 @@ func (h *WebHandler) APIDashboardMetrics(w http.ResponseWriter, r *http.Request)
 	var location string = ""
 	if err == nil && status != nil && status.PublicIP != nil {
 		publicIP = *status.PublicIP
-		log.Printf("DEBUG: Got public IP: %s", publicIP)
+		logger.Debugf("Got public IP: %s", publicIP)
 
 		// If geolocation is missing, try to fetch it now
 		if status.Geolocation == nil {
-			log.Printf("DEBUG: Geolocation is nil, attempting to fetch for IP %s", publicIP)
+			logger.Debugf("Geolocation is nil, attempting to fetch for IP %s", publicIP)
 			geo, geoErr := h.systemStatusService.FetchGeolocation(publicIP)
 			if geoErr == nil && geo != nil {
-				log.Printf("DEBUG: Successfully fetched geolocation, updating SystemStatus")
+				logger.Debugf("Successfully fetched geolocation, updating SystemStatus")
 				status.Geolocation = geo
 				// Update the system status with geolocation
 				_, updateErr := h.systemStatusService.CreateOrUpdate(status)
 				if updateErr != nil {
-					log.Printf("ERROR: Failed to update SystemStatus with geolocation: %v", updateErr)
+					logger.Errorf("Failed to update SystemStatus with geolocation: %v", updateErr)
 				}
 			} else {
-				log.Printf("DEBUG: Failed to fetch geolocation: %v", geoErr)
+				logger.Debugf("Failed to fetch geolocation: %v", geoErr)
 			}
 		}
 
 		// Build location string from geolocation data
 		if status.Geolocation != nil {
 			geo := status.Geolocation
-			log.Printf("DEBUG: Geolocation found - City: %s, Region: %s, Country: %s", geo.City, geo.Region, geo.Country)
+			logger.Debugf("Geolocation found - City: %s, Region: %s, Country: %s", geo.City, geo.Region, geo.Country)
 			if geo.City != "" && geo.Country != "" {
 				location = geo.City + ", " + geo.Country
 			} else if geo.Country != "" {
@@ -2171,12 +2321,12 @@ func (h *WebHandler) APIDashboardMetrics(w http.ResponseWriter, r *http.Request)
 			} else if geo.Region != "" {
 				location = geo.Region
 			}
-			log.Printf("DEBUG: Final location string: %s", location)
+			logger.Debugf("Final location string: %s", location)
 		} else {
-			log.Printf("DEBUG: Geolocation is still nil for public IP %s", publicIP)
+			logger.Debugf("Geolocation is still nil for public IP %s", publicIP)
 		}
 	} else {
-		log.Printf("DEBUG: SystemStatus error or nil - err: %v, status: %v", err, status)
+		logger.Debugf("SystemStatus error or nil - err: %v, status: %v", err, status)
 	}
 
 	// Calculate network saturation
@@ -2207,7 +2357,7 @@ func (h *WebHandler) APIDashboardMetrics(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *WebHandler) APIScanSelectNetwork(w http.ResponseWriter, r *http.Request) {
-	log.Println("APIScanSelectNetwork called")
+	logger.Debugf("APIScanSelectNetwork called")
 	session, _ := h.sessionStore.Get(r, "reconya-session")
 	user := h.getUserFromSession(session)
 	if user == nil {
@@ -2221,7 +2371,7 @@ func (h *WebHandler) APIScanSelectNetwork(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	log.Printf("Setting selected network to: %s", networkID)
+	logger.Infof("Setting selected network to: %s", networkID)
 	err := h.scanManager.SetSelectedNetwork(networkID)
 	if err != nil {
 		if scanErr, ok := err.(*scan.ScanError); ok {
@@ -2237,7 +2387,7 @@ func (h *WebHandler) APIScanSelectNetwork(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	log.Println("APIScanSelectNetwork completed successfully")
+	logger.Debugf("APIScanSelectNetwork completed successfully")
 	w.Header().Set("HX-Trigger", "network-selected")
 	w.WriteHeader(http.StatusOK)
 }
@@ -2260,7 +2410,7 @@ func (h *WebHandler) APIAbout(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("About component JSON encoding error: %v", err)
+		logger.Errorf("About component JSON encoding error: %v", err)
 		http.Error(w, fmt.Sprintf("JSON encoding error: %v", err), http.StatusInternalServerError)
 	}
 }
@@ -2278,7 +2428,7 @@ func (h *WebHandler) APISettings(w http.ResponseWriter, r *http.Request) {
 	// Get user settings
 	settings, err := h.settingsService.GetUserSettings(fmt.Sprintf("%d", user.ID))
 	if err != nil {
-		log.Printf("Error getting user settings: %v", err)
+		logger.Errorf("Error getting user settings: %v", err)
 		http.Error(w, "Failed to load settings", http.StatusInternalServerError)
 		return
 	}
@@ -2289,7 +2439,7 @@ func (h *WebHandler) APISettings(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Error encoding settings JSON: %v", err)
+		logger.Errorf("Error encoding settings JSON: %v", err)
 		http.Error(w, "Failed to encode settings", http.StatusInternalServerError)
 		return
 	}
@@ -2313,7 +2463,7 @@ func (h *WebHandler) APISettingsScreenshots(w http.ResponseWriter, r *http.Reque
 	enabledStr := r.FormValue("screenshots_enabled")
 	enabled := enabledStr == "true" || enabledStr == "on"
 	
-	log.Printf("Screenshot settings update: enabled=%s, parsed=%v", enabledStr, enabled)
+	logger.Debugf("Screenshot settings update: enabled=%s, parsed=%v", enabledStr, enabled)
 
 	// Update settings
 	updates := map[string]interface{}{
@@ -2322,7 +2472,7 @@ func (h *WebHandler) APISettingsScreenshots(w http.ResponseWriter, r *http.Reque
 
 	_, err := h.settingsService.UpdateUserSettings(fmt.Sprintf("%d", user.ID), updates)
 	if err != nil {
-		log.Printf("Error updating screenshot settings: %v", err)
+		logger.Errorf("Error updating screenshot settings: %v", err)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -2332,7 +2482,7 @@ func (h *WebHandler) APISettingsScreenshots(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	log.Printf("Updated screenshot settings for user %d: enabled=%v", user.ID, enabled)
+	logger.Infof("Updated screenshot settings for user %d: enabled=%v", user.ID, enabled)
 	
 	// Return JSON success response
 	w.Header().Set("Content-Type", "application/json")
@@ -2393,7 +2543,7 @@ func (h *WebHandler) APINetworkSuggestion(w http.ResponseWriter, r *http.Request
 
 	network, err := h.networkService.Create(name, cidr, description)
 	if err != nil {
-		log.Printf("Failed to create suggested network %s: %v", cidr, err)
+		logger.Errorf("Failed to create suggested network %s: %v", cidr, err)
 		http.Error(w, fmt.Sprintf("Failed to create network: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -2401,7 +2551,7 @@ func (h *WebHandler) APINetworkSuggestion(w http.ResponseWriter, r *http.Request
 	// Log the event
 	h.eventLogService.Log(models.NetworkCreated, fmt.Sprintf("Network %s created from suggestion", cidr), "")
 
-	log.Printf("Created network from suggestion: %s (ID: %s)", cidr, network.ID)
+	logger.Infof("Created network from suggestion: %s (ID: %s)", cidr, network.ID)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -2457,7 +2607,7 @@ func (h *WebHandler) getVersionFromPackageJSON() string {
 
 	data, err := os.ReadFile(packageJSONPath)
 	if err != nil {
-		log.Printf("Error reading package.json: %v", err)
+		logger.Errorf("Error reading package.json: %v", err)
 		return "unknown"
 	}
 
@@ -2466,7 +2616,7 @@ func (h *WebHandler) getVersionFromPackageJSON() string {
 	}
 
 	if err := json.Unmarshal(data, &packageInfo); err != nil {
-		log.Printf("Error parsing package.json: %v", err)
+		logger.Errorf("Error parsing package.json: %v", err)
 		return "unknown"
 	}
 
@@ -0,0 +1,50 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"reconya-ai/internal/supervisor"
+)
+
+// Healthz is the liveness probe. It only fails once shutdown has started, failed
+// components are restarted by the supervisor itself.
+func (h *WebHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	status := "ok"
+	if h.supervisor == nil || !h.supervisor.Healthy() {
+		status = "stopping"
+	}
+	var components []supervisor.ComponentStatus
+	if h.supervisor != nil {
+		components = h.supervisor.Status(r.Context())
+	}
+	writeProbe(w, status == "ok", map[string]interface{}{
+		"status":     status,
+		"components": components,
+	})
+}
+
+// Readyz is the readiness probe. It passes when every component is running and
+// passes its check, the database answering for instance.
+func (h *WebHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	ready := false
+	var components []supervisor.ComponentStatus
+	if h.supervisor != nil {
+		ready, components = h.supervisor.Ready(r.Context())
+	}
+	writeProbe(w, ready, map[string]interface{}{
+		"ready":      ready,
+		"components": components,
+	})
+}
+
+func writeProbe(w http.ResponseWriter, ok bool, body map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if ok {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,356 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/inventory"
+	"reconya-ai/models"
+
+	"github.com/gorilla/mux"
+)
+
+// annotateAndFilter attaches tags, groups and custom fields to devices and applies
+// the ?tag=, ?group= and ?field.<name>= filters from the request
+func (h *WebHandler) annotateAndFilter(r *http.Request, devices []*models.Device) []*models.Device {
+	if err := h.inventoryService.Annotate(devices); err != nil {
+		logger.Errorf("Failed to load inventory data for devices: %v", err)
+		return devices
+	}
+	return inventory.ParseDeviceFilter(r.URL.Query()).Apply(devices)
+}
+
+// APITags lists all tags
+func (h *WebHandler) APITags(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tags, err := h.inventoryService.ListTags()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load tags: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"tags":    tags,
+	})
+}
+
+// APISaveTag creates a tag, or renames and recolors one when id is set
+func (h *WebHandler) APISaveTag(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var tag models.Tag
+	if err := json.NewDecoder(r.Body).Decode(&tag); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	saved, err := h.inventoryService.SaveTag(&tag)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to save tag: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"tag":     saved,
+	})
+}
+
+// APIDeleteTag deletes a tag and removes it from all devices
+func (h *WebHandler) APIDeleteTag(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.inventoryService.DeleteTag(mux.Vars(r)["id"]); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete tag: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Tag deleted successfully",
+	})
+}
+
+// APIGroups lists all device groups with their full paths
+func (h *WebHandler) APIGroups(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	groups, err := h.inventoryService.ListGroups()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load groups: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"groups":  groups,
+	})
+}
+
+// APICreateGroup creates the group at {"path": "Office/Floor 2/Printers"} along with any missing parents
+func (h *WebHandler) APICreateGroup(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var data struct {
+		Path string `json:"path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	group, err := h.inventoryService.EnsureGroup(data.Path)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to create group: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"group":   group,
+	})
+}
+
+// APIDeleteGroup deletes a group and all of its subgroups
+func (h *WebHandler) APIDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err := h.inventoryService.DeleteGroup(mux.Vars(r)["id"])
+	if err == db.ErrNotFound {
+		http.Error(w, "Group not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete group: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Group deleted successfully",
+	})
+}
+
+// APICustomFields lists the custom field definitions
+func (h *WebHandler) APICustomFields(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	fields, err := h.inventoryService.ListCustomFields()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load custom fields: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"fields":  fields,
+	})
+}
+
+// APISaveCustomField creates or updates a custom field definition
+func (h *WebHandler) APISaveCustomField(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var field models.CustomField
+	if err := json.NewDecoder(r.Body).Decode(&field); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	saved, err := h.inventoryService.SaveCustomField(&field)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to save custom field: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"field":   saved,
+	})
+}
+
+// APIDeleteCustomField deletes a custom field and its values on all devices
+func (h *WebHandler) APIDeleteCustomField(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.inventoryService.DeleteCustomField(mux.Vars(r)["id"]); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete custom field: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Custom field deleted successfully",
+	})
+}
+
+// APIBulkUpdateDevices adds or removes tags and groups and sets custom fields on many devices at once
+func (h *WebHandler) APIBulkUpdateDevices(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var update inventory.BulkUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.inventoryService.ApplyBulk(update); err != nil {
+		logger.Warnf("APIBulkUpdateDevices: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to update devices: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Updated %d devices", len(update.DeviceIDs)),
+	})
+}
+
+// APIExportDevices exports devices as CSV (default) or JSON with ?format=csv|json.
+// ?network_id= and the tag, group and custom field filters narrow the export.
+func (h *WebHandler) APIExportDevices(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "csv" && format != "json" {
+		http.Error(w, "Unsupported format, use csv or json", http.StatusBadRequest)
+		return
+	}
+
+	devices, err := h.deviceService.FindAll()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load devices: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if networkID := r.URL.Query().Get("network_id"); networkID != "" {
+		filtered := make([]*models.Device, 0, len(devices))
+		for _, d := range devices {
+			if d.NetworkID == networkID {
+				filtered = append(filtered, d)
+			}
+		}
+		devices = filtered
+	}
+
+	if err := h.inventoryService.Annotate(devices); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load inventory data: %v", err), http.StatusInternalServerError)
+		return
+	}
+	devices = inventory.ParseDeviceFilter(r.URL.Query()).Apply(devices)
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", "attachment; filename=reconya-devices.json")
+		json.NewEncoder(w).Encode(devices)
+		return
+	}
+
+	fields, err := h.inventoryService.ListCustomFields()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load custom fields: %v", err), http.StatusInternalServerError)
+		return
+	}
+	networks, err := h.networkService.FindAll()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load networks: %v", err), http.StatusInternalServerError)
+		return
+	}
+	networkCIDRs := make(map[string]string, len(networks))
+	for _, n := range networks {
+		networkCIDRs[n.ID] = n.CIDR
+	}
+
+	output, err := inventory.ToCSV(devices, fields, networkCIDRs)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to export devices: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=reconya-devices.csv")
+	w.Write(output)
+}
@@ -0,0 +1,146 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"reconya-ai/db"
+	"reconya-ai/models"
+
+	"github.com/gorilla/mux"
+)
+
+// APIScanJobs lists the scan queue, filtered by type, status and device_id
+func (h *WebHandler) APIScanJobs(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	filter := models.ScanJobFilter{
+		Type:     models.ScanJobType(strings.TrimSpace(r.URL.Query().Get("type"))),
+		Status:   models.ScanJobStatus(strings.TrimSpace(r.URL.Query().Get("status"))),
+		DeviceID: strings.TrimSpace(r.URL.Query().Get("device_id")),
+		Limit:    200,
+	}
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit > 0 && limit <= 1000 {
+		filter.Limit = limit
+	}
+
+	jobs, err := h.jobQueue.List(filter)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load scan jobs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"jobs":    jobs,
+	})
+}
+
+// APIScanJob returns a single scan job
+func (h *WebHandler) APIScanJob(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	job, err := h.jobQueue.FindByID(mux.Vars(r)["id"])
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, "Scan job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load scan job: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"job":     job,
+	})
+}
+
+// APICancelScanJob removes a waiting job from the queue or stops a running one
+func (h *WebHandler) APICancelScanJob(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	job, err := h.jobQueue.Cancel(mux.Vars(r)["id"])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		message := fmt.Sprintf("Failed to cancel scan job: %v", err)
+		if errors.Is(err, db.ErrNotFound) {
+			message = "Scan job not found or already finished"
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   message,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Scan job cancelled",
+		"job":     job,
+	})
+}
+
+// APISetScanJobPriority changes the priority of a waiting or running job, higher
+// runs first and manual rescans use 10
+func (h *WebHandler) APISetScanJobPriority(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	priority, err := strconv.Atoi(strings.TrimSpace(r.FormValue("priority")))
+	if err != nil || priority < -100 || priority > 100 {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Invalid priority, use a number between -100 and 100",
+		})
+		return
+	}
+
+	job, err := h.jobQueue.SetPriority(mux.Vars(r)["id"], priority)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		message := fmt.Sprintf("Failed to set scan job priority: %v", err)
+		if errors.Is(err, db.ErrNotFound) {
+			message = "Scan job not found or already finished"
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   message,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Scan job priority updated",
+		"job":     job,
+	})
+}
@@ -0,0 +1,132 @@
+package web
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"reconya-ai/internal/logging"
+)
+
+// maxLogEntries caps a single /api/logs response
+const maxLogEntries = 1000
+
+// APILogs returns recent log entries, oldest first. ?after= returns only entries
+// newer than that sequence number so the viewer can tail, ?level= is the minimum
+// level, ?component= and ?q= narrow it further and ?limit= keeps the newest ones.
+func (h *WebHandler) APILogs(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := logging.Filter{
+		MinLevel:  slog.LevelDebug,
+		Component: query.Get("component"),
+		Query:     strings.TrimSpace(query.Get("q")),
+		Limit:     maxLogEntries,
+	}
+	if value := query.Get("level"); value != "" {
+		level, err := logging.ParseLevel(value)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.MinLevel = level
+	}
+	if value := query.Get("after"); value != "" {
+		after, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid after sequence number", http.StatusBadRequest)
+			return
+		}
+		filter.After = after
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if limit < maxLogEntries {
+			filter.Limit = limit
+		}
+	}
+
+	entries := logging.Recent(filter)
+	lastSeq := filter.After
+	if len(entries) > 0 {
+		lastSeq = entries[len(entries)-1].Seq
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries":  entries,
+		"last_seq": lastSeq,
+	})
+}
+
+// APILogLevels lists the default level and the level of every component
+func (h *WebHandler) APILogLevels(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	defaultLevel, components := logging.Levels()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"default":    defaultLevel,
+		"components": components,
+	})
+}
+
+// APISetLogLevel changes the level of a component until the next restart. Without
+// a component the default level changes, level=default resets a component.
+func (h *WebHandler) APISetLogLevel(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	component := strings.TrimSpace(r.FormValue("component"))
+	levelName := strings.TrimSpace(r.FormValue("level"))
+	w.Header().Set("Content-Type", "application/json")
+
+	if levelName == "default" && component != "" {
+		logging.ResetLevel(component)
+		logger.Infof("Log level of %s reset to the default by %s", component, user.Username)
+	} else {
+		level, err := logging.ParseLevel(levelName)
+		if err != nil || levelName == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"error":   "Level must be debug, info, warn or error",
+			})
+			return
+		}
+		logging.SetLevel(component, level)
+		if component == "" {
+			logger.Infof("Default log level set to %s by %s", logging.LevelName(level), user.Username)
+		} else {
+			logger.Infof("Log level of %s set to %s by %s", component, logging.LevelName(level), user.Username)
+		}
+	}
+
+	defaultLevel, components := logging.Levels()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"default":    defaultLevel,
+		"components": components,
+	})
+}
@@ -0,0 +1,135 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"reconya-ai/db"
+	"reconya-ai/models"
+
+	"github.com/gorilla/mux"
+)
+
+// APIMergeDevice merges the device given by source_id into the device in the URL
+func (h *WebHandler) APIMergeDevice(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sourceID := strings.TrimSpace(r.FormValue("source_id"))
+	if sourceID == "" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "No device selected to merge",
+		})
+		return
+	}
+
+	op, err := h.deviceMergeService.Merge(mux.Vars(r)["id"], sourceID)
+	h.writeDeviceOperation(w, op, err, "Failed to merge devices")
+}
+
+// APISplitDevice moves the MAC address given by mac out of the device to a new device
+func (h *WebHandler) APISplitDevice(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	op, err := h.deviceMergeService.Split(mux.Vars(r)["id"], r.FormValue("mac"))
+	h.writeDeviceOperation(w, op, err, "Failed to split device")
+}
+
+// APIUndoDeviceOperation reverts a recorded merge or split
+func (h *WebHandler) APIUndoDeviceOperation(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	op, err := h.deviceMergeService.Undo(mux.Vars(r)["id"])
+	h.writeDeviceOperation(w, op, err, "Failed to undo operation")
+}
+
+// APIDeviceOperations lists recorded merges and splits, ?device_id= limits them to one device
+func (h *WebHandler) APIDeviceOperations(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	operations, err := h.deviceMergeService.ListOperations(r.URL.Query().Get("device_id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load device operations: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"operations": operations,
+	})
+}
+
+// APIMergeDuplicateDevices merges devices sharing a MAC address, each merge can be undone
+func (h *WebHandler) APIMergeDuplicateDevices(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	operations, err := h.deviceMergeService.MergeDuplicates()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":    false,
+			"error":      fmt.Sprintf("Failed to merge duplicate devices: %v", err),
+			"operations": operations,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"message":    fmt.Sprintf("Merged %d duplicate devices", len(operations)),
+		"operations": operations,
+	})
+}
+
+func (h *WebHandler) writeDeviceOperation(w http.ResponseWriter, op *models.DeviceOperation, err error, failure string) {
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("%s: %v", failure, err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"message":   op.Description,
+		"operation": op,
+	})
+}
@@ -0,0 +1,26 @@
+package web
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"reconya-ai/internal/metrics"
+)
+
+// Metrics serves scanner and inventory health in the Prometheus text format.
+// Scrapers authenticate with METRICS_TOKEN as a bearer token when one is set.
+func (h *WebHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	if h.config != nil && h.config.MetricsToken != "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(h.config.MetricsToken)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := metrics.Default.Write(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,89 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"reconya-ai/models"
+
+	"github.com/gorilla/mux"
+)
+
+// APIRescanDevice queues an on-demand rescan of a device. The stages come from
+// ports (quick, full or none), service_versions, os_detection, web and
+// screenshots. Progress, cancelling and the changes found go through /api/jobs.
+func (h *WebHandler) APIRescanDevice(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	deviceID := mux.Vars(r)["id"]
+	opts, err := rescanOptionsFromForm(r)
+	if err == nil {
+		var job *models.ScanJob
+		job, err = h.rescanService.Start(deviceID, opts)
+		if err == nil {
+			message := "Rescan queued"
+			if job.Status == models.ScanJobRunning {
+				message = "A rescan of this device is already running"
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"message": message,
+				"job":     job,
+			})
+			return
+		}
+	}
+
+	logger.Warnf("APIRescanDevice: Rescan of device %s not started: %v", deviceID, err)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"error":   fmt.Sprintf("Failed to start rescan: %v", err),
+	})
+}
+
+// rescanOptionsFromForm reads the stages, a quick port scan with fingerprinting
+// when nothing is given. A profile replaces the stages.
+func rescanOptionsFromForm(r *http.Request) (models.RescanOptions, error) {
+	if profile := strings.TrimSpace(r.FormValue("profile")); profile != "" {
+		return models.RescanOptions{Profile: profile}, nil
+	}
+	opts := models.RescanOptions{Ports: models.RescanPortsQuick}
+	switch ports := strings.TrimSpace(r.FormValue("ports")); ports {
+	case "":
+	case "none":
+		opts.Ports = models.RescanPortsNone
+	default:
+		opts.Ports = models.RescanPortRange(ports)
+	}
+
+	for name, target := range map[string]*bool{
+		"service_versions": &opts.ServiceVersions,
+		"os_detection":     &opts.OSDetection,
+		"web":              &opts.Web,
+		"screenshots":      &opts.Screenshots,
+	} {
+		value := strings.TrimSpace(r.FormValue(name))
+		if value == "" {
+			continue
+		}
+		if value == "on" {
+			value = "true"
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return opts, fmt.Errorf("invalid %s value, use true or false", name)
+		}
+		*target = enabled
+	}
+	return opts, nil
+}
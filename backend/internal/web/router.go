@@ -4,6 +4,8 @@ import (
 	"html/template"
 	"net/http"
 
+	"reconya-ai/models"
+
 	"github.com/gorilla/mux"
 )
 
@@ -25,6 +27,13 @@ func (h *WebHandler) SetupRoutes() *mux.Router {
 	r.HandleFunc("/login", h.Login).Methods("GET", "POST")
 	r.HandleFunc("/logout", h.Logout).Methods("POST")
 
+	// Prometheus scrape endpoint
+	r.HandleFunc("/metrics", h.Metrics).Methods("GET")
+
+	// Liveness and readiness probes for systemd and Kubernetes
+	r.HandleFunc("/healthz", h.Healthz).Methods("GET")
+	r.HandleFunc("/readyz", h.Readyz).Methods("GET")
+
 	// API endpoints
 	api := r.PathPrefix("/api").Subrouter()
 	api.HandleFunc("/devices", h.APIDevices).Methods("GET")
@@ -34,12 +43,19 @@ func (h *WebHandler) SetupRoutes() *mux.Router {
 	api.HandleFunc("/devices/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/rescan", h.APIRescanDevice).Methods("POST")
 	api.HandleFunc("/devices/new-scan", h.APINewScan).Methods("GET")
 	api.HandleFunc("/test-ipv6", h.APITestIPv6).Methods("POST")
+	api.HandleFunc("/devices/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/ipv6-addresses", h.APIDeviceIPv6Addresses).Methods("GET")
 	api.HandleFunc("/targets", h.APITargets).Methods("GET")
 	api.HandleFunc("/system-status", h.APISystemStatus).Methods("GET")
 	api.HandleFunc("/dashboard-metrics", h.APIDashboardMetrics).Methods("GET")
 	api.HandleFunc("/event-logs", h.APIEventLogs).Methods("GET")
 	api.HandleFunc("/event-logs-table", h.APIEventLogsTable).Methods("GET")
+	api.HandleFunc("/logs", h.APILogs).Methods("GET")
+	api.HandleFunc("/logs/levels", h.APILogLevels).Methods("GET")
+	api.HandleFunc("/logs/levels", h.APISetLogLevel).Methods("PUT", "POST")
+	api.HandleFunc("/config/effective", h.APIEffectiveConfig).Methods("GET")
+	api.HandleFunc("/config/reload", h.APIReloadConfig).Methods("POST")
 	api.HandleFunc("/network-map", h.APINetworkMap).Methods("GET")
+	api.HandleFunc("/topology", h.APITopology).Methods("GET")
 	api.HandleFunc("/traffic-core", h.APITrafficCore).Methods("GET")
 	api.HandleFunc("/device-list", h.APIDeviceList).Methods("GET")
 	api.HandleFunc("/devices/cleanup-names", h.APICleanupDeviceNames).Methods("POST")
@@ -62,6 +78,84 @@ func (h *WebHandler) SetupRoutes() *mux.Router {
 	api.HandleFunc("/scan/select-network", h.APIScanSelectNetwork).Methods("POST")
 	api.HandleFunc("/about", h.APIAbout).Methods("GET")
 
+	// Scan job queue endpoints
+	api.HandleFunc("/jobs", h.APIScanJobs).Methods("GET")
+	api.HandleFunc("/jobs/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}", h.APIScanJob).Methods("GET")
+	api.HandleFunc("/jobs/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/cancel", h.APICancelScanJob).Methods("POST")
+	api.HandleFunc("/jobs/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/priority", h.APISetScanJobPriority).Methods("PUT", "POST")
+
+	// Ad-hoc scans of targets outside the saved networks
+	api.HandleFunc("/adhoc-scans", h.APIAdhocScans).Methods("GET")
+	api.HandleFunc("/adhoc-scans", h.APIStartAdhocScan).Methods("POST")
+	api.HandleFunc("/adhoc-scans/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}", h.APIAdhocScan).Methods("GET")
+	api.HandleFunc("/adhoc-scans/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}", h.APIDiscardAdhocScan).Methods("DELETE")
+	api.HandleFunc("/adhoc-scans/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/cancel", h.APICancelAdhocScan).Methods("POST")
+	api.HandleFunc("/adhoc-scans/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/promote", h.APIPromoteAdhocScan).Methods("POST")
+	api.HandleFunc("/scan-profiles", h.APIScanProfiles).Methods("GET")
+	api.HandleFunc("/scan-profiles", h.APICreateScanProfile).Methods("POST")
+	api.HandleFunc("/scan-profiles/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}", h.APIUpdateScanProfile).Methods("PUT")
+	api.HandleFunc("/scan-profiles/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}", h.APIDeleteScanProfile).Methods("DELETE")
+	api.HandleFunc("/exclusions", h.APIExclusions).Methods("GET")
+	api.HandleFunc("/exclusions", h.APICreateExclusion).Methods("POST")
+	api.HandleFunc("/exclusions/audit", h.APIExclusionAudit).Methods("GET")
+	api.HandleFunc("/exclusions/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}", h.APIUpdateExclusion).Methods("PUT")
+	api.HandleFunc("/exclusions/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}", h.APIDeleteExclusion).Methods("DELETE")
+
+	// SNMP endpoints
+	api.HandleFunc("/networks/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/snmp-credentials", h.APISNMPCredentials).Methods("GET")
+	api.HandleFunc("/networks/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/snmp-credentials", h.APISaveSNMPCredential).Methods("POST")
+	api.HandleFunc("/snmp-credentials/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}", h.APIDeleteSNMPCredential).Methods("DELETE")
+	api.HandleFunc("/devices/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/snmp", h.APIPollDeviceSNMP).Methods("POST")
+	api.HandleFunc("/devices/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/wake", h.APIWakeDevice).Methods("POST")
+	api.HandleFunc("/wake", h.APIWakeDevices).Methods("POST")
+	api.HandleFunc("/wake-schedules", h.APIWakeSchedules).Methods("GET")
+	api.HandleFunc("/wake-schedules", h.APICreateWakeSchedule).Methods("POST")
+	api.HandleFunc("/wake-schedules/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}", h.APIDeleteWakeSchedule).Methods("DELETE")
+
+	// Inventory endpoints
+	api.HandleFunc("/tags", h.APITags).Methods("GET")
+	api.HandleFunc("/tags", h.APISaveTag).Methods("POST")
+	api.HandleFunc("/tags/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}", h.APIDeleteTag).Methods("DELETE")
+	api.HandleFunc("/groups", h.APIGroups).Methods("GET")
+	api.HandleFunc("/groups", h.APICreateGroup).Methods("POST")
+	api.HandleFunc("/groups/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}", h.APIDeleteGroup).Methods("DELETE")
+	api.HandleFunc("/custom-fields", h.APICustomFields).Methods("GET")
+	api.HandleFunc("/custom-fields", h.APISaveCustomField).Methods("POST")
+	api.HandleFunc("/custom-fields/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}", h.APIDeleteCustomField).Methods("DELETE")
+	api.HandleFunc("/devices/bulk", h.APIBulkUpdateDevices).Methods("POST")
+	api.HandleFunc("/devices/export", h.APIExportDevices).Methods("GET")
+
+	// Device trust endpoints
+	api.HandleFunc("/devices/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/trust", h.APISetDeviceTrust).Methods("POST")
+	api.HandleFunc("/devices/trust", h.APISetDevicesTrust).Methods("POST")
+	api.HandleFunc("/trust/report", h.APITrustReport).Methods("GET")
+	api.HandleFunc("/trust/baseline", h.APIMACBaseline).Methods("GET")
+	api.HandleFunc("/trust/baseline", h.APIImportMACBaseline).Methods("POST")
+	api.HandleFunc("/trust/baseline/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}", h.APIDeleteMACBaseline).Methods("DELETE")
+	api.HandleFunc("/networks/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/lockdown", h.APISetNetworkLockdown).Methods("POST")
+	api.HandleFunc("/networks/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/scan-profile", h.APISetNetworkScanProfile).Methods("POST")
+
+	// Rogue DHCP detection endpoints
+	api.HandleFunc("/networks/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/dhcp", h.APINetworkDHCP).Methods("GET")
+	api.HandleFunc("/networks/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/dhcp/allowlist", h.APISetNetworkDHCPAllowlist).Methods("PUT")
+	api.HandleFunc("/networks/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/dhcp/probe", h.APIProbeNetworkDHCP).Methods("POST")
+
+	// Device merge and split endpoints
+	api.HandleFunc("/devices/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/merge", h.APIMergeDevice).Methods("POST")
+	api.HandleFunc("/devices/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/split", h.APISplitDevice).Methods("POST")
+	api.HandleFunc("/devices/operations", h.APIDeviceOperations).Methods("GET")
+	api.HandleFunc("/devices/operations/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/undo", h.APIUndoDeviceOperation).Methods("POST")
+	api.HandleFunc("/devices/duplicates/merge", h.APIMergeDuplicateDevices).Methods("POST")
+
+	// Remote sensor agent endpoints, enroll, checkin and report authenticate the agent
+	// with its API key or client certificate instead of a session
+	api.HandleFunc("/agents/enroll", h.APIAgentEnroll).Methods("POST")
+	api.HandleFunc("/agents/checkin", h.APIAgentCheckIn).Methods("POST")
+	api.HandleFunc("/agents/report", h.APIAgentReport).Methods("POST")
+	api.HandleFunc("/agents", h.APIAgents).Methods("GET")
+	api.HandleFunc("/agents/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/networks", h.APISetAgentNetworks).Methods("PUT")
+	api.HandleFunc("/agents/{id:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}", h.APIDeleteAgent).Methods("DELETE")
+
 	// Settings endpoints
 	api.HandleFunc("/settings", h.APISettings).Methods("GET")
 	api.HandleFunc("/settings/screenshots", h.APISettingsScreenshots).Methods("POST")
@@ -81,32 +175,27 @@ func (h *WebHandler) SetupRoutes() *mux.Router {
 	return r
 }
 
-func (h *WebHandler) APIRescanDevice(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	deviceID := vars["id"]
-
-	// TODO: Trigger rescan logic
-	w.Write([]byte("<div>Rescan triggered (not implemented yet)</div>"))
-
-	// Return updated modal
-	device, _ := h.deviceService.FindByID(deviceID)
-	if device != nil {
-		if err := h.templates.ExecuteTemplate(w, "components/device-modal.html", device); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
-	}
-}
-
+// APINewScan renders the modal for scanning addresses outside the saved networks.
+// The devices found can then be added to a network or discarded.
 func (h *WebHandler) APINewScan(w http.ResponseWriter, r *http.Request) {
-	// Create a modal for scanning a new IP
+	networks, err := h.networkService.FindAll()
+	if err != nil {
+		logger.Warnf("APINewScan: Failed to load networks: %v", err)
+	}
+	profiles, err := h.scanProfileService.FindAll()
+	if err != nil {
+		logger.Warnf("APINewScan: Failed to load scan profiles: %v", err)
+	}
 	data := struct {
-		Title   string
-		Message string
-		Action  string
+		Title    string
+		Action   string
+		Profiles []*models.ScanProfile
+		Networks []models.Network
 	}{
-		Title:   "Scan New Device",
-		Message: "Would you like to scan this IP address for devices?",
-		Action:  "Start Scan",
+		Title:    "Scan New Device",
+		Action:   "Start Scan",
+		Profiles: profiles,
+		Networks: networks,
 	}
 
 	modalHTML := `
@@ -116,21 +205,101 @@ func (h *WebHandler) APINewScan(w http.ResponseWriter, r *http.Request) {
         <i class="ti ti-x"></i>
     </button>
 </div>
-<div class="mb-4">
-    <p class="text-gray-300 mb-4">{{.Message}}</p>
-    <div class="bg-blue-600 bg-opacity-10 border border-blue-500 rounded p-3">
+<form id="adhoc-scan-form" class="mb-4" onsubmit="startScan(); return false;">
+    <label class="block text-gray-300 mb-1" for="adhoc-targets">Addresses, ranges or CIDRs</label>
+    <textarea id="adhoc-targets" name="targets" rows="3" class="w-full bg-gray-800 border border-gray-600 rounded p-2 text-white font-mono text-sm" placeholder="10.8.0.5, 192.168.50.10-20, 172.16.4.0/28"></textarea>
+    <label class="block text-gray-300 mt-3 mb-1" for="adhoc-profile">Profile</label>
+    <select id="adhoc-profile" name="profile" class="bg-gray-800 border border-gray-600 rounded p-2 text-white text-sm">
+        {{range .Profiles}}<option value="{{.Name}}"{{if eq .Name "standard"}} selected{{end}}>{{.Name}}</option>{{end}}
+    </select>
+    <div class="bg-blue-600 bg-opacity-10 border border-blue-500 rounded p-3 mt-3">
         <i class="ti ti-info-circle mr-2 text-blue-400"></i>
-        <span class="text-blue-400">This will perform a network scan on the selected IP address to detect any devices.</span>
+        <span class="text-blue-400">Results stay in a scratch area until you add them to a network or discard them.</span>
+    </div>
+</form>
+<div id="adhoc-scan-result" class="mb-4 hidden">
+    <p id="adhoc-scan-status" class="text-gray-300 mb-2"></p>
+    <ul id="adhoc-scan-devices" class="text-sm text-gray-300 font-mono mb-3"></ul>
+    <div id="adhoc-scan-promote" class="hidden">
+        <label class="block text-gray-300 mb-1" for="adhoc-network">Add to network</label>
+        <select id="adhoc-network" class="bg-gray-800 border border-gray-600 rounded p-2 text-white text-sm">
+            <option value="">New network from CIDR</option>
+            {{range .Networks}}<option value="{{.ID}}">{{if .Name}}{{.Name}} ({{.CIDR}}){{else}}{{.CIDR}}{{end}}</option>{{end}}
+        </select>
+        <input id="adhoc-cidr" type="text" class="bg-gray-800 border border-gray-600 rounded p-2 text-white text-sm font-mono" placeholder="10.8.0.0/24">
     </div>
 </div>
 <div class="flex justify-end gap-2 pt-3 border-t border-green-600">
-    <button type="button" class="border border-gray-500 text-gray-300 hover:bg-gray-700 hover:text-white px-3 py-2 rounded text-sm transition-colors" onclick="closeModal()">Cancel</button>
-    <button type="button" class="bg-green-600 hover:bg-green-700 text-white px-3 py-2 rounded text-sm transition-colors" onclick="startScan()">{{.Action}}</button>
+    <button type="button" class="border border-gray-500 text-gray-300 hover:bg-gray-700 hover:text-white px-3 py-2 rounded text-sm transition-colors" onclick="closeModal()">Close</button>
+    <button type="button" id="adhoc-discard" class="hidden border border-red-500 text-red-400 hover:bg-red-700 hover:text-white px-3 py-2 rounded text-sm transition-colors" onclick="discardScan()">Discard</button>
+    <button type="button" id="adhoc-promote" class="hidden bg-green-600 hover:bg-green-700 text-white px-3 py-2 rounded text-sm transition-colors" onclick="promoteScan()">Add to Network</button>
+    <button type="button" id="adhoc-start" class="bg-green-600 hover:bg-green-700 text-white px-3 py-2 rounded text-sm transition-colors" onclick="startScan()">{{.Action}}</button>
 </div>
 <script>
+var adhocScanID = null;
+
+function adhocRequest(method, url, body) {
+    return fetch(url, {method: method, body: body}).then(function(response) { return response.json(); });
+}
+
 function startScan() {
-    // TODO: Implement scan functionality
-    alert('Scan functionality not yet implemented');
+    var form = new FormData(document.getElementById('adhoc-scan-form'));
+    adhocRequest('POST', '/api/adhoc-scans', form).then(function(data) {
+        if (!data.success) {
+            alert(data.error);
+            return;
+        }
+        adhocScanID = data.scan.id;
+        document.getElementById('adhoc-scan-form').classList.add('hidden');
+        document.getElementById('adhoc-start').classList.add('hidden');
+        document.getElementById('adhoc-scan-result').classList.remove('hidden');
+        pollScan();
+    });
+}
+
+function pollScan() {
+    adhocRequest('GET', '/api/adhoc-scans/' + adhocScanID).then(function(data) {
+        var scan = data.scan;
+        document.getElementById('adhoc-scan-status').textContent = 'Scan ' + scan.status + ' (' + scan.progress + '%), ' + (scan.devices || []).length + ' devices found';
+        var list = document.getElementById('adhoc-scan-devices');
+        list.innerHTML = '';
+        (scan.devices || []).forEach(function(device) {
+            var item = document.createElement('li');
+            var ports = (device.ports || []).filter(function(port) { return port.state === 'open'; }).map(function(port) { return port.number; });
+            item.textContent = device.ipv4 + (device.hostname ? ' ' + device.hostname : '') + (ports.length ? ' [' + ports.join(', ') + ']' : '');
+            list.appendChild(item);
+        });
+        if (scan.status === 'queued' || scan.status === 'running') {
+            setTimeout(pollScan, 2000);
+            return;
+        }
+        document.getElementById('adhoc-discard').classList.remove('hidden');
+        if ((scan.devices || []).length > 0) {
+            document.getElementById('adhoc-scan-promote').classList.remove('hidden');
+            document.getElementById('adhoc-promote').classList.remove('hidden');
+        }
+    });
+}
+
+function promoteScan() {
+    var body = new FormData();
+    body.append('network_id', document.getElementById('adhoc-network').value);
+    body.append('cidr', document.getElementById('adhoc-cidr').value);
+    adhocRequest('POST', '/api/adhoc-scans/' + adhocScanID + '/promote', body).then(function(data) {
+        if (!data.success) {
+            alert(data.error);
+            return;
+        }
+        var skipped = data.promotion.skipped || [];
+        alert(data.message + (skipped.length ? '\nLeft in the scratch area:\n' + skipped.join('\n') : ''));
+        pollScan();
+    });
+}
+
+function discardScan() {
+    adhocRequest('DELETE', '/api/adhoc-scans/' + adhocScanID).then(function() {
+        closeModal();
+    });
 }
 </script>`
 
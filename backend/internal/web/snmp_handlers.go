@@ -0,0 +1,155 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"reconya-ai/models"
+
+	"github.com/gorilla/mux"
+)
+
+// APISNMPCredentials lists the SNMP credentials of a network with secrets redacted
+func (h *WebHandler) APISNMPCredentials(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	networkID := mux.Vars(r)["id"]
+
+	creds, err := h.snmpService.ListCredentials(networkID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load SNMP credentials: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"credentials": creds,
+	})
+}
+
+// APISaveSNMPCredential creates or updates an SNMP credential for a network.
+// Secrets left empty on update keep their stored value.
+func (h *WebHandler) APISaveSNMPCredential(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	networkID := mux.Vars(r)["id"]
+
+	if network, err := h.networkService.FindByID(networkID); err != nil || network == nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Network not found",
+		})
+		return
+	}
+
+	port := 0
+	if portValue := strings.TrimSpace(r.FormValue("port")); portValue != "" {
+		parsed, err := strconv.Atoi(portValue)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"error":   "Invalid SNMP port",
+			})
+			return
+		}
+		port = parsed
+	}
+
+	cred := &models.SNMPCredential{
+		ID:             strings.TrimSpace(r.FormValue("id")),
+		NetworkID:      networkID,
+		Version:        models.SNMPVersion(strings.TrimSpace(r.FormValue("version"))),
+		Port:           port,
+		Community:      r.FormValue("community"),
+		Username:       strings.TrimSpace(r.FormValue("username")),
+		AuthProtocol:   strings.TrimSpace(r.FormValue("auth_protocol")),
+		AuthPassphrase: r.FormValue("auth_passphrase"),
+		PrivProtocol:   strings.TrimSpace(r.FormValue("priv_protocol")),
+		PrivPassphrase: r.FormValue("priv_passphrase"),
+	}
+
+	saved, err := h.snmpService.SaveCredential(cred)
+	if err != nil {
+		logger.Errorf("APISaveSNMPCredential: Error saving credential: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to save SNMP credential: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"message":    "SNMP credential saved successfully",
+		"credential": saved,
+	})
+}
+
+// APIDeleteSNMPCredential removes an SNMP credential
+func (h *WebHandler) APIDeleteSNMPCredential(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	credentialID := mux.Vars(r)["id"]
+
+	if err := h.snmpService.DeleteCredential(credentialID); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete SNMP credential: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "SNMP credential deleted successfully",
+	})
+}
+
+// APIPollDeviceSNMP polls a device over SNMP right away and returns the updated device
+func (h *WebHandler) APIPollDeviceSNMP(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	deviceID := mux.Vars(r)["id"]
+
+	device, err := h.snmpService.InterrogateDevice(deviceID)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"device":  device,
+	})
+}
@@ -0,0 +1,56 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"reconya-ai/internal/topology"
+)
+
+// APITopology returns the inferred physical topology as JSON, or exports it as
+// GraphML or Graphviz DOT with ?format=graphml|dot. ?network_id= limits the graph to
+// one network and the infrastructure it is attached to.
+func (h *WebHandler) APITopology(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	networkID := r.URL.Query().Get("network_id")
+	if networkID != "" {
+		if network, err := h.networkService.FindByID(networkID); err != nil || network == nil {
+			http.Error(w, "Network not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	graph, err := h.topologyService.Build(networkID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to build topology: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(graph)
+	case "graphml":
+		output, err := topology.ToGraphML(graph)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to export topology: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/graphml+xml")
+		w.Header().Set("Content-Disposition", "attachment; filename=reconya-topology.graphml")
+		w.Write(output)
+	case "dot":
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		w.Header().Set("Content-Disposition", "attachment; filename=reconya-topology.dot")
+		w.Write([]byte(topology.ToDOT(graph)))
+	default:
+		http.Error(w, "Unsupported format, use json, graphml or dot", http.StatusBadRequest)
+	}
+}
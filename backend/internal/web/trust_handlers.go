@@ -0,0 +1,220 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"reconya-ai/db"
+	"reconya-ai/models"
+
+	"github.com/gorilla/mux"
+)
+
+// maxBaselineUpload caps the size of an uploaded MAC list
+const maxBaselineUpload = 1 << 20
+
+// APISetDeviceTrust approves, blocks or resets a single device with state=approved|blocked|new
+func (h *WebHandler) APISetDeviceTrust(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	h.setTrust(w, []string{mux.Vars(r)["id"]}, r.FormValue("state"))
+}
+
+// APISetDevicesTrust changes the trust state of several devices selected by device_ids
+func (h *WebHandler) APISetDevicesTrust(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	deviceIDs := formList(r, "device_ids")
+	if len(deviceIDs) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "No devices selected",
+		})
+		return
+	}
+
+	h.setTrust(w, deviceIDs, r.FormValue("state"))
+}
+
+func (h *WebHandler) setTrust(w http.ResponseWriter, deviceIDs []string, state string) {
+	if err := h.trustService.SetTrustState(deviceIDs, models.TrustState(strings.TrimSpace(state))); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to update trust state: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Marked %d devices as %s", len(deviceIDs), state),
+	})
+}
+
+// APITrustReport lists new and blocked devices per network, ?network_id= limits it to one network
+func (h *WebHandler) APITrustReport(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	reports, err := h.trustService.Report(r.URL.Query().Get("network_id"))
+	if err == db.ErrNotFound {
+		http.Error(w, "Network not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to build trust report: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"networks": reports,
+	})
+}
+
+// APIMACBaseline lists the authorized MAC addresses
+func (h *WebHandler) APIMACBaseline(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	entries, err := h.trustService.ListBaseline()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load MAC baseline: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"baseline": entries,
+	})
+}
+
+// APIImportMACBaseline imports a MAC list from the macs form value or an uploaded file,
+// one MAC per line with an optional label. network_id limits the entries to one network.
+func (h *WebHandler) APIImportMACBaseline(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	text := r.FormValue("macs")
+	if file, _, err := r.FormFile("file"); err == nil {
+		defer file.Close()
+		data, err := io.ReadAll(io.LimitReader(file, maxBaselineUpload))
+		if err != nil {
+			http.Error(w, "Failed to read uploaded file", http.StatusBadRequest)
+			return
+		}
+		text = string(data)
+	}
+
+	added, invalid, err := h.trustService.ImportBaseline(text, strings.TrimSpace(r.FormValue("network_id")))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to import MAC baseline: %v", err),
+			"invalid": invalid,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Imported %d MAC addresses", added),
+		"added":   added,
+		"invalid": invalid,
+	})
+}
+
+// APIDeleteMACBaseline removes a MAC address from the baseline
+func (h *WebHandler) APIDeleteMACBaseline(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err := h.trustService.DeleteBaseline(mux.Vars(r)["id"])
+	if err == db.ErrNotFound {
+		http.Error(w, "Baseline entry not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete baseline entry: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Baseline entry deleted successfully",
+	})
+}
+
+// APISetNetworkLockdown turns lockdown mode on or off with enabled=true|false
+func (h *WebHandler) APISetNetworkLockdown(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Invalid enabled value, use true or false",
+		})
+		return
+	}
+
+	err = h.trustService.SetLockdown(mux.Vars(r)["id"], enabled)
+	if err == db.ErrNotFound {
+		http.Error(w, "Network not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update lockdown: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"lockdown": enabled,
+	})
+}
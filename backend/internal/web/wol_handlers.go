@@ -0,0 +1,235 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"reconya-ai/models"
+
+	"github.com/gorilla/mux"
+)
+
+// APIWakeDevice sends a Wake-on-LAN magic packet to a device's stored MAC address
+func (h *WebHandler) APIWakeDevice(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	deviceID := mux.Vars(r)["id"]
+
+	opts, err := wakeOptionsFromForm(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	result, err := h.wolService.Wake(deviceID, opts)
+	if err != nil {
+		logger.Errorf("APIWakeDevice: Error waking device %s: %v", deviceID, err)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to send Wake-on-LAN packet: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Magic packet sent, the next sweep will tell whether the device came up",
+		"result":  result,
+	})
+}
+
+// APIWakeDevices wakes several devices at once, selected by device_ids and/or network_id
+func (h *WebHandler) APIWakeDevices(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	opts, err := wakeOptionsFromForm(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	deviceIDs := formList(r, "device_ids")
+	if networkID := strings.TrimSpace(r.FormValue("network_id")); networkID != "" {
+		networkDevices, err := h.wolService.DeviceIDsForNetwork(networkID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to load network devices: %v", err), http.StatusInternalServerError)
+			return
+		}
+		deviceIDs = append(deviceIDs, networkDevices...)
+	}
+
+	if len(deviceIDs) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "No devices selected",
+		})
+		return
+	}
+
+	results := h.wolService.WakeDevices(deviceIDs, opts)
+	sent := 0
+	for _, result := range results {
+		if result.Error == "" {
+			sent++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": sent > 0,
+		"message": fmt.Sprintf("Magic packets sent to %d of %d devices", sent, len(results)),
+		"results": results,
+	})
+}
+
+// APIWakeSchedules lists scheduled wakes
+func (h *WebHandler) APIWakeSchedules(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	schedules, err := h.wolService.ListSchedules()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load wake schedules: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"schedules": schedules,
+	})
+}
+
+// APICreateWakeSchedule schedules a wake for devices and/or a whole network at run_at (RFC 3339)
+func (h *WebHandler) APICreateWakeSchedule(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	opts, err := wakeOptionsFromForm(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	runAt, err := time.Parse(time.RFC3339, strings.TrimSpace(r.FormValue("run_at")))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Invalid run_at, use RFC 3339 like 2024-01-02T07:30:00Z",
+		})
+		return
+	}
+
+	schedule, err := h.wolService.ScheduleWake(&models.WakeSchedule{
+		DeviceIDs: formList(r, "device_ids"),
+		NetworkID: strings.TrimSpace(r.FormValue("network_id")),
+		RunAt:     runAt,
+		Options:   opts,
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to schedule wake: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"message":  "Wake scheduled successfully",
+		"schedule": schedule,
+	})
+}
+
+// APIDeleteWakeSchedule cancels a pending wake schedule or removes a finished one
+func (h *WebHandler) APIDeleteWakeSchedule(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.sessionStore.Get(r, "reconya-session")
+	user := h.getUserFromSession(session)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	scheduleID := mux.Vars(r)["id"]
+
+	if err := h.wolService.CancelSchedule(scheduleID); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to cancel wake schedule: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Wake schedule cancelled",
+	})
+}
+
+func wakeOptionsFromForm(r *http.Request) (models.WakeOptions, error) {
+	opts := models.WakeOptions{
+		Interface:        strings.TrimSpace(r.FormValue("interface")),
+		BroadcastAddress: strings.TrimSpace(r.FormValue("broadcast_address")),
+		SecureOnPassword: strings.TrimSpace(r.FormValue("secure_on_password")),
+	}
+	if portValue := strings.TrimSpace(r.FormValue("port")); portValue != "" {
+		port, err := strconv.Atoi(portValue)
+		if err != nil {
+			return opts, fmt.Errorf("invalid Wake-on-LAN port")
+		}
+		opts.Port = port
+	}
+	return opts, nil
+}
+
+// formList reads a form field given either repeatedly or as a comma-separated list
+func formList(r *http.Request, name string) []string {
+	r.ParseForm()
+	var values []string
+	for _, value := range r.Form[name] {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+	}
+	return values
+}
@@ -6,12 +6,15 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reconya-ai/internal/config"
+	"reconya-ai/internal/exclusion"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/metrics"
 	"reconya-ai/models"
 	"regexp"
 	"strconv"
@@ -21,9 +24,17 @@ import (
 	"github.com/chromedp/chromedp"
 )
 
+var logger = logging.For("webservice")
+
+var screenshotsTotal = metrics.NewCounterVec("reconya_screenshots_total",
+	"Web page screenshot captures by result", "result")
+
 type WebService struct {
 	client             *http.Client
 	screenshotsEnabled bool
+	config             *config.Config
+	// Exclusions skip web fetches and screenshots
+	Exclusions *exclusion.ExclusionService
 }
 
 type WebInfo struct {
@@ -36,7 +47,7 @@ type WebInfo struct {
 	Screenshot  string // Base64 encoded screenshot or file path
 }
 
-func NewWebService() *WebService {
+func NewWebService(cfg *config.Config) *WebService {
 	// Create HTTP client with timeouts and insecure TLS (for self-signed certs)
 	client := &http.Client{
 		Timeout: 10 * time.Second,
@@ -49,6 +60,7 @@ func NewWebService() *WebService {
 
 	return &WebService{
 		client: client,
+		config: cfg,
 	}
 }
 
@@ -65,6 +77,21 @@ func (w *WebService) ScanWebServicesWithScreenshots(device *models.Device, captu
 		return webInfos
 	}
 
+	var mac string
+	if device.MAC != nil {
+		mac = *device.MAC
+	}
+	if rule := w.Exclusions.Blocked(models.ExcludeWeb, device.IPv4, mac); rule != nil {
+		logger.Infof("Skipping web services of %s: %v", device.IPv4, exclusion.BlockedError(rule, models.ExcludeWeb))
+		return webInfos
+	}
+	if captureScreenshots {
+		if rule := w.Exclusions.Blocked(models.ExcludeScreenshots, device.IPv4, mac); rule != nil {
+			logger.Infof("Skipping screenshots of %s: %v", device.IPv4, exclusion.BlockedError(rule, models.ExcludeScreenshots))
+			captureScreenshots = false
+		}
+	}
+
 	// Common web ports to check
 	webPorts := map[string][]string{
 		"80":   {"http"},
@@ -104,7 +131,7 @@ func (w *WebService) ScanWebServicesWithScreenshots(device *models.Device, captu
 		// Convert port number to int for fetchWebInfo
 		portNum, err := strconv.Atoi(port.Number)
 		if err != nil {
-			log.Printf("Invalid port number: %s", port.Number)
+			logger.Debugf("Invalid port number: %s", port.Number)
 			continue
 		}
 
@@ -113,7 +140,7 @@ func (w *WebService) ScanWebServicesWithScreenshots(device *models.Device, captu
 			webInfo := w.fetchWebInfo(device.IPv4, portNum, protocol, captureScreenshots)
 			if webInfo != nil {
 				webInfos = append(webInfos, *webInfo)
-				log.Printf("Found web service: %s on %s:%s", protocol, device.IPv4, port.Number)
+				logger.Debugf("Found web service: %s on %s:%s", protocol, device.IPv4, port.Number)
 			}
 		}
 	}
@@ -125,11 +152,11 @@ func (w *WebService) ScanWebServicesWithScreenshots(device *models.Device, captu
 func (w *WebService) fetchWebInfo(ip string, port int, protocol string, captureScreenshots bool) *WebInfo {
 	urlStr := fmt.Sprintf("%s://%s:%d", protocol, ip, port)
 	
-	log.Printf("Fetching web info from: %s", urlStr)
+	logger.Debugf("Fetching web info from: %s", urlStr)
 	
 	resp, err := w.client.Get(urlStr)
 	if err != nil {
-		log.Printf("Failed to fetch %s: %v", urlStr, err)
+		logger.Errorf("Failed to fetch %s: %v", urlStr, err)
 		return nil
 	}
 	defer resp.Body.Close()
@@ -137,7 +164,7 @@ func (w *WebService) fetchWebInfo(ip string, port int, protocol string, captureS
 	// Read response body (limit to 1MB to avoid memory issues)
 	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
 	if err != nil {
-		log.Printf("Failed to read response body from %s: %v", urlStr, err)
+		logger.Errorf("Failed to read response body from %s: %v", urlStr, err)
 		return nil
 	}
 
@@ -158,13 +185,15 @@ func (w *WebService) fetchWebInfo(ip string, port int, protocol string, captureS
 	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
 		// Capture screenshot for successful web pages only if requested
 		if captureScreenshots && strings.Contains(strings.ToLower(webInfo.ContentType), "html") {
-			log.Printf("Attempting to capture screenshot for %s", urlStr)
+			logger.Debugf("Attempting to capture screenshot for %s", urlStr)
 			screenshot := w.captureScreenshot(urlStr)
 			if screenshot != "" {
 				webInfo.Screenshot = screenshot
-				log.Printf("Successfully captured screenshot for %s (size: %d bytes)", urlStr, len(screenshot))
+				screenshotsTotal.Inc("success")
+				logger.Infof("Successfully captured screenshot for %s (size: %d bytes)", urlStr, len(screenshot))
 			} else {
-				log.Printf("Failed to capture screenshot for %s", urlStr)
+				screenshotsTotal.Inc("failure")
+				logger.Errorf("Failed to capture screenshot for %s", urlStr)
 			}
 		}
 		return webInfo
@@ -229,7 +258,7 @@ func (w *WebService) captureScreenshot(urlStr string) string {
 	// Create a temporary directory for screenshots
 	tempDir := "/tmp/reconya-screenshots"
 	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		log.Printf("Failed to create screenshot directory: %v", err)
+		logger.Errorf("Failed to create screenshot directory: %v", err)
 		return ""
 	}
 
@@ -237,43 +266,34 @@ func (w *WebService) captureScreenshot(urlStr string) string {
 	filename := fmt.Sprintf("screenshot_%d.png", time.Now().UnixNano())
 	screenshotPath := filepath.Join(tempDir, filename)
 
-	// Try chromedp (Go-based, no external dependencies) first
-	screenshot := w.captureWithChromedp(urlStr)
-	if screenshot != "" {
-		return screenshot
-	}
-
-	// Try Firefox ESR headless if available
-	screenshot = w.captureWithFirefox(urlStr, screenshotPath)
-	if screenshot != "" {
-		return screenshot
-	}
-
-	// Try Chrome/Chromium headless if available
-	screenshot = w.captureWithChrome(urlStr, screenshotPath)
-	if screenshot != "" {
-		return screenshot
-	}
-
-	// Fallback to other methods if Chrome is not available
-	screenshot = w.captureWithWkhtmltoimage(urlStr, screenshotPath)
-	if screenshot != "" {
-		return screenshot
-	}
-
-	// Try webkit2png if available (macOS)
-	screenshot = w.captureWithWebkit2png(urlStr, screenshotPath)
-	if screenshot != "" {
-		return screenshot
+	// Try each tool in the configured order, chromedp first by default since it
+	// needs no external dependencies
+	for _, tool := range w.config.Tuning().ScreenshotTools {
+		var screenshot string
+		switch tool {
+		case "chromedp":
+			screenshot = w.captureWithChromedp(urlStr)
+		case "firefox":
+			screenshot = w.captureWithFirefox(urlStr, screenshotPath)
+		case "chrome":
+			screenshot = w.captureWithChrome(urlStr, screenshotPath)
+		case "wkhtmltoimage":
+			screenshot = w.captureWithWkhtmltoimage(urlStr, screenshotPath)
+		case "webkit2png":
+			screenshot = w.captureWithWebkit2png(urlStr, screenshotPath)
+		}
+		if screenshot != "" {
+			return screenshot
+		}
 	}
 
-	log.Printf("No screenshot method available for %s", urlStr)
+	logger.Warnf("No screenshot method available for %s", urlStr)
 	return ""
 }
 
 // captureWithChromedp captures screenshot using chromedp (Go-based, no external dependencies)
 func (w *WebService) captureWithChromedp(urlStr string) string {
-	log.Printf("Attempting chromedp screenshot for %s", urlStr)
+	logger.Debugf("Attempting chromedp screenshot for %s", urlStr)
 	
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -307,18 +327,18 @@ func (w *WebService) captureWithChromedp(urlStr string) string {
 	)
 
 	if err != nil {
-		log.Printf("chromedp screenshot failed for %s: %v", urlStr, err)
+		logger.Errorf("chromedp screenshot failed for %s: %v", urlStr, err)
 		return ""
 	}
 
 	if len(screenshotData) == 0 {
-		log.Printf("chromedp returned empty screenshot for %s", urlStr)
+		logger.Warnf("chromedp returned empty screenshot for %s", urlStr)
 		return ""
 	}
 
 	// Encode to base64
 	encoded := base64.StdEncoding.EncodeToString(screenshotData)
-	log.Printf("chromedp screenshot successful for %s (size: %d bytes)", urlStr, len(screenshotData))
+	logger.Infof("chromedp screenshot successful for %s (size: %d bytes)", urlStr, len(screenshotData))
 	
 	return encoded
 }
@@ -350,11 +370,11 @@ func (w *WebService) captureWithChrome(urlStr, outputPath string) string {
 	}
 	
 	if chromeCmd == "" {
-		log.Printf("Chrome/Chromium not found in PATH or standard locations")
+		logger.Debugf("Chrome/Chromium not found in PATH or standard locations")
 		return ""
 	}
 	
-	log.Printf("Using Chrome binary: %s", chromeCmd)
+	logger.Debugf("Using Chrome binary: %s", chromeCmd)
 
 	// Chrome headless command with security options for containers
 	args := []string{
@@ -384,7 +404,7 @@ func (w *WebService) captureWithChrome(urlStr, outputPath string) string {
 
 	err := cmd.Run()
 	if err != nil {
-		log.Printf("Chrome screenshot failed for %s: %v", urlStr, err)
+		logger.Errorf("Chrome screenshot failed for %s: %v", urlStr, err)
 		return ""
 	}
 
@@ -395,11 +415,11 @@ func (w *WebService) captureWithChrome(urlStr, outputPath string) string {
 func (w *WebService) captureWithFirefox(urlStr, outputPath string) string {
 	// Check if firefox is available
 	if _, err := exec.LookPath("firefox"); err != nil {
-		log.Printf("Firefox not found in PATH")
+		logger.Debugf("Firefox not found in PATH")
 		return ""
 	}
 
-	log.Printf("Using Firefox ESR for screenshot")
+	logger.Debugf("Using Firefox ESR for screenshot")
 
 	// Firefox headless command with screenshot
 	args := []string{
@@ -425,7 +445,7 @@ func (w *WebService) captureWithFirefox(urlStr, outputPath string) string {
 
 	err := cmd.Run()
 	if err != nil {
-		log.Printf("Firefox screenshot failed for %s: %v", urlStr, err)
+		logger.Errorf("Firefox screenshot failed for %s: %v", urlStr, err)
 		return ""
 	}
 
@@ -436,7 +456,7 @@ func (w *WebService) captureWithFirefox(urlStr, outputPath string) string {
 func (w *WebService) captureWithWkhtmltoimage(urlStr, outputPath string) string {
 	// Check if wkhtmltoimage is available
 	if _, err := exec.LookPath("wkhtmltoimage"); err != nil {
-		log.Printf("wkhtmltoimage not found in PATH")
+		logger.Debugf("wkhtmltoimage not found in PATH")
 		return ""
 	}
 
@@ -464,7 +484,7 @@ func (w *WebService) captureWithWkhtmltoimage(urlStr, outputPath string) string
 
 	err := cmd.Run()
 	if err != nil {
-		log.Printf("wkhtmltoimage screenshot failed for %s: %v", urlStr, err)
+		logger.Errorf("wkhtmltoimage screenshot failed for %s: %v", urlStr, err)
 		return ""
 	}
 
@@ -475,7 +495,7 @@ func (w *WebService) captureWithWkhtmltoimage(urlStr, outputPath string) string
 func (w *WebService) captureWithWebkit2png(urlStr, outputPath string) string {
 	// Check if webkit2png is available
 	if _, err := exec.LookPath("webkit2png"); err != nil {
-		log.Printf("webkit2png not found in PATH")
+		logger.Debugf("webkit2png not found in PATH")
 		return ""
 	}
 
@@ -506,7 +526,7 @@ func (w *WebService) captureWithWebkit2png(urlStr, outputPath string) string {
 
 	err := cmd.Run()
 	if err != nil {
-		log.Printf("webkit2png screenshot failed for %s: %v", urlStr, err)
+		logger.Errorf("webkit2png screenshot failed for %s: %v", urlStr, err)
 		return ""
 	}
 
@@ -515,7 +535,7 @@ func (w *WebService) captureWithWebkit2png(urlStr, outputPath string) string {
 	
 	// Check if the file was created
 	if _, err := os.Stat(actualOutputPath); os.IsNotExist(err) {
-		log.Printf("webkit2png did not create expected file: %s", actualOutputPath)
+		logger.Warnf("webkit2png did not create expected file: %s", actualOutputPath)
 		return ""
 	}
 
@@ -527,20 +547,20 @@ func (w *WebService) encodeScreenshotToBase64(filePath string) string {
 	// Read the screenshot file
 	imageData, err := os.ReadFile(filePath)
 	if err != nil {
-		log.Printf("Failed to read screenshot file %s: %v", filePath, err)
+		logger.Errorf("Failed to read screenshot file %s: %v", filePath, err)
 		return ""
 	}
 
 	// Clean up the file
 	defer func() {
 		if err := os.Remove(filePath); err != nil {
-			log.Printf("Failed to remove screenshot file %s: %v", filePath, err)
+			logger.Errorf("Failed to remove screenshot file %s: %v", filePath, err)
 		}
 	}()
 
 	// Encode to base64
 	encoded := base64.StdEncoding.EncodeToString(imageData)
-	log.Printf("Screenshot encoded to base64, size: %d bytes", len(imageData))
+	logger.Debugf("Screenshot encoded to base64, size: %d bytes", len(imageData))
 	
 	return encoded
 }
\ No newline at end of file
@@ -0,0 +1,118 @@
+package wol
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// MagicPacket builds a Wake-on-LAN packet: six 0xFF bytes followed by the MAC address
+// sixteen times, with the SecureOn password appended when one is given
+func MagicPacket(mac string, secureOn string) ([]byte, error) {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAC address %q: %v", mac, err)
+	}
+	if len(hw) != 6 {
+		return nil, fmt.Errorf("invalid MAC address %q: Wake-on-LAN needs a 48-bit address", mac)
+	}
+
+	password, err := ParseSecureOnPassword(secureOn)
+	if err != nil {
+		return nil, err
+	}
+
+	packet := bytes.Repeat([]byte{0xff}, 6)
+	for i := 0; i < 16; i++ {
+		packet = append(packet, hw...)
+	}
+	return append(packet, password...), nil
+}
+
+// ParseSecureOnPassword accepts a 6 byte password written like a MAC address, a 4 byte
+// password written like an IPv4 address, or either as plain hex
+func ParseSecureOnPassword(password string) ([]byte, error) {
+	password = strings.TrimSpace(password)
+	if password == "" {
+		return nil, nil
+	}
+
+	if ip := net.ParseIP(password); ip != nil && ip.To4() != nil && strings.Count(password, ".") == 3 {
+		return []byte(ip.To4()), nil
+	}
+
+	raw := strings.NewReplacer(":", "", "-", "", ".", "").Replace(password)
+	decoded, err := hex.DecodeString(raw)
+	if err != nil || (len(decoded) != 4 && len(decoded) != 6) {
+		return nil, fmt.Errorf("invalid SecureOn password: use 6 bytes like 01:23:45:67:89:ab or 4 bytes like 192.168.1.1")
+	}
+	return decoded, nil
+}
+
+// DirectedBroadcast returns the broadcast address of an IPv4 network
+func DirectedBroadcast(cidr string) (string, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	return broadcastAddress(ipNet)
+}
+
+func broadcastAddress(ipNet *net.IPNet) (string, error) {
+	ip := ipNet.IP.To4()
+	if ip == nil || len(ipNet.Mask) != net.IPv4len {
+		return "", fmt.Errorf("%s is not an IPv4 network", ipNet)
+	}
+	broadcast := make(net.IP, net.IPv4len)
+	for i := range ip {
+		broadcast[i] = ip[i] | ^ipNet.Mask[i]
+	}
+	return broadcast.String(), nil
+}
+
+// interfaceAddress returns the first IPv4 address and network of a local interface
+func interfaceAddress(name string) (net.IP, *net.IPNet, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("interface %s not found: %v", name, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return ipNet.IP.To4(), &net.IPNet{IP: ipNet.IP.Mask(ipNet.Mask).To4(), Mask: ipNet.Mask[len(ipNet.Mask)-net.IPv4len:]}, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("interface %s has no IPv4 address", name)
+}
+
+// SendMagicPacket sends a packet to address:port over UDP, from localIP when set
+func SendMagicPacket(packet []byte, address string, port int, localIP net.IP) error {
+	target, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(address, fmt.Sprint(port)))
+	if err != nil {
+		return err
+	}
+
+	var local *net.UDPAddr
+	if localIP != nil {
+		local = &net.UDPAddr{IP: localIP}
+	}
+
+	// Broadcast permission is enabled on IPv4 UDP sockets by the runtime
+	conn, err := net.ListenUDP("udp4", local)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetWriteDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.WriteToUDP(packet, target); err != nil {
+		return fmt.Errorf("failed to send magic packet to %s: %v", target, err)
+	}
+	return nil
+}
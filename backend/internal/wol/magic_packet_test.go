@@ -0,0 +1,90 @@
+package wol
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMagicPacket(t *testing.T) {
+	mac := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+
+	packet, err := MagicPacket("00:11:22:33:44:55", "")
+	require.NoError(t, err)
+	require.Len(t, packet, 102)
+	assert.Equal(t, bytes.Repeat([]byte{0xff}, 6), packet[:6])
+	for i := 0; i < 16; i++ {
+		assert.Equal(t, mac, packet[6+i*6:12+i*6])
+	}
+
+	withPassword, err := MagicPacket("00-11-22-33-44-55", "01:02:03:04:05:06")
+	require.NoError(t, err)
+	require.Len(t, withPassword, 108)
+	assert.Equal(t, []byte{1, 2, 3, 4, 5, 6}, withPassword[102:])
+
+	_, err = MagicPacket("not-a-mac", "")
+	assert.Error(t, err)
+	_, err = MagicPacket("00:11:22:33:44:55:66:77", "")
+	assert.Error(t, err)
+}
+
+func TestParseSecureOnPassword(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []byte
+		wantErr  bool
+	}{
+		{input: "", expected: nil},
+		{input: "01:23:45:67:89:ab", expected: []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab}},
+		{input: "0123456789AB", expected: []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab}},
+		{input: "192.168.1.1", expected: []byte{192, 168, 1, 1}},
+		{input: "deadbeef", expected: []byte{0xde, 0xad, 0xbe, 0xef}},
+		{input: "01:02:03", wantErr: true},
+		{input: "secret", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			password, err := ParseSecureOnPassword(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, password)
+		})
+	}
+}
+
+func TestDirectedBroadcast(t *testing.T) {
+	address, err := DirectedBroadcast("192.168.1.0/24")
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.255", address)
+
+	address, err = DirectedBroadcast("10.0.0.0/22")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.3.255", address)
+
+	_, err = DirectedBroadcast("fd00::/64")
+	assert.Error(t, err)
+}
+
+func TestSendMagicPacket(t *testing.T) {
+	listener, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	packet, err := MagicPacket("00:11:22:33:44:55", "")
+	require.NoError(t, err)
+
+	port := listener.LocalAddr().(*net.UDPAddr).Port
+	require.NoError(t, SendMagicPacket(packet, "127.0.0.1", port, nil))
+
+	buf := make([]byte, 256)
+	n, _, err := listener.ReadFromUDP(buf)
+	require.NoError(t, err)
+	assert.Equal(t, packet, buf[:n])
+}
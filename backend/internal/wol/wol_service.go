@@ -0,0 +1,371 @@
+package wol
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/config"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/eventlog"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/network"
+	"reconya-ai/internal/util"
+	"reconya-ai/models"
+)
+
+var logger = logging.For("wol")
+
+type WakeOnLANService struct {
+	Repository      *db.WakeScheduleRepository
+	DeviceService   *device.DeviceService
+	NetworkService  *network.NetworkService
+	EventLogService *eventlog.EventLogService
+	defaultPort     int
+	defaultIface    string
+	secretKey       []byte
+	// bootGrace is how long a device gets to boot before a sweep counts as verification
+	bootGrace time.Duration
+	pending   map[string]time.Time
+	mutex     sync.Mutex
+}
+
+func NewWakeOnLANService(repository *db.WakeScheduleRepository, deviceService *device.DeviceService, networkService *network.NetworkService, eventLogService *eventlog.EventLogService, cfg *config.Config) *WakeOnLANService {
+	port := cfg.WakeOnLANPort
+	if port == 0 {
+		port = 9
+	}
+	return &WakeOnLANService{
+		Repository:      repository,
+		DeviceService:   deviceService,
+		NetworkService:  networkService,
+		EventLogService: eventLogService,
+		defaultPort:     port,
+		defaultIface:    cfg.WakeOnLANInterface,
+		secretKey:       cfg.SecretKey,
+		bootGrace:       20 * time.Second,
+		pending:         make(map[string]time.Time),
+	}
+}
+
+// Wake sends a magic packet to a device's stored MAC address and watches the next
+// sweep of its network to tell whether it came up
+func (s *WakeOnLANService) Wake(deviceID string, opts models.WakeOptions) (*models.WakeResult, error) {
+	d, err := s.DeviceService.FindByID(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, db.ErrNotFound
+	}
+	if d.MAC == nil || *d.MAC == "" {
+		return nil, fmt.Errorf("device %s has no known MAC address", d.IPv4)
+	}
+
+	opts = s.withDefaults(opts)
+	if err := validateOptions(opts); err != nil {
+		return nil, err
+	}
+
+	packet, err := MagicPacket(*d.MAC, opts.SecureOnPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	var localIP net.IP
+	var ifaceNet *net.IPNet
+	if opts.Interface != "" {
+		if localIP, ifaceNet, err = interfaceAddress(opts.Interface); err != nil {
+			return nil, err
+		}
+	}
+
+	address := s.broadcastFor(d, opts, ifaceNet)
+	if err := SendMagicPacket(packet, address, opts.Port, localIP); err != nil {
+		return nil, err
+	}
+
+	sentAt := time.Now()
+	s.mutex.Lock()
+	s.pending[d.ID] = sentAt
+	s.mutex.Unlock()
+
+	description := fmt.Sprintf("Wake-on-LAN magic packet sent to [%s] %s via %s:%d", d.IPv4, *d.MAC, address, opts.Port)
+	logger.Info(description)
+	if err := s.EventLogService.Log(models.WakeOnLANSent, description, d.ID); err != nil {
+		logger.Errorf("Error creating Wake-on-LAN event log: %v", err)
+	}
+
+	return &models.WakeResult{
+		DeviceID: d.ID,
+		IPv4:     d.IPv4,
+		MAC:      *d.MAC,
+		Address:  fmt.Sprintf("%s:%d", address, opts.Port),
+		SentAt:   sentAt,
+	}, nil
+}
+
+// WakeDevices wakes several devices, reporting failures per device
+func (s *WakeOnLANService) WakeDevices(deviceIDs []string, opts models.WakeOptions) []models.WakeResult {
+	results := make([]models.WakeResult, 0, len(deviceIDs))
+	for _, id := range deviceIDs {
+		result, err := s.Wake(id, opts)
+		if err != nil {
+			results = append(results, models.WakeResult{DeviceID: id, Error: err.Error()})
+			continue
+		}
+		results = append(results, *result)
+	}
+	return results
+}
+
+// DeviceIDsForNetwork returns the devices of a network that have a MAC address to wake
+func (s *WakeOnLANService) DeviceIDsForNetwork(networkID string) ([]string, error) {
+	devices, err := s.DeviceService.FindByNetworkID(networkID)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, d := range devices {
+		if d.MAC != nil && *d.MAC != "" {
+			ids = append(ids, d.ID)
+		}
+	}
+	return ids, nil
+}
+
+// ScheduleWake stores a wake to be sent at schedule.RunAt, with the SecureOn password encrypted
+func (s *WakeOnLANService) ScheduleWake(schedule *models.WakeSchedule) (*models.WakeSchedule, error) {
+	if schedule.RunAt.IsZero() {
+		return nil, fmt.Errorf("run_at is required")
+	}
+	if len(schedule.DeviceIDs) == 0 && schedule.NetworkID == "" {
+		return nil, fmt.Errorf("select devices or a network to wake")
+	}
+	if schedule.NetworkID != "" {
+		if n, err := s.NetworkService.FindByID(schedule.NetworkID); err != nil || n == nil {
+			return nil, fmt.Errorf("network not found")
+		}
+	}
+	if err := validateOptions(s.withDefaults(schedule.Options)); err != nil {
+		return nil, err
+	}
+	if _, err := ParseSecureOnPassword(schedule.Options.SecureOnPassword); err != nil {
+		return nil, err
+	}
+
+	stored := *schedule
+	stored.Status = models.WakeSchedulePending
+	stored.Error = ""
+	var err error
+	if stored.Options.SecureOnPassword, err = util.EncryptSecret(s.secretKey, schedule.Options.SecureOnPassword); err != nil {
+		return nil, err
+	}
+
+	if err := s.Repository.Upsert(context.Background(), &stored); err != nil {
+		return nil, err
+	}
+	return redactSchedule(&stored), nil
+}
+
+// ListSchedules returns all wake schedules with passwords redacted
+func (s *WakeOnLANService) ListSchedules() ([]*models.WakeSchedule, error) {
+	schedules, err := s.Repository.FindAll(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	for i := range schedules {
+		schedules[i] = redactSchedule(schedules[i])
+	}
+	return schedules, nil
+}
+
+// CancelSchedule stops a pending schedule from running. Finished schedules are deleted.
+func (s *WakeOnLANService) CancelSchedule(id string) error {
+	ctx := context.Background()
+	schedule, err := s.Repository.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if schedule.Status != models.WakeSchedulePending {
+		return s.Repository.Delete(ctx, id)
+	}
+	schedule.Status = models.WakeScheduleCancelled
+	return s.Repository.Upsert(ctx, schedule)
+}
+
+// RunDueSchedules sends the wakes whose time has come
+func (s *WakeOnLANService) RunDueSchedules() {
+	ctx := context.Background()
+	schedules, err := s.Repository.FindDue(ctx, time.Now())
+	if err != nil {
+		logger.Errorf("Error loading due wake schedules: %v", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		opts := schedule.Options
+		if opts.SecureOnPassword, err = util.DecryptSecret(s.secretKey, opts.SecureOnPassword); err != nil {
+			s.finishSchedule(schedule, fmt.Errorf("failed to decrypt SecureOn password: %v", err))
+			continue
+		}
+
+		deviceIDs := schedule.DeviceIDs
+		if schedule.NetworkID != "" {
+			networkDevices, err := s.DeviceIDsForNetwork(schedule.NetworkID)
+			if err != nil {
+				s.finishSchedule(schedule, err)
+				continue
+			}
+			deviceIDs = append(append([]string{}, deviceIDs...), networkDevices...)
+		}
+
+		var failures []string
+		for _, result := range s.WakeDevices(uniqueStrings(deviceIDs), opts) {
+			if result.Error != "" {
+				failures = append(failures, fmt.Sprintf("%s: %s", result.DeviceID, result.Error))
+			}
+		}
+
+		if len(failures) > 0 {
+			s.finishSchedule(schedule, fmt.Errorf("%s", strings.Join(failures, "; ")))
+		} else {
+			s.finishSchedule(schedule, nil)
+		}
+	}
+}
+
+func (s *WakeOnLANService) finishSchedule(schedule *models.WakeSchedule, err error) {
+	schedule.Status = models.WakeScheduleCompleted
+	if err != nil {
+		schedule.Status = models.WakeScheduleFailed
+		schedule.Error = err.Error()
+		logger.Errorf("Wake schedule %s failed: %v", schedule.ID, err)
+	}
+	if err := s.Repository.Upsert(context.Background(), schedule); err != nil {
+		logger.Errorf("Error updating wake schedule %s: %v", schedule.ID, err)
+	}
+}
+
+// VerifyWakes checks woken devices of a network against a completed sweep and logs
+// whether they came up. Sweeps that started before the device had time to boot are ignored.
+func (s *WakeOnLANService) VerifyWakes(network *models.Network, sweepStartedAt time.Time) {
+	if network == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	due := make(map[string]time.Time)
+	for deviceID, sentAt := range s.pending {
+		if !sentAt.Add(s.bootGrace).After(sweepStartedAt) {
+			due[deviceID] = sentAt
+		}
+	}
+	s.mutex.Unlock()
+
+	for deviceID, sentAt := range due {
+		d, err := s.DeviceService.FindByID(deviceID)
+		if err != nil || d == nil {
+			s.forget(deviceID, sentAt)
+			continue
+		}
+		if d.NetworkID != network.ID {
+			continue
+		}
+		s.forget(deviceID, sentAt)
+
+		mac := ""
+		if d.MAC != nil {
+			mac = *d.MAC
+		}
+		if d.LastSeenOnlineAt != nil && !d.LastSeenOnlineAt.Before(sweepStartedAt) {
+			description := fmt.Sprintf("Device [%s] %s came up %s after Wake-on-LAN", d.IPv4, mac, d.LastSeenOnlineAt.Sub(sentAt).Round(time.Second))
+			logger.Info(description)
+			if err := s.EventLogService.Log(models.DeviceWoke, description, d.ID); err != nil {
+				logger.Errorf("Error creating device woke event log: %v", err)
+			}
+		} else {
+			description := fmt.Sprintf("Device [%s] %s did not respond to the first sweep after Wake-on-LAN", d.IPv4, mac)
+			logger.Info(description)
+			if err := s.EventLogService.Log(models.DeviceWakeFailed, description, d.ID); err != nil {
+				logger.Errorf("Error creating device wake failed event log: %v", err)
+			}
+		}
+	}
+}
+
+// forget drops a pending verification unless the device was woken again meanwhile
+func (s *WakeOnLANService) forget(deviceID string, sentAt time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.pending[deviceID].Equal(sentAt) {
+		delete(s.pending, deviceID)
+	}
+}
+
+func (s *WakeOnLANService) withDefaults(opts models.WakeOptions) models.WakeOptions {
+	if opts.Port == 0 {
+		opts.Port = s.defaultPort
+	}
+	if opts.Interface == "" {
+		opts.Interface = s.defaultIface
+	}
+	return opts
+}
+
+// broadcastFor picks the destination: an explicit address, the directed broadcast of
+// the device's network, the broadcast of the sending interface, then the limited broadcast
+func (s *WakeOnLANService) broadcastFor(d *models.Device, opts models.WakeOptions, ifaceNet *net.IPNet) string {
+	if opts.BroadcastAddress != "" {
+		return opts.BroadcastAddress
+	}
+	if d.NetworkID != "" {
+		if n, err := s.NetworkService.FindByID(d.NetworkID); err == nil && n != nil {
+			if address, err := DirectedBroadcast(n.CIDR); err == nil {
+				return address
+			}
+		}
+	}
+	if ifaceNet != nil {
+		if address, err := broadcastAddress(ifaceNet); err == nil {
+			return address
+		}
+	}
+	return "255.255.255.255"
+}
+
+func validateOptions(opts models.WakeOptions) error {
+	if opts.Port < 1 || opts.Port > 65535 {
+		return fmt.Errorf("invalid port %d", opts.Port)
+	}
+	if opts.BroadcastAddress != "" {
+		if ip := net.ParseIP(opts.BroadcastAddress); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("invalid broadcast address %s", opts.BroadcastAddress)
+		}
+	}
+	return nil
+}
+
+func redactSchedule(schedule *models.WakeSchedule) *models.WakeSchedule {
+	redacted := *schedule
+	if redacted.Options.SecureOnPassword != "" {
+		redacted.Options.SecureOnPassword = "********"
+	}
+	return &redacted
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool)
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if value != "" && !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
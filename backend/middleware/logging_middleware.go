@@ -3,19 +3,22 @@
 package middleware
 
 import (
-	"log"
 	"net/http"
 	"time"
+
+	"reconya-ai/internal/logging"
 )
 
+var logger = logging.For("web")
+
 // LoggingMiddleware logs each HTTP request
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		log.Printf("Started %s %s", r.Method, r.URL.Path)
+		logger.Debugf("Started %s %s", r.Method, r.URL.Path)
 
 		next.ServeHTTP(w, r)
 
-		log.Printf("Completed %s %s in %v", r.Method, r.URL.Path, time.Since(start))
+		logger.Infof("Completed %s %s in %v", r.Method, r.URL.Path, time.Since(start))
 	})
 }
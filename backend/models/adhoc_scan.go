@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+type AdhocScanStatus string
+
+const (
+	AdhocScanQueued    AdhocScanStatus = "queued"
+	AdhocScanRunning   AdhocScanStatus = "running"
+	AdhocScanCompleted AdhocScanStatus = "completed"
+	AdhocScanFailed    AdhocScanStatus = "failed"
+	AdhocScanCancelled AdhocScanStatus = "cancelled"
+	// AdhocScanPromoted means every device found was moved into a network
+	AdhocScanPromoted AdhocScanStatus = "promoted"
+)
+
+// Active reports whether the scan still waits or runs
+func (s AdhocScanStatus) Active() bool {
+	return s == AdhocScanQueued || s == AdhocScanRunning
+}
+
+// AdhocScan is a scan of addresses outside the saved networks. The devices it
+// finds stay in this scratch area, away from the inventory, until they are
+// promoted into a network or the scan is discarded.
+type AdhocScan struct {
+	ID string `json:"id"`
+	// Targets are the CIDRs swept, single addresses and ranges are turned into CIDRs
+	Targets  []string        `json:"targets"`
+	Profile  string          `json:"profile"`
+	Status   AdhocScanStatus `json:"status"`
+	Progress int             `json:"progress"`
+	Devices  []Device        `json:"devices"`
+	// Errors are per host failures, Error is why the scan as a whole failed
+	Errors     []string   `json:"errors,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	NetworkID  string     `json:"network_id,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// AdhocPromotion is the outcome of moving scratch devices into a network
+type AdhocPromotion struct {
+	NetworkID string   `json:"network_id"`
+	Promoted  []string `json:"promoted"`
+	// Skipped are addresses left in the scratch area, with the reason
+	Skipped []string `json:"skipped,omitempty"`
+}
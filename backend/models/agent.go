@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+type AgentHealth string
+
+const (
+	// AgentPending agents enrolled but never checked in
+	AgentPending AgentHealth = "pending"
+	AgentOnline  AgentHealth = "online"
+	// AgentStale agents missed a few check-ins
+	AgentStale   AgentHealth = "stale"
+	AgentOffline AgentHealth = "offline"
+)
+
+// Agent is a remote sensor running `reconya agent` on another site. It scans the
+// networks assigned to it and reports what it finds to this server.
+type Agent struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Hostname string `json:"hostname,omitempty"`
+	Platform string `json:"platform,omitempty"`
+	// EnrolledVia is "token" or "certificate"
+	EnrolledVia  string      `json:"enrolled_via"`
+	NetworkIDs   []string    `json:"network_ids"`
+	RemoteAddr   string      `json:"remote_addr,omitempty"`
+	LastError    string      `json:"last_error,omitempty"`
+	LastCheckIn  *time.Time  `json:"last_check_in,omitempty"`
+	LastReportAt *time.Time  `json:"last_report_at,omitempty"`
+	Health       AgentHealth `json:"health"`
+	CreatedAt    time.Time   `json:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+}
+
+// HealthAt tells whether the agent checked in recently, given how often agents
+// are expected to check in
+func (a *Agent) HealthAt(now time.Time, checkInInterval time.Duration) AgentHealth {
+	switch {
+	case a.LastCheckIn == nil:
+		return AgentPending
+	case now.Sub(*a.LastCheckIn) <= 3*checkInInterval:
+		return AgentOnline
+	case now.Sub(*a.LastCheckIn) <= 20*checkInInterval:
+		return AgentStale
+	default:
+		return AgentOffline
+	}
+}
+
+// HasNetwork tells whether a network is assigned to the agent
+func (a *Agent) HasNetwork(networkID string) bool {
+	for _, id := range a.NetworkIDs {
+		if id == networkID {
+			return true
+		}
+	}
+	return false
+}
@@ -58,6 +58,17 @@ type Device struct {
 	Ports             []Port        `bson:"ports,omitempty" json:"ports,omitempty"`
 	Hostname          *string       `bson:"hostname,omitempty" json:"hostname,omitempty"`
 	WebServices       []WebService  `bson:"web_services,omitempty" json:"web_services,omitempty"`
+	UPnP              *UPnPInfo     `bson:"upnp,omitempty" json:"upnp,omitempty"`
+	SNMP              *SNMPInfo     `bson:"snmp,omitempty" json:"snmp,omitempty"`
+	SwitchPort        *SwitchPortInfo `bson:"switch_port,omitempty" json:"switch_port,omitempty"`
+	TrustState        TrustState    `bson:"trust_state,omitempty" json:"trust_state,omitempty"`
+	Identity          *DeviceIdentity `bson:"identity,omitempty" json:"identity,omitempty"`
+	// SensorID is the agent that last reported the device, nil for local scans
+	SensorID          *string       `bson:"sensor_id,omitempty" json:"sensor_id,omitempty"`
+	// Tags, group paths and custom field values are kept in their own tables
+	Tags              []string          `bson:"tags,omitempty" json:"tags,omitempty"`
+	Groups            []string          `bson:"groups,omitempty" json:"groups,omitempty"`
+	CustomFields      map[string]string `bson:"custom_fields,omitempty" json:"custom_fields,omitempty"`
 	CreatedAt         time.Time     `bson:"created_at" json:"created_at"`
 	UpdatedAt         time.Time     `bson:"updated_at" json:"updated_at"`
 	LastSeenOnlineAt  *time.Time    `bson:"last_seen_online_at,omitempty" json:"last_seen_online_at,omitempty"`
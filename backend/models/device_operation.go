@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// DeviceOperationType is a manual correction of how sightings were grouped into devices
+type DeviceOperationType string
+
+const (
+	// DeviceOperationMerge combines two devices into one
+	DeviceOperationMerge DeviceOperationType = "merge"
+	// DeviceOperationSplit moves one MAC address of a device out to a new device
+	DeviceOperationSplit DeviceOperationType = "split"
+)
+
+// DeviceOperation records a merge or split so that it can be reviewed and undone
+type DeviceOperation struct {
+	ID   string              `bson:"_id,omitempty" json:"id"`
+	Type DeviceOperationType `bson:"type" json:"type"`
+	// DeviceID is the device kept by the operation
+	DeviceID string `bson:"device_id" json:"device_id"`
+	// OtherDeviceID is the device a merge removed or a split created
+	OtherDeviceID string `bson:"other_device_id" json:"other_device_id"`
+	// MAC is the address a split moved to the new device
+	MAC         string                   `bson:"mac,omitempty" json:"mac,omitempty"`
+	Description string                   `bson:"description" json:"description"`
+	Snapshot    *DeviceOperationSnapshot `bson:"snapshot,omitempty" json:"-"`
+	CreatedAt   time.Time                `bson:"created_at" json:"created_at"`
+	UndoneAt    *time.Time               `bson:"undone_at,omitempty" json:"undone_at,omitempty"`
+}
+
+// DeviceOperationSnapshot holds the state an operation changed
+type DeviceOperationSnapshot struct {
+	// Device is the kept device as it was before the operation
+	Device Device `json:"device"`
+	// Removed is the device a merge deleted
+	Removed *Device `json:"removed,omitempty"`
+	// EventLogIDs are the event logs a merge moved to the kept device
+	EventLogIDs []int64 `json:"event_log_ids,omitempty"`
+	// IPv6Addresses are the recorded IPv6 addresses a merge moved to the kept device
+	IPv6Addresses []string `json:"ipv6_addresses,omitempty"`
+	// The tags, groups and custom field values of the removed device
+	TagIDs      []string          `json:"tag_ids,omitempty"`
+	GroupIDs    []string          `json:"group_ids,omitempty"`
+	FieldValues map[string]string `json:"field_values,omitempty"`
+	// The assignments a merge added to the kept device
+	AddedTagIDs   []string `json:"added_tag_ids,omitempty"`
+	AddedGroupIDs []string `json:"added_group_ids,omitempty"`
+	AddedFieldIDs []string `json:"added_field_ids,omitempty"`
+}
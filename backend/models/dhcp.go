@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// DHCPOffer is a DHCPOFFER received in answer to a DHCP DISCOVER probe
+type DHCPOffer struct {
+	ServerIP     string    `json:"server_ip"`
+	ServerMAC    string    `json:"server_mac,omitempty"`
+	OfferedIP    string    `json:"offered_ip,omitempty"`
+	SubnetMask   string    `json:"subnet_mask,omitempty"`
+	Routers      []string  `json:"routers,omitempty"`
+	DNSServers   []string  `json:"dns_servers,omitempty"`
+	LeaseSeconds int       `json:"lease_seconds,omitempty"`
+	SeenAt       time.Time `json:"seen_at"`
+}
+
+// DHCPExpectedServer is a DHCP server allowed to answer on a network. Options left
+// empty are not checked.
+type DHCPExpectedServer struct {
+	ServerIP   string   `json:"server_ip"`
+	ServerMAC  string   `json:"server_mac,omitempty"`
+	SubnetMask string   `json:"subnet_mask,omitempty"`
+	Routers    []string `json:"routers,omitempty"`
+	DNSServers []string `json:"dns_servers,omitempty"`
+}
+
+// DHCPProbeResult holds the offers collected by one probe and the problems found
+// comparing them with the allowlist
+type DHCPProbeResult struct {
+	NetworkID string      `json:"network_id"`
+	Interface string      `json:"interface"`
+	Offers    []DHCPOffer `json:"offers"`
+	Problems  []string    `json:"problems"`
+	ProbedAt  time.Time   `json:"probed_at"`
+}
@@ -20,4 +20,20 @@ const (
 	NewNetworkDetected EEventLogType = "New network detected"
 	Warning           EEventLogType = "Warning"
 	Alert             EEventLogType = "Alert"
+	WakeOnLANSent     EEventLogType = "Wake-on-LAN sent"
+	DeviceWoke        EEventLogType = "Device woke up"
+	DeviceWakeFailed  EEventLogType = "Device did not wake"
+	UnapprovedDeviceSeen EEventLogType = "Unapproved device seen"
+	DeviceTrustChanged   EEventLogType = "Device trust changed"
+	DevicesMerged         EEventLogType = "Devices merged"
+	DeviceSplit           EEventLogType = "Device split"
+	DeviceOperationUndone EEventLogType = "Device merge or split undone"
+	IPConflictDetected    EEventLogType = "IP address conflict"
+	MACClaimsManyIPs      EEventLogType = "MAC claims many IPs"
+	GatewayMACChanged     EEventLogType = "Gateway MAC changed"
+	GratuitousARPStorm    EEventLogType = "Gratuitous ARP storm"
+	RogueDHCPServer       EEventLogType = "Rogue DHCP server"
+	DHCPOptionsMismatch   EEventLogType = "DHCP options mismatch"
+	AgentEnrolled         EEventLogType = "Agent enrolled"
+	AgentRevoked          EEventLogType = "Agent revoked"
 )
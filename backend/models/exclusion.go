@@ -0,0 +1,118 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type ExclusionScope string
+
+const (
+	// ExclusionGlobal rules apply wherever the address is scanned from
+	ExclusionGlobal ExclusionScope = "global"
+	// ExclusionNetwork rules apply inside one network, to all of it without an address
+	ExclusionNetwork ExclusionScope = "network"
+	// ExclusionDevice rules follow one device by its current address and MAC
+	ExclusionDevice ExclusionScope = "device"
+)
+
+// ExclusionStage is a scan stage a rule keeps away from its hosts
+type ExclusionStage string
+
+const (
+	ExcludeDiscovery   ExclusionStage = "discovery"
+	ExcludePortScan    ExclusionStage = "port_scan"
+	ExcludeWeb         ExclusionStage = "web"
+	ExcludeScreenshots ExclusionStage = "screenshots"
+	ExcludeOSDetection ExclusionStage = "os_detection"
+	ExcludeSNMP        ExclusionStage = "snmp"
+	ExcludeUPnP        ExclusionStage = "upnp"
+)
+
+// ExclusionStages lists the stages a rule can block, in pipeline order
+var ExclusionStages = []ExclusionStage{ExcludeDiscovery, ExcludePortScan, ExcludeWeb, ExcludeScreenshots, ExcludeOSDetection, ExcludeSNMP, ExcludeUPnP}
+
+// ExclusionRule keeps some scan stages away from the hosts it matches
+type ExclusionRule struct {
+	ID        string         `json:"id"`
+	Scope     ExclusionScope `json:"scope"`
+	NetworkID string         `json:"network_id,omitempty"`
+	DeviceID  string         `json:"device_id,omitempty"`
+	// Address is an IPv4 address, CIDR, range such as 10.0.0.5-20, or MAC address
+	Address   string           `json:"address,omitempty"`
+	Stages    []ExclusionStage `json:"stages"`
+	Reason    string           `json:"reason,omitempty"`
+	CreatedBy string           `json:"created_by,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+
+	// Filled in when the rules are loaded, so they can be matched without the database
+	NetworkCIDR string `json:"network_cidr,omitempty"`
+	DeviceIPv4  string `json:"device_ipv4,omitempty"`
+	DeviceMAC   string `json:"device_mac,omitempty"`
+	// KnownIPs are the addresses of the devices with the MAC address of a rule,
+	// so nmap can skip them before it learns their MAC
+	KnownIPs []string `json:"known_ips,omitempty"`
+}
+
+// Blocks reports whether the rule keeps a stage away from its hosts. Hosts that
+// may not be fetched over the web get no screenshots either.
+func (r *ExclusionRule) Blocks(stage ExclusionStage) bool {
+	for _, blocked := range r.Stages {
+		if blocked == stage || (stage == ExcludeScreenshots && blocked == ExcludeWeb) {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary describes the rule in one line for logs and the audit trail
+func (r *ExclusionRule) Summary() string {
+	var target string
+	switch r.Scope {
+	case ExclusionNetwork:
+		target = "network " + r.NetworkID
+		if r.Address != "" {
+			target = r.Address + " in " + target
+		}
+	case ExclusionDevice:
+		target = "device " + r.DeviceID
+	default:
+		target = r.Address
+	}
+	stages := make([]string, len(r.Stages))
+	for i, stage := range r.Stages {
+		stages[i] = string(stage)
+	}
+	summary := fmt.Sprintf("%s rule on %s blocks %s", r.Scope, target, strings.Join(stages, ", "))
+	if r.Reason != "" {
+		summary += ": " + r.Reason
+	}
+	return summary
+}
+
+type ExclusionAuditAction string
+
+const (
+	ExclusionCreated ExclusionAuditAction = "created"
+	ExclusionUpdated ExclusionAuditAction = "updated"
+	ExclusionDeleted ExclusionAuditAction = "deleted"
+	// ExclusionBlocked entries count how often a rule kept a stage away from an address
+	ExclusionBlocked ExclusionAuditAction = "blocked"
+)
+
+// ExclusionAuditEntry records a change to a rule, or the stages it blocked
+type ExclusionAuditEntry struct {
+	ID     string               `json:"id"`
+	RuleID string               `json:"rule_id"`
+	Action ExclusionAuditAction `json:"action"`
+	Stage  ExclusionStage       `json:"stage,omitempty"`
+	Target string               `json:"target,omitempty"`
+	Actor  string               `json:"actor,omitempty"`
+	Detail string               `json:"detail,omitempty"`
+	// Count, FirstAt and LastAt cover repeated blocks, changes happen once
+	Count   int       `json:"count"`
+	FirstAt time.Time `json:"first_at"`
+	LastAt  time.Time `json:"last_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// DeviceIdentity holds the signals used to recognize a device whose MAC address
+// changes, such as phones and laptops using randomized per-network MACs
+type DeviceIdentity struct {
+	// MACRandomized is set when the MAC is locally administered, so it carries no vendor
+	MACRandomized bool   `bson:"mac_randomized,omitempty" json:"mac_randomized,omitempty"`
+	MDNSName      string `bson:"mdns_name,omitempty" json:"mdns_name,omitempty"`
+	DHCPClientID  string `bson:"dhcp_client_id,omitempty" json:"dhcp_client_id,omitempty"`
+	// PreviousMACs lists the MAC addresses the device was seen with before
+	PreviousMACs []string           `bson:"previous_macs,omitempty" json:"previous_macs,omitempty"`
+	Correlation  *DeviceCorrelation `bson:"correlation,omitempty" json:"correlation,omitempty"`
+}
+
+// DeviceCorrelation records how a device with a randomized MAC was matched to a
+// known device
+type DeviceCorrelation struct {
+	DeviceID string `bson:"device_id" json:"device_id"`
+	// Confidence ranges from 0 to 100
+	Confidence int      `bson:"confidence" json:"confidence"`
+	Signals    []string `bson:"signals" json:"signals"`
+	// Merged is false when the match was too weak or ambiguous and the device was
+	// kept separate, DeviceID then names the most likely candidate
+	Merged       bool      `bson:"merged" json:"merged"`
+	CorrelatedAt time.Time `bson:"correlated_at" json:"correlated_at"`
+}
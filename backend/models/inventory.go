@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Tag is a free-form label attached to any number of devices
+type Tag struct {
+	ID        string    `bson:"_id,omitempty" json:"id"`
+	Name      string    `bson:"name" json:"name"`
+	Color     string    `bson:"color,omitempty" json:"color,omitempty"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// DeviceGroup is a node in the group hierarchy, e.g. "Printers" under "Office/Floor 2"
+type DeviceGroup struct {
+	ID       string  `bson:"_id,omitempty" json:"id"`
+	Name     string  `bson:"name" json:"name"`
+	ParentID *string `bson:"parent_id,omitempty" json:"parent_id,omitempty"`
+	// Path is the full slash separated name, computed from the hierarchy
+	Path      string    `bson:"-" json:"path"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// GroupPathSeparator separates group names in a group path
+const GroupPathSeparator = "/"
+
+type CustomFieldType string
+
+const (
+	CustomFieldString CustomFieldType = "string"
+	CustomFieldNumber CustomFieldType = "number"
+	// CustomFieldDate values are stored as YYYY-MM-DD
+	CustomFieldDate CustomFieldType = "date"
+	CustomFieldEnum CustomFieldType = "enum"
+)
+
+// CustomField is an installation specific device attribute such as owner or asset tag
+type CustomField struct {
+	ID   string `bson:"_id,omitempty" json:"id"`
+	Name string `bson:"name" json:"name"`
+	// Label is shown in the UI and export headers, Name is used in filters and the API
+	Label     string          `bson:"label,omitempty" json:"label,omitempty"`
+	Type      CustomFieldType `bson:"type" json:"type"`
+	Options   []string        `bson:"options,omitempty" json:"options,omitempty"`
+	CreatedAt time.Time       `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"net"
+	"strings"
+	"time"
+)
+
+// IPv6AddressState tells whether an IPv6 address is still in use by its device
+type IPv6AddressState string
+
+const (
+	// IPv6AddressActive addresses were seen recently
+	IPv6AddressActive IPv6AddressState = "active"
+	// IPv6AddressStale addresses were not seen for a while, like privacy addresses
+	// past their preferred lifetime
+	IPv6AddressStale IPv6AddressState = "stale"
+	// IPv6AddressExpired addresses are no longer listed on their device
+	IPv6AddressExpired IPv6AddressState = "expired"
+)
+
+// Sources an IPv6 address can be learned from
+const (
+	IPv6SourceNDP       = "ndp"
+	IPv6SourceScan      = "scan"
+	IPv6SourceActive    = "active"
+	IPv6SourceMulticast = "multicast"
+	IPv6SourceInterface = "interface"
+)
+
+// DeviceIPv6Address is one IPv6 address of a device and when it was seen
+type DeviceIPv6Address struct {
+	Address   string           `json:"address"`
+	DeviceID  string           `json:"device_id"`
+	Type      string           `json:"type"` // "link-local", "unique-local", "global"
+	Source    string           `json:"source"`
+	State     IPv6AddressState `json:"state"`
+	FirstSeen time.Time        `json:"first_seen"`
+	LastSeen  time.Time        `json:"last_seen"`
+}
+
+// NormalizeIPv6 returns the canonical form of an IPv6 address without its zone, or
+// an empty string when the value is not an IPv6 address
+func NormalizeIPv6(address string) string {
+	ip := net.ParseIP(strings.SplitN(strings.TrimSpace(address), "%", 2)[0])
+	if ip == nil || ip.To4() != nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// IPv6AddressType classifies an IPv6 address as link-local, unique-local or global
+func IPv6AddressType(address string) string {
+	ip := net.ParseIP(NormalizeIPv6(address))
+	switch {
+	case ip == nil:
+		return ""
+	case ip.IsLinkLocalUnicast():
+		return "link-local"
+	case ip[0]&0xfe == 0xfc:
+		return "unique-local"
+	default:
+		return "global"
+	}
+}
@@ -27,6 +27,14 @@ type Network struct {
 	Status         string        `bson:"status" json:"status"` // active, inactive, scanning
 	LastScannedAt  *time.Time    `bson:"last_scanned_at" json:"last_scanned_at"`
 	DeviceCount    int           `bson:"device_count" json:"device_count"`
+	// Lockdown raises an alert for every device on the network whose MAC is not approved
+	Lockdown       bool          `bson:"lockdown" json:"lockdown"`
+	// DHCPAllowlist lists the DHCP servers expected to answer on the network
+	DHCPAllowlist  []DHCPExpectedServer `bson:"dhcp_allowlist,omitempty" json:"dhcp_allowlist,omitempty"`
+	// DHCPServer is the legitimate DHCP server last seen answering a probe
+	DHCPServer     *DHCPOffer    `bson:"dhcp_server,omitempty" json:"dhcp_server,omitempty"`
+	// ScanProfileID selects how the network is scanned, empty uses the standard profile
+	ScanProfileID  string        `bson:"scan_profile_id,omitempty" json:"scan_profile_id,omitempty"`
 	CreatedAt      time.Time     `bson:"created_at" json:"created_at"`
 	UpdatedAt      time.Time     `bson:"updated_at" json:"updated_at"`
 }
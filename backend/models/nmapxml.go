@@ -43,5 +43,8 @@ type NmapXMLState struct {
 
 // NmapXMLService represents the service of a port in the Nmap XML output
 type NmapXMLService struct {
-	Name string `xml:"name,attr"`
+	Name      string `xml:"name,attr"`
+	Product   string `xml:"product,attr"`
+	Version   string `xml:"version,attr"`
+	ExtraInfo string `xml:"extrainfo,attr"`
 }
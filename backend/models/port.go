@@ -6,4 +6,6 @@ type Port struct {
 	Protocol string `bson:"protocol" json:"protocol"` // Protocol (e.g., "tcp")
 	State    string `bson:"state" json:"state"`       // State (e.g., "open")
 	Service  string `bson:"service" json:"service"`   // Service name (e.g., "http")
+	// Version is the product and version nmap -sV identified, empty without it
+	Version string `bson:"version,omitempty" json:"version,omitempty"`
 }
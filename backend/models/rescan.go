@@ -0,0 +1,52 @@
+package models
+
+type RescanPortRange string
+
+const (
+	// RescanPortsNone skips the port scan and keeps the known ports
+	RescanPortsNone RescanPortRange = ""
+	// RescanPortsQuick scans the configured port list, as sweeps do
+	RescanPortsQuick RescanPortRange = "quick"
+	// RescanPortsFull scans all 65535 TCP ports
+	RescanPortsFull RescanPortRange = "full"
+)
+
+// RescanOptions selects the stages of an on-demand device rescan. With a
+// Profile, the stages come from that scan profile instead.
+type RescanOptions struct {
+	Profile         string          `json:"profile,omitempty"`
+	Ports           RescanPortRange `json:"ports,omitempty"`
+	ServiceVersions bool            `json:"service_versions,omitempty"`
+	OSDetection     bool            `json:"os_detection,omitempty"`
+	Web             bool            `json:"web,omitempty"`
+	Screenshots     bool            `json:"screenshots,omitempty"`
+}
+
+// FieldChange is a device attribute a rescan changed
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// PortChange is a port whose state, service or version changed
+type PortChange struct {
+	Before Port `json:"before"`
+	After  Port `json:"after"`
+}
+
+// DeviceDiff is what a rescan changed on a device
+type DeviceDiff struct {
+	Fields       []FieldChange `json:"fields,omitempty"`
+	PortsOpened  []Port        `json:"ports_opened,omitempty"`
+	PortsClosed  []Port        `json:"ports_closed,omitempty"`
+	PortsChanged []PortChange  `json:"ports_changed,omitempty"`
+	WebAdded     []string      `json:"web_added,omitempty"`
+	WebRemoved   []string      `json:"web_removed,omitempty"`
+}
+
+// Empty reports whether the rescan changed nothing
+func (d DeviceDiff) Empty() bool {
+	return len(d.Fields) == 0 && len(d.PortsOpened) == 0 && len(d.PortsClosed) == 0 &&
+		len(d.PortsChanged) == 0 && len(d.WebAdded) == 0 && len(d.WebRemoved) == 0
+}
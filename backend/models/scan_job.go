@@ -0,0 +1,73 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type ScanJobType string
+
+const (
+	ScanJobPortScan    ScanJobType = "port_scan"
+	ScanJobWebScan     ScanJobType = "web_scan"
+	ScanJobFingerprint ScanJobType = "fingerprint"
+	// ScanJobRescan runs the stages selected for an on-demand device rescan
+	ScanJobRescan ScanJobType = "rescan"
+)
+
+// ScanJobTypes lists the job types in the order a device goes through them
+var ScanJobTypes = []ScanJobType{ScanJobPortScan, ScanJobFingerprint, ScanJobWebScan, ScanJobRescan}
+
+type ScanJobStatus string
+
+const (
+	ScanJobQueued    ScanJobStatus = "queued"
+	ScanJobRunning   ScanJobStatus = "running"
+	ScanJobCompleted ScanJobStatus = "completed"
+	ScanJobFailed    ScanJobStatus = "failed"
+	ScanJobCancelled ScanJobStatus = "cancelled"
+)
+
+// Active reports whether the job still waits or runs
+func (s ScanJobStatus) Active() bool {
+	return s == ScanJobQueued || s == ScanJobRunning
+}
+
+// Jobs with a higher priority run first, manual rescans jump ahead of the sweeps
+const (
+	ScanJobPriorityNormal = 0
+	ScanJobPriorityManual = 10
+)
+
+// ScanJob is one unit of work for a device in the persistent scan queue
+type ScanJob struct {
+	ID          string        `bson:"_id,omitempty" json:"id"`
+	Type        ScanJobType   `bson:"type" json:"type"`
+	DeviceID    string        `bson:"device_id" json:"device_id"`
+	Priority    int           `bson:"priority" json:"priority"`
+	Status      ScanJobStatus `bson:"status" json:"status"`
+	Attempts    int           `bson:"attempts" json:"attempts"`
+	MaxAttempts int           `bson:"max_attempts" json:"max_attempts"`
+	// RunAfter delays the job, retries are pushed back by the queue's backoff
+	RunAfter time.Time `bson:"run_after" json:"run_after"`
+	Error    string    `bson:"error,omitempty" json:"error,omitempty"`
+	// Payload holds options specific to the job type
+	Payload json.RawMessage `bson:"payload,omitempty" json:"payload,omitempty"`
+	// Progress is 0 to 100 and Stage names what the job is doing, for long jobs
+	Progress int    `bson:"progress" json:"progress"`
+	Stage    string `bson:"stage,omitempty" json:"stage,omitempty"`
+	// Result is what a finished job reports back, such as the changes of a rescan
+	Result     json.RawMessage `bson:"result,omitempty" json:"result,omitempty"`
+	CreatedAt  time.Time       `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time       `bson:"updated_at" json:"updated_at"`
+	StartedAt  *time.Time      `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	FinishedAt *time.Time      `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
+
+// ScanJobFilter narrows a job listing, empty fields match everything
+type ScanJobFilter struct {
+	Type     ScanJobType
+	Status   ScanJobStatus
+	DeviceID string
+	Limit    int
+}
@@ -0,0 +1,150 @@
+package models
+
+import (
+	"strconv"
+	"time"
+)
+
+type DiscoveryMethod string
+
+const (
+	// DiscoveryICMP pings with IP packets, as root first to also get MAC addresses
+	DiscoveryICMP DiscoveryMethod = "icmp"
+	// DiscoveryARP asks every address for its MAC, local networks only
+	DiscoveryARP DiscoveryMethod = "arp"
+	// DiscoveryTCP probes the discovery ports, for hosts that drop pings
+	DiscoveryTCP DiscoveryMethod = "tcp"
+	// DiscoveryNative uses the built-in scanner instead of nmap
+	DiscoveryNative DiscoveryMethod = "native"
+)
+
+// DiscoveryMethods lists the methods a profile can use
+var DiscoveryMethods = []DiscoveryMethod{DiscoveryICMP, DiscoveryARP, DiscoveryTCP, DiscoveryNative}
+
+// DefaultScanProfileName is the profile of networks and rescans that have none
+const DefaultScanProfileName = "standard"
+
+// ScanProfile controls how hosts are discovered, which ports are scanned, how
+// fast, and which enrichers run afterwards
+type ScanProfile struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Builtin profiles are seeded at startup and cannot be changed or deleted
+	Builtin bool `json:"builtin"`
+	// Discovery methods are tried in order until one finds hosts
+	Discovery []DiscoveryMethod `json:"discovery"`
+	// DiscoveryPorts are probed by the tcp discovery method
+	DiscoveryPorts string `json:"discovery_ports,omitempty"`
+	// DiscoveryTimeout bounds a sweep in seconds, 0 uses the configured timeouts
+	DiscoveryTimeout int `json:"discovery_timeout,omitempty"`
+	// PortScan off stops at discovery, Ports empty scans the configured list
+	PortScan bool   `json:"port_scan"`
+	Ports    string `json:"ports,omitempty"`
+	// PortScanTimeout bounds the port scan of a host in seconds, 0 uses the configured timeout
+	PortScanTimeout int `json:"port_scan_timeout,omitempty"`
+	// Timing is the nmap timing template, 0 (paranoid) to 5 (insane)
+	Timing int `json:"timing"`
+	// MaxRate caps the packets sent per second, 0 leaves it to the timing
+	MaxRate int `json:"max_rate,omitempty"`
+	// ScanDelay is the wait between probes to a host in milliseconds
+	ScanDelay       int       `json:"scan_delay,omitempty"`
+	ServiceVersions bool      `json:"service_versions"`
+	OSDetection     bool      `json:"os_detection"`
+	Web             bool      `json:"web"`
+	Screenshots     bool      `json:"screenshots"`
+	SNMP            bool      `json:"snmp"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// NmapTimingArgs are the nmap options for the timing and rate limits
+func (p *ScanProfile) NmapTimingArgs() []string {
+	args := []string{"-T" + strconv.Itoa(p.Timing)}
+	if p.MaxRate > 0 {
+		args = append(args, "--max-rate", strconv.Itoa(p.MaxRate))
+	}
+	if p.ScanDelay > 0 {
+		args = append(args, "--scan-delay", strconv.Itoa(p.ScanDelay)+"ms")
+	}
+	return args
+}
+
+// BuiltinScanProfiles returns the profiles seeded at startup, in order of how
+// much they do, then the careful ones
+func BuiltinScanProfiles() []ScanProfile {
+	profiles := []ScanProfile{
+		{
+			Name:            "quick",
+			Description:     "Fast sweep and the most common ports, no OS detection",
+			Discovery:       []DiscoveryMethod{DiscoveryICMP, DiscoveryARP},
+			PortScan:        true,
+			Ports:           "21-23,25,53,80,110,135,139,143,443,445,993,995,3306,3389,5900,8080,8443",
+			PortScanTimeout: 60,
+			Timing:          4,
+			Web:             true,
+		},
+		{
+			Name:           "standard",
+			Description:    "What every new device gets: the configured ports, OS detection, web pages and SNMP",
+			Discovery:      []DiscoveryMethod{DiscoveryICMP, DiscoveryARP, DiscoveryTCP},
+			DiscoveryPorts: "21-23,25,53,80,110,111,135,139,143,443,993,995",
+			PortScan:       true,
+			Timing:         4,
+			OSDetection:    true,
+			Web:            true,
+			SNMP:           true,
+		},
+		{
+			Name:            "deep",
+			Description:     "All 65535 ports with service versions, OS detection, web pages, screenshots and SNMP",
+			Discovery:       []DiscoveryMethod{DiscoveryICMP, DiscoveryARP, DiscoveryTCP},
+			DiscoveryPorts:  "21-23,25,53,80,110,111,135,139,143,443,993,995",
+			PortScan:        true,
+			Ports:           "1-65535",
+			PortScanTimeout: 1200,
+			Timing:          4,
+			ServiceVersions: true,
+			OSDetection:     true,
+			Web:             true,
+			Screenshots:     true,
+			SNMP:            true,
+		},
+		{
+			Name:             "stealthy",
+			Description:      "Slow and rate limited TCP probes, for monitored or congested networks",
+			Discovery:        []DiscoveryMethod{DiscoveryTCP},
+			DiscoveryPorts:   "22,80,443",
+			DiscoveryTimeout: 900,
+			PortScan:         true,
+			PortScanTimeout:  1800,
+			Timing:           2,
+			MaxRate:          20,
+			ScanDelay:        500,
+			Web:              true,
+		},
+		{
+			Name:             "ot-safe",
+			Description:      "Discovery only at a low rate, for PLCs, medical and other fragile devices",
+			Discovery:        []DiscoveryMethod{DiscoveryARP, DiscoveryICMP},
+			DiscoveryTimeout: 300,
+			Timing:           2,
+			MaxRate:          10,
+		},
+	}
+	for i := range profiles {
+		profiles[i].Builtin = true
+	}
+	return profiles
+}
+
+// DefaultScanProfile returns the standard profile as built in, for scans that
+// run without a database
+func DefaultScanProfile() *ScanProfile {
+	for _, profile := range BuiltinScanProfiles() {
+		if profile.Name == DefaultScanProfileName {
+			return &profile
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package models
+
+import "time"
+
+type SNMPVersion string
+
+const (
+	SNMPVersion1  SNMPVersion = "1"
+	SNMPVersion2c SNMPVersion = "2c"
+	SNMPVersion3  SNMPVersion = "3"
+)
+
+// SNMPCredential holds the credentials used to query devices on a network.
+// Community and passphrases are encrypted at rest and never returned by the API.
+type SNMPCredential struct {
+	ID             string      `bson:"_id,omitempty" json:"id"`
+	NetworkID      string      `bson:"network_id" json:"network_id"`
+	Version        SNMPVersion `bson:"version" json:"version"`
+	Port           int         `bson:"port" json:"port"`
+	Community      string      `bson:"community,omitempty" json:"community,omitempty"`
+	Username       string      `bson:"username,omitempty" json:"username,omitempty"`
+	AuthProtocol   string      `bson:"auth_protocol,omitempty" json:"auth_protocol,omitempty"`
+	AuthPassphrase string      `bson:"auth_passphrase,omitempty" json:"auth_passphrase,omitempty"`
+	PrivProtocol   string      `bson:"priv_protocol,omitempty" json:"priv_protocol,omitempty"`
+	PrivPassphrase string      `bson:"priv_passphrase,omitempty" json:"priv_passphrase,omitempty"`
+	CreatedAt      time.Time   `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time   `bson:"updated_at" json:"updated_at"`
+}
+
+// Redacted returns a copy of the credential without secrets, safe for API responses
+func (c SNMPCredential) Redacted() SNMPCredential {
+	if c.Community != "" {
+		c.Community = "********"
+	}
+	if c.AuthPassphrase != "" {
+		c.AuthPassphrase = "********"
+	}
+	if c.PrivPassphrase != "" {
+		c.PrivPassphrase = "********"
+	}
+	return c
+}
+
+// SNMPInfo holds the system and interface data read from a device over SNMP
+type SNMPInfo struct {
+	SysName       string          `bson:"sys_name,omitempty" json:"sys_name,omitempty"`
+	SysDescr      string          `bson:"sys_descr,omitempty" json:"sys_descr,omitempty"`
+	SysObjectID   string          `bson:"sys_object_id,omitempty" json:"sys_object_id,omitempty"`
+	SysLocation   string          `bson:"sys_location,omitempty" json:"sys_location,omitempty"`
+	SysContact    string          `bson:"sys_contact,omitempty" json:"sys_contact,omitempty"`
+	UptimeSeconds int64           `bson:"uptime_seconds,omitempty" json:"uptime_seconds,omitempty"`
+	Version       SNMPVersion     `bson:"version" json:"version"`
+	Interfaces    []SNMPInterface `bson:"interfaces,omitempty" json:"interfaces,omitempty"`
+	Neighbors     []SNMPNeighbor  `bson:"neighbors,omitempty" json:"neighbors,omitempty"`
+	PolledAt      time.Time       `bson:"polled_at" json:"polled_at"`
+}
+
+// SNMPInterface is a row of the IF-MIB ifTable/ifXTable
+type SNMPInterface struct {
+	Index       int    `bson:"index" json:"index"`
+	Name        string `bson:"name,omitempty" json:"name,omitempty"`
+	Descr       string `bson:"descr,omitempty" json:"descr,omitempty"`
+	Alias       string `bson:"alias,omitempty" json:"alias,omitempty"`
+	Type        int    `bson:"type,omitempty" json:"type,omitempty"`
+	MTU         int    `bson:"mtu,omitempty" json:"mtu,omitempty"`
+	SpeedMbps   int64  `bson:"speed_mbps,omitempty" json:"speed_mbps,omitempty"`
+	MAC         string `bson:"mac,omitempty" json:"mac,omitempty"`
+	AdminStatus string `bson:"admin_status,omitempty" json:"admin_status,omitempty"`
+	OperStatus  string `bson:"oper_status,omitempty" json:"oper_status,omitempty"`
+}
+
+type NeighborProtocol string
+
+const (
+	NeighborProtocolLLDP NeighborProtocol = "lldp"
+	NeighborProtocolCDP  NeighborProtocol = "cdp"
+)
+
+// SNMPNeighbor is an LLDP or CDP neighbor reported by a device
+type SNMPNeighbor struct {
+	Protocol       NeighborProtocol `bson:"protocol" json:"protocol"`
+	LocalIfIndex   int              `bson:"local_if_index,omitempty" json:"local_if_index,omitempty"`
+	LocalPort      string           `bson:"local_port,omitempty" json:"local_port,omitempty"`
+	ChassisID      string           `bson:"chassis_id,omitempty" json:"chassis_id,omitempty"`
+	RemotePort     string           `bson:"remote_port,omitempty" json:"remote_port,omitempty"`
+	RemoteSysName  string           `bson:"remote_sys_name,omitempty" json:"remote_sys_name,omitempty"`
+	RemotePlatform string           `bson:"remote_platform,omitempty" json:"remote_platform,omitempty"`
+	RemoteAddress  string           `bson:"remote_address,omitempty" json:"remote_address,omitempty"`
+}
+
+// SwitchPortSource tells how a device's switch port was learned
+type SwitchPortSource string
+
+const (
+	SwitchPortSourceFDB  SwitchPortSource = "fdb"
+	SwitchPortSourceLLDP SwitchPortSource = "lldp"
+	SwitchPortSourceCDP  SwitchPortSource = "cdp"
+)
+
+// SwitchPortInfo records the switch port a device is attached to
+type SwitchPortInfo struct {
+	SwitchDeviceID string           `bson:"switch_device_id" json:"switch_device_id"`
+	SwitchName     string           `bson:"switch_name,omitempty" json:"switch_name,omitempty"`
+	SwitchIP       string           `bson:"switch_ip" json:"switch_ip"`
+	IfIndex        int              `bson:"if_index,omitempty" json:"if_index,omitempty"`
+	Port           string           `bson:"port,omitempty" json:"port,omitempty"`
+	VLAN           int              `bson:"vlan,omitempty" json:"vlan,omitempty"`
+	Source         SwitchPortSource `bson:"source" json:"source"`
+	SeenAt         time.Time        `bson:"seen_at" json:"seen_at"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+type TopologyLinkType string
+
+const (
+	TopologyLinkL2       TopologyLinkType = "l2"
+	TopologyLinkL3       TopologyLinkType = "l3"
+	TopologyLinkWireless TopologyLinkType = "wireless"
+)
+
+// TopologyEvidence tells which data source a link was inferred from
+type TopologyEvidence string
+
+const (
+	TopologyEvidenceLLDP       TopologyEvidence = "lldp"
+	TopologyEvidenceCDP        TopologyEvidence = "cdp"
+	TopologyEvidenceFDB        TopologyEvidence = "fdb"
+	TopologyEvidenceGateway    TopologyEvidence = "gateway"
+	TopologyEvidenceTraceroute TopologyEvidence = "traceroute"
+)
+
+type TopologyNodeKind string
+
+const (
+	// TopologyNodeDevice is a device known to reconya
+	TopologyNodeDevice TopologyNodeKind = "device"
+	// TopologyNodeNeighbor is an LLDP/CDP neighbor that was never discovered by a scan
+	TopologyNodeNeighbor TopologyNodeKind = "neighbor"
+	// TopologyNodeHop is a traceroute hop that is not a known device
+	TopologyNodeHop TopologyNodeKind = "hop"
+)
+
+type TopologyNode struct {
+	ID         string           `json:"id"`
+	Kind       TopologyNodeKind `json:"kind"`
+	Label      string           `json:"label"`
+	DeviceID   string           `json:"device_id,omitempty"`
+	IPv4       string           `json:"ipv4,omitempty"`
+	MAC        string           `json:"mac,omitempty"`
+	DeviceType DeviceType       `json:"device_type,omitempty"`
+	Status     DeviceStatus     `json:"status,omitempty"`
+	NetworkID  string           `json:"network_id,omitempty"`
+	Gateway    bool             `json:"gateway,omitempty"`
+}
+
+type TopologyLink struct {
+	Source     string           `json:"source"`
+	Target     string           `json:"target"`
+	Type       TopologyLinkType `json:"type"`
+	Evidence   TopologyEvidence `json:"evidence"`
+	SourcePort string           `json:"source_port,omitempty"`
+	TargetPort string           `json:"target_port,omitempty"`
+	VLAN       int              `json:"vlan,omitempty"`
+}
+
+// Topology is a graph of devices and the links inferred between them
+type Topology struct {
+	Nodes       []TopologyNode `json:"nodes"`
+	Links       []TopologyLink `json:"links"`
+	GeneratedAt time.Time      `json:"generated_at"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// TrustState records whether a device has been authorized to be on the network
+type TrustState string
+
+const (
+	// TrustStateNew is the state of every device the sweep finds that is not in the baseline
+	TrustStateNew      TrustState = "new"
+	TrustStateApproved TrustState = "approved"
+	// TrustStateBlocked marks a quarantined device, it raises an alert whenever it is seen
+	TrustStateBlocked TrustState = "blocked"
+)
+
+// BaselineEntry is an authorized MAC address. Devices with a baseline MAC are approved
+// automatically when they are seen.
+type BaselineEntry struct {
+	ID  string `bson:"_id,omitempty" json:"id"`
+	MAC string `bson:"mac" json:"mac"`
+	// NetworkID limits the entry to one network, empty means every network
+	NetworkID string    `bson:"network_id,omitempty" json:"network_id,omitempty"`
+	Label     string    `bson:"label,omitempty" json:"label,omitempty"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// TrustReport lists the devices on a network that are not approved
+type TrustReport struct {
+	NetworkID   string    `json:"network_id"`
+	NetworkName string    `json:"network_name"`
+	CIDR        string    `json:"cidr"`
+	Lockdown    bool      `json:"lockdown"`
+	New         []*Device `json:"new"`
+	Blocked     []*Device `json:"blocked"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// UPnPInfo holds the device description collected through SSDP/UPnP discovery
+type UPnPInfo struct {
+	Location     string            `bson:"location" json:"location"`
+	Server       string            `bson:"server,omitempty" json:"server,omitempty"`
+	DeviceType   string            `bson:"device_type,omitempty" json:"device_type,omitempty"`
+	UDN          string            `bson:"udn,omitempty" json:"udn,omitempty"`
+	FriendlyName string            `bson:"friendly_name,omitempty" json:"friendly_name,omitempty"`
+	Manufacturer string            `bson:"manufacturer,omitempty" json:"manufacturer,omitempty"`
+	ModelName    string            `bson:"model_name,omitempty" json:"model_name,omitempty"`
+	ModelNumber  string            `bson:"model_number,omitempty" json:"model_number,omitempty"`
+	SerialNumber string            `bson:"serial_number,omitempty" json:"serial_number,omitempty"`
+	Services     []UPnPService     `bson:"services,omitempty" json:"services,omitempty"`
+	PortMappings []UPnPPortMapping `bson:"port_mappings,omitempty" json:"port_mappings,omitempty"`
+	DiscoveredAt time.Time         `bson:"discovered_at" json:"discovered_at"`
+}
+
+// UPnPService is a service advertised in a UPnP device description
+type UPnPService struct {
+	ServiceType string `bson:"service_type" json:"service_type"`
+	ServiceID   string `bson:"service_id,omitempty" json:"service_id,omitempty"`
+	ControlURL  string `bson:"control_url,omitempty" json:"control_url,omitempty"`
+	SCPDURL     string `bson:"scpd_url,omitempty" json:"scpd_url,omitempty"`
+}
+
+// UPnPPortMapping is an active port forwarding entry reported by an Internet Gateway Device
+type UPnPPortMapping struct {
+	RemoteHost     string `bson:"remote_host,omitempty" json:"remote_host,omitempty"`
+	ExternalPort   int    `bson:"external_port" json:"external_port"`
+	Protocol       string `bson:"protocol" json:"protocol"`
+	InternalPort   int    `bson:"internal_port" json:"internal_port"`
+	InternalClient string `bson:"internal_client" json:"internal_client"`
+	Enabled        bool   `bson:"enabled" json:"enabled"`
+	Description    string `bson:"description,omitempty" json:"description,omitempty"`
+	LeaseDuration  int    `bson:"lease_duration,omitempty" json:"lease_duration,omitempty"`
+}
+
+// IsInternetGateway reports whether the description advertises an IGD WAN connection service
+func (u *UPnPInfo) IsInternetGateway() bool {
+	for _, service := range u.Services {
+		if IsWANConnectionService(service.ServiceType) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsWANConnectionService reports whether a service type is an IGD WANIPConnection or WANPPPConnection
+func IsWANConnectionService(serviceType string) bool {
+	return strings.HasPrefix(serviceType, "urn:schemas-upnp-org:service:WANIPConnection:") ||
+		strings.HasPrefix(serviceType, "urn:schemas-upnp-org:service:WANPPPConnection:")
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// WakeOptions controls how a Wake-on-LAN magic packet is sent
+type WakeOptions struct {
+	// Port is the UDP destination port, 9 (discard) when unset
+	Port int `bson:"port,omitempty" json:"port,omitempty"`
+	// Interface sends the packet from the named local interface
+	Interface string `bson:"interface,omitempty" json:"interface,omitempty"`
+	// BroadcastAddress overrides the directed broadcast address of the device's network
+	BroadcastAddress string `bson:"broadcast_address,omitempty" json:"broadcast_address,omitempty"`
+	// SecureOnPassword is an optional 4 or 6 byte password appended to the packet
+	SecureOnPassword string `bson:"secure_on_password,omitempty" json:"secure_on_password,omitempty"`
+}
+
+// WakeResult describes a magic packet sent to a single device
+type WakeResult struct {
+	DeviceID string    `json:"device_id"`
+	IPv4     string    `json:"ipv4,omitempty"`
+	MAC      string    `json:"mac,omitempty"`
+	Address  string    `json:"address,omitempty"`
+	SentAt   time.Time `json:"sent_at"`
+	Error    string    `json:"error,omitempty"`
+}
+
+type WakeScheduleStatus string
+
+const (
+	WakeSchedulePending   WakeScheduleStatus = "pending"
+	WakeScheduleCompleted WakeScheduleStatus = "completed"
+	WakeScheduleFailed    WakeScheduleStatus = "failed"
+	WakeScheduleCancelled WakeScheduleStatus = "cancelled"
+)
+
+// WakeSchedule wakes a set of devices, or every device of a network, at a given time
+type WakeSchedule struct {
+	ID        string             `bson:"_id,omitempty" json:"id"`
+	DeviceIDs []string           `bson:"device_ids,omitempty" json:"device_ids,omitempty"`
+	NetworkID string             `bson:"network_id,omitempty" json:"network_id,omitempty"`
+	RunAt     time.Time          `bson:"run_at" json:"run_at"`
+	Options   WakeOptions        `bson:"options" json:"options"`
+	Status    WakeScheduleStatus `bson:"status" json:"status"`
+	Error     string             `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
@@ -219,13 +219,24 @@ function renderDeviceModal(device, screenshotsEnabled = false) {
                     <h4 class="text-green-500 font-semibold mb-3">Device Info</h4>
                     <div class="space-y-3 text-sm">
                         <div><span style="color: var(--text-muted);">IP Address:</span> <span class="device-ip" style="color: var(--text-primary); font-size: 1rem;">${device.ipv4}</span></div>
-                        ${device.mac ? `<div><span style="color: var(--text-muted);">MAC Address:</span> <span class="text-blue-400">${device.mac}</span></div>` : ''}
+                        ${device.mac ? `<div><span style="color: var(--text-muted);">MAC Address:</span> <span class="text-blue-400">${device.mac}</span> <button type="button" class="ml-2 px-2 py-0.5 rounded text-xs border border-green-500 text-green-500 hover:bg-green-500 hover:text-white transition-colors" onclick="wakeDevice('${device.id}', '${device.ipv4}')" title="Send Wake-on-LAN magic packet"><i class="ti ti-power"></i> Wake</button></div>` : ''}
+                        ${device.identity && device.identity.mac_randomized ? `<div><span style="color: var(--text-muted);">MAC Type:</span> <span class="px-2 py-1 rounded text-xs border border-yellow-500 text-yellow-500" title="Locally administered address, no vendor lookup">Randomized MAC</span></div>` : ''}
+                        ${device.identity && device.identity.correlation ? `<div><span style="color: var(--text-muted);">Correlation:</span> <span style="color: var(--text-primary);">${device.identity.correlation.confidence}% ${device.identity.correlation.merged ? 'matched' : 'possible match'} (${device.identity.correlation.signals.join(', ')})</span></div>` : ''}
+                        ${device.identity && device.identity.previous_macs && device.identity.previous_macs.length ? `<div><span style="color: var(--text-muted);">Previous MACs:</span> ${device.identity.previous_macs.map(mac => `<span class="text-blue-400">${mac}</span> <button type="button" class="px-2 py-0.5 rounded text-xs border border-yellow-500 text-yellow-500 hover:bg-yellow-500 hover:text-white transition-colors" onclick="splitDevice('${device.id}', '${mac}')" title="Move this MAC address to a separate device">Split</button>`).join(' ')}</div>` : ''}
+                        ${device.identity && device.identity.mdns_name ? `<div><span style="color: var(--text-muted);">mDNS Name:</span> <span style="color: var(--text-primary);">${device.identity.mdns_name}</span></div>` : ''}
                         ${device.hostname ? `<div><span style="color: var(--text-muted);">Hostname:</span> <span style="color: var(--text-primary);">${device.hostname}</span></div>` : ''}
                         <div><span style="color: var(--text-muted);">Status:</span> <span class="px-2 py-1 rounded text-xs ${getStatusBadgeColor(device.status)}">${device.status}</span></div>
                         ${device.LastSeenOnlineAt ? `<div><span style="color: var(--text-muted);">Last Seen:</span> <span style="color: var(--text-primary);">${formatLogTime(device.LastSeenOnlineAt)}</span></div>` : ''}
+                        <div><span style="color: var(--text-muted);">Trust:</span> <span class="px-2 py-1 rounded text-xs ${getTrustBadgeColor(device.trust_state)}">${device.trust_state || 'new'}</span>
+                            ${device.trust_state !== 'approved' ? `<button type="button" class="ml-2 px-2 py-0.5 rounded text-xs border border-green-500 text-green-500 hover:bg-green-500 hover:text-white transition-colors" onclick="setDeviceTrust('${device.id}', 'approved')">Approve</button>` : ''}
+                            ${device.trust_state !== 'blocked' ? `<button type="button" class="ml-1 px-2 py-0.5 rounded text-xs border border-red-500 text-red-500 hover:bg-red-500 hover:text-white transition-colors" onclick="setDeviceTrust('${device.id}', 'blocked')">Block</button>` : ''}
+                        </div>
+                        ${device.tags && device.tags.length ? `<div><span style="color: var(--text-muted);">Tags:</span> ${device.tags.map(tag => `<span class="px-2 py-0.5 rounded text-xs border border-blue-400 text-blue-400">${tag}</span>`).join(' ')}</div>` : ''}
+                        ${device.groups && device.groups.length ? `<div><span style="color: var(--text-muted);">Groups:</span> <span style="color: var(--text-primary);">${device.groups.join(', ')}</span></div>` : ''}
+                        ${device.custom_fields ? Object.entries(device.custom_fields).map(([name, value]) => `<div><span style="color: var(--text-muted);">${name}:</span> <span style="color: var(--text-primary);">${value}</span></div>`).join('') : ''}
                     </div>
                 </div>
-                
+
                 ${device.os ? `
                     <div>
                         <h4 class="text-green-500 font-semibold mb-2">Operating System</h4>
@@ -393,6 +404,78 @@ function deleteDevice(deviceId, deviceIP) {
     }
 }
 
+function wakeDevice(deviceId, deviceIP) {
+    fetch(`/api/devices/${deviceId}/wake`, {
+        method: 'POST',
+        credentials: 'include'
+    })
+    .then(response => response.json())
+    .then(data => {
+        if (data.success) {
+            alert(`Magic packet sent to ${deviceIP}. The next sweep will show whether it came up.`);
+        } else {
+            alert(data.error || 'Failed to send Wake-on-LAN packet');
+        }
+    })
+    .catch(error => {
+        console.error('Error waking device:', error);
+        alert('Failed to send Wake-on-LAN packet');
+    });
+}
+
+function getTrustBadgeColor(state) {
+    switch (state) {
+        case 'approved': return 'bg-green-600 text-white';
+        case 'blocked': return 'bg-red-600 text-white';
+        default: return 'bg-yellow-600 text-white';
+    }
+}
+
+function setDeviceTrust(deviceId, state) {
+    const body = new URLSearchParams({ state: state });
+    fetch(`/api/devices/${deviceId}/trust`, {
+        method: 'POST',
+        credentials: 'include',
+        body: body
+    })
+    .then(response => response.json())
+    .then(data => {
+        if (data.success) {
+            loadDeviceModal(deviceId);
+        } else {
+            alert(data.error || 'Failed to update trust state');
+        }
+    })
+    .catch(error => {
+        console.error('Error updating trust state:', error);
+        alert('Failed to update trust state');
+    });
+}
+
+function splitDevice(deviceId, mac) {
+    if (!confirm(`Move MAC ${mac} to a separate device?`)) {
+        return;
+    }
+    const body = new URLSearchParams({ mac: mac });
+    fetch(`/api/devices/${deviceId}/split`, {
+        method: 'POST',
+        credentials: 'include',
+        body: body
+    })
+    .then(response => response.json())
+    .then(data => {
+        if (data.success) {
+            loadDeviceModal(deviceId);
+        } else {
+            alert(data.error || 'Failed to split device');
+        }
+    })
+    .catch(error => {
+        console.error('Error splitting device:', error);
+        alert('Failed to split device');
+    });
+}
+
 function loadDeviceList() {
     const targetEl = document.getElementById('device-list-container');
     if (targetEl) {
@@ -479,4 +562,5 @@ window.renderDeviceModal = renderDeviceModal;
 window.getStatusBadgeColor = getStatusBadgeColor;
 window.formatLogTime = formatLogTime;
 window.saveDeviceChanges = saveDeviceChanges;
-window.deleteDevice = deleteDevice;
\ No newline at end of file
+window.deleteDevice = deleteDevice;
+window.setDeviceTrust = setDeviceTrust;
\ No newline at end of file
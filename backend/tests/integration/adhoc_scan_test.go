@@ -0,0 +1,146 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/adhoc"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/network"
+	"reconya-ai/models"
+	"reconya-ai/tests/testutils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdhocScanService_Integration(t *testing.T) {
+	factory, cleanup := testutils.SetupTestRepositoryFactory(t)
+	defer cleanup()
+
+	cfg := testutils.GetTestConfig()
+	dbManager := db.NewDBManager()
+	networkRepo := factory.NewNetworkRepository()
+	networkService := network.NewNetworkService(networkRepo, cfg, dbManager)
+	deviceService := device.NewDeviceService(factory.NewDeviceRepository(), networkService, cfg, dbManager, nil)
+	repo := factory.NewAdhocScanRepository()
+	service := adhoc.NewAdhocScanService(repo, nil, networkService, deviceService, nil, 2)
+
+	ctx := context.Background()
+	scratchScan := func(status models.AdhocScanStatus, ips ...string) *models.AdhocScan {
+		var devices []models.Device
+		for _, ip := range ips {
+			hostname := "peer-" + ip
+			devices = append(devices, models.Device{
+				IPv4:       ip,
+				Hostname:   &hostname,
+				Status:     models.DeviceStatusOnline,
+				DeviceType: models.DeviceTypeUnknown,
+				Ports:      []models.Port{{Number: "22", Protocol: "tcp", State: "open", Service: "ssh"}},
+			})
+		}
+		scan, err := repo.Create(ctx, &models.AdhocScan{Targets: []string{"10.8.0.0/24"}, Profile: "standard", Status: status, Devices: devices})
+		require.NoError(t, err)
+		return scan
+	}
+
+	t.Run("Results stay out of the inventory", func(t *testing.T) {
+		scan := scratchScan(models.AdhocScanCompleted, "10.8.0.5")
+		stored, err := service.FindByID(scan.ID)
+		require.NoError(t, err)
+		require.Len(t, stored.Devices, 1)
+		assert.Equal(t, "22", stored.Devices[0].Ports[0].Number)
+
+		inventory, err := deviceService.FindByIPv4("10.8.0.5")
+		require.NoError(t, err)
+		assert.Nil(t, inventory)
+	})
+
+	t.Run("Rejects bad targets and profiles", func(t *testing.T) {
+		_, err := service.Start("printer.lan", "standard")
+		assert.Error(t, err)
+		_, err = service.Start("10.8.0.5", "turbo")
+		assert.Error(t, err)
+	})
+
+	t.Run("Promotes into an existing network and keeps devices outside it", func(t *testing.T) {
+		target, err := networkRepo.CreateOrUpdate(ctx, &models.Network{ID: uuid.New().String(), CIDR: "192.168.77.0/24"})
+		require.NoError(t, err)
+		scan := scratchScan(models.AdhocScanCompleted, "192.168.77.10", "10.9.0.1")
+
+		promotion, err := service.Promote(scan.ID, target.ID, "", nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"192.168.77.10"}, promotion.Promoted)
+		require.Len(t, promotion.Skipped, 1)
+		assert.Contains(t, promotion.Skipped[0], "10.9.0.1")
+
+		promoted, err := deviceService.FindByIPv4("192.168.77.10")
+		require.NoError(t, err)
+		assert.Equal(t, target.ID, promoted.NetworkID)
+
+		stored, err := service.FindByID(scan.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.AdhocScanCompleted, stored.Status)
+		require.Len(t, stored.Devices, 1)
+		assert.Equal(t, "10.9.0.1", stored.Devices[0].IPv4)
+	})
+
+	t.Run("Promotes a single peer into a new /32 network", func(t *testing.T) {
+		scan := scratchScan(models.AdhocScanCompleted, "10.66.0.2")
+
+		promotion, err := service.Promote(scan.ID, "", "10.66.0.2", nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"10.66.0.2"}, promotion.Promoted)
+
+		created, err := networkService.FindByCIDR("10.66.0.2/32")
+		require.NoError(t, err)
+		require.NotNil(t, created)
+		assert.Equal(t, created.ID, promotion.NetworkID)
+
+		stored, err := service.FindByID(scan.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.AdhocScanPromoted, stored.Status)
+		assert.Equal(t, created.ID, stored.NetworkID)
+		assert.Empty(t, stored.Devices)
+	})
+
+	t.Run("Refuses to promote running or empty scans", func(t *testing.T) {
+		_, err := service.Promote(scratchScan(models.AdhocScanRunning, "10.8.0.9").ID, "", "10.8.0.0/24", nil)
+		assert.Error(t, err)
+		_, err = service.Promote(scratchScan(models.AdhocScanCompleted).ID, "", "10.8.0.0/24", nil)
+		assert.Error(t, err)
+		_, err = service.Promote(scratchScan(models.AdhocScanCompleted, "10.8.0.9").ID, "", "", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("Discard deletes the scan", func(t *testing.T) {
+		scan := scratchScan(models.AdhocScanCompleted, "10.8.0.7")
+		require.NoError(t, service.Discard(scan.ID))
+		stored, err := service.FindByID(scan.ID)
+		require.NoError(t, err)
+		assert.Nil(t, stored)
+		assert.Error(t, service.Discard(scan.ID))
+	})
+
+	t.Run("Scans interrupted by a restart are marked failed", func(t *testing.T) {
+		scan := scratchScan(models.AdhocScanRunning, "10.8.0.8")
+
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan error)
+		go func() { done <- service.Run(runCtx) }()
+		require.Eventually(t, func() bool {
+			stored, err := service.FindByID(scan.ID)
+			return err == nil && stored.Status == models.AdhocScanFailed
+		}, 5*time.Second, 20*time.Millisecond)
+		cancel()
+		require.NoError(t, <-done)
+
+		stored, err := service.FindByID(scan.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "interrupted by a restart", stored.Error)
+		assert.Len(t, stored.Devices, 1)
+	})
+}
@@ -0,0 +1,175 @@
+package integration
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/agent"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/eventlog"
+	"reconya-ai/internal/network"
+	"reconya-ai/models"
+	"reconya-ai/tests/testutils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentService_Integration(t *testing.T) {
+	factory, cleanup := testutils.SetupTestRepositoryFactory(t)
+	defer cleanup()
+
+	cfg := testutils.GetTestConfig()
+	cfg.AgentEnrollmentToken = "enroll-secret"
+	dbManager := db.NewDBManager()
+	networkRepo := factory.NewNetworkRepository()
+
+	networkService := network.NewNetworkService(networkRepo, cfg, dbManager)
+	deviceService := device.NewDeviceService(factory.NewDeviceRepository(), networkService, cfg, dbManager, nil)
+	eventLogService := eventlog.NewEventLogService(factory.NewEventLogRepository(), deviceService, dbManager)
+	agentService := agent.NewAgentService(factory.NewAgentRepository(), deviceService, networkService, eventLogService, cfg)
+
+	remoteNetwork, err := networkRepo.CreateOrUpdate(context.Background(), &models.Network{ID: uuid.New().String(), CIDR: "10.20.0.0/24", Name: "Branch office"})
+	require.NoError(t, err)
+	otherNetwork, err := networkRepo.CreateOrUpdate(context.Background(), &models.Network{ID: uuid.New().String(), CIDR: "10.30.0.0/24"})
+	require.NoError(t, err)
+
+	enrolled, err := agentService.Enroll(agent.EnrollRequest{Token: "enroll-secret", Name: "branch-sensor", Hostname: "pi", Platform: "linux/arm64"})
+	require.NoError(t, err)
+	require.NotEmpty(t, enrolled.APIKey)
+
+	sensor, err := agentService.Authenticate(nil, enrolled.APIKey)
+	require.NoError(t, err)
+	assert.Equal(t, enrolled.AgentID, sensor.ID)
+
+	t.Run("EnrollmentRequiresTheToken", func(t *testing.T) {
+		_, err := agentService.Enroll(agent.EnrollRequest{Token: "wrong", Name: "intruder"})
+		assert.ErrorIs(t, err, agent.ErrUnauthorized)
+
+		_, err = agentService.Enroll(agent.EnrollRequest{Token: "enroll-secret", Name: "  "})
+		assert.ErrorIs(t, err, agent.ErrInvalidName)
+
+		disabled := *cfg
+		disabled.AgentEnrollmentToken = ""
+		_, err = agent.NewAgentService(factory.NewAgentRepository(), deviceService, networkService, nil, &disabled).
+			Enroll(agent.EnrollRequest{Token: "", Name: "intruder"})
+		assert.ErrorIs(t, err, agent.ErrEnrollmentDisabled)
+
+		_, err = agentService.Authenticate(nil, "not-a-key")
+		assert.ErrorIs(t, err, agent.ErrUnauthorized)
+	})
+
+	t.Run("EnrollingAgainRotatesTheKey", func(t *testing.T) {
+		other, err := agentService.Enroll(agent.EnrollRequest{Token: "enroll-secret", Name: "rotating-sensor"})
+		require.NoError(t, err)
+		again, err := agentService.Enroll(agent.EnrollRequest{Token: "enroll-secret", Name: "rotating-sensor"})
+		require.NoError(t, err)
+		assert.Equal(t, other.AgentID, again.AgentID)
+
+		_, err = agentService.Authenticate(nil, other.APIKey)
+		assert.ErrorIs(t, err, agent.ErrUnauthorized)
+		_, err = agentService.Authenticate(nil, again.APIKey)
+		assert.NoError(t, err)
+	})
+
+	t.Run("CertificateAgentsEnrollOnFirstUse", func(t *testing.T) {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: "cert-sensor"}}
+		first, err := agentService.Authenticate(cert, "")
+		require.NoError(t, err)
+		assert.Equal(t, "certificate", first.EnrolledVia)
+
+		second, err := agentService.Authenticate(cert, "")
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, second.ID)
+	})
+
+	t.Run("CheckInReturnsAssignedNetworks", func(t *testing.T) {
+		_, err := agentService.AssignNetworks(sensor.ID, []string{"missing-network"})
+		assert.Error(t, err)
+
+		_, err = agentService.AssignNetworks("missing-agent", []string{remoteNetwork.ID})
+		assert.ErrorIs(t, err, db.ErrNotFound)
+
+		updated, err := agentService.AssignNetworks(sensor.ID, []string{remoteNetwork.ID})
+		require.NoError(t, err)
+		assert.Equal(t, []string{remoteNetwork.ID}, updated.NetworkIDs)
+		assert.Equal(t, models.AgentPending, updated.Health)
+
+		sensor, err = agentService.Authenticate(nil, enrolled.APIKey)
+		require.NoError(t, err)
+		response, err := agentService.CheckIn(sensor, agent.CheckInRequest{Hostname: "pi", Platform: "linux/arm64"}, "203.0.113.7")
+		require.NoError(t, err)
+		require.Len(t, response.Networks, 1)
+		assert.Equal(t, "10.20.0.0/24", response.Networks[0].CIDR)
+		assert.Equal(t, "Branch office", response.Networks[0].Name)
+	})
+
+	t.Run("ReportsAreLimitedToAssignedNetworks", func(t *testing.T) {
+		_, err := agentService.IngestReport(sensor, agent.Report{NetworkID: otherNetwork.ID})
+		assert.ErrorIs(t, err, agent.ErrNetworkNotAssigned)
+	})
+
+	t.Run("ReportedDevicesAreTaggedWithTheSensor", func(t *testing.T) {
+		mac := "00:16:3E:20:00:10"
+		name := "should not be taken"
+		response, err := agentService.IngestReport(sensor, agent.Report{
+			NetworkID:  remoteNetwork.ID,
+			StartedAt:  time.Now().Add(-time.Minute),
+			FinishedAt: time.Now(),
+			Devices: []models.Device{
+				{IPv4: "10.20.0.10", MAC: &mac, Name: name},
+				{IPv4: "10.30.0.10"},
+				{IPv4: "not-an-ip"},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, response.Accepted)
+		assert.Equal(t, 2, response.Rejected)
+
+		saved, err := deviceService.FindByIPv4("10.20.0.10")
+		require.NoError(t, err)
+		require.NotNil(t, saved)
+		require.NotNil(t, saved.SensorID)
+		assert.Equal(t, sensor.ID, *saved.SensorID)
+		assert.Equal(t, remoteNetwork.ID, saved.NetworkID)
+		assert.NotEqual(t, name, saved.Name)
+
+		outside, err := deviceService.FindByIPv4("10.30.0.10")
+		require.NoError(t, err)
+		assert.Nil(t, outside)
+
+		// A later sighting without a sensor keeps the tag
+		_, err = deviceService.CreateOrUpdate(&models.Device{IPv4: "10.20.0.10", MAC: &mac, NetworkID: remoteNetwork.ID})
+		require.NoError(t, err)
+		saved, err = deviceService.FindByIPv4("10.20.0.10")
+		require.NoError(t, err)
+		require.NotNil(t, saved.SensorID)
+		assert.Equal(t, sensor.ID, *saved.SensorID)
+	})
+
+	t.Run("ListReportsHealth", func(t *testing.T) {
+		agents, err := agentService.List()
+		require.NoError(t, err)
+		byName := make(map[string]*models.Agent)
+		for _, a := range agents {
+			byName[a.Name] = a
+		}
+		require.Contains(t, byName, "branch-sensor")
+		assert.Equal(t, models.AgentOnline, byName["branch-sensor"].Health)
+		assert.Equal(t, "203.0.113.7", byName["branch-sensor"].RemoteAddr)
+		assert.NotNil(t, byName["branch-sensor"].LastReportAt)
+		assert.Equal(t, models.AgentPending, byName["cert-sensor"].Health)
+	})
+
+	t.Run("RevokedAgentsCanNoLongerAuthenticate", func(t *testing.T) {
+		require.NoError(t, agentService.Revoke(sensor.ID))
+		_, err := agentService.Authenticate(nil, enrolled.APIKey)
+		assert.ErrorIs(t, err, agent.ErrUnauthorized)
+		assert.ErrorIs(t, agentService.Revoke(sensor.ID), db.ErrNotFound)
+	})
+}
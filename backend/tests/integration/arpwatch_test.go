@@ -0,0 +1,79 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/arpwatch"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/eventlog"
+	"reconya-ai/internal/network"
+	"reconya-ai/models"
+	"reconya-ai/tests/testutils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestARPWatchService_Integration(t *testing.T) {
+	factory, cleanup := testutils.SetupTestRepositoryFactory(t)
+	defer cleanup()
+
+	cfg := testutils.GetTestConfig()
+	dbManager := db.NewDBManager()
+	networkRepo := factory.NewNetworkRepository()
+
+	networkService := network.NewNetworkService(networkRepo, cfg, dbManager)
+	deviceService := device.NewDeviceService(factory.NewDeviceRepository(), networkService, cfg, dbManager, nil)
+	eventLogService := eventlog.NewEventLogService(factory.NewEventLogRepository(), deviceService, dbManager)
+	arpWatchService := arpwatch.NewARPWatchService(deviceService, eventLogService)
+
+	testNetwork, err := networkRepo.CreateOrUpdate(context.Background(), &models.Network{ID: uuid.New().String(), CIDR: "10.4.0.0/24"})
+	require.NoError(t, err)
+
+	sighting := func(t *testing.T, ip, mac string) *models.Device {
+		saved, err := deviceService.CreateOrUpdate(&models.Device{IPv4: ip, MAC: &mac, NetworkID: testNetwork.ID})
+		require.NoError(t, err)
+		return saved
+	}
+
+	eventCount := func(t *testing.T, deviceID string, eventType models.EEventLogType) int {
+		events, err := eventLogService.GetAllByDeviceId(deviceID, 100)
+		require.NoError(t, err)
+		count := 0
+		for _, event := range events {
+			if event.Type == eventType {
+				count++
+			}
+		}
+		return count
+	}
+
+	gateway := sighting(t, "10.4.0.1", "00:16:3E:00:00:01")
+	attacker := sighting(t, "10.4.0.66", "00:16:3E:00:00:66")
+
+	t.Run("ConflictIsLoggedForEveryDeviceInvolved", func(t *testing.T) {
+		now := time.Now()
+		arpWatchService.Observe(
+			arpwatch.Observation{IP: "10.4.0.1", MAC: "00:16:3e:00:00:01", Time: now},
+			arpwatch.Observation{IP: "10.4.0.66", MAC: "00:16:3e:00:00:66", Time: now},
+			arpwatch.Observation{IP: "10.4.0.1", MAC: "00:16:3e:00:00:66", Time: now.Add(time.Second)},
+		)
+
+		assert.Equal(t, 1, eventCount(t, gateway.ID, models.IPConflictDetected))
+		assert.Equal(t, 1, eventCount(t, attacker.ID, models.IPConflictDetected))
+
+		events, err := eventLogService.GetAllByDeviceId(attacker.ID, 10)
+		require.NoError(t, err)
+		require.NotEmpty(t, events)
+		assert.Contains(t, events[0].Description, "10.4.0.1")
+	})
+
+	t.Run("SweepRepeatsAreNotReportedAgain", func(t *testing.T) {
+		arpWatchService.Observe(arpwatch.Observation{IP: "10.4.0.1", MAC: "00:16:3e:00:00:01"})
+		assert.Equal(t, 1, eventCount(t, gateway.ID, models.IPConflictDetected))
+	})
+}
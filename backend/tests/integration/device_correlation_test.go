@@ -0,0 +1,131 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/network"
+	"reconya-ai/models"
+	"reconya-ai/tests/testutils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceCorrelation_Integration(t *testing.T) {
+	factory, cleanup := testutils.SetupTestRepositoryFactory(t)
+	defer cleanup()
+
+	cfg := testutils.GetTestConfig()
+	dbManager := db.NewDBManager()
+	networkRepo := factory.NewNetworkRepository()
+
+	networkService := network.NewNetworkService(networkRepo, cfg, dbManager)
+	deviceService := device.NewDeviceService(factory.NewDeviceRepository(), networkService, cfg, dbManager, nil)
+
+	testNetwork, err := networkRepo.CreateOrUpdate(context.Background(), &models.Network{ID: uuid.New().String(), CIDR: "10.2.0.0/24"})
+	require.NoError(t, err)
+
+	sighting := func(t *testing.T, ip, mac, hostname, mdnsName string) *models.Device {
+		d := &models.Device{IPv4: ip, NetworkID: testNetwork.ID, MAC: &mac}
+		if hostname != "" {
+			d.Hostname = &hostname
+		}
+		if mdnsName != "" {
+			d.Identity = &models.DeviceIdentity{MDNSName: mdnsName}
+		}
+		saved, err := deviceService.CreateOrUpdate(d)
+		require.NoError(t, err)
+		return saved
+	}
+
+	// Devices seen moments ago are still present at their own address and are never
+	// merged, so move the earlier sighting back in time
+	goOffline := func(t *testing.T, d *models.Device) {
+		earlier := time.Now().Add(-time.Hour)
+		d.LastSeenOnlineAt = &earlier
+		require.NoError(t, deviceService.UpdateDeviceRecord(d))
+	}
+
+	t.Run("RandomizedMACIsFlagged", func(t *testing.T) {
+		phone := sighting(t, "10.2.0.10", "8e:1a:2b:3c:4d:5e", "annas-iphone", "Annas-iPhone.local")
+		require.NotNil(t, phone.Identity)
+		assert.True(t, phone.Identity.MACRandomized)
+		assert.Nil(t, phone.Identity.Correlation)
+
+		printer := sighting(t, "10.2.0.11", "00:1a:2b:3c:4d:5e", "printer", "")
+		assert.Nil(t, printer.Identity)
+	})
+
+	t.Run("NewRandomizedMACIsMerged", func(t *testing.T) {
+		phone, err := deviceService.FindByIPv4("10.2.0.10")
+		require.NoError(t, err)
+		goOffline(t, phone)
+
+		rejoined := sighting(t, "10.2.0.20", "a2:11:22:33:44:55", "annas-iphone", "Annas-iPhone.local")
+		assert.Equal(t, phone.ID, rejoined.ID)
+		assert.Equal(t, "10.2.0.20", rejoined.IPv4)
+
+		stored, err := deviceService.FindByID(phone.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stored.Identity)
+		assert.Equal(t, "10.2.0.20", stored.IPv4)
+		assert.Equal(t, []string{"8e:1a:2b:3c:4d:5e"}, stored.Identity.PreviousMACs)
+		require.NotNil(t, stored.Identity.Correlation)
+		assert.True(t, stored.Identity.Correlation.Merged)
+		assert.Equal(t, 80, stored.Identity.Correlation.Confidence)
+		assert.Equal(t, []string{"hostname", "mdns_name"}, stored.Identity.Correlation.Signals)
+
+		old, err := deviceService.FindByIPv4("10.2.0.10")
+		require.NoError(t, err)
+		assert.Nil(t, old, "the old address is not left behind as a separate device")
+	})
+
+	t.Run("WeakMatchStaysSeparate", func(t *testing.T) {
+		phone, err := deviceService.FindByIPv4("10.2.0.20")
+		require.NoError(t, err)
+		goOffline(t, phone)
+
+		guest := sighting(t, "10.2.0.30", "b6:00:00:00:00:01", "annas-iphone", "")
+		assert.NotEqual(t, phone.ID, guest.ID)
+		require.NotNil(t, guest.Identity.Correlation)
+		assert.Equal(t, phone.ID, guest.Identity.Correlation.DeviceID)
+		assert.Equal(t, 35, guest.Identity.Correlation.Confidence)
+		assert.False(t, guest.Identity.Correlation.Merged)
+	})
+
+	t.Run("OnlineDeviceIsNotMerged", func(t *testing.T) {
+		printer, err := deviceService.FindByIPv4("10.2.0.11")
+		require.NoError(t, err)
+		printer.Identity = &models.DeviceIdentity{MDNSName: "printer.local"}
+		require.NoError(t, deviceService.UpdateDeviceRecord(printer))
+
+		other := sighting(t, "10.2.0.40", "c2:00:00:00:00:02", "printer", "printer.local")
+		assert.NotEqual(t, printer.ID, other.ID)
+	})
+
+	t.Run("DHCPClientIDFromTrafficIsMerged", func(t *testing.T) {
+		laptop := sighting(t, "10.2.0.50", "da:00:00:00:00:01", "", "")
+		require.NoError(t, deviceService.RecordDHCPClientID("DA-00-00-00-00-01", "ff:00:00:00:01:00:01:2c"))
+
+		stored, err := deviceService.FindByID(laptop.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stored.Identity)
+		assert.Equal(t, "ff:00:00:00:01:00:01:2c", stored.Identity.DHCPClientID, "the known device gets the identifier right away")
+		goOffline(t, stored)
+
+		// The laptop rotates its MAC and asks for a lease with the same client identifier
+		require.NoError(t, deviceService.RecordDHCPClientID("de:00:00:00:00:02", "ff:00:00:00:01:00:01:2c"))
+		rejoined := sighting(t, "10.2.0.51", "de:00:00:00:00:02", "", "")
+		assert.Equal(t, laptop.ID, rejoined.ID)
+		require.NotNil(t, rejoined.Identity.Correlation)
+		assert.Equal(t, []string{"dhcp_client_id"}, rejoined.Identity.Correlation.Signals)
+		assert.True(t, rejoined.Identity.Correlation.Merged)
+
+		assert.Equal(t, "", deviceService.DHCPClientID("de:00:00:00:00:99"))
+	})
+}
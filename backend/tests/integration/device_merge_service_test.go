@@ -0,0 +1,202 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/devicemerge"
+	"reconya-ai/internal/eventlog"
+	"reconya-ai/internal/network"
+	"reconya-ai/models"
+	"reconya-ai/tests/testutils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceMergeService_Integration(t *testing.T) {
+	factory, cleanup := testutils.SetupTestRepositoryFactory(t)
+	defer cleanup()
+
+	cfg := testutils.GetTestConfig()
+	dbManager := db.NewDBManager()
+	networkRepo := factory.NewNetworkRepository()
+	inventoryRepo := factory.NewInventoryRepository()
+
+	networkService := network.NewNetworkService(networkRepo, cfg, dbManager)
+	deviceService := device.NewDeviceService(factory.NewDeviceRepository(), networkService, cfg, dbManager, nil)
+	eventLogService := eventlog.NewEventLogService(factory.NewEventLogRepository(), deviceService, dbManager)
+	mergeService := devicemerge.NewDeviceMergeService(factory.NewDeviceOperationRepository(), deviceService, eventLogService)
+
+	ctx := context.Background()
+	testNetwork, err := networkRepo.CreateOrUpdate(ctx, &models.Network{ID: uuid.New().String(), CIDR: "10.3.0.0/24"})
+	require.NoError(t, err)
+
+	sighting := func(t *testing.T, ip, mac string) *models.Device {
+		saved, err := deviceService.CreateOrUpdate(&models.Device{IPv4: ip, MAC: &mac, NetworkID: testNetwork.ID})
+		require.NoError(t, err)
+		return saved
+	}
+
+	reload := func(t *testing.T, id string) *models.Device {
+		d, err := deviceService.FindByID(id)
+		require.NoError(t, err)
+		return d
+	}
+
+	eventCount := func(t *testing.T, deviceID string, eventType models.EEventLogType) int {
+		events, err := eventLogService.GetAllByDeviceId(deviceID, 100)
+		require.NoError(t, err)
+		count := 0
+		for _, event := range events {
+			if event.Type == eventType {
+				count++
+			}
+		}
+		return count
+	}
+
+	tag := &models.Tag{Name: "wifi"}
+	require.NoError(t, inventoryRepo.UpsertTag(ctx, tag))
+
+	wired := sighting(t, "10.3.0.10", "00:16:3e:00:00:10")
+	wireless := sighting(t, "10.3.0.11", "00:16:3e:00:00:11")
+
+	wired.Name = "Workstation"
+	wired.Ports = []models.Port{{Number: "22", Protocol: "tcp", State: "open", Service: "ssh"}}
+	require.NoError(t, deviceService.UpdateDeviceRecord(wired))
+	comment := "upstairs"
+	wireless.Comment = &comment
+	wireless.Ports = []models.Port{{Number: "3389", Protocol: "tcp", State: "open", Service: "rdp"}}
+	require.NoError(t, deviceService.UpdateDeviceRecord(wireless))
+	require.NoError(t, inventoryRepo.AssignTag(ctx, []string{wireless.ID}, tag.ID, true))
+	require.NoError(t, eventLogService.Log(models.DeviceOnline, "", wireless.ID))
+	// Addresses only seen through NDP live in the address table, not on the device
+	require.NoError(t, deviceService.RecordIPv6Address(wireless.ID, "2001:db8::6", models.IPv6SourceNDP))
+
+	holder := func(t *testing.T, address string) string {
+		d, err := deviceService.FindDeviceByIPv6(address)
+		require.NoError(t, err)
+		return d.ID
+	}
+
+	var mergeOp *models.DeviceOperation
+
+	t.Run("MergeCarriesHistoryOver", func(t *testing.T) {
+		mergeOp, err = mergeService.Merge(wired.ID, wireless.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.DeviceOperationMerge, mergeOp.Type)
+
+		merged := reload(t, wired.ID)
+		assert.Nil(t, reload(t, wireless.ID))
+		assert.Equal(t, "Workstation", merged.Name)
+		assert.Equal(t, "upstairs", *merged.Comment)
+		assert.Len(t, merged.Ports, 2)
+		require.NotNil(t, merged.Identity)
+		assert.Len(t, merged.Identity.PreviousMACs, 1)
+		assert.Equal(t, 1, eventCount(t, wired.ID, models.DeviceOnline), "event logs move with the device")
+		assert.Equal(t, wired.ID, holder(t, "2001:db8::6"), "recorded IPv6 addresses move with the device")
+
+		tags, err := inventoryRepo.DeviceTags(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"wifi"}, tags[wired.ID])
+
+		_, err = mergeService.Merge(wired.ID, wired.ID)
+		assert.Error(t, err)
+		_, err = mergeService.Merge(wired.ID, uuid.New().String())
+		assert.ErrorIs(t, err, db.ErrNotFound)
+	})
+
+	var splitOp *models.DeviceOperation
+
+	t.Run("SplitByMAC", func(t *testing.T) {
+		merged := reload(t, wired.ID)
+		other := merged.Identity.PreviousMACs[0]
+
+		splitOp, err = mergeService.Split(wired.ID, other)
+		require.NoError(t, err)
+
+		created := reload(t, splitOp.OtherDeviceID)
+		require.NotNil(t, created)
+		assert.Equal(t, other, *created.MAC)
+		assert.Equal(t, merged.IPv4, created.IPv4, "the split device shares the address")
+		assert.Empty(t, reload(t, wired.ID).Identity.PreviousMACs)
+
+		// The next sweep keeps both devices apart by MAC
+		again := sighting(t, created.IPv4, other)
+		assert.Equal(t, created.ID, again.ID)
+		again = sighting(t, created.IPv4, *merged.MAC)
+		assert.Equal(t, wired.ID, again.ID)
+
+		_, err = mergeService.Split(wired.ID, "00:00:00:00:00:99")
+		assert.Error(t, err)
+	})
+
+	t.Run("UndoInReverseOrder", func(t *testing.T) {
+		_, err := mergeService.Undo(mergeOp.ID)
+		assert.Error(t, err, "the later split has to be undone first")
+
+		_, err = mergeService.Undo(splitOp.ID)
+		require.NoError(t, err)
+		assert.Nil(t, reload(t, splitOp.OtherDeviceID))
+		assert.Len(t, reload(t, wired.ID).Identity.PreviousMACs, 1)
+
+		_, err = mergeService.Undo(mergeOp.ID)
+		require.NoError(t, err)
+
+		restored := reload(t, wireless.ID)
+		require.NotNil(t, restored)
+		assert.Equal(t, "10.3.0.11", restored.IPv4)
+		assert.Equal(t, "upstairs", *restored.Comment)
+		assert.Len(t, restored.Ports, 1)
+		assert.Equal(t, 1, eventCount(t, wireless.ID, models.DeviceOnline))
+		assert.Equal(t, wireless.ID, holder(t, "2001:db8::6"))
+
+		original := reload(t, wired.ID)
+		assert.Equal(t, "10.3.0.10", original.IPv4)
+		assert.Nil(t, original.Comment)
+		assert.Len(t, original.Ports, 1)
+		assert.Nil(t, original.Identity)
+
+		tags, err := inventoryRepo.DeviceTags(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, tags[wired.ID])
+		assert.Equal(t, []string{"wifi"}, tags[wireless.ID])
+
+		_, err = mergeService.Undo(mergeOp.ID)
+		assert.Error(t, err, "an operation is undone only once")
+	})
+
+	t.Run("OperationsAreRecorded", func(t *testing.T) {
+		operations, err := mergeService.ListOperations(wireless.ID)
+		require.NoError(t, err)
+		require.Len(t, operations, 1)
+		assert.NotNil(t, operations[0].UndoneAt)
+
+		operations, err = mergeService.ListOperations("")
+		require.NoError(t, err)
+		assert.Len(t, operations, 2)
+	})
+
+	t.Run("MergeDuplicates", func(t *testing.T) {
+		stale := sighting(t, "10.3.0.20", "00:16:3e:00:00:20")
+		earlier := time.Now().Add(-time.Hour)
+		stale.LastSeenOnlineAt = &earlier
+		require.NoError(t, deviceService.UpdateDeviceRecord(stale))
+
+		// Older databases could hold the same MAC twice
+		duplicate := &models.Device{ID: uuid.New().String(), IPv4: "10.3.0.21", MAC: stale.MAC, NetworkID: testNetwork.ID, Status: models.DeviceStatusOnline}
+		_, err := factory.NewDeviceRepository().CreateOrUpdate(ctx, duplicate)
+		require.NoError(t, err)
+
+		operations, err := mergeService.MergeDuplicates()
+		require.NoError(t, err)
+		require.Len(t, operations, 1)
+		assert.Equal(t, duplicate.ID, operations[0].DeviceID)
+		assert.Nil(t, reload(t, stale.ID))
+	})
+}
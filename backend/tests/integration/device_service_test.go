@@ -96,5 +96,31 @@ func TestDeviceRepository_Integration(t *testing.T) {
 		assert.Equal(t, models.DeviceStatusOnline, updatedDevice.Status)
 		assert.NotNil(t, updatedDevice.LastSeenOnlineAt)
 	})
-}
 
+	t.Run("ReplacePorts", func(t *testing.T) {
+		testDevice := createTestDevice("192.168.1.105", "Ports Test Device")
+		testDevice.Ports = []models.Port{{Number: "22", Protocol: "tcp", State: "open"}, {Number: "80", Protocol: "tcp", State: "open"}}
+		savedDevice, err := deviceRepo.CreateOrUpdate(ctx, testDevice)
+		require.NoError(t, err)
+
+		// Saving without ports keeps the stored ones
+		savedDevice.Ports = nil
+		_, err = deviceRepo.CreateOrUpdate(ctx, savedDevice)
+		require.NoError(t, err)
+		retrievedDevice, err := deviceRepo.FindByID(ctx, savedDevice.ID)
+		require.NoError(t, err)
+		assert.Len(t, retrievedDevice.Ports, 2)
+
+		require.NoError(t, deviceRepo.ReplacePorts(ctx, savedDevice.ID, []models.Port{{Number: "443", Protocol: "tcp", State: "open"}}))
+		retrievedDevice, err = deviceRepo.FindByID(ctx, savedDevice.ID)
+		require.NoError(t, err)
+		require.Len(t, retrievedDevice.Ports, 1)
+		assert.Equal(t, "443", retrievedDevice.Ports[0].Number)
+
+		// A scan that found nothing open clears them
+		require.NoError(t, deviceRepo.ReplacePorts(ctx, savedDevice.ID, nil))
+		retrievedDevice, err = deviceRepo.FindByID(ctx, savedDevice.ID)
+		require.NoError(t, err)
+		assert.Empty(t, retrievedDevice.Ports)
+	})
+}
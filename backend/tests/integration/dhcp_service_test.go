@@ -0,0 +1,95 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/dhcp"
+	"reconya-ai/internal/eventlog"
+	"reconya-ai/internal/network"
+	"reconya-ai/models"
+	"reconya-ai/tests/testutils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCPService_Integration(t *testing.T) {
+	factory, cleanup := testutils.SetupTestRepositoryFactory(t)
+	defer cleanup()
+
+	cfg := testutils.GetTestConfig()
+	dbManager := db.NewDBManager()
+	networkRepo := factory.NewNetworkRepository()
+
+	networkService := network.NewNetworkService(networkRepo, cfg, dbManager)
+	deviceService := device.NewDeviceService(factory.NewDeviceRepository(), networkService, cfg, dbManager, nil)
+	eventLogService := eventlog.NewEventLogService(factory.NewEventLogRepository(), deviceService, dbManager)
+	dhcpService := dhcp.NewDHCPService(factory.NewDHCPRepository(), networkService, deviceService, eventLogService)
+
+	testNetwork, err := networkRepo.CreateOrUpdate(context.Background(), &models.Network{ID: uuid.New().String(), CIDR: "10.5.0.0/24"})
+	require.NoError(t, err)
+
+	reload := func(t *testing.T) *models.Network {
+		n, err := networkService.FindByID(testNetwork.ID)
+		require.NoError(t, err)
+		return n
+	}
+
+	routerMAC := "00:16:3E:00:00:01"
+	_, err = deviceService.CreateOrUpdate(&models.Device{IPv4: "10.5.0.1", MAC: &routerMAC, NetworkID: testNetwork.ID})
+	require.NoError(t, err)
+	rogueMAC := "00:16:3E:00:00:99"
+	rogueDevice, err := deviceService.CreateOrUpdate(&models.Device{IPv4: "10.5.0.99", MAC: &rogueMAC, NetworkID: testNetwork.ID})
+	require.NoError(t, err)
+
+	office := models.DHCPOffer{ServerIP: "10.5.0.1", OfferedIP: "10.5.0.50", SubnetMask: "255.255.255.0", Routers: []string{"10.5.0.1"}, DNSServers: []string{"10.5.0.1"}}
+	rogue := models.DHCPOffer{ServerIP: "10.5.0.99", OfferedIP: "192.168.0.10", SubnetMask: "255.255.255.0", Routers: []string{"192.168.0.1"}}
+
+	t.Run("LegitimateServerIsRecorded", func(t *testing.T) {
+		result := dhcpService.Evaluate(reload(t), []models.DHCPOffer{office})
+		assert.Empty(t, result.Problems)
+
+		recorded := reload(t).DHCPServer
+		require.NotNil(t, recorded)
+		assert.Equal(t, "10.5.0.1", recorded.ServerIP)
+		assert.Equal(t, routerMAC, recorded.ServerMAC, "the MAC is filled in from the known device")
+		assert.Equal(t, []string{"10.5.0.1"}, recorded.Routers)
+	})
+
+	t.Run("RogueServerRaisesEvent", func(t *testing.T) {
+		result := dhcpService.Evaluate(reload(t), []models.DHCPOffer{office, rogue})
+		require.Len(t, result.Problems, 1)
+
+		events, err := eventLogService.GetAllByDeviceId(rogueDevice.ID, 10)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, models.RogueDHCPServer, events[0].Type)
+
+		// Repeated probes do not repeat the event
+		dhcpService.Evaluate(reload(t), []models.DHCPOffer{office, rogue})
+		events, err = eventLogService.GetAllByDeviceId(rogueDevice.ID, 10)
+		require.NoError(t, err)
+		assert.Len(t, events, 1)
+		assert.Len(t, dhcpService.LastResult(testNetwork.ID).Offers, 2)
+	})
+
+	t.Run("AllowlistIsChecked", func(t *testing.T) {
+		err := dhcpService.SetAllowlist(testNetwork.ID, []models.DHCPExpectedServer{{ServerIP: "10.5.0.1", DNSServers: []string{"10.5.0.53"}}})
+		require.NoError(t, err)
+		assert.Len(t, reload(t).DHCPAllowlist, 1)
+
+		result := dhcpService.Evaluate(reload(t), []models.DHCPOffer{office})
+		require.Len(t, result.Problems, 1)
+		assert.Contains(t, result.Problems[0], "DNS 10.5.0.1 instead of 10.5.0.53")
+
+		assert.Error(t, dhcpService.SetAllowlist(testNetwork.ID, []models.DHCPExpectedServer{{ServerIP: "not-an-ip"}}))
+		assert.ErrorIs(t, dhcpService.SetAllowlist(uuid.New().String(), nil), db.ErrNotFound)
+
+		require.NoError(t, dhcpService.SetAllowlist(testNetwork.ID, nil))
+		assert.Empty(t, reload(t).DHCPAllowlist)
+	})
+}
@@ -0,0 +1,288 @@
+package integration
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/agent"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/exclusion"
+	"reconya-ai/internal/ipv6monitor"
+	"reconya-ai/internal/logging"
+	"reconya-ai/internal/network"
+	"reconya-ai/internal/snmp"
+	"reconya-ai/internal/upnp"
+	"reconya-ai/models"
+	"reconya-ai/tests/testutils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExclusionService_RulesAreCachedAndFailClosed(t *testing.T) {
+	testDB, cleanup := testutils.SetupTestDatabase(t)
+	defer cleanup()
+	service := exclusion.NewExclusionService(db.NewExclusionRepository(testDB))
+
+	_, err := service.Create(&models.ExclusionRule{Scope: models.ExclusionGlobal, Address: "10.41.0.5", Stages: []models.ExclusionStage{models.ExcludeWeb}}, "alice")
+	require.NoError(t, err)
+	require.NotNil(t, service.Blocked(models.ExcludeWeb, "10.41.0.5", ""))
+
+	// The loaded rules keep applying without the database
+	require.NoError(t, testDB.Close())
+	require.NotNil(t, service.Blocked(models.ExcludeWeb, "10.41.0.5", ""))
+	assert.Nil(t, service.Blocked(models.ExcludeWeb, "10.41.0.6", ""))
+
+	// A service that never loaded its rules scans nothing
+	unloaded := exclusion.NewExclusionService(db.NewExclusionRepository(testDB))
+	rule := unloaded.Blocked(models.ExcludeWeb, "10.41.0.6", "")
+	require.NotNil(t, rule)
+	assert.ErrorIs(t, exclusion.BlockedError(rule, models.ExcludeWeb), exclusion.ErrExcluded)
+	_, _, err = unloaded.NmapArgs(models.ExcludeDiscovery, "10.41.0.0/24")
+	assert.ErrorIs(t, err, exclusion.ErrRulesUnavailable)
+	assert.Empty(t, unloaded.Filter(models.ExcludeDiscovery, []models.Device{{IPv4: "10.41.0.6"}}))
+}
+
+func TestExclusionService_Integration(t *testing.T) {
+	factory, cleanup := testutils.SetupTestRepositoryFactory(t)
+	defer cleanup()
+
+	cfg := testutils.GetTestConfig()
+	dbManager := db.NewDBManager()
+	networkRepo := factory.NewNetworkRepository()
+	networkService := network.NewNetworkService(networkRepo, cfg, dbManager)
+	deviceService := device.NewDeviceService(factory.NewDeviceRepository(), networkService, cfg, dbManager, nil)
+	service := exclusion.NewExclusionService(factory.NewExclusionRepository())
+
+	ctx := context.Background()
+	plant, err := networkRepo.CreateOrUpdate(ctx, &models.Network{ID: uuid.New().String(), CIDR: "10.40.0.0/24", Name: "Plant floor"})
+	require.NoError(t, err)
+	pumpMAC := "aa:bb:cc:00:00:01"
+	pump, err := deviceService.CreateOrUpdate(&models.Device{IPv4: "10.40.0.10", MAC: &pumpMAC, NetworkID: plant.ID})
+	require.NoError(t, err)
+	monitorMAC := "aa:bb:cc:00:00:02"
+	_, err = deviceService.CreateOrUpdate(&models.Device{IPv4: "10.40.0.20", MAC: &monitorMAC, NetworkID: plant.ID})
+	require.NoError(t, err)
+
+	t.Run("Rules need an existing network or device", func(t *testing.T) {
+		_, err := service.Create(&models.ExclusionRule{Scope: models.ExclusionNetwork, NetworkID: "missing", Stages: []models.ExclusionStage{models.ExcludeWeb}}, "alice")
+		assert.ErrorContains(t, err, "not found")
+		_, err = service.Create(&models.ExclusionRule{Scope: models.ExclusionDevice, DeviceID: "missing", Stages: []models.ExclusionStage{models.ExcludeWeb}}, "alice")
+		assert.ErrorContains(t, err, "not found")
+	})
+
+	deviceRule, err := service.Create(&models.ExclusionRule{
+		Scope:    models.ExclusionDevice,
+		DeviceID: pump.ID,
+		Stages:   []models.ExclusionStage{models.ExcludePortScan, models.ExcludeOSDetection},
+		Reason:   "infusion pump",
+	}, "alice")
+	require.NoError(t, err)
+	macRule, err := service.Create(&models.ExclusionRule{
+		Scope:   models.ExclusionGlobal,
+		Address: "AA-BB-CC-00-00-02",
+		Stages:  []models.ExclusionStage{models.ExcludeDiscovery},
+	}, "bob")
+	require.NoError(t, err)
+	networkRule, err := service.Create(&models.ExclusionRule{
+		Scope:     models.ExclusionNetwork,
+		NetworkID: plant.ID,
+		Address:   "10.40.0.100-10.40.0.200",
+		Stages:    []models.ExclusionStage{models.ExcludeWeb},
+	}, "bob")
+	require.NoError(t, err)
+
+	t.Run("Rules are loaded with what matching needs", func(t *testing.T) {
+		rules, err := service.FindAll()
+		require.NoError(t, err)
+		require.Len(t, rules, 3)
+		assert.Equal(t, "10.40.0.10", rules[0].DeviceIPv4)
+		assert.Equal(t, pumpMAC, rules[0].DeviceMAC)
+		assert.Equal(t, "alice", rules[0].CreatedBy)
+		assert.Equal(t, monitorMAC, rules[1].Address)
+		assert.Equal(t, []string{"10.40.0.20"}, rules[1].KnownIPs)
+		assert.Equal(t, "10.40.0.0/24", rules[2].NetworkCIDR)
+	})
+
+	t.Run("Every stage checks the rules", func(t *testing.T) {
+		assert.Equal(t, deviceRule.ID, service.Blocked(models.ExcludePortScan, "10.40.0.10", "").ID)
+		assert.Nil(t, service.Blocked(models.ExcludeWeb, "10.40.0.10", pumpMAC))
+		assert.Equal(t, networkRule.ID, service.Blocked(models.ExcludeScreenshots, "10.40.0.150", "").ID)
+
+		args, cleanup, err := service.NmapArgs(models.ExcludeDiscovery, plant.CIDR)
+		require.NoError(t, err)
+		defer cleanup()
+		assert.Equal(t, "--excludefile", args[0], "the monitor is skipped at its last known address")
+
+		devices := service.Filter(models.ExcludeDiscovery, []models.Device{{IPv4: "10.40.0.30", MAC: &monitorMAC}, {IPv4: "10.40.0.31"}})
+		require.Len(t, devices, 1)
+		assert.Equal(t, "10.40.0.31", devices[0].IPv4)
+	})
+
+	t.Run("Repeated blocks add to one audit entry", func(t *testing.T) {
+		service.Blocked(models.ExcludePortScan, "10.40.0.10", "")
+		service.Blocked(models.ExcludePortScan, "10.40.0.10", "")
+
+		entries, err := service.FindAudit(deviceRule.ID, 0)
+		require.NoError(t, err)
+		var blocked, created *models.ExclusionAuditEntry
+		for _, entry := range entries {
+			switch {
+			case entry.Action == models.ExclusionBlocked && entry.Stage == models.ExcludePortScan:
+				blocked = entry
+			case entry.Action == models.ExclusionCreated:
+				created = entry
+			}
+		}
+		require.NotNil(t, blocked)
+		assert.Equal(t, "10.40.0.10", blocked.Target)
+		assert.Equal(t, 3, blocked.Count)
+		require.NotNil(t, created)
+		assert.Equal(t, "alice", created.Actor)
+		assert.Contains(t, created.Detail, "infusion pump")
+	})
+
+	t.Run("Changes are audited and deleted rules keep their trail", func(t *testing.T) {
+		changed := *macRule
+		changed.Stages = []models.ExclusionStage{models.ExcludeDiscovery, models.ExcludePortScan}
+		updated, err := service.Update(macRule.ID, &changed, "carol")
+		require.NoError(t, err)
+		assert.Equal(t, "bob", updated.CreatedBy)
+
+		_, err = service.Update(uuid.New().String(), &changed, "carol")
+		assert.ErrorIs(t, err, db.ErrNotFound)
+
+		require.NoError(t, service.Delete(macRule.ID, "dave"))
+		assert.ErrorIs(t, service.Delete(macRule.ID, "dave"), db.ErrNotFound)
+		assert.Nil(t, service.Blocked(models.ExcludeDiscovery, "10.40.0.20", monitorMAC), "deleted rules stop applying")
+
+		entries, err := service.FindAudit(macRule.ID, 0)
+		require.NoError(t, err)
+		var actions []models.ExclusionAuditAction
+		actors := map[models.ExclusionAuditAction]string{}
+		for _, entry := range entries {
+			actions = append(actions, entry.Action)
+			actors[entry.Action] = entry.Actor
+		}
+		assert.ElementsMatch(t, []models.ExclusionAuditAction{models.ExclusionCreated, models.ExclusionBlocked, models.ExclusionBlocked, models.ExclusionUpdated, models.ExclusionDeleted}, actions,
+			"the exclude file and the filtered sweep result are both audited")
+		assert.Equal(t, "carol", actors[models.ExclusionUpdated])
+		assert.Equal(t, "dave", actors[models.ExclusionDeleted])
+	})
+
+	t.Run("Agents get the rules and their reports are filtered", func(t *testing.T) {
+		cfg.AgentEnrollmentToken = "enroll-secret"
+		agentService := agent.NewAgentService(factory.NewAgentRepository(), deviceService, networkService, nil, cfg)
+		agentService.Exclusions = service
+
+		_, err := service.Create(&models.ExclusionRule{
+			Scope:   models.ExclusionGlobal,
+			Address: "10.40.0.66",
+			Stages:  []models.ExclusionStage{models.ExcludeDiscovery},
+		}, "alice")
+		require.NoError(t, err)
+
+		enrolled, err := agentService.Enroll(agent.EnrollRequest{Token: "enroll-secret", Name: "plant-sensor"})
+		require.NoError(t, err)
+		sensor, err := agentService.AssignNetworks(enrolled.AgentID, []string{plant.ID})
+		require.NoError(t, err)
+
+		checkIn, err := agentService.CheckIn(sensor, agent.CheckInRequest{}, "203.0.113.9")
+		require.NoError(t, err)
+		assert.Len(t, checkIn.Exclusions, 3)
+
+		response, err := agentService.IngestReport(sensor, agent.Report{
+			NetworkID: plant.ID,
+			Devices: []models.Device{
+				{IPv4: "10.40.0.66"},
+				{IPv4: "10.40.0.10", Ports: []models.Port{{Number: "80", Protocol: "tcp", State: "open"}}},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, response.Accepted)
+		assert.Equal(t, 1, response.Rejected)
+
+		saved, err := deviceService.FindByIPv4("10.40.0.10")
+		require.NoError(t, err)
+		assert.Empty(t, saved.Ports, "ports of hosts that may not be port scanned are dropped")
+		missing, err := deviceService.FindByIPv4("10.40.0.66")
+		require.NoError(t, err)
+		assert.Nil(t, missing)
+	})
+
+	t.Run("SNMP polling checks the rules", func(t *testing.T) {
+		snmpService := snmp.NewSNMPService(factory.NewSNMPCredentialRepository(), deviceService, cfg.SecretKey)
+		snmpService.Exclusions = service
+		_, err := snmpService.SaveCredential(&models.SNMPCredential{NetworkID: plant.ID, Version: models.SNMPVersion2c, Community: "public"})
+		require.NoError(t, err)
+		plc, err := deviceService.CreateOrUpdate(&models.Device{IPv4: "10.40.0.40", NetworkID: plant.ID, Status: models.DeviceStatusOnline})
+		require.NoError(t, err)
+
+		rule, err := service.Create(&models.ExclusionRule{Scope: models.ExclusionNetwork, NetworkID: plant.ID, Stages: []models.ExclusionStage{models.ExcludeSNMP}}, "alice")
+		require.NoError(t, err)
+
+		_, err = snmpService.InterrogateDevice(plc.ID)
+		assert.ErrorIs(t, err, exclusion.ErrExcluded)
+
+		snmpService.Run(plant)
+		entries, err := service.FindAudit(rule.ID, 0)
+		require.NoError(t, err)
+		targets := map[string]bool{}
+		for _, entry := range entries {
+			if entry.Action == models.ExclusionBlocked && entry.Stage == models.ExcludeSNMP {
+				targets[entry.Target] = true
+			}
+		}
+		assert.True(t, targets["10.40.0.40"], "online devices are left out of the poll")
+	})
+
+	t.Run("UPnP fetches check the rules", func(t *testing.T) {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer server.Close()
+
+		loopback, err := networkRepo.CreateOrUpdate(ctx, &models.Network{ID: uuid.New().String(), CIDR: "127.0.0.0/8"})
+		require.NoError(t, err)
+		_, err = deviceService.CreateOrUpdate(&models.Device{IPv4: "127.0.0.1", NetworkID: loopback.ID, Status: models.DeviceStatusOnline})
+		require.NoError(t, err)
+		_, err = service.Create(&models.ExclusionRule{Scope: models.ExclusionGlobal, Address: "127.0.0.1", Stages: []models.ExclusionStage{models.ExcludeUPnP}}, "alice")
+		require.NoError(t, err)
+
+		upnpService := upnp.NewUPnPService(deviceService)
+		upnpService.Exclusions = service
+		err = upnpService.UpdateDevice(context.Background(), "127.0.0.1", []upnp.SSDPResponse{{Addr: "127.0.0.1", Location: server.URL + "/rootDesc.xml"}})
+		assert.ErrorIs(t, err, exclusion.ErrExcluded)
+		assert.Zero(t, requests.Load(), "the description is not fetched")
+	})
+
+	t.Run("IPv6 discovery does not solicit protected hosts", func(t *testing.T) {
+		robotMAC := "aa:bb:cc:00:00:05"
+		_, err := deviceService.CreateOrUpdate(&models.Device{IPv4: "10.40.0.50", MAC: &robotMAC, NetworkID: plant.ID})
+		require.NoError(t, err)
+		_, err = service.Create(&models.ExclusionRule{Scope: models.ExclusionGlobal, Address: "10.40.0.50", Stages: []models.ExclusionStage{models.ExcludeDiscovery}}, "alice")
+		require.NoError(t, err)
+
+		ipv6Service := ipv6monitor.NewIPv6MonitorService(deviceService, networkService, logging.For("ipv6monitor"))
+		ipv6Service.Exclusions = service
+		_, prefix, err := net.ParseCIDR("2001:db8:40::/64")
+		require.NoError(t, err)
+
+		// The robot's link-local address heard on the link gives away its identifier too
+		targets := ipv6Service.SolicitationTargets(plant, []*net.IPNet{prefix}, []string{"fe80::a8bb:ccff:fe00:5"})
+		var addresses []string
+		for _, target := range targets {
+			addresses = append(addresses, target.String())
+		}
+		assert.Contains(t, addresses, "2001:db8:40:0:a8bb:ccff:fe00:2", "the monitor is still probed")
+		assert.NotContains(t, addresses, "2001:db8:40:0:a8bb:ccff:fe00:5")
+	})
+}
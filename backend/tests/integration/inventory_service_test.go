@@ -0,0 +1,174 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/inventory"
+	"reconya-ai/internal/network"
+	"reconya-ai/models"
+	"reconya-ai/tests/testutils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventoryService_Integration(t *testing.T) {
+	factory, cleanup := testutils.SetupTestRepositoryFactory(t)
+	defer cleanup()
+
+	cfg := testutils.GetTestConfig()
+	dbManager := db.NewDBManager()
+	networkRepo := factory.NewNetworkRepository()
+	deviceRepo := factory.NewDeviceRepository()
+
+	networkService := network.NewNetworkService(networkRepo, cfg, dbManager)
+	deviceService := device.NewDeviceService(deviceRepo, networkService, cfg, dbManager, nil)
+	inventoryService := inventory.NewInventoryService(factory.NewInventoryRepository(), deviceService)
+
+	ctx := context.Background()
+	testNetwork, err := networkRepo.CreateOrUpdate(ctx, &models.Network{ID: uuid.New().String(), CIDR: "10.0.0.0/24"})
+	require.NoError(t, err)
+
+	printer, err := deviceService.CreateOrUpdate(&models.Device{IPv4: "10.0.0.10", NetworkID: testNetwork.ID})
+	require.NoError(t, err)
+	server, err := deviceService.CreateOrUpdate(&models.Device{IPv4: "10.0.0.20", NetworkID: testNetwork.ID})
+	require.NoError(t, err)
+
+	annotated := func(t *testing.T, ids ...string) []*models.Device {
+		var devices []*models.Device
+		for _, id := range ids {
+			d, err := deviceService.FindByID(id)
+			require.NoError(t, err)
+			devices = append(devices, d)
+		}
+		require.NoError(t, inventoryService.Annotate(devices))
+		return devices
+	}
+
+	t.Run("CustomFields", func(t *testing.T) {
+		_, err := inventoryService.SaveCustomField(&models.CustomField{Label: "Owner", Type: models.CustomFieldString})
+		require.NoError(t, err)
+		_, err = inventoryService.SaveCustomField(&models.CustomField{
+			Name:    "criticality",
+			Type:    models.CustomFieldEnum,
+			Options: []string{"low", "high"},
+		})
+		require.NoError(t, err)
+
+		_, err = inventoryService.SaveCustomField(&models.CustomField{Name: "owner", Type: models.CustomFieldNumber})
+		assert.Error(t, err, "duplicate field names are rejected")
+
+		fields, err := inventoryService.ListCustomFields()
+		require.NoError(t, err)
+		require.Len(t, fields, 2)
+		assert.Equal(t, []string{"low", "high"}, fields[1].Options)
+	})
+
+	t.Run("BulkUpdate", func(t *testing.T) {
+		err := inventoryService.ApplyBulk(inventory.BulkUpdate{
+			DeviceIDs:    []string{printer.ID, server.ID},
+			AddTags:      []string{"prod"},
+			AddGroups:    []string{"Office/Floor 2/Printers"},
+			CustomFields: map[string]string{"owner": "alice", "criticality": "HIGH"},
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, inventoryService.ApplyBulk(inventory.BulkUpdate{
+			DeviceIDs:    []string{server.ID},
+			AddTags:      []string{"web"},
+			RemoveGroups: []string{"office/floor 2/printers"},
+			AddGroups:    []string{"Datacenter"},
+			CustomFields: map[string]string{"owner": ""},
+		}))
+
+		devices := annotated(t, printer.ID, server.ID)
+		assert.Equal(t, []string{"prod"}, devices[0].Tags)
+		assert.Equal(t, []string{"Office/Floor 2/Printers"}, devices[0].Groups)
+		assert.Equal(t, map[string]string{"owner": "alice", "criticality": "high"}, devices[0].CustomFields)
+
+		assert.Equal(t, []string{"prod", "web"}, devices[1].Tags)
+		assert.Equal(t, []string{"Datacenter"}, devices[1].Groups)
+		assert.Equal(t, map[string]string{"criticality": "high"}, devices[1].CustomFields)
+
+		office := inventory.DeviceFilter{Group: "Office"}.Apply(devices)
+		require.Len(t, office, 1)
+		assert.Equal(t, printer.ID, office[0].ID)
+	})
+
+	t.Run("BulkUpdateIsValidatedFirst", func(t *testing.T) {
+		err := inventoryService.ApplyBulk(inventory.BulkUpdate{
+			DeviceIDs:    []string{printer.ID},
+			AddTags:      []string{"never-created"},
+			CustomFields: map[string]string{"criticality": "medium"},
+		})
+		assert.Error(t, err)
+
+		err = inventoryService.ApplyBulk(inventory.BulkUpdate{DeviceIDs: []string{printer.ID}, CustomFields: map[string]string{"colour": "red"}})
+		assert.Error(t, err)
+
+		err = inventoryService.ApplyBulk(inventory.BulkUpdate{DeviceIDs: []string{uuid.New().String()}, AddTags: []string{"prod"}})
+		assert.Error(t, err)
+
+		tags, err := inventoryService.ListTags()
+		require.NoError(t, err)
+		for _, tag := range tags {
+			assert.NotEqual(t, "never-created", tag.Name)
+		}
+	})
+
+	t.Run("Groups", func(t *testing.T) {
+		groups, err := inventoryService.ListGroups()
+		require.NoError(t, err)
+		var paths []string
+		for _, group := range groups {
+			paths = append(paths, group.Path)
+		}
+		assert.Equal(t, []string{"Datacenter", "Office", "Office/Floor 2", "Office/Floor 2/Printers"}, paths)
+
+		again, err := inventoryService.EnsureGroup(" office / Floor 2 ")
+		require.NoError(t, err)
+		assert.Equal(t, groups[2].ID, again.ID, "existing groups are reused regardless of case")
+
+		require.NoError(t, inventoryService.DeleteGroup(groups[1].ID))
+		groups, err = inventoryService.ListGroups()
+		require.NoError(t, err)
+		require.Len(t, groups, 1)
+
+		devices := annotated(t, printer.ID)
+		assert.Empty(t, devices[0].Groups)
+
+		assert.Equal(t, db.ErrNotFound, inventoryService.DeleteGroup(uuid.New().String()))
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		_, err := inventoryService.SaveTag(&models.Tag{Name: "PROD"})
+		assert.Error(t, err, "tag names are unique regardless of case")
+		_, err = inventoryService.SaveTag(&models.Tag{Name: "a,b"})
+		assert.Error(t, err)
+
+		tags, err := inventoryService.ListTags()
+		require.NoError(t, err)
+		require.Len(t, tags, 2)
+		require.NoError(t, inventoryService.DeleteTag(tags[0].ID))
+
+		devices := annotated(t, server.ID)
+		assert.Equal(t, []string{"web"}, devices[0].Tags)
+	})
+
+	t.Run("DeleteDeviceRemovesAssignments", func(t *testing.T) {
+		require.NoError(t, deviceRepo.DeleteByID(ctx, server.ID))
+
+		repo := factory.NewInventoryRepository()
+		tags, err := repo.DeviceTags(ctx)
+		require.NoError(t, err)
+		assert.NotContains(t, tags, server.ID)
+		values, err := repo.DeviceCustomValues(ctx)
+		require.NoError(t, err)
+		assert.NotContains(t, values, server.ID)
+		assert.Contains(t, values, printer.ID)
+	})
+}
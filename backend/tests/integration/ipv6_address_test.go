@@ -0,0 +1,144 @@
+package integration
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"reconya-ai/db"
+	"reconya-ai/models"
+	"reconya-ai/tests/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPv6AddressRepository_Integration(t *testing.T) {
+	factory, cleanup := testutils.SetupTestRepositoryFactory(t)
+	defer cleanup()
+
+	deviceRepo := factory.NewDeviceRepository()
+	ctx := context.Background()
+
+	global := "2001:db8:1::10"
+	linkLocal := "fe80::10"
+	first := createTestDevice("192.168.60.10", "IPv6 Device")
+	first.IPv6Global = &global
+	first.IPv6LinkLocal = &linkLocal
+	first.IPv6Addresses = []string{"2001:db8:1::30"}
+	first, err := deviceRepo.CreateOrUpdate(ctx, first)
+	require.NoError(t, err)
+
+	otherMAC := "00:11:22:33:44:66"
+	second := createTestDevice("192.168.60.20", "Other Device")
+	second.MAC = &otherMAC
+	second, err = deviceRepo.CreateOrUpdate(ctx, second)
+	require.NoError(t, err)
+
+	t.Run("SavedAddressesAreIndexed", func(t *testing.T) {
+		found, err := deviceRepo.FindByIPv6(ctx, "2001:0db8:0001::0010")
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, found.ID)
+
+		found, err = deviceRepo.FindByIPv6(ctx, "fe80::10%eth0")
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, found.ID)
+
+		_, err = deviceRepo.FindByIPv6(ctx, "2001:db8:1::99")
+		assert.Equal(t, db.ErrNotFound, err)
+	})
+
+	t.Run("RecordedAddressesKeepSourceAndType", func(t *testing.T) {
+		require.NoError(t, deviceRepo.RecordIPv6Address(ctx, first.ID, "2001:db8:1::20", models.IPv6SourceNDP, time.Now()))
+
+		addresses, err := deviceRepo.FindIPv6Addresses(ctx, first.ID)
+		require.NoError(t, err)
+		byAddress := make(map[string]models.DeviceIPv6Address)
+		for _, address := range addresses {
+			byAddress[address.Address] = address
+		}
+		require.Contains(t, byAddress, "2001:db8:1::20")
+		assert.Equal(t, models.IPv6SourceNDP, byAddress["2001:db8:1::20"].Source)
+		assert.Equal(t, "global", byAddress["2001:db8:1::20"].Type)
+		assert.Equal(t, models.IPv6AddressActive, byAddress["2001:db8:1::20"].State)
+		assert.Equal(t, "link-local", byAddress["fe80::10"].Type)
+		assert.Equal(t, models.IPv6SourceScan, byAddress["fe80::10"].Source)
+
+		assert.Error(t, deviceRepo.RecordIPv6Address(ctx, first.ID, "192.168.60.10", models.IPv6SourceScan, time.Now()))
+	})
+
+	t.Run("FindByPrefix", func(t *testing.T) {
+		_, prefix, _ := net.ParseCIDR("2001:db8:1::/64")
+		devices, err := deviceRepo.FindByIPv6Prefix(ctx, prefix)
+		require.NoError(t, err)
+		require.Len(t, devices, 1)
+		assert.Equal(t, first.ID, devices[0].ID)
+
+		_, prefix, _ = net.ParseCIDR("2001:db8:2::/64")
+		devices, err = deviceRepo.FindByIPv6Prefix(ctx, prefix)
+		require.NoError(t, err)
+		assert.Empty(t, devices)
+	})
+
+	t.Run("SweepKeepsIPv6Addresses", func(t *testing.T) {
+		swept := createTestDevice("192.168.60.10", "IPv6 Device")
+		swept.ID = first.ID
+		_, err := deviceRepo.CreateOrUpdate(ctx, swept)
+		require.NoError(t, err)
+
+		reloaded, err := deviceRepo.FindByID(ctx, first.ID)
+		require.NoError(t, err)
+		require.NotNil(t, reloaded.IPv6Global)
+		assert.Equal(t, global, *reloaded.IPv6Global)
+		assert.Contains(t, reloaded.IPv6Addresses, "2001:db8:1::30")
+	})
+
+	t.Run("AddressMovesToNewDevice", func(t *testing.T) {
+		require.NoError(t, deviceRepo.RecordIPv6Address(ctx, second.ID, "2001:db8:1::20", models.IPv6SourceMulticast, time.Now()))
+
+		found, err := deviceRepo.FindByIPv6(ctx, "2001:db8:1::20")
+		require.NoError(t, err)
+		assert.Equal(t, second.ID, found.ID)
+	})
+
+	t.Run("UnseenAddressesTurnStaleThenExpire", func(t *testing.T) {
+		now := time.Now()
+		require.NoError(t, deviceRepo.RecordIPv6Address(ctx, first.ID, "2001:db8:1::30", models.IPv6SourceNDP, now.Add(-10*24*time.Hour)))
+		require.NoError(t, deviceRepo.RecordIPv6Address(ctx, first.ID, "2001:db8:1::40", models.IPv6SourceNDP, now.Add(-2*24*time.Hour)))
+
+		expired, err := deviceRepo.ExpireIPv6Addresses(ctx, now.Add(-24*time.Hour), now.Add(-7*24*time.Hour), now.Add(-30*24*time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 1, expired)
+
+		_, err = deviceRepo.FindByIPv6(ctx, "2001:db8:1::30")
+		assert.Equal(t, db.ErrNotFound, err, "expired addresses no longer identify a device")
+
+		found, err := deviceRepo.FindByIPv6(ctx, "2001:db8:1::40")
+		require.NoError(t, err, "stale addresses still identify a device")
+		assert.Equal(t, first.ID, found.ID)
+
+		addresses, err := deviceRepo.FindIPv6Addresses(ctx, first.ID)
+		require.NoError(t, err)
+		states := make(map[string]models.IPv6AddressState)
+		for _, address := range addresses {
+			states[address.Address] = address.State
+		}
+		assert.Equal(t, models.IPv6AddressExpired, states["2001:db8:1::30"])
+		assert.Equal(t, models.IPv6AddressStale, states["2001:db8:1::40"])
+
+		reloaded, err := deviceRepo.FindByID(ctx, first.ID)
+		require.NoError(t, err)
+		assert.NotContains(t, reloaded.IPv6Addresses, "2001:db8:1::30")
+
+		// A stale address seen again is active again
+		require.NoError(t, deviceRepo.RecordIPv6Address(ctx, first.ID, "2001:db8:1::40", models.IPv6SourceNDP, now))
+		addresses, err = deviceRepo.FindIPv6Addresses(ctx, first.ID)
+		require.NoError(t, err)
+		for _, address := range addresses {
+			if address.Address == "2001:db8:1::40" {
+				assert.Equal(t, models.IPv6AddressActive, address.State)
+			}
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package integration
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/metrics"
+	"reconya-ai/internal/network"
+	"reconya-ai/models"
+	"reconya-ai/tests/testutils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventoryMetrics_Integration(t *testing.T) {
+	factory, cleanup := testutils.SetupTestRepositoryFactory(t)
+	defer cleanup()
+
+	cfg := testutils.GetTestConfig()
+	dbManager := db.NewDBManager()
+	networkRepo := factory.NewNetworkRepository()
+
+	networkService := network.NewNetworkService(networkRepo, cfg, dbManager)
+	deviceService := device.NewDeviceService(factory.NewDeviceRepository(), networkService, cfg, dbManager, nil)
+	deviceService.RegisterMetrics()
+
+	testNetwork, err := networkRepo.CreateOrUpdate(context.Background(), &models.Network{ID: uuid.New().String(), CIDR: "10.50.0.0/24"})
+	require.NoError(t, err)
+
+	for i, ip := range []string{"10.50.0.1", "10.50.0.2", "10.50.0.3"} {
+		mac := "00:16:3E:50:00:0" + string(rune('1'+i))
+		d := &models.Device{IPv4: ip, MAC: &mac, NetworkID: testNetwork.ID}
+		if i == 0 {
+			d.DeviceType = models.DeviceTypeRouter
+		}
+		_, err := deviceService.CreateOrUpdate(d)
+		require.NoError(t, err)
+	}
+
+	var out strings.Builder
+	require.NoError(t, metrics.Default.Write(&out))
+	scraped := out.String()
+
+	assert.Contains(t, scraped, "# TYPE reconya_devices gauge\n")
+	assert.Contains(t, scraped, `reconya_devices{status="online",type="router",network="10.50.0.0/24"} 1`+"\n")
+	assert.Contains(t, scraped, `reconya_devices{status="online",type="unknown",network="10.50.0.0/24"} 2`+"\n")
+
+	// Saves go through the DBManager queue
+	assert.Contains(t, scraped, "# TYPE reconya_db_operation_duration_seconds histogram\n")
+	assert.NotContains(t, scraped, "reconya_db_operation_duration_seconds_count 0\n")
+	assert.Contains(t, scraped, "reconya_db_queue_depth ")
+}
@@ -0,0 +1,72 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/neighbor"
+	"reconya-ai/internal/network"
+	"reconya-ai/models"
+	"reconya-ai/tests/testutils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNeighborService_Integration(t *testing.T) {
+	factory, cleanup := testutils.SetupTestRepositoryFactory(t)
+	defer cleanup()
+
+	cfg := testutils.GetTestConfig()
+	dbManager := db.NewDBManager()
+	networkRepo := factory.NewNetworkRepository()
+
+	networkService := network.NewNetworkService(networkRepo, cfg, dbManager)
+	deviceService := device.NewDeviceService(factory.NewDeviceRepository(), networkService, cfg, dbManager, nil)
+	neighborService := neighbor.NewNeighborService(deviceService, networkService, nil)
+
+	testNetwork, err := networkRepo.CreateOrUpdate(context.Background(), &models.Network{ID: uuid.New().String(), CIDR: "10.7.0.0/24"})
+	require.NoError(t, err)
+
+	mac := "00:16:3E:00:00:10"
+	known, err := deviceService.CreateOrUpdate(&models.Device{IPv4: "10.7.0.10", MAC: &mac, NetworkID: testNetwork.ID})
+	require.NoError(t, err)
+	known.Status = models.DeviceStatusOffline
+	require.NoError(t, deviceService.UpdateDeviceRecord(known))
+
+	t.Run("ReachableNeighborMarksDeviceOnline", func(t *testing.T) {
+		neighborService.Handle(neighbor.Sighting{IP: "10.7.0.10", MAC: mac, State: "reachable", Time: time.Now()})
+
+		d, err := deviceService.FindByIPv4("10.7.0.10")
+		require.NoError(t, err)
+		require.NotNil(t, d)
+		assert.Equal(t, known.ID, d.ID)
+		assert.Equal(t, models.DeviceStatusOnline, d.Status)
+	})
+
+	t.Run("NewNeighborInKnownNetworkIsAdded", func(t *testing.T) {
+		neighborService.Handle(neighbor.Sighting{IP: "10.7.0.20", MAC: "00:16:3E:00:00:20", State: "stale", Time: time.Now()})
+
+		d, err := deviceService.FindByIPv4("10.7.0.20")
+		require.NoError(t, err)
+		require.NotNil(t, d)
+		assert.Equal(t, testNetwork.ID, d.NetworkID)
+		require.NotNil(t, d.MAC)
+		assert.Equal(t, "00:16:3E:00:00:20", *d.MAC)
+	})
+
+	t.Run("UnresolvedAndForeignNeighborsAreIgnored", func(t *testing.T) {
+		neighborService.Handle(neighbor.Sighting{IP: "10.7.0.30", State: "incomplete", Time: time.Now()})
+		neighborService.Handle(neighbor.Sighting{IP: "192.0.2.5", MAC: "00:16:3E:00:00:99", State: "reachable", Time: time.Now()})
+
+		for _, ip := range []string{"10.7.0.30", "192.0.2.5"} {
+			d, err := deviceService.FindByIPv4(ip)
+			require.NoError(t, err)
+			assert.Nil(t, d, ip)
+		}
+	})
+}
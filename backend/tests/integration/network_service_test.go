@@ -0,0 +1,70 @@
+package integration
+
+import (
+	"testing"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/network"
+	"reconya-ai/models"
+	"reconya-ai/tests/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkService_Integration(t *testing.T) {
+	factory, cleanup := testutils.SetupTestRepositoryFactory(t)
+	defer cleanup()
+
+	networkService := network.NewNetworkService(factory.NewNetworkRepository(), testutils.GetTestConfig(), db.NewDBManager())
+
+	created, err := networkService.Create("Office", "10.6.0.0/24", "")
+	require.NoError(t, err)
+
+	t.Run("NewNetworksAreIPv4", func(t *testing.T) {
+		n, err := networkService.FindByID(created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.AddressFamilyIPv4, n.AddressFamily)
+		assert.Nil(t, n.IPv6Prefix)
+	})
+
+	t.Run("IPv6PrefixMakesNetworkDualStack", func(t *testing.T) {
+		_, err := networkService.SetIPv6Prefix(created.ID, "2001:db8:6::/64")
+		require.NoError(t, err)
+
+		n, err := networkService.FindByID(created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.AddressFamilyDual, n.AddressFamily)
+		require.NotNil(t, n.IPv6Prefix)
+		assert.Equal(t, "2001:db8:6::/64", *n.IPv6Prefix)
+
+		networks, err := networkService.FindAll()
+		require.NoError(t, err)
+		require.Len(t, networks, 1)
+		assert.True(t, networks[0].IsIPv6Enabled())
+	})
+
+	t.Run("UpdateKeepsIPv6Prefix", func(t *testing.T) {
+		_, err := networkService.Update(created.ID, "Office LAN", "10.6.0.0/24", "")
+		require.NoError(t, err)
+
+		n, err := networkService.FindByID(created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "2001:db8:6::/64", n.GetIPv6Prefix())
+	})
+
+	t.Run("InvalidPrefixIsRejected", func(t *testing.T) {
+		_, err := networkService.SetIPv6Prefix(created.ID, "not-a-prefix")
+		assert.Error(t, err)
+	})
+
+	t.Run("EmptyPrefixTurnsIPv6Off", func(t *testing.T) {
+		_, err := networkService.SetIPv6Prefix(created.ID, "")
+		require.NoError(t, err)
+
+		n, err := networkService.FindByID(created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.AddressFamilyIPv4, n.AddressFamily)
+		assert.Nil(t, n.IPv6Prefix)
+	})
+}
@@ -0,0 +1,123 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/fingerprint"
+	"reconya-ai/internal/jobqueue"
+	"reconya-ai/internal/network"
+	"reconya-ai/internal/portscan"
+	"reconya-ai/internal/rescan"
+	"reconya-ai/models"
+	"reconya-ai/tests/testutils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRescanService_Integration(t *testing.T) {
+	factory, cleanup := testutils.SetupTestRepositoryFactory(t)
+	defer cleanup()
+
+	cfg := testutils.GetTestConfig()
+	dbManager := db.NewDBManager()
+	networkRepo := factory.NewNetworkRepository()
+	networkService := network.NewNetworkService(networkRepo, cfg, dbManager)
+	deviceService := device.NewDeviceService(factory.NewDeviceRepository(), networkService, cfg, dbManager, nil)
+	portScanService := portscan.NewPortScanService(deviceService, nil, cfg)
+	queue := jobqueue.NewJobQueueService(factory.NewScanJobRepository())
+	rescanService := rescan.NewRescanService(deviceService, portScanService, fingerprint.NewFingerprintService(), queue, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- queue.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	testNetwork, err := networkRepo.CreateOrUpdate(context.Background(), &models.Network{ID: uuid.New().String(), CIDR: "127.0.0.0/8"})
+	require.NoError(t, err)
+	hostname := "office-printer"
+	target, err := deviceService.CreateOrUpdate(&models.Device{
+		IPv4:       "127.0.0.9",
+		Hostname:   &hostname,
+		NetworkID:  testNetwork.ID,
+		Status:     models.DeviceStatusOnline,
+		DeviceType: models.DeviceTypeUnknown,
+	})
+	require.NoError(t, err)
+
+	t.Run("Rejects invalid stages and unknown devices", func(t *testing.T) {
+		_, err := rescanService.Start(target.ID, models.RescanOptions{})
+		assert.Error(t, err)
+		_, err = rescanService.Start(uuid.New().String(), models.RescanOptions{Ports: models.RescanPortsQuick})
+		assert.Error(t, err)
+		_, err = rescanService.Start(target.ID, models.RescanOptions{Profile: "no-such-profile"})
+		assert.Error(t, err)
+		// ot-safe never port scans and skips OS detection, leaving nothing to rescan
+		_, err = rescanService.Start(target.ID, models.RescanOptions{Profile: "ot-safe"})
+		assert.ErrorContains(t, err, "ot-safe")
+	})
+
+	t.Run("Runs the stages and reports what changed", func(t *testing.T) {
+		// Without ports the web stage has nothing to fetch and fingerprinting needs
+		// no nmap, the type comes from the hostname
+		job, err := rescanService.Start(target.ID, models.RescanOptions{Web: true})
+		require.NoError(t, err)
+		assert.Equal(t, models.ScanJobPriorityManual, job.Priority)
+		assert.Equal(t, 1, job.MaxAttempts)
+
+		require.Eventually(t, func() bool {
+			job, err = queue.FindByID(job.ID)
+			return err == nil && job.Status == models.ScanJobCompleted
+		}, 10*time.Second, 20*time.Millisecond)
+		assert.Equal(t, 100, job.Progress)
+		assert.Equal(t, "saving", job.Stage)
+
+		var diff models.DeviceDiff
+		require.NoError(t, json.Unmarshal(job.Result, &diff))
+		require.Len(t, diff.Fields, 1)
+		assert.Equal(t, "device_type", diff.Fields[0].Field)
+		assert.Equal(t, string(models.DeviceTypePrinter), diff.Fields[0].After)
+
+		updated, err := deviceService.FindByID(target.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.DeviceTypePrinter, updated.DeviceType)
+	})
+
+	t.Run("Ports closed since the last scan are removed", func(t *testing.T) {
+		// A stand-in nmap that finds the host up with nothing open
+		bin := t.TempDir()
+		script := "#!/bin/sh\necho '<nmaprun><host><status state=\"up\"/><address addr=\"127.0.0.9\" addrtype=\"ipv4\"/><ports></ports></host></nmaprun>'\n"
+		require.NoError(t, os.WriteFile(filepath.Join(bin, "nmap"), []byte(script), 0o755))
+		t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+		require.NoError(t, deviceService.ReplacePorts(target.ID, []models.Port{{Number: "9100", Protocol: "tcp", State: "open", Service: "jetdirect"}}))
+
+		job, err := rescanService.Start(target.ID, models.RescanOptions{Ports: models.RescanPortsQuick})
+		require.NoError(t, err)
+		require.Eventually(t, func() bool {
+			job, err = queue.FindByID(job.ID)
+			return err == nil && (job.Status == models.ScanJobCompleted || job.Status == models.ScanJobFailed)
+		}, 10*time.Second, 20*time.Millisecond)
+		require.Equal(t, models.ScanJobCompleted, job.Status, job.Error)
+
+		var diff models.DeviceDiff
+		require.NoError(t, json.Unmarshal(job.Result, &diff))
+		require.Len(t, diff.PortsClosed, 1)
+		assert.Equal(t, "9100", diff.PortsClosed[0].Number)
+
+		updated, err := deviceService.FindByID(target.ID)
+		require.NoError(t, err)
+		assert.Empty(t, updated.Ports)
+	})
+}
@@ -0,0 +1,207 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"reconya-ai/internal/jobqueue"
+	"reconya-ai/models"
+	"reconya-ai/tests/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanJobRepository_Integration(t *testing.T) {
+	factory, cleanup := testutils.SetupTestRepositoryFactory(t)
+	defer cleanup()
+
+	repo := factory.NewScanJobRepository()
+	ctx := context.Background()
+
+	t.Run("Deduplicates per device and keeps the higher priority", func(t *testing.T) {
+		first, created, err := repo.Enqueue(ctx, &models.ScanJob{Type: models.ScanJobPortScan, DeviceID: "dedup", MaxAttempts: 3})
+		require.NoError(t, err)
+		assert.True(t, created)
+		assert.Equal(t, models.ScanJobQueued, first.Status)
+
+		again, created, err := repo.Enqueue(ctx, &models.ScanJob{Type: models.ScanJobPortScan, DeviceID: "dedup", Priority: models.ScanJobPriorityManual, MaxAttempts: 3})
+		require.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, first.ID, again.ID)
+		assert.Equal(t, models.ScanJobPriorityManual, again.Priority)
+
+		lower, _, err := repo.Enqueue(ctx, &models.ScanJob{Type: models.ScanJobPortScan, DeviceID: "dedup", MaxAttempts: 3})
+		require.NoError(t, err)
+		assert.Equal(t, models.ScanJobPriorityManual, lower.Priority)
+
+		// Another type for the same device is a separate job
+		web, created, err := repo.Enqueue(ctx, &models.ScanJob{Type: models.ScanJobWebScan, DeviceID: "dedup", MaxAttempts: 3})
+		require.NoError(t, err)
+		assert.True(t, created)
+		assert.NotEqual(t, first.ID, web.ID)
+	})
+
+	t.Run("Claims by priority and skips jobs not due", func(t *testing.T) {
+		_, _, err := repo.Enqueue(ctx, &models.ScanJob{Type: models.ScanJobFingerprint, DeviceID: "normal", MaxAttempts: 3})
+		require.NoError(t, err)
+		_, _, err = repo.Enqueue(ctx, &models.ScanJob{Type: models.ScanJobFingerprint, DeviceID: "later", Priority: 50, RunAfter: time.Now().Add(time.Hour), MaxAttempts: 3})
+		require.NoError(t, err)
+		manual, _, err := repo.Enqueue(ctx, &models.ScanJob{Type: models.ScanJobFingerprint, DeviceID: "manual", Priority: models.ScanJobPriorityManual, MaxAttempts: 3})
+		require.NoError(t, err)
+
+		claimed, err := repo.ClaimNext(ctx, models.ScanJobFingerprint, time.Now())
+		require.NoError(t, err)
+		require.NotNil(t, claimed)
+		assert.Equal(t, manual.ID, claimed.ID)
+		assert.Equal(t, models.ScanJobRunning, claimed.Status)
+		assert.Equal(t, 1, claimed.Attempts)
+
+		claimed, err = repo.ClaimNext(ctx, models.ScanJobFingerprint, time.Now())
+		require.NoError(t, err)
+		require.NotNil(t, claimed)
+		assert.Equal(t, "normal", claimed.DeviceID)
+
+		claimed, err = repo.ClaimNext(ctx, models.ScanJobFingerprint, time.Now())
+		require.NoError(t, err)
+		assert.Nil(t, claimed)
+
+		// A running job is requeued after a restart without using up an attempt
+		requeued, err := repo.RequeueRunning(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), requeued)
+		job, err := repo.FindByID(ctx, manual.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.ScanJobQueued, job.Status)
+		assert.Equal(t, 0, job.Attempts)
+	})
+
+	t.Run("Cancel and priority only apply to active jobs", func(t *testing.T) {
+		job, _, err := repo.Enqueue(ctx, &models.ScanJob{Type: models.ScanJobPortScan, DeviceID: "cancel", MaxAttempts: 3})
+		require.NoError(t, err)
+
+		updated, err := repo.SetPriority(ctx, job.ID, 5)
+		require.NoError(t, err)
+		assert.Equal(t, 5, updated.Priority)
+
+		cancelled, err := repo.Cancel(ctx, job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.ScanJobCancelled, cancelled.Status)
+		assert.NotNil(t, cancelled.FinishedAt)
+
+		_, err = repo.Cancel(ctx, job.ID)
+		assert.Error(t, err)
+		_, err = repo.SetPriority(ctx, job.ID, 1)
+		assert.Error(t, err)
+
+		// The device can be queued again once its job is finished
+		_, created, err := repo.Enqueue(ctx, &models.ScanJob{Type: models.ScanJobPortScan, DeviceID: "cancel", MaxAttempts: 3})
+		require.NoError(t, err)
+		assert.True(t, created)
+
+		jobs, err := repo.FindAll(ctx, models.ScanJobFilter{DeviceID: "cancel"})
+		require.NoError(t, err)
+		require.Len(t, jobs, 2)
+		assert.Equal(t, models.ScanJobQueued, jobs[0].Status)
+		assert.Equal(t, models.ScanJobCancelled, jobs[1].Status)
+	})
+}
+
+func TestJobQueueService_Integration(t *testing.T) {
+	factory, cleanup := testutils.SetupTestRepositoryFactory(t)
+	defer cleanup()
+
+	queue := jobqueue.NewJobQueueService(factory.NewScanJobRepository())
+
+	ran := make(chan string, 10)
+	started := make(chan string, 10)
+	queue.Register(models.ScanJobPortScan, 2, func(ctx context.Context, job *models.ScanJob) error {
+		switch job.DeviceID {
+		case "broken":
+			return errors.New("nmap exploded")
+		case "gone":
+			return jobqueue.Permanent(errors.New("device not found"))
+		case "slow":
+			started <- job.ID
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		ran <- job.DeviceID
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- queue.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitFor := func(t *testing.T, id string, status models.ScanJobStatus, attempts int) *models.ScanJob {
+		var job *models.ScanJob
+		require.Eventually(t, func() bool {
+			var err error
+			job, err = queue.FindByID(id)
+			return err == nil && job.Status == status && job.Attempts == attempts
+		}, 5*time.Second, 20*time.Millisecond)
+		return job
+	}
+
+	t.Run("Runs jobs and marks them completed", func(t *testing.T) {
+		job, err := queue.EnqueueDevice(models.ScanJobPortScan, "ok", models.ScanJobPriorityNormal)
+		require.NoError(t, err)
+		select {
+		case deviceID := <-ran:
+			assert.Equal(t, "ok", deviceID)
+		case <-time.After(5 * time.Second):
+			t.Fatal("job did not run")
+		}
+		finished := waitFor(t, job.ID, models.ScanJobCompleted, 1)
+		assert.NotNil(t, finished.FinishedAt)
+	})
+
+	t.Run("Retries failures with backoff", func(t *testing.T) {
+		job, err := queue.EnqueueDevice(models.ScanJobPortScan, "broken", models.ScanJobPriorityNormal)
+		require.NoError(t, err)
+		retried := waitFor(t, job.ID, models.ScanJobQueued, 1)
+		assert.Equal(t, "nmap exploded", retried.Error)
+		assert.WithinDuration(t, time.Now().Add(jobqueue.Backoff(1)), retried.RunAfter, 5*time.Second)
+	})
+
+	t.Run("Permanent errors and the last attempt fail the job", func(t *testing.T) {
+		job, err := queue.EnqueueDevice(models.ScanJobPortScan, "gone", models.ScanJobPriorityNormal)
+		require.NoError(t, err)
+		failed := waitFor(t, job.ID, models.ScanJobFailed, 1)
+		assert.Equal(t, "device not found", failed.Error)
+	})
+
+	t.Run("Cancelling stops a running job", func(t *testing.T) {
+		job, err := queue.EnqueueDevice(models.ScanJobPortScan, "slow", models.ScanJobPriorityNormal)
+		require.NoError(t, err)
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("job did not start")
+		}
+		_, err = queue.Cancel(job.ID)
+		require.NoError(t, err)
+		cancelled := waitFor(t, job.ID, models.ScanJobCancelled, 1)
+		assert.Empty(t, cancelled.Error)
+	})
+
+	t.Run("Rejects unknown types", func(t *testing.T) {
+		_, err := queue.EnqueueDevice(models.ScanJobWebScan, "ok", models.ScanJobPriorityNormal)
+		assert.Error(t, err)
+	})
+}
+
+func TestJobQueueBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, jobqueue.Backoff(1))
+	assert.Equal(t, 2*time.Minute, jobqueue.Backoff(2))
+	assert.Equal(t, 8*time.Minute, jobqueue.Backoff(3))
+	assert.Equal(t, 15*time.Minute, jobqueue.Backoff(4))
+	assert.Equal(t, 15*time.Minute, jobqueue.Backoff(10))
+}
@@ -0,0 +1,99 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/network"
+	"reconya-ai/internal/scanprofile"
+	"reconya-ai/models"
+	"reconya-ai/tests/testutils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanProfileService_Integration(t *testing.T) {
+	factory, cleanup := testutils.SetupTestRepositoryFactory(t)
+	defer cleanup()
+
+	cfg := testutils.GetTestConfig()
+	networkRepo := factory.NewNetworkRepository()
+	networkService := network.NewNetworkService(networkRepo, cfg, db.NewDBManager())
+	service := scanprofile.NewScanProfileService(factory.NewScanProfileRepository(), networkService)
+
+	// Seeding twice updates the built-in profiles instead of duplicating them
+	require.NoError(t, service.SeedBuiltins())
+	require.NoError(t, service.SeedBuiltins())
+	profiles, err := service.FindAll()
+	require.NoError(t, err)
+	require.Len(t, profiles, 5)
+	assert.Equal(t, "quick", profiles[0].Name)
+	assert.True(t, profiles[0].Builtin)
+
+	ctx := context.Background()
+	testNetwork, err := networkRepo.CreateOrUpdate(ctx, &models.Network{ID: uuid.New().String(), CIDR: "10.9.0.0/24"})
+	require.NoError(t, err)
+
+	t.Run("Built-in profiles cannot be changed or deleted", func(t *testing.T) {
+		standard, err := service.Resolve("standard")
+		require.NoError(t, err)
+		assert.Equal(t, []models.DiscoveryMethod{models.DiscoveryICMP, models.DiscoveryARP, models.DiscoveryTCP}, standard.Discovery)
+
+		changed := *standard
+		changed.Timing = 5
+		_, err = service.Update(standard.ID, &changed)
+		assert.Error(t, err)
+		assert.Error(t, service.Delete(standard.ID))
+	})
+
+	t.Run("Custom profiles are validated and unique", func(t *testing.T) {
+		_, err := service.Create(&models.ScanProfile{Name: "broken"})
+		assert.Error(t, err)
+
+		_, err = service.Create(&models.ScanProfile{Name: "deep", Discovery: []models.DiscoveryMethod{models.DiscoveryARP}})
+		assert.ErrorContains(t, err, "already exists")
+	})
+
+	t.Run("Networks use their profile and fall back to the default", func(t *testing.T) {
+		assert.Equal(t, "standard", service.ForNetworkID(testNetwork.ID).Name)
+
+		custom, err := service.Create(&models.ScanProfile{
+			Name:      "plc-cell",
+			Builtin:   true,
+			Discovery: []models.DiscoveryMethod{models.DiscoveryARP},
+			Timing:    1,
+			MaxRate:   5,
+		})
+		require.NoError(t, err)
+		assert.False(t, custom.Builtin)
+
+		assigned, err := service.SetNetworkProfile(testNetwork.ID, "plc-cell")
+		require.NoError(t, err)
+		assert.Equal(t, custom.ID, assigned.ID)
+
+		stored, err := networkService.FindByID(testNetwork.ID)
+		require.NoError(t, err)
+		assert.Equal(t, custom.ID, stored.ScanProfileID)
+		assert.Equal(t, 5, service.ForNetworkID(testNetwork.ID).MaxRate)
+
+		custom.MaxRate = 2
+		_, err = service.Update(custom.ID, custom)
+		require.NoError(t, err)
+		assert.Equal(t, 2, service.ForNetworkID(testNetwork.ID).MaxRate)
+
+		// Deleting the profile sends its networks back to the default
+		require.NoError(t, service.Delete(custom.ID))
+		stored, err = networkService.FindByID(testNetwork.ID)
+		require.NoError(t, err)
+		assert.Empty(t, stored.ScanProfileID)
+		assert.Equal(t, "standard", service.ForNetworkID(testNetwork.ID).Name)
+
+		_, err = service.SetNetworkProfile(uuid.New().String(), "quick")
+		assert.ErrorIs(t, err, db.ErrNotFound)
+		_, err = service.SetNetworkProfile(testNetwork.ID, "missing")
+		assert.Error(t, err)
+	})
+}
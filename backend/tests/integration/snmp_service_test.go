@@ -0,0 +1,134 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/network"
+	"reconya-ai/internal/snmp"
+	"reconya-ai/models"
+	"reconya-ai/tests/testutils"
+
+	"github.com/google/uuid"
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSNMPService_Integration(t *testing.T) {
+	factory, cleanup := testutils.SetupTestRepositoryFactory(t)
+	defer cleanup()
+
+	cfg := testutils.GetTestConfig()
+	dbManager := db.NewDBManager()
+	networkRepo := factory.NewNetworkRepository()
+	credentialRepo := factory.NewSNMPCredentialRepository()
+
+	networkService := network.NewNetworkService(networkRepo, cfg, dbManager)
+	deviceService := device.NewDeviceService(factory.NewDeviceRepository(), networkService, cfg, dbManager, nil)
+	snmpService := snmp.NewSNMPService(credentialRepo, deviceService, cfg.SecretKey)
+
+	ctx := context.Background()
+
+	testNetwork, err := networkRepo.CreateOrUpdate(ctx, &models.Network{ID: uuid.New().String(), CIDR: "127.0.0.0/8"})
+	require.NoError(t, err)
+
+	agent := testutils.NewSNMPAgent(t, "s3cret", []gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.2.1.1.1.0", Type: gosnmp.OctetString, Value: []byte("Cisco IOS Software, C2960 Software, Version 15.0(2)SE11")},
+		{Name: ".1.3.6.1.2.1.1.2.0", Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.4.1.9.1.1208"},
+		{Name: ".1.3.6.1.2.1.1.3.0", Type: gosnmp.TimeTicks, Value: uint32(6000)},
+		{Name: ".1.3.6.1.2.1.1.5.0", Type: gosnmp.OctetString, Value: []byte("core-sw1")},
+		{Name: ".1.3.6.1.2.1.1.6.0", Type: gosnmp.OctetString, Value: []byte("Rack 3")},
+		{Name: ".1.3.6.1.2.1.2.2.1.2.1", Type: gosnmp.OctetString, Value: []byte("GigabitEthernet0/1")},
+		{Name: ".1.3.6.1.2.1.2.2.1.8.1", Type: gosnmp.Integer, Value: 1},
+		// ARP entry and forwarding entry for a host on a remote subnet
+		{Name: ".1.3.6.1.2.1.4.22.1.2.1.10.20.0.5", Type: gosnmp.OctetString, Value: []byte{0xaa, 0xbb, 0xcc, 0x00, 0x00, 0x05}},
+		{Name: ".1.3.6.1.2.1.17.1.4.1.2.3", Type: gosnmp.Integer, Value: 1},
+		{Name: ".1.3.6.1.2.1.17.4.3.1.2.170.187.204.0.0.5", Type: gosnmp.Integer, Value: 3},
+	})
+
+	remoteNetwork, err := networkRepo.CreateOrUpdate(ctx, &models.Network{ID: uuid.New().String(), CIDR: "10.20.0.0/24"})
+	require.NoError(t, err)
+	remoteDevice, err := deviceService.CreateOrUpdate(&models.Device{
+		IPv4:      "10.20.0.5",
+		NetworkID: remoteNetwork.ID,
+		Status:    models.DeviceStatusOnline,
+	})
+	require.NoError(t, err)
+	require.Nil(t, remoteDevice.MAC)
+
+	t.Run("CredentialsAreEncryptedAtRest", func(t *testing.T) {
+		saved, err := snmpService.SaveCredential(&models.SNMPCredential{
+			NetworkID: testNetwork.ID,
+			Version:   models.SNMPVersion2c,
+			Community: "s3cret",
+			Port:      agent.Port(),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "********", saved.Community)
+
+		stored, err := credentialRepo.FindByID(ctx, saved.ID)
+		require.NoError(t, err)
+		assert.NotEmpty(t, stored.Community)
+		assert.NotEqual(t, "s3cret", stored.Community)
+
+		listed, err := snmpService.ListCredentials(testNetwork.ID)
+		require.NoError(t, err)
+		require.Len(t, listed, 1)
+		assert.Equal(t, "********", listed[0].Community)
+	})
+
+	t.Run("InterrogateDevice", func(t *testing.T) {
+		created, err := deviceService.CreateOrUpdate(&models.Device{
+			Name:      "",
+			IPv4:      "127.0.0.1",
+			NetworkID: testNetwork.ID,
+			Status:    models.DeviceStatusOnline,
+		})
+		require.NoError(t, err)
+
+		updated, err := snmpService.InterrogateDevice(created.ID)
+		require.NoError(t, err)
+		require.NotNil(t, updated.SNMP)
+
+		stored, err := deviceService.FindByID(created.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stored.SNMP)
+		assert.Equal(t, "core-sw1", stored.SNMP.SysName)
+		assert.Equal(t, "Rack 3", stored.SNMP.SysLocation)
+		assert.Equal(t, int64(60), stored.SNMP.UptimeSeconds)
+		require.Len(t, stored.SNMP.Interfaces, 1)
+		assert.Equal(t, "up", stored.SNMP.Interfaces[0].OperStatus)
+
+		require.NotNil(t, stored.Hostname)
+		assert.Equal(t, "core-sw1", *stored.Hostname)
+		require.NotNil(t, stored.OS)
+		assert.Equal(t, "Cisco IOS", stored.OS.Name)
+		assert.Equal(t, "15.0(2)SE11", stored.OS.Version)
+	})
+
+	t.Run("HarvestTablesForRemoteDevices", func(t *testing.T) {
+		stored, err := deviceService.FindByID(remoteDevice.ID)
+		require.NoError(t, err)
+
+		require.NotNil(t, stored.MAC)
+		assert.Equal(t, "AA:BB:CC:00:00:05", *stored.MAC)
+
+		require.NotNil(t, stored.SwitchPort)
+		assert.Equal(t, "127.0.0.1", stored.SwitchPort.SwitchIP)
+		assert.Equal(t, "core-sw1", stored.SwitchPort.SwitchName)
+		assert.Equal(t, 1, stored.SwitchPort.IfIndex)
+		assert.Equal(t, "GigabitEthernet0/1", stored.SwitchPort.Port)
+		assert.Equal(t, models.SwitchPortSourceFDB, stored.SwitchPort.Source)
+	})
+
+	t.Run("DeleteCredentialsForNetwork", func(t *testing.T) {
+		require.NoError(t, snmpService.DeleteCredentialsForNetwork(testNetwork.ID))
+
+		listed, err := snmpService.ListCredentials(testNetwork.ID)
+		require.NoError(t, err)
+		assert.Empty(t, listed)
+	})
+}
@@ -0,0 +1,166 @@
+package integration
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/eventlog"
+	"reconya-ai/internal/network"
+	"reconya-ai/internal/trust"
+	"reconya-ai/models"
+	"reconya-ai/tests/testutils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustService_Integration(t *testing.T) {
+	factory, cleanup := testutils.SetupTestRepositoryFactory(t)
+	defer cleanup()
+
+	cfg := testutils.GetTestConfig()
+	dbManager := db.NewDBManager()
+	networkRepo := factory.NewNetworkRepository()
+
+	networkService := network.NewNetworkService(networkRepo, cfg, dbManager)
+	deviceService := device.NewDeviceService(factory.NewDeviceRepository(), networkService, cfg, dbManager, nil)
+	eventLogService := eventlog.NewEventLogService(factory.NewEventLogRepository(), deviceService, dbManager)
+	trustService := trust.NewTrustService(factory.NewTrustRepository(), deviceService, networkService, eventLogService)
+
+	ctx := context.Background()
+	testNetwork, err := networkRepo.CreateOrUpdate(ctx, &models.Network{ID: uuid.New().String(), CIDR: "10.1.0.0/24"})
+	require.NoError(t, err)
+
+	sighting := func(t *testing.T, ip, mac string) *models.Device {
+		d := &models.Device{IPv4: ip, NetworkID: testNetwork.ID}
+		if mac != "" {
+			d.MAC = &mac
+		}
+		saved, err := deviceService.CreateOrUpdate(d)
+		require.NoError(t, err)
+		return saved
+	}
+
+	descriptions := func(t *testing.T, deviceID string, eventType models.EEventLogType) []string {
+		events, err := eventLogService.GetAllByDeviceId(deviceID, 50)
+		require.NoError(t, err)
+		var result []string
+		for _, event := range events {
+			if event.Type == eventType {
+				result = append(result, event.Description)
+			}
+		}
+		return result
+	}
+
+	trustState := func(t *testing.T, deviceID string) models.TrustState {
+		d, err := deviceService.FindByID(deviceID)
+		require.NoError(t, err)
+		return d.TrustState
+	}
+
+	t.Run("NewDevicesStartUnapproved", func(t *testing.T) {
+		sweepStartedAt := time.Now().Add(-time.Second)
+		stranger := sighting(t, "10.1.0.10", "aa:bb:cc:00:00:01")
+		assert.Equal(t, models.TrustStateNew, stranger.TrustState)
+
+		trustService.CheckSweep(testNetwork, sweepStartedAt)
+		assert.Len(t, descriptions(t, stranger.ID, models.UnapprovedDeviceSeen), 1)
+
+		// The same device is not reported again on the next sweep
+		trustService.CheckSweep(testNetwork, sweepStartedAt)
+		assert.Len(t, descriptions(t, stranger.ID, models.UnapprovedDeviceSeen), 1)
+	})
+
+	t.Run("BaselineImportApprovesDevices", func(t *testing.T) {
+		known := sighting(t, "10.1.0.11", "AA:BB:CC:00:00:02")
+
+		added, invalid, err := trustService.ImportBaseline("aa-bb-cc-00-00-02 printer\naa:bb:cc:00:00:03\ngarbage", testNetwork.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, added)
+		assert.Equal(t, []string{"garbage"}, invalid)
+		assert.Equal(t, models.TrustStateApproved, trustState(t, known.ID))
+
+		// A baseline device that shows up later is approved by the sweep
+		sweepStartedAt := time.Now().Add(-time.Second)
+		later := sighting(t, "10.1.0.12", "aa:bb:cc:00:00:03")
+		assert.Equal(t, models.TrustStateNew, later.TrustState)
+		trustService.CheckSweep(testNetwork, sweepStartedAt)
+		assert.Equal(t, models.TrustStateApproved, trustState(t, later.ID))
+		assert.Empty(t, descriptions(t, later.ID, models.UnapprovedDeviceSeen))
+
+		added, _, err = trustService.ImportBaseline("aa:bb:cc:00:00:03", testNetwork.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 0, added, "MACs already in the baseline are not added twice")
+
+		_, _, err = trustService.ImportBaseline("aa:bb:cc:00:00:04", uuid.New().String())
+		assert.Error(t, err)
+	})
+
+	t.Run("RescanKeepsApproval", func(t *testing.T) {
+		approved := sighting(t, "10.1.0.11", "aa:bb:cc:00:00:02")
+		assert.Equal(t, models.TrustStateApproved, approved.TrustState)
+
+		// A stale copy written back by a scan does not undo the approval
+		approved.TrustState = models.TrustStateNew
+		require.NoError(t, deviceService.UpdateDeviceRecord(approved))
+		assert.Equal(t, models.TrustStateApproved, trustState(t, approved.ID))
+	})
+
+	t.Run("LockdownRaisesAlerts", func(t *testing.T) {
+		require.NoError(t, trustService.SetLockdown(testNetwork.ID, true))
+		stored, err := networkService.FindByID(testNetwork.ID)
+		require.NoError(t, err)
+		assert.True(t, stored.Lockdown)
+
+		sweepStartedAt := time.Now().Add(-time.Second)
+		intruder := sighting(t, "10.1.0.20", "")
+		known := sighting(t, "10.1.0.11", "aa:bb:cc:00:00:02")
+		trustService.CheckSweep(testNetwork, sweepStartedAt)
+
+		alerts := descriptions(t, intruder.ID, models.Alert)
+		require.Len(t, alerts, 1)
+		assert.True(t, strings.HasPrefix(alerts[0], "Lockdown: unknown MAC"))
+		assert.Empty(t, descriptions(t, known.ID, models.Alert))
+
+		require.NoError(t, trustService.SetLockdown(testNetwork.ID, false))
+		assert.Equal(t, db.ErrNotFound, trustService.SetLockdown(uuid.New().String(), true))
+	})
+
+	t.Run("BlockedDevicesRaiseAlerts", func(t *testing.T) {
+		stranger := sighting(t, "10.1.0.10", "aa:bb:cc:00:00:01")
+		require.NoError(t, trustService.SetTrustState([]string{stranger.ID}, models.TrustStateBlocked))
+		assert.Len(t, descriptions(t, stranger.ID, models.DeviceTrustChanged), 1)
+
+		trustService.CheckSweep(testNetwork, time.Now().Add(-time.Minute))
+		alerts := descriptions(t, stranger.ID, models.Alert)
+		require.Len(t, alerts, 1)
+		assert.Contains(t, alerts[0], "Blocked device [10.1.0.10]")
+
+		assert.Error(t, trustService.SetTrustState([]string{stranger.ID}, "trusted"))
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		reports, err := trustService.Report(testNetwork.ID)
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+
+		var newIPs, blockedIPs []string
+		for _, d := range reports[0].New {
+			newIPs = append(newIPs, d.IPv4)
+		}
+		for _, d := range reports[0].Blocked {
+			blockedIPs = append(blockedIPs, d.IPv4)
+		}
+		assert.Equal(t, []string{"10.1.0.20"}, newIPs)
+		assert.Equal(t, []string{"10.1.0.10"}, blockedIPs)
+
+		_, err = trustService.Report(uuid.New().String())
+		assert.Equal(t, db.ErrNotFound, err)
+	})
+}
@@ -0,0 +1,175 @@
+package integration
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"reconya-ai/db"
+	"reconya-ai/internal/device"
+	"reconya-ai/internal/eventlog"
+	"reconya-ai/internal/network"
+	"reconya-ai/internal/wol"
+	"reconya-ai/models"
+	"reconya-ai/tests/testutils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWakeOnLANService_Integration(t *testing.T) {
+	factory, cleanup := testutils.SetupTestRepositoryFactory(t)
+	defer cleanup()
+
+	cfg := testutils.GetTestConfig()
+	dbManager := db.NewDBManager()
+	networkRepo := factory.NewNetworkRepository()
+	scheduleRepo := factory.NewWakeScheduleRepository()
+
+	networkService := network.NewNetworkService(networkRepo, cfg, dbManager)
+	deviceService := device.NewDeviceService(factory.NewDeviceRepository(), networkService, cfg, dbManager, nil)
+	eventLogService := eventlog.NewEventLogService(factory.NewEventLogRepository(), deviceService, dbManager)
+	wolService := wol.NewWakeOnLANService(scheduleRepo, deviceService, networkService, eventLogService, cfg)
+
+	ctx := context.Background()
+
+	listener, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer listener.Close()
+	opts := models.WakeOptions{
+		Port:             listener.LocalAddr().(*net.UDPAddr).Port,
+		BroadcastAddress: "127.0.0.1",
+	}
+
+	receive := func(t *testing.T) []byte {
+		buf := make([]byte, 256)
+		listener.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := listener.ReadFromUDP(buf)
+		require.NoError(t, err)
+		return buf[:n]
+	}
+
+	eventTypes := func(t *testing.T, deviceID string) []models.EEventLogType {
+		events, err := eventLogService.GetAllByDeviceId(deviceID, 50)
+		require.NoError(t, err)
+		var types []models.EEventLogType
+		for _, event := range events {
+			types = append(types, event.Type)
+		}
+		return types
+	}
+
+	testNetwork, err := networkRepo.CreateOrUpdate(ctx, &models.Network{ID: uuid.New().String(), CIDR: "127.0.0.0/8"})
+	require.NoError(t, err)
+
+	mac := "00:11:22:33:44:55"
+	target, err := deviceService.CreateOrUpdate(&models.Device{
+		IPv4:      "127.0.0.5",
+		MAC:       &mac,
+		NetworkID: testNetwork.ID,
+		Status:    models.DeviceStatusOffline,
+	})
+	require.NoError(t, err)
+
+	t.Run("WakeSendsMagicPacket", func(t *testing.T) {
+		result, err := wolService.Wake(target.ID, opts)
+		require.NoError(t, err)
+		assert.Equal(t, mac, result.MAC)
+
+		packet := receive(t)
+		require.Len(t, packet, 102)
+		assert.Equal(t, []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}, packet[6:12])
+		assert.Contains(t, eventTypes(t, target.ID), models.WakeOnLANSent)
+	})
+
+	t.Run("EarlySweepIsIgnored", func(t *testing.T) {
+		wolService.VerifyWakes(testNetwork, time.Now())
+		types := eventTypes(t, target.ID)
+		assert.NotContains(t, types, models.DeviceWoke)
+		assert.NotContains(t, types, models.DeviceWakeFailed)
+	})
+
+	t.Run("DeviceCameUp", func(t *testing.T) {
+		sweepStartedAt := time.Now().Add(time.Minute)
+
+		stored, err := deviceService.FindByID(target.ID)
+		require.NoError(t, err)
+		seenAt := sweepStartedAt.Add(5 * time.Second)
+		stored.LastSeenOnlineAt = &seenAt
+		require.NoError(t, deviceService.UpdateDeviceRecord(stored))
+
+		wolService.VerifyWakes(testNetwork, sweepStartedAt)
+		assert.Contains(t, eventTypes(t, target.ID), models.DeviceWoke)
+	})
+
+	t.Run("DeviceDidNotWake", func(t *testing.T) {
+		_, err := wolService.Wake(target.ID, opts)
+		require.NoError(t, err)
+		receive(t)
+
+		wolService.VerifyWakes(testNetwork, time.Now().Add(10*time.Minute))
+		assert.Contains(t, eventTypes(t, target.ID), models.DeviceWakeFailed)
+	})
+
+	t.Run("DeviceWithoutMAC", func(t *testing.T) {
+		noMAC, err := deviceService.CreateOrUpdate(&models.Device{IPv4: "127.0.0.6", NetworkID: testNetwork.ID})
+		require.NoError(t, err)
+
+		_, err = wolService.Wake(noMAC.ID, opts)
+		assert.Error(t, err)
+
+		results := wolService.WakeDevices([]string{noMAC.ID, target.ID}, opts)
+		require.Len(t, results, 2)
+		assert.NotEmpty(t, results[0].Error)
+		assert.Empty(t, results[1].Error)
+		receive(t)
+	})
+
+	t.Run("ScheduledNetworkWake", func(t *testing.T) {
+		scheduleOpts := opts
+		scheduleOpts.SecureOnPassword = "01:02:03:04:05:06"
+
+		saved, err := wolService.ScheduleWake(&models.WakeSchedule{
+			NetworkID: testNetwork.ID,
+			RunAt:     time.Now().Add(-time.Second),
+			Options:   scheduleOpts,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "********", saved.Options.SecureOnPassword)
+
+		stored, err := scheduleRepo.FindByID(ctx, saved.ID)
+		require.NoError(t, err)
+		assert.NotEmpty(t, stored.Options.SecureOnPassword)
+		assert.NotEqual(t, "01:02:03:04:05:06", stored.Options.SecureOnPassword)
+
+		wolService.RunDueSchedules()
+
+		packet := receive(t)
+		require.Len(t, packet, 108)
+		assert.Equal(t, []byte{1, 2, 3, 4, 5, 6}, packet[102:])
+
+		stored, err = scheduleRepo.FindByID(ctx, saved.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.WakeScheduleCompleted, stored.Status)
+	})
+
+	t.Run("CancelSchedule", func(t *testing.T) {
+		saved, err := wolService.ScheduleWake(&models.WakeSchedule{
+			DeviceIDs: []string{target.ID},
+			RunAt:     time.Now().Add(time.Hour),
+			Options:   opts,
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, wolService.CancelSchedule(saved.ID))
+		stored, err := scheduleRepo.FindByID(ctx, saved.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.WakeScheduleCancelled, stored.Status)
+		assert.Equal(t, []string{target.ID}, stored.DeviceIDs)
+
+		_, err = wolService.ScheduleWake(&models.WakeSchedule{RunAt: time.Now()})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,132 @@
+package testutils
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/require"
+)
+
+// SNMPAgent is a minimal SNMPv1/v2c agent answering GET, GETNEXT and GETBULK from a static MIB
+type SNMPAgent struct {
+	conn      *net.UDPConn
+	community string
+	oids      []string
+	mib       map[string]gosnmp.SnmpPDU
+	requests  atomic.Int32
+}
+
+// NewSNMPAgent starts an agent on a random localhost UDP port, stopped when the test ends
+func NewSNMPAgent(t *testing.T, community string, pdus []gosnmp.SnmpPDU) *SNMPAgent {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+
+	agent := &SNMPAgent{conn: conn, community: community, mib: make(map[string]gosnmp.SnmpPDU)}
+	for _, pdu := range pdus {
+		agent.mib[pdu.Name] = pdu
+		agent.oids = append(agent.oids, pdu.Name)
+	}
+	sort.Slice(agent.oids, func(i, j int) bool { return compareOIDs(agent.oids[i], agent.oids[j]) < 0 })
+
+	go agent.serve()
+	t.Cleanup(func() { conn.Close() })
+	return agent
+}
+
+// Port returns the UDP port the agent listens on
+func (a *SNMPAgent) Port() int {
+	return a.conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// Requests returns how many requests reached the agent, whatever their community
+func (a *SNMPAgent) Requests() int {
+	return int(a.requests.Load())
+}
+
+func (a *SNMPAgent) serve() {
+	decoder := &gosnmp.GoSNMP{Version: gosnmp.Version2c}
+	buf := make([]byte, 65535)
+	for {
+		n, from, err := a.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		a.requests.Add(1)
+		request, err := decoder.SnmpDecodePacket(buf[:n])
+		if err != nil || request.Community != a.community {
+			// Wrong communities are silently dropped, like a real agent
+			continue
+		}
+
+		response := &gosnmp.SnmpPacket{
+			Version:   request.Version,
+			Community: request.Community,
+			PDUType:   gosnmp.GetResponse,
+			RequestID: request.RequestID,
+		}
+
+		switch request.PDUType {
+		case gosnmp.GetRequest:
+			for _, v := range request.Variables {
+				if pdu, ok := a.mib[v.Name]; ok {
+					response.Variables = append(response.Variables, pdu)
+				} else {
+					response.Variables = append(response.Variables, gosnmp.SnmpPDU{Name: v.Name, Type: gosnmp.NoSuchObject})
+				}
+			}
+		case gosnmp.GetNextRequest:
+			for _, v := range request.Variables {
+				response.Variables = append(response.Variables, a.next(v.Name))
+			}
+		case gosnmp.GetBulkRequest:
+			for _, v := range request.Variables {
+				name := v.Name
+				for i := 0; i < int(request.MaxRepetitions); i++ {
+					pdu := a.next(name)
+					response.Variables = append(response.Variables, pdu)
+					if pdu.Type == gosnmp.EndOfMibView {
+						break
+					}
+					name = pdu.Name
+				}
+			}
+		}
+
+		out, err := response.MarshalMsg()
+		if err != nil {
+			continue
+		}
+		a.conn.WriteToUDP(out, from)
+	}
+}
+
+func (a *SNMPAgent) next(name string) gosnmp.SnmpPDU {
+	for _, oid := range a.oids {
+		if compareOIDs(oid, name) > 0 {
+			return a.mib[oid]
+		}
+	}
+	return gosnmp.SnmpPDU{Name: name, Type: gosnmp.EndOfMibView}
+}
+
+func compareOIDs(a, b string) int {
+	pa := strings.Split(strings.Trim(a, "."), ".")
+	pb := strings.Split(strings.Trim(b, "."), ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		x, _ := strconv.Atoi(pa[i])
+		y, _ := strconv.Atoi(pb[i])
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(pa) - len(pb)
+}